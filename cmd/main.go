@@ -8,97 +8,872 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/goccy/go-graphviz"
-	"github.com/pborman/getopt/v2"
 
+	// Choreia internal checks on the Choreography Automata
+	"github.com/its-hmny/Choreia/internal/diagnostics"
+	// Choreia internal FSA data structure
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	// Choreia internal leveled logger
+	"github.com/its-hmny/Choreia/internal/logging"
+	// Choreia internal layout of the results directory
+	"github.com/its-hmny/Choreia/internal/output"
+	// Choreia internal pipeline stages and hooks
+	"github.com/its-hmny/Choreia/internal/pipeline"
+	// Choreia internal summaries of the Choreography Automata
+	"github.com/its-hmny/Choreia/internal/reports"
 	// Choreia internal static analysis and metatdata extraction module
 	"github.com/its-hmny/Choreia/internal/static_analysis"
 	// Choreia internal Choreography Automata transformation module
 	"github.com/its-hmny/Choreia/internal/transforms"
 )
 
-func main() {
-	// Getopt setup for CLI argument parsing
-	inputFile := getopt.StringLong("input", 'i', "", "The .go file from which extract the Choreography Automata")
-	outputPath := getopt.StringLong("output", 'o', "./choreia.out", "The path to where the extracted data will be saved")
-	traceFlag := getopt.BoolLong("trace", 't', "Pretty prints on the console the AST", "false")
-	svgExportFlag := getopt.BoolLong("svg", 's', "Saves .svg images alongside the .dot file", "false")
-	showUsage := getopt.BoolLong("help", 'h', "Display this help message", "false")
-	getopt.Parse() // Parses the program arguments
+// A subcommand of the program, each one runs the pipeline up to a specific stage
+type command struct {
+	name        string                  // The name used to invoke the subcommand from the CLI
+	description string                  // A short explanation shown in the usage message
+	run         func(args []string) int // The implementation, it returns the exit code
+}
+
+// The list of the available subcommands, in pipeline order
+var commands = []command{
+	{"parse", "Parses the file and exports the ScopeAutomata of each function", runParse},
+	{"meta", "Prints the metadata (functions, channels, arguments) extracted from the file", runMeta},
+	{"ast", "Prints the AST of the file (text or JSON), optionally only the nodes of the given types", runAst},
+	{"inspect", "Prints the ScopeAutomata of a single function in a readable form", runInspect},
+	{"project", "Exports the local view (deterministic) of each Goroutine", runProject},
+	{"spawns", "Prints the spawn tree of the Goroutines (text or JSON) without composing them", runSpawns},
+	{"compose", "Exports the Choreography Automata (global view)", runCompose},
+	{"check", "Checks the Choreography Automata for issues (e.g deadlocks)", runCheck},
+	{"conform", "Checks that the Choreography Automata conforms to a specification automaton", runConform},
+	{"matrix", "Prints which Goroutines communicate with which (CSV or JSON)", runMatrix},
+	{"coverage", "Prints which interactions of the Choreography Automata have been exercised by a test run", runCoverage},
+	{"report", "Saves a Markdown (or HTML) report that summarizes the whole analysis", runReport},
+	{"export", "Runs the whole pipeline and exports both the local and global views", runExport},
+	{"bundle", "Runs the whole pipeline and saves its results in a single .choreia archive", runBundle},
+	{"repro", "Runs the whole pipeline and saves a self-contained fixture of the case (sources, options and every stage)", runRepro},
+	{"view", "Prints the summary of the analysis saved in a .choreia archive", runView},
+}
 
+// The subcommand used when the program is invoked without one (e.g "choreia -i file.go")
+const defaultCommand = "export"
+
+func main() {
 	// Logger setup
 	log.SetPrefix("[Choreia] ")
 	log.SetFlags(log.Ltime | log.Lshortfile)
 
-	// Checks that the input file is provided via CLI argument
-	if *showUsage || inputFile == nil || *inputFile == "" {
-		getopt.Usage()
-		return
+	args := os.Args[1:]
+	name := defaultCommand
+
+	// Without any argument only the list of the available subcommands is shown
+	if len(args) == 0 {
+		printUsage()
+		os.Exit(1)
 	}
 
-	if _, err := os.Stat(*outputPath); err == nil {
-		os.RemoveAll(*outputPath)
+	// The first argument (if not a flag) is the name of the subcommand to be executed
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
 	}
-	os.Mkdir(*outputPath, 0775)
 
-	// Default level for trace option while parsing the file
-	traceOpts := static_analysis.NoTrace
-	// If the extended mode is enabled, it overrides the basic mode
-	if traceFlag != nil && *traceFlag {
-		traceOpts = static_analysis.Trace
+	// The recoverable issues found during the extraction are collected and summarized once the subcommand is done
+	warnings := []static_analysis.Warning{}
+	static_analysis.SetWarningObserver(func(warning static_analysis.Warning) { warnings = append(warnings, warning) })
+	exitHooks = append(exitHooks, func() { printWarnings(warnings) })
+
+	for _, cmd := range commands {
+		if cmd.name == name {
+			// The getopt.Set expects the program name as first argument
+			exitCode := cmd.run(append([]string{cmd.name}, args...))
+			for _, hook := range exitHooks {
+				hook()
+			}
+			os.Exit(exitCode)
+		}
 	}
 
-	// Parses and extracts the metadata from the given file
-	fileMetadata := static_analysis.ExtractMetadata(*inputFile, traceOpts)
+	printUsage()
+	os.Exit(1)
+}
+
+// Prints the list of the available subcommands to the stderr
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: choreia <command> [options] [input_file.go]")
+	fmt.Fprintln(os.Stderr, "\nAvailable commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintln(os.Stderr, "\nUse \"choreia <command> --help\" for the options of each command")
+}
+
+// ----------------------------------------------------------------------------
+// Subcommands
+
+// Parses the file and exports the ScopeAutomata of each function declared in it
+func runParse(args []string) int {
+	opts := parseOptions(newFlagSet("parse"), args)
+	return opts.forEachEntrypoint(func(opts options) int {
+		layout := opts.prepareOutput()
+
+		fileMetadata := newPipeline(opts).Run(pipeline.Extract).Metadata
+		for _, name := range sortedFunctions(fileMetadata) {
+			opts.export(layout.Function(name), fileMetadata.FunctionMeta[name].Automaton)
+		}
+		writeMeta(opts, layout, fileMetadata)
+
+		printUnsupported(fileMetadata)
+		return 0
+	})
+}
+
+// Prints a summary of the metadata extracted from the file to the stdout
+func runMeta(args []string) int {
+	opts := parseOptions(newFlagSet("meta"), args)
+	return opts.forEachEntrypoint(func(opts options) int {
+		fileMetadata := newPipeline(opts).Run(pipeline.Extract).Metadata
+
+		fmt.Println("Global channels:")
+		printChannels(fileMetadata.GlobalChanMeta)
+
+		for _, name := range sortedFunctions(fileMetadata) {
+			funcMeta := fileMetadata.FunctionMeta[name]
+			fmt.Printf("\nFunction %s:\n", name)
+			if funcMeta.External {
+				fmt.Printf("  external (declared without a body), modeled by a stub: %t\n", funcMeta.IsStub)
+			}
+			for _, arg := range funcMeta.InlineArgs {
+				fmt.Printf("  argument #%d %s (%s)\n", arg.Offset, arg.Name, arg.Type)
+			}
+			for _, result := range funcMeta.ReturnArgs {
+				fmt.Printf("  result #%d %s (channel)\n", result.Offset, result.Name)
+			}
+			printChannels(funcMeta.ChanMeta)
+		}
+
+		fmt.Printf("\n%s", fileMetadata.Unsupported)
+		return 0
+	})
+}
+
+// Prints to the stdout the AST of the file (or of each file of the package) as seen by the static analysis, with
+// the position of each node. The --node option restricts it to the subtrees of the nodes of the given types (e.g
+// "GoStmt,SelectStmt"), so that the construct behind an unexpected extraction can be attached to a bug report
+func runAst(args []string) int {
+	flagSet := newFlagSet("ast")
+	format := flagSet.EnumLong("format", 'f', []string{"text", "json"}, "text", "The output format of the AST (text|json)")
+	nodeTypes := flagSet.ListLong("node", 0, "Prints only the subtrees of the nodes of the given types (e.g 'GoStmt,SendStmt', as named in go/ast)")
+	opts := parseOptions(flagSet, args)
+	return opts.forEachEntrypoint(func(opts options) int {
+		tree := static_analysis.NewSyntaxTree(static_analysis.ParseSource(opts.inputFile, opts.traceMode), *nodeTypes)
+		if len(tree) == 0 {
+			log.Printf("No node of type %s found\n", strings.Join(*nodeTypes, ", "))
+		}
 
-	for _, funcMeta := range fileMetadata.FunctionMeta {
-		// Export the current function automata as .dot file
-		funcMeta.Automaton.Export(fmt.Sprintf("%s/%s.dot", *outputPath, funcMeta.Name), graphviz.XDOT)
-		// Additional export of .svg function automata
-		if svgExportFlag != nil && *svgExportFlag {
-			funcMeta.Automaton.Export(fmt.Sprintf("%s/%s.svg", *outputPath, funcMeta.Name), graphviz.SVG)
+		write := tree.WriteText
+		if *format == "json" {
+			write = tree.WriteJSON
 		}
+		if err := write(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return 0
+	})
+}
+
+// Prints to the stdout the ScopeAutomata of the function given with the --func option in a readable form, each
+// state followed by its outgoing transitions, and optionally (--render) exports it as the parse subcommand does.
+// Only the static analysis is run, so it's a fast way to check how a single function has been extracted
+func runInspect(args []string) int {
+	flagSet := newFlagSet("inspect")
+	funcName := flagSet.StringLong("func", 0, "", "The function whose ScopeAutomata is printed (e.g 'worker' or 'main-func1' for a closure)")
+	render := flagSet.BoolLong("render", 0, "Exports as well the ScopeAutomata in the functions folder of the output path", "false")
+	opts := parseOptions(flagSet, args)
+
+	if *funcName == "" {
+		log.Fatal("No function given, use the --func option")
 	}
 
-	// Extracts the local views starting from the program entrypoint ("main" function)
-	localViews := transforms.ExtractGoroutineFSA(fileMetadata)
+	return opts.forEachEntrypoint(func(opts options) int {
+		fileMetadata := newPipeline(opts).Run(pipeline.Extract).Metadata
+		funcMeta, exist := fileMetadata.FunctionMeta[*funcName]
+		if !exist {
+			log.Fatalf("Function %s not found (available: %s)\n", *funcName, strings.Join(sortedFunctions(fileMetadata), ", "))
+		}
 
-	// For each local view of the Choreography Automata applies transformations (determinization, minimization)
-	for _, lView := range localViews {
-		// Exports the local view (NFA version)
-		filenameNFA := fmt.Sprintf("%s/NFA %s.dot", *outputPath, lView.Name)
-		lView.Automaton.Export(filenameNFA, graphviz.XDOT)
+		states, transitions := automatonSize(funcMeta.Automaton)
+		fmt.Printf("Function %s: %d states, %d transitions\n", funcMeta.Name, states, transitions)
+		for _, arg := range funcMeta.InlineArgs {
+			fmt.Printf("  argument #%d %s (%s)\n", arg.Offset, arg.Name, arg.Type)
+		}
+		for _, result := range funcMeta.ReturnArgs {
+			fmt.Printf("  result #%d %s (channel)\n", result.Offset, result.Name)
+		}
+		printChannels(funcMeta.ChanMeta)
+		fmt.Println()
+		printScopeAutomaton(funcMeta.Automaton)
 
-		// Determinization of the local view FSA
-		lViewDFA := transforms.SubsetConstruction(lView.Automaton)
-		// TODO: Add minimization of the DFA
+		if *render {
+			layout := opts.prepareOutput()
+			opts.export(layout.Function(*funcName), funcMeta.Automaton)
+		}
+
+		printUnsupported(fileMetadata)
+		return 0
+	})
+}
 
-		// Constructs and exports the local view (DFA version)
-		filenameDFA := fmt.Sprintf("%s/DFA %s.dot", *outputPath, lView.Name)
-		lViewDFA.Export(filenameDFA, graphviz.XDOT)
+// Prints the states of the given automaton in ascending order, each one (with its role, if initial or final)
+// followed by its outgoing transitions along with the channel or the arguments they carry (e.g. "Call worker
+// (jobs, results) -> 3"). The states without outgoing transitions are listed as well
+func printScopeAutomaton(automaton *fsa.FSA) {
+	outgoing := make(map[int][]string)
+	automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+		detail := ""
+		switch payload := t.Payload.(type) {
+		case static_analysis.ChanMetadata:
+			detail = fmt.Sprintf(" (chan %s)", payload.Type)
+		case []static_analysis.FuncArg:
+			if len(payload) == 0 {
+				break
+			}
+			names := make([]string, 0, len(payload))
+			for _, arg := range payload {
+				names = append(names, arg.Name)
+			}
+			detail = fmt.Sprintf(" (%s)", strings.Join(names, ", "))
+		}
+		outgoing[from] = append(outgoing[from], fmt.Sprintf("%s %s%s -> %d", t.Move, t.Label, detail, to))
+	})
+
+	automaton.ForEachState(func(id int) {
+		roles := []string{}
+		if id == automaton.InitialState() {
+			roles = append(roles, "initial")
+		}
+		if automaton.IsFinalState(id) {
+			roles = append(roles, "final")
+		}
+		if len(roles) > 0 {
+			fmt.Printf("%d (%s):\n", id, strings.Join(roles, ", "))
+		} else {
+			fmt.Printf("%d:\n", id)
+		}
+		for _, transition := range outgoing[id] {
+			fmt.Printf("  %s\n", transition)
+		}
+	})
+}
 
-		// Updates the automata for the local view
-		lView.Automaton = lViewDFA.Copy()
+// Returns the names of the functions found in the given metadata, sorted
+func sortedFunctions(fileMetadata static_analysis.FileMetadata) []string {
+	funcNames := make([]string, 0, len(fileMetadata.FunctionMeta))
+	for name := range fileMetadata.FunctionMeta {
+		funcNames = append(funcNames, name)
+	}
+	sort.Strings(funcNames)
+	return funcNames
+}
 
-		// Additional export of .svg automata
-		if svgExportFlag != nil && *svgExportFlag {
-			filenameNFA := fmt.Sprintf("%s/NFA %s.svg", *outputPath, lView.Name)
-			lView.Automaton.Export(filenameNFA, graphviz.SVG)
+// Prints the given channels metadata sorted by name
+func printChannels(channels map[string]static_analysis.ChanMetadata) {
+	names := make([]string, 0, len(channels))
+	for name := range channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-			filenameDFA := fmt.Sprintf("%s/DFA %s.svg", *outputPath, lView.Name)
-			lViewDFA.Export(filenameDFA, graphviz.SVG)
+	for _, name := range names {
+		channel := channels[name]
+		capacity := fmt.Sprint(channel.Capacity)
+		if channel.Capacity == static_analysis.UnknownCapacity {
+			capacity = "unknown"
 		}
+		fmt.Printf("  channel %s chan %s (buffered: %t, capacity: %s, may be nil: %t)\n", channel.Name, channel.Type, channel.Async, capacity, channel.MayBeNil)
 	}
+}
+
+// Exports the local view (deterministic version) of each Goroutine spawned in the program
+func runProject(args []string) int {
+	opts := parseOptions(newFlagSet("project"), args)
+	return opts.forEachEntrypoint(func(opts options) int {
+		layout := opts.prepareOutput()
+
+		artifacts := newPipeline(opts).Run(pipeline.Determinize)
+		opts.applyDirectives(artifacts)
+		exportLocalViews(opts, layout, artifacts.LocalViews, nil)
+		writeMeta(opts, layout, artifacts.Metadata)
+
+		printUnsupported(artifacts.Metadata)
+		return 0
+	})
+}
+
+// Prints to the stdout the spawn tree of the program: the Goroutines spawned by each participant along with the
+// spawn site and the channels passed to them, as an indented tree or as JSON. Only the local views are extracted,
+// so it's available even when the composition of the latters is too expensive
+func runSpawns(args []string) int {
+	flagSet := newFlagSet("spawns")
+	format := flagSet.EnumLong("format", 'f', []string{"text", "json"}, "text", "The output format of the spawn tree (text|json)")
+	opts := parseOptions(flagSet, args)
+	return opts.forEachEntrypoint(func(opts options) int {
+
+		artifacts := newPipeline(opts).Run(pipeline.Project)
+		tree := reports.NewSpawnTree(artifacts.LocalViews)
+
+		write := tree.WriteText
+		if *format == "json" {
+			write = tree.WriteJSON
+		}
+		if err := write(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+
+		printUnsupported(artifacts.Metadata)
+		return 0
+	})
+}
+
+// Exports the Choreography Automata (global view) of the program
+func runCompose(args []string) int {
+	flagSet := newFlagSet("compose")
+	channels := flagSet.ListLong("channel", 'c', "Exports as well the view of the interactions over the given channels")
+	hideInternal := flagSet.BoolLong("hide-internal", 0, "Exports as well the protocol view with only the message exchanges (spawns hidden)", "false")
+	opts := parseOptions(flagSet, args)
+	return opts.forEachEntrypoint(func(opts options) int {
+		layout := opts.prepareOutput()
+
+		artifacts := newPipeline(opts).Run(pipeline.Compose)
+		opts.applyDirectives(artifacts)
+		opts.exportGlobal(layout.Global(), artifacts.Choreography, artifacts.LocalViews)
+		exportChannelViews(opts, layout, artifacts.Choreography, *channels)
+		if *hideInternal {
+			exportProtocolView(opts, layout, artifacts.Choreography)
+		}
+		writeMeta(opts, layout, artifacts.Metadata)
+
+		printUnsupported(artifacts.Metadata)
+		return 0
+	})
+}
+
+// Prints to the stderr the summary of the constructs that the analysis wasn't able to model
+// (if any), so that the user knows which parts of the source code the choreography doesn't cover
+func printUnsupported(fileMetadata static_analysis.FileMetadata) {
+	if fileMetadata.Unsupported.Len() > 0 {
+		fmt.Fprint(os.Stderr, fileMetadata.Unsupported)
+	}
+}
+
+// Prints to the stderr the warnings found during the extraction (if any), apart from the unsupported constructs
+// that are already summarized by the subcommands themselves (see printUnsupported)
+func printWarnings(warnings []static_analysis.Warning) {
+	lines := []string{}
+	for _, warning := range warnings {
+		if warning.Kind != static_analysis.UnsupportedNode {
+			lines = append(lines, fmt.Sprintf("  %s\n", warning))
+		}
+	}
+
+	if len(lines) > 0 {
+		fmt.Fprintf(os.Stderr, "%d warning(s) found during the extraction:\n%s", len(lines), strings.Join(lines, ""))
+	}
+}
+
+// Checks the Choreography Automata of the program, every issue found is printed to the stdout and in
+// that case the program exits with a non zero exit code. The interaction loops found are printed too
+func runCheck(args []string) int {
+	flagSet := newFlagSet("check")
+	failOnNames := flagSet.ListLong("fail-on", 0, "The kinds of issue that make the check fail (deadlock|non-termination|leak|orphan|cycle|dead-code, default is every one but cycle and dead-code)")
+	opts := parseOptions(flagSet, args)
+
+	failOn, err := diagnostics.ParseKinds(*failOnNames)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(failOn) == 0 {
+		for _, kind := range diagnostics.DefaultFailOn {
+			failOn[kind] = true
+		}
+	}
+
+	return opts.forEachEntrypoint(func(opts options) int {
+
+		artifacts := newPipeline(opts).Run(pipeline.Check)
+
+		printUnsupported(artifacts.Metadata)
+		issues := artifacts.Diagnostics
+		for _, issue := range issues {
+			fmt.Println(issue)
+		}
+
+		// The interaction loops and the dead communication code are reported as well, but they count as issues only if requested
+		cycles := diagnostics.FindCycles(artifacts.Choreography)
+		for _, cycle := range cycles {
+			fmt.Println(cycle)
+		}
+		deadCode := diagnostics.FindDeadCode(artifacts.Metadata.FunctionMeta)
+		for _, dead := range deadCode {
+			fmt.Println(dead)
+		}
+
+		switch {
+		case len(issues) == 0 && len(cycles) == 0 && len(deadCode) == 0:
+			fmt.Println("No issue found")
+		case len(issues) == 0:
+			fmt.Println("No issue found among the diagnostics")
+		}
+		return diagnostics.ExitCode(append(append(issues, cycles...), deadCode...), failOn)
+	})
+}
+
+// Checks that the Choreography Automata of the program conforms to the specification automaton given with the
+// --spec option, either a JSON document in the schema of the exported automata (e.g a global.json exported with
+// --json and then edited) or the textual form of an automaton. The first nonconforming trace is printed to the
+// stdout and in that case the program exits with the exit code of the nonconformance (see diagnostics.ExitCode)
+func runConform(args []string) int {
+	flagSet := newFlagSet("conform")
+	specFile := flagSet.StringLong("spec", 0, "", "The specification automaton the Choreography Automata has to conform to (.json or textual form)")
+	relationName := flagSet.EnumLong("relation", 0, []string{"inclusion", "bisimulation"}, "inclusion", "How the Choreography Automata has to conform to the specification (inclusion|bisimulation)")
+	opts := parseOptions(flagSet, args)
+
+	if *specFile == "" {
+		log.Fatal("No specification automaton given, use the --spec option")
+	}
+	relation, err := diagnostics.ParseRelation(*relationName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	spec := readSpecification(*specFile)
+
+	return opts.forEachEntrypoint(func(opts options) int {
+
+		artifacts := newPipeline(opts).Run(pipeline.Compose)
+		opts.applyDirectives(artifacts)
+
+		printUnsupported(artifacts.Metadata)
+		issues := diagnostics.CheckConformance(artifacts.Choreography, spec, relation)
+		for _, issue := range issues {
+			fmt.Println(issue)
+		}
+
+		if len(issues) == 0 {
+			fmt.Printf("The choreography conforms to the specification (%s)\n", relation)
+		}
+		return diagnostics.ExitCode(issues, map[diagnostics.Kind]bool{diagnostics.Nonconformance: true})
+	})
+}
+
+// Reads the specification automaton at the given path, a JSON document if it has the .json extension or else
+// the textual form of the automaton (see fsa.FSA.MarshalText), the program exits if it can't be read
+func readSpecification(specPath string) *fsa.FSA {
+	content, err := os.ReadFile(specPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if strings.HasSuffix(specPath, ".json") {
+		document, err := output.DecodeAutomaton(content)
+		if err != nil {
+			log.Fatalf("invalid specification %s: %s\n", specPath, err)
+		}
+		return document.Automaton()
+	}
+
+	spec := fsa.New()
+	if err := spec.UnmarshalText(content); err != nil {
+		log.Fatalf("invalid specification %s: %s\n", specPath, err)
+	}
+	return spec
+}
+
+// Prints to the stdout the interaction matrix of the Choreography Automata, that is which
+// Goroutines ever communicate with which others (and over which channels)
+func runMatrix(args []string) int {
+	flagSet := newFlagSet("matrix")
+	format := flagSet.EnumLong("format", 'f', []string{"csv", "json"}, "csv", "The output format of the matrix (csv|json)")
+	ownership := flagSet.BoolLong("ownership", 0, "Prints the senders, receivers, owner and usage pattern of each channel instead", "false")
+	opts := parseOptions(flagSet, args)
+	return opts.forEachEntrypoint(func(opts options) int {
+
+		artifacts := newPipeline(opts).Run(pipeline.Compose)
+		opts.applyDirectives(artifacts)
+		matrix := reports.NewInteractionMatrix(artifacts.Choreography)
+		channels := reports.NewChannelOwnership(artifacts.Result())
+
+		var err error
+		switch {
+		case *ownership && *format == "json":
+			err = reports.WriteOwnershipJSON(os.Stdout, channels)
+		case *ownership:
+			err = reports.WriteOwnershipCSV(os.Stdout, channels)
+		case *format == "json":
+			err = matrix.WriteJSON(os.Stdout)
+		default:
+			err = matrix.WriteCSV(os.Stdout)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		printUnsupported(artifacts.Metadata)
+		return 0
+	})
+}
+
+// Prints to the stdout the edge coverage of the Choreography Automata: which of its interactions have been exercised
+// by the runs (e.g the tests) whose events have been logged in the file given with the --events option (see
+// reports.ObservedEvent), along with the interactions never exercised and the runs that diverge from the choreography
+func runCoverage(args []string) int {
+	flagSet := newFlagSet("coverage")
+	eventsFile := flagSet.StringLong("events", 0, "", "The events logged by the instrumented test runs, one JSON object per line")
+	format := flagSet.EnumLong("format", 'f', []string{"text", "json"}, "text", "The output format of the coverage (text|json)")
+	opts := parseOptions(flagSet, args)
+
+	if *eventsFile == "" {
+		log.Fatal("No events given, use the --events option")
+	}
+	file, err := os.Open(*eventsFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	events, err := reports.ReadObservedEvents(file)
+	file.Close()
+	if err != nil {
+		log.Fatalf("invalid events %s: %s\n", *eventsFile, err)
+	}
+
+	return opts.forEachEntrypoint(func(opts options) int {
+
+		artifacts := newPipeline(opts).Run(pipeline.Compose)
+		opts.applyDirectives(artifacts)
+		coverage := reports.NewCoverage(artifacts.Result(), events)
+
+		write := coverage.WriteText
+		if *format == "json" {
+			write = coverage.WriteJSON
+		}
+		if err := write(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+
+		printUnsupported(artifacts.Metadata)
+		return 0
+	})
+}
+
+// Saves the summary of the whole analysis (see reports.Summary) in the requested format, along with the
+// image of the Choreography Automata that the Markdown report references (the HTML one inlines it instead)
+func runReport(args []string) int {
+	flagSet := newFlagSet("report")
+	format := flagSet.EnumLong("format", 'f', []string{"markdown", "html"}, "markdown", "The format of the report (markdown|html)")
+	opts := parseOptions(flagSet, args)
+	return opts.forEachEntrypoint(func(opts options) int {
+		layout := opts.prepareOutput()
+
+		artifacts := newPipeline(opts).Run(pipeline.Check)
+		opts.applyDirectives(artifacts)
+
+		imagePath := fmt.Sprintf("%s.svg", layout.Global())
+		// The image is rendered in memory, the HTML report inlines it while the Markdown one references the saved file
+		var image bytes.Buffer
+		if err := artifacts.Choreography.ExportTo(&image, graphviz.SVG); err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(imagePath, image.Bytes(), 0664); err != nil {
+			log.Fatal(err)
+		}
+
+		summary := reports.Summary{
+			Input:       opts.inputFile,
+			Result:      artifacts.Result(),
+			InitFlow:    artifacts.Metadata.Package.InitFlow,
+			Diagnostics: artifacts.Diagnostics,
+			Unsupported: artifacts.Metadata.Unsupported,
+			ImageFile:   filepath.Base(imagePath),
+			ImageSVG:    image.Bytes(),
+		}
+
+		reportPath, write := layout.Summary()+".md", summary.WriteMarkdown
+		if *format == "html" {
+			reportPath, write = layout.Summary()+".html", summary.WriteHTML
+		}
+		reportFile, err := os.Create(reportPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer reportFile.Close()
+		if err := write(reportFile); err != nil {
+			log.Fatal(err)
+		}
+
+		logging.Infof("Report saved to %s", reportPath)
+		printUnsupported(artifacts.Metadata)
+		return 0
+	})
+}
+
+// Runs the whole pipeline and exports both the local views and the Choreography Automata, along
+// with the report of the issues found in the latter (as the check subcommand does). When the input
+// is an analysis bundle (see runBundle) its results are exported instead, without running the analysis.
+// With the tla format the TLA+ specification of the local views (see transforms.WriteTLA) is saved instead
+// of the graphs, as "<outputPath>/global.tla" along with the configuration for TLC ("global.cfg")
+func runExport(args []string) int {
+	flagSet := newFlagSet("export")
+	channels := flagSet.ListLong("channel", 'c', "Exports as well the view of the interactions over the given channels")
+	hideInternal := flagSet.BoolLong("hide-internal", 0, "Exports as well the protocol view with only the message exchanges (spawns hidden)", "false")
+	format := flagSet.EnumLong("format", 'f', []string{"dot", "tla"}, "dot", "The format of the exported views, the graphs (dot) or the TLA+ specification of the local views (tla)")
+	opts := parseOptions(flagSet, args)
+
+	export := exportViews
+	if *format == "tla" {
+		export = exportSpecification
+	}
+
+	if isBundle(opts.inputFile) {
+		layout, bundle := opts.prepareOutput(), openBundle(opts.inputFile)
+		if err := layout.WriteReportDocument(bundle.Report); err != nil {
+			log.Fatal(err)
+		}
+
+		artifacts := &pipeline.Artifacts{LocalViews: bundle.LocalViews, Choreography: bundle.Choreography}
+		export(opts, layout, artifacts, *channels, *hideInternal)
+		if err := layout.WriteMetaDocument(bundle.Meta); err != nil {
+			log.Fatal(err)
+		}
+		return 0
+	}
+
+	return opts.forEachEntrypoint(func(opts options) int {
+		layout := opts.prepareOutput()
+
+		artifacts := newPipeline(opts).Run(pipeline.Check)
+		if err := layout.WriteReport(artifacts.Diagnostics); err != nil {
+			log.Fatal(err)
+		}
+		export(opts, layout, artifacts, *channels, *hideInternal)
+		writeMeta(opts, layout, artifacts.Metadata)
+
+		printUnsupported(artifacts.Metadata)
+		return 0
+	})
+}
+
+// Runs the whole pipeline and saves its results, along with the options given, in a single compressed archive
+// (see output.Bundle) at "<outputPath>.choreia". The archive can be opened later on with the view and export
+// subcommands, so that the results can be inspected (or exported again, e.g with other options) without
+// running the analysis again. As in the export subcommand the diagnostics refer to the original participants
+func runBundle(args []string) int {
+	opts := parseOptions(newFlagSet("bundle"), args)
+	return opts.forEachEntrypoint(func(opts options) int {
+		artifacts := newPipeline(opts).Run(pipeline.Check)
+		report := output.NewReportDocument(artifacts.Diagnostics)
+		opts.applyDirectives(artifacts)
+
+		bundle := output.Bundle{
+			Args:         args[1:],
+			Meta:         output.NewMetaDocument(opts.inputFile, artifacts.Metadata),
+			Report:       report,
+			LocalViews:   artifacts.LocalViews,
+			Choreography: artifacts.Choreography,
+		}
+		bundlePath := strings.TrimSuffix(opts.outputPath, output.BundleExtension) + output.BundleExtension
+		if err := output.WriteBundle(bundlePath, bundle); err != nil {
+			log.Fatal(err)
+		}
+
+		logging.Infof("Bundle saved to %s", bundlePath)
+		printUnsupported(artifacts.Metadata)
+		return 0
+	})
+}
+
+// Runs the whole pipeline and saves a regression fixture of the input (see output.Fixture) in a folder of the --out
+// directory, named after the input (or --name): the copy of the analyzed files and of the stubs, the options given,
+// the automata of every stage (in JSON as well) and the results in their canonical text form. The folder can be
+// attached to a bug report as is, since it reproduces the case without the rest of the project
+func runRepro(args []string) int {
+	flagSet := newFlagSet("repro")
+	outDir := flagSet.StringLong("out", 0, "./fixtures", "The directory where the fixture is saved, in a folder named after the input")
+	caseName := flagSet.StringLong("name", 0, "", "The name of the fixture folder (default is the name of the input file or package directory)")
+	opts := parseOptions(flagSet, args)
+
+	if strings.HasSuffix(opts.inputFile, repositorySuffix) {
+		log.Fatal("A fixture reproduces a single case, the repository mode isn't supported by the repro command")
+	}
+	if *caseName == "" {
+		*caseName = strings.TrimSuffix(filepath.Base(filepath.Clean(opts.inputFile)), ".go")
+	}
+
+	// Every intermediate automaton is saved in the fixture, as the trace (if requested) unless given elsewhere
+	layout := output.New(filepath.Join(*outDir, output.Sanitize(*caseName)))
+	if err := layout.Prepare(); err != nil {
+		log.Fatal(err)
+	}
+	if opts.traceFile == filepath.Join(opts.outputPath, output.TraceFile) {
+		opts.traceFile = filepath.Join(layout.Root, output.TraceFile)
+	}
+	opts.artifactsDir, opts.jsonExport = filepath.Join(layout.Root, output.StagesDir), true
+	opts.dumpStages = parseStages(pipelineStages)
+
+	artifacts := newPipeline(opts).Run(pipeline.Check)
+	report := output.NewReportDocument(artifacts.Diagnostics)
+	opts.applyDirectives(artifacts)
+
+	fixture := output.Fixture{
+		Input:        opts.inputFile,
+		Sources:      []string{},
+		Stubs:        []string{},
+		Args:         args[1:],
+		Meta:         output.NewMetaDocument(opts.inputFile, artifacts.Metadata),
+		Report:       report,
+		LocalViews:   artifacts.LocalViews,
+		Choreography: artifacts.Choreography,
+	}
+	for _, file := range artifacts.Source.Files {
+		fixture.Sources = append(fixture.Sources, artifacts.Source.FileSet.Position(file.Pos()).Filename)
+	}
+	for _, stubPath := range opts.stubPaths {
+		stubFiles, err := static_analysis.FindStubs(stubPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fixture.Stubs = append(fixture.Stubs, stubFiles...)
+	}
+	if err := layout.WriteFixture(fixture); err != nil {
+		log.Fatal(err)
+	}
+
+	logging.Infof("Fixture saved to %s", layout.Root)
+	printUnsupported(artifacts.Metadata)
+	return 0
+}
+
+// Prints to the stdout the summary of the analysis saved in the given bundle: the input and the options with which
+// it has been run, the size of the local views and of the Choreography Automata and the issues found in the latter
+func runView(args []string) int {
+	opts := parseOptions(newFlagSet("view"), args)
+	if !isBundle(opts.inputFile) {
+		log.Fatalf("%s is not an analysis bundle (%s file)\n", opts.inputFile, output.BundleExtension)
+	}
+	bundle := openBundle(opts.inputFile)
+
+	fmt.Printf("Input: %s\n", bundle.Meta.Input)
+	fmt.Printf("Options: %s\n", strings.Join(bundle.Args, " "))
+
+	names := make([]string, 0, len(bundle.LocalViews))
+	for name := range bundle.LocalViews {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Println("\nParticipants:")
+	for _, name := range names {
+		lView := bundle.LocalViews[name]
+		states, transitions := automatonSize(lView.Automaton)
+		fmt.Printf("  %s (%s): %d states, %d transitions\n", name, lView.FuncMetadata.Name, states, transitions)
+	}
+	states, transitions := automatonSize(bundle.Choreography)
+	fmt.Printf("\nGlobal view: %d states, %d transitions\n\n", states, transitions)
+
+	// The issues are printed as the check subcommand does, with the trace in textual form
+	for _, issue := range bundle.Report.Diagnostics {
+		fmt.Printf("[%s] state %d: %s\n", issue.Kind, issue.State, issue.Message)
+		for i, t := range issue.Trace {
+			fmt.Printf("  %d. %s\n", i+1, t)
+		}
+	}
+	if len(bundle.Report.Diagnostics) == 0 {
+		fmt.Println("No issue found")
+	}
+	return 0
+}
+
+// Returns true if the given input path is an analysis bundle (see runBundle)
+func isBundle(inputPath string) bool {
+	return strings.HasSuffix(inputPath, output.BundleExtension)
+}
+
+// Opens the analysis bundle at the given path, the program exits if it can't be read
+func openBundle(bundlePath string) *output.Bundle {
+	bundle, err := output.ReadBundle(bundlePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return bundle
+}
+
+// Returns the number of states and of transitions of the given automaton
+func automatonSize(automaton *fsa.FSA) (int, int) {
+	states, transitions := 0, 0
+	automaton.ForEachState(func(int) { states++ })
+	automaton.ForEachTransition(func(_, _ int, _ fsa.Transition) { transitions++ })
+	return states, transitions
+}
+
+// Exports the local views, the Choreography Automata and the other views requested of the given artifacts,
+// renamed as stated by the directives (see applyDirectives)
+func exportViews(opts options, layout *output.Layout, artifacts *pipeline.Artifacts, channels []string, hideInternal bool) {
+	opts.applyDirectives(artifacts)
+	exportLocalViews(opts, layout, artifacts.LocalViews, artifacts.Choreography)
+	opts.exportGlobal(layout.Global(), artifacts.Choreography, artifacts.LocalViews)
+	exportChannelViews(opts, layout, artifacts.Choreography, channels)
+	if hideInternal {
+		exportProtocolView(opts, layout, artifacts.Choreography)
+	}
+}
+
+// Exports the TLA+ specification of the local views of the given artifacts (see transforms.ExportTLA), renamed as
+// exportViews does. It takes the place of the latter, so the channel and protocol views aren't exported
+func exportSpecification(opts options, layout *output.Layout, artifacts *pipeline.Artifacts, _ []string, _ bool) {
+	opts.applyDirectives(artifacts)
+	transforms.ExportTLA(artifacts.LocalViews, layout.Global())
+}
+
+// Exports the local views (and the system overview) with the Send/Recv transitions annotated in CSP-style,
+// the Choreography Automata (if available) is used to infer the peers (see transforms.AnnotateLocalViews).
+// The local views are exported sorted by name, so that the file names given on collision are stable
+func exportLocalViews(opts options, layout *output.Layout, localViews map[string]*transforms.GoroutineFSA, finalCA *fsa.FSA) {
+	annotatedViews := transforms.AnnotateLocalViews(localViews, finalCA)
+
+	names := make([]string, 0, len(annotatedViews))
+	for name := range annotatedViews {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		opts.exportLocalView(layout.LocalView(name), annotatedViews[name].Automaton)
+	}
+	opts.exportOverview(layout, annotatedViews)
+}
+
+// Exports the abstract protocol view of the Choreography Automata, where the internal transitions
+// are hidden and the states equivalent modulo weak bisimulation are merged (see transforms.HideInternal)
+func exportProtocolView(opts options, layout *output.Layout, finalCA *fsa.FSA) {
+	opts.export(layout.Protocol(), transforms.HideInternal(finalCA))
+}
+
+// Exports the view of the Choreography Automata restricted to the interactions over
+// each one of the given channels (see transforms.ExtractChannelView)
+func exportChannelViews(opts options, layout *output.Layout, finalCA *fsa.FSA, channels []string) {
+	for _, channel := range channels {
+		channelView := transforms.ExtractChannelView(finalCA, channel)
+		opts.export(layout.Channel(channel), channelView)
+	}
+}
 
-	// At last extracts the Choreography Automata (also known as "global view")
-	finalCA := transforms.LocalViewsComposition(localViews)
-	finalCA.Export(fmt.Sprintf("%s/Choreography Automata.dot", *outputPath), graphviz.XDOT)
-	// Additional export of .svg Choreography Automata
-	if svgExportFlag != nil && *svgExportFlag {
-		finalCA.Export(fmt.Sprintf("%s/Choreography Automata.svg", *outputPath), graphviz.SVG)
+// Saves the summary of the analysis, and of the files exported so far, in the results directory
+func writeMeta(opts options, layout *output.Layout, fileMetadata static_analysis.FileMetadata) {
+	if err := layout.WriteMeta(opts.inputFile, fileMetadata); err != nil {
+		log.Fatal(err)
 	}
 }
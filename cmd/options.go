@@ -0,0 +1,431 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// This is the entry point of the whole program (Choreia).
+// It handles directly all the interaction with the respective utilities module in order
+// to extract the Choreography Automata from the given input file
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+
+	"github.com/goccy/go-graphviz"
+	"github.com/pborman/getopt/v2"
+
+	// Choreia internal FSA data structure
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	// Choreia internal checks on the Choreography Automata
+	"github.com/its-hmny/Choreia/internal/diagnostics"
+	// Choreia internal leveled logger
+	"github.com/its-hmny/Choreia/internal/logging"
+	// Choreia internal layout of the results directory
+	"github.com/its-hmny/Choreia/internal/output"
+	// Choreia internal pipeline stages and hooks
+	"github.com/its-hmny/Choreia/internal/pipeline"
+	// Choreia internal static analysis and metatdata extraction module
+	"github.com/its-hmny/Choreia/internal/static_analysis"
+	// Choreia internal structured trace of the analysis
+	"github.com/its-hmny/Choreia/internal/tracing"
+	// Choreia internal Choreography Automata transformation module
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+const (
+	// Pipeline stages whose intermediate automata can be dumped with --dump-stage
+	scopeStage         = "scope"         // The ScopeAutomata of each function
+	linearizedStage    = "linearized"    // The function automata after call inlining
+	localViewStage     = "localview"     // The local views (NFA) of each Goroutine
+	deterministicStage = "deterministic" // The local views after determinization (DFA)
+	globalStage        = "global"        // The Choreography Automata (global view)
+)
+
+// The list of the valid pipeline stages, in execution order
+var pipelineStages = []string{scopeStage, linearizedStage, localViewStage, deterministicStage, globalStage}
+
+// The cleanup functions to be executed before the program exits (e.g stop the profiling)
+var exitHooks = []func(){}
+
+// ----------------------------------------------------------------------------
+// Options
+
+// The set of options that are shared by every subcommand of the program
+type options struct {
+	inputFile    string                           // The .go file to be analyzed
+	outputPath   string                           // The directory where the automata will be saved
+	artifactsDir string                           // The directory where the intermediate automata will be saved
+	traceMode    static_analysis.TraceMode        // The events of the analysis to be traced (see tracing.Level)
+	traceFile    string                           // The file where the trace is written
+	svgExport    bool                             // Saves .svg images alongside the .dot file
+	jsonExport   bool                             // Saves the automata in JSON (see output.AutomatonDocument) alongside the .dot file
+	dumpStages   map[string]bool                  // The pipeline stages whose intermediate automata have to be saved
+	excludeNil   bool                             // Excludes the operations on channels that may be nil from the local views
+	minimize     bool                             // Minimizes the local views before composing them (see transforms.MinimizeLocalView)
+	verbosity    transforms.LabelVerbosity        // How much information is shown in the labels of the Choreography Automata
+	stubPaths    []string                         // The stub models (files or directories) of the external functions
+	plugins      []string                         // The Go plugins that register custom extractors
+	network      bool                             // Models the HTTP/gRPC handlers as participants driven by the network
+	dispatch     bool                             // Resolves the calls made through an interface to every implementation
+	simplify     bool                             // Contracts the eps chains and renumbers the states of the exported automata
+	directives   transforms.ParticipantDirectives // How the participants are renamed (or merged) in the output
+	symmetry     bool                             // Explores the composition up to a permutation of the symmetric Goroutines
+	strategy     transforms.ExplorationStrategy   // The order in which the composition visits the configurations of the system
+	participants []string                         // The patterns of the participants composed (all of them if empty)
+	assertions   []diagnostics.Assertion          // The properties checked on the Choreography Automata
+	showSink     bool                             // Draws the implicit sink state in the exported local views
+	stageLayout  *output.Layout                   // The layout of the intermediate automata (see newPipeline)
+}
+
+// Creates the getopt.Set with the flags shared by every subcommand, the caller can register
+// additional flags on the returned set before calling parseOptions() on it
+func newFlagSet(command string) *getopt.Set {
+	flagSet := getopt.New()
+	flagSet.SetProgram(fmt.Sprintf("choreia %s", command))
+	flagSet.SetParameters("[input_file.go]")
+	return flagSet
+}
+
+// Registers the shared flags on the given set, then parses the given arguments and
+// returns the resulting options. If the help is requested (or the input file is missing)
+// the usage message is shown and the program exits
+func parseOptions(flagSet *getopt.Set, args []string) options {
+	inputFile := flagSet.StringLong("input", 'i', "", "The .go file from which extract the Choreography Automata")
+	outputPath := flagSet.StringLong("output", 'o', "./choreia.out", "The path to where the extracted data will be saved")
+	traceLevel := flagSet.EnumLong("trace", 't', []string{"none", "basic", "extended"}, "none", "Traces the analysis: the transitions emitted and the transformation decisions (basic), the AST nodes visited as well (extended)")
+	traceFile := flagSet.StringLong("trace-file", 0, "", "The path of the trace, written as JSON lines (default is trace.jsonl in the output path)")
+	svgExportFlag := flagSet.BoolLong("svg", 's', "Saves .svg images alongside the .dot file", "false")
+	jsonExportFlag := flagSet.BoolLong("json", 0, "Saves the automata in JSON (versioned schema) alongside the .dot file", "false")
+	dumpStages := flagSet.ListLong("dump-stage", 'd', "Saves the intermediate automata of the given stages (scope|linearized|localview|deterministic|global)")
+	artifactsDir := flagSet.StringLong("debug-artifacts-dir", 0, "", "The path where the intermediate automata will be saved (default is the output path)")
+	simplifyFlag := flagSet.BoolLong("simplify", 0, "Contracts the eps chains and renumbers (breadth-first) the states of the exported automata", "false")
+	excludeNilFlag := flagSet.BoolLong("exclude-nil-channels", 0, "Excludes the operations on channels that may be assigned to nil (disabled select branches)", "false")
+	minimizeLocalFlag := flagSet.BoolLong("minimize-local", 0, "Minimizes the local views before composing them, the Choreography Automata is smaller but equivalent (bisimilar)", "false")
+	showSinkFlag := flagSet.BoolLong("show-sink", 0, "Draws in the local views the implicit sink (error) state, reached with dashed edges by the messages not handled in each state", "false")
+	maxLabelLen := flagSet.IntLong("max-label-len", 0, 0, "Wraps and truncates the edge labels longer than the given length, the full text is kept in the tooltip (0 means no limit)")
+	labelVerbosity := flagSet.EnumLong("label-verbosity", 0, []string{"minimal", "typed", "full"}, "full", "The information shown in the interaction labels (minimal|typed|full)")
+	stubPaths := flagSet.ListLong("stubs", 0, "The stub models of the external functions (.fsa files or directories containing them)")
+	symmetryFlag := flagSet.BoolLong("symmetry-reduction", 0, "Explores the composition up to a permutation of the Goroutines with identical local views", "false")
+	strategy := flagSet.EnumLong("exploration", 0, []string{"bfs", "dfs", "priority"}, "bfs", "The order in which the composition visits the configurations: breadth-first, depth-first or the least active first (bfs|dfs|priority)")
+	participants := flagSet.ListLong("participants", 0, "Composes only the participants matching the given patterns (e.g 'main,worker*'), the others are merged in a single 'others' participant")
+	assertionsFile := flagSet.StringLong("assertions", 0, "", "The file of the properties to be checked on the Choreography Automata (e.g 'eventually main receives on done')")
+	renames := flagSet.ListLong("rename", 0, "Renames the given participants in the output ('old=new' pairs, e.g 'main/worker@main.go:9#3=logger')")
+	merges := flagSet.ListLong("merge", 0, "Merges the Goroutines spawned from the given functions in a single participant (e.g 'worker (*)')")
+	plugins := flagSet.ListLong("extractor-plugin", 0, "The Go plugins (.so) that register custom extractors for the static analysis")
+	opaqueSpawns := flagSet.EnumLong("opaque-spawns", 0, []string{"both", "send", "recv", "none"}, "both", "The operations that the external functions spawned with channel arguments can make on them (both|send|recv|none)")
+	networkFlag := flagSet.BoolLong("network-handlers", 0, "Models the HTTP/gRPC handlers registered as participants driven by the network (experimental)", "false")
+	dispatchFlag := flagSet.BoolLong("interface-dispatch", 0, "Resolves the calls made through an interface to the methods of every implementation in the package, on their own branch (experimental)", "false")
+	buildTags := flagSet.ListLong("tags", 0, "The build tags with which the files of a package are selected (comma separated, as for 'go build -tags')")
+	goos := flagSet.StringLong("goos", 0, "", "The target operating system with which the files of a package are selected (default is the one of GOOS or of the host)")
+	goarch := flagSet.StringLong("goarch", 0, "", "The target architecture with which the files of a package are selected (default is the one of GOARCH or of the host)")
+	verbosity := flagSet.CounterLong("verbose", 'v', "Logs the pipeline progress (repeat it to log debug information as well)")
+	cpuProfile := flagSet.StringLong("cpuprofile", 0, "", "Writes a CPU profile of the execution to the given file")
+	memProfile := flagSet.StringLong("memprofile", 0, "", "Writes a memory (heap) profile to the given file before exiting")
+	showUsage := flagSet.BoolLong("help", 'h', "Display this help message", "false")
+	flagSet.Parse(args) // Parses the subcommand arguments
+
+	logging.SetLevel(logging.Warning + logging.Level(*verbosity))
+	fsa.SetMaxLabelLength(*maxLabelLen)
+	static_analysis.SetBuildContext(*buildTags, *goos, *goarch)
+	// The value has already been validated by the flag parsing
+	opaqueMoves, _ := transforms.ParseOpaqueMoves(*opaqueSpawns)
+	transforms.SetOpaqueMoves(opaqueMoves)
+
+	// The input file can be given as positional argument as well
+	if *inputFile == "" && flagSet.NArgs() > 0 {
+		*inputFile = flagSet.Arg(0)
+	}
+
+	// Checks that the input file is provided via CLI argument
+	if *showUsage || *inputFile == "" {
+		flagSet.PrintUsage(os.Stderr)
+		os.Exit(1)
+	}
+
+	opts := options{
+		inputFile:    *inputFile,
+		outputPath:   *outputPath,
+		artifactsDir: *outputPath,
+		traceFile:    filepath.Join(*outputPath, output.TraceFile),
+		svgExport:    *svgExportFlag,
+		jsonExport:   *jsonExportFlag,
+		dumpStages:   parseStages(*dumpStages),
+		excludeNil:   *excludeNilFlag,
+		minimize:     *minimizeLocalFlag,
+		stubPaths:    *stubPaths,
+		plugins:      *plugins,
+		network:      *networkFlag,
+		dispatch:     *dispatchFlag,
+		simplify:     *simplifyFlag,
+		symmetry:     *symmetryFlag,
+		participants: *participants,
+		showSink:     *showSinkFlag,
+	}
+
+	// The values have already been validated by the flag parsing
+	opts.verbosity, _ = transforms.ParseLabelVerbosity(*labelVerbosity)
+	opts.strategy, _ = transforms.ParseExplorationStrategy(*strategy)
+	opts.traceMode, _ = tracing.ParseLevel(*traceLevel)
+
+	directives, err := transforms.ParseParticipantDirectives(*renames, *merges)
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts.directives = directives
+
+	if *assertionsFile != "" {
+		if opts.assertions, err = diagnostics.LoadAssertions(*assertionsFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *artifactsDir != "" {
+		opts.artifactsDir = *artifactsDir
+	}
+	if *cpuProfile != "" || *memProfile != "" {
+		startProfiling(*cpuProfile, *memProfile)
+	}
+	if *traceFile != "" {
+		opts.traceFile = *traceFile
+	}
+
+	return opts
+}
+
+// Parses the stages list given via CLI argument and returns them as a set,
+// if an unknown stage is found the execution is stopped with an error
+func parseStages(stages []string) map[string]bool {
+	stageSet := make(map[string]bool)
+
+	for _, stage := range stages {
+		isValid := false
+		for _, validStage := range pipelineStages {
+			isValid = isValid || stage == validStage
+		}
+
+		if !isValid {
+			log.Fatalf("Unknown pipeline stage '%s', available are: %s\n", stage, strings.Join(pipelineStages, ", "))
+		}
+
+		stageSet[stage] = true
+	}
+
+	return stageSet
+}
+
+// Starts the CPU profiling (if a path is given) and registers an exit hook that stops it
+// and writes the heap profile (if a path is given) when the subcommand completes
+func startProfiling(cpuProfilePath, memProfilePath string) {
+	if cpuProfilePath != "" {
+		cpuFile, err := os.Create(cpuProfilePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := pprof.StartCPUProfile(cpuFile); err != nil {
+			log.Fatal(err)
+		}
+
+		exitHooks = append(exitHooks, func() {
+			pprof.StopCPUProfile()
+			cpuFile.Close()
+		})
+	}
+
+	if memProfilePath != "" {
+		exitHooks = append(exitHooks, func() {
+			memFile, err := os.Create(memProfilePath)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer memFile.Close()
+
+			runtime.GC() // Gets up-to-date statistics about the allocations
+			if err := pprof.WriteHeapProfile(memFile); err != nil {
+				log.Fatal(err)
+			}
+		})
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Repository mode
+
+// The suffix of the input path that enables the repository mode (e.g "./...")
+const repositorySuffix = "..."
+
+// Runs the given subcommand implementation once for each entrypoint. Usually the only entrypoint is
+// the input file (or package directory) itself, but in repository mode (e.g "choreia compose ./...")
+// every "main" package found under the given directory is analyzed on its own. In the latter case
+// the results are saved in a separate output directory for each package (e.g "<output>/cmd/server")
+// and the returned exit code is the bitwise OR of the ones of the entrypoints (see diagnostics.ExitCode)
+func (opts options) forEachEntrypoint(run func(opts options) int) int {
+	if !strings.HasSuffix(opts.inputFile, repositorySuffix) {
+		return run(opts)
+	}
+
+	rootPath := filepath.Clean(strings.TrimSuffix(opts.inputFile, repositorySuffix))
+	entrypoints := static_analysis.FindMainPackages(rootPath)
+	if len(entrypoints) == 0 {
+		log.Fatalf("No main package found in %s\n", rootPath)
+	}
+
+	exitCode := 0
+	for _, entrypoint := range entrypoints {
+		// The output directory mirrors the position of the package in the repository
+		relPath, err := filepath.Rel(rootPath, entrypoint)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if relPath == "." {
+			relPath = filepath.Base(entrypoint)
+		}
+
+		entrypointOpts := opts
+		entrypointOpts.inputFile = entrypoint
+		entrypointOpts.outputPath = filepath.Join(opts.outputPath, relPath)
+		entrypointOpts.artifactsDir = filepath.Join(opts.artifactsDir, relPath)
+		// The default trace is kept next to the results of the package, else each one would overwrite the previous
+		if opts.traceFile == filepath.Join(opts.outputPath, output.TraceFile) {
+			entrypointOpts.traceFile = filepath.Join(entrypointOpts.outputPath, output.TraceFile)
+		}
+
+		fmt.Fprintf(os.Stderr, "== %s\n", entrypoint)
+		exitCode |= run(entrypointOpts)
+	}
+
+	return exitCode
+}
+
+// ----------------------------------------------------------------------------
+// Output handling
+
+// Creates from scratch the output directory, removing the previous content (if any), and
+// returns the Layout through which the results are saved in it
+func (opts options) prepareOutput() *output.Layout {
+	layout := output.New(opts.outputPath)
+	if err := layout.Prepare(); err != nil {
+		log.Fatal(err)
+	}
+	return layout
+}
+
+// Opens the trace file and redirects the events of the tracer to it, the file is closed when the subcommand
+// completes. Since the trace is written as the analysis goes on it's meant to be called after prepareOutput
+func (opts options) startTracing() {
+	if opts.traceMode == static_analysis.NoTrace {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(opts.traceFile), 0775); err != nil {
+		log.Fatal(err)
+	}
+	traceFile, err := os.Create(opts.traceFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tracing.SetOutput(traceFile)
+	exitHooks = append(exitHooks, func() { traceFile.Close() })
+	logging.Infof("Tracing the analysis (%s) to %s", opts.traceMode, opts.traceFile)
+}
+
+// Exports the given automaton for the given pipeline stage, it does nothing if the
+// user didn't request that stage. The files are saved as "<artifactsDir>/<stage>/<name>.<ext>"
+func (opts options) dumpStage(stage, name string, automaton *fsa.FSA) {
+	if !opts.dumpStages[stage] {
+		return
+	}
+
+	logging.Debugf("Dumping '%s' automaton for stage '%s'", name, stage)
+	opts.export(opts.stageLayout.InFolder(stage, name), automaton)
+}
+
+// Renames (and merges) the participants of the given artifacts as stated by the directives, it's
+// meant to be used only before the export: the checks rely on the original names of the Goroutines
+func (opts options) applyDirectives(artifacts *pipeline.Artifacts) {
+	if opts.directives.Empty() {
+		return
+	}
+
+	artifacts.LocalViews = transforms.RenameLocalViews(artifacts.LocalViews, opts.directives)
+	if artifacts.Choreography != nil {
+		artifacts.Choreography = transforms.RenameParticipants(artifacts.Choreography, opts.directives, opts.verbosity)
+	}
+}
+
+// Exports the given automaton to "<basePath>.dot" and optionally to "<basePath>.svg" and "<basePath>.json"
+func (opts options) export(basePath string, automaton *fsa.FSA) {
+	opts.exportAs(basePath, automaton, (*fsa.FSA).Export)
+}
+
+// Exports the Choreography Automata as export does, with the edges where each participant is spawned and where
+// it terminates drawn with distinct styles (see transforms.LifecycleDecorator). The decorator is computed on the
+// automaton actually exported, since the simplification renumbers its states
+func (opts options) exportGlobal(basePath string, choreography *fsa.FSA, localViews map[string]*transforms.GoroutineFSA) {
+	opts.exportAs(basePath, choreography, func(automaton *fsa.FSA, outputFile string, format graphviz.Format) error {
+		return automaton.ExportDecorated(outputFile, format, transforms.LifecycleDecorator(automaton, localViews))
+	})
+}
+
+// Exports the given local view as export does, with the implicit sink state if requested (see fsa.ExportWithSink)
+func (opts options) exportLocalView(basePath string, automaton *fsa.FSA) {
+	if !opts.showSink {
+		opts.export(basePath, automaton)
+		return
+	}
+	opts.exportAs(basePath, automaton, (*fsa.FSA).ExportWithSink)
+}
+
+// Exports the (simplified, if requested) automaton with the given export method in the requested formats
+func (opts options) exportAs(basePath string, automaton *fsa.FSA, export func(*fsa.FSA, string, graphviz.Format) error) {
+	if opts.simplify {
+		automaton = transforms.Simplify(automaton)
+	}
+
+	if err := export(automaton, fmt.Sprintf("%s.dot", basePath), graphviz.XDOT); err != nil {
+		log.Fatal(err)
+	}
+	// Additional export of .svg automaton
+	if opts.svgExport {
+		if err := export(automaton, fmt.Sprintf("%s.svg", basePath), graphviz.SVG); err != nil {
+			log.Fatal(err)
+		}
+	}
+	// The JSON document is the same whatever the export method, it has no drawing attributes
+	if opts.jsonExport {
+		if err := output.WriteAutomaton(fmt.Sprintf("%s.json", basePath), automaton); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// Exports the given local views in a single diagram (see transforms.ExportSystemOverview)
+// to "<outputPath>/overview.dot" and optionally to "<outputPath>/overview.svg"
+func (opts options) exportOverview(layout *output.Layout, localViews map[string]*transforms.GoroutineFSA) {
+	basePath := layout.Overview()
+
+	if opts.simplify {
+		simplifiedViews := make(map[string]*transforms.GoroutineFSA, len(localViews))
+		for name, lView := range localViews {
+			simplifiedView := *lView
+			simplifiedView.Automaton = transforms.Simplify(lView.Automaton)
+			simplifiedViews[name] = &simplifiedView
+		}
+		localViews = simplifiedViews
+	}
+
+	if err := transforms.ExportSystemOverview(localViews, fmt.Sprintf("%s.dot", basePath), graphviz.XDOT); err != nil {
+		log.Fatal(err)
+	}
+	// Additional export of .svg overview
+	if opts.svgExport {
+		if err := transforms.ExportSystemOverview(localViews, fmt.Sprintf("%s.svg", basePath), graphviz.SVG); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// This is the entry point of the whole program (Choreia).
+// It handles directly all the interaction with the respective utilities module in order
+// to extract the Choreography Automata from the given input file
+package main
+
+import (
+	"log"
+	"plugin"
+
+	// Choreia internal leveled logger
+	"github.com/its-hmny/Choreia/internal/logging"
+	// Choreia internal layout of the results directory
+	"github.com/its-hmny/Choreia/internal/output"
+	// Choreia internal pipeline stages and hooks
+	"github.com/its-hmny/Choreia/internal/pipeline"
+	// Choreia internal static analysis and metatdata extraction module
+	"github.com/its-hmny/Choreia/internal/static_analysis"
+	// Choreia internal Choreography Automata transformation module
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+// ----------------------------------------------------------------------------
+// Pipeline shared by the subcommands
+
+// Creates the pipeline that analyzes the input file with the given options, the stub models are
+// injected in the metadata by a hook (right after the extraction) while another one dumps the
+// intermediate automata of the stages requested by the user. The plugins are loaded (and the trace file opened) beforehand
+func newPipeline(opts options) *pipeline.Pipeline {
+	loadPlugins(opts)
+	opts.startTracing()
+	opts.stageLayout = output.New(opts.artifactsDir)
+
+	analysis := pipeline.New(opts.inputFile)
+	analysis.TraceMode = opts.traceMode
+	analysis.ExcludeNil = opts.excludeNil
+	analysis.MinimizeLocal = opts.minimize
+	analysis.Verbosity = opts.verbosity
+	analysis.Symmetry = opts.symmetry
+	analysis.Strategy = opts.strategy
+	analysis.Participants = opts.participants
+	analysis.Assertions = opts.assertions
+
+	analysis.AddHook(pipeline.HookFunc(func(stage pipeline.Stage, artifacts *pipeline.Artifacts) {
+		if stage == pipeline.Extract {
+			loadStubs(opts, artifacts.Metadata)
+		}
+	}))
+	analysis.AddHook(pipeline.HookFunc(opts.dumpArtifacts))
+
+	return analysis
+}
+
+// Dumps the automata produced by the given stage, the ScopeAutomata of each function (before and
+// after the inlining of function calls), the local views (NFA and DFA) and the Choreography Automata
+func (opts options) dumpArtifacts(stage pipeline.Stage, artifacts *pipeline.Artifacts) {
+	switch stage {
+	case pipeline.Extract:
+		for _, funcMeta := range artifacts.Metadata.FunctionMeta {
+			opts.dumpStage(scopeStage, funcMeta.Name, funcMeta.Automaton)
+		}
+		if opts.dumpStages[linearizedStage] {
+			for funcName, automaton := range transforms.LinearizeFunctions(artifacts.Metadata) {
+				opts.dumpStage(linearizedStage, funcName, automaton)
+			}
+		}
+	case pipeline.Project:
+		for _, lView := range artifacts.LocalViews {
+			opts.dumpStage(localViewStage, lView.Name, lView.Automaton)
+		}
+	case pipeline.Determinize:
+		for _, lView := range artifacts.LocalViews {
+			opts.dumpStage(deterministicStage, lView.Name, lView.Automaton)
+		}
+	case pipeline.Compose:
+		opts.dumpStage(globalStage, "Choreography Automata", artifacts.Choreography)
+	}
+}
+
+// Opens the Go plugins given via CLI argument, every plugin registers its own custom extractors
+// (see static_analysis.RegisterExtractor) in its init() function so nothing else has to be looked up.
+// The network and the interface dispatch extractors, that are built-in, are registered here as well when requested
+func loadPlugins(opts options) {
+	if opts.network {
+		static_analysis.EnableNetworkHandlers()
+	}
+	if opts.dispatch {
+		static_analysis.EnableInterfaceDispatch()
+	}
+	for _, pluginPath := range opts.plugins {
+		if _, err := plugin.Open(pluginPath); err != nil {
+			log.Fatal(err)
+		}
+		logging.Infof("Loaded extractor plugin %s", pluginPath)
+	}
+	if len(opts.plugins) > 0 {
+		logging.Debugf("Custom extractors registered: %v", static_analysis.RegisteredExtractors())
+	}
+}
+
+// Loads the stub models given via CLI argument and registers them in the metadata, this way the calls
+// to the modeled external functions are inlined instead of being replaced with eps transitions
+func loadStubs(opts options, fileMetadata static_analysis.FileMetadata) {
+	for _, stubPath := range opts.stubPaths {
+		stubFiles, err := static_analysis.FindStubs(stubPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, stubFile := range stubFiles {
+			name, automaton, err := static_analysis.LoadStub(stubFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			logging.Infof("Loaded stub model of '%s' from %s", name, stubFile)
+			fileMetadata.AddStub(name, automaton)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import "fmt"
+
+func worker(results chan int) {
+	done := make(chan bool)
+
+	// The closure captures both the formal argument and a local channel
+	go func() {
+		results <- 1
+		done <- true
+	}()
+
+	<-done
+}
+
+func main() {
+	values := make(chan int)
+
+	go worker(values)
+	// The closure captures the channel declared in main
+	go func(n int) {
+		values <- n
+	}(2)
+
+	first, second := <-values, <-values
+	fmt.Println(first, second)
+}
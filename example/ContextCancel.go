@@ -0,0 +1,48 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+)
+
+func worker(ctx context.Context, results chan int) {
+	ticker := time.Tick(time.Millisecond * 100)
+
+	// Produces a result at every tick until the context is cancelled
+	for {
+		select {
+		case <-ticker:
+			results <- 1
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func main() {
+	// The context is cancelled either by the user (SIGINT) or by the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	results := make(chan int)
+	go worker(ctx, results)
+
+	for {
+		select {
+		case value := <-results:
+			fmt.Println("Received", value)
+		case <-interrupt:
+			fmt.Println("Interrupted")
+			return
+		}
+	}
+}
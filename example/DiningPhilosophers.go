@@ -0,0 +1,33 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import "fmt"
+
+func philosopher(left chan bool, right chan bool) {
+	for {
+		// Picks up both the forks, then eats
+		<-left
+		<-right
+		fmt.Println("Eating")
+		// Puts down the forks
+		left <- true
+		right <- true
+	}
+}
+
+func main() {
+	// Creates one (buffered) channel for each fork on the table
+	forkA, forkB, forkC := make(chan bool, 1), make(chan bool, 1), make(chan bool, 1)
+
+	// Puts the forks on the table
+	forkA <- true
+	forkB <- true
+	forkC <- true
+
+	// Every philosopher shares a fork with the one sitting next to them
+	go philosopher(forkA, forkB)
+	go philosopher(forkB, forkC)
+	go philosopher(forkC, forkA)
+}
@@ -0,0 +1,30 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import "fmt"
+
+func sender(channel chan int) {
+	channel <- 1
+}
+
+func main() {
+	chanA, chanB := make(chan int), make(chan int)
+
+	go sender(chanA)
+	go sender(chanB)
+
+	// Receives once from each channel, the branch of a channel is disabled
+	// (by setting the latter to nil) after the first message received on it
+	for chanA != nil || chanB != nil {
+		select {
+		case <-chanA:
+			chanA = nil
+		case <-chanB:
+			chanB = nil
+		}
+	}
+
+	fmt.Println("Received from both channels")
+}
@@ -0,0 +1,26 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import "fmt"
+
+func player(name string, receive chan int, send chan int) {
+	for ball := range receive {
+		fmt.Printf("%s hits the ball (%d)\n", name, ball)
+		send <- ball + 1 // Sends the ball back to the other player
+	}
+}
+
+func main() {
+	// Creates the channels, one for each direction of the game
+	ping, pong := make(chan int), make(chan int)
+
+	// Starts the player processes
+	go player("ping", ping, pong)
+	go player("pong", pong, ping)
+
+	// Serves the ball and then waits for it to come back
+	ping <- 0
+	<-pong
+}
@@ -0,0 +1,32 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import "fmt"
+
+func generator(out chan int) {
+	for i := 0; i < 3; i++ {
+		out <- i // Emits the numbers on the first stage of the pipeline
+	}
+}
+
+func square(in chan int, out chan int) {
+	for n := range in {
+		out <- n * n // Forwards the squared number to the next stage
+	}
+}
+
+func main() {
+	// Creates the channels that link the stages of the pipeline
+	numbers, squares := make(chan int), make(chan int)
+
+	// Starts the pipeline stages
+	go generator(numbers)
+	go square(numbers, squares)
+
+	// Consumes the output of the last stage
+	for result := range squares {
+		fmt.Println(result)
+	}
+}
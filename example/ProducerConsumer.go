@@ -0,0 +1,30 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import "fmt"
+
+func producer(queue chan string, done chan bool) {
+	queue <- "first"
+	queue <- "second"
+	done <- true // Notifies that nothing else will be produced
+}
+
+func consumer(queue chan string) {
+	for item := range queue {
+		fmt.Println("Consumed", item)
+	}
+}
+
+func main() {
+	// Creates a buffered queue and a synchronization channel
+	queue, done := make(chan string, 2), make(chan bool)
+
+	// Starts the producer and consumer processes
+	go producer(queue, done)
+	go consumer(queue)
+
+	// Waits for the producer to complete
+	<-done
+}
@@ -0,0 +1,30 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+func slowResponder(channel chan string) {
+	time.Sleep(time.Second * 2)
+	channel <- "response"
+}
+
+func main() {
+	// Creates the channel on which the response is awaited
+	response := make(chan string)
+
+	// Starts the responder process
+	go slowResponder(response)
+
+	// Waits for the response or gives up after a second
+	select {
+	case msg := <-response:
+		fmt.Println("Received", msg)
+	case <-time.After(time.Second):
+		fmt.Println("Timeout")
+	}
+}
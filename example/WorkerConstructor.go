@@ -0,0 +1,32 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import "fmt"
+
+func worker(jobs chan int, results chan int) {
+	for job := range jobs {
+		results <- job * 2
+	}
+}
+
+// Constructor that hides the channels creation and the spawn of a worker, the caller
+// interacts with the latter only through the channels returned
+func startWorker() (chan int, chan int) {
+	jobs, results := make(chan int), make(chan int, 1)
+	go worker(jobs, results)
+	return jobs, results
+}
+
+func main() {
+	// Each call starts its own worker, with its own channels
+	firstJobs, firstResults := startWorker()
+	secondJobs, secondResults := startWorker()
+
+	firstJobs <- 1
+	secondJobs <- 2
+
+	first, second := <-firstResults, <-secondResults
+	fmt.Println(first, second)
+}
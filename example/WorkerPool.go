@@ -0,0 +1,29 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import "fmt"
+
+func worker(jobs chan int, results chan int) {
+	for job := range jobs {
+		results <- job * 2 // Sends back the result of the job (fan-in)
+	}
+}
+
+func main() {
+	// Creates the channels shared by all the workers
+	jobs, results := make(chan int), make(chan int)
+
+	// Starts the worker processes
+	go worker(jobs, results)
+	go worker(jobs, results)
+
+	// Dispatches the jobs to the workers (fan-out)
+	jobs <- 1
+	jobs <- 2
+
+	// Collects the results
+	fmt.Println(<-results)
+	fmt.Println(<-results)
+}
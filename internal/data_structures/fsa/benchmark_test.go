@@ -0,0 +1,47 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Benchmarks for the visits of the FSA, with and without changes made during them.
+// Run them with "go test ./internal/data_structures/fsa -run ^$ -bench ."
+package fsa
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Generates a chain of "size" states, each one linked to the next by a Send and a Recv on the same channel
+func chainAutomaton(size int) *FSA {
+	automaton := New()
+	for i := 0; i < size; i++ {
+		automaton.AddTransition(i, i+1, Transition{Move: Send, Label: "ch"})
+		automaton.AddTransition(i, i+1, Transition{Move: Recv, Label: "ch"})
+	}
+	automaton.SetFinalState(size)
+	return automaton
+}
+
+func BenchmarkForEachTransition(b *testing.B) {
+	for _, size := range []int{16, 256, 4096} {
+		automaton := chainAutomaton(size)
+
+		// The visit pins the adjacency list, nothing is copied when the callback only reads
+		b.Run(fmt.Sprintf("read/states=%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				automaton.ForEachTransition(func(_, _ int, _ Transition) {})
+			}
+		})
+
+		// Every transition is replaced as the inlining does, the adjacency list is copied once per visit
+		b.Run(fmt.Sprintf("replace/states=%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				replaced := automaton.Copy()
+				replaced.ForEachTransition(func(from, to int, t Transition) {
+					replaced.RemoveTransition(from, to, t)
+					replaced.AddTransition(from, to, Transition{Move: t.Move, Label: "other"})
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// This package implements a Finite State Automata (FSA) data structure and its own API.
+// For this specific use cases the implementation is quite simple & basic
+
+// The only struct available from the outside is Builder and its own (fluent) API
+package fsa
+
+import "log"
+
+// ----------------------------------------------------------------------------------------
+// Builder
+
+// A Builder allows to describe an FSA with a fluent interface, the states are referenced by name
+// and the ids are assigned by the Builder itself in order of appearence (the first one is 0).
+// It's mainly intended for tests and hand-written models, for example:
+//
+//	automaton := fsa.NewBuilder().
+//		State("s0").Send("ch").To("s1").
+//		Recv("ack").To("s2").
+//		Final("s2").
+//		Build()
+//
+// The source of the next transition is the state selected with State or the target
+// of the previous transition, so that a chain of transitions can be written in a row
+type Builder struct {
+	automaton *FSA           // The FSA under construction
+	ids       map[string]int // The id assigned to each named state
+	current   string         // The state from which the next transition starts
+	pending   *Transition    // The transition that will be added by the next call to To
+}
+
+// Creates and returns a Builder for a new (empty) FSA
+func NewBuilder() *Builder {
+	return &Builder{automaton: New(), ids: make(map[string]int)}
+}
+
+// Returns the id of the state with the given name, the id is assigned on the first reference
+func (b *Builder) id(name string) int {
+	if id, exist := b.ids[name]; exist {
+		return id
+	}
+	b.ids[name] = len(b.ids)
+	return b.ids[name]
+}
+
+// Selects the state with the given name as the source of the next transition
+func (b *Builder) State(name string) *Builder {
+	b.id(name)
+	b.current = name
+	return b
+}
+
+// Begins a transition with the given Move and Label from the current state, the Payload is optional
+func (b *Builder) On(move MoveKind, label string, payload ...interface{}) *Builder {
+	if b.current == "" {
+		log.Fatalf("no source state selected for the transition '%s'", label)
+	}
+
+	b.pending = &Transition{Move: move, Label: label}
+	if len(payload) > 0 {
+		b.pending.Payload = payload[0]
+	}
+	return b
+}
+
+// Begins a Send transition on the given channel from the current state
+func (b *Builder) Send(channel string) *Builder { return b.On(Send, channel) }
+
+// Begins a Recv transition on the given channel from the current state
+func (b *Builder) Recv(channel string) *Builder { return b.On(Recv, channel) }
+
+// Begins a Call transition to the given function from the current state
+func (b *Builder) Call(function string) *Builder { return b.On(Call, function) }
+
+// Begins a Spawn transition of the given function from the current state
+func (b *Builder) Spawn(function string) *Builder { return b.On(Spawn, function) }
+
+// Begins an eps-transition with the given label from the current state
+func (b *Builder) Eps(label string) *Builder { return b.On(Eps, label) }
+
+// Ends the pending transition in the state with the given name, the latter becomes the current state
+func (b *Builder) To(name string) *Builder {
+	if b.pending == nil {
+		log.Fatalf("no pending transition to the state '%s'", name)
+	}
+
+	b.automaton.AddTransition(b.id(b.current), b.id(name), *b.pending)
+	b.pending, b.current = nil, name
+	return b
+}
+
+// Marks the states with the given names as final
+func (b *Builder) Final(names ...string) *Builder {
+	for _, name := range names {
+		b.automaton.SetFinalState(b.id(name))
+	}
+	return b
+}
+
+// Marks the state with the given name as the initial one (by default the first state referenced)
+func (b *Builder) Initial(name string) *Builder {
+	b.automaton.SetInitialState(b.id(name))
+	return b
+}
+
+// Returns the id assigned to the state with the given name, or Unknown if it has never been referenced
+func (b *Builder) StateId(name string) int {
+	if id, exist := b.ids[name]; exist {
+		return id
+	}
+	return Unknown
+}
+
+// Returns the FSA described so far, the Builder shouldn't be used anymore after this call
+func (b *Builder) Build() *FSA {
+	if b.pending != nil {
+		log.Fatalf("the transition '%s' has no target state", b.pending.Label)
+	}
+	return b.automaton
+}
@@ -0,0 +1,37 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the fluent Builder of the FSA data structure
+package fsa
+
+import "testing"
+
+func TestBuilder(t *testing.T) {
+	builder := NewBuilder().
+		State("idle").Recv("request").To("busy").
+		Send("response").To("idle").
+		State("busy").Eps("shutdown").To("stopped").
+		Final("stopped")
+	automaton := builder.Build()
+
+	// The ids are assigned in order of appearence and the chain continues from the last target
+	expected := "final 2\n0 -> 1 Recv \"request\"\n1 -> 0 Send \"response\"\n1 -> 2 Epsilon \"shutdown\"\n"
+	if automaton.String() != expected {
+		t.Errorf("expected the automaton\n%s\ngot\n%s", expected, automaton)
+	}
+	if builder.StateId("busy") != 1 || builder.StateId("missing") != Unknown {
+		t.Errorf("unexpected state ids %d and %d", builder.StateId("busy"), builder.StateId("missing"))
+	}
+
+	// A different initial state can be selected and the payload is kept
+	custom := NewBuilder().State("a").On(Spawn, "worker", 42).To("b").Initial("b").Build()
+	custom.ForEachTransition(func(_, _ int, tr Transition) {
+		if tr.Payload != 42 {
+			t.Errorf("expected the payload to be kept, got %v", tr.Payload)
+		}
+	})
+	if custom.InitialState() != 1 {
+		t.Errorf("expected 1 as initial state, got %d", custom.InitialState())
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the copy-on-write adjacency list of the FSA data structure
+package fsa
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Returns a small FSA with a parallel transition and a final state
+func sampleFSA() *FSA {
+	automaton := New()
+	automaton.AddTransition(0, 1, Transition{Move: Send, Label: "a"})
+	automaton.AddTransition(0, 1, Transition{Move: Recv, Label: "b"})
+	automaton.AddTransition(1, 2, Transition{Move: Send, Label: "c"})
+	automaton.SetFinalState(2)
+	return automaton
+}
+
+func TestCopyIsolation(t *testing.T) {
+	original := sampleFSA()
+	expected := original.String()
+
+	// Mutating the copy leaves the original untouched
+	copied := original.Copy()
+	copied.AddTransition(2, 3, Transition{Move: Recv, Label: "d"})
+	copied.RemoveTransition(0, 1, Transition{Move: Send, Label: "a"})
+	copied.SetFinalState(3)
+	if original.String() != expected {
+		t.Errorf("the original changed after mutating the copy:\n%s", original)
+	}
+	if copied.String() == expected {
+		t.Errorf("the copy didn't change after being mutated")
+	}
+
+	// And the other way around, mutating the original leaves the copy untouched
+	other := original.Copy()
+	original.RemoveTransition(1, 2, Transition{Move: Send, Label: "c"})
+	if other.String() != expected {
+		t.Errorf("the copy changed after mutating the original:\n%s", other)
+	}
+}
+
+func TestCopyRefs(t *testing.T) {
+	original := sampleFSA()
+	copied := original.Copy()
+	if original.adjacency != copied.adjacency || original.adjacency.refs != 2 {
+		t.Fatalf("expected the copy to share the adjacency list")
+	}
+
+	// The first mutation detaches the copy, the original is left as the only owner
+	copied.AddTransition(2, 3, Transition{Move: Recv, Label: "d"})
+	if original.adjacency == copied.adjacency {
+		t.Fatalf("expected the mutated copy to own a private adjacency list")
+	}
+	if original.adjacency.refs != 1 || copied.adjacency.refs != 1 {
+		t.Errorf("expected a single owner for both lists, got %d and %d", original.adjacency.refs, copied.adjacency.refs)
+	}
+
+	// An iteration borrows the list only for its own duration
+	original.ForEachTransition(func(from, to int, t Transition) {})
+	if original.adjacency.refs != 1 {
+		t.Errorf("expected the iteration to release the list, got %d refs", original.adjacency.refs)
+	}
+}
+
+func TestCopyDuringIteration(t *testing.T) {
+	automaton := sampleFSA()
+	copied := automaton.Copy()
+	expected := automaton.String()
+
+	// The callback mutates the FSA but the iteration keeps visiting the frozen adjacency list
+	visited := []string{}
+	automaton.ForEachTransition(func(from, to int, t Transition) {
+		visited = append(visited, fmt.Sprintf("%d -> %d %s", from, to, t.Label))
+		automaton.RemoveTransition(from, to, t)
+		automaton.AddTransition(to, to+10, Transition{Move: Eps, Label: strings.ToUpper(t.Label)})
+	})
+
+	if got := strings.Join(visited, ", "); got != "0 -> 1 a, 0 -> 1 b, 1 -> 2 c" {
+		t.Errorf("expected only the frozen transitions to be visited, got %s", got)
+	}
+	if automaton.adjacency.refs != 1 {
+		t.Errorf("expected the iteration to release the list, got %d refs", automaton.adjacency.refs)
+	}
+
+	// The changes made by the callback are visible once the iteration is completed
+	count := 0
+	automaton.ForEachTransition(func(from, to int, tr Transition) {
+		if tr.Move != Eps || to != from+10 {
+			t.Errorf("unexpected transition %d -> %d %s after the iteration", from, to, tr)
+		}
+		count++
+	})
+	if count != 3 {
+		t.Errorf("expected 3 transitions after the iteration, got %d", count)
+	}
+
+	// The copy made before the iteration still shares nothing with the mutated FSA
+	if copied.String() != expected {
+		t.Errorf("the copy changed after mutating the original during an iteration:\n%s", copied)
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// This package implements a Finite State Automata (FSA) data structure and its own API.
+// For this specific use cases the implementation is quite simple & basic
+
+// The only method available from the outside are the Exporter interface and its implementations
+package fsa
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/goccy/go-graphviz"
+	"github.com/goccy/go-graphviz/cgraph"
+)
+
+// ----------------------------------------------------------------------------
+// Exporter
+
+// A GraphNode is a node of an exported graph, the Label is omitted when empty (Graphviz shows the Id instead)
+type GraphNode struct {
+	Id      string           // The unique (among the whole graph) id of the node
+	Cluster string           // The id of the cluster the node belongs to (empty for the root graph)
+	Label   string           // The text shown in the node
+	Shape   cgraph.Shape     // The shape of the node (e.g circle, doublecircle for the final states)
+	Style   cgraph.NodeStyle // The style of the node (empty for the default one)
+}
+
+// A GraphEdge is an edge of an exported graph, it links the nodes with the From and To ids
+type GraphEdge struct {
+	Id       string           // The unique (among the whole graph) id of the edge
+	Cluster  string           // The id of the cluster the edge belongs to (empty for the root graph)
+	From, To string           // The nodes linked by the edge
+	Label    string           // The text shown along the edge
+	Tooltip  string           // The text shown when hovering the edge (or its label) in the interactive formats
+	Style    cgraph.EdgeStyle // The style of the edge (empty for the default one)
+}
+
+// An Exporter builds a graph (made of nodes and edges, maybe grouped in clusters) and renders it to a file
+// (or to any writer). The exports of the automata are built through this interface, so that the structure of
+// the graph can be inspected (e.g in the unit tests with a MemoryGraph) without rendering it with the Graphviz
+// library. The clusters and the nodes have to be added before the edges that refer to them, the errors found
+// while building the graph are returned by the rendering (one of the Render methods has to be called once)
+type Exporter interface {
+	AddCluster(id, label string)                            // Adds a cluster (a boxed subgraph) to the root graph
+	AddNode(node GraphNode)                                 // Adds a node to the graph (or to one of its clusters)
+	AddEdge(edge GraphEdge)                                 // Adds an edge between two nodes already added
+	Render(outputFile string, format graphviz.Format) error // Renders the graph to a file and releases its resources
+	RenderTo(w io.Writer, format graphviz.Format) error     // Renders the graph to a writer and releases its resources
+}
+
+// Returns the edge with the given id between the given nodes, labeled with the parallel transitions squashed in
+// it (see EdgeLabel). The full text is moved to the tooltip when the label is shortened
+func LabeledEdge(id, from, to string, parallelT []Transition) GraphEdge {
+	label, tooltip := EdgeLabel(parallelT)
+	return GraphEdge{Id: id, From: from, To: to, Label: label, Tooltip: tooltip}
+}
+
+// An EdgeDecorator customizes the edge that squashes the given parallel transitions between the states from and
+// to before it's added to the graph (e.g to highlight some of them with a different style)
+type EdgeDecorator func(edge *GraphEdge, from, to int, parallelT []Transition)
+
+// ----------------------------------------------------------------------------
+// Graphviz exporter
+
+// The Exporter that renders the graph with the Graphviz library. The first error found while building the graph
+// is kept (the following additions are ignored) and returned by the rendering, once the resources are released
+type graphvizExporter struct {
+	instance *graphviz.Graphviz       // The Graphviz instance used to render the graph
+	graph    *cgraph.Graph            // The root graph
+	clusters map[string]*cgraph.Graph // The clusters added to the root graph, by id
+	nodes    map[string]*cgraph.Node  // The nodes added to the graph (or its clusters), by id
+	err      error                    // The first error found while building the graph
+}
+
+// Returns an Exporter that renders the graph with the Graphviz library (e.g in the DOT or SVG format)
+func NewGraphvizExporter() Exporter {
+	instance := graphviz.New()
+	graph, err := instance.Graph()
+	return &graphvizExporter{instance, graph, make(map[string]*cgraph.Graph), make(map[string]*cgraph.Node), err}
+}
+
+// Returns the graph (the root or one of its clusters) with the given id
+func (ge *graphvizExporter) subgraph(cluster string) *cgraph.Graph {
+	if subgraph, isCluster := ge.clusters[cluster]; isCluster {
+		return subgraph
+	}
+	return ge.graph
+}
+
+// Adds a cluster to the root graph, Graphviz draws as a box only the subgraphs whose name starts with "cluster"
+func (ge *graphvizExporter) AddCluster(id, label string) {
+	if ge.err != nil {
+		return
+	}
+	cluster := ge.graph.SubGraph(id, 1)
+	cluster.SetLabel(label)
+	ge.clusters[id] = cluster
+}
+
+// Adds the given node to its graph, with the attributes that aren't empty
+func (ge *graphvizExporter) AddNode(node GraphNode) {
+	if ge.err != nil {
+		return
+	}
+	gvNode, err := ge.subgraph(node.Cluster).CreateNode(node.Id)
+	if err != nil {
+		ge.err = err
+		return
+	}
+	gvNode.SetShape(node.Shape)
+	if node.Label != "" {
+		gvNode.SetLabel(node.Label)
+	}
+	if node.Style != "" {
+		gvNode.SetStyle(node.Style)
+	}
+	ge.nodes[node.Id] = gvNode
+}
+
+// Adds the given edge to its graph, with the attributes that aren't empty
+func (ge *graphvizExporter) AddEdge(edge GraphEdge) {
+	if ge.err != nil {
+		return
+	}
+	from, to := ge.nodes[edge.From], ge.nodes[edge.To]
+	if from == nil || to == nil {
+		ge.err = fmt.Errorf("the edge %s links the nodes %s and %s that haven't been added", edge.Id, edge.From, edge.To)
+		return
+	}
+
+	gvEdge, err := ge.subgraph(edge.Cluster).CreateEdge(edge.Id, from, to)
+	if err != nil {
+		ge.err = err
+		return
+	}
+	if edge.Label != "" {
+		gvEdge.SetLabel(edge.Label)
+	}
+	if edge.Tooltip != "" {
+		gvEdge.SetTooltip(edge.Tooltip).SetLabelTooltip(edge.Tooltip)
+	}
+	if edge.Style != "" {
+		gvEdge.SetStyle(edge.Style)
+	}
+}
+
+// Renders the graph to the given path and closes both the Graph and the Graphviz instance
+func (ge *graphvizExporter) Render(outputFile string, format graphviz.Format) error {
+	return ge.render(func() error { return ge.instance.RenderFilename(ge.graph, format, outputFile) })
+}
+
+// Renders the graph to the given writer and closes both the Graph and the Graphviz instance
+func (ge *graphvizExporter) RenderTo(w io.Writer, format graphviz.Format) error {
+	return ge.render(func() error { return ge.instance.Render(ge.graph, format, w) })
+}
+
+// Renders the graph with the given function (unless an error has been found while building it), then releases
+// the resources in any case. The first error is returned: the one of the graph, of the rendering or of the closing
+func (ge *graphvizExporter) render(renderGraph func() error) error {
+	err := ge.err
+	if err == nil {
+		err = renderGraph()
+	}
+	if ge.graph != nil {
+		if closeErr := ge.graph.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if closeErr := ge.instance.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// ----------------------------------------------------------------------------
+// In-memory exporter
+
+// A MemoryGraph is an Exporter that keeps the graph in memory instead of rendering it, it's meant to be used
+// as a test double: the nodes and edges added can be inspected without requiring the Graphviz library
+type MemoryGraph struct {
+	Clusters map[string]string // The label of each cluster, by id
+	Nodes    []GraphNode       // The nodes in the order in which they have been added
+	Edges    []GraphEdge       // The edges in the order in which they have been added
+	Rendered []string          // The output files of the Render calls (empty for RenderTo), nothing is written to them
+}
+
+// Returns an empty MemoryGraph
+func NewMemoryGraph() *MemoryGraph {
+	return &MemoryGraph{Clusters: make(map[string]string), Nodes: []GraphNode{}, Edges: []GraphEdge{}, Rendered: []string{}}
+}
+
+// Saves the given cluster
+func (mg *MemoryGraph) AddCluster(id, label string) {
+	mg.Clusters[id] = label
+}
+
+// Saves the given node
+func (mg *MemoryGraph) AddNode(node GraphNode) {
+	mg.Nodes = append(mg.Nodes, node)
+}
+
+// Saves the given edge, an error is returned by the next Render if its nodes haven't been added (as Graphviz does)
+func (mg *MemoryGraph) AddEdge(edge GraphEdge) {
+	mg.Edges = append(mg.Edges, edge)
+}
+
+// Saves the given output file, returns an error if an edge links a node that doesn't exist
+func (mg *MemoryGraph) Render(outputFile string, _ graphviz.Format) error {
+	for _, edge := range mg.Edges {
+		_, hasFrom := mg.Node(edge.From)
+		_, hasTo := mg.Node(edge.To)
+		if !hasFrom || !hasTo {
+			return fmt.Errorf("the edge %s links the nodes %s and %s that haven't been added", edge.Id, edge.From, edge.To)
+		}
+	}
+	mg.Rendered = append(mg.Rendered, outputFile)
+	return nil
+}
+
+// Checks the graph as Render does, nothing is written to the given writer
+func (mg *MemoryGraph) RenderTo(_ io.Writer, format graphviz.Format) error {
+	return mg.Render("", format)
+}
+
+// Returns the node with the given id, false if it hasn't been added
+func (mg *MemoryGraph) Node(id string) (GraphNode, bool) {
+	for _, node := range mg.Nodes {
+		if node.Id == id {
+			return node, true
+		}
+	}
+	return GraphNode{}, false
+}
+
+// Returns the edges from and to the given nodes, in the order in which they have been added
+func (mg *MemoryGraph) EdgesBetween(from, to string) []GraphEdge {
+	edges := []GraphEdge{}
+	for _, edge := range mg.Edges {
+		if edge.From == from && edge.To == to {
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
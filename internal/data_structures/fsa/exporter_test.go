@@ -0,0 +1,89 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the graph built by the exports, inspected through the in-memory exporter
+package fsa
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-graphviz"
+	"github.com/goccy/go-graphviz/cgraph"
+)
+
+func TestDraw(t *testing.T) {
+	automaton := New()
+	automaton.AddTransition(0, 1, Transition{Move: Send, Label: "ping"})
+	automaton.AddTransition(0, 1, Transition{Move: Recv, Label: "pong"})
+	automaton.AddTransition(1, 2, Transition{Move: Eps, Label: "done"})
+	automaton.SetFinalState(2)
+
+	graph := NewMemoryGraph()
+	automaton.Draw(graph, true)
+	if err := graph.Render("view.dot", graphviz.XDOT); err != nil {
+		t.Fatal(err)
+	}
+
+	// One node per state, plus the start point and the sink
+	if len(graph.Nodes) != 5 {
+		t.Fatalf("expected 5 nodes, got %v", graph.Nodes)
+	}
+	if node, _ := graph.Node("2"); node.Shape != cgraph.DoubleCircleShape {
+		t.Errorf("expected the final state to be a double circle, got %v", node)
+	}
+	if node, _ := graph.Node("0"); node.Shape != cgraph.CircleShape {
+		t.Errorf("expected the other states to be circles, got %v", node)
+	}
+	if start := graph.EdgesBetween("start", "0"); len(start) != 1 {
+		t.Errorf("expected the initial state to be pointed by the start node, got %v", start)
+	}
+
+	// The parallel transitions are squashed in a single edge
+	squashed, _ := EdgeLabel([]Transition{{Move: Send, Label: "ping"}, {Move: Recv, Label: "pong"}})
+	if edges := graph.EdgesBetween("0", "1"); len(edges) != 1 || edges[0].Label != squashed {
+		t.Errorf("expected a single edge with both the transitions, got %v", edges)
+	}
+	if sink, hasSink := graph.Node(sinkNodeId); !hasSink || sink.Style != cgraph.DashedNodeStyle {
+		t.Errorf("expected the dashed sink node, got %v", sink)
+	}
+	if edges := graph.EdgesBetween("2", sinkNodeId); len(edges) != 1 || edges[0].Style != cgraph.DashedEdgeStyle {
+		t.Errorf("expected a dashed edge from the final state to the sink, got %v", edges)
+	}
+}
+
+func TestMemoryGraphRender(t *testing.T) {
+	graph := NewMemoryGraph()
+	graph.AddNode(GraphNode{Id: "0"})
+	graph.AddEdge(GraphEdge{Id: "0-1", From: "0", To: "1"})
+	if err := graph.Render("view.dot", graphviz.XDOT); err == nil || len(graph.Rendered) != 0 {
+		t.Errorf("expected an error for the edge to a missing node")
+	}
+}
+
+func TestExportTo(t *testing.T) {
+	automaton := New()
+	automaton.AddTransition(0, 1, Transition{Move: Send, Label: "ping"})
+	automaton.SetFinalState(1)
+
+	var buffer bytes.Buffer
+	if err := automaton.ExportTo(&buffer, graphviz.XDOT); err != nil {
+		t.Fatal(err)
+	}
+	if content := buffer.String(); !strings.Contains(content, "digraph") || !strings.Contains(content, "0 -> 1") {
+		t.Errorf("expected the graph to be written to the buffer, got\n%s", content)
+	}
+}
+
+func TestGraphvizExporterError(t *testing.T) {
+	// The edge to a missing node is reported by the rendering, nothing is written
+	graph := NewGraphvizExporter()
+	graph.AddNode(GraphNode{Id: "0", Shape: cgraph.CircleShape})
+	graph.AddEdge(GraphEdge{Id: "0-1", From: "0", To: "1"})
+	var buffer bytes.Buffer
+	if err := graph.RenderTo(&buffer, graphviz.XDOT); err == nil || buffer.Len() != 0 {
+		t.Errorf("expected an error for the edge to a missing node, got %v\n%s", err, buffer.String())
+	}
+}
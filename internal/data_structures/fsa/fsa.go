@@ -10,7 +10,9 @@ package fsa
 
 import (
 	"fmt"
+	"io"
 	"log"
+	"sort"
 
 	list "github.com/emirpasic/gods/lists/singlylinkedlist"
 	set "github.com/emirpasic/gods/sets/hashset"
@@ -22,10 +24,26 @@ const (
 	// State.Id error value
 	Unknown = -1
 	// FSA.AddTransition() default values for "from" and "to"
+	//
+	// Deprecated: the implicit root mutated by these flags is fragile when the execution flow forks and
+	// merges, the states should be threaded explicitly with the ids returned by AddState and AddTransition
 	NewState = -2
-	Current  = -3
+	// Deprecated: see NewState
+	Current = -3
 )
 
+const (
+	// DedupPolicy enum
+	DedupIdentical DedupPolicy = iota // A transition identical to a parallel one (see Transition.Identical) isn't added
+	DedupOff                          // Every transition is added, even the ones identical to a parallel one
+)
+
+// Type alias to abstract the DedupPolicy enum, how AddTransition handles the parallel transitions
+type DedupPolicy int
+
+// The handle of a state of the FSA, as returned by AddState and AddTransition
+type StateID = int
+
 // ----------------------------------------------------------------------------------------
 // FSA
 
@@ -35,52 +53,158 @@ const (
 //
 // A struct containing a basic graph implementation that keeps track of the transition that
 // occurs subsequently during the execution flow of a function (or scope).
+//
+// A FSA isn't safe for concurrent use while it's being modified, not even the read methods since the
+// iterations keep track of themselves in the adjacency list (see ForEachTransition). Once frozen (see Freeze)
+// the FSA can't be modified anymore and it can be read (and copied) by many Goroutines at once
 type FSA struct {
-	currentId   int                          // The last id generated, the id of the last node
-	transitions map[int]map[int][]Transition // Adjacency matrix of transition from edge to edge
-	FinalStates *list.List                   // A list containing the ids of the final/accepting states
+	currentId   int         // The last id generated, the id of the last node
+	lastId      int         // The biggest id used so far by the states of the FSA (recomputed only by Prune)
+	initialId   int         // The id of the initial state (0 unless changed with SetInitialState)
+	frozen      bool        // The FSA can't be modified anymore (see Freeze)
+	dedup       DedupPolicy // How the parallel transitions identical to each other are handled (see SetDedupPolicy)
+	adjacency   *adjacency  // Adjacency list of transition from edge to edge (shared on copy)
+	FinalStates *list.List  // A list containing the ids of the final/accepting states
+}
+
+// A single outgoing transition of a state, stored in the adjacency list
+type edge struct {
+	to int        // The ending state of the transition
+	t  Transition // The transition itself
+}
+
+// A compact adjacency list: each starting state has a slice of outgoing edges sorted by
+// ending state (the parallel ones are kept in insertion order). The list can be shared by
+// more FSAs (copy-on-write), the first FSA that mutates a shared list makes its own copy.
+// A frozen list is never written again (its refs included), every FSA sharing it makes its own copy
+type adjacency struct {
+	rows   map[int][]edge // The outgoing edges of each starting state
+	refs   int            // The number of FSAs (or iterations) that are currently sharing the list
+	frozen bool           // The list belongs to a frozen FSA (see FSA.Freeze), the refs aren't counted anymore
 }
 
 // Generates a new empty FSA and returns a pointer reference to it
 func New() *FSA {
 	newFsa := FSA{
 		currentId:   0,
+		lastId:      0,
 		FinalStates: list.New(),
 		// A FSA has always an initial state
-		transitions: map[int]map[int][]Transition{0: nil},
+		adjacency: &adjacency{rows: map[int][]edge{0: nil}, refs: 1},
 	}
 
 	return &newFsa
 }
 
-// This function generates an independent copy of the given FSA and returns it
+// This function generates an independent copy of the given FSA and returns it. The adjacency
+// list is shared between the two FSAs until one of them is modified (copy-on-write), then the
+// modified one gets its own copy. So copying a FSA that is never modified is almost free
+// The copy of a frozen FSA can be modified, the frozen one is left untouched as well
 func (original *FSA) Copy() *FSA {
+	if !original.adjacency.frozen {
+		original.adjacency.refs++
+	}
+
 	localCopy := FSA{
 		currentId: original.currentId,
+		lastId:    original.lastId,
+		initialId: original.initialId,
+		dedup:     original.dedup,
+		adjacency: original.adjacency,
 		// Get a copy of the value to enforce two completely independent copies
 		FinalStates: list.New(original.FinalStates.Values()...),
-		transitions: map[int]map[int][]Transition{0: nil},
 	}
 
-	// Iterates over the transition in the original FSA, copying them one by one
-	original.ForEachTransition(func(from, to int, t Transition) {
-		localCopy.AddTransition(from, to, t)
-	})
-
 	return &localCopy
 }
 
-// Adds a new Transition to the FSA on which is called.
-// The user can specify a special flag for the "to" argument and the "from" one
-// (respectively NewState and Current) to create a new node as destination of "t"
-// or use the last generated node as starting point of "t" itself
-func (fsa *FSA) AddTransition(from, to int, t Transition) {
+// Returns the adjacency list rows ready to be modified, if the list is shared with
+// other FSAs then a private copy is made before returning it (copy-on-write)
+func (fsa *FSA) mutableRows() map[int][]edge {
+	fsa.assertMutable()
+
+	if fsa.adjacency.frozen || fsa.adjacency.refs > 1 {
+		if !fsa.adjacency.frozen {
+			fsa.adjacency.refs--
+		}
+
+		rows := make(map[int][]edge, len(fsa.adjacency.rows))
+		for from, outgoing := range fsa.adjacency.rows {
+			rows[from] = append([]edge(nil), outgoing...)
+		}
+
+		fsa.adjacency = &adjacency{rows: rows, refs: 1}
+	}
+
+	return fsa.adjacency.rows
+}
+
+// Makes the FSA immutable: from now on any method that modifies it (e.g AddTransition or Prune) stops the
+// program, while the read methods (e.g ForEachTransition, ShortestPath or the exporters) can be called from many
+// Goroutines at once. The FSA can't be unfrozen, a Copy of it can be modified instead (without copying anything
+// until the first change). It's meant to be called once an automaton is complete (e.g before the local views
+// are exported in parallel), the exported FinalStates list mustn't be modified directly either
+func (fsa *FSA) Freeze() {
+	fsa.frozen, fsa.adjacency.frozen = true, true
+}
+
+// Returns true if the FSA has been frozen, that is it can't be modified anymore (see Freeze)
+func (fsa *FSA) IsFrozen() bool {
+	return fsa.frozen
+}
+
+// Stops the program if the FSA has been frozen, it's called before each change
+func (fsa *FSA) assertMutable() {
+	if fsa.frozen {
+		log.Fatal("a frozen FSA can't be modified, a copy of it has to be made instead")
+	}
+}
+
+// Sets how AddTransition handles a transition identical to a parallel one already present (same states, Move,
+// Label and Payload). With DedupIdentical (the default) the transition is ignored: the extraction relies on it,
+// since the same transition can be added more than once (e.g the composition reaching the same configurations
+// again, or two formal channels of a callee bound to the same actual one). The transitions that only
+// match (see Transition.Matches) are always kept both, so the payloads (e.g a channel that may be nil only in one
+// of the branches) and the positions of the structural labels are never lost. With DedupOff every transition is
+// added, the FSA becomes a true multigraph (RemoveTransition still removes all the identical ones at once).
+// The policy only affects the transitions added from now on, the copies of the FSA keep it
+func (fsa *FSA) SetDedupPolicy(policy DedupPolicy) {
+	fsa.assertMutable()
+	fsa.dedup = policy
+}
+
+// Returns how AddTransition handles the transitions identical to a parallel one (see SetDedupPolicy)
+func (fsa *FSA) DedupPolicy() DedupPolicy {
+	return fsa.dedup
+}
+
+// Returns the range [lo, hi) of the edges in the given row that have "to" as ending state,
+// since the row is sorted by ending state a binary search is used
+func edgeRange(row []edge, to int) (int, int) {
+	lo := sort.Search(len(row), func(i int) bool { return row[i].to >= to })
+	hi := sort.Search(len(row), func(i int) bool { return row[i].to > to })
+	return lo, hi
+}
+
+// Creates a new state in the FSA (without any transition) and returns its id
+func (fsa *FSA) AddState() StateID {
+	fsa.assertMutable()
+	fsa.lastId++
+	return fsa.lastId
+}
+
+// Adds a new Transition to the FSA on which is called, returns the id of the ending state of "t".
+// For compatibility the user can still specify a special flag for the "to" argument and the "from"
+// one (respectively NewState and Current) to create a new node as destination of "t" or use the
+// last generated node as starting point of "t" itself
+func (fsa *FSA) AddTransition(from, to StateID, t Transition) StateID {
 	// Argument checking
 	if from == Unknown || to == Unknown {
 		log.Fatal("unknown starting or ending state on AddTransition")
 	} else if t.Label == "" {
 		log.Fatal("empty labels are not allowed")
 	}
+	fsa.assertMutable()
 
 	// If the user specified the "Current" flag the starting state used is the latest created
 	if from == Current {
@@ -93,24 +217,40 @@ func (fsa *FSA) AddTransition(from, to int, t Transition) {
 		fsa.SetRootId(to)
 	}
 
-	// If the "nested" map is nil is initialized just before usage
-	if fsa.transitions[from] == nil {
-		fsa.transitions[from] = make(map[int][]Transition)
-	}
-
-	// Avoids adding duplicated transitions
-	for _, prevT := range fsa.transitions[from][to] {
-		if prevT.Move == t.Move && prevT.Label == t.Label {
-			return
+	// Resolves the label against the SymbolTable, this way the label is allocated only once
+	// and the transition can be compared with the others by Symbol instead of Label
+	t.Symbol = Symbols.Intern(t.Label)
+	t.Label = Symbols.Name(t.Symbol)
+
+	// Avoids adding duplicated transitions (unless requested, see SetDedupPolicy), the ones with the same label
+	// but a different payload (e.g a Send on a channel that may be nil only in one of the branches) are kept both
+	row := fsa.adjacency.rows[from]
+	lo, hi := edgeRange(row, to)
+	for _, prev := range row[lo:hi] {
+		if fsa.dedup == DedupIdentical && prev.t.Identical(t) {
+			return to
 		}
 	}
 
-	// Adds the new transition in the adjacency matrix
-	fsa.transitions[from][to] = append(fsa.transitions[from][to], t)
+	// Adds the new transition in the adjacency list, after the parallel ones already present
+	rows := fsa.mutableRows()
+	row = append(rows[from], edge{})
+	copy(row[hi+1:], row[hi:])
+	row[hi] = edge{to, t}
+	rows[from] = row
+
+	// Keeps track of the biggest id available
+	if from > fsa.lastId {
+		fsa.lastId = from
+	}
+	if to > fsa.lastId {
+		fsa.lastId = to
+	}
+	return to
 }
 
-// Removes a transition "from" and "to" the specified states with a matching Move and Label.
-// If no such label that fullfills the match criteria is found then the procedure returns
+// Removes a transition "from" and "to" the specified states identical to the given one (same Move, Label
+// and Payload, see Transition.Identical). If no such transition is found then the procedure returns
 // without provinding any kind of error
 func (fsa *FSA) RemoveTransition(from, to int, t Transition) {
 	// Argument checking
@@ -119,155 +259,322 @@ func (fsa *FSA) RemoveTransition(from, to int, t Transition) {
 	} else if t.Label == "" {
 		log.Fatal("empty labels are not allowed")
 	}
+	fsa.assertMutable()
+
+	// Searches for the matching transitions, if there aren't any the FSA is left untouched
+	row := fsa.adjacency.rows[from]
+	lo, hi := edgeRange(row, to)
+	found := false
+	for _, current := range row[lo:hi] {
+		found = found || current.t.Identical(t)
+	}
+	if !found {
+		return
+	}
 
-	// Retrieves the current transition list and creates a new one
-	oldList := fsa.transitions[from][to]
-	newList := make([]Transition, 0, len(oldList))
-
-	// Puts all the non matching transition in the new list, filtering out only the matching one
-	for _, transition := range oldList {
-		if t.Label != transition.Label || t.Move != transition.Move {
-			newList = append(newList, transition)
+	// Filters out only the identical transitions, keeping all the other ones in the same order
+	rows := fsa.mutableRows()
+	newRow := make([]edge, 0, len(rows[from]))
+	for i, current := range rows[from] {
+		if i < lo || i >= hi || !current.t.Identical(t) {
+			newRow = append(newRow, current)
 		}
 	}
 
-	// Overwrites the old list with the new (filtered) one in the adjacency matrix
-	fsa.transitions[from][to] = newList
+	// Overwrites the old row with the new (filtered) one in the adjacency list. The biggest id is kept as it is, the
+	// ending state could be left without transitions but it can still be referenced (e.g it's final or it has been
+	// returned by AddState) so its id can't be used again until the FSA is pruned (see Prune)
+	rows[from] = newRow
 }
 
-// Returns the id of the last state generated
+// Marks the state identified by the given id as a final/accepting state of the FSA.
+// The FinalStates list never contains duplicates, so marking twice the same state has no effect
+func (fsa *FSA) SetFinalState(id int) {
+	fsa.assertMutable()
+	if id == Unknown || fsa.FinalStates.Contains(id) {
+		return
+	}
+	fsa.FinalStates.Add(id)
+}
+
+// Returns true if the state identified by the given id is a final/accepting state of the FSA
+func (fsa *FSA) IsFinalState(id int) bool {
+	return fsa.FinalStates.Contains(id)
+}
+
+// Returns the id of the initial state of the FSA, the state from which every visit (e.g. Prune,
+// ShortestPath, the determinization and the composition) starts. It's 0 unless explicitly changed
+func (fsa *FSA) InitialState() int {
+	return fsa.initialId
+}
+
+// Sets the state identified by the given id as the initial state of the FSA, the state is created
+// (without transitions) if it doesn't exist yet. The previous initial state is kept as a normal state
+func (fsa *FSA) SetInitialState(id int) {
+	fsa.assertMutable()
+	if id < 0 {
+		return
+	}
+
+	if _, exist := fsa.adjacency.rows[id]; !exist {
+		fsa.mutableRows()[id] = nil
+		fsa.lastId = fsa.computeLastId()
+	}
+	fsa.initialId = id
+}
+
+// Returns the id of the last state generated (the biggest id available in the FSA).
+// The ids could be non contiguous (e.g. after Prune) so the number of states isn't used
 func (fsa *FSA) GetLastId() int {
-	stateSet := set.New()
+	return fsa.lastId
+}
 
-	// Populates the state set (duplicate ids are avoided)
-	for from, outgoing := range fsa.transitions {
-		stateSet.Add(from)
-		for to := range outgoing {
-			stateSet.Add(to)
+// Searches for the biggest id among both starting and ending states
+func (fsa *FSA) computeLastId() int {
+	lastId := 0
+
+	for from, outgoing := range fsa.adjacency.rows {
+		if from > lastId {
+			lastId = from
+		}
+		// The row is sorted, so the biggest ending state is the last one
+		if len(outgoing) > 0 && outgoing[len(outgoing)-1].to > lastId {
+			lastId = outgoing[len(outgoing)-1].to
+		}
+	}
+
+	return lastId
+}
+
+// Removes from the FSA all the states that cannot be reached from the initial state
+// as well as the dangling entries (empty rows) left in the adjacency list by RemoveTransition.
+// The remaining states keep their own ids, so after this operation the ids could be non contiguous
+func (fsa *FSA) Prune() {
+	fsa.assertMutable()
+	// Visits the FSA in breadth-first order starting from the initial state
+	reachable := set.New(fsa.initialId)
+	queue := []int{fsa.initialId}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, outgoing := range fsa.adjacency.rows[current] {
+			if !reachable.Contains(outgoing.to) {
+				reachable.Add(outgoing.to)
+				queue = append(queue, outgoing.to)
+			}
 		}
 	}
-	// ! Maybe will be better to use something like stateSet.Max()
-	// ! to get the biggest id available
-	return stateSet.Size() - 1
+
+	// Removes the unreachable states and the empty entries from the adjacency list
+	rows := fsa.mutableRows()
+	for from, outgoing := range rows {
+		// The initial state is always kept, even if it has no outgoing transition
+		if !reachable.Contains(from) || (len(outgoing) == 0 && from != fsa.initialId) {
+			delete(rows, from)
+		}
+	}
+
+	// Removes the unreachable states from the final states list as well
+	prunedFinalStates := list.New()
+	for _, item := range fsa.FinalStates.Values() {
+		if reachable.Contains(item) {
+			prunedFinalStates.Add(item)
+		}
+	}
+	fsa.FinalStates = prunedFinalStates
+	fsa.lastId = fsa.computeLastId()
+
+	// If the current root has been removed then it's moved to the last state available
+	if !reachable.Contains(fsa.currentId) {
+		fsa.currentId = fsa.GetLastId()
+	}
 }
 
 // Sets the state identified by the given id as the new root of the FSA, this means that the next
 // transition added with the "Current" flag will start from this node, this is valid until a new
 // state is generated with the NewState flag which, in that case, will override the current root id
+//
+// Deprecated: the root is used only by the NewState and Current flags, see NewState
 func (fsa *FSA) SetRootId(newRootId int) {
+	fsa.assertMutable()
 	fsa.currentId = newRootId
 }
 
 // Allows functional iteration over each transition currently available in the FSA.
 // The callback of the user can change and interact with FSA but the changes made will
-// not be available in this method since it considers a "frozen" version of the adjency matrix
+// not be available in this method since it considers a "frozen" version of the adjency matrix:
+// the transitions added by the callback aren't visited, the ones removed are visited anyway.
+// The transitions are visited in a deterministic order: sorted by starting and then ending state,
+// the parallel transitions (with same start and ending state) are visited in insertion order
 func (fsa *FSA) ForEachTransition(callback func(from, to int, t Transition)) {
-	// Iterates over each state in the adjacency matrix
-	for from, outgointTransitions := range fsa.transitions {
-		// Iterates over each outgoing transitions for the abovesaid state
-		for to, parallelTransitions := range outgointTransitions {
-			// Iterates over each parallel transition (with same start and ending state)
-			for _, t := range parallelTransitions {
-				callback(from, to, t)
-			}
+	pinned, release := fsa.pin()
+	defer release()
+
+	// Iterates over each state in the adjacency list
+	for _, from := range sortedKeys(pinned.rows) {
+		// Iterates over each outgoing transitions (sorted by ending state) for the abovesaid state
+		for _, outgoing := range pinned.rows[from] {
+			callback(from, outgoing.to, outgoing.t)
 		}
 	}
 }
 
+// Iterates over the outgoing transitions of the given state, grouped by ending state
+// (in ascending order). Each group contains the parallel transitions in insertion order,
+// the changes made by the callback aren't visited as for ForEachTransition
+func (fsa *FSA) forEachParallelGroup(from int, callback func(to int, parallelT []Transition)) {
+	pinned, release := fsa.pin()
+	defer release()
+	row := pinned.rows[from]
+
+	for lo := 0; lo < len(row); {
+		_, hi := edgeRange(row, row[lo].to)
+
+		parallelT := make([]Transition, 0, hi-lo)
+		for _, outgoing := range row[lo:hi] {
+			parallelT = append(parallelT, outgoing.t)
+		}
+
+		callback(row[lo].to, parallelT)
+		lo = hi
+	}
+}
+
+// Pins the adjacency list of the FSA for the duration of a visit, the returned function releases it. The pinned
+// list counts as shared, so the first change made to the FSA in the meantime gives the latter a private copy (see
+// mutableRows) and the visit goes on over the rows as they were when it started. Since the copy is made only once
+// (the list of the FSA isn't pinned anymore) a visit costs a single copy at most, whatever the changes made.
+// The list of a frozen FSA is never modified, so it doesn't have to be counted
+func (fsa *FSA) pin() (*adjacency, func()) {
+	pinned := fsa.adjacency
+	if pinned.frozen {
+		return pinned, func() {}
+	}
+
+	pinned.refs++
+	return pinned, func() { pinned.refs-- }
+}
+
 // Allows functional iteration over each state currently available in the FSA.
 // The callback of the user can change and interact with FSA but the changes made will
-// not be available in this method since it considers a "frozen" version of the adjency matrix
+// not be available in this method since it considers a "frozen" version of the adjency matrix.
+// The states are visited in ascending order of id
 func (fsa *FSA) ForEachState(callback func(id int)) {
 	stateSet := set.New()
 
 	// Populates the state set (duplicate ids are avoided)
-	for from, outgoing := range fsa.transitions {
+	for from, outgoing := range fsa.adjacency.rows {
 		stateSet.Add(from)
-		for to := range outgoing {
-			stateSet.Add(to)
+		for _, current := range outgoing {
+			stateSet.Add(current.to)
 		}
 	}
 
-	// Iterate on the set with only unique values
-	for stateId := range stateSet.Values() {
+	// Sorts the set with only unique values then iterates on it
+	stateIds := make([]int, 0, stateSet.Size())
+	for _, stateId := range stateSet.Values() {
+		stateIds = append(stateIds, stateId.(int))
+	}
+	sort.Ints(stateIds)
+
+	for _, stateId := range stateIds {
 		callback(stateId)
 	}
 }
 
+// Returns the starting states of the given adjacency list in ascending order
+func sortedKeys(rows map[int][]edge) []int {
+	keys := make([]int, 0, len(rows))
+	for key := range rows {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
 // Exports the referenced FSA to a given path and in the given format/encoding.
 // Some supported encoding/format are: SVG, PNG, DOT, etc... The funcion doesn't
-// do any check about the given path and wil straight up fail if the path is invalid
-// or it will overwrite the current file saved at that location
-func (fsa *FSA) Export(outputFile string, format graphviz.Format) {
-	// Creates a GraphViz instance and initializes a Graph render object
-	gvInstance := graphviz.New()
-	graph, graphErr := gvInstance.Graph()
-
-	// Cleanup function that closes both the Graph and GraphViz instances
-	// in case of any error during execution or after the execution completed successfully
-	defer func() {
-		if err := graph.Close(); err != nil {
-			log.Fatal(err)
-		}
-		gvInstance.Close()
-	}()
+// do any check about the given path and returns an error if the path is invalid,
+// otherwise it will overwrite the current file saved at that location
+func (fsa *FSA) Export(outputFile string, format graphviz.Format) error {
+	return fsa.export(fileRender(outputFile, format), false, nil)
+}
 
-	if graphErr != nil {
-		log.Fatal(graphErr)
-	}
+// Exports the referenced FSA as Export does, but the output is written to the given writer instead of a file
+// (e.g a buffer) so that it can be streamed. The writer isn't closed, an error is returned if the rendering fails
+func (fsa *FSA) ExportTo(w io.Writer, format graphviz.Format) error {
+	return fsa.export(func(graph Exporter) error { return graph.RenderTo(w, format) }, false, nil)
+}
 
-	// A simple conversion map to keep track of the cross references
-	// (FSA => graphviz.Graph) between states and nodes
-	state2node := make(map[int]*cgraph.Node)
+// Exports the referenced FSA as Export does, along with the implicit sink (error) state: a dashed node
+// reached by the messages that aren't handled in each state (see UndefinedTransitions). It's meant for the
+// deterministic local views, where a message not handled in a state is an error of the participant
+func (fsa *FSA) ExportWithSink(outputFile string, format graphviz.Format) error {
+	return fsa.export(fileRender(outputFile, format), true, nil)
+}
 
-	// Bulk copy of states from the FSA to the graphviz Graph (as nodes)
-	fsa.ForEachState(func(stateId int) {
-		// Creates a cgraph.Node from the current stateId
-		node, nodeErr := graph.CreateNode(fmt.Sprint(stateId))
-		node.SetShape(cgraph.CircleShape) // Default shape
+// Exports the referenced FSA as Export does, each edge is given to the decorator before being added to
+// the graph so that its style (or tooltip) can be changed according to the transitions squashed in it
+func (fsa *FSA) ExportDecorated(outputFile string, format graphviz.Format, decorate EdgeDecorator) error {
+	return fsa.export(fileRender(outputFile, format), false, decorate)
+}
 
-		if nodeErr != nil {
-			log.Fatal(nodeErr)
-		}
+// Renders the referenced FSA with the given function, with the sink state when requested (see ExportWithSink).
+// The resources of the graph are released by the rendering itself, whatever its outcome
+func (fsa *FSA) export(render func(graph Exporter) error, withSink bool, decorate EdgeDecorator) error {
+	graph := NewGraphvizExporter()
+	fsa.draw(graph, withSink, decorate)
+	return render(graph)
+}
+
+// Returns the function that renders a graph to the given path in the given format
+func fileRender(outputFile string, format graphviz.Format) func(graph Exporter) error {
+	return func(graph Exporter) error { return graph.Render(outputFile, format) }
+}
+
+// Adds the states (as nodes) and the transitions (as edges) of the referenced FSA to the given graph, along with
+// the sink state when requested. The final states are drawn as double circles and the initial one is pointed by
+// an arrow coming from an unlabeled point node. The graph isn't rendered, so that the caller can inspect it
+func (fsa *FSA) Draw(graph Exporter, withSink bool) {
+	fsa.draw(graph, withSink, nil)
+}
+
+// Adds the referenced FSA to the given graph as Draw does (without the sink state), each edge is given to the
+// decorator before being added (see EdgeDecorator)
+func (fsa *FSA) DrawDecorated(graph Exporter, decorate EdgeDecorator) {
+	fsa.draw(graph, false, decorate)
+}
 
-		// If the current state is final state then changes the shape
+// Adds the referenced FSA to the given graph, the decorator (if not nil) is called on each edge but the start one
+func (fsa *FSA) draw(graph Exporter, withSink bool, decorate EdgeDecorator) {
+	// Bulk copy of states from the FSA to the graph (as nodes), the id of the node is the one of the state
+	fsa.ForEachState(func(stateId int) {
+		node := GraphNode{Id: fmt.Sprint(stateId), Shape: cgraph.CircleShape}
 		if fsa.FinalStates.Contains(stateId) {
-			node.SetShape(cgraph.DoubleCircleShape)
+			node.Shape = cgraph.DoubleCircleShape
 		}
-
-		// At last updates the association map with the new entries
-		state2node[stateId] = node
+		graph.AddNode(node)
 	})
 
-	// Bulk copy of transitions from the FSA to the graphviz Graph (as edges)
-	fsa.ForEachState(func(startId int) {
-		for destId, parallelT := range fsa.transitions[startId] {
-			// Retrieves the references to the graphviz.Graph nodes
-			fromRef, toRef := state2node[startId], state2node[destId]
-			// Creates a uid for the current edge from the tuple (from, to, t)
-			edgeId := fmt.Sprintf("%d-%d", startId, destId)
-			edgeLabel := ""
+	graph.AddNode(GraphNode{Id: "start", Shape: cgraph.PointShape})
+	graph.AddEdge(GraphEdge{Id: "start", From: "start", To: fmt.Sprint(fsa.initialId)})
 
+	// Bulk copy of transitions from the FSA to the graph (as edges)
+	fsa.ForEachState(func(startId int) {
+		fsa.forEachParallelGroup(startId, func(destId int, parallelT []Transition) {
 			// Since Graphviz doesn't support parallel edges we implement it ourselves
-			// by "squashing" all parallel transitions into one singe label "\n" separated
-			for _, t := range parallelT {
-				edgeLabel += fmt.Sprintf("\n%s", t)
-			}
-
-			// Creates the edge and sets its label
-			edge, edgeErr := graph.CreateEdge(edgeId, fromRef, toRef)
-			edge.SetLabel(edgeLabel)
-
-			if edgeErr != nil {
-				log.Fatal(edgeErr)
+			// by "squashing" all parallel transitions into one (see EdgeLabel)
+			edgeId := fmt.Sprintf("%d-%d", startId, destId)
+			edge := LabeledEdge(edgeId, fmt.Sprint(startId), fmt.Sprint(destId), parallelT)
+			if decorate != nil {
+				decorate(&edge, startId, destId, parallelT)
 			}
-		}
+			graph.AddEdge(edge)
+		})
 	})
-
-	// Creates an export in the format requested at the given path
-	exportErr := gvInstance.RenderFilename(graph, format, outputFile)
-
-	if exportErr != nil {
-		log.Fatal(exportErr)
+	if withSink {
+		fsa.drawSinkState(graph)
 	}
 }
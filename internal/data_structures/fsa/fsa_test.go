@@ -0,0 +1,364 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the initial state handling of the FSA data structure
+package fsa
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestInitialState(t *testing.T) {
+	automaton := New()
+	automaton.AddTransition(0, 1, Transition{Move: Send, Label: "a"})
+	automaton.AddTransition(1, 2, Transition{Move: Send, Label: "b"})
+	automaton.SetFinalState(2)
+
+	if automaton.InitialState() != 0 {
+		t.Fatalf("expected 0 as default initial state, got %d", automaton.InitialState())
+	}
+
+	// The initial state is preserved by Copy and Prune starts from it
+	automaton.SetInitialState(1)
+	pruned := automaton.Copy()
+	pruned.Prune()
+	if pruned.InitialState() != 1 || pruned.String() != "final 2\ninitial 1\n1 -> 2 Send \"b\"\n" {
+		t.Errorf("unexpected pruned automaton\n%s", pruned)
+	}
+
+	// The text form round trip keeps the initial state as well
+	decoded := New()
+	if err := decoded.UnmarshalText([]byte(pruned.String())); err != nil || decoded.InitialState() != 1 {
+		t.Errorf("expected the decoded initial state to be 1, got %d (%v)", decoded.InitialState(), err)
+	}
+
+	// A state without transitions can be the initial one as well
+	automaton.SetInitialState(5)
+	automaton.Prune()
+	if automaton.InitialState() != 5 || automaton.GetLastId() != 5 || automaton.FinalStates.Size() != 0 {
+		t.Errorf("expected only the isolated initial state to be kept, got\n%s", automaton)
+	}
+}
+
+func TestStateHandles(t *testing.T) {
+	automaton := New()
+	first := automaton.AddState()
+	second := automaton.AddTransition(first, automaton.AddState(), Transition{Move: Send, Label: "a"})
+	// The handle of an existing state is returned as is, even for a duplicated transition
+	if loop := automaton.AddTransition(second, first, Transition{Move: Recv, Label: "b"}); loop != first {
+		t.Errorf("expected the ending state %d, got %d", first, loop)
+	}
+	if again := automaton.AddTransition(first, second, Transition{Move: Send, Label: "a"}); again != second {
+		t.Errorf("expected the ending state %d, got %d", second, again)
+	}
+
+	automaton.SetFinalState(second)
+	if first != 1 || second != 2 || automaton.String() != "final 2\n1 -> 2 Send \"a\"\n2 -> 1 Recv \"b\"\n" {
+		t.Errorf("unexpected automaton (states %d and %d)\n%s", first, second, automaton)
+	}
+}
+
+func TestTransitionIdentity(t *testing.T) {
+	type info struct{ MayBeNil bool }
+
+	automaton := New()
+	automaton.AddTransition(0, 1, Transition{Move: Send, Label: "ch", Payload: info{false}})
+	automaton.AddTransition(0, 1, Transition{Move: Send, Label: "ch", Payload: info{true}})
+	automaton.AddTransition(0, 1, Transition{Move: Send, Label: "ch", Payload: info{true}})
+
+	// The transitions with the same label but a different payload are both kept, the duplicate isn't
+	payloads := []interface{}{}
+	automaton.ForEachTransition(func(_, _ int, t Transition) { payloads = append(payloads, t.Payload) })
+	if len(payloads) != 2 || payloads[0] != (info{false}) || payloads[1] != (info{true}) {
+		t.Fatalf("expected the two distinct payloads in insertion order, got %v", payloads)
+	}
+
+	// Only the identical transition is removed
+	automaton.RemoveTransition(0, 1, Transition{Move: Send, Label: "ch", Payload: info{false}})
+	payloads = payloads[:0]
+	automaton.ForEachTransition(func(_, _ int, t Transition) { payloads = append(payloads, t.Payload) })
+	if len(payloads) != 1 || payloads[0] != (info{true}) {
+		t.Errorf("expected only the other payload to be left, got %v", payloads)
+	}
+
+	// The payloads that can't be compared with == (e.g slices) are compared by their content
+	a := Transition{Move: Call, Label: "f", Payload: []string{"ch"}}
+	b := Transition{Move: Call, Label: "f", Payload: []string{"ch"}}
+	if !a.Identical(b) || a.Identical(Transition{Move: Call, Label: "f"}) {
+		t.Errorf("unexpected identity of %v and %v", a, b)
+	}
+}
+
+func TestFrozenConcurrentReads(t *testing.T) {
+	automaton := NewBuilder().State("start").Send("a").To("middle").Recv("b").To("end").Final("end").Build()
+	automaton.Freeze()
+	expected := automaton.String()
+
+	// Each Goroutine reads the frozen automaton and modifies its own copy (run with -race to check the accesses)
+	var wg sync.WaitGroup
+	copies := make([]*FSA, 8)
+	for i := range copies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			automaton.ForEachTransition(func(_, _ int, _ Transition) {})
+			if path := automaton.ShortestPath(automaton.InitialState(), 2); len(path) != 2 {
+				t.Errorf("expected the final state to be reachable in 2 steps, got %v", path)
+			}
+
+			copies[i] = automaton.Copy()
+			copies[i].AddTransition(2, 3, Transition{Move: Send, Label: "c"})
+			copies[i].SetFinalState(3)
+		}(i)
+	}
+	wg.Wait()
+
+	if !automaton.IsFrozen() || automaton.String() != expected {
+		t.Errorf("expected the frozen automaton to be untouched, got\n%s", automaton)
+	}
+	for _, copied := range copies {
+		if copied.IsFrozen() || !copied.IsFinalState(3) || copied.GetLastId() != 3 {
+			t.Errorf("expected each copy to be modified on its own, got\n%s", copied)
+		}
+	}
+}
+
+func TestDedupPolicy(t *testing.T) {
+	count := func(automaton *FSA) int {
+		n := 0
+		automaton.ForEachTransition(func(_, _ int, _ Transition) { n++ })
+		return n
+	}
+	tEps := Transition{Move: Eps, Label: "if-block-end"}
+
+	automaton := New()
+	automaton.AddTransition(0, 1, tEps)
+	automaton.AddTransition(0, 1, tEps)
+	if automaton.DedupPolicy() != DedupIdentical || count(automaton) != 1 {
+		t.Fatalf("expected the identical transitions to be added once by default, got %d", count(automaton))
+	}
+
+	// Without deduplication the parallel transitions are all kept, the copies keep the policy
+	automaton.SetDedupPolicy(DedupOff)
+	automaton.AddTransition(0, 1, tEps)
+	copied := automaton.Copy()
+	copied.AddTransition(0, 1, tEps)
+	if count(automaton) != 2 || count(copied) != 3 || copied.DedupPolicy() != DedupOff {
+		t.Fatalf("expected every transition to be kept, got %d and %d", count(automaton), count(copied))
+	}
+
+	// The identical ones are removed all at once
+	copied.RemoveTransition(0, 1, tEps)
+	if count(copied) != 0 || count(automaton) != 2 {
+		t.Errorf("expected only the copy to be emptied, got %d and %d", count(copied), count(automaton))
+	}
+}
+
+func TestRelabel(t *testing.T) {
+	automaton := New()
+	automaton.AddTransition(0, 1, Transition{Move: Send, Label: "a"})
+	automaton.AddTransition(0, 1, Transition{Move: Send, Label: "b"})
+	automaton.AddTransition(1, 2, Transition{Move: Recv, Label: "c"})
+	automaton.SetFinalState(2)
+
+	// The renamed transition keeps its position among the parallel ones, the original isn't touched
+	relabeled := automaton.Relabel(func(old Transition) Transition {
+		if old.Label == "a" {
+			return Transition{Move: old.Move, Label: "z"}
+		}
+		return old
+	})
+	labels := []string{}
+	relabeled.ForEachTransition(func(_, _ int, t Transition) { labels = append(labels, t.Label) })
+	if strings.Join(labels, " ") != "z b c" || !relabeled.IsFinalState(2) {
+		t.Errorf("expected the transitions 'z b c' with 2 final, got '%s'\n%s", strings.Join(labels, " "), relabeled)
+	}
+	if automaton.String() == relabeled.String() {
+		t.Errorf("expected the original FSA to be untouched")
+	}
+}
+
+func TestRenumber(t *testing.T) {
+	automaton := New()
+	automaton.AddTransition(0, 3, Transition{Move: Send, Label: "a"})
+	automaton.AddTransition(3, 7, Transition{Move: Recv, Label: "b"})
+	automaton.SetFinalState(7)
+	automaton.Prune()
+
+	// The ids are non contiguous, the offset is applied to each of them anyway
+	renumbered := automaton.Renumber(10)
+	edges := []string{}
+	renumbered.ForEachTransition(func(from, to int, t Transition) { edges = append(edges, fmt.Sprintf("%d-%s-%d", from, t.Label, to)) })
+	if strings.Join(edges, " ") != "10-a-13 13-b-17" {
+		t.Errorf("expected the transitions '10-a-13 13-b-17', got '%s'", strings.Join(edges, " "))
+	}
+	if renumbered.InitialState() != 10 || !renumbered.IsFinalState(17) || renumbered.GetLastId() != 17 {
+		t.Errorf("expected 10 as initial and 17 as final and last state\n%s", renumbered)
+	}
+}
+
+func TestRemoveTransitionKeepsIds(t *testing.T) {
+	tEps := Transition{Move: Eps, Label: "call"}
+	automaton := New()
+	automaton.AddTransition(0, 1, tEps)
+	automaton.SetFinalState(1)
+
+	// The final state is still referenced once its only transition is removed, so its id isn't given again
+	automaton.RemoveTransition(0, 1, tEps)
+	if id := automaton.AddState(); id != 2 {
+		t.Errorf("expected the new state to be 2, got %d", id)
+	}
+
+	// Once pruned the unreachable states are gone and the ids can be used again
+	automaton.Prune()
+	if automaton.GetLastId() != 0 || automaton.IsFinalState(1) {
+		t.Errorf("expected only the initial state to be left\n%s", automaton)
+	}
+}
+
+func TestMutationDuringIteration(t *testing.T) {
+	automaton := New()
+	for i := 0; i < 4; i++ {
+		automaton.AddTransition(i, i+1, Transition{Move: Send, Label: fmt.Sprint("ch", i)})
+		automaton.AddTransition(i, i+1, Transition{Move: Recv, Label: fmt.Sprint("ch", i)})
+	}
+
+	// Every transition is replaced (as done by the inlining) and a new one is added after the visited one: only
+	// the transitions available when the iteration started are visited, the removed ones included
+	visited := []string{}
+	automaton.ForEachTransition(func(from, to int, tr Transition) {
+		visited = append(visited, fmt.Sprintf("%d %s %d", from, tr, to))
+		automaton.RemoveTransition(from, to, tr)
+		automaton.AddTransition(from, to, Transition{Move: tr.Move, Label: tr.Label + "'"})
+		automaton.AddTransition(to, automaton.AddState(), Transition{Move: Eps, Label: "added"})
+		automaton.RemoveTransition(from+1, from+2, Transition{Move: Recv, Label: fmt.Sprint("ch", from+1)})
+	})
+	if len(visited) != 8 || visited[1] != "0 ← ch0 1" || visited[7] != "3 ← ch3 4" {
+		t.Errorf("expected the 8 original transitions to be visited in order, got %v", visited)
+	}
+	if text := automaton.String(); strings.Contains(text, "\"ch0\"") || !strings.Contains(text, "\"ch3'\"") {
+		t.Errorf("expected every original transition to be replaced\n%s", text)
+	}
+
+	// The copy made before the iteration and the one made during it aren't affected by the changes of the other ones
+	automaton = New()
+	automaton.AddTransition(0, 1, Transition{Move: Send, Label: "ch"})
+	before, during := automaton.Copy(), (*FSA)(nil)
+	automaton.ForEachTransition(func(from, to int, tr Transition) {
+		during = automaton.Copy()
+		automaton.RemoveTransition(from, to, tr)
+		during.AddTransition(from, to, Transition{Move: Eps, Label: "copy"})
+	})
+	if !strings.Contains(before.String(), "Send") || strings.Contains(before.String(), "copy") {
+		t.Errorf("expected the copy made before the iteration to be untouched\n%s", before)
+	}
+	if strings.Contains(automaton.String(), "Send") || !strings.Contains(during.String(), "Send") || !strings.Contains(during.String(), "copy") {
+		t.Errorf("expected the copies to be independent, got\n%s\n%s", automaton, during)
+	}
+}
+
+func TestNestedIterations(t *testing.T) {
+	automaton := New()
+	automaton.AddTransition(0, 1, Transition{Move: Send, Label: "a"})
+	automaton.AddTransition(1, 2, Transition{Move: Send, Label: "b"})
+
+	// The inner iterations start after the changes made by the outer one, that still visits the original transitions
+	outer, inner := 0, []int{}
+	automaton.ForEachTransition(func(from, to int, tr Transition) {
+		outer++
+		automaton.AddTransition(to, automaton.AddState(), Transition{Move: Eps, Label: fmt.Sprint("added", outer)})
+		n := 0
+		automaton.ForEachTransition(func(_, _ int, _ Transition) { n++ })
+		inner = append(inner, n)
+	})
+	if outer != 2 || fmt.Sprint(inner) != "[3 4]" {
+		t.Errorf("expected 2 outer visits and [3 4] inner ones, got %d and %v", outer, inner)
+	}
+	if automaton.adjacency.refs != 1 {
+		t.Errorf("expected the adjacency list to be released by every iteration, got %d refs", automaton.adjacency.refs)
+	}
+}
+
+func TestUnion(t *testing.T) {
+	setup := New()
+	setup.AddTransition(0, 1, Transition{Move: Send, Label: "config"})
+	setup.SetFinalState(1)
+	flow := New()
+	flow.AddTransition(0, 1, Transition{Move: Recv, Label: "request"})
+	flow.AddTransition(1, 2, Transition{Move: Send, Label: "response"})
+	flow.SetFinalState(2)
+
+	// The operands follow the fresh initial state one after the other, each one reached by its own eps transition
+	union := Union(setup, flow)
+	edges := []string{}
+	union.ForEachTransition(func(from, to int, tr Transition) { edges = append(edges, fmt.Sprintf("%d-%s-%d", from, tr.Label, to)) })
+	expected := "[0-union-first-1 0-union-second-3 1-config-2 3-request-4 4-response-5]"
+	if fmt.Sprint(edges) != expected || union.InitialState() != 0 || union.GetLastId() != 5 {
+		t.Errorf("expected the transitions %s, got %v\n%s", expected, edges, union)
+	}
+	if !union.IsFinalState(2) || !union.IsFinalState(5) || union.FinalStates.Size() != 2 {
+		t.Errorf("expected the final states of both the operands, got %v", union.FinalStates.Values())
+	}
+
+	// The operands aren't modified
+	if setup.GetLastId() != 1 || !flow.IsFinalState(2) || flow.InitialState() != 0 {
+		t.Errorf("expected the operands to be untouched\n%s\n%s", setup, flow)
+	}
+}
+
+func TestConcatAndStar(t *testing.T) {
+	ping, pong := Transition{Move: Send, Label: "ping"}, Transition{Move: Recv, Label: "pong"}
+	// The first operand has two final states, both of them have to be linked to the second operand
+	first := New()
+	first.AddTransition(0, 1, ping)
+	first.AddTransition(0, 2, pong)
+	first.SetFinalState(1)
+	first.SetFinalState(2)
+	second := New()
+	second.AddTransition(0, 1, pong)
+	second.SetFinalState(1)
+
+	concat := Concat(first, second)
+	for word, accepted := range map[string]bool{"ping pong": true, "pong pong": true, "ping": false, "pong": false, "": false} {
+		if concat.Accepts(parseWord(word, ping, pong)) != accepted {
+			t.Errorf("expected the concatenation to accept '%s': %t\n%s", word, accepted, concat)
+		}
+	}
+	if concat.FinalStates.Size() != 1 || !concat.IsFinalState(4) || concat.InitialState() != 0 {
+		t.Errorf("expected only the copy of the final state of the second operand to be final\n%s", concat)
+	}
+
+	// The initial state of the operand is reached again by its own transitions ("ping pong"), that mustn't be accepted
+	loop := New()
+	loop.AddTransition(0, 1, ping)
+	loop.AddTransition(1, 0, pong)
+	loop.AddTransition(1, 2, ping)
+	loop.SetFinalState(2)
+
+	star := Star(loop)
+	for word, accepted := range map[string]bool{"": true, "ping ping": true, "ping pong ping ping ping ping": true, "ping pong": false, "ping": false} {
+		if star.Accepts(parseWord(word, ping, pong)) != accepted {
+			t.Errorf("expected the star to accept '%s': %t\n%s", word, accepted, star)
+		}
+	}
+	if star.FinalStates.Size() != 1 || !star.IsFinalState(0) || loop.InitialState() != 0 || loop.IsFinalState(0) {
+		t.Errorf("expected only the fresh initial state to be final and the operand untouched\n%s", star)
+	}
+}
+
+// Returns the word made of the given transitions, named by their label in the given space separated text
+func parseWord(text string, alphabet ...Transition) []Transition {
+	word := []Transition{}
+	for _, label := range strings.Fields(text) {
+		for _, symbol := range alphabet {
+			if symbol.Label == label {
+				word = append(word, symbol)
+			}
+		}
+	}
+	return word
+}
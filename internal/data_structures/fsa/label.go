@@ -0,0 +1,88 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// This package implements a Finite State Automata (FSA) data structure and its own API.
+// For this specific use cases the implementation is quite simple & basic
+
+// The only method available from the outside are the ones that render the edge labels in the exports
+package fsa
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The number of rows a single transition can be wrapped on, the text left after the last one is truncated
+const maxWrappedRows = 2
+
+// The longest row (in characters) of the edge labels in the exported graphs, 0 means no limit
+var maxLabelLength = 0
+
+// The characters after which a long row is preferably wrapped (e.g "func-anonymousFunc-main-return")
+const wrapAfter = " -/:,"
+
+// ----------------------------------------------------------------------------
+// Edge labels
+
+// Sets the longest row (in characters) of the edge labels in the exported graphs (see EdgeLabel),
+// a value of 0 (the default) or a negative one disables the limit
+func SetMaxLabelLength(length int) {
+	maxLabelLength = length
+}
+
+// Returns the label of an edge that squashes the given parallel transitions, since Graphviz doesn't
+// support parallel edges each transition is on its own row. When a limit is set (see SetMaxLabelLength)
+// the long rows are wrapped and then truncated, in this case the tooltip returned is the full text of the
+// label (one transition per row) while it's empty when the label is shown as it is
+func EdgeLabel(parallelT []Transition) (string, string) {
+	var label, tooltip strings.Builder
+	isShortened := false
+
+	for i, t := range parallelT {
+		text := t.String()
+		if i > 0 {
+			tooltip.WriteString("\n")
+		}
+		tooltip.WriteString(text)
+
+		rows := wrapRow(text, maxLabelLength)
+		isShortened = isShortened || len(rows) > 1 || rows[0] != text
+		for _, row := range rows {
+			fmt.Fprintf(&label, "\n%s", row)
+		}
+	}
+
+	if !isShortened {
+		return label.String(), ""
+	}
+	return label.String(), tooltip.String()
+}
+
+// Splits the given text in rows of at most the given length, preferably after one of the
+// wrapAfter characters. After maxWrappedRows rows the remaining text is replaced by an ellipsis
+func wrapRow(text string, length int) []string {
+	runes := []rune(text)
+	if length <= 0 || len(runes) <= length {
+		return []string{text}
+	}
+
+	rows := []string{}
+	for len(runes) > length && len(rows) < maxWrappedRows-1 {
+		cut := length
+		for i := length - 1; i > length/2; i-- {
+			if strings.ContainsRune(wrapAfter, runes[i]) {
+				cut = i + 1
+				break
+			}
+		}
+		rows = append(rows, string(runes[:cut]))
+		runes = runes[cut:]
+	}
+
+	if len(runes) > length {
+		// The ellipsis takes the place of the last character kept, so the row is still within the length
+		runes = append(runes[:length-1], '…')
+	}
+	return append(rows, string(runes))
+}
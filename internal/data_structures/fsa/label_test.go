@@ -0,0 +1,30 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the wrapping and truncation of the edge labels
+package fsa
+
+import "testing"
+
+func TestEdgeLabel(t *testing.T) {
+	defer SetMaxLabelLength(0)
+	parallelT := []Transition{
+		{Move: Eps, Label: "func-anonymousFunc-main-return-deferred"},
+		{Move: Send, Label: "ch"},
+	}
+
+	// Without a limit the label is the one of the previous exports
+	if label, tooltip := EdgeLabel(parallelT); label != "\nϵ func-anonymousFunc-main-return-deferred\n→ ch" || tooltip != "" {
+		t.Errorf("expected the label to be left untouched, got %q (tooltip %q)", label, tooltip)
+	}
+
+	SetMaxLabelLength(16)
+	label, tooltip := EdgeLabel(parallelT)
+	if expected := "\nϵ func-anonymous\nFunc-main-retur…\n→ ch"; label != expected {
+		t.Errorf("expected the label %q, got %q", expected, label)
+	}
+	if expected := "ϵ func-anonymousFunc-main-return-deferred\n→ ch"; tooltip != expected {
+		t.Errorf("expected the tooltip %q, got %q", expected, tooltip)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// This package implements a Finite State Automata (FSA) data structure and its own API.
+// For this specific use cases the implementation is quite simple & basic
+
+// The only method available from the outside are the regular operators of FSA (union, concatenation and star)
+package fsa
+
+// ----------------------------------------------------------------------------------------
+// FSA regular operators
+
+// Returns a new FSA that accepts the sequences of transitions accepted by either of the given ones (e.g the flows of
+// two alternative entrypoints): a fresh initial state (0) branches with an eps transition to a copy of each operand,
+// the states of the first one come right after it and the ones of the second one after the latter (see Renumber).
+// The final states of both the operands are final in the union as well, the operands themselves aren't modified.
+// The alphabets of the operands are meant to be disjoint, otherwise the branch taken can't be told from the
+// transitions; in any case the union is non deterministic (because of the eps transitions, see SubsetConstruction).
+// The union has the same dedup policy of the first operand
+func Union(a, b *FSA) *FSA {
+	first := a.Renumber(1)
+	second := b.Renumber(first.GetLastId() + 1)
+
+	union := New()
+	union.dedup = a.dedup
+	union.AddTransition(0, first.initialId, UnionFirst.Transition())
+	union.AddTransition(0, second.initialId, UnionSecond.Transition())
+
+	union.include(first, true)
+	union.include(second, true)
+	return union
+}
+
+// Returns a new FSA that accepts the sequences of transitions accepted by the first one followed by the ones
+// accepted by the second one (e.g a setup and the main flow): the initial state is the one of a copy of the first
+// operand and each final state of the latter is linked with an eps transition to the initial state of a copy of the
+// second one, whose states come right after (see Renumber). Only the final states of the second operand are final
+// in the concatenation, so the latter accepts nothing if the first operand has no final state. The operands aren't
+// modified and the concatenation has the same dedup policy of the first one
+func Concat(a, b *FSA) *FSA {
+	first := a.Renumber(0)
+	second := b.Renumber(first.GetLastId() + 1)
+
+	concat := New()
+	concat.dedup = a.dedup
+	concat.SetInitialState(first.initialId)
+	concat.include(first, false)
+	for _, item := range first.FinalStates.Values() {
+		concat.AddTransition(item.(int), second.initialId, ConcatLink.Transition())
+	}
+
+	concat.include(second, true)
+	return concat
+}
+
+// Returns a new FSA that accepts any number of repetitions (none included) of the sequences of transitions accepted
+// by the given one (e.g the body of a loop): a fresh initial state (0), that is the only final one, branches with an
+// eps transition to a copy of the operand (whose states come right after it, see Renumber) and each final state of
+// the latter goes back with an eps transition to the fresh one. The fresh state keeps the repetitions apart from the
+// transitions that reach the initial state of the operand (if any), that would be accepted otherwise. The operand
+// isn't modified and the star has the same dedup policy of the latter
+func Star(a *FSA) *FSA {
+	body := a.Renumber(1)
+
+	star := New()
+	star.dedup = a.dedup
+	star.AddTransition(0, body.initialId, StarEnter.Transition())
+	star.include(body, false)
+	for _, item := range body.FinalStates.Values() {
+		star.AddTransition(item.(int), 0, StarLoop.Transition())
+	}
+
+	star.SetFinalState(0)
+	return star
+}
+
+// Adds the transitions of the given FSA (already renumbered, so that its ids don't overlap the ones used so far)
+// to the referenced one along with its final states, if requested. The states of the operand without transitions
+// (e.g its final ones after a RemoveTransition) count as well for the biggest id of the referenced FSA
+func (fsa *FSA) include(operand *FSA, withFinalStates bool) {
+	operand.ForEachTransition(func(from, to int, t Transition) {
+		fsa.AddTransition(from, to, t)
+	})
+	if withFinalStates {
+		for _, item := range operand.FinalStates.Values() {
+			fsa.SetFinalState(item.(int))
+		}
+	}
+
+	if lastId := operand.GetLastId(); lastId > fsa.lastId {
+		fsa.lastId = lastId
+	}
+	fsa.currentId = fsa.lastId
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// This package implements a Finite State Automata (FSA) data structure and its own API.
+// For this specific use cases the implementation is quite simple & basic
+
+// The only method available from the outside are the reachability queries of FSA
+package fsa
+
+// ----------------------------------------------------------------------------
+// FSA reachability queries
+
+// A single step of a path, it records the transition used to reach a state (and from where)
+type step struct {
+	from int
+	t    Transition
+}
+
+// Visits the FSA in breadth-first order starting from the given state until the target state is found,
+// returns for each visited state the step that reached it first. Outgoing transitions are visited by
+// ending state (and insertion order) so that the paths found are always the same for a given FSA
+func (fsa *FSA) breadthFirstVisit(from, to int) map[int]step {
+	reachedBy := map[int]step{from: {from: Unknown}}
+	queue := []int{from}
+
+	for len(queue) > 0 && from != to {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, outgoing := range fsa.adjacency.rows[current] {
+			if _, visited := reachedBy[outgoing.to]; visited {
+				continue
+			}
+
+			reachedBy[outgoing.to] = step{current, outgoing.t}
+			if outgoing.to == to {
+				return reachedBy
+			}
+			queue = append(queue, outgoing.to)
+		}
+	}
+
+	return reachedBy
+}
+
+// Returns true if the state "to" can be reached from the state "from" with zero or more transitions
+func (fsa *FSA) Reachable(from, to int) bool {
+	_, isReached := fsa.breadthFirstVisit(from, to)[to]
+	return isReached
+}
+
+// Returns the transitions of a shortest path (the one with the least number of transitions) that goes
+// from the state "from" to the state "to", an empty path is returned if the two states are the same.
+// If the state "to" is not reachable from "from" then nil is returned
+func (fsa *FSA) ShortestPath(from, to int) []Transition {
+	reachedBy := fsa.breadthFirstVisit(from, to)
+	if _, isReached := reachedBy[to]; !isReached {
+		return nil
+	}
+
+	// Walks the path backwards, from the target state to the starting one
+	path := []Transition{}
+	for current := to; current != from; current = reachedBy[current].from {
+		path = append(path, reachedBy[current].t)
+	}
+
+	// Then reverses it, in order to have the transitions in the execution order
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	return path
+}
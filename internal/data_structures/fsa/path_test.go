@@ -0,0 +1,33 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the reachability queries of the FSA data structure
+package fsa
+
+import "testing"
+
+func TestShortestPath(t *testing.T) {
+	automaton := New()
+	automaton.AddTransition(0, 1, Transition{Move: Send, Label: "a"})
+	automaton.AddTransition(1, 2, Transition{Move: Send, Label: "b"})
+	automaton.AddTransition(2, 3, Transition{Move: Send, Label: "c"})
+	automaton.AddTransition(0, 2, Transition{Move: Recv, Label: "shortcut"})
+	automaton.AddTransition(4, 0, Transition{Move: Recv, Label: "unreachable"})
+
+	path := automaton.ShortestPath(0, 3)
+	if len(path) != 2 || path[0].Label != "shortcut" || path[1].Label != "c" {
+		t.Errorf("unexpected shortest path from 0 to 3: %v", path)
+	}
+
+	if path := automaton.ShortestPath(2, 2); path == nil || len(path) != 0 {
+		t.Errorf("expected an empty path from a state to itself, got %v", path)
+	}
+
+	if automaton.Reachable(0, 4) || automaton.ShortestPath(0, 4) != nil {
+		t.Error("state 4 shouldn't be reachable from 0")
+	}
+	if !automaton.Reachable(4, 3) {
+		t.Error("state 3 should be reachable from 4")
+	}
+}
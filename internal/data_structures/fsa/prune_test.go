@@ -0,0 +1,75 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the removal of the unreachable states of the FSA data structure
+package fsa
+
+import (
+	"sort"
+	"testing"
+)
+
+// Returns the (sorted) ids of the states of the given automaton
+func stateIds(automaton *FSA) []int {
+	ids := []int{}
+	automaton.ForEachState(func(id int) { ids = append(ids, id) })
+	sort.Ints(ids)
+	return ids
+}
+
+func TestPrune(t *testing.T) {
+	automaton := New()
+	automaton.AddTransition(0, 1, Transition{Move: Send, Label: "a"})
+	automaton.AddTransition(1, 2, Transition{Move: Recv, Label: "b"})
+	automaton.AddTransition(3, 4, Transition{Move: Send, Label: "c"}) // Unreachable from the initial state
+	automaton.AddTransition(4, 2, Transition{Move: Recv, Label: "d"})
+	automaton.SetFinalState(2)
+	automaton.SetFinalState(4)
+
+	// The reachable states keep their own ids, the unreachable ones are dropped with their final states
+	automaton.Prune()
+	if ids := stateIds(automaton); len(ids) != 3 || ids[0] != 0 || ids[1] != 1 || ids[2] != 2 {
+		t.Errorf("expected the states [0 1 2], got %v", ids)
+	}
+	if automaton.FinalStates.Size() != 1 || !automaton.IsFinalState(2) {
+		t.Errorf("expected only the final state 2 to be kept, got %v", automaton.FinalStates.Values())
+	}
+	if automaton.GetLastId() != 2 {
+		t.Errorf("expected 2 as last id, got %d", automaton.GetLastId())
+	}
+}
+
+func TestPruneNonContiguousIds(t *testing.T) {
+	automaton := New()
+	automaton.AddTransition(0, 5, Transition{Move: Send, Label: "a"})
+	automaton.AddTransition(0, 1, Transition{Move: Send, Label: "b"})
+	automaton.AddTransition(1, 2, Transition{Move: Send, Label: "c"})
+
+	// The states left only by the removed transitions are dropped, the ids aren't compacted
+	automaton.RemoveTransition(0, 1, Transition{Move: Send, Label: "b"})
+	automaton.Prune()
+	if ids := stateIds(automaton); len(ids) != 2 || ids[0] != 0 || ids[1] != 5 {
+		t.Errorf("expected the states [0 5], got %v", ids)
+	}
+	if automaton.GetLastId() != 5 {
+		t.Errorf("expected 5 as last id, got %d", automaton.GetLastId())
+	}
+
+	// The next state generated follows the biggest id, so it doesn't collide with the kept ones
+	automaton.AddTransition(Current, NewState, Transition{Move: Recv, Label: "d"})
+	if automaton.GetLastId() != 6 {
+		t.Errorf("expected the new state to be 6, got %d", automaton.GetLastId())
+	}
+}
+
+func TestPruneInitialState(t *testing.T) {
+	// The initial state is kept even when it has no outgoing transitions left
+	automaton := New()
+	automaton.AddTransition(0, 1, Transition{Move: Send, Label: "a"})
+	automaton.RemoveTransition(0, 1, Transition{Move: Send, Label: "a"})
+	automaton.Prune()
+	if ids := stateIds(automaton); len(ids) != 1 || ids[0] != 0 {
+		t.Errorf("expected only the initial state to be kept, got %v", ids)
+	}
+}
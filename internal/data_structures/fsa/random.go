@@ -0,0 +1,143 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// This package implements a Finite State Automata (FSA) data structure and its own API.
+// For this specific use cases the implementation is quite simple & basic
+
+// The only method available from the outside are the random generation and sampling of FSA
+package fsa
+
+import (
+	"math/rand"
+)
+
+// ----------------------------------------------------------------------------
+// Random FSA generation
+
+// The options of the random FSA generated by Random, the densities are probabilities (between 0 and 1)
+type RandomOptions struct {
+	States          int          // The number of states of the FSA (at least 1)
+	Transitions     int          // The number of transitions added besides the ones that make every state reachable
+	Alphabet        []Transition // The (non eps) transitions to choose from, a few sends and receives if empty
+	EpsDensity      float64      // The probability of a transition to be an eps one
+	ParallelDensity float64      // The probability of a transition to be parallel to an already existing one
+	FinalDensity    float64      // The probability of a state to be final, the FSA has always at least one
+}
+
+// The alphabet used by Random when none is given
+var defaultAlphabet = []Transition{
+	{Move: Send, Label: "a"}, {Move: Recv, Label: "a"},
+	{Move: Send, Label: "b"}, {Move: Recv, Label: "b"},
+	{Move: Send, Label: "c"},
+}
+
+// Returns a random FSA with the given options, generated with the given source of randomness so that the same
+// seed always yields the same FSA. The FSA is well-formed: its states are numbered from 0 (the initial one) and
+// each one is reachable from the initial one, since the first transition entering a state comes from a lower one.
+// The duplicated transitions are discarded (see AddTransition), so the FSA may have fewer than requested
+func Random(rng *rand.Rand, opts RandomOptions) *FSA {
+	if opts.States < 1 {
+		opts.States = 1
+	}
+	alphabet := opts.Alphabet
+	if len(alphabet) == 0 {
+		alphabet = defaultAlphabet
+	}
+	randomTransition := func() Transition {
+		if rng.Float64() < opts.EpsDensity {
+			return Transition{Move: Eps, Label: "eps"}
+		}
+		return alphabet[rng.Intn(len(alphabet))]
+	}
+
+	type edgeEnds struct{ from, to int }
+	automaton, edges := New(), []edgeEnds{}
+	for id := 1; id < opts.States; id++ {
+		from := rng.Intn(id)
+		automaton.AddTransition(from, id, randomTransition())
+		edges = append(edges, edgeEnds{from, id})
+	}
+
+	for i := 0; i < opts.Transitions; i++ {
+		ends := edgeEnds{rng.Intn(opts.States), rng.Intn(opts.States)}
+		if len(edges) > 0 && rng.Float64() < opts.ParallelDensity {
+			ends = edges[rng.Intn(len(edges))]
+		}
+		automaton.AddTransition(ends.from, ends.to, randomTransition())
+		edges = append(edges, ends)
+	}
+
+	for id := 0; id < opts.States; id++ {
+		if rng.Float64() < opts.FinalDensity {
+			automaton.SetFinalState(id)
+		}
+	}
+	if automaton.FinalStates.Size() == 0 {
+		automaton.SetFinalState(rng.Intn(opts.States))
+	}
+	return automaton
+}
+
+// Returns the (non eps) transitions of a random walk of at most the given length from the initial state, that
+// is a word the FSA can read. The walk stops early in a state without outgoing transitions or, at random, in a
+// final state, so that the accepted words are sampled as well as the ones that aren't (the prefixes)
+func (fsa *FSA) RandomWord(rng *rand.Rand, maxLength int) []Transition {
+	word, current := []Transition{}, fsa.initialId
+	for steps := 0; steps < maxLength; steps++ {
+		outgoing := fsa.adjacency.rows[current]
+		if len(outgoing) == 0 || (fsa.IsFinalState(current) && rng.Intn(4) == 0) {
+			break
+		}
+
+		next := outgoing[rng.Intn(len(outgoing))]
+		if next.t.Move != Eps {
+			word = append(word, next.t)
+		}
+		current = next.to
+	}
+	return word
+}
+
+// Returns true if the FSA accepts the given word, that is a final state can be reached from the initial one
+// reading its transitions in order (see Transition.Identical) and any number of eps transitions in between
+func (fsa *FSA) Accepts(word []Transition) bool {
+	current := fsa.epsClosure(map[int]bool{fsa.initialId: true})
+	for _, symbol := range word {
+		next := make(map[int]bool)
+		for id := range current {
+			for _, outgoing := range fsa.adjacency.rows[id] {
+				if outgoing.t.Move != Eps && outgoing.t.Identical(symbol) {
+					next[outgoing.to] = true
+				}
+			}
+		}
+		current = fsa.epsClosure(next)
+	}
+
+	for id := range current {
+		if fsa.IsFinalState(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the given states along with the ones reachable from them with eps transitions only
+func (fsa *FSA) epsClosure(states map[int]bool) map[int]bool {
+	queue := []int{}
+	for id := range states {
+		queue = append(queue, id)
+	}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, outgoing := range fsa.adjacency.rows[current] {
+			if outgoing.t.Move == Eps && !states[outgoing.to] {
+				states[outgoing.to] = true
+				queue = append(queue, outgoing.to)
+			}
+		}
+	}
+	return states
+}
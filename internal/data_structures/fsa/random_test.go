@@ -0,0 +1,45 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the random generation and the sampling of FSA
+package fsa
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestRandom(t *testing.T) {
+	opts := RandomOptions{States: 8, Transitions: 10, EpsDensity: 0.3, FinalDensity: 0.2}
+	automaton := Random(rand.New(rand.NewSource(42)), opts)
+
+	// Every state is reachable from the initial one and the same seed gives the same FSA
+	for id := 0; id < opts.States; id++ {
+		if !automaton.Reachable(0, id) {
+			t.Errorf("expected the state %d to be reachable\n%s", id, automaton)
+		}
+	}
+	if again := Random(rand.New(rand.NewSource(42)), opts); again.String() != automaton.String() {
+		t.Errorf("expected the same FSA for the same seed\n%s\n%s", automaton, again)
+	}
+	if automaton.FinalStates.Size() == 0 {
+		t.Errorf("expected at least a final state")
+	}
+}
+
+func TestAccepts(t *testing.T) {
+	automaton := New()
+	automaton.AddTransition(0, 1, Transition{Move: Send, Label: "a"})
+	automaton.AddTransition(1, 2, Transition{Move: Eps, Label: "eps"})
+	automaton.AddTransition(2, 1, Transition{Move: Recv, Label: "b"})
+	automaton.SetFinalState(2)
+
+	send, recv := Transition{Move: Send, Label: "a"}, Transition{Move: Recv, Label: "b"}
+	if !automaton.Accepts([]Transition{send}) || !automaton.Accepts([]Transition{send, recv, recv}) {
+		t.Errorf("expected the words through the eps transition to be accepted")
+	}
+	if automaton.Accepts([]Transition{}) || automaton.Accepts([]Transition{recv}) {
+		t.Errorf("expected the words that don't reach the final state to be rejected")
+	}
+}
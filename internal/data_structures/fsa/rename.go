@@ -0,0 +1,79 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// This package implements a Finite State Automata (FSA) data structure and its own API.
+// For this specific use cases the implementation is quite simple & basic
+
+// The only method available from the outside are the renaming utilities of FSA
+package fsa
+
+import (
+	"log"
+
+	list "github.com/emirpasic/gods/lists/singlylinkedlist"
+)
+
+// ----------------------------------------------------------------------------------------
+// FSA renaming utilities
+
+// Returns a copy of the FSA in which every transition is replaced by the one returned by the given mapping, the
+// states (ids included) are the same. Differently from a RemoveTransition followed by an AddTransition the mapped
+// transitions keep their position among the parallel ones, so the visits of the copy are the same of the original.
+// The mapped transitions are added with the dedup policy of the FSA (e.g two transitions mapped to the same one
+// are merged, unless the policy is DedupOff) and they can't have an empty label
+func (fsa *FSA) Relabel(mapping func(old Transition) Transition) *FSA {
+	relabeled := fsa.emptyCopy(0)
+
+	for _, from := range sortedKeys(fsa.adjacency.rows) {
+		for _, outgoing := range fsa.adjacency.rows[from] {
+			relabeled.AddTransition(from, outgoing.to, mapping(outgoing.t))
+		}
+	}
+
+	return relabeled
+}
+
+// Returns a copy of the FSA in which the id of every state (initial and final ones included) is increased by the
+// given offset, the transitions are the same. It's meant to make room for a FSA inside another one (e.g with the
+// first id after the biggest one of the latter as offset, see GetLastId), since the ids could be non contiguous
+func (fsa *FSA) Renumber(offset int) *FSA {
+	fsa.ForEachState(func(id int) {
+		if id+offset < 0 {
+			log.Fatalf("the offset %d would make the id of the state %d negative", offset, id)
+		}
+	})
+	renumbered := fsa.emptyCopy(offset)
+
+	for _, from := range sortedKeys(fsa.adjacency.rows) {
+		// The offset is the same for every state, so the rows are still sorted by ending state
+		row := make([]edge, 0, len(fsa.adjacency.rows[from]))
+		for _, outgoing := range fsa.adjacency.rows[from] {
+			row = append(row, edge{outgoing.to + offset, outgoing.t})
+		}
+		renumbered.adjacency.rows[from+offset] = row
+	}
+
+	return renumbered
+}
+
+// Returns a FSA with the same settings and final states of the given one (with the given offset applied to the ids)
+// and a row for each starting state, so that the states without outgoing transitions are kept as well
+func (fsa *FSA) emptyCopy(offset int) *FSA {
+	empty := FSA{
+		currentId:   fsa.currentId + offset,
+		lastId:      fsa.lastId + offset,
+		initialId:   fsa.initialId + offset,
+		dedup:       fsa.dedup,
+		adjacency:   &adjacency{rows: make(map[int][]edge, len(fsa.adjacency.rows)), refs: 1},
+		FinalStates: list.New(),
+	}
+	for from := range fsa.adjacency.rows {
+		empty.adjacency.rows[from+offset] = nil
+	}
+	for _, item := range fsa.FinalStates.Values() {
+		empty.FinalStates.Add(item.(int) + offset)
+	}
+
+	return &empty
+}
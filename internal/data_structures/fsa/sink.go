@@ -0,0 +1,80 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// This package implements a Finite State Automata (FSA) data structure and its own API.
+// For this specific use cases the implementation is quite simple & basic
+
+// The only method available from the outside are the ones about the implicit sink state of the exports
+package fsa
+
+import (
+	"fmt"
+
+	"github.com/goccy/go-graphviz/cgraph"
+)
+
+// The id of the node that represents the sink state in the exported graphs
+const sinkNodeId = "sink"
+
+// ----------------------------------------------------------------------------
+// Sink state
+
+// Returns the messages of the automaton that aren't handled in the given state, that is the ones for which the
+// transition function is undefined and that lead to the implicit sink (error) state. The messages are the Send,
+// Recv and Empty transitions (e.g the annotated operations of a local view), while the internal ones (Eps, Call
+// and Spawn) aren't part of the alphabet. The latter is made of the distinct Move and Label of the messages
+// (the payload is ignored) and the undefined ones are returned in order of appearance
+func (fsa *FSA) UndefinedTransitions(id int) []Transition {
+	alphabet, handled := []Transition{}, []Transition{}
+	fsa.ForEachTransition(func(from, _ int, t Transition) {
+		if t.Move != Send && t.Move != Recv && t.Move != Empty {
+			return
+		}
+		if !containsMatching(alphabet, t) {
+			alphabet = append(alphabet, Transition{Move: t.Move, Label: t.Label, Symbol: t.Symbol})
+		}
+		if from == id {
+			handled = append(handled, t)
+		}
+	})
+
+	undefined := []Transition{}
+	for _, t := range alphabet {
+		if !containsMatching(handled, t) {
+			undefined = append(undefined, t)
+		}
+	}
+	return undefined
+}
+
+// Returns true if the given transitions contain one with the same Move and Label of t (see Transition.Matches)
+func containsMatching(transitions []Transition, t Transition) bool {
+	for _, current := range transitions {
+		if current.Matches(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Adds to the given graph the sink state along with a dashed edge from each state (node) to it, labeled with the
+// messages undefined in that state. The sink node is added only if at least one message is undefined somewhere
+func (fsa *FSA) drawSinkState(graph Exporter) {
+	hasSink := false
+	fsa.ForEachState(func(stateId int) {
+		undefined := fsa.UndefinedTransitions(stateId)
+		if len(undefined) == 0 {
+			return
+		}
+
+		if !hasSink {
+			graph.AddNode(GraphNode{Id: sinkNodeId, Label: "⊥", Shape: cgraph.CircleShape, Style: cgraph.DashedNodeStyle})
+			hasSink = true
+		}
+
+		edge := LabeledEdge(fmt.Sprintf("%d-%s", stateId, sinkNodeId), fmt.Sprint(stateId), sinkNodeId, undefined)
+		edge.Style = cgraph.DashedEdgeStyle
+		graph.AddEdge(edge)
+	})
+}
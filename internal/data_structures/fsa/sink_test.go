@@ -0,0 +1,41 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the implicit sink state of the exports
+package fsa
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-graphviz"
+)
+
+func TestUndefinedTransitions(t *testing.T) {
+	automaton := New()
+	automaton.AddTransition(0, 1, Transition{Move: Recv, Label: "ping"})
+	automaton.AddTransition(1, 2, Transition{Move: Eps, Label: "loop-end"})
+	automaton.AddTransition(1, 0, Transition{Move: Send, Label: "pong"})
+	automaton.AddTransition(0, 3, Transition{Move: Spawn, Label: "worker"})
+	automaton.SetFinalState(2)
+
+	// The internal transitions (Eps and Spawn) aren't messages, so they are never undefined
+	if undefined := automaton.UndefinedTransitions(0); len(undefined) != 1 || undefined[0].String() != "→ pong" {
+		t.Errorf("expected only the send on 'pong' to be undefined in 0, got %v", undefined)
+	}
+	if undefined := automaton.UndefinedTransitions(2); len(undefined) != 2 || undefined[0].String() != "← ping" {
+		t.Errorf("expected both the messages to be undefined in the final state, got %v", undefined)
+	}
+
+	path := filepath.Join(t.TempDir(), "view.dot")
+	if err := automaton.ExportWithSink(path, graphviz.XDOT); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(content), "0 -> sink") || !strings.Contains(string(content), "dashed") {
+		t.Errorf("expected the dashed edges to the sink state in the export (%v)\n%s", err, content)
+	}
+}
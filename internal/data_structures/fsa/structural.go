@@ -0,0 +1,157 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// This package implements a Finite State Automata (FSA) data structure and its own API.
+// For this specific use cases the implementation is quite simple & basic
+
+// The only method available from the outside are the registry of the eps labels and the Structural predicate
+package fsa
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// The labels of the structural eps transitions, the ones that only model the control flow
+	IfBlockStart        EpsLabel = "if-block-start"
+	IfBlockEnd          EpsLabel = "if-block-end"
+	IfBlockSkip         EpsLabel = "if-block-skip"
+	ElseBlockStart      EpsLabel = "else-block-start"
+	ElseBlockEnd        EpsLabel = "else-block-end"
+	SwitchSkip          EpsLabel = "switch-skip"
+	ForIterationStart   EpsLabel = "for-iteration-start"
+	ForIterationEnd     EpsLabel = "for-iteration-end"
+	ForIterationSkip    EpsLabel = "for-iteration-skip"
+	ForUnrolledExit     EpsLabel = "for-unrolled-exit"
+	RangeIterationStart EpsLabel = "range-iteration-start"
+	RangeIterationEnd   EpsLabel = "range-iteration-end"
+	RangeIterationSkip  EpsLabel = "range-iteration-skip"
+	RangeYieldStart     EpsLabel = "range-yield-start"
+	RangeYieldEnd       EpsLabel = "range-yield-end"
+	RangeYieldReturn    EpsLabel = "range-yield-return"
+	EventLoopSkip       EpsLabel = "event-loop-skip"
+	ConditionRhsStart   EpsLabel = "condition-rhs-start"
+	ConditionRhsEnd     EpsLabel = "condition-rhs-end"
+	ConditionRhsSkip    EpsLabel = "condition-rhs-skip"
+	StartCallExpansion  EpsLabel = "start-call-expansion" // From the call site to the initial state of the inlined callee
+	EndCallExpansion    EpsLabel = "end-call-expansion"   // From a final state of the inlined callee back to the caller
+	UnionFirst          EpsLabel = "union-first"          // From the initial state of a union to the one of its first operand
+	UnionSecond         EpsLabel = "union-second"         // From the initial state of a union to the one of its second operand
+	ConcatLink          EpsLabel = "concat"               // From a final state of the first operand of a concatenation to the second one
+	StarEnter           EpsLabel = "star-enter"           // From the initial state of a star to the one of its operand
+	StarLoop            EpsLabel = "star-loop"            // From a final state of the operand back to the initial state of a star
+
+	// The templates of the structural labels with a parameter, to be filled with EpsLabel.With
+	SwitchCaseNoMatch     EpsLabel = "switch-case-%d-no-match"    // The index of the clause
+	SwitchCaseStart       EpsLabel = "switch-case-%d-start"       // The index of the clause
+	SwitchCaseEnd         EpsLabel = "switch-case-%d-end"         // The index of the clause
+	SwitchCaseFallthrough EpsLabel = "switch-case-%d-fallthrough" // The index of the clause
+	BranchCaseStart       EpsLabel = "%s-case-%d-start"           // The kind of statement (e.g "select") and the index of the clause
+	BranchCaseEnd         EpsLabel = "%s-case-%d-end"             // The kind of statement (e.g "select") and the index of the clause
+	EventLoopCaseStart    EpsLabel = "event-loop-case-%d-start"   // The index of the clause
+	EventLoopCaseEnd      EpsLabel = "event-loop-case-%d-end"     // The index of the clause
+	EventLoopCaseExit     EpsLabel = "event-loop-case-%d-exit"    // The index of the clause
+	ForUnrolledIteration  EpsLabel = "for-unrolled-iteration-%d"  // The index of the iteration
+	FuncReturn            EpsLabel = "func-%s-return"             // The name of the function
+
+	// The labels of the eps transitions that replace an action that isn't modeled, they aren't structural
+	UnknownFunctionCall  EpsLabel = "unknown-function-call"  // A call to a function not declared in the file
+	UnknownFunctionSpawn EpsLabel = "unknown-function-spawn" // A spawn of a function not declared in the file
+	UnsupportedSpawn     EpsLabel = "unsupported-spawn"      // A spawn of an expression that isn't a function
+	NetworkHandlerCall   EpsLabel = "network-handler-call"   // The invocation of a handler by the network
+	ExternalFunctionCall EpsLabel = "external-function-call" // A call to a function declared without a body
+
+	PositionSeparator  = "@" // Between a structural label and the position of the statement it comes from
+	ExpansionSeparator = "/" // Between the expansion of a call and the label of its eps transitions
+)
+
+// Type alias to abstract the labels (and the templates of the labels) of the eps transitions
+type EpsLabel string
+
+// ----------------------------------------------------------------------------------------
+// Structural labels registry
+
+// The structural labels and templates, the only ones for which Structural returns true
+var structuralLabels = []EpsLabel{
+	IfBlockStart, IfBlockEnd, IfBlockSkip, ElseBlockStart, ElseBlockEnd, SwitchSkip,
+	ForIterationStart, ForIterationEnd, ForIterationSkip, ForUnrolledExit,
+	RangeIterationStart, RangeIterationEnd, RangeIterationSkip, RangeYieldStart, RangeYieldEnd, RangeYieldReturn,
+	EventLoopSkip, ConditionRhsStart, ConditionRhsEnd, ConditionRhsSkip, StartCallExpansion, EndCallExpansion,
+	UnionFirst, UnionSecond, ConcatLink, StarEnter, StarLoop,
+	SwitchCaseNoMatch, SwitchCaseStart, SwitchCaseEnd, SwitchCaseFallthrough, BranchCaseStart, BranchCaseEnd,
+	EventLoopCaseStart, EventLoopCaseEnd, EventLoopCaseExit, ForUnrolledIteration, FuncReturn,
+}
+
+// The expression that matches any of the structural labels, each verb of the templates matches any parameter.
+// The expressions of the single labels are compiled once as well, they're only read afterwards (see Matches)
+var (
+	structuralPattern = compileLabels(structuralLabels)
+	labelPatterns     = make(map[EpsLabel]*regexp.Regexp, len(structuralLabels))
+)
+
+func init() {
+	for _, label := range structuralLabels {
+		labelPatterns[label] = compileLabels([]EpsLabel{label})
+	}
+}
+
+// Returns the expression that matches exactly any of the given labels (or templates)
+func compileLabels(labels []EpsLabel) *regexp.Regexp {
+	alternatives := make([]string, 0, len(labels))
+	for _, label := range labels {
+		quoted := regexp.QuoteMeta(string(label))
+		quoted = strings.ReplaceAll(quoted, "%d", `\d+`)
+		alternatives = append(alternatives, strings.ReplaceAll(quoted, "%s", `.+`))
+	}
+	return regexp.MustCompile("^(" + strings.Join(alternatives, "|") + ")$")
+}
+
+// Returns the label obtained filling the template with the given parameters (e.g SwitchCaseStart.With(0))
+func (label EpsLabel) With(args ...interface{}) EpsLabel {
+	return EpsLabel(fmt.Sprintf(string(label), args...))
+}
+
+// Returns an eps transition with the label
+func (label EpsLabel) Transition() Transition {
+	return Transition{Move: Eps, Label: string(label)}
+}
+
+// Returns true if the label is one of the structural ones (or it has been obtained from one of their templates)
+func (label EpsLabel) Structural() bool {
+	return structuralPattern.MatchString(string(label))
+}
+
+// Returns true if the label is one of the given ones or it has been obtained from one of the given templates
+// (e.g "event-loop-case-2-start" matches EventLoopCaseStart)
+func (label EpsLabel) Matches(templates ...EpsLabel) bool {
+	for _, template := range templates {
+		pattern, isRegistered := labelPatterns[template]
+		if !isRegistered {
+			pattern = compileLabels([]EpsLabel{template})
+		}
+		if pattern.MatchString(string(label)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the given label of an eps transition without the expansions of the calls it comes from and without the
+// position of its statement (e.g "if-block-start" for "worker#2/if-block-start@main.go:12:2")
+func BaseLabel(label string) EpsLabel {
+	label = label[strings.LastIndex(label, ExpansionSeparator)+1:]
+	if index := strings.Index(label, PositionSeparator); index >= 0 {
+		label = label[:index]
+	}
+	return EpsLabel(label)
+}
+
+// Returns true if the transition is a structural eps transition (e.g the start of an if block), one that only
+// models the control flow of the source. The eps transitions that replace an action that isn't modeled (e.g
+// UnknownFunctionCall) aren't structural, as well as any transition with a different Move
+func (t Transition) Structural() bool {
+	return t.Move == Eps && BaseLabel(t.Label).Structural()
+}
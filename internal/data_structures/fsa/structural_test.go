@@ -0,0 +1,42 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the registry of the structural eps labels
+package fsa
+
+import "testing"
+
+func TestStructural(t *testing.T) {
+	structural := []Transition{
+		IfBlockStart.Transition(),
+		{Move: Eps, Label: "if-block-start@main.go:12:2"},
+		{Move: Eps, Label: "worker#2/notify#1/start-call-expansion"},
+		SwitchCaseStart.With(3).Transition(),
+		BranchCaseEnd.With("select", 0).Transition(),
+		FuncReturn.With("main-func1").Transition(),
+	}
+	for _, tr := range structural {
+		if !tr.Structural() {
+			t.Errorf("expected '%s' to be structural", tr.Label)
+		}
+	}
+
+	// The placeholders of the actions that aren't modeled, the messages and the unknown labels aren't structural
+	others := []Transition{
+		UnknownFunctionCall.Transition(),
+		{Move: Eps, Label: "main#1/unsupported-spawn@main.go:3:1"},
+		{Move: Send, Label: string(IfBlockStart)},
+		{Move: Eps, Label: "switch-case-x-start"},
+		{Move: Eps, Label: "if-block-start-again"},
+	}
+	for _, tr := range others {
+		if tr.Structural() {
+			t.Errorf("expected '%s' not to be structural", tr.Label)
+		}
+	}
+
+	if label := BaseLabel("worker#2/event-loop-case-1-end@main.go:8:3"); !label.Matches(EventLoopCaseStart, EventLoopCaseEnd) || label.Matches(EventLoopCaseExit) {
+		t.Errorf("expected '%s' to match only the end of an event loop case", label)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// This package implements a Finite State Automata (FSA) data structure and its own API.
+// For this specific use cases the implementation is quite simple & basic
+
+// The only struct available from the outside is SymbolTable and its own API
+package fsa
+
+import "sync"
+
+// The Symbol used for an empty (or not yet interned) name
+const NoSymbol Symbol = 0
+
+// A small integer id that identifies a name (channel, participant, ...) in a SymbolTable
+type Symbol int
+
+// ----------------------------------------------------------------------------
+// SymbolTable
+
+// A SymbolTable maps the names used in the transition labels (channels, participants, ...)
+// to small integer ids, so that the transforms can compare two labels with a single integer
+// comparison and each distinct name is allocated only once. The table is safe for concurrent use
+type SymbolTable struct {
+	mutex sync.RWMutex      // Protects the table from concurrent access
+	ids   map[string]Symbol // Association from name to Symbol
+	names []string          // Association from Symbol to name (the Symbol is the index)
+}
+
+// The SymbolTable shared by every FSA, the Transition.Symbol of each transition added to
+// a FSA is resolved against this table
+var Symbols = NewSymbolTable()
+
+// Generates a new SymbolTable, the NoSymbol id is always associated to the empty name
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{ids: map[string]Symbol{"": NoSymbol}, names: []string{""}}
+}
+
+// Returns the Symbol associated to the given name, if the latter isn't in the table yet
+// then a new Symbol is generated and associated to it
+func (st *SymbolTable) Intern(name string) Symbol {
+	st.mutex.RLock()
+	symbol, exist := st.ids[name]
+	st.mutex.RUnlock()
+
+	if exist {
+		return symbol
+	}
+
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	// Another Goroutine could have added the name in the meantime
+	if symbol, exist := st.ids[name]; exist {
+		return symbol
+	}
+
+	symbol = Symbol(len(st.names))
+	st.ids[name] = symbol
+	st.names = append(st.names, name)
+	return symbol
+}
+
+// Returns the Symbol associated to the given name without adding it to the table,
+// if the name isn't in the table then NoSymbol and false are returned
+func (st *SymbolTable) Lookup(name string) (Symbol, bool) {
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+
+	symbol, exist := st.ids[name]
+	return symbol, exist
+}
+
+// Returns the name associated to the given Symbol, if the Symbol isn't valid
+// for the current table then an empty string is returned
+func (st *SymbolTable) Name(symbol Symbol) string {
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+
+	if symbol < 0 || int(symbol) >= len(st.names) {
+		return ""
+	}
+	return st.names[symbol]
+}
@@ -0,0 +1,92 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the SymbolTable and the comparison of the transitions by Symbol
+package fsa
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestSymbolTable(t *testing.T) {
+	table := NewSymbolTable()
+
+	// The empty name is always associated to NoSymbol
+	if symbol, exist := table.Lookup(""); !exist || symbol != NoSymbol {
+		t.Errorf("expected the empty name to be NoSymbol, got %d (%t)", symbol, exist)
+	}
+
+	// The same name is always associated to the same Symbol and the Symbol resolves back to it
+	first, second := table.Intern("ch"), table.Intern("done")
+	if first == NoSymbol || first == second || table.Intern("ch") != first {
+		t.Errorf("expected distinct and stable symbols, got %d and %d", first, second)
+	}
+	if table.Name(first) != "ch" || table.Name(second) != "done" {
+		t.Errorf("expected the symbols to resolve to their names, got %q and %q", table.Name(first), table.Name(second))
+	}
+
+	// Lookup doesn't add the missing names and Name rejects the unknown symbols
+	if _, exist := table.Lookup("missing"); exist {
+		t.Errorf("expected Lookup not to intern the missing names")
+	}
+	if table.Name(Symbol(100)) != "" || table.Name(Symbol(-1)) != "" {
+		t.Errorf("expected the unknown symbols to resolve to the empty name")
+	}
+}
+
+func TestSymbolTableConcurrent(t *testing.T) {
+	table := NewSymbolTable()
+	symbols := make([]Symbol, 16)
+
+	// Many Goroutines interning the same names must agree on the associated symbols
+	var wg sync.WaitGroup
+	for i := range symbols {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				table.Intern(fmt.Sprintf("name-%d", j))
+			}
+			symbols[i] = table.Intern("name-42")
+		}(i)
+	}
+	wg.Wait()
+
+	for _, symbol := range symbols {
+		if symbol != symbols[0] || table.Name(symbol) != "name-42" {
+			t.Fatalf("expected every Goroutine to get the same symbol, got %v", symbols)
+		}
+	}
+}
+
+func TestTransitionMatches(t *testing.T) {
+	automaton := New()
+	automaton.AddTransition(0, 1, Transition{Move: Send, Label: "ch", Payload: 1})
+
+	// The transition added to the FSA has the Symbol resolved against the shared table
+	var added Transition
+	automaton.ForEachTransition(func(from, to int, tr Transition) { added = tr })
+	if added.Symbol == NoSymbol || Symbols.Name(added.Symbol) != "ch" {
+		t.Fatalf("expected the Symbol to be resolved when added to the FSA, got %d", added.Symbol)
+	}
+
+	// A transition without Symbol matches the one in the FSA, the Payload is ignored
+	if !added.Matches(Transition{Move: Send, Label: "ch", Payload: 2}) {
+		t.Errorf("expected the transitions with same Move and Label to match")
+	}
+	if added.Matches(Transition{Move: Recv, Label: "ch"}) || added.Matches(Transition{Move: Send, Label: "other"}) {
+		t.Errorf("expected the transitions with different Move or Label not to match")
+	}
+
+	// So the duplicates aren't added and the removal works with or without the Symbol
+	automaton.AddTransition(0, 1, Transition{Move: Send, Label: "ch", Payload: 1})
+	automaton.RemoveTransition(0, 1, Transition{Move: Send, Label: "ch", Payload: 1})
+	count := 0
+	automaton.ForEachTransition(func(from, to int, tr Transition) { count++ })
+	if count != 0 {
+		t.Errorf("expected the transition to be removed, got %d transitions", count)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// This package implements a Finite State Automata (FSA) data structure and its own API.
+// For this specific use cases the implementation is quite simple & basic
+
+// The only method available from the outside are the (un)marshaling ones of FSA
+package fsa
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ----------------------------------------------------------------------------
+// FSA text serialization
+
+// In order to satisfy the encoding.TextMarshaler interface the FSA is serialized in a canonical
+// text form: a "final" line with the (sorted) ids of the final states, an "initial" line with the id of
+// the initial state (only if it isn't 0), then one line for each
+// transition in the form `<from> -> <to> <Move> "<Label>"`. The transitions are sorted by
+// starting state, ending state, Move and Label so that equal FSAs always have the same text form.
+// The Payload of the transitions is not serialized
+func (fsa *FSA) MarshalText() ([]byte, error) {
+	var buffer bytes.Buffer
+
+	// Collects and sorts the ids of the final states
+	finalStates := []string{}
+	fsa.ForEachState(func(id int) {
+		if fsa.IsFinalState(id) {
+			finalStates = append(finalStates, strconv.Itoa(id))
+		}
+	})
+	fmt.Fprintf(&buffer, "final %s\n", strings.Join(finalStates, " "))
+	// The initial state is serialized only when it's not the default one
+	if fsa.initialId != 0 {
+		fmt.Fprintf(&buffer, "initial %d\n", fsa.initialId)
+	}
+
+	fsa.ForEachState(func(from int) {
+		fsa.forEachParallelGroup(from, func(to int, parallelT []Transition) {
+			// Sorts the parallel transitions by Move and Label
+			sort.SliceStable(parallelT, func(i, j int) bool {
+				if parallelT[i].Move != parallelT[j].Move {
+					return parallelT[i].Move < parallelT[j].Move
+				}
+				return parallelT[i].Label < parallelT[j].Label
+			})
+
+			for _, t := range parallelT {
+				fmt.Fprintf(&buffer, "%d -> %d %s %s\n", from, to, t.Move, strconv.Quote(t.Label))
+			}
+		})
+	})
+
+	return buffer.Bytes(), nil
+}
+
+// In order to satisfy the encoding.TextUnmarshaler interface the FSA can be deserialized from
+// the text form generated by MarshalText, the content of the FSA is overwritten. Empty
+// lines and lines starting with "#" are ignored, any other malformed line returns an error
+func (fsa *FSA) UnmarshalText(text []byte) error {
+	decoded := New()
+	initialId := 0
+	scanner := bufio.NewScanner(bytes.NewReader(text))
+
+	for nLine := 1; scanner.Scan(); nLine++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// The final states line, a whitespace separated list of ids
+		if fields := strings.Fields(line); fields[0] == "final" {
+			for _, field := range fields[1:] {
+				id, err := strconv.Atoi(field)
+				if err != nil {
+					return fmt.Errorf("line %d: invalid final state '%s'", nLine, field)
+				}
+				decoded.SetFinalState(id)
+			}
+			continue
+		}
+
+		// The initial state line (optional), a single id
+		if fields := strings.Fields(line); fields[0] == "initial" {
+			if len(fields) != 2 {
+				return fmt.Errorf("line %d: expected a single initial state", nLine)
+			}
+			id, err := strconv.Atoi(fields[1])
+			if err != nil || id < 0 {
+				return fmt.Errorf("line %d: invalid initial state '%s'", nLine, fields[1])
+			}
+			initialId = id
+			continue
+		}
+
+		// Else the line is a transition, the label is the (quoted) remainder of the line
+		var from, to int
+		var move string
+		if _, err := fmt.Sscanf(line, "%d -> %d %s", &from, &to, &move); err != nil {
+			return fmt.Errorf("line %d: malformed transition '%s'", nLine, line)
+		}
+
+		moveKind, err := ParseMoveKind(move)
+		if err != nil {
+			return fmt.Errorf("line %d: %s", nLine, err)
+		}
+
+		quotedLabel := strings.TrimSpace(line[strings.Index(line, move)+len(move):])
+		label, err := strconv.Unquote(quotedLabel)
+		if err != nil || label == "" {
+			return fmt.Errorf("line %d: invalid label %s", nLine, quotedLabel)
+		}
+
+		decoded.AddTransition(from, to, Transition{Move: moveKind, Label: label})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	decoded.SetInitialState(initialId)
+	decoded.SetRootId(decoded.GetLastId())
+	*fsa = *decoded
+	return nil
+}
+
+// Converts the FSA to its canonical text form (see MarshalText)
+func (fsa *FSA) String() string {
+	text, _ := fsa.MarshalText()
+	return string(text)
+}
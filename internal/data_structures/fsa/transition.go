@@ -8,20 +8,78 @@
 // The only struct available from the outside is Transition and its own API adn related enum
 package fsa
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
 
 const (
 	// Transition type enum
-	Call  MoveKind = "Call"
-	Empty MoveKind = "Empty"
-	Eps   MoveKind = "Epsilon"
-	Recv  MoveKind = "Recv"
-	Send  MoveKind = "Send"
-	Spawn MoveKind = "Spawn"
+	Call MoveKind = iota
+	Empty
+	Eps
+	Recv
+	Send
+	Spawn
 )
 
 // Type alias to abstact the MoveKind enum
-type MoveKind string
+type MoveKind int
+
+// The canonical names and symbols of each MoveKind, indexed by the MoveKind value itself
+var (
+	moveNames   = [...]string{Call: "Call", Empty: "Empty", Eps: "Epsilon", Recv: "Recv", Send: "Send", Spawn: "Spawn"}
+	moveSymbols = [...]string{Call: "⨏", Empty: "", Eps: "ϵ", Recv: "←", Send: "→", Spawn: "△"}
+)
+
+// Converts the MoveKind to its canonical name (e.g "Send", "Epsilon", ...)
+func (m MoveKind) String() string {
+	if m < 0 || int(m) >= len(moveNames) {
+		return fmt.Sprintf("MoveKind(%d)", int(m))
+	}
+	return moveNames[m]
+}
+
+// Returns the symbol used to represent the MoveKind in the exported automata
+func (m MoveKind) Symbol() string {
+	if m < 0 || int(m) >= len(moveSymbols) {
+		return "⁈"
+	}
+	return moveSymbols[m]
+}
+
+// Converts the given canonical name (case insensitive) to the respective MoveKind,
+// an error is returned if the name doesn't match any of the available MoveKind
+func ParseMoveKind(name string) (MoveKind, error) {
+	for move, moveName := range moveNames {
+		if strings.EqualFold(name, moveName) {
+			return MoveKind(move), nil
+		}
+	}
+	return Empty, fmt.Errorf("unknown MoveKind '%s'", name)
+}
+
+// In order to satisfy the json.Marshaler interface the MoveKind is encoded with its canonical name
+func (m MoveKind) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// In order to satisfy the json.Unmarshaler interface the MoveKind is decoded from its canonical name
+func (m *MoveKind) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+
+	move, err := ParseMoveKind(name)
+	if err != nil {
+		return err
+	}
+
+	*m = move
+	return nil
+}
 
 // ----------------------------------------------------------------------------
 // Transition
@@ -33,25 +91,45 @@ type MoveKind string
 type Transition struct {
 	Move    MoveKind    // The MoveType of Transition (Call, Eps, Recv, Send, Spawn)
 	Label   string      // An explicative label of the action that is being executed
+	Symbol  Symbol      // The Label id in the Symbols table (overwritten when the transition is added to a FSA)
 	Payload interface{} // A generic payload container for further info memorization
 }
 
+// Returns the Symbol associated to the Label of the transition, if the transition hasn't
+// been added to a FSA yet (Symbol not set) then it's resolved against the Symbols table
+func (t Transition) LabelSymbol() Symbol {
+	if t.Symbol != NoSymbol || t.Label == "" {
+		return t.Symbol
+	}
+	return Symbols.Intern(t.Label)
+}
+
+// Returns true if the two transitions have the same Move and Label, the Payload is ignored.
+// When both the transitions come from a FSA the labels are compared by Symbol
+func (t Transition) Matches(other Transition) bool {
+	return t.Move == other.Move && t.LabelSymbol() == other.LabelSymbol()
+}
+
+// Returns true if the two transitions have the same identity: they match (see Matches) and carry the same
+// payload (see PayloadKey). It's the identity with which the FSA avoids duplicated transitions
+func (t Transition) Identical(other Transition) bool {
+	return t.Matches(other) && PayloadKey(t.Payload) == PayloadKey(other.Payload)
+}
+
+// Returns the canonical key of the given payload, two payloads with the same key carry the same data (e.g
+// two ChanMetadata with equal fields or two lists with the same arguments). The key is the Go representation
+// of the value, type included, so the pointers are the only payloads compared by identity (their address)
+func PayloadKey(payload interface{}) string {
+	if payload == nil {
+		return ""
+	}
+	return fmt.Sprintf("%#v", payload)
+}
+
 // Converts the Transition struct to a general pourpose string format.
 func (t Transition) String() string {
-	switch t.Move {
-	case Eps:
-		return fmt.Sprintf("ϵ %s", t.Label)
-	case Recv:
-		return fmt.Sprintf("← %s", t.Label)
-	case Send:
-		return fmt.Sprintf("→ %s", t.Label)
-	case Call:
-		return fmt.Sprintf("⨏ %s", t.Label)
-	case Spawn:
-		return fmt.Sprintf("△ %s", t.Label)
-	case Empty:
+	if t.Move == Empty {
 		return t.Label
-	default:
-		return fmt.Sprintf("⁈ %s", t.Label)
 	}
+	return fmt.Sprintf("%s %s", t.Move.Symbol(), t.Label)
 }
@@ -0,0 +1,315 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package diagnostics implements the checks that can be performed on the extracted Choreography
+// Automata (e.g deadlock detection). Each check returns a list of Diagnostic that describes
+// the issue found and where (in which state of the automaton) it has been found.
+//
+package diagnostics
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+const (
+	// AssertionKind enum
+	Never      AssertionKind = "never"      // The event never happens (before the Until one if given)
+	Eventually AssertionKind = "eventually" // The event happens in every execution
+	Temporal   AssertionKind = "temporal"   // The CTL Formula holds in the initial state
+)
+
+// Type alias to abstract the AssertionKind enum, the temporal operator of an Assertion
+type AssertionKind string
+
+// ----------------------------------------------------------------------------
+// Assertions
+
+// An Event is a message exchange of the Choreography Automata: a participant that sends (or receives)
+// on a channel. Both the participant and the channel are patterns (see transforms.MatchParticipant),
+// so that "worker" matches any Goroutine spawned from the worker function
+type Event struct {
+	Participant string       // The pattern of the participant that makes the operation
+	Move        fsa.MoveKind // Either Send or Recv
+	Channel     string       // The pattern of the channel of the operation
+}
+
+// Returns true if the given transition of the Choreography Automata is an interaction that matches the Event,
+// that is the participant sends (Send) or receives (Recv) a message on the channel. On a buffered channel the
+// send is matched when the message is put in the buffer and the receive when it's delivered (see LocalMoves)
+func (e Event) Matches(t fsa.Transition) bool {
+	interaction, isInteraction := t.Payload.(transforms.Interaction)
+	if !isInteraction || interaction.IsSpawn() {
+		return false
+	}
+	if isMatch, _ := path.Match(e.Channel, interaction.Channel.Name); !isMatch {
+		return false
+	}
+
+	for _, move := range interaction.LocalMoves() {
+		if isMatch, _ := transforms.MatchParticipant(e.Participant, move.Participant); isMatch && move.Move == e.Move {
+			return true
+		}
+	}
+	return false
+}
+
+// Converts the Event to the form accepted by ParseAssertions (e.g "worker sends on ch")
+func (e Event) String() string {
+	verb := "sends"
+	if e.Move == fsa.Recv {
+		verb = "receives"
+	}
+	return fmt.Sprintf("%s %s on %s", e.Participant, verb, e.Channel)
+}
+
+// An Assertion is a temporal property that every execution of the Choreography Automata has to satisfy. The
+// Never ones state that the Event doesn't happen at all or, when Until is given, that it doesn't happen before
+// the Until event (e.g "never worker sends on ch before worker receives on init"). The Eventually ones state
+// that the Event happens in every execution (e.g "eventually main receives on done"). The executions are the
+// paths from the initial state that either reach a final state (all the participants may have terminated) or
+// can't go on (a deadlock), the loops are assumed to be left sooner or later (if they can be)
+type Assertion struct {
+	Kind    AssertionKind // The temporal operator of the property
+	Event   Event         // The event the property is about
+	Until   *Event        // The event after which the Event is allowed (only for Never, nil if not given)
+	Formula *Formula      // The CTL formula (only for Temporal)
+}
+
+// Converts the Assertion to the form accepted by ParseAssertions
+func (a Assertion) String() string {
+	if a.Kind == Temporal {
+		return a.Formula.String()
+	}
+	if a.Until != nil {
+		return fmt.Sprintf("%s %s before %s", a.Kind, a.Event, a.Until)
+	}
+	return fmt.Sprintf("%s %s", a.Kind, a.Event)
+}
+
+// Parses the given assertions, one per line: the empty ones and the ones starting with '#' are ignored.
+// Each line is either "never <event> [before <event>]" or "eventually <event>" where the event has the
+// form "<participant> sends|receives [on|from] <channel>", any other line is a CTL formula (see Formula).
+// An error with the line is returned if invalid
+func ParseAssertions(text string) ([]Assertion, error) {
+	assertions := []Assertion{}
+	for i, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		var assertion Assertion
+		var err error
+		if kind := AssertionKind(fields[0]); kind == Never || kind == Eventually {
+			assertion, err = parseAssertion(fields)
+		} else {
+			assertion.Kind = Temporal
+			assertion.Formula, err = ParseFormula(line)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", i+1, err)
+		}
+		assertions = append(assertions, assertion)
+	}
+	return assertions, nil
+}
+
+// Reads and parses the assertions saved in the given file (see ParseAssertions)
+func LoadAssertions(file string) ([]Assertion, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	assertions, err := ParseAssertions(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return assertions, nil
+}
+
+// Parses the fields of a single assertion, see ParseAssertions for its grammar
+func parseAssertion(fields []string) (Assertion, error) {
+	assertion := Assertion{Kind: AssertionKind(fields[0])}
+	event, rest, err := parseEvent(fields[1:])
+	if err != nil {
+		return assertion, err
+	}
+	assertion.Event = event
+
+	if len(rest) > 0 {
+		if assertion.Kind != Never || rest[0] != "before" {
+			return assertion, fmt.Errorf("unexpected '%s' after the event", strings.Join(rest, " "))
+		}
+		until, rest, err := parseEvent(rest[1:])
+		if err != nil {
+			return assertion, err
+		}
+		if len(rest) > 0 {
+			return assertion, fmt.Errorf("unexpected '%s' after the event", strings.Join(rest, " "))
+		}
+		assertion.Until = &until
+	}
+	return assertion, nil
+}
+
+// Parses an event from the beginning of the given fields, returns the fields left
+func parseEvent(fields []string) (Event, []string, error) {
+	if len(fields) < 3 {
+		return Event{}, nil, fmt.Errorf("incomplete event '%s' (expected '<participant> sends|receives on <channel>')", strings.Join(fields, " "))
+	}
+
+	event := Event{Participant: fields[0]}
+	switch fields[1] {
+	case "sends":
+		event.Move = fsa.Send
+	case "receives":
+		event.Move = fsa.Recv
+	default:
+		return event, nil, fmt.Errorf("unknown operation '%s' (expected sends or receives)", fields[1])
+	}
+
+	rest := fields[2:]
+	if (rest[0] == "on" || rest[0] == "from") && len(rest) > 1 {
+		rest = rest[1:]
+	}
+	event.Channel = rest[0]
+
+	// The patterns are validated here, so that the matching can ignore the errors
+	for _, pattern := range []string{event.Participant, event.Channel} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return event, nil, fmt.Errorf("invalid pattern '%s': %s", pattern, err)
+		}
+	}
+	return event, rest[1:], nil
+}
+
+// ----------------------------------------------------------------------------
+// Assertion checking
+
+// Checks the given assertions on the Choreography Automata, a diagnostic is returned for each one violated.
+// The trace of the diagnostic is the shortest counterexample: for the Never assertions the execution that
+// ends with the forbidden event, for the Eventually ones the execution that can't make the event happen anymore
+func CheckAssertions(choreography *fsa.FSA, assertions []Assertion) []Diagnostic {
+	diagnostics := []Diagnostic{}
+	for _, assertion := range assertions {
+		var state int
+		var trace []fsa.Transition
+		var isViolated bool
+		switch assertion.Kind {
+		case Never:
+			state, trace, isViolated = findForbidden(choreography, assertion.Event, assertion.Until)
+		case Eventually:
+			state, trace, isViolated = findAvoiding(choreography, assertion.Event)
+		case Temporal:
+			state, trace, isViolated = checkFormula(choreography, assertion.Formula)
+		}
+
+		if isViolated {
+			message := fmt.Sprintf("the assertion '%s' doesn't hold", assertion)
+			diagnostics = append(diagnostics, Diagnostic{Kind: Violation, State: state, Message: message, Trace: trace})
+		}
+	}
+	return diagnostics
+}
+
+// Searches (breadth-first) for an interaction that matches the forbidden event, without going through the
+// ones that match the until event (if any). Returns the state reached with it and the trace that leads there
+func findForbidden(choreography *fsa.FSA, forbidden Event, until *Event) (int, []fsa.Transition, bool) {
+	outgoing := outgoingSteps(choreography)
+	reachedBy := map[int]assertionStep{choreography.InitialState(): {from: fsa.Unknown}}
+	queue := []int{choreography.InitialState()}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, next := range outgoing[current] {
+			if forbidden.Matches(next.t) {
+				return next.to, append(counterexample(reachedBy, current), next.t), true
+			}
+			if _, isVisited := reachedBy[next.to]; !isVisited && (until == nil || !until.Matches(next.t)) {
+				reachedBy[next.to] = assertionStep{current, next.to, next.t}
+				queue = append(queue, next.to)
+			}
+		}
+	}
+	return fsa.Unknown, nil, false
+}
+
+// Searches (breadth-first) for an execution that avoids the given event: through the interactions that don't
+// match it, a state is reached that is final (the execution may end there), that has no outgoing transitions
+// or from which no interaction matching the event can be reached anymore. Returns the state and its trace
+func findAvoiding(choreography *fsa.FSA, event Event) (int, []fsa.Transition, bool) {
+	outgoing := outgoingSteps(choreography)
+
+	// The states from which an interaction matching the event can be reached, computed backward
+	predecessors, canHappen, queue := make(map[int][]int), make(map[int]bool), []int{}
+	for from, steps := range outgoing {
+		for _, next := range steps {
+			predecessors[next.to] = append(predecessors[next.to], from)
+			if event.Matches(next.t) && !canHappen[from] {
+				canHappen[from] = true
+				queue = append(queue, from)
+			}
+		}
+	}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, previous := range predecessors[current] {
+			if !canHappen[previous] {
+				canHappen[previous] = true
+				queue = append(queue, previous)
+			}
+		}
+	}
+
+	reachedBy := map[int]assertionStep{choreography.InitialState(): {from: fsa.Unknown}}
+	queue = []int{choreography.InitialState()}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		if choreography.IsFinalState(current) || len(outgoing[current]) == 0 || !canHappen[current] {
+			return current, counterexample(reachedBy, current), true
+		}
+		for _, next := range outgoing[current] {
+			if _, isVisited := reachedBy[next.to]; !isVisited && !event.Matches(next.t) {
+				reachedBy[next.to] = assertionStep{current, next.to, next.t}
+				queue = append(queue, next.to)
+			}
+		}
+	}
+	return fsa.Unknown, nil, false
+}
+
+// A single step of an execution of the Choreography Automata, the transition taken (and between which states)
+type assertionStep struct {
+	from, to int
+	t        fsa.Transition
+}
+
+// Returns the outgoing steps of each state of the given automaton, in the order of ForEachTransition
+func outgoingSteps(automaton *fsa.FSA) map[int][]assertionStep {
+	outgoing := make(map[int][]assertionStep)
+	automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+		outgoing[from] = append(outgoing[from], assertionStep{from, to, t})
+	})
+	return outgoing
+}
+
+// Returns the transitions that lead from the initial state (the one reached by no step) to the given state
+func counterexample(reachedBy map[int]assertionStep, state int) []fsa.Transition {
+	trace := []fsa.Transition{}
+	for current := state; reachedBy[current].from != fsa.Unknown; current = reachedBy[current].from {
+		trace = append([]fsa.Transition{reachedBy[current].t}, trace...)
+	}
+	return trace
+}
@@ -0,0 +1,68 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the user defined assertions checked on the Choreography Automata
+package diagnostics
+
+import (
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestParseAssertions(t *testing.T) {
+	assertions, err := ParseAssertions(`
+# The worker has to be initialized first
+never worker sends on results before worker receives from init
+eventually main receives done
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assertions) != 2 || assertions[0].Until == nil || assertions[1].Event.Move != fsa.Recv {
+		t.Fatalf("expected the two assertions, got %v", assertions)
+	}
+	if text := assertions[0].String(); text != "never worker sends on results before worker receives on init" {
+		t.Errorf("expected the canonical form of the assertion, got '%s'", text)
+	}
+
+	for _, invalid := range []string{"always main sends on ch", "never main closes ch", "eventually main sends on ch before main receives on ch", "never [main sends on ch"} {
+		if _, err := ParseAssertions(invalid); err == nil {
+			t.Errorf("expected an error for '%s'", invalid)
+		}
+	}
+}
+
+func TestCheckAssertions(t *testing.T) {
+	localViews := transforms.ExtractGoroutineFSA(meta.ExtractMetadata("../../example/PingPong.go", meta.NoTrace))
+	for _, lView := range localViews {
+		lView.Automaton = transforms.SubsetConstruction(lView.Automaton)
+	}
+	choreography := transforms.LocalViewsComposition(localViews)
+
+	assertions, err := ParseAssertions(`
+eventually main receives on pong
+never main receives on pong before main sends on ping
+never main/player@PingPong.go:21#1 sends on ping
+eventually main sends on pong
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the second player sends on ping, while main never sends on pong
+	issues := CheckAssertions(choreography, assertions)
+	if len(issues) != 2 || issues[0].Kind != Violation || issues[1].Kind != Violation {
+		t.Fatalf("expected the last two assertions to be violated, got %v", issues)
+	}
+	trace := issues[0].Trace
+	if last := trace[len(trace)-1].Payload.(transforms.Interaction); last.From != "main/player@PingPong.go:21#1" || last.Channel.Name != "ping" {
+		t.Errorf("expected the counterexample to end with the forbidden send, got %v", trace)
+	}
+	if len(issues[1].Trace) != 0 {
+		t.Errorf("expected the send on pong to be impossible from the initial state, got %v", issues[1].Trace)
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package diagnostics implements the checks that can be performed on the extracted Choreography
+// Automata (e.g deadlock detection). Each check returns a list of Diagnostic that describes
+// the issue found and where (in which state of the automaton) it has been found.
+//
+package diagnostics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+)
+
+const (
+	// Relation enum
+	Inclusion    Relation = "inclusion"    // Every execution of the choreography is allowed by the specification
+	Bisimulation Relation = "bisimulation" // The choreography and the specification can simulate each other
+)
+
+// Type alias to abstract the Relation enum, how the choreography has to conform to a specification
+type Relation string
+
+// Parses the name of a Relation (e.g "inclusion"), an error is returned for unknown names
+func ParseRelation(name string) (Relation, error) {
+	switch relation := Relation(name); relation {
+	case Inclusion, Bisimulation:
+		return relation, nil
+	}
+	return "", fmt.Errorf("unknown relation '%s' (expected %s or %s)", name, Inclusion, Bisimulation)
+}
+
+// ----------------------------------------------------------------------------
+// Conformance
+
+// A conformanceStep is a transition of an automaton reduced to what the conformance looks at: its label
+type conformanceStep struct {
+	to int
+	t  fsa.Transition
+}
+
+// A pair of states, one of the choreography and one of the specification (or a set of them for the inclusion)
+type conformancePair struct {
+	choreography int
+	spec         int // The state of the specification, or the index of the set of states for the inclusion
+}
+
+// Checks that the given choreography conforms to the given specification, an automaton written by hand (or exported
+// and then edited) that describes the intended protocol. The transitions are compared by label only, so the labels of
+// the specification have to be the ones of the choreography (e.g "main → worker: jobs<int>"). With Inclusion every
+// execution of the choreography, as well as its termination, has to be allowed by the specification (which can
+// allow more). With Bisimulation the two automata have to be (strongly) bisimilar: the specification can't allow more
+// and the same choices have to be available at the same time. The Diagnostic returned (at most one) is on the state of
+// the choreography where the two differ, its Trace is the shortest execution that leads there followed (if any) by
+// the transition that one of the two automata can make and the other can't
+func CheckConformance(choreography, spec *fsa.FSA, relation Relation) []Diagnostic {
+	if relation == Bisimulation {
+		return checkBisimulation(choreography, spec)
+	}
+	return checkInclusion(choreography, spec)
+}
+
+// Checks the language inclusion of the choreography in the specification, the latter is determinized on the fly: each
+// state of the choreography is paired with the set of states in which the specification can be after the same trace
+func checkInclusion(choreography, spec *fsa.FSA) []Diagnostic {
+	choreographySteps, specSteps := conformanceSteps(choreography), conformanceSteps(spec)
+	// The sets of states of the specification, each one is indexed once (by key, see stateSetKey)
+	specSets, setIndex := [][]int{}, map[string]int{}
+	indexOf := func(states []int) int {
+		key := stateSetKey(states)
+		if _, isKnown := setIndex[key]; !isKnown {
+			setIndex[key] = len(specSets)
+			specSets = append(specSets, states)
+		}
+		return setIndex[key]
+	}
+
+	initial := conformancePair{choreography.InitialState(), indexOf([]int{spec.InitialState()})}
+	reachedBy := map[conformancePair]conformanceParent{initial: {}}
+	queue := []conformancePair{initial}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		specStates := specSets[current.spec]
+
+		if choreography.IsFinalState(current.choreography) && !anyFinal(spec, specStates) {
+			message := "the choreography can terminate here, the specification can't"
+			return []Diagnostic{{Nonconformance, current.choreography, message, traceOf(reachedBy, current)}}
+		}
+
+		for _, step := range choreographySteps[current.choreography] {
+			nextStates := []int{}
+			for _, q := range specStates {
+				for _, specStep := range specSteps[q] {
+					if specStep.t.Label == step.t.Label {
+						nextStates = append(nextStates, specStep.to)
+					}
+				}
+			}
+			if len(nextStates) == 0 {
+				message := fmt.Sprintf("the choreography can make '%s' here, the specification can't", step.t.Label)
+				trace := append(traceOf(reachedBy, current), step.t)
+				return []Diagnostic{{Nonconformance, current.choreography, message, trace}}
+			}
+
+			next := conformancePair{step.to, indexOf(nextStates)}
+			if _, isVisited := reachedBy[next]; !isVisited {
+				reachedBy[next] = conformanceParent{&current, step.t}
+				queue = append(queue, next)
+			}
+		}
+	}
+	return []Diagnostic{}
+}
+
+// Checks that the choreography and the specification are bisimilar. The largest bisimulation is computed first (as
+// the greatest fixpoint of the relation between the states with the same finality), then the reason why the initial
+// states aren't related (if they aren't) is searched breadth-first among the pairs reached with the same trace
+func checkBisimulation(choreography, spec *fsa.FSA) []Diagnostic {
+	choreographySteps, specSteps := conformanceSteps(choreography), conformanceSteps(spec)
+
+	related := map[conformancePair]bool{}
+	choreography.ForEachState(func(p int) {
+		spec.ForEachState(func(q int) {
+			if choreography.IsFinalState(p) == spec.IsFinalState(q) {
+				related[conformancePair{p, q}] = true
+			}
+		})
+	})
+	// Returns true if each step of "from" is matched by a step of "other" with the same label into related states
+	simulates := func(from, other []conformanceStep, isRelated func(to, otherTo int) bool) bool {
+		for _, step := range from {
+			isMatched := false
+			for _, otherStep := range other {
+				isMatched = isMatched || (otherStep.t.Label == step.t.Label && isRelated(step.to, otherStep.to))
+			}
+			if !isMatched {
+				return false
+			}
+		}
+		return true
+	}
+	for isChanged := true; isChanged; {
+		isChanged = false
+		for pair := range related {
+			q := pair.spec
+			forward := simulates(choreographySteps[pair.choreography], specSteps[q], func(p, q int) bool { return related[conformancePair{p, q}] })
+			backward := simulates(specSteps[q], choreographySteps[pair.choreography], func(q, p int) bool { return related[conformancePair{p, q}] })
+			if !forward || !backward {
+				delete(related, pair)
+				isChanged = true
+			}
+		}
+	}
+
+	initial := conformancePair{choreography.InitialState(), spec.InitialState()}
+	if related[initial] {
+		return []Diagnostic{}
+	}
+
+	// Among the pairs that aren't related there's always one where the difference shows up in a single step
+	reachedBy := map[conformancePair]conformanceParent{initial: {}}
+	queue := []conformancePair{initial}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		q := current.spec
+		trace := traceOf(reachedBy, current)
+
+		if isFinal := choreography.IsFinalState(current.choreography); isFinal != spec.IsFinalState(q) {
+			message := "the choreography can terminate here, the specification can't"
+			if !isFinal {
+				message = "the specification can terminate here, the choreography can't"
+			}
+			return []Diagnostic{{Nonconformance, current.choreography, message, trace}}
+		}
+		if step, isUnmatched := unmatchedStep(choreographySteps[current.choreography], specSteps[q]); isUnmatched {
+			message := fmt.Sprintf("the choreography can make '%s' here, the specification can't", step.t.Label)
+			return []Diagnostic{{Nonconformance, current.choreography, message, append(trace, step.t)}}
+		}
+		if step, isUnmatched := unmatchedStep(specSteps[q], choreographySteps[current.choreography]); isUnmatched {
+			message := fmt.Sprintf("the specification can make '%s' here, the choreography can't", step.t.Label)
+			return []Diagnostic{{Nonconformance, current.choreography, message, append(trace, step.t)}}
+		}
+
+		for _, step := range choreographySteps[current.choreography] {
+			for _, specStep := range specSteps[q] {
+				next := conformancePair{step.to, specStep.to}
+				if _, isVisited := reachedBy[next]; specStep.t.Label == step.t.Label && !related[next] && !isVisited {
+					reachedBy[next] = conformanceParent{&current, step.t}
+					queue = append(queue, next)
+				}
+			}
+		}
+	}
+
+	// Not reachable with finite automata, the initial states are reported as they are
+	message := "the choreography and the specification aren't bisimilar"
+	return []Diagnostic{{Nonconformance, initial.choreography, message, []fsa.Transition{}}}
+}
+
+// How a pair has been reached during a visit: the previous pair and the transition of the choreography taken
+type conformanceParent struct {
+	previous *conformancePair
+	t        fsa.Transition
+}
+
+// Returns the transitions of the choreography that lead to the given pair, following the parents of the visit
+func traceOf(reachedBy map[conformancePair]conformanceParent, pair conformancePair) []fsa.Transition {
+	trace := []fsa.Transition{}
+	for parent := reachedBy[pair]; parent.previous != nil; parent = reachedBy[*parent.previous] {
+		trace = append([]fsa.Transition{parent.t}, trace...)
+	}
+	return trace
+}
+
+// Returns the first of the given steps whose label isn't the one of any of the other steps
+func unmatchedStep(steps, other []conformanceStep) (conformanceStep, bool) {
+	for _, step := range steps {
+		isMatched := false
+		for _, otherStep := range other {
+			isMatched = isMatched || otherStep.t.Label == step.t.Label
+		}
+		if !isMatched {
+			return step, true
+		}
+	}
+	return conformanceStep{}, false
+}
+
+// Returns the outgoing steps of each state of the given automaton sorted by label (and then by destination), so
+// that the visits (and the trace reported) are always the same
+func conformanceSteps(automaton *fsa.FSA) map[int][]conformanceStep {
+	steps := make(map[int][]conformanceStep)
+	automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+		steps[from] = append(steps[from], conformanceStep{to, t})
+	})
+	for _, outgoing := range steps {
+		sort.SliceStable(outgoing, func(i, j int) bool {
+			if outgoing[i].t.Label != outgoing[j].t.Label {
+				return outgoing[i].t.Label < outgoing[j].t.Label
+			}
+			return outgoing[i].to < outgoing[j].to
+		})
+	}
+	return steps
+}
+
+// Returns true if any of the given states is final in the given automaton
+func anyFinal(automaton *fsa.FSA, states []int) bool {
+	for _, id := range states {
+		if automaton.IsFinalState(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the key of the given set of states (sorted and without duplicates), the states are sorted in place
+func stateSetKey(states []int) string {
+	sort.Ints(states)
+	ids := []string{}
+	for i, id := range states {
+		if i == 0 || states[i-1] != id {
+			ids = append(ids, fmt.Sprint(id))
+		}
+	}
+	return strings.Join(ids, ",")
+}
@@ -0,0 +1,78 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the conformance of the Choreography Automata to a specification
+package diagnostics
+
+import (
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+)
+
+// The choreography of a worker that replies to a single request, then main either stops it or not
+func conformanceChoreography() *fsa.FSA {
+	return fsa.NewBuilder().
+		State("start").Eps("main △ worker").To("spawned").
+		Eps("main → worker: req").To("serving").
+		Eps("worker → main: resp").To("replied").
+		Eps("main → worker: stop").To("done").
+		Final("replied", "done").
+		Build()
+}
+
+func TestCheckInclusion(t *testing.T) {
+	// The specification allows more requests, and the stop message, before the termination
+	spec := fsa.NewBuilder().
+		State("s0").Eps("main △ worker").To("s1").
+		Eps("main → worker: req").To("s2").
+		Eps("worker → main: resp").To("s1").
+		State("s1").Eps("main → worker: stop").To("s3").
+		Final("s1", "s3").
+		Build()
+	if issues := CheckConformance(conformanceChoreography(), spec, Inclusion); len(issues) != 0 {
+		t.Errorf("expected the choreography to be included in the specification, got %v", issues)
+	}
+
+	// Without the stop message the first nonconforming trace ends with it
+	strict := fsa.NewBuilder().
+		State("s0").Eps("main △ worker").To("s1").
+		Eps("main → worker: req").To("s2").
+		Eps("worker → main: resp").To("s3").
+		Final("s3").
+		Build()
+	issues := CheckConformance(conformanceChoreography(), strict, Inclusion)
+	if len(issues) != 1 || issues[0].Kind != Nonconformance || issues[0].State != 3 || len(issues[0].Trace) != 4 {
+		t.Fatalf("expected a nonconformance on the stop message, got %v", issues)
+	}
+	if issues[0].Trace[3].Label != "main → worker: stop" {
+		t.Errorf("expected the trace to end with the stop message, got %v", issues[0].Trace)
+	}
+}
+
+func TestCheckBisimulation(t *testing.T) {
+	// The choreography is bisimilar to itself (with the states renumbered)
+	if issues := CheckConformance(conformanceChoreography(), conformanceChoreography(), Bisimulation); len(issues) != 0 {
+		t.Errorf("expected the choreography to be bisimilar to itself, got %v", issues)
+	}
+
+	// The specification that allows more requests includes the choreography but isn't bisimilar to it
+	spec := fsa.NewBuilder().
+		State("s0").Eps("main △ worker").To("s1").
+		Eps("main → worker: req").To("s2").
+		Eps("worker → main: resp").To("s3").
+		Eps("main → worker: stop").To("s4").
+		State("s3").Eps("main → worker: req").To("s2").
+		Final("s3", "s4").
+		Build()
+	if issues := CheckConformance(conformanceChoreography(), spec, Inclusion); len(issues) != 0 {
+		t.Errorf("expected the choreography to be included in the specification, got %v", issues)
+	}
+
+	issues := CheckConformance(conformanceChoreography(), spec, Bisimulation)
+	expected := "the specification can make 'main → worker: req' here, the choreography can't"
+	if len(issues) != 1 || issues[0].State != 3 || issues[0].Message != expected || len(issues[0].Trace) != 4 {
+		t.Errorf("expected the additional request of the specification to be reported, got %v", issues)
+	}
+}
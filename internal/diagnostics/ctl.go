@@ -0,0 +1,419 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package diagnostics implements the checks that can be performed on the extracted Choreography
+// Automata (e.g deadlock detection). Each check returns a list of Diagnostic that describes
+// the issue found and where (in which state of the automaton) it has been found.
+//
+package diagnostics
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+)
+
+// The operators of the CTL formulas, the ones whose name starts with a letter are keywords of the syntax
+var (
+	stateAtoms      = []string{"true", "false", "final", "deadlock"} // The atomic propositions over the states
+	unaryOperators  = []string{"EX", "AX", "EF", "AF", "EG", "AG"}   // The temporal operators over a single formula
+	binaryOperators = []string{"->", "|", "&"}                       // The boolean connectives, by increasing precedence
+)
+
+// ----------------------------------------------------------------------------
+// CTL formulas
+
+// A Formula is a CTL (Computation Tree Logic) formula over the states of the Choreography Automata, the atomic
+// propositions are "true", "false", "final" (every participant may have terminated) and "deadlock" (no interaction
+// is possible but some participant hasn't terminated). The modalities "<event> f" (an interaction matching the
+// event leads to a state where f holds) and "[event] f" (every interaction matching the event does) relate the
+// formulas to the interactions (see Event), so that "<main sends on ch> true" holds where main can send on ch.
+//
+// The temporal operators are the usual ones: EX, AX (next), EF, AF (finally), EG, AG (globally), E[f U g] and
+// A[f U g] (until), along with the boolean connectives "!", "&", "|" and "->". The paths are the maximal ones, so
+// a path can end in a state without outgoing interactions (e.g "EG f" holds in a final state where f holds)
+type Formula struct {
+	Operator string     // The operator (e.g "AG", "&", "<>" for the diamond, "[]" for the box) or the atom
+	Event    Event      // The event of the modalities (only for "<>" and "[]")
+	Operands []*Formula // The operands of the operator (none for the atoms)
+}
+
+// Converts the Formula to the form accepted by ParseFormula, the binary connectives are always parenthesized
+func (f *Formula) String() string {
+	switch f.Operator {
+	case "<>":
+		return fmt.Sprintf("<%s> %s", f.Event, f.Operands[0])
+	case "[]":
+		return fmt.Sprintf("[%s] %s", f.Event, f.Operands[0])
+	case "!":
+		return "!" + f.Operands[0].String()
+	case "EU", "AU":
+		return fmt.Sprintf("%s[%s U %s]", f.Operator[:1], f.Operands[0], f.Operands[1])
+	case "->", "|", "&":
+		return fmt.Sprintf("(%s %s %s)", f.Operands[0], f.Operator, f.Operands[1])
+	}
+	if len(f.Operands) == 1 {
+		return fmt.Sprintf("%s %s", f.Operator, f.Operands[0])
+	}
+	return f.Operator
+}
+
+// Returns true if the Formula holds in the initial state of the given automaton (see Satisfying)
+func (f *Formula) Holds(choreography *fsa.FSA) bool {
+	return f.Satisfying(choreography)[choreography.InitialState()]
+}
+
+// Returns the states of the given automaton where the Formula holds, computed bottom-up with the usual
+// fixpoint characterization of each operator (e.g EF f is the least fixpoint of Z = f | EX Z)
+func (f *Formula) Satisfying(choreography *fsa.FSA) map[int]bool {
+	return newCTLModel(choreography).eval(f)
+}
+
+// ----------------------------------------------------------------------------
+// Model checking
+
+// The Kripke structure the formulas are evaluated on, the states of the automaton along with their steps
+type ctlModel struct {
+	automaton *fsa.FSA
+	outgoing  map[int][]assertionStep
+	states    []int
+}
+
+// Returns the model of the given automaton
+func newCTLModel(automaton *fsa.FSA) ctlModel {
+	model := ctlModel{automaton, outgoingSteps(automaton), []int{}}
+	automaton.ForEachState(func(id int) { model.states = append(model.states, id) })
+	return model
+}
+
+// Returns the states (among all the ones of the model) that satisfy the given predicate
+func (m ctlModel) filter(predicate func(id int) bool) map[int]bool {
+	result := make(map[int]bool)
+	for _, id := range m.states {
+		if predicate(id) {
+			result[id] = true
+		}
+	}
+	return result
+}
+
+// Returns the states that have a step to the target ones, only the steps matching the event if given
+func (m ctlModel) existsNext(target map[int]bool, event *Event) map[int]bool {
+	return m.filter(func(id int) bool {
+		for _, next := range m.outgoing[id] {
+			if target[next.to] && (event == nil || event.Matches(next.t)) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Returns the states whose steps all lead to the target ones (vacuously the ones without steps), only the
+// steps matching the event are taken into account if given
+func (m ctlModel) allNext(target map[int]bool, event *Event) map[int]bool {
+	return m.filter(func(id int) bool {
+		for _, next := range m.outgoing[id] {
+			if !target[next.to] && (event == nil || event.Matches(next.t)) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Returns the least fixpoint of Z = goal | (hold & pre(Z)), that is the states from which the goal is
+// reached through states where hold is true (E[hold U goal] or A[hold U goal] depending on pre)
+func (m ctlModel) until(hold, goal map[int]bool, universal bool) map[int]bool {
+	reached := m.filter(func(id int) bool { return goal[id] })
+	for {
+		var previous map[int]bool
+		if universal {
+			// The states without steps have no path going on, so they can't reach the goal
+			previous = m.allNext(reached, nil)
+		} else {
+			previous = m.existsNext(reached, nil)
+		}
+
+		changed := false
+		for id := range previous {
+			if hold[id] && !reached[id] && (!universal || len(m.outgoing[id]) > 0) {
+				reached[id], changed = true, true
+			}
+		}
+		if !changed {
+			return reached
+		}
+	}
+}
+
+// Returns the greatest fixpoint of Z = hold & (EX Z | no step), that is the states from which a maximal
+// path starts whose states all satisfy hold (EG hold)
+func (m ctlModel) globally(hold map[int]bool) map[int]bool {
+	current := m.filter(func(id int) bool { return hold[id] })
+	for {
+		next := m.existsNext(current, nil)
+		changed := false
+		for id := range current {
+			if !next[id] && len(m.outgoing[id]) > 0 {
+				delete(current, id)
+				changed = true
+			}
+		}
+		if !changed {
+			return current
+		}
+	}
+}
+
+// Returns the states that don't belong to the given set
+func (m ctlModel) complement(set map[int]bool) map[int]bool {
+	return m.filter(func(id int) bool { return !set[id] })
+}
+
+// Returns the states where the given formula holds
+func (m ctlModel) eval(f *Formula) map[int]bool {
+	operands := make([]map[int]bool, len(f.Operands))
+	for i, operand := range f.Operands {
+		operands[i] = m.eval(operand)
+	}
+	all := m.filter(func(int) bool { return true })
+
+	switch f.Operator {
+	case "true":
+		return all
+	case "false":
+		return map[int]bool{}
+	case "final":
+		return m.filter(m.automaton.IsFinalState)
+	case "deadlock":
+		return m.filter(func(id int) bool { return len(m.outgoing[id]) == 0 && !m.automaton.IsFinalState(id) })
+	case "!":
+		return m.complement(operands[0])
+	case "&":
+		return m.filter(func(id int) bool { return operands[0][id] && operands[1][id] })
+	case "|":
+		return m.filter(func(id int) bool { return operands[0][id] || operands[1][id] })
+	case "->":
+		return m.filter(func(id int) bool { return !operands[0][id] || operands[1][id] })
+	case "<>":
+		return m.existsNext(operands[0], &f.Event)
+	case "[]":
+		return m.allNext(operands[0], &f.Event)
+	case "EX":
+		return m.existsNext(operands[0], nil)
+	case "AX":
+		return m.allNext(operands[0], nil)
+	case "EF":
+		return m.until(all, operands[0], false)
+	case "AF":
+		return m.until(all, operands[0], true)
+	case "EG":
+		return m.globally(operands[0])
+	case "AG":
+		return m.complement(m.until(all, m.complement(operands[0]), false))
+	case "EU":
+		return m.until(operands[0], operands[1], false)
+	case "AU":
+		return m.until(operands[0], operands[1], true)
+	}
+	panic(fmt.Sprintf("unknown CTL operator '%s'", f.Operator))
+}
+
+// Checks the given formula on the Choreography Automata, returns the state and the trace of the counterexample
+// when it doesn't hold in the initial state. The counterexample is computed only for the outermost AG (the shortest
+// path to a state that violates its operand) and AF (a path that never satisfies its operand), otherwise the trace
+// is empty and the state is the initial one
+func checkFormula(choreography *fsa.FSA, formula *Formula) (int, []fsa.Transition, bool) {
+	model, initial := newCTLModel(choreography), choreography.InitialState()
+	if model.eval(formula)[initial] {
+		return fsa.Unknown, nil, false
+	}
+
+	switch formula.Operator {
+	case "AG":
+		// Breadth-first, so that the first violating state found is the closest one
+		holds := model.eval(formula.Operands[0])
+		reachedBy := map[int]assertionStep{initial: {from: fsa.Unknown}}
+		queue := []int{initial}
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			if !holds[current] {
+				return current, counterexample(reachedBy, current), true
+			}
+			for _, next := range model.outgoing[current] {
+				if _, isVisited := reachedBy[next.to]; !isVisited {
+					reachedBy[next.to] = assertionStep{current, next.to, next.t}
+					queue = append(queue, next.to)
+				}
+			}
+		}
+	case "AF":
+		// The path stays in the states where EG !f holds until either it can't go on or it loops
+		avoiding := model.globally(model.complement(model.eval(formula.Operands[0])))
+		trace, visited, current := []fsa.Transition{}, map[int]bool{}, initial
+		for !visited[current] {
+			visited[current] = true
+			for _, next := range model.outgoing[current] {
+				if avoiding[next.to] {
+					trace = append(trace, next.t)
+					current = next.to
+					break
+				}
+			}
+		}
+		return current, trace, true
+	}
+	return initial, []fsa.Transition{}, true
+}
+
+// ----------------------------------------------------------------------------
+// Parsing
+
+// Parses the given CTL formula (see Formula for its syntax), the binary connectives are right associative and
+// bind less than the unary operators: "&" binds more than "|" that binds more than "->". The events of the
+// modalities have the same form of the assertions (e.g "<worker sends on ch> true") but they can't contain
+// the character that closes them (so the participant patterns inside a box can't use character classes)
+func ParseFormula(text string) (*Formula, error) {
+	parser := formulaParser{text: text}
+	formula, err := parser.parseBinary(0)
+	if err == nil && !parser.atEnd() {
+		err = parser.errorf("unexpected '%s'", parser.text[parser.pos:])
+	}
+	return formula, err
+}
+
+// A recursive descent parser of the CTL formulas, it reads the text one character at a time
+type formulaParser struct {
+	text string // The text of the formula
+	pos  int    // The offset of the next character to be read
+}
+
+// Returns an error that reports the column the parser reached
+func (p *formulaParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("column %d: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+// Skips the spaces, then returns true if the whole text has been read
+func (p *formulaParser) atEnd() bool {
+	for p.pos < len(p.text) && unicode.IsSpace(rune(p.text[p.pos])) {
+		p.pos++
+	}
+	return p.pos == len(p.text)
+}
+
+// Consumes the given token if the text continues with it, the keywords can't be followed by a letter
+func (p *formulaParser) accept(token string) bool {
+	if p.atEnd() || !strings.HasPrefix(p.text[p.pos:], token) {
+		return false
+	}
+	end := p.pos + len(token)
+	if unicode.IsLetter(rune(token[len(token)-1])) && end < len(p.text) && unicode.IsLetter(rune(p.text[end])) {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+// Consumes the given token or returns an error
+func (p *formulaParser) expect(token string) error {
+	if !p.accept(token) {
+		return p.errorf("expected '%s'", token)
+	}
+	return nil
+}
+
+// Parses the binary connectives from the given precedence level (see binaryOperators) on
+func (p *formulaParser) parseBinary(level int) (*Formula, error) {
+	if level == len(binaryOperators) {
+		return p.parseUnary()
+	}
+
+	left, err := p.parseBinary(level + 1)
+	if err != nil || !p.accept(binaryOperators[level]) {
+		return left, err
+	}
+	right, err := p.parseBinary(level)
+	return &Formula{Operator: binaryOperators[level], Operands: []*Formula{left, right}}, err
+}
+
+// Parses a unary operator (or a modality, an until, an atom, a parenthesized formula) along with its operand
+func (p *formulaParser) parseUnary() (*Formula, error) {
+	if p.atEnd() {
+		return nil, p.errorf("unexpected end of the formula")
+	}
+
+	for _, atom := range stateAtoms {
+		if p.accept(atom) {
+			return &Formula{Operator: atom}, nil
+		}
+	}
+	for _, operator := range unaryOperators {
+		if p.accept(operator) {
+			operand, err := p.parseUnary()
+			return &Formula{Operator: operator, Operands: []*Formula{operand}}, err
+		}
+	}
+	for _, quantifier := range []string{"E", "A"} {
+		if p.accept(quantifier + "[") {
+			return p.parseUntil(quantifier)
+		}
+	}
+
+	switch {
+	case p.accept("!"):
+		operand, err := p.parseUnary()
+		return &Formula{Operator: "!", Operands: []*Formula{operand}}, err
+	case p.accept("("):
+		formula, err := p.parseBinary(0)
+		if err == nil {
+			err = p.expect(")")
+		}
+		return formula, err
+	case p.accept("<"):
+		return p.parseModality("<>", ">")
+	case p.accept("["):
+		return p.parseModality("[]", "]")
+	}
+	return nil, p.errorf("unexpected '%s'", p.text[p.pos:])
+}
+
+// Parses the rest of an until (after "E[" or "A["), that is "f U g]"
+func (p *formulaParser) parseUntil(quantifier string) (*Formula, error) {
+	hold, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("U"); err != nil {
+		return nil, err
+	}
+	goal, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	return &Formula{Operator: quantifier + "U", Operands: []*Formula{hold, goal}}, p.expect("]")
+}
+
+// Parses the rest of a modality (after its opening character), that is the event until the closing one and the operand
+func (p *formulaParser) parseModality(operator, closing string) (*Formula, error) {
+	end := strings.Index(p.text[p.pos:], closing)
+	if end == -1 {
+		return nil, p.errorf("expected '%s' after the event", closing)
+	}
+
+	event, rest, err := parseEvent(strings.Fields(p.text[p.pos : p.pos+end]))
+	if err == nil && len(rest) > 0 {
+		err = fmt.Errorf("unexpected '%s' after the event", strings.Join(rest, " "))
+	}
+	if err != nil {
+		return nil, p.errorf("%s", err)
+	}
+	p.pos += end + len(closing)
+
+	operand, err := p.parseUnary()
+	return &Formula{Operator: operator, Event: event, Operands: []*Formula{operand}}, err
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the CTL model checking of the Choreography Automata
+package diagnostics
+
+import (
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+// Returns a Choreography Automata where main either sends the job (then the worker polls until it's done)
+// or gives up and deadlocks: 0 -job-> 1 -poll-> 1 -done-> 2 (final), 0 -abort-> 3
+func ctlChoreography() *fsa.FSA {
+	interaction := func(from, to, channel string) fsa.Transition {
+		payload := transforms.Interaction{From: from, To: to, Channel: meta.ChanMetadata{Name: channel}}
+		return fsa.Transition{Move: fsa.Send, Label: channel, Payload: payload}
+	}
+	automaton := fsa.New()
+	automaton.AddTransition(0, 1, interaction("main", "worker", "job"))
+	automaton.AddTransition(1, 1, interaction("worker", "main", "poll"))
+	automaton.AddTransition(1, 2, interaction("worker", "main", "done"))
+	automaton.AddTransition(0, 3, interaction("main", "worker", "abort"))
+	automaton.SetFinalState(2)
+	return automaton
+}
+
+func TestFormulaHolds(t *testing.T) {
+	automaton := ctlChoreography()
+	expected := map[string]bool{
+		"EF final":                                               true,
+		"AG !deadlock":                                           false,
+		"EF deadlock & EF final":                                 true,
+		"<main sends on job> EX final":                           true,
+		"[main sends on job] AF final":                           false, // The worker may poll forever
+		"[main sends on job] EG !final":                          true,
+		"AG (<worker sends on done> true -> EX final)":           true,
+		"E[!final U <worker sends on done> true]":                true,
+		"A[true U final | deadlock]":                             false,
+		"AX (final | deadlock | <worker receives on poll> true)": false,
+		"AX [worker sends on *] final":                           false,
+		"AG (final -> AX false)":                                 true,
+	}
+	for text, holds := range expected {
+		formula, err := ParseFormula(text)
+		if err != nil {
+			t.Fatalf("unexpected error for '%s': %s", text, err)
+		}
+		if formula.Holds(automaton) != holds {
+			t.Errorf("expected '%s' (parsed as '%s') to be %v", text, formula, holds)
+		}
+	}
+
+	for _, invalid := range []string{"EF", "AG (final", "E[final U]", "<main sends> true", "final final", "EXfinal"} {
+		if _, err := ParseFormula(invalid); err == nil {
+			t.Errorf("expected an error for '%s'", invalid)
+		}
+	}
+}
+
+func TestFormulaCounterexample(t *testing.T) {
+	automaton := ctlChoreography()
+	assertions, err := ParseAssertions("AG !deadlock\nAF final")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issues := CheckAssertions(automaton, assertions)
+	if len(issues) != 2 || issues[0].State != 3 || len(issues[0].Trace) != 1 || issues[0].Trace[0].Label != "abort" {
+		t.Fatalf("expected the deadlock to be reached with the abort, got %v", issues)
+	}
+	// The first path that never reaches a final state is the one where the worker polls forever
+	if trace := issues[1].Trace; issues[1].State != 1 || len(trace) != 2 || trace[1].Label != "poll" {
+		t.Errorf("expected the counterexample of AF to loop on the poll, got %v", issues[1])
+	}
+}
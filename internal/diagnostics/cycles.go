@@ -0,0 +1,138 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package diagnostics implements the checks that can be performed on the extracted Choreography
+// Automata (e.g deadlock detection). Each check returns a list of Diagnostic that describes
+// the issue found and where (in which state of the automaton) it has been found.
+//
+package diagnostics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+// Searches the given Choreography Automata for the recurring interaction loops (e.g. the protocol
+// recursion of an heartbeat), that is the strongly connected components with at least one transition.
+// These aren't necessarily issues, they're reported so that the user can confirm that every loop is
+// intended: the message lists the states, participants and channels involved in the loop while the
+// trace leads to the first state of the loop. The diagnostics returned are sorted by state id
+func FindCycles(choreography *fsa.FSA) []Diagnostic {
+	diagnostics := []Diagnostic{}
+
+	for _, component := range stronglyConnectedComponents(choreography) {
+		inComponent := make(map[int]bool)
+		for _, id := range component {
+			inComponent[id] = true
+		}
+
+		// Collects the interactions from within the component, a single state is a loop only with a self loop
+		participants, channels, nLoopTransitions := make(map[string]bool), make(map[string]bool), 0
+		choreography.ForEachTransition(func(from, to int, t fsa.Transition) {
+			if !inComponent[from] || !inComponent[to] {
+				return
+			}
+
+			nLoopTransitions++
+			if interaction, isInteraction := t.Payload.(transforms.Interaction); isInteraction {
+				participants[interaction.From] = true
+				if !interaction.IsBufferedSend() {
+					participants[interaction.To] = true
+				}
+				if interaction.Channel.Name != "" {
+					channels[interaction.Channel.Name] = true
+				}
+			}
+		})
+
+		if nLoopTransitions == 0 {
+			continue
+		}
+
+		states := make([]string, len(component))
+		for i, id := range component {
+			states[i] = fmt.Sprint(id)
+		}
+
+		message := fmt.Sprintf("interaction loop over states %s", strings.Join(states, ", "))
+		if len(participants) > 0 {
+			message += fmt.Sprintf(" between %s", strings.Join(sortedNames(participants), ", "))
+		}
+		if len(channels) > 0 {
+			message += fmt.Sprintf(" on %s", strings.Join(sortedNames(channels), ", "))
+		}
+
+		trace := choreography.ShortestPath(choreography.InitialState(), component[0])
+		diagnostics = append(diagnostics, Diagnostic{Kind: Cycle, State: component[0], Message: message, Trace: trace})
+	}
+
+	sort.Slice(diagnostics, func(i, j int) bool { return diagnostics[i].State < diagnostics[j].State })
+	return diagnostics
+}
+
+// Returns the strongly connected components of the given automaton (Tarjan's algorithm), each
+// one of them is a list of state ids sorted in ascending order. Every state belongs to a component
+func stronglyConnectedComponents(automaton *fsa.FSA) [][]int {
+	successors := make(map[int][]int)
+	automaton.ForEachTransition(func(from, to int, _ fsa.Transition) {
+		successors[from] = append(successors[from], to)
+	})
+
+	index, lowLink, onStack := make(map[int]int), make(map[int]int), make(map[int]bool)
+	stack, components := []int{}, [][]int{}
+
+	var visit func(id int)
+	visit = func(id int) {
+		index[id], lowLink[id] = len(index), len(index)
+		stack, onStack[id] = append(stack, id), true
+
+		for _, next := range successors[id] {
+			if _, visited := index[next]; !visited {
+				visit(next)
+				if lowLink[next] < lowLink[id] {
+					lowLink[id] = lowLink[next]
+				}
+			} else if onStack[next] && index[next] < lowLink[id] {
+				lowLink[id] = index[next]
+			}
+		}
+
+		// The state is the root of a component, the latter is popped from the stack
+		if lowLink[id] == index[id] {
+			component := []int{}
+			for {
+				top := stack[len(stack)-1]
+				stack, onStack[top] = stack[:len(stack)-1], false
+				component = append(component, top)
+				if top == id {
+					break
+				}
+			}
+			sort.Ints(component)
+			components = append(components, component)
+		}
+	}
+
+	automaton.ForEachState(func(id int) {
+		if _, visited := index[id]; !visited {
+			visit(id)
+		}
+	})
+
+	return components
+}
+
+// Returns the keys of the given set sorted in ascending order
+func sortedNames(set map[string]bool) []string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
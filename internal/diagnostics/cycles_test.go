@@ -0,0 +1,41 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the detection of the interaction loops in the Choreography Automata
+package diagnostics
+
+import (
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestFindCycles(t *testing.T) {
+	interaction := func(from, to, channel string) fsa.Transition {
+		i := transforms.Interaction{From: from, To: to, Channel: meta.ChanMetadata{Name: channel}}
+		return fsa.Transition{Move: fsa.Empty, Label: i.Label(transforms.FullLabels), Payload: i}
+	}
+
+	choreography := fsa.New()
+	choreography.AddTransition(0, 1, interaction("main", "worker", ""))
+	choreography.AddTransition(1, 2, interaction("main", "worker", "ping"))
+	choreography.AddTransition(2, 1, interaction("worker", "main", "pong"))
+	choreography.AddTransition(2, 3, interaction("main", "worker", "done"))
+	choreography.AddTransition(3, 3, interaction("environment", "worker", "tick"))
+
+	cycles := FindCycles(choreography)
+	if len(cycles) != 2 {
+		t.Fatalf("expected 2 cycles, got %v", cycles)
+	}
+
+	expected := "interaction loop over states 1, 2 between main, worker on ping, pong"
+	if cycles[0].Kind != Cycle || cycles[0].State != 1 || cycles[0].Message != expected || len(cycles[0].Trace) != 1 {
+		t.Errorf("unexpected diagnostic for the ping-pong loop: %s", cycles[0])
+	}
+	if cycles[1].State != 3 || cycles[1].Message != "interaction loop over states 3 between environment, worker on tick" {
+		t.Errorf("unexpected diagnostic for the self loop: %s", cycles[1])
+	}
+}
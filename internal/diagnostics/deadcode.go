@@ -0,0 +1,55 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package diagnostics implements the checks that can be performed on the extracted Choreography
+// Automata (e.g deadlock detection). Each check returns a list of Diagnostic that describes
+// the issue found and where (in which state of the automaton) it has been found.
+//
+package diagnostics
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	"github.com/its-hmny/Choreia/internal/static_analysis"
+)
+
+// Searches the functions of a file for dead communication code, the channel operations and spawns that start from
+// a state of the ScopeAutomata unreachable from the entry of the function (see static_analysis.DeadOperation). Since
+// such operations never happen they're missing from the choreography as well, this usually hides a bug: a message
+// that the other participants keep waiting for (e.g a send placed after an early return). Differently from the other
+// checks the State is the one of the ScopeAutomata of the function, the diagnostics are sorted by function
+func FindDeadCode(functions map[string]static_analysis.FuncMetadata) []Diagnostic {
+	names := make([]string, 0, len(functions))
+	for name := range functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	diagnostics := []Diagnostic{}
+	for _, name := range names {
+		for _, dead := range functions[name].DeadCode {
+			location := name
+			if dead.Position != "" {
+				location = fmt.Sprintf("%s (%s)", name, dead.Position)
+			}
+			message := fmt.Sprintf("%s in %s is never executed, the function has already returned", deadOperation(dead.Transition), location)
+			diagnostics = append(diagnostics, Diagnostic{Kind: DeadCode, State: dead.State, Message: message, Trace: []fsa.Transition{}})
+		}
+	}
+	return diagnostics
+}
+
+// Describes the operation made by the given transition (e.g "the send on 'jobs'" or "the spawn of 'worker'")
+func deadOperation(t fsa.Transition) string {
+	switch t.Move {
+	case fsa.Send:
+		return fmt.Sprintf("the send on '%s'", t.Label)
+	case fsa.Recv:
+		return fmt.Sprintf("the receive on '%s'", t.Label)
+	default:
+		return fmt.Sprintf("the spawn of '%s'", t.Label)
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the detection of the dead communication code
+package diagnostics
+
+import (
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+)
+
+func TestFindDeadCode(t *testing.T) {
+	functions := map[string]meta.FuncMetadata{
+		"worker": {Name: "worker", DeadCode: []meta.DeadOperation{
+			{State: 3, Transition: fsa.Transition{Move: fsa.Send, Label: "done"}, Position: "main.go:9:3"},
+		}},
+		"main": {Name: "main", DeadCode: []meta.DeadOperation{
+			{State: 5, Transition: fsa.Transition{Move: fsa.Spawn, Label: "worker"}},
+		}},
+	}
+
+	issues := FindDeadCode(functions)
+	if len(issues) != 2 || issues[0].Kind != DeadCode || issues[0].State != 5 || issues[1].State != 3 {
+		t.Fatalf("expected a diagnostic for each dead operation (sorted by function), got %v", issues)
+	}
+	expected := "the send on 'done' in worker (main.go:9:3) is never executed, the function has already returned"
+	if issues[1].Message != expected {
+		t.Errorf("expected the message\n%s\ngot\n%s", expected, issues[1].Message)
+	}
+	if code := ExitCode(issues, map[Kind]bool{Deadlock: true}); code != 0 {
+		t.Errorf("expected the dead code not to fail the check unless requested, got %d", code)
+	}
+}
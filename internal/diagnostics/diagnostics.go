@@ -0,0 +1,84 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package diagnostics implements the checks that can be performed on the extracted Choreography
+// Automata (e.g deadlock detection). Each check returns a list of Diagnostic that describes
+// the issue found and where (in which state of the automaton) it has been found.
+//
+package diagnostics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+)
+
+const (
+	// Diagnostic kind enum
+	Deadlock       Kind = "deadlock"
+	Cycle          Kind = "cycle"
+	NonTermination Kind = "non-termination"
+	Leak           Kind = "leak"
+	Violation      Kind = "assertion"
+	Nonconformance Kind = "nonconformance"
+	DeadCode       Kind = "dead-code"
+	Orphan         Kind = "orphan"
+)
+
+// Type alias to abstract the Diagnostic Kind enum
+type Kind string
+
+// ----------------------------------------------------------------------------
+// Diagnostic
+
+// A Diagnostic represents a single issue found by a check on an automaton
+//
+// The Diagnostic has a Kind that identifies the check that generated it, the id of the
+// state in which the issue has been found and a human readable message describing it.
+// When available the Trace is a counterexample: the shortest sequence of transitions
+// that leads from the initial state of the automaton to the state of the issue
+type Diagnostic struct {
+	Kind    Kind             // The kind of issue found (deadlock, cycle, ...)
+	State   int              // The state of the automaton in which the issue has been found
+	Message string           // An explicative message about the issue
+	Trace   []fsa.Transition // The transitions that lead to the state of the issue (if any)
+}
+
+// Converts the Diagnostic struct to a general pourpose string format.
+// The trace (if any) is listed in the following lines, one transition per line
+func (d Diagnostic) String() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "[%s] state %d: %s", d.Kind, d.State, d.Message)
+	for i, t := range d.Trace {
+		fmt.Fprintf(&builder, "\n  %d. %s", i+1, t)
+	}
+	return builder.String()
+}
+
+// ----------------------------------------------------------------------------
+// Checks
+
+// Searches the given Choreography Automata for deadlocks, that is non final states
+// from which no transition is available (no participant can make progress anymore).
+// The diagnostics returned are sorted by state id
+func FindDeadlocks(choreography *fsa.FSA) []Diagnostic {
+	hasOutgoing := make(map[int]bool)
+	choreography.ForEachTransition(func(from, _ int, _ fsa.Transition) {
+		hasOutgoing[from] = true
+	})
+
+	diagnostics := []Diagnostic{}
+	choreography.ForEachState(func(id int) {
+		if !hasOutgoing[id] && !choreography.IsFinalState(id) {
+			message := "no interaction is possible but not every participant has terminated"
+			trace := choreography.ShortestPath(choreography.InitialState(), id)
+			diagnostics = append(diagnostics, Diagnostic{Kind: Deadlock, State: id, Message: message, Trace: trace})
+		}
+	})
+
+	sort.Slice(diagnostics, func(i, j int) bool { return diagnostics[i].State < diagnostics[j].State })
+	return diagnostics
+}
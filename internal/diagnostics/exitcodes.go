@@ -0,0 +1,66 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package diagnostics implements the checks that can be performed on the extracted Choreography
+// Automata (e.g deadlock detection). Each check returns a list of Diagnostic that describes
+// the issue found and where (in which state of the automaton) it has been found.
+//
+package diagnostics
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ----------------------------------------------------------------------------
+// Exit codes
+
+// The exit code of each Kind, every one is a distinct bit so that the code of a run with issues of many
+// kinds is their bitwise OR (e.g 2|8 = 10 for deadlocks and leaks) and a CI pipeline can tell them apart.
+// The code 1 is left to the errors that stop the execution (an invalid input, a missing file, ...)
+var exitCodes = map[Kind]int{
+	Deadlock:       2,
+	NonTermination: 4,
+	Leak:           8,
+	Cycle:          16,
+	Violation:      32,
+	Nonconformance: 64,
+	DeadCode:       128,
+	Orphan:         256,
+}
+
+// The kinds that make a check fail when no other one is requested, the cycles and the dead code are listed but
+// aren't issues (the latter concerns the source code, not the protocol)
+var DefaultFailOn = []Kind{Deadlock, NonTermination, Leak, Violation, Nonconformance, Orphan}
+
+// Returns the exit code assigned to the given Kind (see exitCodes), 0 for an unknown one
+func (k Kind) ExitCode() int {
+	return exitCodes[k]
+}
+
+// Parses the given Kind names (e.g "deadlock,leak" split on commas) in the set of kinds that make
+// a check fail. An error listing the valid names is returned when one of them isn't a known Kind
+func ParseKinds(names []string) (map[Kind]bool, error) {
+	kinds := make(map[Kind]bool)
+	for _, name := range names {
+		kind := Kind(strings.TrimSpace(name))
+		if _, isKnown := exitCodes[kind]; !isKnown {
+			return nil, fmt.Errorf("unknown diagnostic kind '%s' (expected %s, %s, %s, %s, %s, %s, %s or %s)", name, Deadlock, NonTermination, Leak, Cycle, Violation, Nonconformance, DeadCode, Orphan)
+		}
+		kinds[kind] = true
+	}
+	return kinds, nil
+}
+
+// Returns the exit code of a check that found the given diagnostics, that is the bitwise OR of the codes
+// of their kinds. Only the kinds in failOn are taken into account, the code is 0 when none of them is found
+func ExitCode(diagnostics []Diagnostic, failOn map[Kind]bool) int {
+	code := 0
+	for _, diagnostic := range diagnostics {
+		if failOn[diagnostic.Kind] {
+			code |= diagnostic.Kind.ExitCode()
+		}
+	}
+	return code
+}
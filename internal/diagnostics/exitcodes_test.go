@@ -0,0 +1,38 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the exit codes of the checks
+package diagnostics
+
+import "testing"
+
+func TestExitCode(t *testing.T) {
+	issues := []Diagnostic{{Kind: Deadlock}, {Kind: Leak}, {Kind: Leak}, {Kind: Cycle}}
+
+	defaults := map[Kind]bool{}
+	for _, kind := range DefaultFailOn {
+		defaults[kind] = true
+	}
+	if code := ExitCode(issues, defaults); code != Deadlock.ExitCode()|Leak.ExitCode() {
+		t.Errorf("expected the codes of deadlocks and leaks to be combined, got %d", code)
+	}
+
+	failOn, err := ParseKinds([]string{"leak", " cycle"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := ExitCode(issues, failOn); code != Leak.ExitCode()|Cycle.ExitCode() {
+		t.Errorf("expected only the requested kinds to be taken into account, got %d", code)
+	}
+	if code := ExitCode([]Diagnostic{{Kind: Deadlock}}, failOn); code != 0 {
+		t.Errorf("expected a deadlock not to fail the check, got %d", code)
+	}
+
+	if failOn, err := ParseKinds([]string{"orphan"}); err != nil || ExitCode([]Diagnostic{{Kind: Orphan}}, failOn) != 256 {
+		t.Errorf("expected the orphan messages to have their own exit code, got %v (%v)", failOn, err)
+	}
+	if _, err := ParseKinds([]string{"lost"}); err == nil {
+		t.Errorf("expected an error for an unknown kind")
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package diagnostics implements the checks that can be performed on the extracted Choreography
+// Automata (e.g deadlock detection). Each check returns a list of Diagnostic that describes
+// the issue found and where (in which state of the automaton) it has been found.
+//
+package diagnostics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+// Searches for the Goroutines leaked when the program exits, that is the participants that are still in the
+// middle of their protocol (started but not in a final state) in a configuration where the "main" Goroutine
+// has reached a final state of its local view. Since the program terminates together with the "main" Goroutine
+// the leaked ones are abandoned while waiting on their pending operations, the latter are listed in the message.
+// A single diagnostic is returned for each leaked participant, with the trace to the first configuration found
+func FindLeaks(choreography *fsa.FSA, localViews map[string]*transforms.GoroutineFSA) []Diagnostic {
+	main, isMainKnown := localViews[transforms.MainName]
+	if !isMainKnown {
+		return []Diagnostic{}
+	}
+
+	participants := make([]string, 0, len(localViews))
+	for name := range localViews {
+		participants = append(participants, name)
+	}
+	sort.Strings(participants)
+	mainIndex := sort.SearchStrings(participants, transforms.MainName)
+
+	configs, _ := replayInteractions(choreography, localViews, participants)
+	reported := make(map[string]bool)
+	diagnostics := []Diagnostic{}
+
+	// The configurations are in breadth-first order, so the first one found is the closest to the initial one
+	for i, config := range configs {
+		if !main.Automaton.IsFinalState(config.locals[mainIndex]) {
+			continue
+		}
+
+		for p, name := range participants {
+			automaton := localViews[name].Automaton
+			// The environment is never leaked, it's always available to emit its events
+			if reported[name] || name == transforms.EnvironmentName || config.locals[p] == notStarted || automaton.IsFinalState(config.locals[p]) {
+				continue
+			}
+
+			message := fmt.Sprintf("%s is still running when %s exits (blocked on %s)", name, transforms.MainName, pendingOperations(automaton, config.locals[p]))
+			diagnostics = append(diagnostics, Diagnostic{Kind: Leak, State: config.global, Message: message, Trace: traceTo(configs, i)})
+			reported[name] = true
+		}
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool { return diagnostics[i].State < diagnostics[j].State })
+	return diagnostics
+}
+
+// Returns a human readable list of the operations available from the given local state (e.g "Send done, Recv ch")
+func pendingOperations(automaton *fsa.FSA, state int) string {
+	operations := []string{}
+	automaton.ForEachTransition(func(from, _ int, t fsa.Transition) {
+		if from == state {
+			operations = append(operations, fmt.Sprintf("%s %s", t.Move, t.Label))
+		}
+	})
+
+	if len(operations) == 0 {
+		return "nothing"
+	}
+	return strings.Join(operations, ", ")
+}
@@ -0,0 +1,42 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the detection of the Goroutines leaked when the "main" Goroutine exits
+package diagnostics
+
+import (
+	"testing"
+
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+// Runs the whole pipeline on the given example and returns the leak diagnostics
+func leaks(t *testing.T, example string) []Diagnostic {
+	t.Helper()
+	fileMetadata := meta.ExtractMetadata("../../example/"+example, meta.NoTrace)
+	localViews := transforms.ExtractGoroutineFSA(fileMetadata)
+	for _, lView := range localViews {
+		lView.Automaton = transforms.SubsetConstruction(lView.Automaton)
+	}
+	return FindLeaks(transforms.LocalViewsComposition(localViews), localViews)
+}
+
+func TestFindLeaks(t *testing.T) {
+	// When the timeout expires main exits while the responder is still waiting to send the response
+	issues := leaks(t, "SelectTimeout.go")
+	expected := "main/slowResponder@SelectTimeout.go:21#1 is still running when main exits (blocked on Send response)"
+	if len(issues) != 1 || issues[0].Kind != Leak || issues[0].Message != expected {
+		t.Fatalf("expected the responder to be reported, got %v", issues)
+	}
+
+	// The default branch of the select lets main exit without receiving from the responders
+	if issues := leaks(t, "SimpleExchange.go"); len(issues) != 2 {
+		t.Errorf("expected both responders to be reported, got %v", issues)
+	}
+
+	if issues := leaks(t, "PingPong.go"); len(issues) != 0 {
+		t.Errorf("expected no leaked Goroutine, got %v", issues)
+	}
+}
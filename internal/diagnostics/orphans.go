@@ -0,0 +1,154 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package diagnostics implements the checks that can be performed on the extracted Choreography
+// Automata (e.g deadlock detection). Each check returns a list of Diagnostic that describes
+// the issue found and where (in which state of the automaton) it has been found.
+//
+package diagnostics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+// Searches for the orphan messages, that is the ones that are never received: either they're sent on a channel
+// from which no participant ever receives (no receive can match the send, so on an unbuffered channel the sender
+// is stuck as well) or they're left in the buffer of a channel once the system can't make progress anymore (in a
+// final state or in a deadlock of the Choreography Automata). The sends of the environment aren't orphans since
+// the events it emits can be ignored. A single diagnostic is returned for each participant sending on a channel
+// without receivers and for each channel with messages left in its buffer, with the trace to the first state found
+func FindOrphans(choreography *fsa.FSA, localViews map[string]*transforms.GoroutineFSA) []Diagnostic {
+	diagnostics := append(unmatchedSends(choreography, localViews), undeliveredMessages(choreography)...)
+	sort.SliceStable(diagnostics, func(i, j int) bool { return diagnostics[i].State < diagnostics[j].State })
+	return diagnostics
+}
+
+// Returns the diagnostics of the sends on a channel without receivers, each one is reported in the first
+// configuration (see replayInteractions) where the sender is ready to make it
+func unmatchedSends(choreography *fsa.FSA, localViews map[string]*transforms.GoroutineFSA) []Diagnostic {
+	participants := make([]string, 0, len(localViews))
+	received := make(map[string]bool)
+	for name, lView := range localViews {
+		participants = append(participants, name)
+		lView.Automaton.ForEachTransition(func(_, _ int, t fsa.Transition) {
+			received[t.Label] = received[t.Label] || t.Move == fsa.Recv
+		})
+	}
+	sort.Strings(participants)
+
+	configs, _ := replayInteractions(choreography, localViews, participants)
+	diagnostics := []Diagnostic{}
+	for p, name := range participants {
+		if name == transforms.EnvironmentName || name == transforms.OthersName {
+			continue
+		}
+
+		// The local states from which the participant sends on each channel without receivers
+		senders, channels := make(map[string]map[int]bool), []string{}
+		localViews[name].Automaton.ForEachTransition(func(from, _ int, t fsa.Transition) {
+			channel, _ := t.Payload.(meta.ChanMetadata)
+			if t.Move != fsa.Send || received[t.Label] || channel.Environment {
+				return
+			}
+			if _, exist := senders[t.Label]; !exist {
+				senders[t.Label] = make(map[int]bool)
+				channels = append(channels, t.Label)
+			}
+			senders[t.Label][from] = true
+		})
+		sort.Strings(channels)
+
+		// The configurations are in breadth-first order, so the first one found is the closest to the initial one
+		for _, channel := range channels {
+			for i, config := range configs {
+				if config.locals[p] != notStarted && senders[channel][config.locals[p]] {
+					message := fmt.Sprintf("%s sends on %s but no participant ever receives from it", name, channel)
+					diagnostics = append(diagnostics, Diagnostic{Kind: Orphan, State: config.global, Message: message, Trace: traceTo(configs, i)})
+					break
+				}
+			}
+		}
+	}
+	return diagnostics
+}
+
+// Returns the diagnostics of the messages left in the buffers: the states of the Choreography Automata are visited
+// in breadth-first order keeping the senders of the messages in each buffer (a send in the buffer appends its
+// sender, a delivery removes the oldest one) and the final states, as well as the ones without interactions, that
+// are reached with some message still in a buffer are reported (only the first one for each channel)
+func undeliveredMessages(choreography *fsa.FSA) []Diagnostic {
+	outgoing := make(map[int][]transforms.Interaction)
+	targets := make(map[int][]int)
+	choreography.ForEachTransition(func(from, to int, t fsa.Transition) {
+		if interaction, isInteraction := t.Payload.(transforms.Interaction); isInteraction {
+			outgoing[from] = append(outgoing[from], interaction)
+			targets[from] = append(targets[from], to)
+		}
+	})
+
+	start := choreography.InitialState()
+	buffers := map[int]map[string][]string{start: {}}
+	queue, reported := []int{start}, make(map[string]bool)
+	diagnostics := []Diagnostic{}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		if len(outgoing[current]) == 0 || choreography.IsFinalState(current) {
+			channels := make([]string, 0, len(buffers[current]))
+			for channel := range buffers[current] {
+				channels = append(channels, channel)
+			}
+			sort.Strings(channels)
+
+			for _, channel := range channels {
+				if senders := buffers[current][channel]; len(senders) > 0 && !reported[channel] {
+					message := fmt.Sprintf("%d message(s) sent on %s by %s left in the buffer, never received", len(senders), channel, strings.Join(distinct(senders), ", "))
+					trace := choreography.ShortestPath(start, current)
+					diagnostics = append(diagnostics, Diagnostic{Kind: Orphan, State: current, Message: message, Trace: trace})
+					reported[channel] = true
+				}
+			}
+		}
+
+		for i, interaction := range outgoing[current] {
+			next := targets[current][i]
+			if _, isVisited := buffers[next]; isVisited {
+				continue
+			}
+
+			updated := make(map[string][]string, len(buffers[current]))
+			for channel, senders := range buffers[current] {
+				updated[channel] = senders
+			}
+			if channel := interaction.Channel.Name; interaction.IsBufferedSend() {
+				updated[channel] = append(append([]string{}, updated[channel]...), interaction.From)
+			} else if interaction.IsBuffered() && len(updated[channel]) > 0 {
+				updated[channel] = updated[channel][1:]
+			}
+			buffers[next] = updated
+			queue = append(queue, next)
+		}
+	}
+	return diagnostics
+}
+
+// Returns the given names without duplicates, in the order of their first occurrence
+func distinct(names []string) []string {
+	seen, unique := make(map[string]bool), []string{}
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			unique = append(unique, name)
+		}
+	}
+	return unique
+}
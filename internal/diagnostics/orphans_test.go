@@ -0,0 +1,73 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the detection of the messages that are never received
+package diagnostics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+// Runs the whole pipeline on the Go file at the given path and returns the orphan messages found
+func orphans(t *testing.T, path string) []Diagnostic {
+	t.Helper()
+	localViews := transforms.ExtractGoroutineFSA(meta.ExtractMetadata(path, meta.NoTrace))
+	for _, lView := range localViews {
+		lView.Automaton = transforms.SubsetConstruction(lView.Automaton)
+	}
+	return FindOrphans(transforms.LocalViewsComposition(localViews), localViews)
+}
+
+// A notifier sends on a channel no one receives from, while main exits leaving a message in a buffer
+const orphansSource = `package main
+
+func notifier(events chan int) {
+	events <- 1
+}
+
+func main() {
+	events, queue := make(chan int), make(chan int, 2)
+	go notifier(events)
+	queue <- 1
+	queue <- 2
+	<-queue
+}
+`
+
+func TestFindOrphans(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orphans.go")
+	if err := os.WriteFile(path, []byte(orphansSource), 0664); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := map[string]bool{}
+	for _, issue := range orphans(t, path) {
+		if issue.Kind != Orphan || len(issue.Trace) == 0 {
+			t.Errorf("expected an orphan message with its trace, got %v", issue)
+		}
+		messages[issue.Message] = true
+	}
+	expected := []string{
+		"main/notifier@orphans.go:9#1 sends on events but no participant ever receives from it",
+		"1 message(s) sent on queue by main left in the buffer, never received",
+	}
+	for _, message := range expected {
+		if !messages[message] {
+			t.Errorf("expected the orphan '%s', got %v", message, messages)
+		}
+	}
+	if len(messages) != len(expected) {
+		t.Errorf("expected %d orphan messages, got %v", len(expected), messages)
+	}
+
+	// The ball is always received by the other player
+	if issues := orphans(t, "../../example/PingPong.go"); len(issues) != 0 {
+		t.Errorf("expected no orphan message, got %v", issues)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package diagnostics implements the checks that can be performed on the extracted Choreography
+// Automata (e.g deadlock detection). Each check returns a list of Diagnostic that describes
+// the issue found and where (in which state of the automaton) it has been found.
+//
+package diagnostics
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+// The local state of a participant that hasn't been spawned yet
+const notStarted = -1
+
+// A configuration of the system: the state of the Choreography Automata together with the local state
+// of every participant (in the same order of the sorted participants), reached during the replay
+type configuration struct {
+	global  int            // The state of the Choreography Automata
+	locals  []int          // The local state of each participant
+	parent  int            // The index of the configuration it has been reached from (-1 for the initial one)
+	through fsa.Transition // The transition of the Choreography Automata taken from the parent
+	partial bool           // Some interaction of the Choreography Automata can't be replayed from here
+}
+
+// Searches for the participants that can never terminate, that is they never reach a final state of their
+// local view. The interactions of the Choreography Automata are replayed on the local views, so that the
+// local state of every participant is known in each reachable configuration of the system: if a participant
+// never reaches a final state in any of them it can never terminate, else if from some configuration no final
+// state can be reached anymore it may not terminate. In both cases this usually means a leaked Goroutine.
+// The composition doesn't always agree with the local views, a configuration from which some interaction
+// can't be replayed (as well as one in a final state of the Choreography Automata, where every participant
+// started has terminated) is assumed to allow every participant to terminate so that only sure issues are reported.
+// A single diagnostic is returned for each participant, with the trace to the first configuration found
+func FindNonTerminating(choreography *fsa.FSA, localViews map[string]*transforms.GoroutineFSA) []Diagnostic {
+	participants := make([]string, 0, len(localViews))
+	for name := range localViews {
+		participants = append(participants, name)
+	}
+	sort.Strings(participants)
+
+	configs, successors := replayInteractions(choreography, localViews, participants)
+	predecessors := make(map[int][]int)
+	for from, nexts := range successors {
+		for _, to := range nexts {
+			predecessors[to] = append(predecessors[to], from)
+		}
+	}
+
+	diagnostics := []Diagnostic{}
+	for p, name := range participants {
+		automaton := localViews[name].Automaton
+
+		// Visits backward the configurations from the ones where the participant is in a final state
+		canTerminate := make(map[int]bool)
+		queue := []int{}
+		for i, config := range configs {
+			if config.partial || choreography.IsFinalState(config.global) || (config.locals[p] != notStarted && automaton.IsFinalState(config.locals[p])) {
+				canTerminate[i] = true
+				queue = append(queue, i)
+			}
+		}
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			for _, previous := range predecessors[current] {
+				if !canTerminate[previous] {
+					canTerminate[previous] = true
+					queue = append(queue, previous)
+				}
+			}
+		}
+
+		// The configurations are in breadth-first order, so the first one found is the closest to the initial one
+		for i, config := range configs {
+			if config.locals[p] == notStarted || canTerminate[i] {
+				continue
+			}
+
+			message := fmt.Sprintf("%s may never reach a final state from here (leaked Goroutine?)", name)
+			if !reachesFinal(configs, p, automaton) {
+				message = fmt.Sprintf("%s never reaches a final state (leaked Goroutine?)", name)
+			}
+			diagnostics = append(diagnostics, Diagnostic{Kind: NonTermination, State: config.global, Message: message, Trace: traceTo(configs, i)})
+			break
+		}
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool { return diagnostics[i].State < diagnostics[j].State })
+	return diagnostics
+}
+
+// Returns true if the participant with the given index is in a final state in one of the given configurations
+func reachesFinal(configs []configuration, p int, automaton *fsa.FSA) bool {
+	for _, config := range configs {
+		if config.locals[p] != notStarted && automaton.IsFinalState(config.locals[p]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Visits in breadth-first order the configurations of the system reachable from the initial one (only the
+// "main" Goroutine is started) replaying every interaction of the Choreography Automata on the local views of
+// the participants involved. The interactions that can't be replayed from the current local states are skipped.
+// Returns the configurations found and, for each one of them, the indexes of the configurations that follow it
+func replayInteractions(choreography *fsa.FSA, localViews map[string]*transforms.GoroutineFSA, participants []string) ([]configuration, map[int][]int) {
+	indexOf := make(map[string]int, len(participants))
+	initial := configuration{global: choreography.InitialState(), locals: make([]int, len(participants)), parent: -1}
+	for i, name := range participants {
+		indexOf[name] = i
+		initial.locals[i] = notStarted
+		// The main Goroutine and the environment are the only participants running since the beginning
+		if name == transforms.EnvironmentName || name == transforms.MainName {
+			initial.locals[i] = localViews[name].Automaton.InitialState()
+		}
+	}
+
+	outgoing := make(map[int][]fsa.Transition)
+	targets := make(map[int][]int)
+	choreography.ForEachTransition(func(from, to int, t fsa.Transition) {
+		outgoing[from] = append(outgoing[from], t)
+		targets[from] = append(targets[from], to)
+	})
+
+	configs := []configuration{initial}
+	seen := map[string]int{configKey(initial): 0}
+	successors := make(map[int][]int)
+
+	for current := 0; current < len(configs); current++ {
+		config := configs[current]
+
+		for i, t := range outgoing[config.global] {
+			interaction, isInteraction := t.Payload.(transforms.Interaction)
+			if !isInteraction {
+				continue
+			}
+
+			locals, isReplayed := replayInteraction(interaction, config.locals, localViews, indexOf)
+			if !isReplayed {
+				configs[current].partial = true
+				continue
+			}
+
+			next := configuration{global: targets[config.global][i], locals: locals, parent: current, through: t}
+			nextIndex, exist := seen[configKey(next)]
+			if !exist {
+				nextIndex = len(configs)
+				seen[configKey(next)] = nextIndex
+				configs = append(configs, next)
+			}
+			successors[current] = append(successors[current], nextIndex)
+		}
+	}
+
+	return configs, successors
+}
+
+// Replays the given interaction on the local states of the participants involved (see Interaction.LocalMoves): a
+// spawn moves the spawner (and starts the spawned one) while a message exchange moves both the sender and the receiver,
+// unless it's buffered. Returns the updated local states or false if one of the participants can't make the move
+// required from its current local state
+func replayInteraction(interaction transforms.Interaction, locals []int, localViews map[string]*transforms.GoroutineFSA, indexOf map[string]int) ([]int, bool) {
+	updated := append([]int{}, locals...)
+
+	for _, move := range interaction.LocalMoves() {
+		p, isKnown := indexOf[move.Participant]
+		if !isKnown || locals[p] == notStarted {
+			return nil, false
+		}
+		next, isMoved := localMove(localViews[move.Participant].Automaton, locals[p], move.Move, move.Label)
+		if !isMoved {
+			return nil, false
+		}
+		updated[p] = next
+	}
+
+	if interaction.IsSpawn() {
+		spawned, isKnown := indexOf[interaction.To]
+		if !isKnown {
+			return nil, false
+		}
+		updated[spawned] = localViews[interaction.To].Automaton.InitialState()
+	}
+	return updated, true
+}
+
+// Returns the state reached from the given one with a transition of the given Move and Label (the local
+// views are deterministic so there's at most one), the second return value is false if there's none
+func localMove(automaton *fsa.FSA, from int, move fsa.MoveKind, label string) (int, bool) {
+	target, isMoved := 0, false
+	automaton.ForEachTransition(func(start, end int, t fsa.Transition) {
+		if !isMoved && start == from && t.Move == move && t.Label == label {
+			target, isMoved = end, true
+		}
+	})
+	return target, isMoved
+}
+
+// Encodes the given configuration so that it can be used as map key
+func configKey(config configuration) string {
+	return fmt.Sprint(config.global, config.locals)
+}
+
+// Returns the transitions of the Choreography Automata that lead from the initial configuration to the given one
+func traceTo(configs []configuration, index int) []fsa.Transition {
+	trace := []fsa.Transition{}
+	for current := index; configs[current].parent != -1; current = configs[current].parent {
+		trace = append([]fsa.Transition{configs[current].through}, trace...)
+	}
+	return trace
+}
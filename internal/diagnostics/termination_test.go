@@ -0,0 +1,102 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the termination analysis of the participants of the Choreography Automata
+package diagnostics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+// Runs the whole pipeline on the given example and returns the termination diagnostics
+func nonTerminating(t *testing.T, example string) []Diagnostic {
+	t.Helper()
+	return nonTerminatingAt(t, "../../example/"+example)
+}
+
+// Runs the whole pipeline on the Go file at the given path and returns the termination diagnostics
+func nonTerminatingAt(t *testing.T, path string) []Diagnostic {
+	t.Helper()
+	fileMetadata := meta.ExtractMetadata(path, meta.NoTrace)
+	localViews := transforms.ExtractGoroutineFSA(fileMetadata)
+	for _, lView := range localViews {
+		lView.Automaton = transforms.SubsetConstruction(lView.Automaton)
+	}
+	return FindNonTerminating(transforms.LocalViewsComposition(localViews), localViews)
+}
+
+func TestFindNonTerminating(t *testing.T) {
+	// When the timeout expires the responder is left blocked on its send
+	issues := nonTerminating(t, "SelectTimeout.go")
+	expected := "main/slowResponder@SelectTimeout.go:21#1 may never reach a final state from here (leaked Goroutine?)"
+	if len(issues) != 1 || issues[0].Kind != NonTermination || issues[0].Message != expected {
+		t.Fatalf("expected the responder to be reported, got %v", issues)
+	}
+	if trace := issues[0].Trace; len(trace) != 2 || trace[1].Label != "environment → main: time.After<time.Time>" {
+		t.Errorf("expected the trace to end with the timeout, got %v", trace)
+	}
+
+	if issues := nonTerminating(t, "SimpleExchange.go"); len(issues) != 0 {
+		t.Errorf("expected every participant to terminate, got %v", issues)
+	}
+}
+
+// Three workers taking a single job each, main waits for all of them
+const oneShotWorkersSource = `package main
+
+func worker(jobs chan int, done chan bool) {
+	<-jobs
+	done <- true
+}
+
+func main() {
+	jobs, done := make(chan int), make(chan bool)
+	go worker(jobs, done)
+	go worker(jobs, done)
+	go worker(jobs, done)
+	jobs <- 1
+	jobs <- 2
+	jobs <- 3
+	<-done
+	<-done
+	<-done
+}
+`
+
+func TestFindNonTerminatingManyWorkers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "workers.go")
+	if err := os.WriteFile(path, []byte(oneShotWorkersSource), 0664); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each worker terminates once it has reported back, as main does once every worker has
+	if issues := nonTerminatingAt(t, path); len(issues) != 0 {
+		t.Errorf("expected main and the workers to terminate, got %v", issues)
+	}
+}
+
+func TestFindNonTerminatingFinalState(t *testing.T) {
+	spawn := fsa.Transition{Move: fsa.Spawn, Label: "w"}
+	mainView, workerView := fsa.New(), fsa.New()
+	mainView.SetFinalState(mainView.AddTransition(0, mainView.AddState(), spawn))
+	workerView.SetFinalState(workerView.AddTransition(0, workerView.AddState(), fsa.Transition{Move: fsa.Send, Label: "ch"}))
+	localViews := map[string]*transforms.GoroutineFSA{
+		transforms.MainName: {Name: transforms.MainName, FuncMetadata: meta.FuncMetadata{Automaton: mainView}},
+		"w":                 {Name: "w", FuncMetadata: meta.FuncMetadata{Automaton: workerView}},
+	}
+
+	// The choreography says that the system has terminated once w is spawned, so it isn't reported
+	choreography := fsa.New()
+	choreography.SetFinalState(choreography.AddTransition(0, choreography.AddState(),
+		fsa.Transition{Move: fsa.Empty, Label: "main △ w", Payload: transforms.Interaction{From: transforms.MainName, To: "w"}}))
+	if issues := FindNonTerminating(choreography, localViews); len(issues) != 0 {
+		t.Errorf("expected no participant to be reported in a final state of the choreography, got %v", issues)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package logging implements a simple leveled logger used by every Choreia module.
+// By default the logger is silent so that the library calls don't have any side effect,
+// the final user (e.g the CLI) can opt in the desired verbosity level with SetLevel()
+//
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+const (
+	// Logging levels enum, each level includes all the previous ones
+	Silent  Level = iota // No message is logged
+	Error                // Only unrecoverable or unexpected issues are logged
+	Warning              // Recoverable issues are logged as well
+	Info                 // General progress information about the pipeline stages
+	Debug                // Detailed information about each transformation decision
+)
+
+// Type alias to abstract the logging Level enum
+type Level int
+
+var (
+	currentLevel = Silent
+	logger       = log.New(os.Stderr, "[Choreia] ", log.Ltime)
+)
+
+// Converts the Level to a short string representation used as prefix in each message
+func (l Level) String() string {
+	switch l {
+	case Error:
+		return "ERROR"
+	case Warning:
+		return "WARN"
+	case Info:
+		return "INFO"
+	case Debug:
+		return "DEBUG"
+	default:
+		return "SILENT"
+	}
+}
+
+// Sets the logging level, all the messages with a higher level will be discarded
+func SetLevel(level Level) {
+	currentLevel = level
+}
+
+// Returns the current logging level
+func GetLevel() Level {
+	return currentLevel
+}
+
+// Redirects the log messages to the given writer (default is os.Stderr)
+func SetOutput(w io.Writer) {
+	logger.SetOutput(w)
+}
+
+// Returns true if a message with the given level will be actually logged
+func Enabled(level Level) bool {
+	return level != Silent && level <= currentLevel
+}
+
+// Logs a message with the given level, formatting it with the given arguments
+func Logf(level Level, format string, args ...interface{}) {
+	if !Enabled(level) {
+		return
+	}
+	logger.Printf("%s %s", level, fmt.Sprintf(format, args...))
+}
+
+// Logs a message with Error level, the execution is not stopped
+func Errorf(format string, args ...interface{}) { Logf(Error, format, args...) }
+
+// Logs a message with Warning level
+func Warnf(format string, args ...interface{}) { Logf(Warning, format, args...) }
+
+// Logs a message with Info level
+func Infof(format string, args ...interface{}) { Logf(Info, format, args...) }
+
+// Logs a message with Debug level
+func Debugf(format string, args ...interface{}) { Logf(Debug, format, args...) }
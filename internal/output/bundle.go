@@ -0,0 +1,209 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package output manages the directory where the results of Choreia are saved. The results are laid out
+// in a fixed structure (the local views in their own folder, the global view and the JSON data at the top)
+// and the files are named after the participants (or functions, channels) they represent, with the names made
+// safe for the file system and for the tools that consume them (e.g. "main/worker@main.go:9#1" is saved as
+// "main_worker_main.go_9_1")
+//
+package output
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	"github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+// The extension of the analysis bundles, the input paths with this extension are opened as such
+const BundleExtension = ".choreia"
+
+// The document that describes the content of a bundle (see Bundle)
+const BundleDocumentKind DocumentKind = "bundle"
+
+const (
+	manifestEntry = "manifest.json" // The entry of the bundle with the BundleDocument
+	globalEntry   = "global.json"   // The entry of the bundle with the Choreography Automata
+)
+
+// ----------------------------------------------------------------------------
+// Bundle
+
+// A Bundle collects the results of a whole analysis (the metadata, the local views, the Choreography Automata and
+// the issues found in it) along with the options with which it has been run, so that they can be saved in a single
+// compressed archive and visualized (or exported) later on without running the analysis again. The archive is a zip
+// file of JSON documents in the versioned schema (see SchemaVersion):
+//
+//	manifest.json (see BundleDocument), meta.json, report.json, global.json
+//	localviews/<participant>.json
+type Bundle struct {
+	Args         []string                            // The options the analysis has been run with (e.g "--symmetry-reduction")
+	Meta         MetaDocument                        // The summary of the analyzed input
+	Report       ReportDocument                      // The issues found in the Choreography Automata
+	LocalViews   map[string]*transforms.GoroutineFSA // The local view of each participant
+	Choreography *fsa.FSA                            // The Choreography Automata, the global view
+}
+
+// A BundleDocument is the manifest of a bundle, the entries where each automaton is saved
+type BundleDocument struct {
+	documentHeader
+	Input        string             `json:"input"`        // The analyzed file (or package directory)
+	Args         []string           `json:"args"`         // The options the analysis has been run with
+	Global       string             `json:"global"`       // The entry of the Choreography Automata
+	Participants []ParticipantEntry `json:"participants"` // The participants, sorted by name
+}
+
+// A participant of the bundle: where its local view is saved and from which function it has been spawned
+type ParticipantEntry struct {
+	Name     string `json:"name"`     // The name of the participant (e.g "main/worker@main.go:9#1")
+	Function string `json:"function"` // The function that the participant executes (e.g "worker")
+	File     string `json:"file"`     // The entry of its local view (e.g "localviews/main_worker_main.go_9_1.json")
+}
+
+// Saves the given bundle in a (new) archive at the given path, the parent directory is created if needed
+func WriteBundle(path string, bundle Bundle) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0775); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	manifest := BundleDocument{
+		documentHeader: documentHeader{SchemaVersion, BundleDocumentKind},
+		Input:          bundle.Meta.Input,
+		Args:           bundle.Args,
+		Global:         globalEntry,
+		Participants:   []ParticipantEntry{},
+	}
+	if manifest.Args == nil {
+		manifest.Args = []string{}
+	}
+
+	// The participants are saved sorted by name, so that the entries given on collision are stable
+	names := make([]string, 0, len(bundle.LocalViews))
+	for name := range bundle.LocalViews {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	entries := map[string]interface{}{globalEntry: NewAutomatonDocument(bundle.Choreography)}
+	taken := map[string]bool{}
+	for _, name := range names {
+		entry := fmt.Sprintf("%s/%s.json", LocalViewsDir, Sanitize(name))
+		for i := 2; taken[strings.ToLower(entry)]; i++ {
+			entry = fmt.Sprintf("%s/%s-%d.json", LocalViewsDir, Sanitize(name), i)
+		}
+		taken[strings.ToLower(entry)] = true
+
+		lView := bundle.LocalViews[name]
+		manifest.Participants = append(manifest.Participants, ParticipantEntry{name, lView.FuncMetadata.Name, entry})
+		entries[entry] = NewAutomatonDocument(lView.Automaton)
+	}
+	entries[manifestEntry], entries[MetaFile], entries[ReportFile] = manifest, bundle.Meta, bundle.Report
+
+	// The manifest comes first, the other entries follow in lexicographic order
+	order := []string{}
+	for entry := range entries {
+		if entry != manifestEntry {
+			order = append(order, entry)
+		}
+	}
+	sort.Strings(order)
+	for _, entry := range append([]string{manifestEntry}, order...) {
+		writer, err := archive.Create(entry)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(entries[entry]); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// Opens the bundle saved at the given path, the documents of the older schema versions are supported as well
+func ReadBundle(path string) (*Bundle, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	readEntry := func(name string) ([]byte, error) {
+		file, err := archive.Open(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bundle %s: %s", path, err)
+		}
+		defer file.Close()
+		return io.ReadAll(file)
+	}
+	readAutomaton := func(name string) (*fsa.FSA, error) {
+		content, err := readEntry(name)
+		if err != nil {
+			return nil, err
+		}
+		document, err := DecodeAutomaton(content)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bundle entry %s: %s", name, err)
+		}
+		return document.Automaton(), nil
+	}
+
+	content, err := readEntry(manifestEntry)
+	if err != nil {
+		return nil, err
+	}
+	manifest := BundleDocument{}
+	if _, err := schemaVersion(content, BundleDocumentKind); err != nil {
+		return nil, fmt.Errorf("invalid bundle manifest: %s", err)
+	}
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid bundle manifest: %s", err)
+	}
+
+	bundle := &Bundle{Args: manifest.Args, LocalViews: make(map[string]*transforms.GoroutineFSA)}
+	if content, err = readEntry(MetaFile); err != nil {
+		return nil, err
+	}
+	if bundle.Meta, err = DecodeMeta(content); err != nil {
+		return nil, fmt.Errorf("invalid bundle entry %s: %s", MetaFile, err)
+	}
+	if content, err = readEntry(ReportFile); err != nil {
+		return nil, err
+	}
+	if bundle.Report, err = DecodeReport(content); err != nil {
+		return nil, fmt.Errorf("invalid bundle entry %s: %s", ReportFile, err)
+	}
+	if bundle.Choreography, err = readAutomaton(manifest.Global); err != nil {
+		return nil, err
+	}
+
+	// Only the name of the function is kept, the other metadata is needed only by the analysis
+	for _, participant := range manifest.Participants {
+		automaton, err := readAutomaton(participant.File)
+		if err != nil {
+			return nil, err
+		}
+		funcMeta := static_analysis.FuncMetadata{Name: participant.Function, Automaton: automaton}
+		bundle.LocalViews[participant.Name] = &transforms.GoroutineFSA{Name: participant.Name, FuncMetadata: funcMeta}
+	}
+
+	return bundle, nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the analysis bundles
+package output
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	"github.com/its-hmny/Choreia/internal/diagnostics"
+	"github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	fileMetadata := static_analysis.ExtractMetadata("../../example/PingPong.go", static_analysis.NoTrace)
+	localViews := transforms.ExtractGoroutineFSA(fileMetadata)
+	for _, lView := range localViews {
+		lView.Automaton = transforms.SubsetConstruction(lView.Automaton)
+	}
+	choreography := transforms.LocalViewsComposition(localViews)
+
+	path := filepath.Join(t.TempDir(), "results", "pingpong"+BundleExtension)
+	bundle := Bundle{
+		Args:         []string{"--symmetry-reduction"},
+		Meta:         NewMetaDocument("PingPong.go", fileMetadata),
+		Report:       NewReportDocument(diagnostics.FindDeadlocks(choreography)),
+		LocalViews:   localViews,
+		Choreography: choreography,
+	}
+	if err := WriteBundle(path, bundle); err != nil {
+		t.Fatal(err)
+	}
+	opened, err := ReadBundle(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(opened.Args, bundle.Args) || !reflect.DeepEqual(opened.Meta, bundle.Meta) || !reflect.DeepEqual(opened.Report, bundle.Report) {
+		t.Errorf("expected the documents to be preserved, got %+v", opened)
+	}
+	if textOf(t, opened.Choreography) != textOf(t, choreography) {
+		t.Errorf("expected the global view to be preserved, got\n%s", textOf(t, opened.Choreography))
+	}
+	if len(opened.LocalViews) != len(localViews) {
+		t.Fatalf("expected %d local views, got %d", len(localViews), len(opened.LocalViews))
+	}
+	for name, lView := range localViews {
+		openedView := opened.LocalViews[name]
+		if openedView == nil || openedView.FuncMetadata.Name != lView.FuncMetadata.Name || textOf(t, openedView.Automaton) != textOf(t, lView.Automaton) {
+			t.Errorf("expected the local view of %s to be preserved, got %+v", name, openedView)
+		}
+	}
+
+	// The interactions are restored as well, so that the queryable form is the same
+	if participants := transforms.NewChoreography(opened.Choreography, opened.LocalViews).Participants(); len(participants) != 3 {
+		t.Errorf("expected 3 participants in the reopened choreography, got %v", participants)
+	}
+}
+
+// Returns the canonical text form of the given automaton
+func textOf(t *testing.T, automaton *fsa.FSA) string {
+	text, err := automaton.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(text)
+}
@@ -0,0 +1,186 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package output manages the directory where the results of Choreia are saved. The results are laid out
+// in a fixed structure (the local views in their own folder, the global view and the JSON data at the top)
+// and the files are named after the participants (or functions, channels) they represent, with the names made
+// safe for the file system and for the tools that consume them (e.g. "main/worker@main.go:9#1" is saved as
+// "main_worker_main.go_9_1")
+//
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+// The document that describes the content of a regression fixture (see Fixture)
+const FixtureDocumentKind DocumentKind = "fixture"
+
+const (
+	FixtureFile  = "fixture.json" // The FixtureDocument, at the top of the fixture directory
+	ExpectedFile = "expected.txt" // The local views and the Choreography Automata in their canonical text form
+	SourceDir    = "source"       // The folder of the copy of the analyzed files
+	StubsDir     = "stubs"        // The folder of the copy of the stub models loaded
+	StagesDir    = "stages"       // The folder of the intermediate automata, one subfolder for each stage
+)
+
+// ----------------------------------------------------------------------------
+// Fixture
+
+// A Fixture is a self-contained record of an analysis, meant to be attached to a bug report and then checked in
+// as a regression test: the analyzed files and the stubs are copied in the fixture directory, so that the case
+// can be reproduced without the rest of the project, along with the options and the results of the analysis:
+//
+//	<root>/fixture.json (see FixtureDocument), meta.json, report.json, global.json
+//	<root>/expected.txt, <root>/localviews/<participant>.json
+//	<root>/source/<file>.go, <root>/stubs/<function>.fsa
+//	<root>/stages/<stage>/<name>.dot (and .json)
+//
+// The intermediate automata are saved by the pipeline itself as the stages are run (see StagesDir)
+type Fixture struct {
+	Input        string                              // The analyzed file (or package directory)
+	Sources      []string                            // The paths of the analyzed files
+	Stubs        []string                            // The paths of the stub models loaded
+	Args         []string                            // The options the analysis has been run with (e.g "--symmetry-reduction")
+	Meta         MetaDocument                        // The summary of the analyzed input
+	Report       ReportDocument                      // The issues found in the Choreography Automata
+	LocalViews   map[string]*transforms.GoroutineFSA // The local view of each participant
+	Choreography *fsa.FSA                            // The Choreography Automata, the global view
+}
+
+// A FixtureDocument is the manifest of a fixture, the paths are relative to the fixture directory
+type FixtureDocument struct {
+	documentHeader
+	Input    string   `json:"input"`    // The copy of the analyzed input (e.g "source/main.go", or "source" for a package)
+	Original string   `json:"original"` // The analyzed input, as given to the analysis
+	Args     []string `json:"args"`     // The options the analysis has been run with
+	Sources  []string `json:"sources"`  // The copies of the analyzed files (e.g "source/main.go")
+	Stubs    []string `json:"stubs"`    // The copies of the stub models (e.g "stubs/relay.Forward.fsa")
+	Expected string   `json:"expected"` // The file with the canonical text form of the results (see ExpectedText)
+}
+
+// Saves the given fixture in the root directory of the layout, that is expected to be already prepared (the
+// intermediate automata are saved in it while the analysis runs). The files are copied with their base name,
+// the stubs are named after the function they model and the files of a package share the same directory
+func (l *Layout) WriteFixture(fixture Fixture) error {
+	manifest := FixtureDocument{
+		documentHeader: documentHeader{SchemaVersion, FixtureDocumentKind},
+		Input:          SourceDir,
+		Original:       fixture.Input,
+		Args:           fixture.Args,
+		Sources:        []string{},
+		Stubs:          []string{},
+		Expected:       ExpectedFile,
+	}
+	if manifest.Args == nil {
+		manifest.Args = []string{}
+	}
+
+	var err error
+	if manifest.Sources, err = l.copyFiles(SourceDir, fixture.Sources); err != nil {
+		return err
+	}
+	if manifest.Stubs, err = l.copyFiles(StubsDir, fixture.Stubs); err != nil {
+		return err
+	}
+	if fStat, err := os.Stat(fixture.Input); err == nil && !fStat.IsDir() && len(manifest.Sources) == 1 {
+		manifest.Input = manifest.Sources[0]
+	}
+
+	// The participants are saved sorted by name, as done in the bundles
+	names := make([]string, 0, len(fixture.LocalViews))
+	for name := range fixture.LocalViews {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := WriteAutomaton(l.LocalView(name)+".json", fixture.LocalViews[name].Automaton); err != nil {
+			return err
+		}
+	}
+	if err := WriteAutomaton(l.Global()+".json", fixture.Choreography); err != nil {
+		return err
+	}
+
+	expected := ExpectedText(fixture.LocalViews, fixture.Choreography)
+	if err := os.WriteFile(filepath.Join(l.Root, ExpectedFile), expected, 0664); err != nil {
+		return err
+	}
+	if err := l.WriteReportDocument(fixture.Report); err != nil {
+		return err
+	}
+	if err := l.WriteMetaDocument(fixture.Meta); err != nil {
+		return err
+	}
+	return l.writeJSON(FixtureFile, manifest)
+}
+
+// Copies the given files in the given folder of the root directory and returns their paths relative to the
+// latter, the files are read at once since both the sources and the stubs are small
+func (l *Layout) copyFiles(folder string, paths []string) ([]string, error) {
+	copies := []string{}
+	if len(paths) == 0 {
+		return copies, nil
+	}
+	if err := os.MkdirAll(filepath.Join(l.Root, folder), 0775); err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		copyPath := filepath.Join(folder, filepath.Base(path))
+		if err := os.WriteFile(filepath.Join(l.Root, copyPath), content, 0664); err != nil {
+			return nil, err
+		}
+		copies = append(copies, filepath.ToSlash(copyPath))
+	}
+	return copies, nil
+}
+
+// Returns the canonical text form (see fsa.MarshalText) of the given local views, sorted by name, followed by the
+// one of the given Choreography Automata. It's the same form of the golden files of the example corpus, so that
+// the results of a fixture can be compared with the ones of a later version of Choreia by a plain diff
+func ExpectedText(localViews map[string]*transforms.GoroutineFSA, choreography *fsa.FSA) []byte {
+	var buffer bytes.Buffer
+
+	names := make([]string, 0, len(localViews))
+	for name := range localViews {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(&buffer, "== local view: %s\n%s\n", name, localViews[name].Automaton)
+	}
+	fmt.Fprintf(&buffer, "== global view\n%s", choreography)
+
+	return buffer.Bytes()
+}
+
+// Opens the manifest of the fixture in the given directory
+func ReadFixture(dirPath string) (FixtureDocument, error) {
+	manifest := FixtureDocument{}
+	content, err := os.ReadFile(filepath.Join(dirPath, FixtureFile))
+	if err != nil {
+		return manifest, err
+	}
+	if _, err := schemaVersion(content, FixtureDocumentKind); err != nil {
+		return manifest, fmt.Errorf("invalid fixture manifest: %s", err)
+	}
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return manifest, fmt.Errorf("invalid fixture manifest: %s", err)
+	}
+	return manifest, nil
+}
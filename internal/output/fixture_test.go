@@ -0,0 +1,87 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the regression fixtures
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/diagnostics"
+	"github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestFixture(t *testing.T) {
+	programPath := "../../example/PingPong.go"
+	fileMetadata := static_analysis.ExtractMetadata(programPath, static_analysis.NoTrace)
+	localViews := transforms.ExtractGoroutineFSA(fileMetadata)
+	for _, lView := range localViews {
+		lView.Automaton = transforms.SubsetConstruction(lView.Automaton)
+	}
+	choreography := transforms.LocalViewsComposition(localViews)
+
+	stubPath := filepath.Join(t.TempDir(), "relay.Forward.fsa")
+	if err := os.WriteFile(stubPath, []byte("final 1\n0 -> 1 Send \"$0\"\n"), 0664); err != nil {
+		t.Fatal(err)
+	}
+
+	layout := New(filepath.Join(t.TempDir(), "fixtures", "pingpong"))
+	if err := layout.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	fixture := Fixture{
+		Input:        programPath,
+		Sources:      []string{programPath},
+		Stubs:        []string{stubPath},
+		Args:         []string{"--symmetry-reduction", programPath},
+		Meta:         NewMetaDocument(programPath, fileMetadata),
+		Report:       NewReportDocument(diagnostics.FindDeadlocks(choreography)),
+		LocalViews:   localViews,
+		Choreography: choreography,
+	}
+	if err := layout.WriteFixture(fixture); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := ReadFixture(layout.Root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := FixtureDocument{
+		documentHeader: documentHeader{SchemaVersion, FixtureDocumentKind},
+		Input:          "source/PingPong.go",
+		Original:       programPath,
+		Args:           fixture.Args,
+		Sources:        []string{"source/PingPong.go"},
+		Stubs:          []string{"stubs/relay.Forward.fsa"},
+		Expected:       ExpectedFile,
+	}
+	if !reflect.DeepEqual(manifest, expected) {
+		t.Errorf("expected the manifest %+v, got %+v", expected, manifest)
+	}
+
+	// The copies are identical to the originals, the results are the same of the golden files
+	for original, copied := range map[string]string{programPath: "source/PingPong.go", stubPath: "stubs/relay.Forward.fsa"} {
+		want, _ := os.ReadFile(original)
+		if got, err := os.ReadFile(filepath.Join(layout.Root, copied)); err != nil || string(got) != string(want) {
+			t.Errorf("expected %s to be a copy of %s (%v)", copied, original, err)
+		}
+	}
+	golden, err := os.ReadFile("../transforms/testdata/golden/PingPong.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(filepath.Join(layout.Root, ExpectedFile)); err != nil || string(got) != string(golden) {
+		t.Errorf("expected the results in the form of the golden files, got\n%s", got)
+	}
+	for _, file := range []string{MetaFile, ReportFile, GlobalName + ".json"} {
+		if _, err := os.Stat(filepath.Join(layout.Root, file)); err != nil {
+			t.Errorf("expected %s in the fixture: %s", file, err)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package output manages the directory where the results of Choreia are saved. The results are laid out
+// in a fixed structure (the local views in their own folder, the global view and the JSON data at the top)
+// and the files are named after the participants (or functions, channels) they represent, with the names made
+// safe for the file system and for the tools that consume them (e.g. "main/worker@main.go:9#1" is saved as
+// "main_worker_main.go_9_1")
+//
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/its-hmny/Choreia/internal/diagnostics"
+	"github.com/its-hmny/Choreia/internal/static_analysis"
+)
+
+const (
+	LocalViewsDir = "localviews" // The folder of the local views (one file per participant)
+	FunctionsDir  = "functions"  // The folder of the ScopeAutomata (one file per function)
+	ChannelsDir   = "channels"   // The folder of the channel views (one file per channel)
+
+	GlobalName   = "global"   // The Choreography Automata (global view)
+	ProtocolName = "protocol" // The protocol view (see transforms.HideInternal)
+	OverviewName = "overview" // The system overview (see transforms.ExportSystemOverview)
+	SummaryName  = "summary"  // The human readable report of the analysis (see reports.Summary)
+
+	ReportFile = "report.json" // The issues found in the global view
+	MetaFile   = "meta.json"   // The summary of the analysis and the name of the participant saved in each file
+	TraceFile  = "trace.jsonl" // The trace of the analysis, when requested (see tracing.Level)
+)
+
+// Matches the characters that aren't safe in a file name (e.g spaces, parentheses and slashes)
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Returns the file name (without extension) derived from the given name, every sequence of unsafe
+// characters is replaced by a single underscore (e.g "network/http /orders#1" becomes "network_http_orders_1")
+func Sanitize(name string) string {
+	sanitized := strings.Trim(unsafeChars.ReplaceAllString(name, "_"), "_.")
+	if sanitized == "" {
+		return "_"
+	}
+	return sanitized
+}
+
+// ----------------------------------------------------------------------------
+// Layout
+
+// A Layout is the structure of a results directory:
+//
+//	<root>/localviews/<participant>.dot (and .svg)
+//	<root>/functions/<function>.dot, <root>/channels/<channel>.dot
+//	<root>/global.dot, <root>/protocol.dot, <root>/overview.dot
+//	<root>/summary.md (or .html)
+//	<root>/report.json, <root>/meta.json, <root>/trace.jsonl
+//
+// The names are sanitized (see Sanitize) and two names that become the same file in the same folder
+// (e.g "main/worker#1" and "main_worker_1", or two names that differ only by case) are kept apart with a numeric
+// suffix ("main_worker_1-2"), so the suffix depends on the order in which the paths are requested
+type Layout struct {
+	Root  string                       // The directory where the results are saved
+	files map[string]map[string]string // The file name given to each name, by folder
+	names map[string]map[string]string // The name saved in each file (lowercase), by folder
+}
+
+// Creates a new Layout rooted at the given directory, nothing is created on the file system
+func New(root string) *Layout {
+	return &Layout{Root: root, files: map[string]map[string]string{}, names: map[string]map[string]string{}}
+}
+
+// Creates from scratch the root directory, removing the previous content (if any)
+func (l *Layout) Prepare() error {
+	if err := os.RemoveAll(l.Root); err != nil {
+		return err
+	}
+	return os.MkdirAll(l.Root, 0775)
+}
+
+// Returns the path (without extension) of the local view of the given participant
+func (l *Layout) LocalView(participant string) string {
+	return l.path(LocalViewsDir, participant)
+}
+
+// Returns the path (without extension) of the ScopeAutomata of the given function
+func (l *Layout) Function(function string) string {
+	return l.path(FunctionsDir, function)
+}
+
+// Returns the path (without extension) of the view of the given channel
+func (l *Layout) Channel(channel string) string {
+	return l.path(ChannelsDir, channel)
+}
+
+// Returns the path (without extension) of the given automaton in a folder of its own (e.g a pipeline stage)
+func (l *Layout) InFolder(folder, name string) string {
+	return l.path(Sanitize(folder), name)
+}
+
+// Returns the path (without extension) of the Choreography Automata
+func (l *Layout) Global() string {
+	return filepath.Join(l.Root, GlobalName)
+}
+
+// Returns the path (without extension) of the protocol view
+func (l *Layout) Protocol() string {
+	return filepath.Join(l.Root, ProtocolName)
+}
+
+// Returns the path (without extension) of the system overview
+func (l *Layout) Overview() string {
+	return filepath.Join(l.Root, OverviewName)
+}
+
+// Returns the path (without extension) of the summary of the analysis
+func (l *Layout) Summary() string {
+	return filepath.Join(l.Root, SummaryName)
+}
+
+// Returns the path of the given name in the given folder, the latter is created if it doesn't exist yet.
+// The same name always gets the same path, while a file name already given to another name gets a suffix
+func (l *Layout) path(folder, name string) string {
+	if l.files[folder] == nil {
+		if err := os.MkdirAll(filepath.Join(l.Root, folder), 0775); err != nil {
+			log.Fatal(err)
+		}
+		l.files[folder], l.names[folder] = map[string]string{}, map[string]string{}
+	}
+
+	file, isKnown := l.files[folder][name]
+	if !isKnown {
+		// The file systems can be case insensitive, so the collisions are checked in lowercase
+		file = Sanitize(name)
+		for i := 2; l.names[folder][strings.ToLower(file)] != ""; i++ {
+			file = fmt.Sprintf("%s-%d", Sanitize(name), i)
+		}
+		l.files[folder][name], l.names[folder][strings.ToLower(file)] = file, name
+	}
+
+	return filepath.Join(l.Root, folder, file)
+}
+
+// Returns the name saved in each file given so far in the folders, indexed by path relative to the root (e.g
+// "localviews/main_0"). The files at the top of the root directory have a fixed name, so they aren't listed
+func (l *Layout) Files() map[string]string {
+	files := make(map[string]string)
+	for folder, names := range l.files {
+		for name, file := range names {
+			files[filepath.ToSlash(filepath.Join(folder, file))] = name
+		}
+	}
+	return files
+}
+
+// ----------------------------------------------------------------------------
+// JSON data
+
+// Saves the given diagnostics in the report file (see ReportDocument)
+func (l *Layout) WriteReport(issues []diagnostics.Diagnostic) error {
+	return l.WriteReportDocument(NewReportDocument(issues))
+}
+
+// Saves the given report document in the report file (e.g the one of an analysis bundle)
+func (l *Layout) WriteReportDocument(report ReportDocument) error {
+	return l.writeJSON(ReportFile, report)
+}
+
+// Saves in the meta file the summary of the given metadata along with the files given so far, then it's
+// meant to be called after the automata have been exported (see MetaDocument)
+func (l *Layout) WriteMeta(input string, metadata static_analysis.FileMetadata) error {
+	return l.WriteMetaDocument(NewMetaDocument(input, metadata))
+}
+
+// Saves in the meta file the given summary, whose files are replaced by the ones given so far in the layout
+func (l *Layout) WriteMetaDocument(summary MetaDocument) error {
+	summary.Files = l.Files()
+	return l.writeJSON(MetaFile, summary)
+}
+
+// Saves the given value as indented JSON in the given file of the root directory
+func (l *Layout) writeJSON(file string, value interface{}) error {
+	content, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(l.Root, file), append(content, '\n'), 0664)
+}
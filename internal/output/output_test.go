@@ -0,0 +1,94 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the naming of the files and the layout of the results directory
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/diagnostics"
+	"github.com/its-hmny/Choreia/internal/static_analysis"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := map[string]string{
+		"main (0)":         "main_0",
+		"http /orders (1)": "http_orders_1",
+		"main-func1 (2)":   "main-func1_2",
+		"worker (*)":       "worker",
+		"../..":            "_",
+	}
+	for name, expected := range tests {
+		if file := Sanitize(name); file != expected {
+			t.Errorf("expected '%s' to become '%s', got '%s'", name, expected, file)
+		}
+	}
+}
+
+func TestLayoutCollisions(t *testing.T) {
+	layout := New(t.TempDir())
+
+	first, second, third := layout.LocalView("main (0)"), layout.LocalView("main_0"), layout.LocalView("MAIN (0)")
+	if filepath.Base(first) != "main_0" || filepath.Base(second) != "main_0-2" || filepath.Base(third) != "MAIN_0-3" {
+		t.Errorf("expected distinct file names, got %s, %s and %s", first, second, third)
+	}
+	if again := layout.LocalView("main (0)"); again != first {
+		t.Errorf("expected the same name to get the same path, got %s and %s", first, again)
+	}
+	// The folders are independent from each other
+	if channel := layout.Channel("main_0"); filepath.Base(channel) != "main_0" {
+		t.Errorf("expected no collision with another folder, got %s", channel)
+	}
+	if _, err := os.Stat(filepath.Join(layout.Root, LocalViewsDir)); err != nil {
+		t.Errorf("expected the folder of the local views to be created: %s", err)
+	}
+}
+
+func TestWriteMeta(t *testing.T) {
+	layout := New(filepath.Join(t.TempDir(), "out"))
+	if err := layout.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+
+	layout.LocalView("main (0)")
+	metadata := static_analysis.FileMetadata{
+		GlobalChanMeta: map[string]static_analysis.ChanMetadata{"queue": {Name: "queue"}},
+		FunctionMeta:   map[string]static_analysis.FuncMetadata{"worker": {}, "main": {}},
+	}
+	if err := layout.WriteMeta("main.go", metadata); err != nil {
+		t.Fatal(err)
+	}
+	if err := layout.WriteReport([]diagnostics.Diagnostic{}); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(layout.Root, MetaFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary, err := DecodeMeta(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := MetaDocument{
+		documentHeader: documentHeader{SchemaVersion, MetaDocumentKind},
+		Input:          "main.go",
+		Functions:      []string{"main", "worker"},
+		GlobalChannels: []string{"queue"},
+		Files:          map[string]string{"localviews/main_0": "main (0)"},
+	}
+	if !reflect.DeepEqual(summary, expected) {
+		t.Errorf("expected the summary %+v, got %+v", expected, summary)
+	}
+
+	expectedReport := "{\n  \"schema_version\": 1,\n  \"kind\": \"report\",\n  \"diagnostics\": []\n}\n"
+	if report, err := os.ReadFile(filepath.Join(layout.Root, ReportFile)); err != nil || string(report) != expectedReport {
+		t.Errorf("expected an empty report, got '%s' (%v)", report, err)
+	}
+}
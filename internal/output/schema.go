@@ -0,0 +1,283 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package output manages the directory where the results of Choreia are saved. The results are laid out
+// in a fixed structure (the local views in their own folder, the global view and the JSON data at the top)
+// and the files are named after the participants (or functions, channels) they represent, with the names made
+// safe for the file system and for the tools that consume them (e.g. "main/worker@main.go:9#1" is saved as
+// "main_worker_main.go_9_1")
+//
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	"github.com/its-hmny/Choreia/internal/diagnostics"
+	"github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+// The version of the schema of the JSON documents written by Choreia. It's increased only when a field is
+// removed, renamed or changes meaning: the new (optional) fields are added without changing it. The documents
+// of every previous version can still be decoded (see DecodeMeta, DecodeReport and DecodeAutomaton)
+const SchemaVersion = 1
+
+const (
+	// DocumentKind enum
+	MetaDocumentKind      DocumentKind = "meta"      // The summary of the analysis (meta.json)
+	ReportDocumentKind    DocumentKind = "report"    // The issues found in the global view (report.json)
+	AutomatonDocumentKind DocumentKind = "automaton" // A local view or the global view (<name>.json)
+)
+
+// Type alias to abstract the DocumentKind enum, what a JSON document contains
+type DocumentKind string
+
+// ----------------------------------------------------------------------------
+// Schema
+
+// The header shared by every JSON document, the documents saved before the schema was versioned (version 0)
+// don't have one: the meta file had the same fields of the MetaDocument while the report was a bare list
+type documentHeader struct {
+	SchemaVersion int          `json:"schema_version"` // The version of the schema (see SchemaVersion)
+	Kind          DocumentKind `json:"kind"`           // What the document contains
+}
+
+// A MetaDocument is the content of the meta file: what has been analyzed and what has been saved where
+type MetaDocument struct {
+	documentHeader
+	Input          string            `json:"input"`           // The analyzed file (or package directory)
+	Functions      []string          `json:"functions"`       // The (sorted) functions declared in the input
+	GlobalChannels []string          `json:"global_channels"` // The (sorted) channels declared at the top level
+	Files          map[string]string `json:"files"`           // The name saved in each file (see Layout.Files)
+}
+
+// A ReportDocument is the content of the report file, the issues found by the checks on the global view
+type ReportDocument struct {
+	documentHeader
+	Diagnostics []DiagnosticEntry `json:"diagnostics"` // The issues, in the order in which they've been found
+}
+
+// The JSON form of a diagnostics.Diagnostic, the transitions of the trace are in textual form
+type DiagnosticEntry struct {
+	Kind    diagnostics.Kind `json:"kind"`    // The kind of the issue (e.g "deadlock")
+	State   int              `json:"state"`   // The state of the global view where the issue has been found
+	Message string           `json:"message"` // The description of the issue
+	Trace   []string         `json:"trace"`   // The interactions that lead from the initial state to State
+}
+
+// An AutomatonDocument is the JSON form of an exported automaton (a local view or the global view)
+type AutomatonDocument struct {
+	documentHeader
+	Initial     int               `json:"initial"`     // The id of the initial state
+	States      []int             `json:"states"`      // The (sorted) ids of the states
+	Final       []int             `json:"final"`       // The (sorted) ids of the final states
+	Transitions []TransitionEntry `json:"transitions"` // The transitions, sorted by source and destination
+}
+
+// The JSON form of a transition, the Interaction is given only for the ones of the global view while the Capacity only
+// for the ones made on a buffered channel (static_analysis.UnknownCapacity if not constant), in both views
+type TransitionEntry struct {
+	From        int               `json:"from"`                  // The source state
+	To          int               `json:"to"`                    // The destination state
+	Move        fsa.MoveKind      `json:"move"`                  // The kind of the transition (e.g "Send", "Spawn")
+	Label       string            `json:"label"`                 // The channel (or function, participant) of the move
+	Interaction *InteractionEntry `json:"interaction,omitempty"` // The participants involved (global view only)
+	Capacity    *int              `json:"capacity,omitempty"`    // The buffer size of the channel, for the buffered ones
+}
+
+// The JSON form of a transforms.Interaction, the Channel and its Type are empty for the spawns
+type InteractionEntry struct {
+	From    string `json:"from"`    // The participant that sends the message (or spawns the other)
+	To      string `json:"to"`      // The participant that receives the message (or that is spawned)
+	Channel string `json:"channel"` // The channel over which the message is exchanged
+	Type    string `json:"type"`    // The type of the message
+}
+
+// ----------------------------------------------------------------------------
+// Encoding
+
+// Returns the meta document of the given metadata, the files are left empty (see Layout.WriteMetaDocument)
+func NewMetaDocument(input string, metadata static_analysis.FileMetadata) MetaDocument {
+	summary := MetaDocument{
+		documentHeader: documentHeader{SchemaVersion, MetaDocumentKind},
+		Input:          input,
+		Functions:      []string{},
+		GlobalChannels: []string{},
+		Files:          map[string]string{},
+	}
+	for name := range metadata.FunctionMeta {
+		summary.Functions = append(summary.Functions, name)
+	}
+	for name := range metadata.GlobalChanMeta {
+		summary.GlobalChannels = append(summary.GlobalChannels, name)
+	}
+	sort.Strings(summary.Functions)
+	sort.Strings(summary.GlobalChannels)
+	return summary
+}
+
+// Returns the report document of the given diagnostics
+func NewReportDocument(issues []diagnostics.Diagnostic) ReportDocument {
+	report := ReportDocument{documentHeader{SchemaVersion, ReportDocumentKind}, make([]DiagnosticEntry, len(issues))}
+	for i, issue := range issues {
+		report.Diagnostics[i] = DiagnosticEntry{Kind: issue.Kind, State: issue.State, Message: issue.Message, Trace: []string{}}
+		for _, t := range issue.Trace {
+			report.Diagnostics[i].Trace = append(report.Diagnostics[i].Trace, t.String())
+		}
+	}
+	return report
+}
+
+// Returns the document of the given automaton, the interactions are taken from the payload of the transitions
+func NewAutomatonDocument(automaton *fsa.FSA) AutomatonDocument {
+	document := AutomatonDocument{
+		documentHeader: documentHeader{SchemaVersion, AutomatonDocumentKind},
+		Initial:        automaton.InitialState(),
+		States:         []int{},
+		Final:          []int{},
+		Transitions:    []TransitionEntry{},
+	}
+
+	automaton.ForEachState(func(id int) {
+		document.States = append(document.States, id)
+		if automaton.IsFinalState(id) {
+			document.Final = append(document.Final, id)
+		}
+	})
+	automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+		entry := TransitionEntry{From: from, To: to, Move: t.Move, Label: t.Label}
+		if interaction, isInteraction := t.Payload.(transforms.Interaction); isInteraction {
+			entry.Interaction = &InteractionEntry{interaction.From, interaction.To, interaction.Channel.Name, interaction.Channel.Type}
+			if interaction.IsBuffered() {
+				entry.Capacity = &interaction.Channel.Capacity
+			}
+		} else if channel, isChannel := t.Payload.(static_analysis.ChanMetadata); isChannel && channel.Async {
+			entry.Capacity = &channel.Capacity
+		}
+		document.Transitions = append(document.Transitions, entry)
+	})
+
+	sort.Ints(document.States)
+	sort.Ints(document.Final)
+	sort.SliceStable(document.Transitions, func(i, j int) bool {
+		if document.Transitions[i].From != document.Transitions[j].From {
+			return document.Transitions[i].From < document.Transitions[j].From
+		}
+		return document.Transitions[i].To < document.Transitions[j].To
+	})
+	return document
+}
+
+// Saves the document of the given automaton as indented JSON at the given path (e.g "<root>/global.json")
+func WriteAutomaton(path string, automaton *fsa.FSA) error {
+	content, err := json.MarshalIndent(NewAutomatonDocument(automaton), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0664)
+}
+
+// ----------------------------------------------------------------------------
+// Decoding
+
+// Returns the version of the schema of the given document, 0 for the ones saved before it was versioned.
+// An error is returned if the document is from a newer version of Choreia or it's of another kind
+func schemaVersion(data []byte, kind DocumentKind) (int, error) {
+	header := documentHeader{}
+	// The unversioned report is a list, it can't be decoded in the header
+	if err := json.Unmarshal(data, &header); err != nil {
+		if _, isList := err.(*json.UnmarshalTypeError); isList {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	if header.SchemaVersion > SchemaVersion {
+		return 0, fmt.Errorf("unsupported schema version %d (the latest known is %d)", header.SchemaVersion, SchemaVersion)
+	}
+	if header.SchemaVersion > 0 && header.Kind != kind {
+		return 0, fmt.Errorf("expected a %s document, got a %s one", kind, header.Kind)
+	}
+	return header.SchemaVersion, nil
+}
+
+// Decodes the given meta file of any schema version, the result is always on the latest one
+func DecodeMeta(data []byte) (MetaDocument, error) {
+	document := MetaDocument{}
+	if _, err := schemaVersion(data, MetaDocumentKind); err != nil {
+		return document, err
+	}
+	// The unversioned meta file has the same fields, only the header is missing
+	if err := json.Unmarshal(data, &document); err != nil {
+		return document, err
+	}
+	document.documentHeader = documentHeader{SchemaVersion, MetaDocumentKind}
+	return document, nil
+}
+
+// Decodes the given report file of any schema version, the result is always on the latest one
+func DecodeReport(data []byte) (ReportDocument, error) {
+	document := ReportDocument{}
+	version, err := schemaVersion(data, ReportDocumentKind)
+	if err != nil {
+		return document, err
+	}
+
+	// The unversioned report is the bare list of the diagnostics
+	if version == 0 {
+		err = json.Unmarshal(data, &document.Diagnostics)
+	} else {
+		err = json.Unmarshal(data, &document)
+	}
+	document.documentHeader = documentHeader{SchemaVersion, ReportDocumentKind}
+	return document, err
+}
+
+// Decodes the given automaton file, the automata have been saved as JSON since the first schema version
+func DecodeAutomaton(data []byte) (AutomatonDocument, error) {
+	document := AutomatonDocument{}
+	version, err := schemaVersion(data, AutomatonDocumentKind)
+	if err != nil {
+		return document, err
+	}
+	if version == 0 {
+		return document, fmt.Errorf("expected an %s document, got one without schema version", AutomatonDocumentKind)
+	}
+	err = json.Unmarshal(data, &document)
+	return document, err
+}
+
+// Rebuilds the automaton described by the document, the transitions of the global view get back their Interaction
+// payload while the Send/Recv ones get the (untyped) channel on which they're made, both along with its buffer size. The
+// automaton obtained can be exported and analyzed as the original one, but the other metadata of the channels is lost
+func (d AutomatonDocument) Automaton() *fsa.FSA {
+	automaton := fsa.New()
+	for _, entry := range d.Transitions {
+		t := fsa.Transition{Move: entry.Move, Label: entry.Label}
+		if entry.Interaction != nil {
+			channel := static_analysis.ChanMetadata{Name: entry.Interaction.Channel, Type: entry.Interaction.Type}
+			if entry.Capacity != nil {
+				channel.Async, channel.Capacity = true, *entry.Capacity
+			}
+			t.Payload = transforms.Interaction{From: entry.Interaction.From, To: entry.Interaction.To, Channel: channel}
+		} else if entry.Move == fsa.Send || entry.Move == fsa.Recv {
+			channel := static_analysis.ChanMetadata{Name: entry.Label}
+			if entry.Capacity != nil {
+				channel.Async, channel.Capacity = true, *entry.Capacity
+			}
+			t.Payload = channel
+		}
+		automaton.AddTransition(entry.From, entry.To, t)
+	}
+
+	automaton.SetInitialState(d.Initial)
+	for _, id := range d.Final {
+		automaton.SetFinalState(id)
+	}
+	return automaton
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the versioned schema of the JSON documents
+package output
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	"github.com/its-hmny/Choreia/internal/diagnostics"
+	"github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestDecodeLegacyDocuments(t *testing.T) {
+	// The documents saved before the schema was versioned
+	legacyMeta := `{"input": "main.go", "functions": ["main"], "global_channels": [], "files": {}}`
+	legacyReport := `[{"kind": "deadlock", "state": 3, "message": "stuck", "trace": ["main → w: ch"]}]`
+
+	metaDocument, err := DecodeMeta([]byte(legacyMeta))
+	if err != nil || metaDocument.SchemaVersion != SchemaVersion || metaDocument.Input != "main.go" {
+		t.Errorf("expected the legacy meta file to be decoded, got %+v (%v)", metaDocument, err)
+	}
+
+	report, err := DecodeReport([]byte(legacyReport))
+	expected := []DiagnosticEntry{{Kind: diagnostics.Deadlock, State: 3, Message: "stuck", Trace: []string{"main → w: ch"}}}
+	if err != nil || report.Kind != ReportDocumentKind || !reflect.DeepEqual(report.Diagnostics, expected) {
+		t.Errorf("expected the legacy report to be decoded, got %+v (%v)", report, err)
+	}
+}
+
+func TestDecodeUnsupportedDocuments(t *testing.T) {
+	if _, err := DecodeReport([]byte(`{"schema_version": 2, "kind": "report"}`)); err == nil {
+		t.Errorf("expected a newer schema version to be rejected")
+	}
+	if _, err := DecodeMeta([]byte(`{"schema_version": 1, "kind": "report"}`)); err == nil {
+		t.Errorf("expected a document of another kind to be rejected")
+	}
+	if _, err := DecodeAutomaton([]byte(`{"initial": 0}`)); err == nil {
+		t.Errorf("expected an unversioned automaton to be rejected")
+	}
+}
+
+func TestAutomatonDocument(t *testing.T) {
+	channel, capacity := static_analysis.ChanMetadata{Name: "ch", Type: "int", Async: true, Capacity: 3}, 3
+	automaton := fsa.New()
+	automaton.AddTransition(1, 2, fsa.Transition{Move: fsa.Send, Label: "ch", Payload: channel})
+	automaton.AddTransition(0, 1, fsa.Transition{Move: fsa.Eps, Label: "main → w: ch", Payload: transforms.Interaction{From: "main", To: "w", Channel: channel}})
+	automaton.SetFinalState(2)
+
+	content, err := json.Marshal(NewAutomatonDocument(automaton))
+	if err != nil {
+		t.Fatal(err)
+	}
+	document, err := DecodeAutomaton(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := AutomatonDocument{
+		documentHeader: documentHeader{SchemaVersion, AutomatonDocumentKind},
+		Initial:        0,
+		States:         []int{0, 1, 2},
+		Final:          []int{2},
+		Transitions: []TransitionEntry{
+			{From: 0, To: 1, Move: fsa.Eps, Label: "main → w: ch", Interaction: &InteractionEntry{"main", "w", "ch", "int"}, Capacity: &capacity},
+			{From: 1, To: 2, Move: fsa.Send, Label: "ch", Capacity: &capacity},
+		},
+	}
+	if !reflect.DeepEqual(document, expected) {
+		t.Errorf("expected the document %+v, got %+v", expected, document)
+	}
+
+	// The buffer size is restored along with the channel of the Send and of the Interaction
+	document.Automaton().ForEachTransition(func(_, _ int, tr fsa.Transition) {
+		if restored, isChannel := tr.Payload.(static_analysis.ChanMetadata); isChannel && (!restored.Async || restored.Capacity != 3) {
+			t.Errorf("expected the buffered channel to be restored, got %+v", restored)
+		}
+		if restored, isInteraction := tr.Payload.(transforms.Interaction); isInteraction && !restored.IsBuffered() {
+			t.Errorf("expected the buffered channel of the interaction to be restored, got %+v", restored)
+		}
+	})
+}
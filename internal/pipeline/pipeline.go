@@ -0,0 +1,190 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package pipeline exposes the analysis performed by Choreia as a sequence of named stages
+// (Parse -> Extract -> Project -> Determinize -> Compose -> Check -> Export). Every stage reads and
+// updates a shared set of Artifacts, after each one of them the registered hooks are invoked so that the
+// caller can observe or modify the intermediate results (e.g. inject a stub, drop a participant)
+//
+package pipeline
+
+import (
+	"log"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	"github.com/its-hmny/Choreia/internal/diagnostics"
+	"github.com/its-hmny/Choreia/internal/logging"
+	"github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+const (
+	// Stage enum
+	Parse       Stage = "parse"       // Parses the input source (Artifacts.Source)
+	Extract     Stage = "extract"     // Extracts the metadata and ScopeAutomata (Artifacts.Metadata)
+	Project     Stage = "project"     // Extracts the local view of each Goroutine (Artifacts.LocalViews)
+	Determinize Stage = "determinize" // Replaces each local view with its deterministic (and optionally minimal) version
+	Compose     Stage = "compose"     // Composes the local views in the global view (Artifacts.Choreography)
+	Check       Stage = "check"       // Checks the global view for issues (Artifacts.Diagnostics)
+	Export      Stage = "export"      // Hands over the artifacts to the Pipeline.Exporter (if any)
+)
+
+// The list of the stages, in execution order
+var Stages = []Stage{Parse, Extract, Project, Determinize, Compose, Check, Export}
+
+// Type alias to abstract the Stage enum, it identifies a step of the pipeline
+type Stage string
+
+// ----------------------------------------------------------------------------
+// Artifacts
+
+// The results of the stages executed so far, each stage fills (or replaces) its own field
+// while the following stages start from the content left by the previous ones and the hooks
+type Artifacts struct {
+	Source       static_analysis.Source              // The parsed files (Parse)
+	Metadata     static_analysis.FileMetadata        // The metadata and ScopeAutomata of the functions (Extract)
+	LocalViews   map[string]*transforms.GoroutineFSA // The local view of each Goroutine (Project, Determinize)
+	Choreography *fsa.FSA                            // The Choreography Automata, the global view (Compose)
+	Diagnostics  []diagnostics.Diagnostic            // The issues found in the global view (Check)
+}
+
+// Returns the queryable form of the Choreography Automata and of the local views composed in it (see
+// transforms.Choreography), it's meant to be called once the Compose stage has been executed
+func (a *Artifacts) Result() *transforms.Choreography {
+	return transforms.NewChoreography(a.Choreography, a.LocalViews)
+}
+
+// ----------------------------------------------------------------------------
+// Hooks
+
+// A Hook is invoked after every stage of the pipeline with the artifacts produced so far, that can be
+// modified in place: the changes made are seen by the following stages (and the other hooks)
+type Hook interface {
+	AfterStage(stage Stage, artifacts *Artifacts)
+}
+
+// The HookFunc type is an adapter to allow the use of ordinary functions as Hook
+type HookFunc func(stage Stage, artifacts *Artifacts)
+
+// Calls f(stage, artifacts)
+func (f HookFunc) AfterStage(stage Stage, artifacts *Artifacts) {
+	f(stage, artifacts)
+}
+
+// ----------------------------------------------------------------------------
+// Pipeline
+
+// A Pipeline holds the configuration of the analysis of a single input (a file or a package directory)
+//
+// The options mirror the ones available on the CLI, the hooks are invoked in registration order
+// after each stage. The Exporter is the implementation of the Export stage, without it the latter
+// only invokes the hooks: the artifacts are returned by Run anyway
+type Pipeline struct {
+	Input         string                         // The .go file (or package directory) to be analyzed
+	TraceMode     static_analysis.TraceMode      // The events of the analysis to be traced (see the tracing package)
+	ExcludeNil    bool                           // Excludes the operations on channels that may be nil from the local views
+	MinimizeLocal bool                           // Minimizes each local view once determinized, before the composition
+	Verbosity     transforms.LabelVerbosity      // How much information is shown in the labels of the global view
+	Symmetry      bool                           // Explores the composition up to a permutation of the symmetric Goroutines
+	Strategy      transforms.ExplorationStrategy // The order in which the composition visits the configurations of the system
+	Participants  []string                       // The patterns of the participants to be composed, all of them if empty
+	Assertions    []diagnostics.Assertion        // The properties checked on the global view along with the built-in checks
+	Exporter      func(artifacts *Artifacts)     // Saves the artifacts during the Export stage (optional)
+	hooks         []Hook                         // The hooks invoked after each stage
+}
+
+// Creates a new Pipeline for the given input with the default options (the same of the CLI)
+func New(input string) *Pipeline {
+	return &Pipeline{Input: input, TraceMode: static_analysis.NoTrace, Verbosity: transforms.FullLabels}
+}
+
+// Registers the given hook, it will be invoked after each stage (after the ones already registered)
+func (p *Pipeline) AddHook(hook Hook) *Pipeline {
+	p.hooks = append(p.hooks, hook)
+	return p
+}
+
+// Runs every stage from the first one up to the given one (included), returns the artifacts produced
+func (p *Pipeline) Run(last Stage) *Artifacts {
+	artifacts := &Artifacts{}
+
+	for _, stage := range Stages {
+		p.RunStage(stage, artifacts)
+		if stage == last {
+			return artifacts
+		}
+	}
+
+	log.Fatalf("Unknown pipeline stage '%s'\n", last)
+	return nil
+}
+
+// Runs only the given stage on the given artifacts and then the hooks, this way the stages can
+// be composed by the caller (e.g. to compose more times the same local views after a change)
+func (p *Pipeline) RunStage(stage Stage, artifacts *Artifacts) {
+	switch stage {
+	case Parse:
+		logging.Infof("Parsing %s", p.Input)
+		artifacts.Source = static_analysis.ParseSource(p.Input, p.TraceMode)
+	case Extract:
+		logging.Infof("Extracting metadata from %s", p.Input)
+		artifacts.Metadata = static_analysis.ExtractSourceMetadata(artifacts.Source)
+	case Project:
+		logging.Infof("Extracting the local views of %d function(s)", len(artifacts.Metadata.FunctionMeta))
+		artifacts.LocalViews = transforms.ExtractGoroutineFSA(artifacts.Metadata)
+		// Disables the select branches (and any other operation) on channels that may be nil
+		if p.ExcludeNil {
+			for _, lView := range artifacts.LocalViews {
+				lView.Automaton = transforms.ExcludeNilChannelOps(lView.Automaton)
+			}
+		}
+	case Determinize:
+		// The Goroutines spawned in the same way (e.g the workers of a pool) are determinized only once
+		cache := transforms.NewDeterminizationCache()
+		for _, lView := range artifacts.LocalViews {
+			lView.Automaton = cache.Determinize(lView, artifacts.LocalViews).Copy()
+		}
+		logging.Debugf("Determinized %d local view(s), %d reused", cache.Hits+cache.Misses, cache.Hits)
+		// The minimal local views are composed in a smaller (but bisimilar) Choreography Automata
+		if p.MinimizeLocal {
+			logging.Infof("Minimizing %d local view(s)", len(artifacts.LocalViews))
+			for _, lView := range artifacts.LocalViews {
+				lView.Automaton = transforms.MinimizeLocalView(lView.Automaton)
+			}
+		}
+	case Compose:
+		logging.Infof("Composing the Choreography Automata from %d local view(s), %s order", len(artifacts.LocalViews), p.Strategy)
+		// The local views of the Goroutines represented by others are dropped, as in the composition
+		if len(p.Participants) > 0 {
+			if p.Symmetry {
+				logging.Warnf("The symmetry reduction is ignored when only some participants are composed")
+			}
+			artifacts.Choreography, artifacts.LocalViews = transforms.PartialComposition(artifacts.LocalViews, p.Participants, p.Strategy)
+		} else if p.Symmetry {
+			artifacts.Choreography = transforms.SymmetricComposition(artifacts.LocalViews, p.Strategy)
+		} else {
+			initial := transforms.InitialConfiguration(artifacts.LocalViews)
+			artifacts.Choreography = transforms.LocalViewsCompositionWith(artifacts.LocalViews, initial, p.Strategy)
+		}
+		if p.Verbosity != transforms.FullLabels {
+			artifacts.Choreography = transforms.RelabelInteractions(artifacts.Choreography, p.Verbosity)
+		}
+	case Check:
+		artifacts.Diagnostics = diagnostics.FindDeadlocks(artifacts.Choreography)
+		artifacts.Diagnostics = append(artifacts.Diagnostics, diagnostics.FindNonTerminating(artifacts.Choreography, artifacts.LocalViews)...)
+		artifacts.Diagnostics = append(artifacts.Diagnostics, diagnostics.FindLeaks(artifacts.Choreography, artifacts.LocalViews)...)
+		artifacts.Diagnostics = append(artifacts.Diagnostics, diagnostics.FindOrphans(artifacts.Choreography, artifacts.LocalViews)...)
+		artifacts.Diagnostics = append(artifacts.Diagnostics, diagnostics.CheckAssertions(artifacts.Choreography, p.Assertions)...)
+	case Export:
+		if p.Exporter != nil {
+			p.Exporter(artifacts)
+		}
+	default:
+		log.Fatalf("Unknown pipeline stage '%s'\n", stage)
+	}
+
+	for _, hook := range p.hooks {
+		hook.AfterStage(stage, artifacts)
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the execution of the pipeline stages and of the hooks between them
+package pipeline_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	"github.com/its-hmny/Choreia/internal/pipeline"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestRunStages(t *testing.T) {
+	executed := []pipeline.Stage{}
+	analysis := pipeline.New("../../example/PingPong.go").AddHook(pipeline.HookFunc(func(stage pipeline.Stage, _ *pipeline.Artifacts) {
+		executed = append(executed, stage)
+	}))
+
+	artifacts := analysis.Run(pipeline.Compose)
+
+	expected := []pipeline.Stage{pipeline.Parse, pipeline.Extract, pipeline.Project, pipeline.Determinize, pipeline.Compose}
+	if !reflect.DeepEqual(executed, expected) {
+		t.Errorf("expected the stages %v to be executed, got %v", expected, executed)
+	}
+	if artifacts.Choreography == nil || len(artifacts.LocalViews) != 3 {
+		t.Errorf("expected the global view and 3 local views, got %d local views", len(artifacts.LocalViews))
+	}
+	if artifacts.Diagnostics != nil {
+		t.Error("the Check stage should not be executed")
+	}
+}
+
+func TestHookDropsParticipant(t *testing.T) {
+	analysis := pipeline.New("../../example/PingPong.go").AddHook(pipeline.HookFunc(func(stage pipeline.Stage, artifacts *pipeline.Artifacts) {
+		if stage == pipeline.Project {
+			delete(artifacts.LocalViews, "main/player@PingPong.go:21#1")
+		}
+	}))
+
+	artifacts := analysis.Run(pipeline.Compose)
+
+	artifacts.Choreography.ForEachTransition(func(_, _ int, tr fsa.Transition) {
+		interaction := tr.Payload.(transforms.Interaction)
+		if interaction.Channel.Name != "" && (interaction.From == "main/player@PingPong.go:21#1" || interaction.To == "main/player@PingPong.go:21#1") {
+			t.Errorf("unexpected interaction '%s' of the dropped participant", tr.Label)
+		}
+	})
+}
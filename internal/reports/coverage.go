@@ -0,0 +1,232 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package reports implements the summaries that can be computed on the extracted Choreography
+// Automata (e.g. which Goroutines communicate with which). Differently from the diagnostics the
+// reports don't look for issues, they provide an overview of the choreography in a format that
+// can be easily consumed by other tools (CSV, JSON)
+//
+package reports
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+// ----------------------------------------------------------------------------
+// ObservedEvent
+
+// An ObservedEvent is an interaction that has actually happened during a run of the program (e.g a test), as
+// logged by the instrumented code: a message exchanged on a channel or a spawn (with an empty Channel). Since the
+// program doesn't know the names given by the analysis to the Goroutines, the participants can be named after the
+// function they execute (e.g "worker") as well. The events of the same Run are in the order they happened
+type ObservedEvent struct {
+	Run     string `json:"run"`     // The run the event belongs to (e.g the name of the test)
+	From    string `json:"from"`    // The participant that sends the message (or spawns the Goroutine)
+	To      string `json:"to"`      // The participant that receives the message (or is spawned)
+	Channel string `json:"channel"` // The channel the message is exchanged on, empty for the spawns
+}
+
+// Returns a readable description of the event, in the same form of the labels of the choreography
+func (event ObservedEvent) String() string {
+	if event.Channel == "" {
+		return fmt.Sprintf("%s △ %s", event.From, event.To)
+	}
+	return fmt.Sprintf("%s → %s: %s", event.From, event.To, event.Channel)
+}
+
+// Reads the events logged during one or more runs, one JSON object per line (the empty lines are skipped)
+func ReadObservedEvents(r io.Reader) ([]ObservedEvent, error) {
+	events := []ObservedEvent{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		event := ObservedEvent{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("invalid event at line %d: %s", line, err)
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
+
+// ----------------------------------------------------------------------------
+// Coverage
+
+// An Edge is an interaction of the Choreography Automata, from one state to another
+type Edge struct {
+	From  int    `json:"from_state"` // The state the interaction starts from
+	To    int    `json:"to_state"`   // The state the interaction leads to
+	Label string `json:"label"`      // The label of the interaction (e.g "main → worker: jobs<int>")
+}
+
+// A Divergence is an event that can't be replayed on the Choreography Automata, the rest of its run is ignored
+type Divergence struct {
+	Run   string `json:"run"`   // The run of the event
+	Index int    `json:"index"` // The position of the event in its run (from 1)
+	Event string `json:"event"` // The description of the event (see ObservedEvent.String)
+}
+
+// A Coverage tells which interactions of the Choreography Automata have been exercised by the observed runs
+//
+// Each run is replayed from the initial state of the choreography, following the interactions that match its
+// events one after another (the other transitions, e.g the eps ones or the sends in a buffer, are taken freely). An event that matches more
+// interactions at once (e.g the same message of two Goroutines spawned from the same function) covers all of them.
+// A run that strays from the choreography (e.g because of a construct that the analysis doesn't model) is reported
+// as a Divergence, the interactions covered until then are kept
+type Coverage struct {
+	Runs        int          `json:"runs"`        // The number of runs replayed
+	Covered     int          `json:"covered"`     // The number of interactions exercised by at least one run
+	Total       int          `json:"total"`       // The number of interactions of the choreography
+	Percentage  float64      `json:"percentage"`  // The edge coverage, 100 if there are no interactions at all
+	Uncovered   []Edge       `json:"uncovered"`   // The interactions never exercised, sorted by state
+	Divergences []Divergence `json:"divergences"` // The events that couldn't be replayed, at most one per run
+}
+
+// Only the transitions that have a transforms.Interaction payload (the ones generated by the composition) count
+// as edges. The message sent in a buffer is observed once delivered, so the sends in a buffer don't count
+func isObservable(t fsa.Transition) bool {
+	interaction, isInteraction := t.Payload.(transforms.Interaction)
+	return isInteraction && !interaction.IsBufferedSend()
+}
+
+// Replays the given events on the given choreography and computes its edge coverage, only the observable
+// transitions (see isObservable) count as edges
+func NewCoverage(result *transforms.Choreography, events []ObservedEvent) Coverage {
+	functions := make(map[string]string)
+	for _, participant := range result.Participants() {
+		functions[participant.Name] = participant.Function
+	}
+	// An observed name matches a participant if it's its name or the name of the function it executes
+	matches := func(observed, participant string) bool {
+		return observed == participant || observed == functions[participant]
+	}
+
+	covered := make(map[Edge]bool)
+	result.Automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+		if isObservable(t) {
+			covered[Edge{from, to, t.Label}] = false
+		}
+	})
+
+	// The events are grouped by run, the runs keep the order in which they first appear
+	runs, runEvents := []string{}, make(map[string][]ObservedEvent)
+	for _, event := range events {
+		if _, isKnown := runEvents[event.Run]; !isKnown {
+			runs = append(runs, event.Run)
+		}
+		runEvents[event.Run] = append(runEvents[event.Run], event)
+	}
+
+	coverage := Coverage{Runs: len(runs), Uncovered: []Edge{}, Divergences: []Divergence{}}
+	for _, run := range runs {
+		current := interactionClosure(result.Automaton, []int{result.Automaton.InitialState()})
+		for i, event := range runEvents[run] {
+			next := []int{}
+			for _, state := range current {
+				result.Automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+					interaction, _ := t.Payload.(transforms.Interaction)
+					if from != state || !isObservable(t) || interaction.Channel.Name != event.Channel {
+						return
+					}
+					if matches(event.From, interaction.From) && matches(event.To, interaction.To) {
+						covered[Edge{from, to, t.Label}] = true
+						next = append(next, to)
+					}
+				})
+			}
+
+			if len(next) == 0 {
+				coverage.Divergences = append(coverage.Divergences, Divergence{run, i + 1, event.String()})
+				break
+			}
+			current = interactionClosure(result.Automaton, next)
+		}
+	}
+
+	for edge, isCovered := range covered {
+		if isCovered {
+			coverage.Covered++
+		} else {
+			coverage.Uncovered = append(coverage.Uncovered, edge)
+		}
+	}
+	coverage.Total, coverage.Percentage = len(covered), 100
+	if coverage.Total > 0 {
+		coverage.Percentage = float64(coverage.Covered) * 100 / float64(coverage.Total)
+	}
+
+	sort.Slice(coverage.Uncovered, func(i, j int) bool {
+		a, b := coverage.Uncovered[i], coverage.Uncovered[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.Label < b.Label
+	})
+
+	return coverage
+}
+
+// Returns the given states along with the ones reachable from them with the transitions that aren't observable
+func interactionClosure(choreography *fsa.FSA, states []int) []int {
+	closure, isVisited := []int{}, make(map[int]bool)
+	for len(states) > 0 {
+		state := states[0]
+		states = states[1:]
+		if isVisited[state] {
+			continue
+		}
+		isVisited[state] = true
+		closure = append(closure, state)
+
+		choreography.ForEachTransition(func(from, to int, t fsa.Transition) {
+			if from == state && !isObservable(t) {
+				states = append(states, to)
+			}
+		})
+	}
+	return closure
+}
+
+// Writes the coverage in a readable form: the edge coverage, then the uncovered interactions and the divergences
+func (coverage Coverage) WriteText(w io.Writer) error {
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "Edge coverage: %d/%d interactions (%.1f%%) over %d run(s)\n",
+		coverage.Covered, coverage.Total, coverage.Percentage, coverage.Runs)
+
+	if len(coverage.Uncovered) > 0 {
+		fmt.Fprintln(&buffer, "\nUncovered interactions:")
+		for _, edge := range coverage.Uncovered {
+			fmt.Fprintf(&buffer, "  %d -> %d: %s\n", edge.From, edge.To, edge.Label)
+		}
+	}
+	if len(coverage.Divergences) > 0 {
+		fmt.Fprintln(&buffer, "\nRuns that diverge from the choreography:")
+		for _, divergence := range coverage.Divergences {
+			fmt.Fprintf(&buffer, "  %s: event %d (%s) can't be replayed\n", divergence.Run, divergence.Index, divergence.Event)
+		}
+	}
+
+	_, err := w.Write(buffer.Bytes())
+	return err
+}
+
+// Writes the coverage in (indented) JSON format
+func (coverage Coverage) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(coverage)
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the edge coverage of the choreography, replaying the events of some hand-written runs
+package reports
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestCoverage(t *testing.T) {
+	interaction := func(from, to, channel string) fsa.Transition {
+		payload := transforms.Interaction{From: from, To: to, Channel: meta.ChanMetadata{Name: channel}}
+		return fsa.Transition{Move: fsa.Empty, Label: from + " → " + to + ": " + channel, Payload: payload}
+	}
+
+	// main spawns a worker, then either sends it a job (and gets the result) or stops it
+	const worker = "main/worker@main.go:9#1"
+	choreography := fsa.New()
+	choreography.AddTransition(0, 1, interaction("main", worker, ""))
+	choreography.AddTransition(1, 2, interaction("main", worker, "jobs"))
+	choreography.AddTransition(2, 3, interaction(worker, "main", "results"))
+	choreography.AddTransition(1, 4, interaction("main", worker, "quit"))
+	choreography.AddTransition(3, 5, fsa.Transition{Move: fsa.Eps, Label: "ε"})
+	choreography.AddTransition(5, 6, interaction("main", worker, "quit"))
+
+	localViews := map[string]*transforms.GoroutineFSA{
+		"main": {Name: "main", FuncMetadata: meta.FuncMetadata{Name: "main", Automaton: fsa.New()}},
+		worker: {Name: worker, FuncMetadata: meta.FuncMetadata{Name: "worker", Automaton: fsa.New()}},
+	}
+
+	// The worker is named after its function, the second run diverges after the spawn
+	log := `{"run": "TestJob", "from": "main", "to": "worker", "channel": ""}
+{"run": "TestJob", "from": "main", "to": "worker", "channel": "jobs"}
+{"run": "TestJob", "from": "worker", "to": "main", "channel": "results"}
+{"run": "TestJob", "from": "main", "to": "worker", "channel": "quit"}
+
+{"run": "TestLost", "from": "main", "to": "worker", "channel": ""}
+{"run": "TestLost", "from": "worker", "to": "main", "channel": "results"}
+`
+	events, err := ReadObservedEvents(strings.NewReader(log))
+	if err != nil || len(events) != 6 {
+		t.Fatalf("expected 6 events, got %v (%v)", events, err)
+	}
+
+	coverage := NewCoverage(transforms.NewChoreography(choreography, localViews), events)
+	if coverage.Runs != 2 || coverage.Covered != 4 || coverage.Total != 5 || coverage.Percentage != 80 {
+		t.Errorf("unexpected coverage: %+v", coverage)
+	}
+	if len(coverage.Uncovered) != 1 || coverage.Uncovered[0] != (Edge{1, 4, "main → " + worker + ": quit"}) {
+		t.Errorf("expected the direct quit to be uncovered, got %v", coverage.Uncovered)
+	}
+	if len(coverage.Divergences) != 1 || coverage.Divergences[0] != (Divergence{"TestLost", 2, "worker → main: results"}) {
+		t.Errorf("expected the second run to diverge, got %v", coverage.Divergences)
+	}
+
+	var buffer bytes.Buffer
+	if err := coverage.WriteText(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buffer.String(), "Edge coverage: 4/5 interactions (80.0%) over 2 run(s)\n") {
+		t.Errorf("unexpected text output:\n%s", buffer.String())
+	}
+
+	if _, err := ReadObservedEvents(strings.NewReader("{\"run\": 1}\n")); err == nil {
+		t.Error("expected an error for the malformed event")
+	}
+}
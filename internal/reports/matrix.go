@@ -0,0 +1,137 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package reports implements the summaries that can be computed on the extracted Choreography
+// Automata (e.g. which Goroutines communicate with which). Differently from the diagnostics the
+// reports don't look for issues, they provide an overview of the choreography in a format that
+// can be easily consumed by other tools (CSV, JSON)
+//
+package reports
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+// ----------------------------------------------------------------------------
+// InteractionMatrix
+
+// A MatrixEntry describes the messages sent by a participant (From) to another one (To)
+type MatrixEntry struct {
+	From     string   `json:"from"`     // The name of the local view that sends the messages
+	To       string   `json:"to"`       // The name of the local view that receives the messages
+	Channels []string `json:"channels"` // The (sorted) channels over which the messages are exchanged
+	Count    int      `json:"count"`    // The number of transitions of the choreography between the two
+}
+
+// An InteractionMatrix represents which Goroutines ever communicate with which others
+//
+// The matrix is indexed by the participants of the choreography (sorted by name), the cell of
+// row i and column j is the number of transitions in which Participants[i] sends to Participants[j].
+// Spawns aren't considered interactions but the spawned Goroutines are listed as participants anyway
+type InteractionMatrix struct {
+	Participants []string      `json:"participants"` // The (sorted) names of the local views
+	Counts       [][]int       `json:"counts"`       // The heatmap-ready matrix of the interactions
+	Entries      []MatrixEntry `json:"interactions"` // The non empty cells, sorted by From and To
+}
+
+// Computes the InteractionMatrix of the given Choreography Automata, only the transitions
+// that have a transforms.Interaction payload (the ones generated by the composition) are considered
+func NewInteractionMatrix(choreography *fsa.FSA) InteractionMatrix {
+	type pair struct{ from, to string }
+
+	participantSet := make(map[string]bool)
+	entries := make(map[pair]*MatrixEntry)
+	channels := make(map[pair]map[string]bool)
+
+	choreography.ForEachTransition(func(_, _ int, t fsa.Transition) {
+		interaction, isInteraction := t.Payload.(transforms.Interaction)
+		// The message sent in a buffer is counted once delivered, when the receiver is known
+		if !isInteraction || interaction.IsBufferedSend() {
+			return
+		}
+
+		participantSet[interaction.From], participantSet[interaction.To] = true, true
+		// Spawns don't carry a channel, they only contribute to the list of participants
+		if interaction.Channel.Name == "" {
+			return
+		}
+
+		key := pair{interaction.From, interaction.To}
+		if entries[key] == nil {
+			entries[key] = &MatrixEntry{From: key.from, To: key.to}
+			channels[key] = make(map[string]bool)
+		}
+		entries[key].Count++
+		channels[key][interaction.Channel.Name] = true
+	})
+
+	matrix := InteractionMatrix{Participants: []string{}, Entries: []MatrixEntry{}}
+	for name := range participantSet {
+		matrix.Participants = append(matrix.Participants, name)
+	}
+	sort.Strings(matrix.Participants)
+
+	index := make(map[string]int)
+	for i, name := range matrix.Participants {
+		index[name] = i
+		matrix.Counts = append(matrix.Counts, make([]int, len(matrix.Participants)))
+	}
+
+	for key, entry := range entries {
+		for channel := range channels[key] {
+			entry.Channels = append(entry.Channels, channel)
+		}
+		sort.Strings(entry.Channels)
+
+		matrix.Counts[index[key.from]][index[key.to]] = entry.Count
+		matrix.Entries = append(matrix.Entries, *entry)
+	}
+
+	sort.Slice(matrix.Entries, func(i, j int) bool {
+		if matrix.Entries[i].From != matrix.Entries[j].From {
+			return matrix.Entries[i].From < matrix.Entries[j].From
+		}
+		return matrix.Entries[i].To < matrix.Entries[j].To
+	})
+
+	return matrix
+}
+
+// Writes the matrix in CSV format: the header row and the first column contain the names of the
+// participants, every other cell contains the number of messages sent from the row to the column
+func (matrix InteractionMatrix) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(append([]string{"from/to"}, matrix.Participants...)); err != nil {
+		return err
+	}
+
+	for i, name := range matrix.Participants {
+		record := []string{name}
+		for _, count := range matrix.Counts[i] {
+			record = append(record, fmt.Sprint(count))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// Writes the matrix in (indented) JSON format, both the heatmap-ready
+// matrix and the list of the interactions (with their channels) are included
+func (matrix InteractionMatrix) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(matrix)
+}
@@ -0,0 +1,46 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the interaction matrix, computed on a small hand-written Choreography Automata
+package reports
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestInteractionMatrix(t *testing.T) {
+	interaction := func(from, to, channel string) fsa.Transition {
+		payload := transforms.Interaction{From: from, To: to, Channel: meta.ChanMetadata{Name: channel}}
+		return fsa.Transition{Move: fsa.Empty, Label: from + to + channel, Payload: payload}
+	}
+
+	choreography := fsa.New()
+	choreography.AddTransition(0, 1, interaction("main", "worker", ""))
+	choreography.AddTransition(1, 2, interaction("main", "worker", "jobs"))
+	choreography.AddTransition(2, 3, interaction("main", "worker", "quit"))
+	choreography.AddTransition(3, 4, interaction("worker", "main", "results"))
+
+	matrix := NewInteractionMatrix(choreography)
+
+	if len(matrix.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %v", matrix.Entries)
+	}
+	if entry := matrix.Entries[0]; entry.Count != 2 || len(entry.Channels) != 2 || entry.Channels[0] != "jobs" {
+		t.Errorf("unexpected entry main -> worker: %+v", entry)
+	}
+
+	var buffer bytes.Buffer
+	if err := matrix.WriteCSV(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	expected := "from/to,main,worker\nmain,0,2\nworker,1,0\n"
+	if buffer.String() != expected {
+		t.Errorf("unexpected CSV output:\n%s", buffer.String())
+	}
+}
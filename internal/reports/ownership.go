@@ -0,0 +1,124 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package reports implements the summaries that can be computed on the extracted Choreography
+// Automata (e.g. which Goroutines communicate with which). Differently from the diagnostics the
+// reports don't look for issues, they provide an overview of the choreography in a format that
+// can be easily consumed by other tools (CSV, JSON)
+//
+package reports
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+const (
+	// ChannelPattern enum
+	OneToOne   ChannelPattern = "one-to-one"   // A single sender and a single receiver
+	FanIn      ChannelPattern = "fan-in"       // Many senders and a single receiver
+	FanOut     ChannelPattern = "fan-out"      // A single sender and many receivers
+	ManyToMany ChannelPattern = "many-to-many" // Many senders and many receivers
+)
+
+// Type alias to abstract the ChannelPattern enum, how the participants share a channel
+type ChannelPattern string
+
+// ----------------------------------------------------------------------------
+// ChannelOwnership
+
+// A ChannelOwnership describes who uses a channel and in which direction. By the usual Go convention a channel
+// is owned by its only sender (the one that should close it), so the Owner is empty when there are many of them.
+// The participants that both send and receive on the channel are listed as Bidirectional: it usually means that
+// the channel is used to reply as well (or that a participant talks to itself), which is worth documenting
+type ChannelOwnership struct {
+	Channel       string         `json:"channel"`       // The name of the channel
+	Senders       []string       `json:"senders"`       // The (sorted) participants that only send on the channel
+	Receivers     []string       `json:"receivers"`     // The (sorted) participants that only receive from the channel
+	Bidirectional []string       `json:"bidirectional"` // The (sorted) participants that both send and receive
+	Owner         string         `json:"owner"`         // The only sender of the channel, if any
+	Pattern       ChannelPattern `json:"pattern"`       // How many participants send and receive on the channel
+}
+
+// Infers the ownership of each channel of the given choreography (sorted by name) from its interactions,
+// the participants that both send and receive count as a sender and as a receiver in the Pattern
+func NewChannelOwnership(result *transforms.Choreography) []ChannelOwnership {
+	ownership := []ChannelOwnership{}
+	for _, channel := range result.Channels() {
+		usage := ChannelOwnership{Channel: channel.Name, Senders: []string{}, Receivers: []string{}, Bidirectional: []string{}}
+
+		isReceiver := make(map[string]bool, len(channel.Receivers))
+		for _, receiver := range channel.Receivers {
+			isReceiver[receiver] = true
+		}
+		isSender := make(map[string]bool, len(channel.Senders))
+		for _, sender := range channel.Senders {
+			isSender[sender] = true
+			if isReceiver[sender] {
+				usage.Bidirectional = append(usage.Bidirectional, sender)
+			} else {
+				usage.Senders = append(usage.Senders, sender)
+			}
+		}
+		for _, receiver := range channel.Receivers {
+			if !isSender[receiver] {
+				usage.Receivers = append(usage.Receivers, receiver)
+			}
+		}
+
+		if len(channel.Senders) == 1 {
+			usage.Owner = channel.Senders[0]
+		}
+		switch manySenders, manyReceivers := len(channel.Senders) > 1, len(channel.Receivers) > 1; {
+		case manySenders && manyReceivers:
+			usage.Pattern = ManyToMany
+		case manySenders:
+			usage.Pattern = FanIn
+		case manyReceivers:
+			usage.Pattern = FanOut
+		default:
+			usage.Pattern = OneToOne
+		}
+		ownership = append(ownership, usage)
+	}
+	return ownership
+}
+
+// Returns a short description of the usage of the channel (e.g "fan-in", "one-to-one, bidirectional: main")
+func (co ChannelOwnership) Usage() string {
+	if len(co.Bidirectional) == 0 {
+		return string(co.Pattern)
+	}
+	return string(co.Pattern) + ", bidirectional: " + strings.Join(co.Bidirectional, ", ")
+}
+
+// Writes the ownership of the channels in CSV format, one row per channel with the participants joined by spaces
+func WriteOwnershipCSV(w io.Writer, ownership []ChannelOwnership) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write([]string{"channel", "senders", "receivers", "bidirectional", "owner", "pattern"}); err != nil {
+		return err
+	}
+	for _, usage := range ownership {
+		record := []string{usage.Channel, strings.Join(usage.Senders, " "), strings.Join(usage.Receivers, " "),
+			strings.Join(usage.Bidirectional, " "), usage.Owner, string(usage.Pattern)}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// Writes the ownership of the channels in (indented) JSON format
+func WriteOwnershipJSON(w io.Writer, ownership []ChannelOwnership) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(ownership)
+}
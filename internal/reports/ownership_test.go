@@ -0,0 +1,52 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the ownership of the channels, inferred on a small hand-written Choreography Automata
+package reports
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestChannelOwnership(t *testing.T) {
+	interaction := func(from, to, channel string) fsa.Transition {
+		payload := transforms.Interaction{From: from, To: to, Channel: meta.ChanMetadata{Name: channel}}
+		return fsa.Transition{Move: fsa.Empty, Label: from + to + channel, Payload: payload}
+	}
+
+	choreography := fsa.New()
+	choreography.AddTransition(0, 1, interaction("main", "a", "jobs"))
+	choreography.AddTransition(1, 2, interaction("main", "b", "jobs"))
+	choreography.AddTransition(2, 3, interaction("a", "main", "results"))
+	choreography.AddTransition(3, 4, interaction("b", "main", "results"))
+	choreography.AddTransition(4, 5, interaction("main", "a", "sync"))
+	choreography.AddTransition(5, 6, interaction("a", "main", "sync"))
+
+	ownership := NewChannelOwnership(transforms.NewChoreography(choreography, nil))
+	expected := []ChannelOwnership{
+		{Channel: "jobs", Senders: []string{"main"}, Receivers: []string{"a", "b"}, Bidirectional: []string{}, Owner: "main", Pattern: FanOut},
+		{Channel: "results", Senders: []string{"a", "b"}, Receivers: []string{"main"}, Bidirectional: []string{}, Pattern: FanIn},
+		{Channel: "sync", Senders: []string{}, Receivers: []string{}, Bidirectional: []string{"a", "main"}, Pattern: ManyToMany},
+	}
+	if !reflect.DeepEqual(ownership, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, ownership)
+	}
+	if usage := ownership[2].Usage(); usage != "many-to-many, bidirectional: a, main" {
+		t.Errorf("unexpected usage of sync: %s", usage)
+	}
+
+	var buffer bytes.Buffer
+	if err := WriteOwnershipCSV(&buffer, ownership[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "channel,senders,receivers,bidirectional,owner,pattern\njobs,main,a b,,main,fan-out\n"; buffer.String() != expected {
+		t.Errorf("unexpected CSV output:\n%s", buffer.String())
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package reports implements the summaries that can be computed on the extracted Choreography
+// Automata (e.g. which Goroutines communicate with which). Differently from the diagnostics the
+// reports don't look for issues, they provide an overview of the choreography in a format that
+// can be easily consumed by other tools (CSV, JSON)
+//
+package reports
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+// ----------------------------------------------------------------------------
+// SpawnTree
+
+// A SpawnNode is a Goroutine of the spawn tree, along with the way it has been spawned (see transforms.SpawnInfo)
+type SpawnNode struct {
+	Name     string       `json:"name"`     // The name of the local view (e.g "main/worker@main.go:9#1")
+	Function string       `json:"function"` // The function the Goroutine executes (the name itself for the others)
+	Parent   string       `json:"parent"`   // The participant that spawns it, empty for the roots (e.g "main")
+	Site     string       `json:"site"`     // The spawn site (e.g "main.go:9"), empty if unknown
+	Channels []ChannelArg `json:"channels"` // The channels passed to the Goroutine, in the order of the arguments
+	Children []*SpawnNode `json:"children"` // The Goroutines it spawns, sorted by name
+}
+
+// A channel passed to a spawned Goroutine, the argument of the function bound to a channel of the spawner
+type ChannelArg struct {
+	Parameter string `json:"parameter"` // The formal argument (e.g "jobs"), "$<offset>" for the external functions
+	Channel   string `json:"channel"`   // The channel of the spawner (e.g "tasks")
+}
+
+// A SpawnTree is the forest of the Goroutines spawned by each participant running from the start: main, and
+// the network or the runtime when modeled. It's computed from the local views alone, so that it can be inspected
+// without composing them (which can be expensive). The roots are sorted by name, with main always the first one
+type SpawnTree struct {
+	Roots []*SpawnNode `json:"roots"`
+}
+
+// Computes the SpawnTree of the given local views, a Goroutine whose spawner isn't among them is a root as well
+func NewSpawnTree(localViews map[string]*transforms.GoroutineFSA) SpawnTree {
+	nodes := make(map[string]*SpawnNode, len(localViews))
+	for name, lView := range localViews {
+		function := name
+		if lView.FuncMetadata.Name != "" {
+			function = lView.FuncMetadata.Name
+		}
+
+		node := &SpawnNode{Name: name, Function: function, Parent: lView.Spawn.Spawner, Site: lView.Spawn.Site}
+		node.Channels, node.Children = []ChannelArg{}, []*SpawnNode{}
+		for _, binding := range lView.Spawn.Bindings {
+			node.Channels = append(node.Channels, ChannelArg{binding.Parameter, binding.Channel})
+		}
+		nodes[name] = node
+	}
+
+	names := make([]string, 0, len(nodes))
+	for name := range nodes {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == transforms.MainName) != (names[j] == transforms.MainName) {
+			return names[i] == transforms.MainName
+		}
+		return names[i] < names[j]
+	})
+
+	tree := SpawnTree{Roots: []*SpawnNode{}}
+	for _, name := range names {
+		node := nodes[name]
+		if parent, isKnown := nodes[node.Parent]; isKnown && node.Parent != "" {
+			parent.Children = append(parent.Children, node)
+		} else {
+			tree.Roots = append(tree.Roots, node)
+		}
+	}
+	return tree
+}
+
+// Writes the tree in a readable form, one Goroutine per line (indented under its spawner) followed by the
+// spawn site and the channels passed to it (e.g "main/worker@main.go:9#1 (worker at main.go:9, jobs=tasks)")
+func (tree SpawnTree) WriteText(w io.Writer) error {
+	var builder strings.Builder
+
+	var write func(node *SpawnNode, prefix string, isLast, isRoot bool)
+	write = func(node *SpawnNode, prefix string, isLast, isRoot bool) {
+		details := []string{node.Function}
+		if node.Site != "" {
+			details[0] = fmt.Sprintf("%s at %s", node.Function, node.Site)
+		}
+		for _, channel := range node.Channels {
+			details = append(details, fmt.Sprintf("%s=%s", channel.Parameter, channel.Channel))
+		}
+
+		branch, indent := "├── ", "│   "
+		if isLast {
+			branch, indent = "└── ", "    "
+		}
+		if isRoot {
+			branch, indent = "", ""
+		}
+		fmt.Fprintf(&builder, "%s%s%s (%s)\n", prefix, branch, node.Name, strings.Join(details, ", "))
+
+		for i, child := range node.Children {
+			write(child, prefix+indent, i == len(node.Children)-1, false)
+		}
+	}
+
+	for _, root := range tree.Roots {
+		write(root, "", true, true)
+	}
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// Writes the tree in (indented) JSON format, each node with its children
+func (tree SpawnTree) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(tree)
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the spawn tree, computed on the local views extracted from a small program
+package reports
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestSpawnTree(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.go")
+	source := `package main
+func helper(out chan int) { out <- 1 }
+func worker(in, out chan int) {
+	<-in
+	go helper(out)
+}
+func main() {
+	tasks, results := make(chan int), make(chan int)
+	go worker(tasks, results)
+	tasks <- 1
+	<-results
+}
+`
+	if err := os.WriteFile(path, []byte(source), 0664); err != nil {
+		t.Fatal(err)
+	}
+
+	tree := NewSpawnTree(transforms.ExtractGoroutineFSA(meta.ExtractMetadata(path, meta.NoTrace)))
+	if len(tree.Roots) != 1 || tree.Roots[0].Name != "main" || len(tree.Roots[0].Children) != 1 {
+		t.Fatalf("expected main to be the only root, with a single child: %+v", tree.Roots)
+	}
+
+	worker := tree.Roots[0].Children[0]
+	if worker.Parent != "main" || worker.Function != "worker" || worker.Site != "main.go:9" || len(worker.Channels) != 2 {
+		t.Errorf("unexpected worker node: %+v", worker)
+	}
+	if worker.Channels[0] != (ChannelArg{"in", "tasks"}) || worker.Channels[1] != (ChannelArg{"out", "results"}) {
+		t.Errorf("expected the channels of main to be bound to the arguments of worker, got %v", worker.Channels)
+	}
+
+	var buffer bytes.Buffer
+	if err := tree.WriteText(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	expected := `main (main)
+└── main/worker@main.go:9#1 (worker at main.go:9, in=tasks, out=results)
+    └── main/worker/helper@main.go:5#1 (helper at main.go:5, out=results)
+`
+	if buffer.String() != expected {
+		t.Errorf("expected the tree\n%s\ngot\n%s", expected, buffer.String())
+	}
+}
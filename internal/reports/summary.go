@@ -0,0 +1,292 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package reports implements the summaries that can be computed on the extracted Choreography
+// Automata (e.g. which Goroutines communicate with which). Differently from the diagnostics the
+// reports don't look for issues, they provide an overview of the choreography in a format that
+// can be easily consumed by other tools (CSV, JSON)
+//
+package reports
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/its-hmny/Choreia/internal/diagnostics"
+	"github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+// ----------------------------------------------------------------------------
+// Summary
+
+// A Summary is the human readable report of a whole analysis, meant to be attached to a design document
+// or a pull request: the participants (with their spawn tree and channels), the Choreography Automata,
+// the issues found by the checks and the constructs of the source that the choreography doesn't cover.
+//
+// The channels are listed with their owner and usage pattern (see ChannelOwnership), while the Choreography
+// Automata is shown as the image at ImageFile (a path relative to the report) in Markdown. In HTML the ImageSVG
+// is inlined instead, so that a single file is enough. Without its image a format omits the section, the same
+// goes for the init functions of the package
+type Summary struct {
+	Input       string                             // The analyzed file (or package directory)
+	Result      *transforms.Choreography           // The result of the composition
+	InitFlow    []string                           // The init functions run (by main) before main, in order
+	Diagnostics []diagnostics.Diagnostic           // The issues found in the Choreography Automata
+	Unsupported *static_analysis.UnsupportedReport // The constructs that the analysis isn't able to model
+	ImageFile   string                             // The (relative) path of the image of the Choreography Automata
+	ImageSVG    []byte                             // The SVG image of the Choreography Automata
+}
+
+// The rows of the participants table, each participant with the channels it uses
+type participantRow struct {
+	transforms.Participant
+	Sends, Receives string
+}
+
+// Returns the rows of the participants table, the channels are listed in the columns of the sends and receives
+func (s Summary) participantRows() []participantRow {
+	sends, receives := make(map[string][]string), make(map[string][]string)
+	for _, channel := range s.Result.Channels() {
+		for _, sender := range channel.Senders {
+			sends[sender] = append(sends[sender], channel.Name)
+		}
+		for _, receiver := range channel.Receivers {
+			receives[receiver] = append(receives[receiver], channel.Name)
+		}
+	}
+
+	rows := []participantRow{}
+	for _, participant := range s.Result.Participants() {
+		rows = append(rows, participantRow{participant, strings.Join(sends[participant.Name], ", "), strings.Join(receives[participant.Name], ", ")})
+	}
+	return rows
+}
+
+// Returns the buffering of the given channel in a short form (e.g "unbuffered", "buffered (4)")
+func buffering(channel transforms.Channel) string {
+	switch {
+	case !channel.Async:
+		return "unbuffered"
+	case channel.Capacity == static_analysis.UnknownCapacity:
+		return "buffered (unknown)"
+	default:
+		return fmt.Sprintf("buffered (%d)", channel.Capacity)
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Markdown
+
+// Writes the summary as a Markdown document, the tables are in the GitHub flavor
+func (s Summary) WriteMarkdown(w io.Writer) error {
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder, "# Choreia report: `%s`\n\n", s.Input)
+
+	builder.WriteString("## Participants\n\n| Participant | Function | Spawned by | Sends on | Receives from |\n|---|---|---|---|---|\n")
+	for _, row := range s.participantRows() {
+		fmt.Fprintf(&builder, "| `%s` | %s | %s | %s | %s |\n", markdownCell(row.Name), markdownCell(row.Function),
+			markdownCell(row.Spawner), markdownCell(row.Sends), markdownCell(row.Receives))
+	}
+
+	if len(s.InitFlow) > 0 {
+		builder.WriteString("\n## Initialization\n\nRun by `main` before its body, in order:\n\n")
+		for i, name := range s.InitFlow {
+			fmt.Fprintf(&builder, "%d. `%s`\n", i+1, markdownCell(name))
+		}
+	}
+
+	builder.WriteString("\n## Spawn tree\n\n")
+	writeMarkdownTree(&builder, s.Result.SpawnTree(), 0)
+
+	builder.WriteString("\n## Channels\n\n")
+	if channels := s.Result.Channels(); len(channels) == 0 {
+		builder.WriteString("No message exchanged\n")
+	} else {
+		ownership := NewChannelOwnership(s.Result)
+		builder.WriteString("| Channel | Type | Buffer | Senders | Receivers | Owner | Usage |\n|---|---|---|---|---|---|---|\n")
+		for i, channel := range channels {
+			fmt.Fprintf(&builder, "| `%s` | `%s` | %s | %s | %s | %s | %s |\n", markdownCell(channel.Name), markdownCell(channel.Type), buffering(channel),
+				markdownCell(strings.Join(channel.Senders, ", ")), markdownCell(strings.Join(channel.Receivers, ", ")),
+				markdownCell(ownership[i].Owner), markdownCell(ownership[i].Usage()))
+		}
+	}
+
+	if s.ImageFile != "" {
+		fmt.Fprintf(&builder, "\n## Choreography\n\n![Choreography Automata](%s)\n", s.ImageFile)
+	}
+
+	builder.WriteString("\n## Diagnostics\n\n")
+	if len(s.Diagnostics) == 0 {
+		builder.WriteString("No issue found\n")
+	}
+	for _, issue := range s.Diagnostics {
+		fmt.Fprintf(&builder, "- **%s** (state %d): %s\n", issue.Kind, issue.State, markdownCell(issue.Message))
+		for i, t := range issue.Trace {
+			fmt.Fprintf(&builder, "  %d. `%s`\n", i+1, t)
+		}
+	}
+
+	builder.WriteString("\n## Unsupported constructs\n\n")
+	if s.Unsupported.Len() == 0 {
+		builder.WriteString("No unsupported construct found\n")
+	} else {
+		builder.WriteString("| Kind | Construct | Function | Line |\n|---|---|---|---|\n")
+		for _, construct := range s.Unsupported.Constructs {
+			fmt.Fprintf(&builder, "| %s | `%s` | %s | %d |\n", construct.Kind, markdownCell(construct.Name), markdownCell(construct.Function), construct.Line)
+		}
+	}
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// Writes the given spawn tree as a nested Markdown list, starting from the given depth
+func writeMarkdownTree(builder *strings.Builder, tree transforms.Tree, depth int) {
+	fmt.Fprintf(builder, "%s- `%s`\n", strings.Repeat("  ", depth), tree.Name)
+	for _, child := range tree.Children {
+		writeMarkdownTree(builder, child, depth+1)
+	}
+}
+
+// Escapes the characters of the given text that would break a cell of a Markdown table
+func markdownCell(text string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(text)
+}
+
+// ----------------------------------------------------------------------------
+// HTML
+
+// The template of the HTML report, the values are escaped by html/template (the SVG image excluded)
+var htmlTemplate = template.Must(template.New("summary").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Choreia report: {{.Input}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+code { background: #f4f4f4; }
+</style>
+</head>
+<body>
+<h1>Choreia report: <code>{{.Input}}</code></h1>
+<h2>Participants</h2>
+<table>
+<tr><th>Participant</th><th>Function</th><th>Spawned by</th><th>Sends on</th><th>Receives from</th></tr>
+{{- range .Participants}}
+<tr><td><code>{{.Name}}</code></td><td>{{.Function}}</td><td>{{.Spawner}}</td><td>{{.Sends}}</td><td>{{.Receives}}</td></tr>
+{{- end}}
+</table>
+{{- if .InitFlow}}
+<h2>Initialization</h2>
+<p>Run by <code>main</code> before its body, in order:</p>
+<ol>
+{{- range .InitFlow}}
+<li><code>{{.}}</code></li>
+{{- end}}
+</ol>
+{{- end}}
+<h2>Spawn tree</h2>
+{{template "tree" .SpawnTree}}
+<h2>Channels</h2>
+{{- if .Channels}}
+<table>
+<tr><th>Channel</th><th>Type</th><th>Buffer</th><th>Senders</th><th>Receivers</th><th>Owner</th><th>Usage</th></tr>
+{{- range .Channels}}
+<tr><td><code>{{.Name}}</code></td><td><code>{{.Type}}</code></td><td>{{.Buffer}}</td><td>{{.Senders}}</td><td>{{.Receivers}}</td><td>{{.Owner}}</td><td>{{.Usage}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No message exchanged</p>
+{{- end}}
+{{- if .Image}}
+<h2>Choreography</h2>
+{{.Image}}
+{{- end}}
+<h2>Diagnostics</h2>
+{{- if .Diagnostics}}
+<ul>
+{{- range .Diagnostics}}
+<li><strong>{{.Kind}}</strong> (state {{.State}}): {{.Message}}{{if .Trace}}<ol>{{range .Trace}}<li><code>{{.}}</code></li>{{end}}</ol>{{end}}</li>
+{{- end}}
+</ul>
+{{- else}}
+<p>No issue found</p>
+{{- end}}
+<h2>Unsupported constructs</h2>
+{{- if .Unsupported}}
+<table>
+<tr><th>Kind</th><th>Construct</th><th>Function</th><th>Line</th></tr>
+{{- range .Unsupported}}
+<tr><td>{{.Kind}}</td><td><code>{{.Name}}</code></td><td>{{.Function}}</td><td>{{.Line}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No unsupported construct found</p>
+{{- end}}
+</body>
+</html>
+{{define "tree"}}<ul><li><code>{{.Name}}</code>{{range .Children}}{{template "tree" .}}{{end}}</li></ul>{{end}}`))
+
+// A row of the channels table of the HTML template, the participants are already joined
+type htmlChannel struct {
+	Name, Type, Buffer, Senders, Receivers, Owner, Usage string
+}
+
+// An issue in the HTML template, the transitions of the trace are in textual form
+type htmlDiagnostic struct {
+	Kind    diagnostics.Kind
+	State   int
+	Message string
+	Trace   []string
+}
+
+// Writes the summary as a self-contained HTML page, the image of the Choreography Automata is inlined
+func (s Summary) WriteHTML(w io.Writer) error {
+	channels, ownership := []htmlChannel{}, NewChannelOwnership(s.Result)
+	for i, channel := range s.Result.Channels() {
+		channels = append(channels, htmlChannel{channel.Name, channel.Type, buffering(channel),
+			strings.Join(channel.Senders, ", "), strings.Join(channel.Receivers, ", "), ownership[i].Owner, ownership[i].Usage()})
+	}
+
+	issues := []htmlDiagnostic{}
+	for _, issue := range s.Diagnostics {
+		htmlIssue := htmlDiagnostic{Kind: issue.Kind, State: issue.State, Message: issue.Message}
+		for _, t := range issue.Trace {
+			htmlIssue.Trace = append(htmlIssue.Trace, t.String())
+		}
+		issues = append(issues, htmlIssue)
+	}
+
+	var constructs []static_analysis.UnsupportedConstruct
+	if s.Unsupported.Len() > 0 {
+		constructs = s.Unsupported.Constructs
+	}
+
+	return htmlTemplate.Execute(w, map[string]interface{}{
+		"Input":        s.Input,
+		"Participants": s.participantRows(),
+		"InitFlow":     s.InitFlow,
+		"SpawnTree":    s.Result.SpawnTree(),
+		"Channels":     channels,
+		"Image":        inlineSVG(s.ImageSVG),
+		"Diagnostics":  issues,
+		"Unsupported":  constructs,
+	})
+}
+
+// Returns the given SVG image ready to be inlined in the HTML page, that is without the XML prolog and
+// the doctype emitted by GraphViz before the "svg" element. An empty string is returned without image
+func inlineSVG(image []byte) template.HTML {
+	svg := string(image)
+	if start := strings.Index(svg, "<svg"); start != -1 {
+		return template.HTML(svg[start:])
+	}
+	return ""
+}
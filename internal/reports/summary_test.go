@@ -0,0 +1,77 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the summary of the analysis, in both the Markdown and HTML formats
+package reports
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/diagnostics"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func exampleSummary() Summary {
+	localViews := transforms.ExtractGoroutineFSA(meta.ExtractMetadata("../../example/PingPong.go", meta.NoTrace))
+	for _, lView := range localViews {
+		lView.Automaton = transforms.SubsetConstruction(lView.Automaton)
+	}
+	automaton := transforms.LocalViewsComposition(localViews)
+
+	unsupported := meta.NewUnsupportedReport(nil)
+	unsupported.Add(meta.SelectorCall, "fmt.Printf", "player", 0)
+
+	return Summary{
+		Input:       "PingPong.go",
+		Result:      transforms.NewChoreography(automaton, localViews),
+		InitFlow:    []string{"init#1", "init#2"},
+		Diagnostics: []diagnostics.Diagnostic{{Kind: diagnostics.Deadlock, State: 3, Message: "stuck | here"}},
+		Unsupported: unsupported,
+		ImageFile:   "global.svg",
+		ImageSVG:    []byte("<?xml version=\"1.0\"?>\n<!DOCTYPE svg>\n<svg><g/></svg>\n"),
+	}
+}
+
+func TestMarkdownSummary(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := exampleSummary().WriteMarkdown(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	report := buffer.String()
+	for _, expected := range []string{
+		"| `main/player@PingPong.go:20#1` | player | main | pong | ping |",
+		"  - `main/player@PingPong.go:21#1`",
+		"| `ping` | `int` | unbuffered | main, main/player@PingPong.go:21#1 | main/player@PingPong.go:20#1 |  | fan-in |",
+		"## Initialization\n\nRun by `main` before its body, in order:\n\n1. `init#1`\n2. `init#2`\n",
+		"![Choreography Automata](global.svg)",
+		"- **deadlock** (state 3): stuck \\| here",
+		"| selector-call | `fmt.Printf` | player | 0 |",
+	} {
+		if !strings.Contains(report, expected) {
+			t.Errorf("expected the report to contain %q, got\n%s", expected, report)
+		}
+	}
+}
+
+func TestHTMLSummary(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := exampleSummary().WriteHTML(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	report := buffer.String()
+	if !strings.Contains(report, "<h2>Choreography</h2>\n<svg><g/></svg>") || strings.Contains(report, "<?xml") {
+		t.Errorf("expected the SVG image to be inlined without its prolog, got\n%s", report)
+	}
+	if !strings.Contains(report, "<td><code>ping</code></td><td><code>int</code></td>") || !strings.Contains(report, "stuck | here") {
+		t.Errorf("expected the channels and the diagnostics in the report, got\n%s", report)
+	}
+	if !strings.Contains(report, "<ol>\n<li><code>init#1</code></li>\n<li><code>init#2</code></li>\n</ol>") {
+		t.Errorf("expected the init functions in the report, got\n%s", report)
+	}
+}
@@ -10,8 +10,8 @@
 package static_analysis
 
 import (
-	"fmt"
 	"go/ast"
+	"go/token"
 
 	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
 )
@@ -22,135 +22,203 @@ import (
 // This function parses a IfStmt statement and saves the data extracted in a FuncMetadata struct.
 // In case of error during execution a zero value of abovesaid struct is returned (no error returned).
 func parseIfStmt(stmt *ast.IfStmt, fm *FuncMetadata) {
-	// First parses the init statement that is always executed before branching
+	// The statement is an implicit block, the channels declared in the init are visible only inside it
+	fm.scope.open()
+	defer fm.scope.close()
+
+	// First parses the init statement and the condition that are always executed before branching
 	ast.Walk(fm, stmt.Init)
+	parseValueExpr(stmt.Cond, fm)
 
 	// Saves a local copy of the current id.
 	// All the branches in this statement will fork from it
-	branchingStateId := fm.Automaton.GetLastId()
+	branchingStateId := fm.currentState()
 
 	// Generate an eps-transition to represent the creation of a new nested scope/branch
-	tEpsIfStart := fsa.Transition{Move: fsa.Eps, Label: "if-block-start"}
-	fm.Automaton.AddTransition(branchingStateId, fsa.NewState, tEpsIfStart)
-	// Then parses both the condition and the nested scope (if-then)
-	ast.Walk(fm, stmt.Cond)
+	tEpsIfStart := fsa.IfBlockStart.Transition()
+	fm.emitFrom(branchingStateId, tEpsIfStart)
+	// Then parses the nested scope (if-then)
 	ast.Walk(fm, stmt.Body)
 	// Generates a transition to return/merge to the "main" scope
-	tEpsIfEnd := fsa.Transition{Move: fsa.Eps, Label: "if-block-end"}
-	fm.Automaton.AddTransition(fsa.Current, fsa.NewState, tEpsIfEnd)
-
-	// Saves the id of the latest created state
+	tEpsIfEnd := fsa.IfBlockEnd.Transition()
+	// Saves the id of the newly created state
 	// All the branches in this statement will converge to this
-	mergeStateId := fm.Automaton.GetLastId()
+	mergeStateId := fm.emit(tEpsIfEnd)
 
 	// If an else block is specified then its parsed on its own branch (2 equal branches are created)
 	if stmt.Else != nil {
-		tEpsElseStart := fsa.Transition{Move: fsa.Eps, Label: "else-block-start"}
-		fm.Automaton.AddTransition(branchingStateId, fsa.NewState, tEpsElseStart)
+		tEpsElseStart := fsa.ElseBlockStart.Transition()
+		fm.emitFrom(branchingStateId, tEpsElseStart)
 		// Parses the else block
 		ast.Walk(fm, stmt.Else)
 		// Links the else-block-end to the same destination as the if-block-end
-		tEpsElseEnd := fsa.Transition{Move: fsa.Eps, Label: "else-block-end"}
-		fm.Automaton.AddTransition(fsa.Current, mergeStateId, tEpsElseEnd)
+		tEpsElseEnd := fsa.ElseBlockEnd.Transition()
+		fm.addTransition(fm.currentState(), mergeStateId, tEpsElseEnd)
 	} else {
 		// If an else block isn't provided the we will have a "main" branch and the "alternative"
 		// execution flow (the one in which also the if-then block is executed as well)
-		tEpsIfSkip := fsa.Transition{Move: fsa.Eps, Label: "if-block-skip"}
-		fm.Automaton.AddTransition(branchingStateId, mergeStateId, tEpsIfSkip)
+		tEpsIfSkip := fsa.IfBlockSkip.Transition()
+		fm.addTransition(branchingStateId, mergeStateId, tEpsIfSkip)
 	}
 
-	// Set the new root of the Automaton, from which all future transition will start
-	fm.Automaton.SetRootId(mergeStateId)
+	// Moves to the merge state, from which all future transition will start
+	fm.moveTo(mergeStateId)
 }
 
 // This function parses a SwitchStmt statement and saves the data extracted in a FuncMetadata struct.
 // In case of error during execution a zero value of abovesaid struct is returned (no error returned).
-// ! Refactor the parseTypeSwitchStmt and parseSwitchStmt functions since they're almost equal
 func parseSwitchStmt(stmt *ast.SwitchStmt, fm *FuncMetadata) {
+	fm.scope.open() // The init is scoped to the statement, as for the IfStmt
+	defer fm.scope.close()
+
 	// First parses the init and tag sections, that are always executed before branching
 	ast.Walk(fm, stmt.Init)
-	ast.Walk(fm, stmt.Tag)
+	parseValueExpr(stmt.Tag, fm)
+	// Then each CaseClause is parsed on its own branch
+	parseSwitchClauses(stmt.Body.List, fm)
+}
+
+// This function handles the clauses of a SwitchStmt (both with and without tag), differently from the other
+// multi-branch statements the control flow of a switch isn't a simple fork: the case expressions are evaluated
+// one after the other (in source order) until one matches, while the default clause is taken only when none
+// does (wherever it's placed). Moreover a clause ending with "fallthrough" continues in the body of the next
+// clause instead of merging. If there's no default clause the switch can be skipped entirely. If no clause is
+// given the FSA is left untouched.
+func parseSwitchClauses(clauses []ast.Stmt, fm *FuncMetadata) {
+	if len(clauses) == 0 {
+		return
+	}
+
+	// Evaluates the case expressions in sequence, saving the state in which each one of them matches
+	matchStateIds := make([]int, len(clauses))
+	for i, clause := range clauses {
+		caseClause := clause.(*ast.CaseClause)
+		if caseClause.List == nil {
+			continue // The default clause has no expression to be evaluated
+		}
+
+		for _, expr := range caseClause.List {
+			parseValueExpr(expr, fm)
+		}
+		matchStateIds[i] = fm.currentState()
+
+		// Generates the transition to the evaluation of the next case (the current one doesn't match)
+		fm.emit(fsa.SwitchCaseNoMatch.With(i).Transition())
+	}
+	// The state reached when none of the case expressions matches
+	noMatchStateId := fm.currentState()
 
-	// Saves the id of the latest created state
-	// All the branches in this statement will converge to this
-	currentAutomataId := fm.Automaton.GetLastId()
 	// All the branches in this statement will converge to this state
-	// The first branch to be parsed will be the one to initialize the variable with a valid id
+	// The first branch to be merged will be the one to initialize the variable with a valid id
 	mergeStateId := fsa.Unknown
+	linkToMerge := func(from int, label fsa.EpsLabel) {
+		tEpsEnd := label.Transition()
+		if mergeStateId == fsa.Unknown {
+			mergeStateId = fm.emitFrom(from, tEpsEnd)
+		} else {
+			fm.addTransition(from, mergeStateId, tEpsEnd)
+		}
+	}
 
-	for i, bodyStmt := range stmt.Body.List {
-		// Convert the Stmt to a CaseClause one, this is always possible at the moment.
-		// Since we're parsing a "switch" statement and this is the only option available
-		caseClauseStmt := bodyStmt.(*ast.CaseClause)
+	// The state reached at the end of the previous clause, if the latter falls through the current one
+	fallthroughStateId := fsa.Unknown
+	hasDefault := false
 
-		// Generate an eps-transition to represent the fork/branch (the cases in the select)
-		// and add it as a transition from the "branching point" saved before
-		startLabel := fmt.Sprintf("switch-case-%d-start", i)
-		tEpsStart := fsa.Transition{Move: fsa.Eps, Label: startLabel}
-		fm.Automaton.AddTransition(currentAutomataId, fsa.NewState, tEpsStart)
+	for i, clause := range clauses {
+		caseClause := clause.(*ast.CaseClause)
+		branchingStateId := matchStateIds[i]
+		if caseClause.List == nil {
+			branchingStateId, hasDefault = noMatchStateId, true
+		}
 
-		// Parses the ClauseCase statement, then parses the nested block/scopes
-		ast.Walk(fm, caseClauseStmt)
+		// The body of the clause starts from a new state or from the one in which the previous clause fell through
+		tEpsStart := fsa.SwitchCaseStart.With(i).Transition()
+		if fallthroughStateId != fsa.Unknown {
+			fm.moveTo(fm.addTransition(branchingStateId, fallthroughStateId, tEpsStart))
+			fallthroughStateId = fsa.Unknown
+		} else {
+			fm.emitFrom(branchingStateId, tEpsStart)
+		}
 
-		// Generates a transition to return/merge to the main scope
-		endLabel := fmt.Sprintf("switch-case-%d-end", i)
-		tEpsEnd := fsa.Transition{Move: fsa.Eps, Label: endLabel}
+		// Parses the nested block/scopes of the clause, the latter is a block on its own
+		fm.scope.open()
+		for _, bodyStmt := range caseClause.Body {
+			ast.Walk(fm, bodyStmt)
+		}
+		fm.scope.close()
 
-		if mergeStateId == fsa.Unknown {
-			// Saves the id, of the merge state for use in next iterations
-			fm.Automaton.AddTransition(fsa.Current, fsa.NewState, tEpsEnd)
-			mergeStateId = fm.Automaton.GetLastId()
-		} else {
-			fm.Automaton.AddTransition(fsa.Current, mergeStateId, tEpsEnd)
+		if fallsThrough(caseClause) {
+			fallthroughStateId = fm.emit(fsa.SwitchCaseFallthrough.With(i).Transition())
+			continue
 		}
+		linkToMerge(fm.currentState(), fsa.SwitchCaseEnd.With(i))
 	}
 
-	// Set the new root of the Automaton, from which all future transition will start
-	fm.Automaton.SetRootId(mergeStateId)
+	// Without a default clause the execution continues after the switch when no case matches
+	if !hasDefault {
+		linkToMerge(noMatchStateId, fsa.SwitchSkip)
+	}
+
+	// Moves to the merge state, from which all future transition will start
+	fm.moveTo(mergeStateId)
+}
+
+// Returns true if the given clause ends with a "fallthrough" statement
+func fallsThrough(clause *ast.CaseClause) bool {
+	if len(clause.Body) == 0 {
+		return false
+	}
+
+	branch, isBranch := clause.Body[len(clause.Body)-1].(*ast.BranchStmt)
+	return isBranch && branch.Tok == token.FALLTHROUGH
 }
 
 // This function parses a TypeSwitchStmt statement and saves the data extracted in a FuncMetadata struct.
 // In case of error during execution a zero value of abovesaid struct is returned (no error returned).
-// ! Refactor the parseTypeSwitchStmt and parseSwitchStmt functions since they're almost equal
 func parseTypeSwitchStmt(stmt *ast.TypeSwitchStmt, fm *FuncMetadata) {
-	// First parses the init and tag sections, that are always executed before branching
+	fm.scope.open() // The init is scoped to the statement, as for the IfStmt
+	defer fm.scope.close()
+
+	// First parses the init and assign sections, that are always executed before branching
 	ast.Walk(fm, stmt.Init)
 	ast.Walk(fm, stmt.Assign)
+	// Then each CaseClause is parsed on its own branch
+	parseBranchingStmt("typeswitch", stmt.Body.List, fm)
+}
 
-	// Saves the id of the latest created state
-	// All the branches in this statement will converge to this
-	currentAutomataId := fm.Automaton.GetLastId()
+// This function handles the common structure of every multi-branch statement (switch, type switch
+// and select): each clause is parsed on its own branch forking from the current state, then all
+// the branches converge to the same merge state. The labels of the eps-transitions are prefixed
+// with the given kind (e.g "select-case-0-start"). If no clause is given the FSA is left untouched.
+func parseBranchingStmt(kind string, clauses []ast.Stmt, fm *FuncMetadata) {
+	// Saves a local copy of the current id, all the branch will fork from it
+	currentAutomataId := fm.currentState()
 	// All the branches in this statement will converge to this state
 	// The first branch to be parsed will be the one to initialize the variable with a valid id
 	mergeStateId := fsa.Unknown
 
-	for i, bodyStmt := range stmt.Body.List {
-		// Convert the Stmt to a CaseClause one, this is always possible at the moment.
-		// Since we're parsing a "switch" statement and this is the only option available
-		caseClauseStmt := bodyStmt.(*ast.CaseClause)
-
-		// Generate an eps-transition to represent the fork/branch (the cases in the select)
+	for i, clause := range clauses {
+		// Generate an eps-transition to represent the fork/branch (the cases in the statement)
 		// and add it as a transition from the "branching point" saved before
-		startLabel := fmt.Sprintf("typeswitch-case-%d-start", i)
-		tEpsStart := fsa.Transition{Move: fsa.Eps, Label: startLabel}
-		fm.Automaton.AddTransition(currentAutomataId, fsa.NewState, tEpsStart)
+		tEpsStart := fsa.BranchCaseStart.With(kind, i).Transition()
+		fm.emitFrom(currentAutomataId, tEpsStart)
 
-		// Parses the ClauseCase statement, then parses the nested block/scopes
-		ast.Walk(fm, caseClauseStmt)
+		// Parses the CaseClause (or CommClause), then parses the nested block/scopes
+		ast.Walk(fm, clause)
 
-		// Generates a transition to return/merge to the main scope
-		endLabel := fmt.Sprintf("typeswitch-case-%d-end", i)
-		tEpsEnd := fsa.Transition{Move: fsa.Eps, Label: endLabel}
+		// Generates a transition to return/merge to the "main" scope
+		tEpsEnd := fsa.BranchCaseEnd.With(kind, i).Transition()
 
 		if mergeStateId == fsa.Unknown {
 			// Saves the id, of the merge state for use in next iterations
-			fm.Automaton.AddTransition(fsa.Current, fsa.NewState, tEpsEnd)
-			mergeStateId = fm.Automaton.GetLastId()
+			mergeStateId = fm.emit(tEpsEnd)
 		} else {
-			fm.Automaton.AddTransition(fsa.Current, mergeStateId, tEpsEnd)
+			fm.addTransition(fm.currentState(), mergeStateId, tEpsEnd)
 		}
 	}
 
-	// Set the new root of the Automaton, from which all future transition will start
-	fm.Automaton.SetRootId(mergeStateId)
+	// Moves to the merge state, from which all future transition will start
+	if mergeStateId != fsa.Unknown {
+		fm.moveTo(mergeStateId)
+	}
 }
@@ -0,0 +1,88 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the control flow of the switch statements (sequential case evaluation and fallthrough)
+package static_analysis
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// Extracts the metadata of the given source and returns the text format of the "main" ScopeAutomata
+func mainScopeAutomaton(t *testing.T, source string) string {
+	t.Helper()
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"main.go": source})
+
+	metadata := ExtractMetadata(filepath.Join(root, "main.go"), NoTrace)
+	text, err := metadata.FunctionMeta["main"].Automaton.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(text)
+}
+
+func TestSwitchFallthrough(t *testing.T) {
+	text := mainScopeAutomaton(t, `package main
+func main() {
+	ch, x := make(chan int), 1
+	switch {
+	case x > 0:
+		ch <- 1
+		fallthrough
+	case x > 1:
+		ch <- 2
+	default:
+		<-ch
+	}
+}
+`)
+
+	// The first case continues in the body of the second one, the default is taken when neither matches
+	expected := `final 11
+0 -> 1 Call "make"
+1 -> 2 Epsilon "switch-case-0-no-match@main.go:4:2"
+1 -> 4 Epsilon "switch-case-0-start@main.go:4:2"
+2 -> 3 Epsilon "switch-case-1-no-match@main.go:4:2"
+2 -> 6 Epsilon "switch-case-1-start@main.go:4:2"
+3 -> 9 Epsilon "switch-case-2-start@main.go:4:2"
+4 -> 5 Send "ch"
+5 -> 6 Epsilon "switch-case-0-fallthrough@main.go:4:2"
+6 -> 7 Send "ch"
+7 -> 8 Epsilon "switch-case-1-end@main.go:4:2"
+8 -> 11 Epsilon "func-main-return@main.go:13:1"
+9 -> 10 Recv "ch"
+10 -> 8 Epsilon "switch-case-2-end@main.go:4:2"
+`
+	if text != expected {
+		t.Errorf("expected the ScopeAutomata\n%s\ngot\n%s", expected, text)
+	}
+}
+
+func TestSwitchWithoutDefault(t *testing.T) {
+	text := mainScopeAutomaton(t, `package main
+func main() {
+	ch, x := make(chan int), 1
+	switch x {
+	case 1:
+		ch <- 1
+	}
+}
+`)
+
+	// When the only case doesn't match the execution continues after the switch
+	expected := `final 6
+0 -> 1 Call "make"
+1 -> 2 Epsilon "switch-case-0-no-match@main.go:4:2"
+1 -> 3 Epsilon "switch-case-0-start@main.go:4:2"
+2 -> 5 Epsilon "switch-skip@main.go:4:2"
+3 -> 4 Send "ch"
+4 -> 5 Epsilon "switch-case-0-end@main.go:4:2"
+5 -> 6 Epsilon "func-main-return@main.go:8:1"
+`
+	if text != expected {
+		t.Errorf("expected the ScopeAutomata\n%s\ngot\n%s", expected, text)
+	}
+}
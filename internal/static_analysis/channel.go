@@ -10,14 +10,18 @@
 package static_analysis
 
 import (
-	"fmt"
 	"go/ast"
+	"go/constant"
 	"go/token"
+	"go/types"
 	"log"
 
 	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
 )
 
+// The capacity of a buffered channel whose buffer size can't be evaluated statically
+const UnknownCapacity = -1
+
 // ----------------------------------------------------------------------------
 // ChanMetadata
 
@@ -26,85 +30,132 @@ import (
 // A struct containing all the metadata that the Visitor algorithm has been able to extrapolate.
 // This kind of date are derived both from channel declaration and assignment.
 // Only the channel declared in the file are evaluated (channel returned from function call or
-// imported from another module are ignored)
+// imported from another module are ignored), except the ones of the stdlib modeled in stdlib.go
 type ChanMetadata struct {
-	Name  string // The name of the channel
-	Type  string // The type of message the channel supports (int, string, interface{}, ...)
-	Async bool   // Is the channel unbuffered (synchronous) or buffered (asynchronous)
+	Name     string // The name of the channel
+	Type     string // The type of message the channel supports (int, string, interface{}, ...)
+	Async    bool   // Is the channel unbuffered (synchronous) or buffered (asynchronous)
+	Capacity int    // The size of the buffer (0 if unbuffered, UnknownCapacity if not constant)
+	MayBeNil bool   // Is the channel assigned to nil in the function (its operations may be disabled)
+	// Is the channel filled by the runtime instead of a Goroutine (e.g "time.After()", see stdlib.go)
+	Environment bool
+}
+
+// Returns true if the given metadata describes a channel bound to a variable, that is a valid one (see parseMakeCall)
+// assigned to a name other than the blank identifier (e.g "_, done := make(chan int), make(chan bool)")
+func isBoundChannel(channel ChanMetadata) bool {
+	return channel.Name != "" && channel.Name != "_" && channel.Type != ""
 }
 
 // ----------------------------------------------------------------------------
 // Channel related parsing method
 
 // This function parses a SendStmt statement and saves the transition(s) extracted
-// in the given FuncMetadata argument. In case of error the whole execution is stopped.
+// in the given FuncMetadata argument. In case of error during execution no error is returned.
 func parseSendStmt(stmt *ast.SendStmt, fm *FuncMetadata) {
+	// The value sent is evaluated first, with the calls and receives it contains (e.g "results <- compute(<-jobs)")
+	parseValueExpr(stmt.Value, fm)
+
 	chanIdent, isIdent := stmt.Chan.(*ast.Ident)
-	if isIdent {
-		channelMeta := fm.ChanMeta[chanIdent.Name]
-		tSend := fsa.Transition{Move: fsa.Send, Label: chanIdent.Name, Payload: channelMeta}
-		fm.Automaton.AddTransition(fsa.Current, fsa.NewState, tSend)
-	} else {
-		log.Fatalf("Could't find identifier in SendStmt at line: %d\n", stmt.Pos())
+	if element, isElement := fm.variadicElement(stmt.Chan); isElement {
+		chanIdent, isIdent = element, true
+	}
+
+	// Channels that aren't referenced by an identifier (e.g "pkg.Channel <- 1") are not tracked
+	if !isIdent {
+		fm.report.Add(ExternalChannel, types.ExprString(stmt.Chan), fm.Name, stmt.Pos())
+		return
 	}
+
+	channelMeta := fm.lookupChannel(chanIdent, stmt.Pos())
+	tSend := fsa.Transition{Move: fsa.Send, Label: fm.channelName(chanIdent.Name), Payload: channelMeta}
+	fm.emit(tSend)
 }
 
 // This function parses a UnaryExpr statement and saves the Transition(s) data extracted
 // in the given FuncMetadata argument. In case of error during execution no error is returned.
 func parseRecvStmt(expr *ast.UnaryExpr, fm *FuncMetadata) {
-	// Tries to extract the identifier of the expression
-	chanIdent, isIdent := expr.X.(*ast.Ident)
-
-	// If an ident isn't found or the token is not "<-" then we return.
+	// If the token is not "<-" then we return.
 	// This is means the current op we're parsing isn't a ReceiveStmt
-	if !isIdent || expr.Op != token.ARROW {
+	if expr.Op != token.ARROW {
+		return
+	}
+
+	// The channels returned by the stdlib functions modeled (e.g "<-time.After(d)") are environment inputs
+	if callExpr, isCall := expr.X.(*ast.CallExpr); isCall {
+		if channelMeta, isKnown := fm.lookupCallChannel(callExpr); isKnown {
+			tRecv := fsa.Transition{Move: fsa.Recv, Label: channelMeta.Name, Payload: channelMeta}
+			fm.emit(tRecv)
+			return
+		}
+	}
+
+	// Tries to extract the identifier of the expression, channels returned by any other function
+	// call or accessed through a selector (e.g "<-pkg.Channel") are reported and skipped
+	chanIdent, isIdent := expr.X.(*ast.Ident)
+	if element, isElement := fm.variadicElement(expr.X); isElement {
+		chanIdent, isIdent = element, true
+	}
+	if !isIdent {
+		fm.report.Add(ExternalChannel, types.ExprString(expr.X), fm.Name, expr.Pos())
 		return
 	}
 
 	// Retrieves the channel metadata and initializes a valid transition
-	channelMeta := fm.ChanMeta[chanIdent.Name]
-	tRecv := fsa.Transition{Move: fsa.Recv, Label: chanIdent.Name, Payload: channelMeta}
-	fm.Automaton.AddTransition(fsa.Current, fsa.NewState, tRecv)
+	channelMeta := fm.lookupChannel(chanIdent, expr.Pos())
+	tRecv := fsa.Transition{Move: fsa.Recv, Label: fm.channelName(chanIdent.Name), Payload: channelMeta}
+	fm.emit(tRecv)
+}
+
+// Retrieves the metadata of the given channel from the function scope. A channel without metadata
+// hasn't been declared in the file (e.g. returned from an external function), the transition is
+// extracted anyway but the channel is reported since no information about its type is available.
+// The identifier is resolved in the block being visited, so a shadowed channel is never returned
+func (fm *FuncMetadata) lookupChannel(chanIdent *ast.Ident, pos token.Pos) ChanMetadata {
+	channelMeta, exist := fm.ChanMeta[fm.channelName(chanIdent.Name)]
+	if !exist {
+		fm.report.Add(ExternalChannel, chanIdent.Name, fm.Name, pos)
+	}
+	return channelMeta
+}
+
+// Marks the given channel as assigned to nil somewhere in the function. This is the common pattern used
+// to disable a select branch (e.g "case v := <-ch: ch = nil"), since the assignment could be placed after
+// the operations on the channel (e.g. in a loop) the latters are annotated when the whole body is parsed
+func parseNilAssignment(chanIdent *ast.Ident, fm *FuncMetadata) {
+	chanName := fm.channelName(chanIdent.Name)
+	if _, isChannel := fm.ChanMeta[chanName]; isChannel {
+		fm.nilChannels[chanName] = true
+	}
+}
+
+// Annotates (with MayBeNil) the metadata of the channels assigned to nil in the function and the
+// payload of every Send/Recv transition on them, so that the later transformation can exclude them
+func annotateNilChannels(fm FuncMetadata) {
+	for chanName := range fm.nilChannels {
+		channelMeta := fm.ChanMeta[chanName]
+		channelMeta.MayBeNil = true
+		fm.ChanMeta[chanName] = channelMeta
+	}
+
+	fm.Automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+		channelMeta, isChanOp := t.Payload.(ChanMetadata)
+		if !isChanOp || !fm.nilChannels[t.Label] || (t.Move != fsa.Send && t.Move != fsa.Recv) {
+			return
+		}
+
+		channelMeta.MayBeNil = true
+		newT := fsa.Transition{Move: t.Move, Label: t.Label, Payload: channelMeta}
+		fm.Automaton.RemoveTransition(from, to, t)
+		fm.Automaton.AddTransition(from, to, newT)
+	})
 }
 
 // This function parses a SelectStmt statement and saves the Transition(s) data extracted
 // in the given FuncMetadata argument. In case of error during execution no error is returned.
+// Each CommClause (the default one included) is parsed on its own branch
 func parseSelectStmt(stmt *ast.SelectStmt, fm *FuncMetadata) {
-	// Saves a local copy of the current id, all the branch will fork from it
-	currentAutomataId := fm.Automaton.GetLastId()
-	// The id of the state in which all the nested scopes will converge.
-	// It will be initialized correctly after the first iteration
-	mergeStateId := fsa.Unknown
-
-	for i, bodyStmt := range stmt.Body.List {
-		// Convert the bodyStmt to a CommClause one, this is always possible at the moment
-		// since we're parsing a "select" statement and this is the only option available
-		commClause := bodyStmt.(*ast.CommClause)
-
-		// Generate an eps-transition to represent the fork/branch (the cases in the select)
-		// and add it as a transition from the "branching point" saved before
-		startLabel := fmt.Sprintf("select-case-%d-start", i)
-		tEpsStart := fsa.Transition{Move: fsa.Eps, Label: startLabel}
-		fm.Automaton.AddTransition(currentAutomataId, fsa.NewState, tEpsStart)
-
-		// Parses the CaseClause, then parses the nested block/scopes
-		ast.Walk(fm, commClause)
-
-		// Generates a transition to return/merge to the "main" scope
-		endLabel := fmt.Sprintf("select-case-%d-end", i)
-		tEpsEnd := fsa.Transition{Move: fsa.Eps, Label: endLabel}
-
-		if mergeStateId == fsa.Unknown {
-			// Saves the id, of the merge state for use in next iterations
-			fm.Automaton.AddTransition(fsa.Current, fsa.NewState, tEpsEnd)
-			mergeStateId = fm.Automaton.GetLastId()
-		} else {
-			fm.Automaton.AddTransition(fsa.Current, mergeStateId, tEpsEnd)
-		}
-	}
-
-	// Set the new root of the Automaton, from which all future transition will start
-	fm.Automaton.SetRootId(mergeStateId)
+	parseBranchingStmt("select", stmt.Body.List, fm)
 }
 
 // Specific function to extrapolate channel metadata from a DeclStmt statement.
@@ -118,14 +169,44 @@ func parseDeclStmt(stmt *ast.DeclStmt, fm *FuncMetadata) {
 		log.Fatalf("Couldn't get the GenDecl statement from the DeclStmt at line %d\n", stmt.Pos())
 	}
 
-	chanMeta := parseGenDecl(genDecl)
-	fm.addChannels(chanMeta...)
+	// The values are evaluated before the variables are declared (e.g "var result = <-ch" or "var x = compute()")
+	if genDecl.Tok == token.VAR {
+		for _, spec := range genDecl.Specs {
+			parseValueSpec(spec.(*ast.ValueSpec), fm)
+		}
+	}
+
+	chanMeta := parseGenDecl(genDecl, fm.constants, fm.chanTypes)
+	fm.declareChannels(chanMeta...)
+}
+
+// Extracts the channel operations in the values of a variable declaration, the calls are parsed as the ones of
+// an assignment that declares the variables (see parseAssignedCall) so that a returned channel keeps its identity
+func parseValueSpec(spec *ast.ValueSpec, fm *FuncMetadata) {
+	names := make([]ast.Expr, len(spec.Names))
+	for i, name := range spec.Names {
+		names[i] = name
+	}
+
+	for i, value := range spec.Values {
+		callExpr, isCall := value.(*ast.CallExpr)
+		switch {
+		case isCall && len(spec.Values) == 1:
+			parseAssignedCall(callExpr, names, true, fm)
+		case isCall:
+			parseAssignedCall(callExpr, names[i:i+1], true, fm)
+		default:
+			parseValueExpr(value, fm)
+		}
+	}
 }
 
 // This function tries to extract metadata about a channel from the GenDecl subtree.
 // Since is possible to declare more variables in a single GenDecl statement the function
-// returns a slice of ChanMetadata. If errors are encountered at any point the function returns nil
-func parseGenDecl(genDecl *ast.GenDecl) []ChanMetadata {
+// returns a slice of ChanMetadata. If errors are encountered at any point the function returns nil.
+// The locals are the variables with a constant value in scope (nil for the global declarations)
+// while the named types are the channel types declared in the file (see namedChannelTypes)
+func parseGenDecl(genDecl *ast.GenDecl, locals map[string]constant.Value, named map[string]*ast.ChanType) []ChanMetadata {
 	// Initializes the slice where al the data extracted will be aggregated
 	bufferMetadata := []ChanMetadata{}
 
@@ -146,8 +227,8 @@ func parseGenDecl(genDecl *ast.GenDecl) []ChanMetadata {
 			callExpr, isCallExpr := rVal.(*ast.CallExpr)
 			// If the Rhs expression is a function call then is possible is a "make call"
 			if isCallExpr {
-				newChan := parseMakeCall(callExpr, lVal.Name)
-				bufferMetadata = append(bufferMetadata, newChan)
+				newChan := parseMakeCall(callExpr, lVal.Name, locals, named)
+				bufferMetadata = append(bufferMetadata, newChan, parseStdlibChannel(callExpr, lVal.Name))
 			}
 		}
 	}
@@ -157,8 +238,9 @@ func parseGenDecl(genDecl *ast.GenDecl) []ChanMetadata {
 
 // This function tries to parse a "make" function call in order to extract metadata
 // about the initialized channel. If at any point errors are encountered then the
-// function returns the zero value of the ChanMetadata struct
-func parseMakeCall(callExpr *ast.CallExpr, chanName string) ChanMetadata {
+// function returns the zero value of the ChanMetadata struct. The type made can be a named one as well
+// (e.g "make(Jobs, 4)" with "type Jobs chan Job"), in this case the message type is the one of the latter
+func parseMakeCall(callExpr *ast.CallExpr, chanName string, locals map[string]constant.Value, named map[string]*ast.ChanType) ChanMetadata {
 	// Tries to extract the function name (identifier), else return a zero value
 	funcIdent, isIdent := callExpr.Fun.(*ast.Ident)
 
@@ -170,15 +252,94 @@ func parseMakeCall(callExpr *ast.CallExpr, chanName string) ChanMetadata {
 	// to extract some data about an eventual channel declared
 	if funcIdent.Name == "make" {
 		// If the first argument is a ChanType we're initializing a channel
-		channelTypeExpr, isChannelType := callExpr.Args[0].(*ast.ChanType)
+		channelTypeExpr, isChannelType := channelType(callExpr.Args[0], named)
 		if isChannelType {
 			// Extrapolates all the metadata needed about the chan
-			channelType := channelTypeExpr.Value.(*ast.Ident).Name
-			isChannelBuffered := len(callExpr.Args) > 1
+			channelType := types.ExprString(channelTypeExpr.Value)
+			capacity := 0
+			if len(callExpr.Args) > 1 {
+				capacity = constantCapacity(callExpr.Args[1], locals)
+			}
 			// The name is empty and has to be set from the caller function
-			return ChanMetadata{Name: chanName, Type: channelType, Async: isChannelBuffered}
+			return ChanMetadata{Name: chanName, Type: channelType, Async: capacity != 0, Capacity: capacity}
 		}
 	}
 
 	return ChanMetadata{}
 }
+
+// Evaluates the buffer size given to a "make" call, the latter can be an integer literal or a constant
+// expression (e.g "2 * bufferSize") made of the constants declared in the same file and of the local
+// variables with a constant value (see propagateConstants). Any other expression (e.g a function call)
+// can't be evaluated statically and UnknownCapacity is returned
+func constantCapacity(sizeExpr ast.Expr, locals map[string]constant.Value) int {
+	if size, isConstant := constantInt(sizeExpr, locals); isConstant && size >= 0 {
+		return size
+	}
+	return UnknownCapacity
+}
+
+
+// ----------------------------------------------------------------------------
+// Named channel types
+
+// Collects the channel types declared with a name in the given files (e.g "type Jobs chan Job"), along with
+// the ones defined or aliased from another named channel type (e.g "type Queue = Jobs"). The types declared
+// inside the functions aren't collected, since they're visible only in the latter
+func namedChannelTypes(files []*ast.File) map[string]*ast.ChanType {
+	declared := make(map[string]ast.Expr)
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			genDecl, isGenDecl := decl.(*ast.GenDecl)
+			if !isGenDecl || genDecl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				if typeSpec, isTypeSpec := spec.(*ast.TypeSpec); isTypeSpec {
+					declared[typeSpec.Name.Name] = typeSpec.Type
+				}
+			}
+		}
+	}
+
+	named := make(map[string]*ast.ChanType)
+	for name := range declared {
+		// A chain of named types is followed up to its underlying type, the cycles (invalid Go) are ignored
+		typeExpr, seen := declared[name], map[string]bool{name: true}
+		for {
+			ident, isIdent := unparen(typeExpr).(*ast.Ident)
+			if !isIdent || seen[ident.Name] || declared[ident.Name] == nil {
+				break
+			}
+			typeExpr, seen[ident.Name] = declared[ident.Name], true
+		}
+		if chanType, isChannel := unparen(typeExpr).(*ast.ChanType); isChannel {
+			named[name] = chanType
+		}
+	}
+	return named
+}
+
+// Returns the channel type denoted by the given type expression, either a literal one (e.g "chan int")
+// or one of the given named channel types (e.g "Jobs"). The second value is false for any other type
+func channelType(typeExpr ast.Expr, named map[string]*ast.ChanType) (*ast.ChanType, bool) {
+	switch castExpr := unparen(typeExpr).(type) {
+	case *ast.ChanType:
+		return castExpr, true
+	case *ast.Ident:
+		chanType, isNamed := named[castExpr.Name]
+		return chanType, isNamed
+	}
+	return nil, false
+}
+
+// Returns the given expression without the enclosing parentheses (e.g "chan int" for "(chan int)")
+func unparen(expr ast.Expr) ast.Expr {
+	for {
+		parenExpr, isParen := expr.(*ast.ParenExpr)
+		if !isParen {
+			return expr
+		}
+		expr = parenExpr.X
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the extraction of the buffer capacity and of the type of the channels, and of the values sent
+package static_analysis
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+)
+
+func TestChannelCapacity(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"main.go": `package main
+const bufferSize = 4
+var global = make(chan int, bufferSize)
+func main() {
+	n, m := 3, 3
+	m++
+	sync, literal, expr := make(chan int), make(chan int, 2), make(chan int, 2*bufferSize+1)
+	zero, local, dynamic := make(chan int, 0), make(chan int, n), make(chan int, m)
+	sync <- <-literal
+	expr <- <-zero
+	dynamic <- <-global
+	local <- 0
+}
+`})
+
+	metadata := ExtractMetadata(filepath.Join(root, "main.go"), NoTrace)
+	channels := metadata.FunctionMeta["main"].ChanMeta
+
+	expected := map[string]int{"global": 4, "sync": 0, "literal": 2, "expr": 9, "zero": 0, "local": 3, "dynamic": UnknownCapacity}
+	for name, capacity := range expected {
+		channel, exist := channels[name]
+		if !exist {
+			t.Errorf("channel '%s' not found", name)
+			continue
+		}
+		if channel.Capacity != capacity || channel.Async != (capacity != 0) {
+			t.Errorf("expected '%s' to have capacity %d (buffered: %t), got %d (buffered: %t)", name, capacity, capacity != 0, channel.Capacity, channel.Async)
+		}
+	}
+}
+
+func TestNamedChannelTypes(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"main.go": `package main
+type Msg struct{}
+type MsgChan chan Msg
+type Outbox = (MsgChan)
+func consumer(in MsgChan, out Outbox) {
+	msg := <-in
+	out <- msg
+}
+func open() Outbox { return make(Outbox) }
+func main() {
+	in, out := make(MsgChan, 2), open()
+	go consumer(in, out)
+	in <- Msg{}
+	<-out
+}
+`})
+
+	metadata := ExtractMetadata(filepath.Join(root, "main.go"), NoTrace)
+
+	channels := metadata.FunctionMeta["main"].ChanMeta
+	if in := channels["in"]; in.Type != "Msg" || in.Capacity != 2 {
+		t.Errorf("expected 'in' to be a buffered Msg channel, got %v", in)
+	}
+	if out := channels["out"]; out.Type != "Msg" {
+		t.Errorf("expected 'out' (returned by open) to be a Msg channel, got %v", out)
+	}
+
+	consumer := metadata.FunctionMeta["consumer"]
+	if len(consumer.InlineArgs) != 2 || consumer.ChanMeta["in"].Type != "Msg" || consumer.ChanMeta["out"].Type != "Msg" {
+		t.Errorf("expected both the arguments of consumer to be Msg channels, got %v", consumer.InlineArgs)
+	}
+	operations := 0
+	consumer.Automaton.ForEachTransition(func(_, _ int, tr fsa.Transition) {
+		if tr.Move == fsa.Send || tr.Move == fsa.Recv {
+			operations++
+		}
+	})
+	if operations != 2 {
+		t.Errorf("expected a receive and a send in consumer, got %d operation(s)", operations)
+	}
+}
+
+func TestTupleAssignments(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"main.go": `package main
+func newConn() (chan int, error) { return make(chan int, 3), nil }
+func pair() (error, chan string) { return nil, make(chan string) }
+func main() {
+	a, b := make(chan int), make(chan string, 2)
+	_, done := make(chan int, 4), make(chan bool)
+	n, c := 1, make(chan int, 7)
+	var p, _, q = make(chan int, 5), make(chan int), make(chan bool)
+	ch, err := newConn()
+	_, s := pair()
+	_, _, _, _, _, _, _, _, _, _ = a, b, done, n, c, p, q, ch, err, s
+}
+`})
+
+	metadata := ExtractMetadata(filepath.Join(root, "main.go"), NoTrace)
+	channels := metadata.FunctionMeta["main"].ChanMeta
+
+	// Each lvalue gets the metadata of the value in its own position, the blank identifier discards the channel
+	expected := map[string]ChanMetadata{
+		"a": {Name: "a", Type: "int"}, "b": {Name: "b", Type: "string", Async: true, Capacity: 2},
+		"done": {Name: "done", Type: "bool"}, "c": {Name: "c", Type: "int", Async: true, Capacity: 7},
+		"p": {Name: "p", Type: "int", Async: true, Capacity: 5}, "q": {Name: "q", Type: "bool"},
+		"ch": {Name: "ch", Type: "int"}, "s": {Name: "s", Type: "string"},
+	}
+	if len(channels) != len(expected) {
+		t.Errorf("expected %d channels, got %v", len(expected), channels)
+	}
+	for name, channel := range expected {
+		if channels[name] != channel {
+			t.Errorf("expected '%s' to be %+v, got %+v", name, channel, channels[name])
+		}
+	}
+
+	// The channel created by the return statement is bound by position, along with its buffer size
+	returned := metadata.FunctionMeta["newConn"]
+	if len(returned.ReturnArgs) != 1 || returned.ReturnArgs[0].Offset != 0 {
+		t.Fatalf("expected the first result of newConn to be a channel, got %v", returned.ReturnArgs)
+	}
+	if created := returned.ChanMeta[returned.ReturnArgs[0].Name]; created.Capacity != 3 || created.Type != "int" {
+		t.Errorf("expected the channel returned by newConn to have capacity 3, got %+v", created)
+	}
+	if results := metadata.FunctionMeta["pair"].ReturnArgs; len(results) != 1 || results[0].Offset != 1 {
+		t.Errorf("expected the second result of pair to be a channel, got %v", results)
+	}
+}
+
+func TestNestedCallsInSend(t *testing.T) {
+	text := mainScopeAutomaton(t, `package main
+import "fmt"
+func compute(in chan int) int { return <-in }
+func main() {
+	in, out := make(chan int), make(chan int)
+	out <- compute(in)
+	fmt.Println(<-out)
+	go report(<-in)
+}
+func report(x int) {}
+`)
+
+	// The value sent (and the arguments of the calls) are evaluated before the operation itself
+	expected := `final 9
+0 -> 1 Call "make"
+1 -> 2 Call "make"
+2 -> 3 Call "compute"
+3 -> 4 Send "out"
+4 -> 5 Recv "out"
+5 -> 6 Call "fmt.Println"
+6 -> 7 Recv "in"
+7 -> 8 Spawn "report"
+8 -> 9 Epsilon "func-main-return@main.go:9:1"
+`
+	if text != expected {
+		t.Errorf("expected the ScopeAutomata\n%s\ngot\n%s", expected, text)
+	}
+}
+
+func TestReceivesInExpressions(t *testing.T) {
+	text := mainScopeAutomaton(t, `package main
+func main() {
+	a, b, c := make(chan int), make(chan int), make(chan int)
+	results := []int{0, 0}
+	x := 1 + <-a
+	v, ok := <-b
+	results[<-a] = <-c
+	var y = []int{<-b, x}
+	_, _, _ = v, ok, y
+	return <-c
+}
+`)
+
+	// The receives are extracted in evaluation order, the operands on the left of an assignment first
+	expected := `final 10
+0 -> 1 Call "make"
+1 -> 2 Call "make"
+2 -> 3 Call "make"
+3 -> 4 Recv "a"
+4 -> 5 Recv "b"
+5 -> 6 Recv "a"
+6 -> 7 Recv "c"
+7 -> 8 Recv "b"
+8 -> 9 Recv "c"
+9 -> 10 Epsilon "func-main-return@main.go:11:1"
+`
+	if text != expected {
+		t.Errorf("expected the ScopeAutomata\n%s\ngot\n%s", expected, text)
+	}
+}
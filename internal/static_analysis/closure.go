@@ -0,0 +1,126 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package static_analysis declares the types used to represent metadata extracted from the Go source.
+// The source code is transformed to an Abstract Syntax Tree via go/ast module.
+// Said AST is visited through the Visitor pattern all the metadata available are extractred
+// and agglomerated in a single comprehensive struct.
+//
+package static_analysis
+
+import (
+	"fmt"
+	"go/ast"
+	"sort"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+)
+
+// The name given to the n-th closure found in a function (e.g "main-func1")
+const closureNameTemplate = "%s-func%d"
+
+// ----------------------------------------------------------------------------
+// Closure related parsing method
+
+// This function parses a FuncLit (an anonymous function) that is either called or spawned as a
+// Goroutine. The closure is extracted as a standalone function of the file, under a generated name,
+// and the returned transition (of the given Move) references it by such name.
+//
+// The channels captured from the enclosing scope are turned into implicit arguments of the closure,
+// only the latter are declared after the explicit ones. In this way the "actual" channel is carried in
+// the transition payload and substituted as for any other argument, so that the closure keeps the
+// identity of the outer channel even when the enclosing function is inlined or spawned itself
+func parseFuncLit(lit *ast.FuncLit, call *ast.CallExpr, move fsa.MoveKind, fm *FuncMetadata) fsa.Transition {
+	closure := FuncMetadata{
+		Name:        fm.closureName(),
+		ChanMeta:    make(map[string]ChanMetadata),
+		InlineArgs:  make([]FuncArg, 0),
+		Automaton:   fsa.New(),
+		report:      fm.report,
+		functions:   fm.functions,
+		signatures:  fm.signatures,
+		methods:     fm.methods,
+		nilChannels: make(map[string]bool),
+		cursor:      new(fsa.StateID),
+		visiting:    fm.visiting,
+		scope:       &channelScope{},
+		flow:        &controlFlow{},
+		chanTypes:   fm.chanTypes,
+		typeInfo:    fm.typeInfo,
+	}
+
+	// Scope inheritance, the closure can access every channel (and constant) of the enclosing function.
+	// The channels declared in the blocks of the latter are known by the name they have in the closure
+	for name, meta := range fm.ChanMeta {
+		closure.ChanMeta[name] = meta
+	}
+	for name, identity := range fm.scope.shadowed() {
+		meta := fm.ChanMeta[identity]
+		meta.Name = name
+		closure.ChanMeta[name] = meta
+	}
+	closure.constants = propagateConstants(lit.Body, fm.constants)
+	closure.variables = typedVariables(lit.Type, ReceiverMetadata{}, lit.Body, fm.methods, fm.variables)
+
+	nArgs := parseFuncArgs(lit.Type, &closure)
+	// The captured channels take the positions after the explicit arguments, the ones that the elements of a
+	// variadic channel parameter would take as well, so the latter isn't supported for the closures
+	closure.Variadic, closure.variadic = FuncArg{}, nil
+	actualArgs := parseCallArgs(call, fm)
+
+	// Adds the captured channels both as formal argument of the closure and as actual one, the latter
+	// is the channel that the name refers to in the block where the closure is declared
+	for i, chanName := range capturedChannels(lit, fm) {
+		capturedArg := FuncArg{Offset: nArgs + i, Name: chanName, Type: Channel}
+		closure.InlineArgs = append(closure.InlineArgs, capturedArg)
+		actualArgs = append(actualArgs, FuncArg{Offset: nArgs + i, Name: fm.channelName(chanName), Type: Channel})
+	}
+
+	parseFuncBody(lit.Body, closure)
+	closure.ReturnArgs = returnedChannels(lit.Body, closure)
+	closure.DeadCode = deadOperations(closure)
+	fm.functions[closure.Name] = closure
+
+	return fsa.Transition{Move: move, Label: closure.Name, Payload: actualArgs}
+}
+
+// Returns the (sorted) names of the channels of the enclosing function used in the closure body.
+// The channels shadowed by an argument of the closure aren't captured, the ones shadowed by a
+// declaration inside the body instead are still considered captured (the body isn't visited yet)
+func capturedChannels(lit *ast.FuncLit, fm *FuncMetadata) []string {
+	shadowed := make(map[string]bool)
+	for _, arg := range lit.Type.Params.List {
+		for _, argIdent := range arg.Names {
+			shadowed[argIdent.Name] = true
+		}
+	}
+
+	captured := make(map[string]bool)
+	ast.Inspect(lit.Body, func(node ast.Node) bool {
+		if ident, isIdent := node.(*ast.Ident); isIdent {
+			if _, isChannel := fm.ChanMeta[fm.channelName(ident.Name)]; isChannel && !shadowed[ident.Name] {
+				captured[ident.Name] = true
+			}
+		}
+		return true
+	})
+
+	names := make([]string, 0, len(captured))
+	for name := range captured {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Generates a name for a new closure declared in the function, the name
+// is the first one (e.g "main-func1", "main-func2") not yet used in the file
+func (fm *FuncMetadata) closureName() string {
+	for n := 1; ; n++ {
+		name := fmt.Sprintf(closureNameTemplate, fm.Name, n)
+		if _, exist := fm.functions[name]; !exist {
+			return name
+		}
+	}
+}
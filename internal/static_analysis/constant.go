@@ -0,0 +1,263 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package static_analysis declares the types used to represent metadata extracted from the Go source.
+// The source code is transformed to an Abstract Syntax Tree via go/ast module.
+// Said AST is visited through the Visitor pattern all the metadata available are extractred
+// and agglomerated in a single comprehensive struct.
+//
+package static_analysis
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+)
+
+// ----------------------------------------------------------------------------
+// Constant propagation
+
+// A lightweight constant propagation pass over the given function body, returns the local variables that
+// have a constant value for the whole function. A variable is constant only if it's assigned exactly once
+// (e.g "n := 4" or "var n = 2 * size") with a constant expression and it's never modified afterwards (no
+// other assignment, increment or address taken). The names aren't resolved against their scope, so two
+// variables with the same name declared in different blocks are conservatively both non constant, while
+// the assignments made by the closures declared in the body are taken into account as well
+func propagateConstants(body *ast.BlockStmt, inherited map[string]constant.Value) map[string]constant.Value {
+	assignments := make(map[string]int)
+	definitions := []struct {
+		name  string
+		value ast.Expr
+	}{}
+
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch stmt := node.(type) {
+		case *ast.AssignStmt:
+			for i, lVal := range stmt.Lhs {
+				ident, isIdent := lVal.(*ast.Ident)
+				if !isIdent || ident.Name == "_" {
+					continue
+				}
+				assignments[ident.Name]++
+				// Only the single value assignments can be constant (e.g not "a, b := f()" or "n += 1")
+				isAssign := stmt.Tok == token.DEFINE || stmt.Tok == token.ASSIGN
+				if isAssign && len(stmt.Lhs) == len(stmt.Rhs) {
+					definitions = append(definitions, struct {
+						name  string
+						value ast.Expr
+					}{ident.Name, stmt.Rhs[i]})
+				}
+			}
+		case *ast.ValueSpec:
+			for i, ident := range stmt.Names {
+				assignments[ident.Name]++
+				if len(stmt.Names) == len(stmt.Values) {
+					definitions = append(definitions, struct {
+						name  string
+						value ast.Expr
+					}{ident.Name, stmt.Values[i]})
+				}
+			}
+		case *ast.IncDecStmt:
+			if ident, isIdent := stmt.X.(*ast.Ident); isIdent {
+				assignments[ident.Name]++
+			}
+		case *ast.UnaryExpr:
+			if ident, isIdent := stmt.X.(*ast.Ident); isIdent && stmt.Op == token.AND {
+				assignments[ident.Name]++
+			}
+		case *ast.RangeStmt:
+			for _, iterVar := range []ast.Expr{stmt.Key, stmt.Value} {
+				if ident, isIdent := iterVar.(*ast.Ident); isIdent {
+					assignments[ident.Name]++
+				}
+			}
+		}
+		return true
+	})
+
+	constants := make(map[string]constant.Value)
+	for name, value := range inherited {
+		if assignments[name] == 0 {
+			constants[name] = value
+		}
+	}
+
+	// The definitions are evaluated in source order, so a constant can be defined in terms of the previous ones
+	for _, definition := range definitions {
+		if assignments[definition.name] != 1 {
+			continue
+		}
+		if value := constantValue(definition.value, constants); value.Kind() != constant.Unknown {
+			constants[definition.name] = value
+		}
+	}
+
+	return constants
+}
+
+// Returns the value of the given constant expression, the identifiers are resolved at first among the given
+// local variables with a constant value and then through the objects of the AST (only the constants declared
+// in the same file with an explicit value are resolved, "iota" isn't evaluated). If the expression isn't
+// constant then the returned value is of the Unknown kind
+func constantValue(expr ast.Expr, locals map[string]constant.Value) constant.Value {
+	switch castExpr := expr.(type) {
+	case *ast.BasicLit:
+		return constant.MakeFromLiteral(castExpr.Value, castExpr.Kind, 0)
+	case *ast.ParenExpr:
+		return constantValue(castExpr.X, locals)
+	case *ast.UnaryExpr:
+		if operand := constantValue(castExpr.X, locals); operand.Kind() != constant.Unknown && castExpr.Op != token.ARROW {
+			return constant.UnaryOp(castExpr.Op, operand, 0)
+		}
+	case *ast.BinaryExpr:
+		x, y := constantValue(castExpr.X, locals), constantValue(castExpr.Y, locals)
+		if x.Kind() == constant.Unknown || y.Kind() == constant.Unknown {
+			break
+		}
+		switch castExpr.Op {
+		case token.SHL, token.SHR:
+			if shift, isExact := constant.Uint64Val(constant.ToInt(y)); isExact {
+				return constant.Shift(x, castExpr.Op, uint(shift))
+			}
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+			return constant.MakeBool(constant.Compare(x, castExpr.Op, y))
+		case token.QUO, token.REM:
+			if constant.Sign(y) == 0 {
+				break
+			}
+			// The division between two integers is an integer division
+			if castExpr.Op == token.QUO && x.Kind() == constant.Int && y.Kind() == constant.Int {
+				return constant.BinaryOp(x, token.QUO_ASSIGN, y)
+			}
+			return constant.BinaryOp(x, castExpr.Op, y)
+		default:
+			return constant.BinaryOp(x, castExpr.Op, y)
+		}
+	case *ast.Ident:
+		if value, isLocal := locals[castExpr.Name]; isLocal {
+			return value
+		}
+		if castExpr.Obj == nil || castExpr.Obj.Kind != ast.Con {
+			break
+		}
+		if valueSpec, isValueSpec := castExpr.Obj.Decl.(*ast.ValueSpec); isValueSpec {
+			for i, name := range valueSpec.Names {
+				if name.Name == castExpr.Name && i < len(valueSpec.Values) {
+					return constantValue(valueSpec.Values[i], locals)
+				}
+			}
+		}
+	}
+
+	return constant.MakeUnknown()
+}
+
+// Returns the value of the given expression as an int, the second value is false if the expression
+// isn't a constant integer (or the latter doesn't fit in an int)
+func constantInt(expr ast.Expr, locals map[string]constant.Value) (int, bool) {
+	value := constant.ToInt(constantValue(expr, locals))
+	if value.Kind() != constant.Int {
+		return 0, false
+	}
+	intValue, isExact := constant.Int64Val(value)
+	return int(intValue), isExact && int64(int(intValue)) == intValue
+}
+
+// ----------------------------------------------------------------------------
+// Loop bounds
+
+// Returns the number of iterations of a counting loop (e.g "for i := 0; i < numWorkers; i++"), that is
+// a loop whose counter is initialized, compared and incremented (or decremented) by constant values and
+// never modified in the body. The count is evaluated up to the given limit (limit + 1 is returned when
+// it's exceeded), the second return value is false if the loop isn't a counting loop
+func tripCount(stmt *ast.ForStmt, locals map[string]constant.Value, limit int) (int, bool) {
+	// The initialization of the counter (e.g "i := 0")
+	init, isAssign := stmt.Init.(*ast.AssignStmt)
+	if !isAssign || len(init.Lhs) != 1 || len(init.Rhs) != 1 || (init.Tok != token.DEFINE && init.Tok != token.ASSIGN) {
+		return 0, false
+	}
+	counter, isIdent := init.Lhs[0].(*ast.Ident)
+	if !isIdent {
+		return 0, false
+	}
+	start := constant.ToInt(constantValue(init.Rhs[0], locals))
+
+	// The increment of the counter (e.g "i++" or "i += 2")
+	var step constant.Value
+	switch post := stmt.Post.(type) {
+	case *ast.IncDecStmt:
+		if ident, isIdent := post.X.(*ast.Ident); isIdent && ident.Name == counter.Name {
+			step = constant.MakeInt64(1)
+			if post.Tok == token.DEC {
+				step = constant.MakeInt64(-1)
+			}
+		}
+	case *ast.AssignStmt:
+		if ident, isIdent := post.Lhs[0].(*ast.Ident); isIdent && ident.Name == counter.Name && len(post.Rhs) == 1 {
+			step = constant.ToInt(constantValue(post.Rhs[0], locals))
+			if post.Tok == token.SUB_ASSIGN {
+				step = constant.UnaryOp(token.SUB, step, 0)
+			} else if post.Tok != token.ADD_ASSIGN {
+				step = nil
+			}
+		}
+	}
+
+	cond, isBinary := stmt.Cond.(*ast.BinaryExpr)
+	if step == nil || !isBinary || start.Kind() != constant.Int || step.Kind() != constant.Int || constant.Sign(step) == 0 {
+		return 0, false
+	}
+	if isModifiedIn(stmt.Body, counter.Name) {
+		return 0, false
+	}
+
+	// The condition is evaluated replacing the counter with its value at each iteration
+	counterValue := map[string]constant.Value{}
+	for name, value := range locals {
+		counterValue[name] = value
+	}
+
+	iterations := 0
+	for current := start; iterations <= limit; iterations++ {
+		counterValue[counter.Name] = current
+		isTrue := constantValue(cond, counterValue)
+		if isTrue.Kind() != constant.Bool {
+			return 0, false
+		}
+		if !constant.BoolVal(isTrue) {
+			return iterations, true
+		}
+		current = constant.BinaryOp(current, token.ADD, step)
+	}
+
+	return iterations, true
+}
+
+// Returns true if the variable with the given name is assigned, incremented or has its address taken in the given node
+func isModifiedIn(node ast.Node, name string) bool {
+	isModified := false
+
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch stmt := node.(type) {
+		case *ast.AssignStmt:
+			for _, lVal := range stmt.Lhs {
+				if ident, isIdent := lVal.(*ast.Ident); isIdent && ident.Name == name {
+					isModified = true
+				}
+			}
+		case *ast.IncDecStmt:
+			if ident, isIdent := stmt.X.(*ast.Ident); isIdent && ident.Name == name {
+				isModified = true
+			}
+		case *ast.UnaryExpr:
+			if ident, isIdent := stmt.X.(*ast.Ident); isIdent && ident.Name == name && stmt.Op == token.AND {
+				isModified = true
+			}
+		}
+		return !isModified
+	})
+
+	return isModified
+}
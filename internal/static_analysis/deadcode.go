@@ -0,0 +1,115 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package static_analysis declares the types used to represent metadata extracted from the Go source.
+// The source code is transformed to an Abstract Syntax Tree via go/ast module.
+// Said AST is visited through the Visitor pattern all the metadata available are extractred
+// and agglomerated in a single comprehensive struct.
+//
+package static_analysis
+
+import (
+	"go/ast"
+	"go/token"
+	"sort"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+)
+
+// ----------------------------------------------------------------------------
+// DeadOperation
+
+// A DeadOperation is a channel operation or a spawn that can never be executed, since the state of the
+// ScopeAutomata it starts from is unreachable from the entry of the function (e.g the code that follows
+// a return in the same block, or the one after a statement whose branches all return)
+type DeadOperation struct {
+	State      fsa.StateID    // The (unreachable) state of the ScopeAutomata the transition starts from
+	Transition fsa.Transition // The Send, Recv or Spawn transition that can't be executed
+	Position   string         // The position in the source file ("file.go:line:column"), empty if not available
+}
+
+// ----------------------------------------------------------------------------
+// Returns
+
+// A controlFlow keeps track of how the body of a function is left, it's shared by the Visitor copies (as the cursor)
+//
+// The return statements leave the function right away: the state reached is linked to the final state of the
+// ScopeAutomata (see parseFuncBody) and the transitions that follow in the same block start from a new state
+// with no incoming transition. The position of every Send, Recv and Spawn is kept as well, so that the ones
+// left unreachable can be reported along with the source code they come from (see deadOperations)
+type controlFlow struct {
+	tail       ast.Stmt      // The last statement of the body, a return there is the same as reaching the closing brace
+	returns    []returnPoint // The states in which the other returns leave the function
+	operations []operation   // The channel operations and spawns emitted, in order
+}
+
+// A state in which a return statement leaves the function, along with the position of the latter
+type returnPoint struct {
+	state fsa.StateID
+	pos   token.Pos
+}
+
+// A Send, Recv or Spawn transition added to the ScopeAutomata, with the position of the statement it comes from
+type operation struct {
+	from fsa.StateID
+	t    fsa.Transition
+	pos  token.Pos
+}
+
+// Records the given transition if it's a channel operation or a spawn, no-op when the flow isn't tracked
+func (flow *controlFlow) addOperation(from fsa.StateID, t fsa.Transition, pos token.Pos) {
+	if flow != nil && (t.Move == fsa.Send || t.Move == fsa.Recv || t.Move == fsa.Spawn) {
+		flow.operations = append(flow.operations, operation{from, t, pos})
+	}
+}
+
+// Leaves the function from the current state, the next transitions (if any) are dead code until a
+// state reachable in another way is entered (e.g the merge state of a branch that doesn't return)
+func (fm *FuncMetadata) leaveFunction(stmt *ast.ReturnStmt) {
+	if fm.flow == nil || ast.Stmt(stmt) == fm.flow.tail {
+		return
+	}
+	fm.flow.returns = append(fm.flow.returns, returnPoint{fm.currentState(), stmt.Pos()})
+	fm.moveTo(fm.Automaton.AddState())
+}
+
+// Returns the channel operations and spawns of the given function that start from an unreachable state
+// of its ScopeAutomata, sorted by position in the source file (the unreachable states are kept, so that
+// the ScopeAutomata still shows them, the local views are pruned later on by the extraction)
+func deadOperations(fm FuncMetadata) []DeadOperation {
+	if fm.flow == nil {
+		return []DeadOperation{}
+	}
+
+	reachable := map[fsa.StateID]bool{fm.Automaton.InitialState(): true}
+	queue := []fsa.StateID{fm.Automaton.InitialState()}
+	outgoing := make(map[fsa.StateID][]fsa.StateID)
+	fm.Automaton.ForEachTransition(func(from, to int, _ fsa.Transition) {
+		outgoing[from] = append(outgoing[from], to)
+	})
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, to := range outgoing[current] {
+			if !reachable[to] {
+				reachable[to] = true
+				queue = append(queue, to)
+			}
+		}
+	}
+
+	dead := []operation{}
+	for _, op := range fm.flow.operations {
+		if !reachable[op.from] {
+			dead = append(dead, op)
+		}
+	}
+	sort.SliceStable(dead, func(i, j int) bool { return dead[i].pos < dead[j].pos })
+
+	deadCode := make([]DeadOperation, 0, len(dead))
+	for _, op := range dead {
+		deadCode = append(deadCode, DeadOperation{op.from, op.t, fm.report.position(op.pos)})
+	}
+	return deadCode
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the modeling of the return statements and the dead communication code
+package static_analysis
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+)
+
+func TestEarlyReturn(t *testing.T) {
+	text := mainScopeAutomaton(t, `package main
+func main() {
+	ch, fail := make(chan int), false
+	if fail {
+		return
+	}
+	ch <- 1
+	return
+}
+`)
+
+	// The if block leaves the function, the send happens only when it's skipped. The return at the end
+	// of the body is the same as reaching the closing brace, so it doesn't add anything
+	expected := `final 6
+0 -> 1 Call "make"
+1 -> 2 Epsilon "if-block-start@main.go:4:2"
+1 -> 4 Epsilon "if-block-skip@main.go:4:2"
+2 -> 6 Epsilon "func-main-return@main.go:5:3"
+3 -> 4 Epsilon "if-block-end@main.go:4:2"
+4 -> 5 Send "ch"
+5 -> 6 Epsilon "func-main-return@main.go:9:1"
+`
+	if text != expected {
+		t.Errorf("expected the ScopeAutomata\n%s\ngot\n%s", expected, text)
+	}
+}
+
+func TestDeadCode(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"main.go": `package main
+func worker(ch chan int) { ch <- 0 }
+func main() {
+	ch := make(chan int)
+	if len(ch) == 0 {
+		return
+		go worker(ch)
+	} else {
+		return
+	}
+	<-ch
+}
+`})
+
+	metadata := ExtractMetadata(filepath.Join(root, "main.go"), NoTrace)
+	deadCode := metadata.FunctionMeta["main"].DeadCode
+	if len(deadCode) != 2 {
+		t.Fatalf("expected the spawn and the receive to be dead code, got %v", deadCode)
+	}
+	if t0 := deadCode[0].Transition; t0.Move != fsa.Spawn || t0.Label != "worker" || deadCode[0].Position != "main.go:7:3" {
+		t.Errorf("expected the spawn after the first return, got %v", deadCode[0])
+	}
+	// Both the branches return, so the state in which they merge is never entered
+	if t1 := deadCode[1].Transition; t1.Move != fsa.Recv || t1.Label != "ch" || deadCode[1].Position != "main.go:11:2" {
+		t.Errorf("expected the receive after the if statement, got %v", deadCode[1])
+	}
+	if dead := metadata.FunctionMeta["worker"].DeadCode; len(dead) != 0 {
+		t.Errorf("expected no dead code in worker, got %v", dead)
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package static_analysis declares the types used to represent metadata extracted from the Go source.
+// The source code is transformed to an Abstract Syntax Tree via go/ast module.
+// Said AST is visited through the Visitor pattern all the metadata available are extractred
+// and agglomerated in a single comprehensive struct.
+//
+package static_analysis
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path"
+	"path/filepath"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+)
+
+// The name under which the interface dispatch extractor is registered (see EnableInterfaceDispatch)
+const InterfaceDispatchName = "interface-dispatch"
+
+// The kind of the branches of a call made through an interface (e.g "dispatch-case-0-start")
+const dispatchBranchKind = "dispatch"
+
+// ----------------------------------------------------------------------------
+// Interface dispatch (experimental)
+
+// Registers the interface dispatch extractor, an opt-in extension that resolves the calls (and spawns) made
+// through an interface (e.g "h.Handle(ch)" with "h Handler") to the methods of the types of the package that
+// implement it. The dynamic type isn't known statically, so the call is over-approximated with a branch for
+// each implementation (e.g "dispatch-case-0-start"): the communications made inside any of them aren't lost,
+// with the interleavings of the ones that never run as the price to pay. The types are resolved by go/types
+func EnableInterfaceDispatch() {
+	RegisterExtractor(InterfaceDispatchName, interfaceDispatchExtractor)
+}
+
+// The result of the type check of the analyzed package, the types are needed only by the interface dispatch
+type typeInfo struct {
+	pkg  *types.Package
+	info *types.Info
+}
+
+// Type checks the given files as a single package. The imported packages are type checked from their source when
+// they can be found locally (see moduleImporter), the other ones are left empty: only the types declared in the
+// analyzed package are needed to find the implementations, so the errors caused by the latter (e.g the functions
+// of an external package) are ignored and the check goes on
+func checkTypes(files []*ast.File, fileSet *token.FileSet) *typeInfo {
+	if len(files) == 0 {
+		return nil
+	}
+
+	// A malformed go.mod doesn't stop the analysis, the imported packages are left empty then
+	module, _ := FindModule(filepath.Dir(fileSet.Position(files[0].Pos()).Filename))
+	importer := &moduleImporter{module, fileSet, make(map[string]*types.Package)}
+
+	info := &types.Info{Selections: make(map[*ast.SelectorExpr]*types.Selection)}
+	pkg, _ := importer.config().Check(files[0].Name.Name, fileSet, files, info)
+	return &typeInfo{pkg, info}
+}
+
+// An importer that type checks the imported packages that belong to the given module (or to the modules vendored
+// or replaced with a local directory, see Module.PackageDir), the files are selected with the current build context.
+// An empty package, named after the last element of the import path, is returned for any other import path
+type moduleImporter struct {
+	module   *Module                   // The module of the analyzed package, nil if there's none
+	fileSet  *token.FileSet            // The FileSet shared with the analyzed package
+	packages map[string]*types.Package // The packages imported so far, by import path
+}
+
+// Returns the configuration of the type checks made with the importer, the errors are ignored (see checkTypes)
+func (importer *moduleImporter) config() *types.Config {
+	return &types.Config{Importer: importer, FakeImportC: true, Error: func(error) {}}
+}
+
+func (importer *moduleImporter) Import(importPath string) (*types.Package, error) {
+	if pkg, isImported := importer.packages[importPath]; isImported {
+		return pkg, nil
+	}
+
+	// The empty package is registered first, so that an import cycle ends there
+	pkg := types.NewPackage(importPath, path.Base(importPath))
+	pkg.MarkComplete()
+	importer.packages[importPath] = pkg
+	if importer.module == nil {
+		return pkg, nil
+	}
+	dirPath, isLocal := importer.module.PackageDir(importPath)
+	if !isLocal {
+		return pkg, nil
+	}
+	buildPkg, err := buildContext.ImportDir(dirPath, 0)
+	if err != nil {
+		return pkg, nil
+	}
+
+	files := []*ast.File{}
+	for _, fileName := range buildPkg.GoFiles {
+		if file, err := parser.ParseFile(importer.fileSet, filepath.Join(dirPath, fileName), nil, 0); err == nil {
+			files = append(files, file)
+		}
+	}
+	checked, _ := importer.config().Check(importPath, importer.fileSet, files, nil)
+	if checked != nil {
+		importer.packages[importPath] = checked
+		return checked, nil
+	}
+	return pkg, nil
+}
+
+// Returns the names of the methods called by the given selector when its operand is an interface (e.g "Server.Handle"
+// and "Proxy.Handle" for "h.Handle"), one for each type of the package that implements the latter either with its
+// value or its pointer (see MethodName). The methods are sorted by name, nothing is returned for any other selector
+func (ti *typeInfo) implementations(selector *ast.SelectorExpr) []string {
+	selection, isSelection := ti.info.Selections[selector]
+	if !isSelection || selection.Kind() != types.MethodVal {
+		return nil
+	}
+	iface, isInterface := selection.Recv().Underlying().(*types.Interface)
+	if !isInterface {
+		return nil
+	}
+
+	methods, found := []string{}, make(map[string]bool)
+	scope := ti.pkg.Scope()
+	for _, name := range scope.Names() {
+		typeName, isTypeName := scope.Lookup(name).(*types.TypeName)
+		if !isTypeName || typeName.IsAlias() || types.IsInterface(typeName.Type()) {
+			continue
+		}
+		pointer := types.NewPointer(typeName.Type())
+		if !types.Implements(typeName.Type(), iface) && !types.Implements(pointer, iface) {
+			continue
+		}
+
+		// The method could be promoted from an embedded type, the one that declares it is called instead
+		object, _, _ := types.LookupFieldOrMethod(pointer, false, ti.pkg, selector.Sel.Name)
+		method, isMethod := object.(*types.Func)
+		if !isMethod || method.Pkg() != ti.pkg {
+			continue
+		}
+		receiver := method.Type().(*types.Signature).Recv().Type()
+		if pointer, isPointer := receiver.(*types.Pointer); isPointer {
+			receiver = pointer.Elem()
+		}
+		named, isNamed := receiver.(*types.Named)
+		if !isNamed {
+			continue
+		}
+
+		methodName := MethodName(named.Obj().Name(), method.Name())
+		if !found[methodName] {
+			found[methodName] = true
+			methods = append(methods, methodName)
+		}
+	}
+	return methods
+}
+
+// The Extractor that replaces a call (or a spawn) made through an interface with the implementations found, on
+// their own branch. The calls without any implementation in the package are left to the default handling
+func interfaceDispatchExtractor(node ast.Node, fm *FuncMetadata) bool {
+	call, move := (*ast.CallExpr)(nil), fsa.Call
+	goStmt, isGoStmt := node.(*ast.GoStmt)
+	switch castNode := node.(type) {
+	case *ast.CallExpr:
+		call = castNode
+	case *ast.GoStmt:
+		call, move = castNode.Call, fsa.Spawn
+	default:
+		return false
+	}
+
+	selector, isSelector := call.Fun.(*ast.SelectorExpr)
+	if !isSelector || fm.typeInfo == nil {
+		return false
+	}
+	methods := fm.typeInfo.implementations(selector)
+	if len(methods) == 0 {
+		return false
+	}
+
+	// The arguments are evaluated once, before the dynamic type is known
+	parseCallArgExprs(call, fm)
+	actualArgs := parseCallArgs(call, fm)
+	if isGoStmt {
+		actualArgs = fm.withSite(actualArgs, goStmt)
+	}
+	if len(methods) == 1 {
+		fm.emit(fsa.Transition{Move: move, Label: methods[0], Payload: actualArgs})
+		return true
+	}
+
+	forkStateId, mergeStateId := fm.currentState(), fsa.Unknown
+	for i, method := range methods {
+		fm.emitFrom(forkStateId, fsa.BranchCaseStart.With(dispatchBranchKind, i).Transition())
+		fm.emit(fsa.Transition{Move: move, Label: method, Payload: actualArgs})
+
+		tEpsEnd := fsa.BranchCaseEnd.With(dispatchBranchKind, i).Transition()
+		if mergeStateId == fsa.Unknown {
+			mergeStateId = fm.emit(tEpsEnd)
+		} else {
+			fm.addTransition(fm.currentState(), mergeStateId, tEpsEnd)
+		}
+	}
+	fm.moveTo(mergeStateId)
+	return true
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the resolution of the calls made through an interface to the implementations in the package
+package static_analysis
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+const dispatchSource = `package main
+type Handler interface{ Handle(ch chan int) }
+type Logger struct{}
+type Relay struct{ Logger }
+type Forwarder struct{}
+func (Logger) Handle(ch chan int) { <-ch }
+func (f *Forwarder) Handle(ch chan int) { ch <- 1 }
+func (f Forwarder) Close() {}
+func serve(h Handler, ch chan int) {
+	h.Handle(ch)
+	go h.Handle(ch)
+}
+func main() {}
+`
+
+func TestInterfaceDispatch(t *testing.T) {
+	defer func() { extractors = []namedExtractor{} }()
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"main.go": dispatchSource})
+
+	// Without the extractor the call through the interface is only reported
+	metadata := ExtractMetadata(filepath.Join(root, "main.go"), NoTrace)
+	if metadata.Unsupported.Len() != 2 {
+		t.Errorf("expected the calls through the interface to be reported, got %s", metadata.Unsupported)
+	}
+
+	// The method promoted to Relay is the one of Logger, so it's called only once
+	EnableInterfaceDispatch()
+	metadata = ExtractMetadata(filepath.Join(root, "main.go"), NoTrace)
+	expected := "final 11\n" +
+		"0 -> 1 Epsilon \"dispatch-case-0-start@main.go:10:2\"\n" +
+		"0 -> 4 Epsilon \"dispatch-case-1-start@main.go:10:2\"\n" +
+		"1 -> 2 Call \"Forwarder.Handle\"\n" +
+		"2 -> 3 Epsilon \"dispatch-case-0-end@main.go:10:2\"\n" +
+		"3 -> 6 Epsilon \"dispatch-case-0-start@main.go:11:2\"\n" +
+		"3 -> 9 Epsilon \"dispatch-case-1-start@main.go:11:2\"\n" +
+		"4 -> 5 Call \"Logger.Handle\"\n" +
+		"5 -> 3 Epsilon \"dispatch-case-1-end@main.go:10:2\"\n" +
+		"6 -> 7 Spawn \"Forwarder.Handle\"\n" +
+		"7 -> 8 Epsilon \"dispatch-case-0-end@main.go:11:2\"\n" +
+		"8 -> 11 Epsilon \"func-serve-return@main.go:12:1\"\n" +
+		"9 -> 10 Spawn \"Logger.Handle\"\n" +
+		"10 -> 8 Epsilon \"dispatch-case-1-end@main.go:11:2\"\n"
+	if text := metadata.FunctionMeta["serve"].Automaton.String(); text != expected {
+		t.Errorf("expected the automaton of serve\n%s\ngot\n%s", expected, text)
+	}
+	if metadata.Unsupported.Len() != 0 {
+		t.Errorf("expected the calls through the interface to be resolved, got %s", metadata.Unsupported)
+	}
+}
+
+func TestInterfaceDispatchAcrossPackages(t *testing.T) {
+	defer func() { extractors = []namedExtractor{} }()
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"go.mod":     "module example.com/app\n",
+		"lib/lib.go": "package lib\ntype Handler interface{ Handle(ch chan int) }\n",
+		"main.go":    "package main\nimport \"example.com/app/lib\"\ntype Echo struct{}\nfunc (Echo) Handle(ch chan int) { ch <- 1 }\nfunc serve(h lib.Handler, ch chan int) { h.Handle(ch) }\nfunc main() {}\n",
+	})
+
+	// The interface is declared in another package of the module, found through the go.mod
+	EnableInterfaceDispatch()
+	metadata := ExtractPackageMetadata(root, NoTrace)
+	expected := "final 2\n0 -> 1 Call \"Echo.Handle\"\n1 -> 2 Epsilon \"func-serve-return@main.go:5:55\"\n"
+	if text := metadata.FunctionMeta["serve"].Automaton.String(); text != expected {
+		t.Errorf("expected the automaton of serve\n%s\ngot\n%s", expected, text)
+	}
+}
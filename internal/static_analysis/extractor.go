@@ -0,0 +1,97 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package static_analysis declares the types used to represent metadata extracted from the Go source.
+// The source code is transformed to an Abstract Syntax Tree via go/ast module.
+// Said AST is visited through the Visitor pattern all the metadata available are extractred
+// and agglomerated in a single comprehensive struct.
+//
+package static_analysis
+
+import (
+	"go/ast"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+)
+
+// The custom extractors registered, in registration order
+var extractors = []namedExtractor{}
+
+// ----------------------------------------------------------------------------
+// Custom extractors
+
+// An Extractor is a custom handler that teaches the static analysis about some construct that it
+// doesn't know natively, for example an in-house messaging wrapper whose "bus.Publish(topic, msg)"
+// should be modeled as a Send on the topic. The extractor receives every statement visited inside
+// a function body and every function call (e.g the "bus.Publish()" of the ExprStmt above) and, if
+// it recognizes the node, it updates the given FuncMetadata accordingly (see AddChannelOp and
+// DeclareChannel) and returns true. In this case the default handling of the node is skipped
+type Extractor func(node ast.Node, fm *FuncMetadata) bool
+
+type namedExtractor struct {
+	name      string
+	extractor Extractor
+}
+
+// Registers the given extractor under the given name, the extractors are tried in registration order and
+// the first one that handles a node wins. Registering again a name replaces the previous extractor. This
+// is meant to be called from an init() function, either of a fork or of a Go plugin loaded via CLI
+func RegisterExtractor(name string, extractor Extractor) {
+	for i, item := range extractors {
+		if item.name == name {
+			extractors[i].extractor = extractor
+			return
+		}
+	}
+	extractors = append(extractors, namedExtractor{name, extractor})
+}
+
+// Returns the names of the extractors registered, in registration order
+func RegisteredExtractors() []string {
+	names := make([]string, len(extractors))
+	for i, item := range extractors {
+		names[i] = item.name
+	}
+	return names
+}
+
+// Returns true if an extractor is registered under the given name
+func isRegistered(name string) bool {
+	for _, item := range extractors {
+		if item.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Runs the registered extractors on the given node, returns true if one of them handled it
+func runExtractors(node ast.Node, fm *FuncMetadata) bool {
+	for _, item := range extractors {
+		if item.extractor(node, fm) {
+			return true
+		}
+	}
+	return false
+}
+
+// Adds to the automaton of the function a transition of the given Move (Send or Recv) on the given
+// channel. The channel doesn't need to be declared, in this case it's treated as a global one and its
+// name is used to match the operations of the other Goroutines (e.g the topic of a messaging wrapper)
+func (fm *FuncMetadata) AddChannelOp(move fsa.MoveKind, channel string) {
+	channel = fm.channelName(channel)
+	chanMeta, exist := fm.ChanMeta[channel]
+	if !exist {
+		chanMeta = ChanMetadata{Name: channel}
+	}
+
+	t := fsa.Transition{Move: move, Label: channel, Payload: chanMeta}
+	fm.emit(t)
+}
+
+// Declares a new channel in the function scope (e.g the one returned by "bus.Subscribe()"), an existing
+// channel with the same name is overwritten. As for the other channels both the Name and Type are mandatory
+func (fm *FuncMetadata) DeclareChannel(channel ChanMetadata) {
+	fm.addChannels(channel)
+}
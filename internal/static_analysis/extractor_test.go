@@ -0,0 +1,63 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the registration and execution of the custom extractors
+package static_analysis
+
+import (
+	"go/ast"
+	"go/types"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+)
+
+// Models "bus.Publish(topic, msg)" and "bus.Subscribe(topic)" as a Send and a Recv on the topic
+func busExtractor(node ast.Node, fm *FuncMetadata) bool {
+	call, isCall := node.(*ast.CallExpr)
+	if !isCall || len(call.Args) == 0 {
+		return false
+	}
+	topic, isLit := call.Args[0].(*ast.BasicLit)
+	if !isLit {
+		return false
+	}
+	name, _ := strconv.Unquote(topic.Value)
+
+	switch types.ExprString(call.Fun) {
+	case "bus.Publish":
+		fm.AddChannelOp(fsa.Send, name)
+	case "bus.Subscribe":
+		fm.AddChannelOp(fsa.Recv, name)
+	default:
+		return false
+	}
+	return true
+}
+
+func TestCustomExtractor(t *testing.T) {
+	defer func() { extractors = []namedExtractor{} }()
+	RegisterExtractor("bus", func(ast.Node, *FuncMetadata) bool { return false })
+	RegisterExtractor("bus", busExtractor)
+	if names := RegisteredExtractors(); len(names) != 1 || names[0] != "bus" {
+		t.Fatalf("expected a single extractor 'bus', got %v", names)
+	}
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"main.go": "package main\nimport \"bus\"\nfunc main() {\n\tbus.Publish(\"orders\", 1)\n\tmsg := bus.Subscribe(\"invoices\")\n\t_ = msg\n}\n",
+	})
+
+	metadata := ExtractMetadata(filepath.Join(root, "main.go"), NoTrace)
+	if metadata.Unsupported.Len() != 0 {
+		t.Errorf("expected the calls handled by the extractor not to be reported, got %s", metadata.Unsupported)
+	}
+
+	expected := "final 3\n0 -> 1 Send \"orders\"\n1 -> 2 Recv \"invoices\"\n2 -> 3 Epsilon \"func-main-return@main.go:7:1\"\n"
+	if text := metadata.FunctionMeta["main"].Automaton.String(); text != expected {
+		t.Errorf("expected the automaton\n%s\ngot\n%s", expected, text)
+	}
+}
@@ -11,7 +11,10 @@ package static_analysis
 
 import (
 	"go/ast"
+	"go/token"
 	"log"
+
+	"github.com/its-hmny/Choreia/internal/tracing"
 )
 
 // ----------------------------------------------------------------------------
@@ -23,8 +26,15 @@ import (
 // gather from the parsed file. The data are structured hierarchically:
 // Module -> File -> Function -> Channels
 type FileMetadata struct {
-	GlobalChanMeta map[string]ChanMetadata // The channel declared in the global scope
-	FunctionMeta   map[string]FuncMetadata // The top-level function declared in the file
+	GlobalChanMeta map[string]ChanMetadata  // The channel declared in the global scope
+	FunctionMeta   map[string]FuncMetadata  // The top-level function (and methods, see MethodName) declared in the file
+	Package        PackageMetadata          // The metadata about the package as a whole (e.g the init functions)
+	Unsupported    *UnsupportedReport       // The constructs that the analysis isn't able to model
+	signatures     map[string][]string      // The message type of the channels returned by each function
+	methods        map[string][]string      // The names of the methods declared on each (receiver) type
+	chanTypes      map[string]*ast.ChanType // The channel types declared with a name (e.g "type Jobs chan Job")
+	initNames      map[*ast.FuncDecl]string // The names given to the init functions (see PackageMetadata)
+	typeInfo       *typeInfo                // The types of the package, only for the interface dispatch (see checkTypes)
 }
 
 // Adds the given metadata about some channel(s) to the FileMetadata struct
@@ -35,7 +45,7 @@ func (fm *FileMetadata) addChannelMeta(newChanMeta ...ChanMetadata) {
 	// Adds/updates the associations
 	for _, channel := range newChanMeta {
 		// Checks the validity of the current item
-		if channel.Name != "" && channel.Type != "" {
+		if isBoundChannel(channel) {
 			fm.GlobalChanMeta[channel.Name] = channel
 		}
 	}
@@ -50,11 +60,14 @@ func (fm FileMetadata) Visit(node ast.Node) ast.Visitor {
 	if node == nil {
 		return nil
 	}
+	if tracing.Enabled(tracing.Extended) {
+		tracing.Nodef("static-analysis", "", fm.Unsupported.position(node.Pos()), "%T", node)
+	}
 
 	switch stmt := node.(type) {
 	// In this case we're interested in extrapolating info about global channel declaration
 	case *ast.GenDecl:
-		newChannels := parseGenDecl(stmt)
+		newChannels := parseGenDecl(stmt, nil, fm.chanTypes)
 		fm.addChannelMeta(newChannels...)
 		return nil
 	// Obviously we want to extrapolate data about the declared function (and their action)
@@ -73,17 +86,56 @@ func (fm FileMetadata) Visit(node ast.Node) ast.Visitor {
 // ----------------------------------------------------------------------------
 // File related parsing method
 
-// This function handles the extraction of metadata about the given file, it simply
-// receives an *ast.File as input and call ast.Walk on it. Whenever it encounters something
-// interesting such as global channel or function declaration it saves the metadata available
-func parseAstFile(file *ast.File) FileMetadata {
+// This function handles the extraction of metadata about a package made of more files, the
+// metadata are agglomerated in a single FileMetadata struct. The global channels are collected
+// from every file before the functions are visited, since a function can reference a global
+// channel declared in another file of the same package. For the same reason the channel types
+// returned by each function are collected as well (the callee can be declared after the caller), as the named channel types.
+// The init functions are named in the order in which they run, the global channels they create are collected upfront too.
+// The FileSet is used only to retrieve the line of the unsupported constructs found
+func parseAstFiles(files []*ast.File, fileSet *token.FileSet) FileMetadata {
 	// Initializes the FileMetadata struct
 	metadata := FileMetadata{
 		GlobalChanMeta: map[string]ChanMetadata{},
 		FunctionMeta:   map[string]FuncMetadata{},
+		Package:        PackageMetadata{InitFlow: []string{}},
+		Unsupported:    NewUnsupportedReport(fileSet),
+		signatures:     map[string][]string{},
+		methods:        map[string][]string{},
+		chanTypes:      namedChannelTypes(files),
+		initNames:      map[*ast.FuncDecl]string{},
+	}
+	if len(files) > 0 {
+		metadata.Package.Name = files[0].Name.Name
 	}
+	// The type check is needed only to resolve the calls made through an interface
+	if isRegistered(InterfaceDispatchName) {
+		metadata.typeInfo = checkTypes(files, fileSet)
+	}
+
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			switch castDecl := decl.(type) {
+			case *ast.GenDecl:
+				metadata.addChannelMeta(parseGenDecl(castDecl, nil, metadata.chanTypes)...)
+			case *ast.FuncDecl:
+				metadata.signatures[declarationName(castDecl)] = resultChannelTypes(castDecl.Type, metadata.chanTypes)
+				if castDecl.Recv != nil && len(castDecl.Recv.List) == 1 {
+					receiver := receiverType(castDecl.Recv.List[0].Type)
+					metadata.methods[receiver] = append(metadata.methods[receiver], castDecl.Name.Name)
+				}
+				if isInitFunc(castDecl) {
+					metadata.initNames[castDecl] = metadata.Package.addInit()
+					metadata.addChannelMeta(initChannels(castDecl, metadata.chanTypes)...)
+				}
+			}
+		}
+	}
+
 	// With Walk() descends the AST in depth-first order
-	ast.Walk(metadata, file)
+	for _, file := range files {
+		ast.Walk(metadata, file)
+	}
 	// Returns the collected data
 	return metadata
 }
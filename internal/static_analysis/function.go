@@ -12,14 +12,21 @@ package static_analysis
 import (
 	"fmt"
 	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strings"
 
 	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	"github.com/its-hmny/Choreia/internal/tracing"
 )
 
 const (
 	Function ArgType = iota // Possible value of FuncArg.type
 	Channel
-	anonymousFunc = "anonymousFunc" // Constant to identify anonymous function
+	Result
+	Site
 )
 
 // ----------------------------------------------------------------------------
@@ -31,10 +38,32 @@ const (
 // extrapolate from the function declaration. Only the function declared in the file
 // by the user are evaluated (built-in and external functions are ignored)
 type FuncMetadata struct {
-	Name       string                  // The identifier of the function
-	ChanMeta   map[string]ChanMetadata // The channels available inside the function scope
-	InlineArgs []FuncArg               // The argument of the function to be inlined (Callbacks/Functions or Channels)
-	Automaton  *fsa.FSA                // A graph representing the transition made inside the function body
+	Name        string                    // The identifier of the function (e.g "Server.Run" for the methods, see MethodName)
+	Receiver    ReceiverMetadata          // The receiver of the method, without Type for the plain functions
+	ChanMeta    map[string]ChanMetadata   // The channels available inside the function scope
+	InlineArgs  []FuncArg                 // The argument of the function to be inlined (Callbacks/Functions or Channels)
+	Variadic    FuncArg                   // The variadic channel parameter (e.g "chs ...chan int"), without Name if there isn't one
+	ReturnArgs  []FuncArg                 // The channels returned by the function (the Offset is the position among the results)
+	DeadCode    []DeadOperation           // The channel operations and spawns that can never be executed (see deadOperations)
+	Automaton   *fsa.FSA                  // A graph representing the transition made inside the function body
+	IsStub      bool                      // The automaton is an hand-written model of an external function (see AddStub)
+	External    bool                      // The function is declared without a body (e.g in assembly), see Modeled
+	Endpoint    string                    // The network endpoint served, only for the handlers modeled by the network extractor
+	report      *UnsupportedReport        // The (file wide) report where the unsupported constructs are added
+	functions   map[string]FuncMetadata   // The functions of the file, where the closures found are registered
+	signatures  map[string][]string       // The message type of the channels returned by each function of the file
+	methods     map[string][]string       // The methods declared in the file, by name of the receiver type
+	variables   map[string]string         // The variables whose type declares some methods (see typedVariables)
+	nilChannels map[string]bool           // The channels assigned to nil in the function body
+	yields      map[string]*ast.BlockStmt // The bodies of the range-over-func loops, by name of their yield callback
+	constants   map[string]constant.Value // The local variables with a constant value (see propagateConstants)
+	cursor      *fsa.StateID              // The state from which the next transition starts (shared by the Visitor copies)
+	visiting    *token.Pos                // The node being visited, the position of the transitions traced (shared as well)
+	scope       *channelScope             // The blocks being visited with the channels declared inside (shared as well)
+	flow        *controlFlow              // The returns and the channel operations found in the body (shared as well)
+	variadic    *variadicParam            // The variadic channel parameter and its elements accessed (shared as well)
+	chanTypes   map[string]*ast.ChanType  // The channel types declared with a name in the file (see namedChannelTypes)
+	typeInfo    *typeInfo                 // The types of the package, nil unless the interface dispatch is enabled
 }
 
 type FuncArg struct {
@@ -45,20 +74,110 @@ type FuncArg struct {
 
 type ArgType int // Enum of the arguments type that we're interested in
 
+// Converts the ArgType to a general pourpose string format.
+func (at ArgType) String() string {
+	switch at {
+	case Function:
+		return "function"
+	case Channel:
+		return "channel"
+	case Result:
+		return "result"
+	case Site:
+		return "site"
+	default:
+		return "unknown"
+	}
+}
+
+// Returns true if the automaton models the behaviour of the function, that is always the case except for the
+// External functions: their automaton is empty, so their calls are replaced with an eps transition until a
+// stub with the same name is given (see AddStub)
+func (fm FuncMetadata) Modeled() bool {
+	return !fm.External || fm.IsStub
+}
+
 // Adds the given metadata about some channel(s) to the FuncMetadata struct
 // In case a channel with the same name already exist then the previous association
 // is overwritten, this is correct since the channel name is the variable to which
-// the channel is assigned and this means that a new assignment was made to that variable
+// the channel is assigned and this means that a new assignment was made to that variable.
+// The name is resolved in the block being visited, the declarations have their own handling (see declareChannels)
 func (fm *FuncMetadata) addChannels(newChanMeta ...ChanMetadata) {
 	// Adds or updates the associations
 	for _, channel := range newChanMeta {
 		// Checks the validity of the current item
-		if channel.Name != "" && channel.Type != "" {
+		if isBoundChannel(channel) {
+			channel.Name = fm.channelName(channel.Name)
 			fm.ChanMeta[channel.Name] = channel
 		}
 	}
 }
 
+// Returns the state of the ScopeAutomata from which the next transition of the function body starts
+func (fm *FuncMetadata) currentState() fsa.StateID {
+	return *fm.cursor
+}
+
+// Moves the ScopeAutomata to the given state, the next transition of the function body will start from it
+// (e.g. after a branching statement the execution flow continues from the state in which the branches merge)
+func (fm *FuncMetadata) moveTo(id fsa.StateID) {
+	*fm.cursor = id
+}
+
+// Adds the given transition from the given state to a new one, the latter becomes the current state
+// of the ScopeAutomata and its id is returned
+func (fm *FuncMetadata) emitFrom(from fsa.StateID, t fsa.Transition) fsa.StateID {
+	fm.moveTo(fm.addTransition(from, fm.Automaton.AddState(), t))
+	return fm.currentState()
+}
+
+// Adds the given transition from the current state of the ScopeAutomata to a new one (see emitFrom)
+func (fm *FuncMetadata) emit(t fsa.Transition) fsa.StateID {
+	return fm.emitFrom(fm.currentState(), t)
+}
+
+// Adds the given transition between the given states of the ScopeAutomata and returns the destination one,
+// the transition comes from the node being visited (see Visit and addTransitionAt)
+func (fm *FuncMetadata) addTransition(from, to fsa.StateID, t fsa.Transition) fsa.StateID {
+	position := token.NoPos
+	if fm.visiting != nil {
+		position = *fm.visiting
+	}
+	return fm.addTransitionAt(from, to, t, position)
+}
+
+// Adds the given transition between the given states of the ScopeAutomata and returns the destination one,
+// the transition is traced along with the given position of the statement it comes from. The eps transitions
+// are the structural ones (e.g the start of an if block), their label is made unique by the position as well
+// (see PositionedLabel) otherwise the same branch of two statements couldn't be told apart
+func (fm *FuncMetadata) addTransitionAt(from, to fsa.StateID, t fsa.Transition, position token.Pos) fsa.StateID {
+	if t.Move == fsa.Eps {
+		t.Label = PositionedLabel(t.Label, fm.report.position(position))
+	}
+	if tracing.Enabled(tracing.Basic) {
+		tracing.Transitionf("static-analysis", fm.Name, fm.report.position(position), "%d -> %d %s", from, to, t)
+	}
+	fm.flow.addOperation(from, t, position)
+	return fm.Automaton.AddTransition(from, to, t)
+}
+
+// Returns the given label of a structural eps transition followed by the given position of the statement it comes
+// from (e.g "if-block-start@main.go:12:2"), the label is returned as it is when the position isn't available
+func PositionedLabel(label, position string) string {
+	if position == "" {
+		return label
+	}
+	return label + fsa.PositionSeparator + position
+}
+
+// Returns the label of a structural eps transition without its position (e.g "if-block-start"), see PositionedLabel
+func StructuralLabel(label string) string {
+	if index := strings.Index(label, fsa.PositionSeparator); index >= 0 {
+		return label[:index]
+	}
+	return label
+}
+
 // In order to satisfy the ast.Visitor interface FuncMetadata implements
 // the Visit() method with this function signature. The Visit method takes as
 // only argument an ast.Node interface and evaluates all the meaningful cases,
@@ -69,7 +188,28 @@ func (fm FuncMetadata) Visit(node ast.Node) ast.Visitor {
 		return nil
 	}
 
+	// The transitions emitted while handling the node are traced with its position, the enclosing
+	// node is restored afterwards (e.g the merge of the branches after the body of an IfStmt)
+	if fm.visiting != nil {
+		enclosing := *fm.visiting
+		*fm.visiting = node.Pos()
+		defer func() { *fm.visiting = enclosing }()
+	}
+	if tracing.Enabled(tracing.Extended) {
+		tracing.Nodef("static-analysis", fm.Name, fm.report.position(node.Pos()), "%T", node)
+	}
+
+	// The custom extractors take precedence over the default handling of the node
+	if _, isStmt := node.(ast.Stmt); isStmt && runExtractors(node, &fm) {
+		return nil
+	}
+
 	switch stmt := node.(type) {
+	// Blocks (and clauses) open a new scope for the channels declared inside them, closed by blockVisitor
+	case *ast.BlockStmt, *ast.CaseClause, *ast.CommClause:
+		fm.scope.open()
+		return blockVisitor{fm}
+
 	// Handle for-range loops (e.g "for index, item := range list")
 	case *ast.RangeStmt:
 		parseRangeStmt(stmt, &fm)
@@ -120,10 +260,21 @@ func (fm FuncMetadata) Visit(node ast.Node) ast.Visitor {
 		parseAssignStmt(stmt, &fm)
 		return nil
 
+	// Statement to return the values of the function, they may contain receives and calls
+	case *ast.ReturnStmt:
+		parseReturnStmt(stmt, &fm)
+		return nil
+
 	// Statement to declare a new variable (channel decl)
 	case *ast.DeclStmt:
 		parseDeclStmt(stmt, &fm)
 		return nil
+
+	// The calls to the yield callback of a range-over-func loop inside expressions (e.g "if !yield(v)")
+	case *ast.CallExpr:
+		if parseYieldCall(stmt, &fm) {
+			return nil
+		}
 	}
 	return fm
 }
@@ -132,19 +283,34 @@ func (fm FuncMetadata) Visit(node ast.Node) ast.Visitor {
 // Function related parsing method
 
 // This function parses a FuncDecl statement and saves the data extracted in a FuncMetadata struct.
-// In case of strange condition (function declared in another module or C function called fromGo code)
-// then no metadata are extracted and the execution will resume parsing the global scope.
+// The functions declared without a body (implemented in assembly or linked with "//go:linkname") are
+// stored as External, with their arguments but an empty automaton, and reported as unsupported.
+// The methods are stored after their receiver type (see MethodName), the calls made on the latter are resolved to them
 func parseFuncDecl(stmt *ast.FuncDecl, fm FileMetadata) {
-	// Retrieve function name and arguments
-	funcName := stmt.Name.Name
-	funcArgs := stmt.Type.Params.List
+	// Retrieve function name, the init functions have their own since they're all named "init"
+	funcName, isInit := fm.initNames[stmt]
+	if !isInit {
+		funcName = declarationName(stmt)
+	}
 
 	// Initial setup of the metadata record
 	metadata := FuncMetadata{
-		Name:       funcName,
-		ChanMeta:   make(map[string]ChanMetadata),
-		InlineArgs: make([]FuncArg, 0),
-		Automaton:  fsa.New(),
+		Name:        funcName,
+		Receiver:    parseReceiver(stmt),
+		ChanMeta:    make(map[string]ChanMetadata),
+		InlineArgs:  make([]FuncArg, 0),
+		Automaton:   fsa.New(),
+		report:      fm.Unsupported,
+		functions:   fm.FunctionMeta,
+		signatures:  fm.signatures,
+		methods:     fm.methods,
+		nilChannels: make(map[string]bool),
+		cursor:      new(fsa.StateID),
+		visiting:    new(token.Pos),
+		scope:       &channelScope{},
+		flow:        &controlFlow{},
+		chanTypes:   fm.chanTypes,
+		typeInfo:    fm.typeInfo,
 	}
 
 	// Copies the global scope channel in the nested scope of the function.
@@ -153,118 +319,274 @@ func parseFuncDecl(stmt *ast.FuncDecl, fm FileMetadata) {
 		metadata.ChanMeta[name] = meta
 	}
 
-	// If the current is an external (non Go) function then is skipped since
-	// it isn't useful in order to evaluate the choreography of the automon
+	// If the current is an external (non Go) function there's nothing to parse, it's tagged so that its
+	// calls aren't mistaken for the ones of an empty function (unless a stub models it, see AddStub)
 	if stmt.Body == nil {
+		metadata.External = true
+		parseFuncArgs(stmt.Type, &metadata)
+		fm.Unsupported.Add(ExternalFunction, funcName, funcName, stmt.Pos())
+		fm.FunctionMeta[funcName] = metadata
 		return
 	}
 
-	// If the function has arguments we search for channels or callback/functions since
-	// this are relevant for the Choreography Automata and must be "inlined" later on
-	if len(funcArgs) > 0 {
-		for i, arg := range funcArgs {
-			// Extrapolates the argument name and type
-			argName := arg.Names[0].Name
-			_, isChannel := arg.Type.(*ast.ChanType)
-			_, isFunction := arg.Type.(*ast.FuncType)
+	metadata.constants = propagateConstants(stmt.Body, nil)
+	metadata.variables = typedVariables(stmt.Type, metadata.Receiver, stmt.Body, fm.methods, nil)
+	parseFuncArgs(stmt.Type, &metadata)
+	parseFuncBody(stmt.Body, metadata)
+	metadata.addVariadicArgs()
+	metadata.ReturnArgs = returnedChannels(stmt.Body, metadata)
+	metadata.DeadCode = deadOperations(metadata)
+
+	// At last all the data extracted is returned
+	fm.FunctionMeta[funcName] = metadata
+}
+
+// This function parses the arguments of a function (either declared or literal) searching for
+// channels or callback/functions since this are relevant for the Choreography Automata and
+// must be "inlined" later on. Returns the number of arguments declared by the function
+func parseFuncArgs(funcType *ast.FuncType, fm *FuncMetadata) int {
+	offset := 0
+
+	for _, arg := range funcType.Params.List {
+		// Extrapolates the argument type, a single field can declare more
+		// arguments (e.g "a, b chan int") or none at all if the latter are unnamed
+		chanType, isChannel := channelType(arg.Type, fm.chanTypes)
+		_, isFunction := arg.Type.(*ast.FuncType)
+		isContext := types.ExprString(arg.Type) == "context.Context"
+
+		if len(arg.Names) == 0 {
+			offset++
+			continue
+		}
+
+		// The variadic channel parameter (always the last one) is inlined element by element (see variadicParam)
+		if ellipsis, isVariadic := arg.Type.(*ast.Ellipsis); isVariadic {
+			if elemType, isChannel := channelType(ellipsis.Elt, fm.chanTypes); isChannel {
+				fm.Variadic = FuncArg{Offset: offset, Name: arg.Names[0].Name, Type: Channel}
+				fm.variadic = &variadicParam{fm.Variadic, types.ExprString(elemType.Value), make(map[int]bool)}
+			}
+			offset++
+			continue
+		}
+
+		for _, argIdent := range arg.Names {
+			argName := argIdent.Name
 
 			if isChannel {
 				// Adds the channel arg as "to be inlined"
-				newInlineArg := FuncArg{Offset: i, Name: argName, Type: Channel}
-				metadata.InlineArgs = append(metadata.InlineArgs, newInlineArg)
-				// In case of channel it adds as well to the ChanMeta fields
-				metadata.ChanMeta[argName] = ChanMetadata{Name: argName}
+				newInlineArg := FuncArg{Offset: offset, Name: argName, Type: Channel}
+				fm.InlineArgs = append(fm.InlineArgs, newInlineArg)
+				// In case of channel it adds as well to the ChanMeta fields (the buffering is unknown)
+				fm.ChanMeta[argName] = ChanMetadata{Name: argName, Type: types.ExprString(chanType.Value)}
 			} else if isFunction {
 				// Adds the function arg as "to be inlined"
-				newInlineArg := FuncArg{Offset: i, Name: argName, Type: Function}
-				metadata.InlineArgs = append(metadata.InlineArgs, newInlineArg)
+				newInlineArg := FuncArg{Offset: offset, Name: argName, Type: Function}
+				fm.InlineArgs = append(fm.InlineArgs, newInlineArg)
+			} else if isContext {
+				// The done channel of a Context is always an environment one, no need to inline it
+				fm.addChannels(contextDoneChannel(argName))
 			}
+
+			offset++
 		}
 	}
 
+	return offset
+}
+
+// This function visits the body of a function with the given (already set up) FuncMetadata,
+// at the end of the body the return transition to the final state of the ScopeAutomata is added,
+// the states left by the return statements found along the way are linked to the latter as well
+func parseFuncBody(body *ast.BlockStmt, fm FuncMetadata) {
+	if fm.flow != nil && len(body.List) > 0 {
+		fm.flow.tail = body.List[len(body.List)-1]
+	}
+
 	// Upon completion of the "setup" phase then the body of the
 	// function is visited through the ast.Walk() function in order to
 	// gather additional information about the stmt in the function scope
-	ast.Walk(metadata, stmt.Body)
+	ast.Walk(fm, body)
+	annotateNilChannels(fm)
+
+	// The return is traced at the closing brace of the body, the position of the caller is kept for the
+	// enclosing statement since the closures share it (see parseFuncLit)
+	if fm.visiting != nil {
+		enclosing := *fm.visiting
+		*fm.visiting = body.Rbrace
+		defer func() { *fm.visiting = enclosing }()
+	}
 
 	// Adds an eps transition to a new state
-	t := fsa.Transition{Move: fsa.Eps, Label: fmt.Sprintf("func-%s-return", metadata.Name)}
-	metadata.Automaton.AddTransition(fsa.Current, fsa.NewState, t)
+	t := fsa.FuncReturn.With(fm.Name).Transition()
+	finalStateId := fm.emit(t)
+	if fm.flow != nil {
+		for _, exit := range fm.flow.returns {
+			fm.addTransitionAt(exit.state, finalStateId, t, exit.pos)
+		}
+	}
 	// The newly created state will be the final state of the ScopeAutomata
-	metadata.Automaton.FinalStates.Add(metadata.Automaton.GetLastId())
-
-	// At last all the data extracted is returned
-	fm.FunctionMeta[funcName] = metadata
+	fm.Automaton.SetFinalState(finalStateId)
 }
 
 // This function parses a GoStmt statement and saves the transition data extracted
 // in the given FuncMetadata argument. In case of error during execution no error is returned.
 func parseGoStmt(stmt *ast.GoStmt, fm *FuncMetadata) {
+	// The arguments are evaluated by the spawner, before the new Goroutine is started
+	parseCallArgExprs(stmt.Call, fm)
+
 	// Determines if GoStmt spawns a Go routine from declared or anonymous function
-	funcIdent, isFuncIdent := stmt.Call.Fun.(*ast.Ident) // Declared function
-	_, isFuncAnonymous := stmt.Call.Fun.(*ast.FuncLit)   // Anonymous function
-
-	// Then extracts the data accordingly
-	if isFuncIdent {
-		tSpawn := fsa.Transition{Move: fsa.Spawn, Label: funcIdent.Name}
-
-		// Parses the GoStmt arguments looking for channels and saves the "actual" argument to list
-		// in the Transition. Later this channels will be inlined during the generation of the automaton
-		// ! Remove duplicate at line 253
-		for i, arg := range stmt.Call.Args {
-			argIdent, isIdent := arg.(*ast.Ident)
-			if isIdent {
-				_, isChannel := fm.ChanMeta[argIdent.Name]
-				if isChannel {
-					funcArgList, _ := tSpawn.Payload.([]FuncArg)
-					newFuncArg := FuncArg{Offset: i, Name: argIdent.Name, Type: Channel}
-					tSpawn.Payload = append(funcArgList, newFuncArg)
-				}
-			}
+	switch callee := stmt.Call.Fun.(type) {
+	// Declared function, the "actual" channel arguments are saved in the Transition
+	// payload. Later this channels will be inlined during the generation of the automaton
+	case *ast.Ident:
+		tSpawn := fsa.Transition{Move: fsa.Spawn, Label: callee.Name, Payload: fm.withSite(parseCallArgs(stmt.Call, fm), stmt)}
+		fm.emit(tSpawn)
+
+	// Anonymous function, the closure is extracted as a standalone function (see parseFuncLit)
+	case *ast.FuncLit:
+		tSpawn := parseFuncLit(callee, stmt.Call, fsa.Spawn, fm)
+		tSpawn.Payload = fm.withSite(tSpawn.Payload.([]FuncArg), stmt)
+		fm.emit(tSpawn)
+
+	// The methods declared in the file are spawned as the plain functions. The other methods and the package
+	// functions can't be analyzed, the spawn is reported but kept in the automaton since the function
+	// could be modeled by a stub, else it will be replaced by an eps transition
+	case *ast.SelectorExpr:
+		if method, isMethod := fm.methodName(callee); isMethod {
+			tSpawn := fsa.Transition{Move: fsa.Spawn, Label: method, Payload: fm.withSite(parseCallArgs(stmt.Call, fm), stmt)}
+			fm.emit(tSpawn)
+			return
 		}
+		fm.reportUnsupportedCall(stmt.Call)
+		tSpawn := fsa.Transition{Move: fsa.Spawn, Label: types.ExprString(callee), Payload: fm.withSite(parseCallArgs(stmt.Call, fm), stmt)}
+		fm.emit(tSpawn)
+
+	// Any other callee (e.g "go handlers[i]()") is reported and replaced
+	// with an eps transition so that the control flow of the caller is preserved
+	default:
+		fm.reportUnsupportedCall(stmt.Call)
+		tEps := fsa.UnsupportedSpawn.Transition()
+		fm.emit(tEps)
+	}
+}
 
-		// At last add the transition (with the payload) to the ScopeAutomata
-		fm.Automaton.AddTransition(fsa.Current, fsa.NewState, tSpawn)
-	} else if isFuncAnonymous {
-		// ToDo: This functionality is not yet implemented
-		anonFuncName := fmt.Sprintf("%s-%s", anonymousFunc, fm.Name)
-		tSpawn := fsa.Transition{Move: fsa.Spawn, Label: anonFuncName}
-		fm.Automaton.AddTransition(fsa.Current, fsa.NewState, tSpawn)
-		// ? Add parent ChanMeta (scope inheritance)
-		// ? Add parse arguments (different from above)
-		// ? Should parse body of funcLiteral
+// Appends to the actual arguments of a spawn the position of the GoStmt in the source (e.g "main.go:42"),
+// saved as an argument of the Site type. The spawned Goroutine is named after it (see transforms.ExtractGoroutineFSA)
+// so that its name doesn't change when other spawns are added elsewhere. Without a FileSet the arguments are returned as they are
+func (fm *FuncMetadata) withSite(args []FuncArg, stmt *ast.GoStmt) []FuncArg {
+	if fm.report == nil || fm.report.fileSet == nil || !stmt.Pos().IsValid() {
+		return args
 	}
+
+	position := fm.report.fileSet.Position(stmt.Pos())
+	site := fmt.Sprintf("%s:%d", filepath.Base(position.Filename), position.Line)
+	return append(args, FuncArg{Offset: len(args), Name: site, Type: Site})
 }
 
 // This function parses a CallExpr statement and saves the transition data extracted
 // in the given FuncMetadata argument. In case of error during execution no error is returned.
 func parseCallExpr(expr *ast.CallExpr, fm *FuncMetadata) {
-	// Tries to extract the function name (identifier), else throw an exception
-	funcIdent, isIdent := expr.Fun.(*ast.Ident)
+	parseAssignedCall(expr, nil, false, fm)
+}
 
-	if !isIdent {
-		// ? Consider struct.method() syntax as well (*ast.SelectorExpr)
+// This function parses a CallExpr whose results are assigned to the given lvalues (e.g "jobs, results
+// := startWorker()"), the lvalues bound to a channel returned by the callee are saved in the payload of
+// the Call transition, so that the channel keeps its identity once the callee is inlined (see bindResults).
+// The define flag is set when the lvalues are declared by the assignment (e.g "jobs := startWorker()")
+func parseAssignedCall(expr *ast.CallExpr, lValues []ast.Expr, define bool, fm *FuncMetadata) {
+	var tCall fsa.Transition
+
+	// The call is modeled by one of the custom extractors (e.g a messaging wrapper)
+	if runExtractors(expr, fm) || parseYieldCall(expr, fm) {
+		return
+	}
+	parseCallArgExprs(expr, fm)
+
+	// Tries to extract the function name (identifier), else the call is reported as unsupported
+	switch callee := expr.Fun.(type) {
+	case *ast.Ident:
+		// Creates a valid transition struct, the "actual" channel arguments are saved in the Transition
+		// payload. Later this channels will be inlined during the generation of the automaton
+		actualArgs := append(parseCallArgs(expr, fm), fm.bindResults(callee.Name, lValues, define)...)
+		tCall = fsa.Transition{Move: fsa.Call, Label: callee.Name, Payload: actualArgs}
+	case *ast.FuncLit:
+		// Anonymous function called in place (e.g "func() { ... }()")
+		tCall = parseFuncLit(callee, expr, fsa.Call, fm)
+	case *ast.SelectorExpr:
+		// A method declared in the file (e.g "s.Run()") is called as the plain functions are
+		if method, isMethod := fm.methodName(callee); isMethod {
+			actualArgs := append(parseCallArgs(expr, fm), fm.bindResults(method, lValues, define)...)
+			tCall = fsa.Transition{Move: fsa.Call, Label: method, Payload: actualArgs}
+			break
+		}
+		// Any other method or package function (e.g "http.ListenAndServe()"), it's reported but the call is kept as
+		// well since a stub could model it. When no stub is available it will be replaced by an eps transition
+		if isStdlibModel(types.ExprString(callee)) {
+			parseStdlibNotify(expr, fm)
+			return
+		}
+		fm.reportUnsupportedCall(expr)
+		tCall = fsa.Transition{Move: fsa.Call, Label: types.ExprString(callee), Payload: parseCallArgs(expr, fm)}
+	// A function value (e.g "fns[i]()") or a conversion (e.g "[]byte(s)"), the first is reported
+	// and neither of them is added to the automaton
+	default:
+		fm.reportUnsupportedCall(expr)
 		return
 	}
 
-	// Creates a valid transition struct
-	tCall := fsa.Transition{Move: fsa.Call, Label: funcIdent.Name}
+	// At last add full the transition to the ScopeAutomata of the FuncMetadata
+	fm.emit(tCall)
+}
+
+// Adds to the report a function call (or Goroutine spawn) whose callee isn't a plain identifier.
+// Calls to the "reflect" package are distinguished from the other selector calls (e.g "obj.Method()")
+// since their effect on the channels (e.g "reflect.Select()") can't be determined statically, while
+// any other callee is a function value only known at runtime (e.g "handlers[i]()" or "getHandler()()")
+func (fm *FuncMetadata) reportUnsupportedCall(expr *ast.CallExpr) {
+	switch callee := expr.Fun.(type) {
+	case *ast.SelectorExpr:
+		if pkgIdent, isIdent := callee.X.(*ast.Ident); isIdent && pkgIdent.Name == "reflect" {
+			fm.report.Add(Reflection, types.ExprString(callee), fm.Name, expr.Pos())
+		} else {
+			fm.report.Add(SelectorCall, types.ExprString(callee), fm.Name, expr.Pos())
+		}
+	default:
+		if !isConversion(callee) {
+			fm.report.Add(DynamicCall, types.ExprString(callee), fm.Name, expr.Pos())
+		}
+	}
+}
+
+// Returns true if the callee of a CallExpr is a type literal, that is the call is a conversion
+// (e.g "[]byte(s)", "(*T)(p)" or "(chan<- int)(ch)") and not a call to a function value
+func isConversion(callee ast.Expr) bool {
+	switch callee := callee.(type) {
+	case *ast.ParenExpr:
+		return isConversion(callee.X)
+	case *ast.StarExpr, *ast.ArrayType, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.InterfaceType, *ast.StructType:
+		return true
+	}
+	return false
+}
+
+// This function parses the arguments of a CallExpr (either a plain function call or a Goroutine spawn)
+// looking for channels available in the current scope, the latter are returned as a list of "actual"
+// arguments. If no channel is passed to the function then nil is returned
+func parseCallArgs(expr *ast.CallExpr, fm *FuncMetadata) []FuncArg {
+	var actualArgs []FuncArg
 
-	// Parses the CallExpr arguments looking for channels and saves the "actual" argument to list
-	// in the Transition. Later this channels will be inlined during the generation of the automaton
-	// ! Remove duplicate at line 211
 	for i, arg := range expr.Args {
 		argIdent, isIdent := arg.(*ast.Ident)
-		if isIdent {
-			_, isChannel := fm.ChanMeta[argIdent.Name]
-			if isChannel {
-				funcArgList, _ := tCall.Payload.([]FuncArg)
-				newFuncArg := FuncArg{Offset: i, Name: argIdent.Name, Type: Channel}
-				tCall.Payload = append(funcArgList, newFuncArg)
-			}
+		if !isIdent {
+			continue
+		}
+
+		chanName := fm.channelName(argIdent.Name)
+		if _, isChannel := fm.ChanMeta[chanName]; isChannel {
+			newFuncArg := FuncArg{Offset: i, Name: chanName, Type: Channel}
+			actualArgs = append(actualArgs, newFuncArg)
 		}
 	}
 
-	// At last add full the transition to the ScopeAutomata of the FuncMetadata
-	fm.Automaton.AddTransition(fsa.Current, fsa.NewState, tCall)
+	return actualArgs
 }
@@ -11,13 +11,41 @@ package static_analysis
 
 import (
 	"go/ast"
+	"go/token"
+	"go/types"
 	"log"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
 )
 
 // This function parses an AssignStmt statement and evaluates all the possible cases for it.
 // In particular this statement can contain a receive operation from a channel, a function call
-// or the initialization of a channel.
+// or the initialization of a channel, anywhere in the expressions assigned (see parseValueExpr).
 func parseAssignStmt(stmt *ast.AssignStmt, fm *FuncMetadata) {
+	// The channels declared by the statement (e.g "ch := make(chan int)") belong to the block being visited,
+	// while the ones assigned (e.g "ch = make(chan int)") are the ones already visible with the same name
+	define := stmt.Tok == token.DEFINE
+	bindChannels := fm.addChannels
+	if define {
+		bindChannels = fm.declareChannels
+	}
+
+	// A function returning multiple values (e.g "ctx, cancel := context.WithCancel(parent)"),
+	// only the first value can be a channel (or a Context) known to the static analysis
+	if callExpr, isCall := stmt.Rhs[0].(*ast.CallExpr); isCall && len(stmt.Rhs) == 1 && len(stmt.Lhs) > 1 {
+		parseAssignedCall(callExpr, stmt.Lhs, define, fm)
+		if identName, isIdent := stmt.Lhs[0].(*ast.Ident); isIdent {
+			bindChannels(parseStdlibChannel(callExpr, identName.Name))
+		}
+		return
+	}
+
+	// The other expressions with multiple values are the "comma ok" forms (e.g "v, ok := <-ch" or "v, ok := table[k]")
+	if len(stmt.Rhs) == 1 && len(stmt.Lhs) > 1 {
+		parseValueExpr(stmt.Rhs[0], fm)
+		return
+	}
+
 	// Check that the number of rvalue are the same of lvalue (values assignments) in the statement
 	if len(stmt.Lhs) != len(stmt.Rhs) {
 		log.Fatalf("Not the same number of lVal and rVal in AssignStmt at line %d\n", stmt.Pos())
@@ -26,18 +54,37 @@ func parseAssignStmt(stmt *ast.AssignStmt, fm *FuncMetadata) {
 	// Now iterates over each assignment
 	for i := range stmt.Lhs {
 		lVal, rVal := stmt.Lhs[i], stmt.Rhs[i]
-		// At the moment of writing this cast should always be successful
-		identName := lVal.(*ast.Ident)
+		identName, isIdent := lVal.(*ast.Ident)
+
+		// Assignment to an element or a field (e.g "results[i] = <-ch"), the operands on the left are evaluated first
+		if !isIdent {
+			parseValueExpr(lVal, fm)
+			if callExpr, isCall := rVal.(*ast.CallExpr); isCall {
+				parseCallExpr(callExpr, fm)
+			} else {
+				parseValueExpr(rVal, fm)
+			}
+			continue
+		}
+
+		// Assignment of nil to a channel (e.g "ch = nil")
+		if nilIdent, isIdent := rVal.(*ast.Ident); isIdent && nilIdent.Name == "nil" {
+			parseNilAssignment(identName, fm)
+			continue
+		}
 
 		switch castStmt := rVal.(type) {
 		// Function call (+ assignment) or channel init
 		case *ast.CallExpr:
-			parseCallExpr(castStmt, fm)
-			chanMeta := parseMakeCall(castStmt, identName.Name)
-			fm.addChannels(chanMeta)
-		// Receive (+ assignment) from a channel
-		case *ast.UnaryExpr:
-			parseRecvStmt(castStmt, fm)
+			parseAssignedCall(castStmt, stmt.Lhs[i:i+1], define, fm)
+			chanMeta := parseMakeCall(castStmt, identName.Name, fm.constants, fm.chanTypes)
+			bindChannels(chanMeta, parseStdlibChannel(castStmt, identName.Name))
+		// Closures assigned to a variable can't be tracked back from their later calls
+		case *ast.FuncLit:
+			fm.report.Add(Closure, identName.Name, fm.Name, stmt.Pos())
+		// Any other expression, with the receives and the calls it contains (e.g "x := <-ch" or "sum += <-ch")
+		default:
+			parseValueExpr(rVal, fm)
 		}
 	}
 }
@@ -49,8 +96,129 @@ func parseExprStmt(stmt *ast.ExprStmt, fm *FuncMetadata) {
 	switch castStmt := stmt.X.(type) {
 	case *ast.CallExpr:
 		parseCallExpr(castStmt, fm)
+	default:
+		parseValueExpr(castStmt, fm)
+	}
+
+}
+
+// This function parses a ReturnStmt statement, the values returned are evaluated (in order) before
+// leaving the function (e.g "return <-results" or "return compute(x)"), see parseValueExpr. Then the
+// function is left, the statements after the return are dead code (see leaveFunction)
+func parseReturnStmt(stmt *ast.ReturnStmt, fm *FuncMetadata) {
+	for _, result := range stmt.Results {
+		if callExpr, isCall := result.(*ast.CallExpr); isCall {
+			parseCallExpr(callExpr, fm)
+		} else {
+			parseValueExpr(result, fm)
+		}
+	}
+	fm.leaveFunction(stmt)
+}
+
+// This function parses an expression evaluated for its value wherever it appears: the condition of an if or for
+// statement, the tag of a switch, the right hand side of an assignment, a returned value, an argument of a call or
+// a value sent. The channel operations are extracted in evaluation order (the operands from left to right, the
+// arguments before the call): the receives (e.g "if <-done", "x := y + <-ch" or "return <-ch"), the calls to the
+// functions declared in the file and the ones to a yield callback. The calls to builtins and selector calls (e.g
+// "len(queue) > 0") don't affect the channels and only their arguments are parsed. The closures (FuncLit) aren't
+// entered, since their body is evaluated only once they're called.
+//
+// The right operand of "&&" and "||" is evaluated only depending on the value of the left one, so when it
+// contains some channel operation the latter are placed on their own branch, that can be skipped
+func parseValueExpr(expr ast.Expr, fm *FuncMetadata) {
+	switch castExpr := expr.(type) {
+	case *ast.ParenExpr:
+		parseValueExpr(castExpr.X, fm)
 	case *ast.UnaryExpr:
-		parseRecvStmt(castStmt, fm)
+		if castExpr.Op == token.ARROW {
+			parseRecvStmt(castExpr, fm)
+		} else {
+			parseValueExpr(castExpr.X, fm)
+		}
+	case *ast.BinaryExpr:
+		parseValueExpr(castExpr.X, fm)
+		if (castExpr.Op != token.LAND && castExpr.Op != token.LOR) || !hasChannelOps(castExpr.Y) {
+			parseValueExpr(castExpr.Y, fm)
+			return
+		}
+
+		forkStateId := fm.currentState()
+		fm.emit(fsa.ConditionRhsStart.Transition())
+		parseValueExpr(castExpr.Y, fm)
+		mergeStateId := fm.emit(fsa.ConditionRhsEnd.Transition())
+		fm.addTransition(forkStateId, mergeStateId, fsa.ConditionRhsSkip.Transition())
+	case *ast.CallExpr:
+		// The yield callback receives its arguments on its own (see parseYieldCall)
+		if parseYieldCall(castExpr, fm) {
+			return
+		}
+		// The arguments of the declared calls are extracted along with the call itself (see parseAssignedCall)
+		if isDeclaredCall(castExpr) || fm.isMethodCall(castExpr) {
+			parseCallExpr(castExpr, fm)
+		} else {
+			parseCallArgExprs(castExpr, fm)
+		}
+	case *ast.IndexExpr:
+		parseValueExpr(castExpr.X, fm)
+		parseValueExpr(castExpr.Index, fm)
+	case *ast.SliceExpr:
+		for _, operand := range []ast.Expr{castExpr.X, castExpr.Low, castExpr.High, castExpr.Max} {
+			if operand != nil {
+				parseValueExpr(operand, fm)
+			}
+		}
+	case *ast.CompositeLit:
+		for _, element := range castExpr.Elts {
+			parseValueExpr(element, fm)
+		}
+	case *ast.KeyValueExpr:
+		parseValueExpr(castExpr.Key, fm)
+		parseValueExpr(castExpr.Value, fm)
+	case *ast.TypeAssertExpr:
+		parseValueExpr(castExpr.X, fm)
+	case *ast.SelectorExpr:
+		parseValueExpr(castExpr.X, fm)
+	case *ast.StarExpr:
+		parseValueExpr(castExpr.X, fm)
 	}
+}
 
+// Extracts the channel operations in the arguments of the given call (e.g "process(<-input, compute(x))"),
+// since the arguments are evaluated before the call they have to be parsed before its transition is emitted
+func parseCallArgExprs(expr *ast.CallExpr, fm *FuncMetadata) {
+	for _, arg := range expr.Args {
+		parseValueExpr(arg, fm)
+	}
+}
+
+// Returns true if the given expression contains a receive or a call that could perform channel operations
+func hasChannelOps(expr ast.Expr) bool {
+	found := false
+
+	ast.Inspect(expr, func(node ast.Node) bool {
+		switch castNode := node.(type) {
+		case *ast.UnaryExpr:
+			found = found || castNode.Op == token.ARROW
+		case *ast.CallExpr:
+			found = found || isDeclaredCall(castNode)
+		case *ast.FuncLit:
+			return false // The body of a closure is evaluated only if the latter is called
+		}
+		return !found
+	})
+
+	return found
+}
+
+// Returns true if the callee of the given call may be declared in the file (a plain identifier that isn't a
+// builtin function or type conversion, e.g "isReady(ch)") or a closure called in place
+func isDeclaredCall(expr *ast.CallExpr) bool {
+	switch callee := expr.Fun.(type) {
+	case *ast.Ident:
+		return types.Universe.Lookup(callee.Name) == nil
+	case *ast.FuncLit:
+		return true
+	}
+	return false
 }
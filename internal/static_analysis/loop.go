@@ -11,86 +11,321 @@ package static_analysis
 
 import (
 	"go/ast"
+	"go/token"
 
 	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
 )
 
+// The maximum number of iterations of a counting loop that spawns Goroutines to be unrolled
+const maxUnrolledIterations = 16
+
 // ----------------------------------------------------------------------------
 // Looping/Iteration constructs related parsing method
 
 // This function parses a ForStmt statement and saves the transition(s) data extracted
 // in the given FuncMetadata argument. In case of error during execution no error is returned.
 func parseForStmt(stmt *ast.ForStmt, fm *FuncMetadata) {
-	// Parse the init statement at first and the condition (always executed at least one time)
+	// The event loop idiom ("for { select { ... } }") has its own handling
+	if selectStmt, isEventLoop := eventLoopSelect(stmt); isEventLoop {
+		parseEventLoop(selectStmt, fm)
+		return
+	}
+
+	// The counting loops that spawn Goroutines are unrolled, so that each instance is spawned on its own
+	if iterations, isUnrollable := unrollableIterations(stmt, fm); isUnrollable {
+		ast.Walk(fm, stmt.Init)
+		unrollLoop(stmt.Body, stmt.Post, iterations, fm)
+		return
+	}
+
+	// Parse the init statement at first (scoped to the loop), then the condition that is evaluated again before each iteration
+	fm.scope.open()
+	defer fm.scope.close()
 	ast.Walk(fm, stmt.Init)
-	ast.Walk(fm, stmt.Cond) // ? Parse BinaryExpr to find transition inside
-	// Saves a local copy of the current id, all the branch will fork from it
-	forkStateId := fm.Automaton.GetLastId()
+	loopHeadId := fm.currentState()
+	parseValueExpr(stmt.Cond, fm)
+	// Saves a local copy of the current id (after the condition), all the branch will fork from it
+	forkStateId := fm.currentState()
 
 	// Generate an eps-transition to represent the fork/branch (the iteration scope in the for loop)
 	// and add it as a transition from the "fork point" saved before
-	tEpsStart := fsa.Transition{Move: fsa.Eps, Label: "for-iteration-start"}
-	fm.Automaton.AddTransition(forkStateId, fsa.NewState, tEpsStart)
+	tEpsStart := fsa.ForIterationStart.Transition()
+	fm.emitFrom(forkStateId, tEpsStart)
 
 	// Parses the nested block (and then) the post iteration statement
 	ast.Walk(fm, stmt.Body)
 	ast.Walk(fm, stmt.Post)
 
-	// Links back the iteration block to the fork state
-	tEpsEnd := fsa.Transition{Move: fsa.Eps, Label: "for-iteration-end"}
-	fm.Automaton.AddTransition(fsa.Current, forkStateId, tEpsEnd)
+	// Links back the iteration block to the evaluation of the condition (the fork state when it has no channel operation)
+	tEpsEnd := fsa.ForIterationEnd.Transition()
+	fm.addTransition(fm.currentState(), loopHeadId, tEpsEnd)
 	// Links the fork state to a new one (this represents the no-iteration or exit-iteration cases)
-	tEpsSkip := fsa.Transition{Move: fsa.Eps, Label: "for-iteration-skip"}
-	fm.Automaton.AddTransition(forkStateId, fsa.NewState, tEpsSkip)
+	tEpsSkip := fsa.ForIterationSkip.Transition()
+	fm.emitFrom(forkStateId, tEpsSkip)
 }
 
 // This function parses a RangeStmt statement and saves the data extracted in a FuncMetadata struct.
 // In case of error during execution no error is returned. If the identifier on which we're iterating
 // is a channel then the range function behaves as a for loop in which we're receiving from the channel
-// before each iteration, else (if we're iterating on a map or list) an eps-transition is used instead
+// before each iteration, else (if we're iterating on a map, a list or an integer) an eps-transition is
+// used instead. The iterator functions (range-over-func) written in place have their own handling
 func parseRangeStmt(stmt *ast.RangeStmt, fm *FuncMetadata) {
-	// Parse the init statement at first and the condition (always executed at least one time)
-	iterateeIdent, isIdent := stmt.X.(*ast.Ident)
-	// Flag to set if the iteratee is a local channel identifier
+	// Flag to set if the iteratee is a local channel identifier (or a call that returns a known channel)
 	matchFound := false
+	var channelMeta ChanMetadata
 
+	switch iteratee := stmt.X.(type) {
 	// Checks if the iteratee identifier is a locally declared channel, eventually sets a flag
 	// this is needs because "ranging" over a channel is equal to receiving multiple time from it
-	if isIdent {
+	case *ast.Ident:
+		chanName := fm.channelName(iteratee.Name)
 		for _, chanMeta := range fm.ChanMeta {
-			if chanMeta.Name == iterateeIdent.Name {
-				matchFound = true
+			if chanMeta.Name == chanName {
+				matchFound, channelMeta = true, fm.ChanMeta[chanName]
 			}
 		}
 		for _, arg := range fm.InlineArgs {
-			if arg.Name == iterateeIdent.Name {
-				matchFound = true
+			if arg.Name == chanName {
+				matchFound, channelMeta = true, fm.ChanMeta[chanName]
 			}
 		}
+	// The iteratee is evaluated once before the loop (e.g "range time.Tick(d)" or "range seq(ch)")
+	case *ast.CallExpr:
+		if channelMeta, matchFound = fm.lookupCallChannel(iteratee); !matchFound {
+			parseCallExpr(iteratee, fm)
+		}
+	// An iterator function declared in place (e.g "range func(yield func(int) bool) { ... }")
+	case *ast.FuncLit:
+		parseRangeOverFunc(stmt, iteratee, fm)
+		return
+	}
+
+	// Ranging over a constant integer that spawns Goroutines is unrolled as for the counting loops
+	if iterations, isConstant := constantInt(stmt.X, fm.constants); isConstant && !matchFound && isUnrollableBody(stmt.Body, iterations) {
+		unrollLoop(stmt.Body, nil, iterations, fm)
+		return
 	}
 
 	// Saves a local copy of the current id, all the branch will fork from it
-	forkStateId := fm.Automaton.GetLastId()
+	forkStateId := fm.currentState()
 
 	// Generate an eps-transition to represent the fork/branch (the iteration block in the loop)
 	// and add it as a transition, if we're using range on a channel then the transition became
 	// a Recv transition since on channel this is the default overload of "range" keyword
 	if matchFound {
-		channelMeta := fm.ChanMeta[iterateeIdent.Name]
-		tRecvStart := fsa.Transition{Move: fsa.Recv, Label: iterateeIdent.Name, Payload: channelMeta}
-		fm.Automaton.AddTransition(fsa.Current, fsa.NewState, tRecvStart)
+		tRecvStart := fsa.Transition{Move: fsa.Recv, Label: channelMeta.Name, Payload: channelMeta}
+		fm.emit(tRecvStart)
 	} else {
-		tEpsStart := fsa.Transition{Move: fsa.Eps, Label: "range-iteration-start"}
-		fm.Automaton.AddTransition(fsa.Current, fsa.NewState, tEpsStart)
+		tEpsStart := fsa.RangeIterationStart.Transition()
+		fm.emit(tEpsStart)
 	}
 
 	// Parses the nested block
 	ast.Walk(fm, stmt.Body)
 
 	// Links back the iteration block to the fork state
-	tEpsEnd := fsa.Transition{Move: fsa.Eps, Label: "range-iteration-end"}
-	fm.Automaton.AddTransition(fsa.Current, forkStateId, tEpsEnd)
+	tEpsEnd := fsa.RangeIterationEnd.Transition()
+	fm.addTransition(fm.currentState(), forkStateId, tEpsEnd)
 	// Links the fork state to a new one (this represents the no-iteration or exit-iteration cases)
-	tEpsSkip := fsa.Transition{Move: fsa.Eps, Label: "range-iteration-skip"}
-	fm.Automaton.AddTransition(forkStateId, fsa.NewState, tEpsSkip)
+	tEpsSkip := fsa.RangeIterationSkip.Transition()
+	fm.emitFrom(forkStateId, tEpsSkip)
+}
+
+// This function parses a range-over-func loop whose iterator is a function literal. The loop body is
+// the callback received by the iterator as its (first) yield argument, so the body of the iterator is
+// parsed in place and every call to yield is expanded with the loop body: the operations of the loop
+// are interleaved with the ones of the iterator, exactly as they're performed at runtime
+func parseRangeOverFunc(stmt *ast.RangeStmt, iterator *ast.FuncLit, fm *FuncMetadata) {
+	params := iterator.Type.Params.List
+	if len(params) == 0 || len(params[0].Names) == 0 {
+		// The yield callback can't be referenced by the iterator, the loop body is never executed
+		parseIteratorBody(iterator.Body, fm)
+		return
+	}
+
+	yieldName := params[0].Names[0].Name
+	if fm.yields == nil {
+		fm.yields = make(map[string]*ast.BlockStmt)
+	}
+	fm.yields[yieldName] = stmt.Body
+	parseIteratorBody(iterator.Body, fm)
+	delete(fm.yields, yieldName)
+}
+
+// This function parses the body of the iterator of a range-over-func loop in place. A return of the iterator
+// only ends the loop, so the states it leaves are linked to the one reached at the end of the body instead of
+// the final state of the function. The same is done for a return in the loop body expanded at the calls to
+// yield, even though the latter would leave the enclosing function as well
+func parseIteratorBody(body *ast.BlockStmt, fm *FuncMetadata) {
+	if fm.flow == nil {
+		ast.Walk(fm, body)
+		return
+	}
+
+	enclosingTail, enclosingReturns := fm.flow.tail, fm.flow.returns
+	fm.flow.tail, fm.flow.returns = nil, nil
+	if len(body.List) > 0 {
+		fm.flow.tail = body.List[len(body.List)-1]
+	}
+	ast.Walk(fm, body)
+
+	tEpsReturn := fsa.RangeYieldReturn.Transition()
+	for _, exit := range fm.flow.returns {
+		fm.addTransitionAt(exit.state, fm.currentState(), tEpsReturn, exit.pos)
+	}
+	fm.flow.tail, fm.flow.returns = enclosingTail, enclosingReturns
+}
+
+// This function parses a call to the yield callback of a range-over-func loop (see parseRangeOverFunc)
+// expanding the body of the loop in its place, the values yielded are received (if needed) before
+// the loop body is executed. Returns false if the call isn't a yield one
+func parseYieldCall(expr *ast.CallExpr, fm *FuncMetadata) bool {
+	callee, isIdent := expr.Fun.(*ast.Ident)
+	if !isIdent {
+		return false
+	}
+	loopBody, isYield := fm.yields[callee.Name]
+	if !isYield {
+		return false
+	}
+
+	for _, arg := range expr.Args {
+		if recvExpr, isUnary := arg.(*ast.UnaryExpr); isUnary {
+			parseRecvStmt(recvExpr, fm)
+		}
+	}
+
+	tEpsStart := fsa.RangeYieldStart.Transition()
+	fm.emit(tEpsStart)
+	ast.Walk(fm, loopBody)
+	tEpsEnd := fsa.RangeYieldEnd.Transition()
+	fm.emit(tEpsEnd)
+	return true
+}
+
+// Returns the SelectStmt of the given ForStmt if the latter is an event loop, that is an endless loop
+// (no init, condition or post statement) whose body is made only of a select statement
+func eventLoopSelect(stmt *ast.ForStmt) (*ast.SelectStmt, bool) {
+	if stmt.Init != nil || stmt.Cond != nil || stmt.Post != nil || len(stmt.Body.List) != 1 {
+		return nil, false
+	}
+
+	selectStmt, isSelect := stmt.Body.List[0].(*ast.SelectStmt)
+	return selectStmt, isSelect
+}
+
+// This function parses an event loop ("for { select { ... } }") and saves the transition(s) data extracted
+// in the given FuncMetadata argument. Parsing it as a loop with a nested select would generate a fork and a
+// merge state for each iteration, here instead every clause is parsed on its own branch that starts from
+// and returns to the same loop head, so that it's clear that the select is repeated forever.
+//
+// The clauses that leave the loop (with a return or a labeled break) are linked to the exit state
+// instead, the latter can be reached from the loop head as well so that (as for any other loop)
+// the function is still able to terminate even if the loop is never exited explicitly
+func parseEventLoop(stmt *ast.SelectStmt, fm *FuncMetadata) {
+	// Saves a local copy of the current id (the loop head), every branch will fork from and return to it
+	loopHeadId := fm.currentState()
+	// The first clause that leaves the loop (or the skip transition) initializes it with a valid id
+	exitStateId := fsa.Unknown
+
+	for i, clause := range stmt.Body.List {
+		// The branch of the n-th clause starts with "event-loop-case-n-start" from the loop head
+		tEpsStart := fsa.EventLoopCaseStart.With(i).Transition()
+		fm.emitFrom(loopHeadId, tEpsStart)
+
+		// Parses the CommClause, then parses the nested block/scopes
+		ast.Walk(fm, clause)
+
+		if commClause, isCommClause := clause.(*ast.CommClause); !isCommClause || !exitsLoop(commClause) {
+			fm.addTransition(fm.currentState(), loopHeadId, fsa.EventLoopCaseEnd.With(i).Transition())
+			continue
+		}
+
+		tEpsExit := fsa.EventLoopCaseExit.With(i).Transition()
+		if exitStateId == fsa.Unknown {
+			exitStateId = fm.emit(tEpsExit)
+		} else {
+			fm.addTransition(fm.currentState(), exitStateId, tEpsExit)
+		}
+	}
+
+	// Links the loop head to the exit state (this represents the exit-iteration case)
+	tEpsSkip := fsa.EventLoopSkip.Transition()
+	if exitStateId == fsa.Unknown {
+		exitStateId = fm.emitFrom(loopHeadId, tEpsSkip)
+	} else {
+		fm.addTransition(loopHeadId, exitStateId, tEpsSkip)
+	}
+
+	// Moves to the exit state, from which all future transition will start
+	fm.moveTo(exitStateId)
+}
+
+// Returns true if the body of the given clause leaves the enclosing event loop, that is if one of its
+// statements is a return or a labeled break (an unlabeled one only leaves the select statement)
+func exitsLoop(clause *ast.CommClause) bool {
+	for _, stmt := range clause.Body {
+		switch branch := stmt.(type) {
+		case *ast.ReturnStmt:
+			return true
+		case *ast.BranchStmt:
+			if branch.Tok == token.BREAK && branch.Label != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ----------------------------------------------------------------------------
+// Loop unrolling
+
+// Returns the number of iterations of the given loop if the latter can be unrolled, that is a counting loop
+// with constant bounds (see tripCount) whose body spawns at least a Goroutine. Unrolling such loops lets the
+// Goroutines spawned at each iteration be distinct participants, instead of a single one spawned again and
+// again by the back edge of the loop (which would lose how many instances of the Goroutine are running)
+func unrollableIterations(stmt *ast.ForStmt, fm *FuncMetadata) (int, bool) {
+	iterations, isCounting := tripCount(stmt, fm.constants, maxUnrolledIterations)
+	return iterations, isCounting && isUnrollableBody(stmt.Body, iterations)
+}
+
+// Returns true if the given loop body with the given number of iterations can be unrolled: the body has to
+// spawn a Goroutine and it must not leave the current iteration early (no break, continue, goto or return)
+func isUnrollableBody(body *ast.BlockStmt, iterations int) bool {
+	spawns, jumps := false, false
+
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch node.(type) {
+		case *ast.GoStmt:
+			spawns = true
+		case *ast.BranchStmt, *ast.ReturnStmt:
+			jumps = true
+		case *ast.FuncLit:
+			// The jumps inside a closure don't affect the loop, the Goroutines spawned by it are
+			// counted if the closure itself is spawned (found already as a GoStmt)
+			return false
+		}
+		return true
+	})
+
+	return spawns && !jumps && iterations <= maxUnrolledIterations
+}
+
+// Unrolls a loop with a known number of iterations, the body (and then the post statement, if any) is
+// parsed once per iteration in sequence. Each iteration starts with an eps-transition labeled with its
+// index (e.g "for-unrolled-iteration-0"), after the last one the execution continues with no back edge
+func unrollLoop(body *ast.BlockStmt, post ast.Stmt, iterations int, fm *FuncMetadata) {
+	for i := 0; i < iterations; i++ {
+		tEpsIteration := fsa.ForUnrolledIteration.With(i).Transition()
+		fm.emit(tEpsIteration)
+
+		ast.Walk(fm, body)
+		if post != nil {
+			ast.Walk(fm, post)
+		}
+	}
+
+	// The exit from the unrolled loop, as in the non unrolled case (where it's "for-iteration-skip")
+	tEpsExit := fsa.ForUnrolledExit.Transition()
+	fm.emit(tEpsExit)
 }
@@ -0,0 +1,133 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the parsing of the loops (range-over-int, range-over-func, unrolling) and of their conditions
+package static_analysis
+
+import "testing"
+
+func TestRangeOverInt(t *testing.T) {
+	text := mainScopeAutomaton(t, `package main
+func main() {
+	ch := make(chan int, 3)
+	for i := range 3 {
+		ch <- i
+	}
+}
+`)
+
+	expected := `final 5
+0 -> 1 Call "make"
+1 -> 2 Epsilon "range-iteration-start@main.go:4:2"
+1 -> 4 Epsilon "range-iteration-skip@main.go:4:2"
+2 -> 3 Send "ch"
+3 -> 1 Epsilon "range-iteration-end@main.go:4:2"
+4 -> 5 Epsilon "func-main-return@main.go:7:1"
+`
+	if text != expected {
+		t.Errorf("expected the ScopeAutomata\n%s\ngot\n%s", expected, text)
+	}
+}
+
+func TestRangeOverFunc(t *testing.T) {
+	text := mainScopeAutomaton(t, `package main
+func main() {
+	in, out := make(chan int), make(chan int)
+	for v := range func(yield func(int) bool) { yield(<-in); if !yield(<-in) { return } } {
+		out <- v
+	}
+}
+`)
+
+	// Every call to yield is expanded with the loop body, after the receive done by the iterator
+	// (the second one is part of the if condition, so it's evaluated before branching). The return of the
+	// iterator ends the loop, the state it leaves (12) is never entered and the if block merges with it
+	expected := `final 14
+0 -> 1 Call "make"
+1 -> 2 Call "make"
+2 -> 3 Recv "in"
+3 -> 4 Epsilon "range-yield-start@main.go:4:46"
+4 -> 5 Send "out"
+5 -> 6 Epsilon "range-yield-end@main.go:4:46"
+6 -> 7 Recv "in"
+7 -> 8 Epsilon "range-yield-start@main.go:4:59"
+8 -> 9 Send "out"
+9 -> 10 Epsilon "range-yield-end@main.go:4:59"
+10 -> 11 Epsilon "if-block-start@main.go:4:59"
+10 -> 13 Epsilon "if-block-skip@main.go:4:59"
+11 -> 13 Epsilon "range-yield-return@main.go:4:77"
+12 -> 13 Epsilon "if-block-end@main.go:4:59"
+13 -> 14 Epsilon "func-main-return@main.go:7:1"
+`
+	if text != expected {
+		t.Errorf("expected the ScopeAutomata\n%s\ngot\n%s", expected, text)
+	}
+}
+
+func TestUnrolledSpawnLoop(t *testing.T) {
+	text := mainScopeAutomaton(t, `package main
+const numWorkers = 2
+func worker(ch chan int) { ch <- 0 }
+func main() {
+	ch := make(chan int)
+	for i := 0; i < numWorkers; i++ {
+		go worker(ch)
+	}
+	for i := numWorkers; i > 0; i-- {
+		<-ch
+	}
+}
+`)
+
+	// Only the loop that spawns is unrolled, the other one keeps its back edge
+	expected := `final 10
+0 -> 1 Call "make"
+1 -> 2 Epsilon "for-unrolled-iteration-0@main.go:6:2"
+2 -> 3 Spawn "worker"
+3 -> 4 Epsilon "for-unrolled-iteration-1@main.go:6:2"
+4 -> 5 Spawn "worker"
+5 -> 6 Epsilon "for-unrolled-exit@main.go:6:2"
+6 -> 7 Epsilon "for-iteration-start@main.go:9:2"
+6 -> 9 Epsilon "for-iteration-skip@main.go:9:2"
+7 -> 8 Recv "ch"
+8 -> 6 Epsilon "for-iteration-end@main.go:9:2"
+9 -> 10 Epsilon "func-main-return@main.go:12:1"
+`
+	if text != expected {
+		t.Errorf("expected the ScopeAutomata\n%s\ngot\n%s", expected, text)
+	}
+}
+
+func TestReceiveInCondition(t *testing.T) {
+	text := mainScopeAutomaton(t, `package main
+func main() {
+	tick, done := make(chan bool), make(chan bool)
+	for <-tick {
+	}
+	if len(done) == 0 && <-done {
+	}
+}
+`)
+
+	// The loop condition is evaluated again after each iteration, the right operand of && may be skipped
+	expected := `final 11
+0 -> 1 Call "make"
+1 -> 2 Call "make"
+2 -> 3 Recv "tick"
+3 -> 4 Epsilon "for-iteration-start@main.go:4:2"
+3 -> 5 Epsilon "for-iteration-skip@main.go:4:2"
+4 -> 2 Epsilon "for-iteration-end@main.go:4:2"
+5 -> 6 Epsilon "condition-rhs-start@main.go:6:2"
+5 -> 8 Epsilon "condition-rhs-skip@main.go:6:2"
+6 -> 7 Recv "done"
+7 -> 8 Epsilon "condition-rhs-end@main.go:6:2"
+8 -> 9 Epsilon "if-block-start@main.go:6:2"
+8 -> 10 Epsilon "if-block-skip@main.go:6:2"
+9 -> 10 Epsilon "if-block-end@main.go:6:2"
+10 -> 11 Epsilon "func-main-return@main.go:8:1"
+`
+	if text != expected {
+		t.Errorf("expected the ScopeAutomata\n%s\ngot\n%s", expected, text)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package static_analysis declares the types used to represent metadata extracted from the Go source.
+// The source code is transformed to an Abstract Syntax Tree via go/ast module.
+// Said AST is visited through the Visitor pattern all the metadata available are extractred
+// and agglomerated in a single comprehensive struct.
+//
+package static_analysis
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+)
+
+// The name under which a method is stored among the functions of the file (e.g "Server.Run")
+const methodNameTemplate = "%s.%s"
+
+// ----------------------------------------------------------------------------
+// ReceiverMetadata
+
+// A ReceiverMetadata describes the receiver of a method declared in the file (e.g "s *Server" for
+// "func (s *Server) Run()"). The methods are functions of the file as any other, only they're stored (and
+// called) by the name of their receiver type followed by their own (see MethodName), so that the methods
+// with the same name declared on different types don't collide with each other nor with the plain functions
+type ReceiverMetadata struct {
+	Name    string // The identifier of the receiver inside the method (empty if unnamed)
+	Type    string // The name of the receiver type, without pointer and type parameters (e.g "Server")
+	Pointer bool   // The method is declared on the pointer type (e.g "*Server")
+}
+
+// Returns the name under which the given method of the given receiver type is stored (e.g "Server.Run")
+func MethodName(receiverType, method string) string {
+	return fmt.Sprintf(methodNameTemplate, receiverType, method)
+}
+
+// Returns the name under which the given function (or method) declaration is stored among the functions of the file
+func declarationName(decl *ast.FuncDecl) string {
+	if decl.Recv == nil || len(decl.Recv.List) != 1 {
+		return decl.Name.Name
+	}
+	return MethodName(receiverType(decl.Recv.List[0].Type), decl.Name.Name)
+}
+
+// Returns the metadata about the receiver of the given method declaration (the zero value for the plain functions)
+func parseReceiver(decl *ast.FuncDecl) ReceiverMetadata {
+	if decl.Recv == nil || len(decl.Recv.List) != 1 {
+		return ReceiverMetadata{}
+	}
+
+	field := decl.Recv.List[0]
+	_, isPointer := field.Type.(*ast.StarExpr)
+	receiver := ReceiverMetadata{Type: receiverType(field.Type), Pointer: isPointer}
+	if len(field.Names) == 1 && field.Names[0].Name != "_" {
+		receiver.Name = field.Names[0].Name
+	}
+	return receiver
+}
+
+// ----------------------------------------------------------------------------
+// Method calls resolution
+
+// Returns the variables of a function whose type declares some methods in the file, by name of the variable:
+// the receiver, the parameters and the local variables declared with a type (e.g "var s Server") or assigned to
+// a value of such type (e.g "s := &Server{}" or "s := new(Server)"). The inherited ones are the variables of the
+// enclosing function (for the closures). As for the constants (see propagateConstants) the names aren't resolved
+// against their scope, the last declaration found wins
+func typedVariables(funcType *ast.FuncType, receiver ReceiverMetadata, body *ast.BlockStmt, methods map[string][]string, inherited map[string]string) map[string]string {
+	variables := make(map[string]string)
+	for name, typeName := range inherited {
+		variables[name] = typeName
+	}
+	declare := func(name, typeName string) {
+		if name != "_" && len(methods[typeName]) > 0 {
+			variables[name] = typeName
+		}
+	}
+
+	if receiver.Name != "" {
+		declare(receiver.Name, receiver.Type)
+	}
+	for _, param := range funcType.Params.List {
+		for _, ident := range param.Names {
+			declare(ident.Name, receiverType(param.Type))
+		}
+	}
+
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch stmt := node.(type) {
+		case *ast.AssignStmt:
+			if (stmt.Tok != token.DEFINE && stmt.Tok != token.ASSIGN) || len(stmt.Lhs) != len(stmt.Rhs) {
+				return true
+			}
+			for i, lVal := range stmt.Lhs {
+				if ident, isIdent := lVal.(*ast.Ident); isIdent {
+					declare(ident.Name, valueType(stmt.Rhs[i]))
+				}
+			}
+		case *ast.ValueSpec:
+			for i, ident := range stmt.Names {
+				if stmt.Type != nil {
+					declare(ident.Name, receiverType(stmt.Type))
+				} else if len(stmt.Names) == len(stmt.Values) {
+					declare(ident.Name, valueType(stmt.Values[i]))
+				}
+			}
+		}
+		return true
+	})
+
+	return variables
+}
+
+// Returns the name of the type of the value built by the given expression (e.g "Server" for "&Server{}",
+// "Server{}" or "new(Server)"), an empty string is returned for any other expression
+func valueType(expr ast.Expr) string {
+	switch castExpr := unparen(expr).(type) {
+	case *ast.CompositeLit:
+		if castExpr.Type != nil {
+			return receiverType(castExpr)
+		}
+	case *ast.UnaryExpr:
+		if castExpr.Op == token.AND {
+			return valueType(castExpr.X)
+		}
+	case *ast.CallExpr:
+		if ident, isIdent := castExpr.Fun.(*ast.Ident); isIdent && ident.Name == "new" && len(castExpr.Args) == 1 {
+			return receiverType(castExpr.Args[0])
+		}
+	}
+	return ""
+}
+
+// Returns the name of the method of the file called by the given selector (e.g "Server.Run" for "s.Run" when
+// "s" is a *Server), either on a variable of a known type (see typedVariables) or on a value built in place
+// (e.g "(&Server{}).Run"). False is returned for the package functions and the methods of unknown types
+func (fm *FuncMetadata) methodName(selector *ast.SelectorExpr) (string, bool) {
+	typeName := valueType(selector.X)
+	if ident, isIdent := selector.X.(*ast.Ident); isIdent {
+		typeName = fm.variables[ident.Name]
+	}
+
+	for _, method := range fm.methods[typeName] {
+		if method == selector.Sel.Name {
+			return MethodName(typeName, method), true
+		}
+	}
+	return "", false
+}
+
+// Returns true if the callee of the given call is a method declared in the file (see methodName)
+func (fm *FuncMetadata) isMethodCall(expr *ast.CallExpr) bool {
+	selector, isSelector := expr.Fun.(*ast.SelectorExpr)
+	if !isSelector {
+		return false
+	}
+	_, isMethod := fm.methodName(selector)
+	return isMethod
+}
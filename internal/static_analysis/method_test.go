@@ -0,0 +1,82 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the methods declared in the file and for the resolution of their calls
+package static_analysis
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+)
+
+func TestMethods(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"main.go": `package main
+type Server struct{}
+type Worker struct{}
+func (s *Server) Run(out chan int) { s.notify(out) }
+func (s *Server) notify(out chan int) { out <- 1 }
+func (Worker) Run(in chan int) { <-in }
+func Run() {}
+func main() {
+	ch := make(chan int)
+	s := &Server{}
+	var w Worker
+	go w.Run(ch)
+	s.Run(ch)
+	func() { s.notify(ch) }()
+	Run()
+}
+`,
+	})
+
+	metadata := ExtractMetadata(filepath.Join(root, "main.go"), NoTrace)
+
+	// The methods with the same name don't collide with each other nor with the plain function
+	names := []string{}
+	for name := range metadata.FunctionMeta {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if expected := "Run Server.Run Server.notify Worker.Run main main-func1"; strings.Join(names, " ") != expected {
+		t.Fatalf("expected the functions %s, got %v", expected, names)
+	}
+	if receiver := metadata.FunctionMeta["Server.Run"].Receiver; receiver != (ReceiverMetadata{"s", "Server", true}) {
+		t.Errorf("unexpected receiver of Server.Run %+v", receiver)
+	}
+	if receiver := metadata.FunctionMeta["Worker.Run"].Receiver; receiver != (ReceiverMetadata{"", "Worker", false}) {
+		t.Errorf("unexpected receiver of Worker.Run %+v", receiver)
+	}
+	if receiver := metadata.FunctionMeta["Run"].Receiver; receiver != (ReceiverMetadata{}) {
+		t.Errorf("expected no receiver for the plain function, got %+v", receiver)
+	}
+
+	// The calls and spawns are resolved after the type of the receiver, inside the methods and closures as well
+	calls := map[string][]string{}
+	for _, name := range []string{"main", "main-func1", "Server.Run"} {
+		metadata.FunctionMeta[name].Automaton.ForEachTransition(func(from, to int, tr fsa.Transition) {
+			if tr.Move == fsa.Call || tr.Move == fsa.Spawn {
+				calls[name] = append(calls[name], tr.Move.String()+" "+tr.Label)
+			}
+		})
+	}
+	expected := map[string]string{
+		"main":       "Call make, Spawn Worker.Run, Call Server.Run, Call main-func1, Call Run",
+		"main-func1": "Call Server.notify",
+		"Server.Run": "Call Server.notify",
+	}
+	for name, text := range expected {
+		if strings.Join(calls[name], ", ") != text {
+			t.Errorf("expected the calls of %s to be %s, got %v", name, text, calls[name])
+		}
+	}
+	if metadata.Unsupported.Len() != 0 {
+		t.Errorf("expected the method calls to be supported, got %s", metadata.Unsupported)
+	}
+}
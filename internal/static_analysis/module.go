@@ -0,0 +1,205 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package static_analysis declares the types used to represent metadata extracted from the Go source.
+// The source code is transformed to an Abstract Syntax Tree via go/ast module.
+// Said AST is visited through the Visitor pattern all the metadata available are extractred
+// and agglomerated in a single comprehensive struct.
+//
+package static_analysis
+
+import (
+	"fmt"
+	"go/build"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	modFileName     = "go.mod"             // The file that declares a module, in its root directory
+	vendorManifest  = "vendor/modules.txt" // The manifest of the vendored dependencies, relative to the module root
+	vendorDirectory = "vendor"             // The directory of the vendored packages, relative to the module root
+)
+
+// The build context with which the files of a package are selected (see SetBuildContext)
+var buildContext = build.Default
+
+// ----------------------------------------------------------------------------
+// Build context
+
+// Sets the build tags and the target (GOOS and GOARCH) with which the files of a package are selected from now
+// on, the files excluded by their build constraints (or by their name, e.g "server_windows.go") aren't analyzed.
+// An empty GOOS (or GOARCH) is the one of the host, as cgo is disabled when the target isn't the latter (as
+// done by the go tool). The files given on their own are analyzed anyway, the selection applies to the packages
+func SetBuildContext(tags []string, goos, goarch string) {
+	buildContext = build.Default
+	buildContext.BuildTags = append([]string{}, tags...)
+	if goos != "" {
+		buildContext.GOOS = goos
+	}
+	if goarch != "" {
+		buildContext.GOARCH = goarch
+	}
+	if buildContext.GOOS != runtime.GOOS || buildContext.GOARCH != runtime.GOARCH {
+		buildContext.CgoEnabled = false
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Module
+
+// A Module is the Go module that contains the analyzed package, as declared by its go.mod file. It maps the import
+// paths to the local directories of the packages: the ones of the module itself, the vendored ones (when the
+// module has a vendor directory) and the ones of the modules replaced with a local directory (e.g "replace
+// example.com/lib => ../lib"). The other modules are in the module cache, that isn't looked up
+type Module struct {
+	Path     string            // The module path (e.g "github.com/its-hmny/Choreia")
+	Dir      string            // The root directory of the module, the one of the go.mod file
+	Replaces map[string]string // The directories of the modules replaced with a local one, by module path
+	Vendored bool              // The dependencies are resolved from the vendor directory (see vendorManifest)
+}
+
+// Returns the module that contains the given directory, the go.mod file is searched from the latter up to the
+// root of the filesystem. Nil is returned (without error) when the directory doesn't belong to any module
+func FindModule(dirPath string) (*Module, error) {
+	dirPath, err := filepath.Abs(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		content, err := os.ReadFile(filepath.Join(dirPath, modFileName))
+		if err == nil {
+			return ParseModFile(dirPath, content)
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		parent := filepath.Dir(dirPath)
+		if parent == dirPath {
+			return nil, nil
+		}
+		dirPath = parent
+	}
+}
+
+// Parses the content of the go.mod file in the given directory, only the module path and the replacements with a
+// local directory are needed to resolve the import paths. The replacements can be declared one by one or in a
+// block (e.g "replace ( ... )"), the local directories are relative to the module root
+func ParseModFile(dirPath string, content []byte) (*Module, error) {
+	module := &Module{Dir: dirPath, Replaces: make(map[string]string)}
+	if _, err := os.Stat(filepath.Join(dirPath, vendorManifest)); err == nil {
+		module.Vendored = true
+	}
+
+	inBlock := ""
+	for i, line := range strings.Split(string(content), "\n") {
+		if index := strings.Index(line, "//"); index >= 0 {
+			line = line[:index]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		// The directives of a block (e.g "require (") are on their own line, up to the closing parenthesis
+		verb := inBlock
+		switch {
+		case inBlock != "" && fields[0] == ")":
+			inBlock = ""
+			continue
+		case inBlock == "" && len(fields) == 2 && fields[1] == "(":
+			inBlock = fields[0]
+			continue
+		case inBlock == "":
+			verb, fields = fields[0], fields[1:]
+		}
+
+		switch verb {
+		case "module":
+			if len(fields) != 1 {
+				return nil, fmt.Errorf("%s:%d: malformed module directive", modFileName, i+1)
+			}
+			module.Path = unquotePath(fields[0])
+		case "replace":
+			arrow := indexOf(fields, "=>")
+			if arrow < 1 || arrow == len(fields)-1 {
+				return nil, fmt.Errorf("%s:%d: malformed replace directive", modFileName, i+1)
+			}
+			// Only the replacements with a local directory (without version) are resolved
+			if target := unquotePath(fields[arrow+1]); isLocalPath(target) && arrow+2 == len(fields) {
+				if !filepath.IsAbs(target) {
+					target = filepath.Join(dirPath, target)
+				}
+				module.Replaces[unquotePath(fields[0])] = target
+			}
+		}
+	}
+
+	if module.Path == "" {
+		return nil, fmt.Errorf("%s: missing module directive", filepath.Join(dirPath, modFileName))
+	}
+	return module, nil
+}
+
+// Returns the local directory of the package with the given import path, false is returned when the latter
+// doesn't belong to the module nor to a vendored (or locally replaced) one. The vendored packages take the
+// precedence over the replacements, as done by the go tool, while the longest replaced module path wins
+func (module *Module) PackageDir(importPath string) (string, bool) {
+	if dir, isInside := subPath(module.Path, importPath); isInside {
+		return filepath.Join(module.Dir, dir), true
+	}
+	if module.Vendored {
+		vendored := filepath.Join(module.Dir, vendorDirectory, filepath.FromSlash(importPath))
+		if info, err := os.Stat(vendored); err == nil && info.IsDir() {
+			return vendored, true
+		}
+		return "", false
+	}
+
+	replaced, replacedDir := "", ""
+	for modulePath, targetDir := range module.Replaces {
+		if dir, isInside := subPath(modulePath, importPath); isInside && len(modulePath) > len(replaced) {
+			replaced, replacedDir = modulePath, filepath.Join(targetDir, dir)
+		}
+	}
+	return replacedDir, replaced != ""
+}
+
+// Returns the path of the given import path relative to the given module path, if the former belongs to the latter
+func subPath(modulePath, importPath string) (string, bool) {
+	if importPath == modulePath {
+		return ".", true
+	} else if strings.HasPrefix(importPath, modulePath+"/") {
+		return filepath.FromSlash(strings.TrimPrefix(importPath, modulePath+"/")), true
+	}
+	return "", false
+}
+
+// Returns the given path of a go.mod directive without the quotes, if any (e.g "\"example.com/lib\"")
+func unquotePath(path string) string {
+	if unquoted, err := strconv.Unquote(path); err == nil {
+		return unquoted
+	}
+	return path
+}
+
+// Returns true if the given replacement target is a local directory rather than a module path
+func isLocalPath(path string) bool {
+	return filepath.IsAbs(path) || path == "." || path == ".." ||
+		strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../")
+}
+
+// Returns the index of the given item in the given slice, -1 if it isn't there
+func indexOf(items []string, item string) int {
+	for i, current := range items {
+		if current == item {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the go.mod parsing, the resolution of the import paths and the build context
+package static_analysis
+
+import (
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestModuleResolution(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"app/go.mod": `module "example.com/app" // the main module
+
+go 1.16
+
+require example.com/lib v1.0.0
+replace example.com/lib => ../lib
+replace (
+	example.com/lib/v2 v2.0.0 => ./forks/lib
+	example.com/remote => example.com/fork v1.2.0
+)
+`,
+		"app/cmd/server/main.go": "package main\nfunc main() {}\n",
+	})
+
+	module, err := FindModule(filepath.Join(root, "app", "cmd", "server"))
+	if err != nil || module == nil {
+		t.Fatalf("expected the module to be found, got %v (%v)", module, err)
+	}
+	if module.Path != "example.com/app" || module.Dir != filepath.Join(root, "app") || module.Vendored {
+		t.Errorf("unexpected module %+v", module)
+	}
+
+	// The longest replaced module wins, the replacements with another module aren't resolved
+	for importPath, expected := range map[string]string{
+		"example.com/app":            filepath.Join(root, "app"),
+		"example.com/app/cmd/server": filepath.Join(root, "app", "cmd", "server"),
+		"example.com/lib/queue":      filepath.Join(root, "lib", "queue"),
+		"example.com/lib/v2/queue":   filepath.Join(root, "app", "forks", "lib", "queue"),
+		"example.com/remote":         "",
+		"example.com/application":    "",
+	} {
+		if dir, isLocal := module.PackageDir(importPath); dir != expected || isLocal != (expected != "") {
+			t.Errorf("expected '%s' to be resolved to '%s', got '%s'", importPath, expected, dir)
+		}
+	}
+
+	if _, err := ParseModFile(root, []byte("replace example.com/lib =>\n")); err == nil {
+		t.Errorf("expected an error for the malformed replace directive")
+	}
+	if module, err := FindModule(root); module != nil || err != nil {
+		t.Errorf("expected no module outside of the module root, got %v (%v)", module, err)
+	}
+}
+
+func TestVendoredModule(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"go.mod":                        "module example.com/app\nreplace example.com/lib => ../lib\n",
+		"vendor/modules.txt":            "# example.com/lib v1.0.0 => ../lib\nexample.com/lib\n",
+		"vendor/example.com/lib/lib.go": "package lib\n",
+	})
+
+	module, err := FindModule(root)
+	if err != nil || !module.Vendored {
+		t.Fatalf("expected a vendored module, got %+v (%v)", module, err)
+	}
+	if dir, _ := module.PackageDir("example.com/lib"); dir != filepath.Join(root, "vendor", "example.com", "lib") {
+		t.Errorf("expected the vendored package to take the precedence, got '%s'", dir)
+	}
+	if _, isLocal := module.PackageDir("example.com/other"); isLocal {
+		t.Errorf("expected the packages not vendored to be unresolved")
+	}
+}
+
+func TestBuildContext(t *testing.T) {
+	defer SetBuildContext(nil, "", "")
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"main.go":         "package main\nfunc main() {}\n",
+		"main_windows.go": "package main\nfunc windows() {}\n",
+		"main_linux.go":   "package main\nfunc linux() {}\n",
+		"debug.go":        "//go:build debug\n\npackage main\nfunc debug() {}\n",
+	})
+
+	functions := func() []string {
+		names := []string{}
+		for name := range ExtractPackageMetadata(root, NoTrace).FunctionMeta {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	SetBuildContext(nil, "windows", "amd64")
+	if names := functions(); len(names) != 2 || names[0] != "main" || names[1] != "windows" {
+		t.Errorf("expected only the files for windows, got %v", names)
+	}
+	SetBuildContext([]string{"debug"}, "linux", "")
+	if names := functions(); len(names) != 3 || names[0] != "debug" || names[1] != "linux" || names[2] != "main" {
+		t.Errorf("expected the files for linux with the debug tag, got %v", names)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package static_analysis declares the types used to represent metadata extracted from the Go source.
+// The source code is transformed to an Abstract Syntax Tree via go/ast module.
+// Said AST is visited through the Visitor pattern all the metadata available are extractred
+// and agglomerated in a single comprehensive struct.
+//
+package static_analysis
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+)
+
+// The name under which the network extractor is registered (see EnableNetworkHandlers)
+const NetworkExtractorName = "network-handlers"
+
+const (
+	httpHandlerTemplate = "http %s" // The name of the function that models an HTTP handler (e.g "http /orders")
+	grpcHandlerTemplate = "grpc %s" // The name of the function that models a gRPC service (e.g "grpc Orders")
+	requestTemplate     = "%s request"
+	responseTemplate    = "%s response"
+)
+
+// Matches the registration functions generated for the gRPC services (e.g "pb.RegisterOrdersServer")
+var grpcRegisterPattern = regexp.MustCompile(`^Register(\w+)Server$`)
+
+// ----------------------------------------------------------------------------
+// Network handlers (experimental)
+
+// Registers the network extractor, an opt-in extension that models the HTTP handlers (e.g "http.HandleFunc(
+// "/orders", handleOrders)") and the gRPC services (e.g "pb.RegisterOrdersServer(s, &server{})") registered in
+// the program as participants of their own, driven by an external "network" participant. The latter sends a
+// request on a synthetic channel (e.g "/orders request") to the handler that, after running, sends back the
+// response on another one (e.g "/orders response"). The handlers are collected as functions of the file, each
+// one with its Endpoint set, from which the network participant is built (see transforms.ExtractNetwork)
+func EnableNetworkHandlers() {
+	RegisterExtractor(NetworkExtractorName, networkExtractor)
+}
+
+// Returns the synthetic channels on which the given endpoint receives the requests and sends the responses
+func NetworkChannels(endpoint string) (ChanMetadata, ChanMetadata) {
+	request := ChanMetadata{Name: fmt.Sprintf(requestTemplate, endpoint), Type: "request"}
+	response := ChanMetadata{Name: fmt.Sprintf(responseTemplate, endpoint), Type: "response"}
+	return request, response
+}
+
+// The Extractor that recognizes the registration of the network handlers, the registration itself
+// doesn't add any transition: the handler is started by the network participant instead
+func networkExtractor(node ast.Node, fm *FuncMetadata) bool {
+	callExpr, isCall := node.(*ast.CallExpr)
+	if !isCall {
+		return false
+	}
+	selector, isSelector := callExpr.Fun.(*ast.SelectorExpr)
+	if !isSelector || len(callExpr.Args) != 2 {
+		return false
+	}
+
+	// HTTP handlers registered on the default or on a custom ServeMux (e.g "mux.HandleFunc(path, handler)")
+	if selector.Sel.Name == "HandleFunc" {
+		var tCall fsa.Transition
+		switch handler := callExpr.Args[1].(type) {
+		case *ast.Ident:
+			tCall = fsa.Transition{Move: fsa.Call, Label: handler.Name}
+		case *ast.FuncLit:
+			tCall = parseFuncLit(handler, &ast.CallExpr{Fun: handler}, fsa.Call, fm)
+		default:
+			return false
+		}
+
+		endpoint := handlerPath(callExpr.Args[0])
+		fm.addHandler(fmt.Sprintf(httpHandlerTemplate, endpoint), endpoint, []fsa.Transition{tCall})
+		return true
+	}
+
+	// gRPC services, every method declared on the type of the implementation (e.g "&server{}") can be invoked
+	if match := grpcRegisterPattern.FindStringSubmatch(selector.Sel.Name); match != nil {
+		methods := append([]string{}, fm.methods[receiverType(callExpr.Args[1])]...)
+		sort.Strings(methods)
+
+		tCalls := make([]fsa.Transition, len(methods))
+		for i, method := range methods {
+			tCalls[i] = fsa.Transition{Move: fsa.Call, Label: MethodName(receiverType(callExpr.Args[1]), method)}
+		}
+
+		fm.addHandler(fmt.Sprintf(grpcHandlerTemplate, match[1]), match[1], tCalls)
+		return true
+	}
+
+	return false
+}
+
+// Adds to the functions of the file the one that models a network handler with the given name, that serves
+// the requests of the given endpoint forever: the request is received, then one of the given calls is made
+// (the handler itself or one of the methods of a gRPC service) and at last the response is sent
+func (fm *FuncMetadata) addHandler(name, endpoint string, tCalls []fsa.Transition) {
+	request, response := NetworkChannels(endpoint)
+	handler := FuncMetadata{
+		Name:       name,
+		ChanMeta:   map[string]ChanMetadata{request.Name: request, response.Name: response},
+		InlineArgs: make([]FuncArg, 0),
+		Automaton:  fsa.New(),
+		Endpoint:   endpoint,
+	}
+
+	handler.Automaton.AddTransition(0, 1, fsa.Transition{Move: fsa.Recv, Label: request.Name, Payload: request})
+	if len(tCalls) == 0 {
+		handler.Automaton.AddTransition(1, 2, fsa.NetworkHandlerCall.Transition())
+	}
+	for _, tCall := range tCalls {
+		handler.Automaton.AddTransition(1, 2, tCall)
+	}
+	handler.Automaton.AddTransition(2, 0, fsa.Transition{Move: fsa.Send, Label: response.Name, Payload: response})
+	handler.Automaton.SetFinalState(0)
+
+	fm.functions[name] = handler
+}
+
+// Returns the path given to a registration (e.g "/orders"), any expression that isn't a string literal is kept as it is
+func handlerPath(expr ast.Expr) string {
+	if literal, isLiteral := expr.(*ast.BasicLit); isLiteral && literal.Kind == token.STRING {
+		if path, err := strconv.Unquote(literal.Value); err == nil {
+			return path
+		}
+	}
+	return types.ExprString(expr)
+}
+
+// Returns the name of the type of the given receiver or value, without pointers and literals
+// (e.g "server" for "*server", "&server{}" or "server{}")
+func receiverType(expr ast.Expr) string {
+	switch castExpr := expr.(type) {
+	case *ast.StarExpr:
+		return receiverType(castExpr.X)
+	case *ast.UnaryExpr:
+		return receiverType(castExpr.X)
+	case *ast.CompositeLit:
+		return receiverType(castExpr.Type)
+	case *ast.IndexExpr: // Generic receivers (e.g "*list[T]")
+		return receiverType(castExpr.X)
+	}
+	return strings.TrimSpace(types.ExprString(expr))
+}
@@ -0,0 +1,55 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the recognition of the HTTP/gRPC handlers registered in the program
+package static_analysis
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestNetworkHandlers(t *testing.T) {
+	defer func() { extractors = []namedExtractor{} }()
+	EnableNetworkHandlers()
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"main.go": `package main
+import ("net/http"; "google.golang.org/grpc"; "pb")
+type server struct{}
+func (s *server) Place() {}
+func (s *server) Cancel() {}
+func handleOrders(w http.ResponseWriter, r *http.Request) {}
+func main() {
+	http.HandleFunc("/orders", handleOrders)
+	pb.RegisterOrdersServer(grpc.NewServer(), &server{})
+}
+`,
+	})
+
+	metadata := ExtractMetadata(filepath.Join(root, "main.go"), NoTrace)
+
+	expected := map[string]string{
+		"http /orders": "final 0\n0 -> 1 Recv \"/orders request\"\n1 -> 2 Call \"handleOrders\"\n2 -> 0 Send \"/orders response\"\n",
+		"grpc Orders":  "final 0\n0 -> 1 Recv \"Orders request\"\n1 -> 2 Call \"server.Cancel\"\n1 -> 2 Call \"server.Place\"\n2 -> 0 Send \"Orders response\"\n",
+	}
+	for name, text := range expected {
+		handler, exist := metadata.FunctionMeta[name]
+		if !exist {
+			t.Fatalf("expected the handler '%s' to be registered", name)
+		}
+		if handler.Automaton.String() != text {
+			t.Errorf("expected the automaton of '%s'\n%s\ngot\n%s", name, text, handler.Automaton.String())
+		}
+	}
+
+	// The registration itself isn't part of the automaton of main
+	if text := metadata.FunctionMeta["main"].Automaton.String(); text != "final 1\n0 -> 1 Epsilon \"func-main-return@main.go:10:1\"\n" {
+		t.Errorf("expected the registrations to be skipped in main, got\n%s", text)
+	}
+	if endpoint := metadata.FunctionMeta["grpc Orders"].Endpoint; endpoint != "Orders" {
+		t.Errorf("expected the endpoint 'Orders', got '%s'", endpoint)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package static_analysis declares the types used to represent metadata extracted from the Go source.
+// The source code is transformed to an Abstract Syntax Tree via go/ast module.
+// Said AST is visited through the Visitor pattern all the metadata available are extractred
+// and agglomerated in a single comprehensive struct.
+//
+package static_analysis
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+)
+
+// The name under which the n-th init function of the package is stored (e.g "init#2"), they're all named "init"
+const initNameTemplate = "init#%d"
+
+// ----------------------------------------------------------------------------
+// PackageMetadata
+
+// A PackageMetadata contains the metadata about the analyzed package as a whole, rather than about one of its
+// functions. The package can declare any number of init functions (even more than one per file), that the Go
+// runtime runs one after the other before main: the files are given to the compiler in the order of their names
+// (as done by "go build", see packageFiles) and the init functions of a file run in the order of declaration.
+// The imported packages are initialized before the analyzed one, but they aren't analyzed so they don't appear
+type PackageMetadata struct {
+	Name     string   // The name of the package (e.g "main")
+	InitFlow []string // The names of the init functions (see initNameTemplate), in the order in which they run
+}
+
+// Returns true if the given declaration is an init function of the package (a method can be named init as well)
+func isInitFunc(decl *ast.FuncDecl) bool {
+	return decl.Name.Name == "init" && decl.Recv == nil && decl.Body != nil
+}
+
+// Adds the given init function to the init flow of the package and returns the name under which it's stored
+func (pm *PackageMetadata) addInit() string {
+	name := fmt.Sprintf(initNameTemplate, len(pm.InitFlow)+1)
+	pm.InitFlow = append(pm.InitFlow, name)
+	return name
+}
+
+// Returns the global channels created by the given init function (e.g "jobs = make(chan int)" with "var jobs chan
+// int" in the global scope). The latter are visible in every function of the package, so they're collected before
+// any function is visited. A channel assigned without declaring it in the body is a global one, since the init
+// functions don't have any parameter
+func initChannels(decl *ast.FuncDecl, named map[string]*ast.ChanType) []ChanMetadata {
+	locals, assigned := make(map[string]bool), []ChanMetadata{}
+
+	ast.Inspect(decl.Body, func(node ast.Node) bool {
+		switch stmt := node.(type) {
+		case *ast.AssignStmt:
+			for i, lVal := range stmt.Lhs {
+				ident, isIdent := lVal.(*ast.Ident)
+				if !isIdent {
+					continue
+				} else if stmt.Tok == token.DEFINE {
+					locals[ident.Name] = true
+					continue
+				}
+				if stmt.Tok != token.ASSIGN || len(stmt.Lhs) != len(stmt.Rhs) {
+					continue
+				}
+				if callExpr, isCall := stmt.Rhs[i].(*ast.CallExpr); isCall {
+					assigned = append(assigned, parseMakeCall(callExpr, ident.Name, nil, named))
+				}
+			}
+		case *ast.ValueSpec:
+			for _, ident := range stmt.Names {
+				locals[ident.Name] = true
+			}
+		case *ast.FuncLit:
+			return false // The closures could be called (or spawned) later, their assignments aren't modeled here
+		}
+		return true
+	})
+
+	channels := []ChanMetadata{}
+	for _, channel := range assigned {
+		if !locals[channel.Name] && isBoundChannel(channel) {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
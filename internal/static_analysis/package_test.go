@@ -0,0 +1,42 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the init functions of the package and the global channels they create
+package static_analysis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInitFlow(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"b.go": "package main\nvar jobs chan int\nfunc init() { jobs = make(chan int, 2) }\nfunc main() { jobs <- 1 }\n",
+		"a.go": "package main\ntype config struct{}\nfunc (config) init() {}\nfunc init() { ch := make(chan int); ch = make(chan int); go func() { <-ch }() }\nfunc init() {}\n",
+	})
+
+	// The files are sorted by name, the methods named init aren't init functions
+	metadata := ExtractPackageMetadata(root, NoTrace)
+	if expected := (PackageMetadata{"main", []string{"init#1", "init#2", "init#3"}}); !reflect.DeepEqual(metadata.Package, expected) {
+		t.Errorf("expected the package metadata %v, got %v", expected, metadata.Package)
+	}
+	for _, name := range []string{"init#1", "init#2", "init#3", "config.init"} {
+		if _, exist := metadata.FunctionMeta[name]; !exist {
+			t.Errorf("expected the function '%s' to be stored", name)
+		}
+	}
+	if _, exist := metadata.FunctionMeta["init"]; exist {
+		t.Errorf("expected no function stored as 'init'")
+	}
+
+	// Only the channel assigned to the global variable is a global one, visible in main as well
+	expected := ChanMetadata{Name: "jobs", Type: "int", Async: true, Capacity: 2}
+	if channel := metadata.FunctionMeta["main"].ChanMeta["jobs"]; channel != expected {
+		t.Errorf("expected the global channel %v in main, got %v", expected, channel)
+	}
+	if _, isGlobal := metadata.GlobalChanMeta["ch"]; isGlobal || len(metadata.GlobalChanMeta) != 1 {
+		t.Errorf("expected only 'jobs' to be a global channel, got %v", metadata.GlobalChanMeta)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package static_analysis declares the types used to represent metadata extracted from the Go source.
+// The source code is transformed to an Abstract Syntax Tree via go/ast module.
+// Said AST is visited through the Visitor pattern all the metadata available are extractred
+// and agglomerated in a single comprehensive struct.
+//
+package static_analysis
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+)
+
+// The name given to a channel created in a return statement (e.g "result#0" for "return make(chan int), nil")
+const resultNameTemplate = "result#%d"
+
+// ----------------------------------------------------------------------------
+// Returned channels related parsing method
+
+// Returns the message type of each result declared by the given function signature, the results that
+// aren't channels have an empty type (e.g "func start() (chan Job, error)" returns ["Job", ""]). A
+// field can declare more results at once (e.g "(in, out chan int)"), each one of them has its own entry.
+// The results of a named channel type (e.g "Jobs" with "type Jobs chan Job") are channels as well
+func resultChannelTypes(funcType *ast.FuncType, named map[string]*ast.ChanType) []string {
+	resultTypes := []string{}
+	if funcType.Results == nil {
+		return resultTypes
+	}
+
+	for _, field := range funcType.Results.List {
+		msgType := ""
+		if chanType, isChannel := channelType(field.Type, named); isChannel {
+			msgType = types.ExprString(chanType.Value)
+		}
+
+		for i := 0; i < len(field.Names) || i == 0; i++ {
+			resultTypes = append(resultTypes, msgType)
+		}
+	}
+
+	return resultTypes
+}
+
+// Returns the channels returned by the function with the given body, so that the callers can reference them
+// (see bindResults). The body is scanned once parsed, so that the channels known in the function scope are
+// resolved. The channels are returned by identifier or created in the return statement itself (e.g "return
+// make(chan int, 3), nil"), the latter are added to the channels of the function with a name of their own (see
+// resultNameTemplate) so that the callers get their buffer size as well. When more return statements are given
+// the first channel returned in each position is kept (e.g "return nil, err" doesn't override "return jobs, nil")
+func returnedChannels(body *ast.BlockStmt, fm FuncMetadata) []FuncArg {
+	var returnArgs []FuncArg
+	isBound := make(map[int]bool)
+
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch stmt := node.(type) {
+		case *ast.FuncLit:
+			return false // The return statements of a closure belong to the latter
+		case *ast.ReturnStmt:
+			for offset, result := range stmt.Results {
+				if callExpr, isCall := result.(*ast.CallExpr); isCall && !isBound[offset] {
+					created := parseMakeCall(callExpr, fmt.Sprintf(resultNameTemplate, offset), fm.constants, fm.chanTypes)
+					if isBoundChannel(created) {
+						fm.ChanMeta[created.Name] = created
+						returnArgs = append(returnArgs, FuncArg{Offset: offset, Name: created.Name, Type: Result})
+						isBound[offset] = true
+					}
+				}
+
+				chanIdent, isIdent := result.(*ast.Ident)
+				if !isIdent || isBound[offset] {
+					continue
+				}
+				if _, isChannel := fm.ChanMeta[chanIdent.Name]; isChannel {
+					returnArgs = append(returnArgs, FuncArg{Offset: offset, Name: chanIdent.Name, Type: Result})
+					isBound[offset] = true
+				}
+			}
+		}
+		return true
+	})
+
+	return returnArgs
+}
+
+// Binds the given lvalues to the channels returned by the function with the given name, each lvalue
+// that receives a channel (e.g "jobs" in "jobs, err := startWorker()") is added to the channels of the
+// function scope and it's returned as a Result argument with the position of the result as Offset.
+// The message type is the one declared in the callee signature while the buffering isn't known here,
+// since the callee could be parsed later: it's the inlining of the call that renames the channel
+// returned by the callee as the lvalue, with the metadata of the former. The lvalues declared by the
+// assignment (define) are bound to a new channel of the block being visited (see declareChannels)
+func (fm *FuncMetadata) bindResults(funcName string, lValues []ast.Expr, define bool) []FuncArg {
+	var boundResults []FuncArg
+	resultTypes := fm.signatures[funcName]
+
+	for offset, lVal := range lValues {
+		identName, isIdent := lVal.(*ast.Ident)
+		if !isIdent || identName.Name == "_" || offset >= len(resultTypes) || resultTypes[offset] == "" {
+			continue
+		}
+
+		result := ChanMetadata{Name: identName.Name, Type: resultTypes[offset]}
+		if define {
+			fm.declareChannels(result)
+		} else {
+			fm.addChannels(result)
+		}
+		boundResults = append(boundResults, FuncArg{Offset: offset, Name: fm.channelName(identName.Name), Type: Result})
+	}
+
+	return boundResults
+}
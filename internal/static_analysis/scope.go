@@ -0,0 +1,135 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package static_analysis declares the types used to represent metadata extracted from the Go source.
+// The source code is transformed to an Abstract Syntax Tree via go/ast module.
+// Said AST is visited through the Visitor pattern all the metadata available are extractred
+// and agglomerated in a single comprehensive struct.
+//
+package static_analysis
+
+import (
+	"fmt"
+	"go/ast"
+)
+
+// The name given to a channel that shadows another one with the same name (e.g "ch'1" for the first one)
+const shadowNameTemplate = "%s'%d"
+
+// ----------------------------------------------------------------------------
+// Channel scope
+
+// A channelScope is the stack of the blocks of a function opened during the visit (the innermost is the last),
+// each one with the channels declared inside it. A channel declared in a block (e.g "ch := make(chan int)" in
+// the body of a loop) is identified by its name unless the latter is already taken by another channel of the
+// function, then a distinct identity is given to it (e.g "ch'1") and used for the operations made in the block.
+//
+// The channels inherited by the function (globals, arguments and captured ones) aren't part of any block,
+// so an identifier that isn't declared in the open blocks refers to the channel of the function with that name
+type channelScope struct {
+	blocks []map[string]string // The identity of the channels declared in each open block, by name
+}
+
+// Opens a new (innermost) block, a nil scope is ignored (e.g a function whose body isn't visited)
+func (s *channelScope) open() {
+	if s != nil {
+		s.blocks = append(s.blocks, make(map[string]string))
+	}
+}
+
+// Closes the innermost block, the channels declared inside it aren't visible anymore
+func (s *channelScope) close() {
+	if s != nil && len(s.blocks) > 0 {
+		s.blocks = s.blocks[:len(s.blocks)-1]
+	}
+}
+
+// Returns the identity of the channel the given name refers to, the one declared by the innermost block
+func (s *channelScope) resolve(name string) string {
+	if s == nil {
+		return name
+	}
+	for i := len(s.blocks) - 1; i >= 0; i-- {
+		if identity, isDeclared := s.blocks[i][name]; isDeclared {
+			return identity
+		}
+	}
+	return name
+}
+
+// Declares the channel with the given name in the innermost block and returns its identity. The declarations
+// repeated in the same block (e.g "ch, err := ..." after "ch := ...") refer to the same channel, any other one
+// is given the first identity not yet used by the known channels of the function (see shadowNameTemplate)
+func (s *channelScope) declare(name string, known map[string]ChanMetadata) string {
+	if s == nil || len(s.blocks) == 0 {
+		return name
+	}
+
+	block := s.blocks[len(s.blocks)-1]
+	if identity, isDeclared := block[name]; isDeclared {
+		return identity
+	}
+
+	identity := name
+	for n := 1; ; n++ {
+		if _, isTaken := known[identity]; !isTaken {
+			break
+		}
+		identity = fmt.Sprintf(shadowNameTemplate, name, n)
+	}
+	block[name] = identity
+	return identity
+}
+
+// Returns the channels visible in the innermost block whose identity differs from their name, by name
+func (s *channelScope) shadowed() map[string]string {
+	visible := make(map[string]string)
+	if s == nil {
+		return visible
+	}
+	for _, block := range s.blocks {
+		for name, identity := range block {
+			visible[name] = identity
+		}
+	}
+	for name, identity := range visible {
+		if name == identity {
+			delete(visible, name)
+		}
+	}
+	return visible
+}
+
+// The Visitor of the statements of a block (or of a clause), the block is closed once all of them are visited
+type blockVisitor struct {
+	fm FuncMetadata
+}
+
+// Visits the given node of the block with the Visitor of the function, at the end (nil) closes the block
+func (bv blockVisitor) Visit(node ast.Node) ast.Visitor {
+	if node == nil {
+		bv.fm.scope.close()
+		return nil
+	}
+	return bv.fm.Visit(node)
+}
+
+// ----------------------------------------------------------------------------
+// Channel identifiers resolution
+
+// Returns the identity of the channel that the given identifier refers to in the block being visited
+func (fm *FuncMetadata) channelName(name string) string {
+	return fm.scope.resolve(name)
+}
+
+// Declares the given channel(s) in the block being visited (e.g "ch := make(chan int)"), the metadata of
+// each one is saved under the identity given to it (see channelScope.declare). Invalid channels are ignored
+func (fm *FuncMetadata) declareChannels(newChanMeta ...ChanMetadata) {
+	for _, channel := range newChanMeta {
+		if isBoundChannel(channel) {
+			channel.Name = fm.scope.declare(channel.Name, fm.ChanMeta)
+			fm.ChanMeta[channel.Name] = channel
+		}
+	}
+}
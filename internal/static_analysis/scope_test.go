@@ -0,0 +1,86 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the resolution of the channels shadowed in the nested blocks
+package static_analysis
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+)
+
+func TestShadowedChannels(t *testing.T) {
+	text := mainScopeAutomaton(t, `package main
+func main() {
+	ch := make(chan int, 1)
+	if true {
+		ch := make(chan string)
+		ch <- "inner"
+	}
+	for i := 0; i < 2; i++ {
+		ch = make(chan int, 2)
+		ch <- i
+	}
+	<-ch
+}
+`)
+
+	// The assignment in the loop refers to the outer channel, the declaration in the if-block doesn't
+	expected := `final 11
+0 -> 1 Call "make"
+1 -> 2 Epsilon "if-block-start@main.go:4:2"
+1 -> 5 Epsilon "if-block-skip@main.go:4:2"
+2 -> 3 Call "make"
+3 -> 4 Send "ch'1"
+4 -> 5 Epsilon "if-block-end@main.go:4:2"
+5 -> 6 Epsilon "for-iteration-start@main.go:8:2"
+5 -> 9 Epsilon "for-iteration-skip@main.go:8:2"
+6 -> 7 Call "make"
+7 -> 8 Send "ch"
+8 -> 5 Epsilon "for-iteration-end@main.go:8:2"
+9 -> 10 Recv "ch"
+10 -> 11 Epsilon "func-main-return@main.go:13:1"
+`
+	if text != expected {
+		t.Errorf("expected the ScopeAutomata\n%s\ngot\n%s", expected, text)
+	}
+}
+
+func TestShadowedChannelCapture(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"main.go": `package main
+func main() {
+	done := make(chan bool)
+	for i := 0; i < 2; i++ {
+		done := make(chan int, 1)
+		go func() { done <- 0 }()
+		<-done
+	}
+	<-done
+}
+`})
+
+	metadata := ExtractMetadata(filepath.Join(root, "main.go"), NoTrace)
+	channels := metadata.FunctionMeta["main"].ChanMeta
+	if channels["done"].Type != "bool" || channels["done'1"].Type != "int" || channels["done'1"].Capacity != 1 {
+		t.Errorf("expected the channels 'done' (bool) and 'done'1' (int, buffered), got %v", channels)
+	}
+
+	// The closure knows the channel by its own name, the one of the declaring block is the actual argument
+	closure := metadata.FunctionMeta["main-func1"]
+	if len(closure.InlineArgs) != 1 || closure.InlineArgs[0].Name != "done" || closure.ChanMeta["done"].Type != "int" {
+		t.Errorf("expected the closure to capture the int channel 'done', got %v", closure.InlineArgs)
+	}
+	spawned := false
+	metadata.FunctionMeta["main"].Automaton.ForEachTransition(func(_, _ int, tr fsa.Transition) {
+		if args, isArgs := tr.Payload.([]FuncArg); isArgs && tr.Label == "main-func1" {
+			spawned = len(args) > 0 && args[0].Name == "done'1"
+		}
+	})
+	if !spawned {
+		t.Errorf("expected the closure to be spawned with the shadowing channel 'done'1'")
+	}
+}
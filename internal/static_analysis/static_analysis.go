@@ -10,28 +10,39 @@
 package static_analysis
 
 import (
+	"go/ast"
 	"go/parser"
 	"go/token"
 	"log"
 	"os"
+	"path/filepath"
+
+	"github.com/its-hmny/Choreia/internal/tracing"
 )
 
 const (
-	NoTrace TraceMode = iota
-	Trace
+	NoTrace       = tracing.Off      // Nothing is traced
+	BasicTrace    = tracing.Basic    // The transitions emitted and the decisions of the transformations are traced
+	ExtendedTrace = tracing.Extended // Every AST node visited is traced as well
 
 	// parser.ParseFIle default flags, we want all every error possible
 	defaultFlags = parser.DeclarationErrors | parser.AllErrors
 )
 
-// Simple type alias to wrap trace option definition
-type TraceMode int
+// Simple type alias to wrap trace option definition, the events are written by the tracing package
+type TraceMode = tracing.Level
+
+// The parsed source of a program: the AST of its files and the FileSet used to parse them
+type Source struct {
+	Files   []*ast.File    // The AST of each file, in the same order as they were parsed
+	FileSet *token.FileSet // The FileSet shared by the files, to retrieve the positions of the nodes
+}
 
 // ----------------------------------------------------------------------------
 // Meta package API
 
 // Parses the file identified by the given path, if the latter is valid, if the user
-// opted in the available trace option handles the traces as well then extracts the metadata
+// opted in one of the trace options enables the tracer as well then extracts the metadata
 // from the AST and returns said metadata to the caller
 func ExtractMetadata(filePath string, traceOpts TraceMode) FileMetadata {
 	// At first checks that the given input path actually exists
@@ -39,19 +50,98 @@ func ExtractMetadata(filePath string, traceOpts TraceMode) FileMetadata {
 		log.Fatal("A path to an existing go source file is needed")
 	}
 
-	parserFlags := defaultFlags
+	return ExtractSourceMetadata(parseFiles([]string{filePath}, traceOpts))
+}
+
+// Parses every file of the package in the given directory, the files are selected as done by the
+// "go build" command (e.g. test files and files excluded by build constraints are ignored) then
+// extracts the metadata from all of them and returns said metadata to the caller, as a single file
+func ExtractPackageMetadata(dirPath string, traceOpts TraceMode) FileMetadata {
+	return ExtractSourceMetadata(parseFiles(packageFiles(dirPath), traceOpts))
+}
 
-	// Enable trace during the ast generation
-	if traceOpts == Trace {
-		parserFlags |= parser.Trace
+// Parses the source at the given path without extracting any metadata, the path can be either a
+// single file or the directory of a package (the files are selected as in ExtractPackageMetadata).
+// Along with ExtractSourceMetadata it allows to inspect (or modify) the AST before the extraction
+func ParseSource(path string, traceOpts TraceMode) Source {
+	fStat, err := os.Stat(path)
+	if err != nil {
+		log.Fatal(err)
 	}
+	if !fStat.IsDir() {
+		return parseFiles([]string{path}, traceOpts)
+	}
+
+	return parseFiles(packageFiles(path), traceOpts)
+}
+
+// Extracts the metadata from the given (already parsed) source, the files are handled as a single package
+func ExtractSourceMetadata(source Source) FileMetadata {
+	return parseAstFiles(source.Files, source.FileSet)
+}
+
+// Returns the paths of the files of the package in the given directory, as selected by "go build" with the
+// current build context (see SetBuildContext)
+func packageFiles(dirPath string) []string {
+	pkg, err := buildContext.ImportDir(dirPath, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	filePaths := make([]string, 0, len(pkg.GoFiles))
+	for _, fileName := range pkg.GoFiles {
+		filePaths = append(filePaths, filepath.Join(dirPath, fileName))
+	}
+	return filePaths
+}
+
+// Parses the files at the given paths and returns their AST, on syntax errors the execution is stopped
+func parseFiles(filePaths []string, traceOpts TraceMode) Source {
+	// Enables the trace of the analysis, a NoTrace option doesn't turn off a tracer enabled elsewhere
+	if traceOpts != NoTrace {
+		tracing.SetLevel(traceOpts)
+	}
+
+	// Parses the files and retrieves their AST
+	source := Source{Files: []*ast.File{}, FileSet: token.NewFileSet()}
+	for _, filePath := range filePaths {
+		f, err := parser.ParseFile(source.FileSet, filePath, nil, defaultFlags)
+		if err != nil {
+			log.Fatal(err)
+		}
+		source.Files = append(source.Files, f)
+	}
+
+	return source
+}
+
+// Searches recursively the given directory for "main" packages (the entrypoints of the programs
+// in a module) and returns their directories sorted. As done by the go tool the "vendor" and
+// "testdata" directories are skipped, as well as the ones whose name starts with "." or "_"
+func FindMainPackages(rootPath string) []string {
+	mainPackages := []string{}
+
+	err := filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return err
+		}
+
+		name := info.Name()
+		isHidden := path != rootPath && (name[0] == '.' || name[0] == '_')
+		if isHidden || name == "vendor" || name == "testdata" {
+			return filepath.SkipDir
+		}
 
-	// Parses the file and retrieves the AST
-	f, err := parser.ParseFile(token.NewFileSet(), filePath, nil, parserFlags)
+		// Directories without Go files (or without a valid package) are ignored
+		if pkg, err := buildContext.ImportDir(path, 0); err == nil && pkg.Name == "main" {
+			mainPackages = append(mainPackages, path)
+		}
+		return nil
+	})
 
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	return parseAstFile(f)
+	return mainPackages
 }
@@ -0,0 +1,53 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the package (multiple files) and repository mode of the metadata extraction
+package static_analysis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Writes the given files (path => content) under the root directory
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		fullPath := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0775); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0664); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRepositoryMode(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"cmd/server/main.go":    "package main\nvar requests = make(chan int)\nfunc main() { go handler(); requests <- 1 }\n",
+		"cmd/server/worker.go":  "package main\nfunc handler() { <-requests }\n",
+		"cmd/client/main.go":    "package main\nfunc main() {}\n",
+		"lib/lib.go":            "package lib\n",
+		"testdata/fake/main.go": "package main\nfunc main() {}\n",
+	})
+
+	mainPackages := FindMainPackages(root)
+	expected := []string{filepath.Join(root, "cmd/client"), filepath.Join(root, "cmd/server")}
+	if len(mainPackages) != len(expected) || mainPackages[0] != expected[0] || mainPackages[1] != expected[1] {
+		t.Fatalf("expected main packages %v, got %v", expected, mainPackages)
+	}
+
+	// The global channel declared in main.go must be available in worker.go as well
+	metadata := ExtractPackageMetadata(expected[1], NoTrace)
+	handler, exist := metadata.FunctionMeta["handler"]
+	if _, isInherited := handler.ChanMeta["requests"]; !exist || !isInherited {
+		t.Errorf("expected 'handler' to inherit the global channel 'requests', got %+v", handler.ChanMeta)
+	}
+	if _, exist := metadata.FunctionMeta["main"]; !exist {
+		t.Error("expected the 'main' function in the package metadata")
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package static_analysis declares the types used to represent metadata extracted from the Go source.
+// The source code is transformed to an Abstract Syntax Tree via go/ast module.
+// Said AST is visited through the Visitor pattern all the metadata available are extractred
+// and agglomerated in a single comprehensive struct.
+//
+package static_analysis
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+)
+
+// The name given to the done channel of a Context (e.g "ctx.Done()")
+const doneChannelTemplate = "%s.Done()"
+
+var (
+	// The stdlib functions that return a channel filled by the runtime, with the type of its messages
+	stdlibChannelFuncs = map[string]string{
+		"time.After": "time.Time",
+		"time.Tick":  "time.Time",
+	}
+	// The stdlib functions that register the channel given as first argument to be filled by the runtime
+	stdlibNotifyFuncs = map[string]bool{
+		"signal.Notify": true,
+	}
+	// The stdlib functions that return a Context (as first value) whose done channel is closed by the runtime
+	stdlibContextFuncs = map[string]bool{
+		"context.Background":   true,
+		"context.TODO":         true,
+		"context.WithCancel":   true,
+		"context.WithDeadline": true,
+		"context.WithTimeout":  true,
+		"context.WithValue":    true,
+	}
+)
+
+// ----------------------------------------------------------------------------
+// Stdlib channel models
+
+// Returns true if the given function of the stdlib (e.g "time.After") is modeled natively by the static
+// analysis, the calls to these functions aren't reported since their effect on the channels is known
+func isStdlibModel(funcName string) bool {
+	_, isChannelFunc := stdlibChannelFuncs[funcName]
+	return isChannelFunc || stdlibNotifyFuncs[funcName] || stdlibContextFuncs[funcName]
+}
+
+// This function tries to parse a call to one of the stdlib functions that return a channel (e.g "time.Tick(d)")
+// or a Context, in order to extract the metadata about the channel assigned to the given variable. The latter
+// is an "environment" channel: nobody in the program sends on it, the runtime does. If the call doesn't
+// return such a channel then the function returns the zero value of the ChanMetadata struct
+func parseStdlibChannel(callExpr *ast.CallExpr, varName string) ChanMetadata {
+	funcName := types.ExprString(callExpr.Fun)
+
+	if msgType, isChannelFunc := stdlibChannelFuncs[funcName]; isChannelFunc {
+		return ChanMetadata{Name: varName, Type: msgType, Async: true, Capacity: 1, Environment: true}
+	}
+	if stdlibContextFuncs[funcName] {
+		return contextDoneChannel(varName)
+	}
+
+	return ChanMetadata{}
+}
+
+// This function parses a call to one of the stdlib functions that register a channel declared in the
+// file to be filled by the runtime (e.g "signal.Notify(sigs, os.Interrupt)"), the channel is marked as an
+// environment one from now on. In case the channel isn't declared in the function scope nothing is done
+func parseStdlibNotify(callExpr *ast.CallExpr, fm *FuncMetadata) {
+	if !stdlibNotifyFuncs[types.ExprString(callExpr.Fun)] || len(callExpr.Args) == 0 {
+		return
+	}
+
+	if chanIdent, isIdent := callExpr.Args[0].(*ast.Ident); isIdent {
+		chanName := fm.channelName(chanIdent.Name)
+		if channelMeta, isChannel := fm.ChanMeta[chanName]; isChannel {
+			channelMeta.Environment = true
+			fm.ChanMeta[chanName] = channelMeta
+		}
+	}
+}
+
+// Returns the metadata of the done channel of the Context with the given name, the channel is closed
+// by the runtime (or by the cancel function) so a receive on it is an input from the environment
+func contextDoneChannel(ctxName string) ChanMetadata {
+	return ChanMetadata{Name: fmt.Sprintf(doneChannelTemplate, ctxName), Type: "struct{}", Environment: true}
+}
+
+// Resolves the channel returned by a call in a receive expression (e.g "<-time.After(d)" or "<-ctx.Done()"),
+// only the calls modeled natively and the done channels of the known Contexts are resolved. The second
+// return value is false if the channel isn't known
+func (fm *FuncMetadata) lookupCallChannel(callExpr *ast.CallExpr) (ChanMetadata, bool) {
+	funcName := types.ExprString(callExpr.Fun)
+
+	if msgType, isChannelFunc := stdlibChannelFuncs[funcName]; isChannelFunc {
+		return ChanMetadata{Name: funcName, Type: msgType, Async: true, Capacity: 1, Environment: true}, true
+	}
+
+	channelMeta, exist := fm.ChanMeta[types.ExprString(callExpr)]
+	return channelMeta, exist && channelMeta.Environment
+}
@@ -0,0 +1,135 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package static_analysis declares the types used to represent metadata extracted from the Go source.
+// The source code is transformed to an Abstract Syntax Tree via go/ast module.
+// Said AST is visited through the Visitor pattern all the metadata available are extractred
+// and agglomerated in a single comprehensive struct.
+//
+package static_analysis
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+)
+
+const (
+	stubExtension   = ".fsa" // The extension of the stub model files
+	stubPlaceholder = "$"    // The prefix of the positional channel arguments in the stubs (e.g "$0")
+)
+
+// ----------------------------------------------------------------------------
+// Stub models
+
+// A stub is an hand-written automaton that models the behaviour of an external function (e.g
+// "http.ListenAndServe") that can't be analyzed since its source isn't available. The stubs are
+// written in the FSA text format (see fsa.MarshalText) and every file describes a single function,
+// the name of the latter is the name of the file without extension (e.g "http.ListenAndServe.fsa").
+//
+// The channel arguments of the function are referenced by position in the Send/Recv labels, for
+// example "$0" is the first argument of the call while the others labels are treated as channels
+// of the global scope. Loads the stub at the given path and returns its name and automaton
+func LoadStub(path string) (string, *fsa.FSA, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	automaton := fsa.New()
+	if err := automaton.UnmarshalText(content); err != nil {
+		return "", nil, fmt.Errorf("stub %s: %s", path, err)
+	}
+	if automaton.FinalStates.Empty() {
+		return "", nil, fmt.Errorf("stub %s: no final state declared", path)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), stubExtension)
+	return name, automaton, nil
+}
+
+// Returns the stub files available at the given path: the path itself if it's
+// a file or all the files with the stub extension (".fsa") if it's a directory
+func FindStubs(path string) ([]string, error) {
+	fStat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fStat.IsDir() {
+		return []string{path}, nil
+	}
+
+	stubs, err := filepath.Glob(filepath.Join(path, "*"+stubExtension))
+	sort.Strings(stubs)
+	return stubs, err
+}
+
+// Registers the given stub automaton as the function with the given name, so that the calls (and
+// spawns) to the latter are inlined as for any other function declared in the file. The constructs
+// of the report that refer to the function are removed since now they're modeled by the stub. The functions
+// declared without a body (see FuncMetadata.External) are replaced as well, they stay tagged as External
+func (fm *FileMetadata) AddStub(name string, automaton *fsa.FSA) {
+	metadata := FuncMetadata{
+		Name:       name,
+		ChanMeta:   make(map[string]ChanMetadata),
+		InlineArgs: make([]FuncArg, 0),
+		Automaton:  automaton.Copy(),
+		IsStub:     true,
+		External:   fm.FunctionMeta[name].External,
+		report:     fm.Unsupported,
+		functions:  fm.FunctionMeta,
+	}
+
+	for chanName, chanMeta := range fm.GlobalChanMeta {
+		metadata.ChanMeta[chanName] = chanMeta
+	}
+
+	// The stub automaton has no payload, the latter is added to the Send/Recv transitions
+	// while the positional placeholders (e.g "$0") become the formal arguments of the function
+	metadata.Automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+		if t.Move != fsa.Send && t.Move != fsa.Recv {
+			return
+		}
+
+		if offset, isPlaceholder := placeholderOffset(t.Label); isPlaceholder {
+			if _, exist := metadata.ChanMeta[t.Label]; !exist {
+				metadata.InlineArgs = append(metadata.InlineArgs, FuncArg{Offset: offset, Name: t.Label, Type: Channel})
+				metadata.ChanMeta[t.Label] = ChanMetadata{Name: t.Label}
+			}
+		}
+
+		chanMeta, exist := metadata.ChanMeta[t.Label]
+		if !exist {
+			chanMeta = ChanMetadata{Name: t.Label}
+		}
+
+		newT := fsa.Transition{Move: t.Move, Label: t.Label, Payload: chanMeta}
+		metadata.Automaton.RemoveTransition(from, to, t)
+		metadata.Automaton.AddTransition(from, to, newT)
+	})
+
+	sort.Slice(metadata.InlineArgs, func(i, j int) bool {
+		return metadata.InlineArgs[i].Offset < metadata.InlineArgs[j].Offset
+	})
+
+	fm.FunctionMeta[name] = metadata
+	fm.Unsupported.Remove(SelectorCall, name)
+	fm.Unsupported.Remove(ExternalFunction, name)
+}
+
+// Returns the offset of the argument referenced by the given positional placeholder (e.g 0 for "$0"),
+// if the label isn't a placeholder then the second return value is false
+func placeholderOffset(label string) (int, bool) {
+	if !strings.HasPrefix(label, stubPlaceholder) {
+		return 0, false
+	}
+
+	offset, err := strconv.Atoi(strings.TrimPrefix(label, stubPlaceholder))
+	return offset, err == nil && offset >= 0
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the loading and registration of the stub models of the external functions
+package static_analysis
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+)
+
+func TestStubModel(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"main.go":                 "package main\nimport \"relay\"\nvar done = make(chan bool)\nfunc main() {\n\tin, out := make(chan int), make(chan int)\n\trelay.Forward(0, in, out)\n}\n",
+		"stubs/relay.Forward.fsa": "final 3\n0 -> 1 Recv \"$1\"\n1 -> 2 Send \"$2\"\n2 -> 3 Send \"done\"\n",
+		"stubs/README.md":         "not a stub",
+	})
+
+	stubFiles, err := FindStubs(filepath.Join(root, "stubs"))
+	if err != nil || len(stubFiles) != 1 {
+		t.Fatalf("expected a single stub file, got %v (%v)", stubFiles, err)
+	}
+	name, automaton, err := LoadStub(stubFiles[0])
+	if err != nil || name != "relay.Forward" {
+		t.Fatalf("expected the stub of 'relay.Forward', got '%s' (%v)", name, err)
+	}
+
+	metadata := ExtractMetadata(filepath.Join(root, "main.go"), NoTrace)
+	if metadata.Unsupported.Len() != 1 {
+		t.Fatalf("expected the call to 'relay.Forward' to be reported, got %s", metadata.Unsupported)
+	}
+	metadata.AddStub(name, automaton)
+	if metadata.Unsupported.Len() != 0 {
+		t.Errorf("expected the call modeled by the stub to be removed from the report, got %s", metadata.Unsupported)
+	}
+
+	// The placeholders become the formal arguments, the other labels refer to the global channels
+	stub := metadata.FunctionMeta[name]
+	expectedArgs := []FuncArg{{Offset: 1, Name: "$1", Type: Channel}, {Offset: 2, Name: "$2", Type: Channel}}
+	if !stub.IsStub || len(stub.InlineArgs) != 2 || stub.InlineArgs[0] != expectedArgs[0] || stub.InlineArgs[1] != expectedArgs[1] {
+		t.Errorf("expected the formal arguments %v, got %v", expectedArgs, stub.InlineArgs)
+	}
+	stub.Automaton.ForEachTransition(func(from, to int, tr fsa.Transition) {
+		if chanMeta, isChanMeta := tr.Payload.(ChanMetadata); !isChanMeta || chanMeta.Name != tr.Label {
+			t.Errorf("expected the channel metadata of '%s' in the payload, got %v", tr.Label, tr.Payload)
+		}
+	})
+	if stub.Automaton.Copy().String() != automaton.String() {
+		t.Errorf("expected the stub automaton to be unchanged, got\n%s", stub.Automaton)
+	}
+}
+
+func TestStubWithoutFinalState(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"broken.fsa": "0 -> 1 Send \"$0\"\n"})
+
+	if _, _, err := LoadStub(filepath.Join(root, "broken.fsa")); err == nil {
+		t.Error("expected an error for a stub without final states")
+	}
+}
+
+func TestExternalFunction(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"main.go": "package main\nfunc checksum(in chan int, n int) int\nfunc main() {\n\tin := make(chan int)\n\tchecksum(in, 1)\n}\n",
+	})
+
+	// The function without a body is tagged and reported, its channel argument is known anyway
+	metadata := ExtractMetadata(filepath.Join(root, "main.go"), NoTrace)
+	external, exist := metadata.FunctionMeta["checksum"]
+	if !exist || !external.External || external.Modeled() || len(external.InlineArgs) != 1 || external.InlineArgs[0].Name != "in" {
+		t.Fatalf("expected 'checksum' to be an external function with the argument 'in', got %+v", external)
+	}
+	expected := UnsupportedConstruct{Kind: ExternalFunction, Name: "checksum", Function: "checksum", Line: 2}
+	if metadata.Unsupported.Len() != 1 || metadata.Unsupported.Constructs[0] != expected {
+		t.Fatalf("expected the external function to be reported, got %s", metadata.Unsupported)
+	}
+
+	// A stub with the same name models it from then on
+	automaton := fsa.New()
+	if err := automaton.UnmarshalText([]byte("final 1\n0 -> 1 Send \"$0\"\n")); err != nil {
+		t.Fatal(err)
+	}
+	metadata.AddStub("checksum", automaton)
+	if stub := metadata.FunctionMeta["checksum"]; !stub.External || !stub.IsStub || !stub.Modeled() {
+		t.Errorf("expected 'checksum' to be modeled by the stub, got %+v", stub)
+	}
+	if metadata.Unsupported.Len() != 0 {
+		t.Errorf("expected the external function modeled by the stub to be removed from the report, got %s", metadata.Unsupported)
+	}
+}
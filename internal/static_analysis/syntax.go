@@ -0,0 +1,183 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package static_analysis declares the types used to represent metadata extracted from the Go source.
+// The source code is transformed to an Abstract Syntax Tree via go/ast module.
+// Said AST is visited through the Visitor pattern all the metadata available are extractred
+// and agglomerated in a single comprehensive struct.
+//
+package static_analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// ----------------------------------------------------------------------------
+// SyntaxTree
+
+// A SyntaxNode is a node of the AST of the source, as seen by the static analysis, stripped down to what's
+// needed to tell which construct has been found where: its type (the name of the go/ast struct, e.g "GoStmt"),
+// its position and its children. The identifiers, literals and operators carry their value as well
+type SyntaxNode struct {
+	Type     string        `json:"type"`            // The type of the node (e.g "SendStmt" for *ast.SendStmt)
+	Value    string        `json:"value,omitempty"` // The name, literal or operator of the node (e.g "worker" or "<-")
+	Position string        `json:"position"`        // The position where the node starts (e.g "main.go:12:2")
+	End      string        `json:"end"`             // The position right after the node ends (e.g "main.go:12:9")
+	Children []*SyntaxNode `json:"children"`        // The nodes directly contained, in the order of the source
+}
+
+// A SyntaxTree is the AST of the files of the source (one root for each file) or, when filtered by node type,
+// of the subtrees rooted in the nodes of the given types (see NewSyntaxTree) in the order of the source
+type SyntaxTree []*SyntaxNode
+
+// Returns the syntax tree of the given source. When some node types are given (e.g "GoStmt" or "*ast.GoStmt")
+// the tree is made only of the outermost nodes of those types, each one with its subtree: the nested matches
+// are found inside the latter. The types are compared ignoring the case, so that "gostmt" is accepted as well
+func NewSyntaxTree(source Source, nodeTypes []string) SyntaxTree {
+	filter := make(map[string]bool)
+	for _, nodeType := range nodeTypes {
+		filter[strings.ToLower(strings.TrimPrefix(nodeType, "*ast."))] = true
+	}
+
+	tree := SyntaxTree{}
+	for _, file := range source.Files {
+		root := syntaxNode(file, source.FileSet)
+		if len(filter) == 0 {
+			tree = append(tree, root)
+		} else {
+			tree = append(tree, root.matches(filter)...)
+		}
+	}
+	return tree
+}
+
+// Builds the syntax tree of the given node, along with the one of each node it contains
+func syntaxNode(root ast.Node, fileSet *token.FileSet) *SyntaxNode {
+	var tree *SyntaxNode
+	stack := []*SyntaxNode{}
+
+	ast.Inspect(root, func(node ast.Node) bool {
+		// The subtree of the node on top of the stack has been visited entirely
+		if node == nil {
+			stack = stack[:len(stack)-1]
+			return false
+		}
+
+		current := &SyntaxNode{
+			Type:     reflect.TypeOf(node).Elem().Name(),
+			Value:    syntaxValue(node),
+			Position: syntaxPosition(fileSet, node.Pos()),
+			End:      syntaxPosition(fileSet, node.End()),
+			Children: []*SyntaxNode{},
+		}
+		if len(stack) == 0 {
+			tree = current
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, current)
+		}
+		stack = append(stack, current)
+		return true
+	})
+
+	return tree
+}
+
+// Returns the outermost nodes of the subtree (the node itself included) whose type is in the given filter
+func (node *SyntaxNode) matches(filter map[string]bool) []*SyntaxNode {
+	if filter[strings.ToLower(node.Type)] {
+		return []*SyntaxNode{node}
+	}
+
+	matches := []*SyntaxNode{}
+	for _, child := range node.Children {
+		matches = append(matches, child.matches(filter)...)
+	}
+	return matches
+}
+
+// Returns the value that identifies the given node among the ones of the same type (e.g the name of an identifier
+// or the operator of an expression), an empty string is returned for the nodes identified by their children only
+func syntaxValue(node ast.Node) string {
+	switch castNode := node.(type) {
+	case *ast.Ident:
+		return castNode.Name
+	case *ast.BasicLit:
+		return castNode.Value
+	case *ast.BinaryExpr:
+		return castNode.Op.String()
+	case *ast.UnaryExpr:
+		return castNode.Op.String()
+	case *ast.AssignStmt:
+		return castNode.Tok.String()
+	case *ast.IncDecStmt:
+		return castNode.Tok.String()
+	case *ast.BranchStmt:
+		return castNode.Tok.String()
+	case *ast.GenDecl:
+		return castNode.Tok.String()
+	case *ast.RangeStmt:
+		return castNode.Tok.String()
+	case *ast.ChanType:
+		switch castNode.Dir {
+		case ast.SEND:
+			return "chan<-"
+		case ast.RECV:
+			return "<-chan"
+		}
+		return "chan"
+	}
+	return ""
+}
+
+// Returns the given position as "file.go:line:column" (the file name only, as in the rest of the analysis)
+func syntaxPosition(fileSet *token.FileSet, pos token.Pos) string {
+	if !pos.IsValid() {
+		return ""
+	}
+	position := fileSet.Position(pos)
+	return fmt.Sprintf("%s:%d:%d", filepath.Base(position.Filename), position.Line, position.Column)
+}
+
+// Writes the syntax tree to the given writer in a textual form, a node for each line (e.g "GoStmt
+// [main.go:12:2-12:17]") indented by its depth. The value of the node follows its type, quoted
+func (tree SyntaxTree) WriteText(w io.Writer) error {
+	var builder strings.Builder
+
+	var write func(node *SyntaxNode, depth int)
+	write = func(node *SyntaxNode, depth int) {
+		value := ""
+		if node.Value != "" {
+			value = fmt.Sprintf(" %q", node.Value)
+		}
+		// The end is in the same file as the start, so the file name isn't repeated
+		end := node.End[strings.Index(node.End, ":")+1:]
+		fmt.Fprintf(&builder, "%s%s%s [%s-%s]\n", strings.Repeat("  ", depth), node.Type, value, node.Position, end)
+
+		for _, child := range node.Children {
+			write(child, depth+1)
+		}
+	}
+
+	for _, root := range tree {
+		write(root, 0)
+	}
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// Writes the syntax tree to the given writer as an (indented) JSON array, one element for each root
+func (tree SyntaxTree) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(tree)
+}
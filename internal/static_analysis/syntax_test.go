@@ -0,0 +1,56 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the syntax tree printed by the ast subcommand
+package static_analysis
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestSyntaxTree(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"main.go": "package main\nfunc worker(ch chan int) { ch <- 1 }\nfunc main() {\n\tch := make(chan int)\n\tgo worker(ch)\n\t<-ch\n}\n",
+	})
+	source := ParseSource(filepath.Join(root, "main.go"), NoTrace)
+
+	// Without filter there's a single root, the file itself
+	if tree := NewSyntaxTree(source, nil); len(tree) != 1 || tree[0].Type != "File" || tree[0].Position != "main.go:1:1" {
+		t.Fatalf("expected the file as the only root, got %+v", tree)
+	}
+
+	// The nodes are matched by type ignoring the case and the "*ast." prefix, in the order of the source
+	var text bytes.Buffer
+	if err := NewSyntaxTree(source, []string{"*ast.GoStmt", "sendstmt"}).WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	expected := `SendStmt [main.go:2:28-2:35]
+  Ident "ch" [main.go:2:28-2:30]
+  BasicLit "1" [main.go:2:34-2:35]
+GoStmt [main.go:5:2-5:15]
+  CallExpr [main.go:5:5-5:15]
+    Ident "worker" [main.go:5:5-5:11]
+    Ident "ch" [main.go:5:12-5:14]
+`
+	if text.String() != expected {
+		t.Errorf("expected the syntax tree\n%s\ngot\n%s", expected, text.String())
+	}
+
+	// The JSON form is an array of the same nodes
+	var encoded bytes.Buffer
+	if err := NewSyntaxTree(source, []string{"UnaryExpr"}).WriteJSON(&encoded); err != nil {
+		t.Fatal(err)
+	}
+	decoded := []SyntaxNode{}
+	if err := json.Unmarshal(encoded.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 1 || decoded[0].Value != "<-" || decoded[0].End != "main.go:6:6" || len(decoded[0].Children) != 1 {
+		t.Errorf("expected the receive from 'ch', got %+v", decoded)
+	}
+}
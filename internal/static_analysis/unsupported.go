@@ -0,0 +1,173 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package static_analysis declares the types used to represent metadata extracted from the Go source.
+// The source code is transformed to an Abstract Syntax Tree via go/ast module.
+// Said AST is visited through the Visitor pattern all the metadata available are extractred
+// and agglomerated in a single comprehensive struct.
+//
+package static_analysis
+
+import (
+	"fmt"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// UnsupportedKind enum
+	SelectorCall     UnsupportedKind = "selector-call"     // Method or package function call (e.g "obj.Method()")
+	Closure          UnsupportedKind = "closure"           // Anonymous function assigned to a variable
+	ExternalChannel  UnsupportedKind = "external-channel"  // Channel not declared in the file (e.g "<-time.After()")
+	Reflection       UnsupportedKind = "reflection"        // Usage of the "reflect" package (e.g "reflect.Select()")
+	UnknownFunction  UnsupportedKind = "unknown-function"  // Call or spawn of a function not declared in the file
+	ExternalFunction UnsupportedKind = "external-function" // Function declared without a body (e.g implemented in assembly)
+	DynamicCall      UnsupportedKind = "dynamic-call"      // Call or spawn of a function value (e.g "handlers[i]()")
+)
+
+// Type alias to abstract the UnsupportedKind enum
+type UnsupportedKind string
+
+// ----------------------------------------------------------------------------
+// UnsupportedReport
+
+// An UnsupportedConstruct is a piece of the source code that the analysis is not able to model,
+// the construct is skipped (or replaced with an eps transition) so the choreography doesn't cover it
+type UnsupportedConstruct struct {
+	Kind     UnsupportedKind // The kind of the construct found
+	Name     string          // The construct as written in the source code (e.g "fmt.Println")
+	Function string          // The function in which the construct has been found
+	Line     int             // The line in the source file (0 if not available)
+}
+
+// An UnsupportedReport collects all the UnsupportedConstruct found during the analysis of a file
+//
+// The report is shared (by pointer) between the FileMetadata and every FuncMetadata of the
+// file so that both the static analysis and the later transformations can add to it
+type UnsupportedReport struct {
+	fileSet    *token.FileSet         // Used to convert the token positions to line numbers
+	Constructs []UnsupportedConstruct // The constructs found, without duplicates
+}
+
+// Creates a new and empty report, the given FileSet can be nil (no line informations)
+func NewUnsupportedReport(fileSet *token.FileSet) *UnsupportedReport {
+	return &UnsupportedReport{fileSet: fileSet, Constructs: []UnsupportedConstruct{}}
+}
+
+// Returns the given position as "file.go:line:column" (the file name only, as for the spawn sites), an
+// empty string is returned when the position isn't valid or the report doesn't have a FileSet
+func (report *UnsupportedReport) position(pos token.Pos) string {
+	if report == nil || report.fileSet == nil || !pos.IsValid() {
+		return ""
+	}
+	position := report.fileSet.Position(pos)
+	return fmt.Sprintf("%s:%d:%d", filepath.Base(position.Filename), position.Line, position.Column)
+}
+
+// Adds a new construct to the report, if an identical one is already present it's ignored.
+// The position can be token.NoPos when not available, calling Add on a nil report is a no-op
+func (report *UnsupportedReport) Add(kind UnsupportedKind, name, function string, pos token.Pos) {
+	if report == nil {
+		return
+	}
+
+	construct := UnsupportedConstruct{Kind: kind, Name: name, Function: function}
+	if report.fileSet != nil && pos.IsValid() {
+		construct.Line = report.fileSet.Position(pos).Line
+	}
+
+	for _, item := range report.Constructs {
+		if item == construct {
+			return
+		}
+	}
+	report.Constructs = append(report.Constructs, construct)
+}
+
+// Removes from the report every construct with the given kind and name (e.g. when a stub
+// models the external function), calling Remove on a nil report is a no-op
+func (report *UnsupportedReport) Remove(kind UnsupportedKind, name string) {
+	if report == nil {
+		return
+	}
+
+	kept := report.Constructs[:0]
+	for _, item := range report.Constructs {
+		if item.Kind != kind || item.Name != name {
+			kept = append(kept, item)
+		}
+	}
+	report.Constructs = kept
+}
+
+// Returns the number of constructs in the report, a nil report is considered empty
+func (report *UnsupportedReport) Len() int {
+	if report == nil {
+		return 0
+	}
+	return len(report.Constructs)
+}
+
+// Informs the WarningObserver (see SetWarningObserver) of each construct in the report, as an UnsupportedNode
+// Warning. Calling Warn on a nil report is a no-op
+func (report *UnsupportedReport) Warn() {
+	for i := 0; i < report.Len(); i++ {
+		construct := report.Constructs[i]
+		if construct.Line > 0 {
+			Warnf(UnsupportedNode, construct.Function, "%s '%s' at line %d isn't modeled", construct.Kind, construct.Name, construct.Line)
+		} else {
+			Warnf(UnsupportedNode, construct.Function, "%s '%s' isn't modeled", construct.Kind, construct.Name)
+		}
+	}
+}
+
+// Converts the report to a human readable summary: the constructs with the same kind,
+// name and function are shown on a single line (with all the lines where they appear)
+func (report *UnsupportedReport) String() string {
+	if report.Len() == 0 {
+		return "No unsupported construct found\n"
+	}
+
+	type groupKey struct {
+		kind           UnsupportedKind
+		name, function string
+	}
+
+	keys := []groupKey{}
+	lines := make(map[groupKey][]string)
+	for _, item := range report.Constructs {
+		key := groupKey{item.Kind, item.Name, item.Function}
+		if _, exist := lines[key]; !exist {
+			keys = append(keys, key)
+			lines[key] = []string{}
+		}
+		if item.Line > 0 {
+			lines[key] = append(lines[key], fmt.Sprint(item.Line))
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].kind != keys[j].kind {
+			return keys[i].kind < keys[j].kind
+		}
+		if keys[i].function != keys[j].function {
+			return keys[i].function < keys[j].function
+		}
+		return keys[i].name < keys[j].name
+	})
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "%d unsupported construct(s) not covered by the choreography:\n", report.Len())
+	for _, key := range keys {
+		fmt.Fprintf(&builder, "  [%s] %s in %s", key.kind, key.name, key.function)
+		if len(lines[key]) > 0 {
+			fmt.Fprintf(&builder, " (line %s)", strings.Join(lines[key], ", "))
+		}
+		builder.WriteString("\n")
+	}
+
+	return builder.String()
+}
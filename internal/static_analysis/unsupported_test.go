@@ -0,0 +1,122 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the report of the constructs that the analysis isn't able to model
+package static_analysis
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const unsupportedSource = `package main
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"example.com/clock"
+)
+
+func main() {
+	ch := make(chan int)
+	go func() { ch <- 1 }()
+	fmt.Println(<-ch)
+	<-time.After(time.Second)
+	<-clock.After(time.Second)
+	reflect.Select(nil)
+	fmt.Println("done")
+}
+`
+
+func TestUnsupportedReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(path, []byte(unsupportedSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The closures are extracted as functions and the stdlib channels (e.g "time.After") are modeled,
+	// the same construct in the same function is reported once, with all the lines where it appears
+	report := ExtractMetadata(path, NoTrace).Unsupported
+	expected := map[UnsupportedConstruct]bool{
+		{Kind: SelectorCall, Name: "fmt.Println", Function: "main", Line: 14}:                 true,
+		{Kind: ExternalChannel, Name: "clock.After(time.Second)", Function: "main", Line: 16}: true,
+		{Kind: Reflection, Name: "reflect.Select", Function: "main", Line: 17}:                true,
+		{Kind: SelectorCall, Name: "fmt.Println", Function: "main", Line: 18}:                 true,
+	}
+	for _, construct := range report.Constructs {
+		if !expected[construct] {
+			t.Errorf("unexpected construct %+v", construct)
+		}
+		delete(expected, construct)
+	}
+	for construct := range expected {
+		t.Errorf("expected construct %+v not reported", construct)
+	}
+
+	if summary := report.String(); !strings.Contains(summary, "[selector-call] fmt.Println in main (line 14, 18)") {
+		t.Errorf("expected the calls to 'fmt.Println' to be grouped, got:\n%s", summary)
+	}
+}
+
+func TestUnsupportedReportAdd(t *testing.T) {
+	// A report without FileSet has no line informations and ignores the duplicates
+	report := NewUnsupportedReport(nil)
+	report.Add(UnknownFunction, "helper", "main", 1)
+	report.Add(UnknownFunction, "helper", "main", 2)
+	if report.Len() != 1 || report.Constructs[0].Line != 0 {
+		t.Errorf("expected a single construct without line, got %+v", report.Constructs)
+	}
+	if summary := report.String(); summary != "1 unsupported construct(s) not covered by the choreography:\n  [unknown-function] helper in main\n" {
+		t.Errorf("unexpected summary:\n%s", summary)
+	}
+
+	// A nil report is empty and adding to it is a no-op
+	var empty *UnsupportedReport
+	empty.Add(Closure, "func() {}", "main", 1)
+	if empty.Len() != 0 || empty.String() != "No unsupported construct found\n" {
+		t.Errorf("expected the nil report to be empty")
+	}
+}
+
+const dynamicCallSource = `package main
+
+func worker() {}
+
+func main() {
+	handlers := []func(){worker}
+	fns := map[int]func(){0: worker}
+	go handlers[0]()
+	for i := range fns {
+		fns[i]()
+	}
+	_ = []byte("done")
+}
+`
+
+func TestUnsupportedDynamicCall(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(path, []byte(dynamicCallSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The spawns and calls of a function value are reported, the conversions (e.g "[]byte(...)") aren't
+	report := ExtractMetadata(path, NoTrace).Unsupported
+	expected := map[UnsupportedConstruct]bool{
+		{Kind: DynamicCall, Name: "handlers[0]", Function: "main", Line: 8}: true,
+		{Kind: DynamicCall, Name: "fns[i]", Function: "main", Line: 10}:     true,
+	}
+	for _, construct := range report.Constructs {
+		if !expected[construct] {
+			t.Errorf("unexpected construct %+v", construct)
+		}
+		delete(expected, construct)
+	}
+	for construct := range expected {
+		t.Errorf("expected construct %+v not reported", construct)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package static_analysis declares the types used to represent metadata extracted from the Go source.
+// The source code is transformed to an Abstract Syntax Tree via go/ast module.
+// Said AST is visited through the Visitor pattern all the metadata available are extractred
+// and agglomerated in a single comprehensive struct.
+//
+package static_analysis
+
+import (
+	"fmt"
+	"go/ast"
+	"sort"
+)
+
+// The name of the element of a variadic channel parameter (e.g "chs[1]"), an argument to be inlined on its own
+const variadicElementTemplate = "%s[%d]"
+
+// ----------------------------------------------------------------------------
+// Variadic channel parameters
+
+// A variadicParam is the variadic channel parameter of a function (e.g "chs ...chan int"), it's shared by the
+// Visitor copies (as the cursor). The number of channels passed isn't known when the function is declared, so
+// each element accessed with a constant index (e.g "chs[1] <- v") becomes an argument to be inlined on its own:
+// the one in the position of the parameter plus the index, bound to the actual argument there by each caller
+type variadicParam struct {
+	arg      FuncArg      // The parameter itself, the Offset is the one of its first element
+	elemType string       // The type of the messages exchanged on the elements (e.g "int")
+	elements map[int]bool // The indexes of the elements accessed in the body
+}
+
+// Returns the identifier of the element of the variadic channel parameter referenced by the given expression (e.g
+// "chs[1]"), the element is added to the channels of the function the first time it's found. False is returned
+// for any other expression, as well as for the elements whose index isn't a constant (e.g "chs[i]")
+func (fm *FuncMetadata) variadicElement(expr ast.Expr) (*ast.Ident, bool) {
+	indexExpr, isIndex := unparen(expr).(*ast.IndexExpr)
+	if !isIndex || fm.variadic == nil {
+		return nil, false
+	}
+	paramIdent, isIdent := indexExpr.X.(*ast.Ident)
+	if !isIdent || paramIdent.Name != fm.variadic.arg.Name || fm.channelName(paramIdent.Name) != paramIdent.Name {
+		return nil, false
+	}
+	index, isConstant := constantInt(indexExpr.Index, fm.constants)
+	if !isConstant || index < 0 {
+		return nil, false
+	}
+
+	name := fmt.Sprintf(variadicElementTemplate, paramIdent.Name, index)
+	if !fm.variadic.elements[index] {
+		fm.variadic.elements[index] = true
+		fm.ChanMeta[name] = ChanMetadata{Name: name, Type: fm.variadic.elemType}
+	}
+	return &ast.Ident{NamePos: paramIdent.NamePos, Name: name}, true
+}
+
+// Adds the elements of the variadic channel parameter accessed in the body (if any) to the arguments to be inlined,
+// sorted by index. It's meant to be called once the body has been visited, since the elements are found there
+func (fm *FuncMetadata) addVariadicArgs() {
+	if fm.variadic == nil {
+		return
+	}
+
+	indexes := []int{}
+	for index := range fm.variadic.elements {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	for _, index := range indexes {
+		name := fmt.Sprintf(variadicElementTemplate, fm.variadic.arg.Name, index)
+		fm.InlineArgs = append(fm.InlineArgs, FuncArg{Offset: fm.variadic.arg.Offset + index, Name: name, Type: Channel})
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package static_analysis declares the types used to represent metadata extracted from the Go source.
+// The source code is transformed to an Abstract Syntax Tree via go/ast module.
+// Said AST is visited through the Visitor pattern all the metadata available are extractred
+// and agglomerated in a single comprehensive struct.
+//
+package static_analysis
+
+import "fmt"
+
+const (
+	// WarningKind enum
+	ArgumentMismatch WarningKind = "argument-mismatch" // The actual arguments of a call or spawn aren't as many as the formal ones
+	UnknownSpawn     WarningKind = "unknown-spawn"     // Spawn of a function not declared in the file, replaced with an eps transition
+	UnsupportedNode  WarningKind = "unsupported-node"  // A construct that the analysis isn't able to model (see UnsupportedReport)
+)
+
+// Type alias to abstract the WarningKind enum
+type WarningKind string
+
+// ----------------------------------------------------------------------------
+// Warning
+
+// A Warning is an issue found while extracting the local views that doesn't stop the analysis: the construct
+// involved is approximated (e.g replaced with an eps transition) and the extraction goes on, so the choreography
+// obtained could be less precise than expected. The WarningObserver (if any) is informed of each one of them
+type Warning struct {
+	Kind     WarningKind // The kind of the issue found
+	Function string      // The function (or Goroutine) in which the issue has been found
+	Message  string      // A readable description of the issue and of how it has been handled
+}
+
+// Returns a readable description of the warning (e.g "[unknown-spawn] spawn of 'run' replaced ... (in main)")
+func (warning Warning) String() string {
+	return fmt.Sprintf("[%s] %s (in %s)", warning.Kind, warning.Message, warning.Function)
+}
+
+// A WarningObserver is invoked with every Warning as soon as it's found, e.g. to print or collect them
+type WarningObserver func(warning Warning)
+
+// The observer currently subscribed, nil when the warnings are discarded
+var warningObserver WarningObserver
+
+// Subscribes the given observer to the warnings found from now on (replacing the previous one), nil unsubscribes it
+func SetWarningObserver(observer WarningObserver) {
+	warningObserver = observer
+}
+
+// Informs the observer (if any) of a Warning of the given kind found in the given function
+func Warnf(kind WarningKind, function, format string, args ...interface{}) {
+	if warningObserver != nil {
+		warningObserver(Warning{kind, function, fmt.Sprintf(format, args...)})
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package tracing implements the structured trace of the analysis: what has been visited in the AST,
+// which transitions have been emitted (and from where in the source) and which decisions have been
+// taken by the transformations. Each event is written as a JSON object on its own line, so that the trace
+// can be filtered with the usual tools (e.g jq). As for the logger the tracer is off by default
+//
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/its-hmny/Choreia/internal/logging"
+)
+
+const (
+	// Trace levels enum, each level includes all the previous ones
+	Off      Level = iota // No event is traced
+	Basic                 // The transitions emitted by the static analysis and the decisions of the transformations
+	Extended              // Every AST node visited by the static analysis as well
+)
+
+const (
+	// EventKind enum
+	Node       EventKind = "node"       // An AST node visited by the static analysis
+	Transition EventKind = "transition" // A transition added to a ScopeAutomata
+	Decision   EventKind = "decision"   // A choice made by a transformation (e.g the inlining of a call)
+)
+
+// Type alias to abstract the trace Level enum
+type Level int
+
+// Type alias to abstract the EventKind enum
+type EventKind string
+
+// An Event of the trace, the position and the scope are omitted when not meaningful
+type Event struct {
+	Seq      int       `json:"seq"`                // The (1-based) order of the event in the trace
+	Kind     EventKind `json:"kind"`               // What the event is about
+	Stage    string    `json:"stage"`              // The step that produced it (e.g "static-analysis", "inlining")
+	Scope    string    `json:"scope,omitempty"`    // The function (or local view) concerned
+	Position string    `json:"position,omitempty"` // The position in the source, as "file.go:line:column"
+	Message  string    `json:"message"`            // The description of the event
+}
+
+var (
+	currentLevel = Off
+	encoder      = newEncoder(os.Stderr)
+	written      = 0
+)
+
+// Converts the Level to the name used in the CLI
+func (l Level) String() string {
+	switch l {
+	case Basic:
+		return "basic"
+	case Extended:
+		return "extended"
+	default:
+		return "none"
+	}
+}
+
+// Returns the Level with the given name (see Level.String), an error is returned for an unknown one
+func ParseLevel(name string) (Level, error) {
+	for _, level := range []Level{Off, Basic, Extended} {
+		if level.String() == name {
+			return level, nil
+		}
+	}
+	return Off, fmt.Errorf("unknown trace level '%s', available are: none, basic, extended", name)
+}
+
+// ----------------------------------------------------------------------------
+// Tracer
+
+// Sets the trace level, the events with a higher level will be discarded
+func SetLevel(level Level) {
+	currentLevel = level
+}
+
+// Returns the current trace level
+func GetLevel() Level {
+	return currentLevel
+}
+
+// Redirects the events to the given writer (default is os.Stderr), the numbering starts again from 1
+func SetOutput(w io.Writer) {
+	encoder, written = newEncoder(w), 0
+}
+
+// Returns the encoder of the events, the labels of the transitions are kept readable (e.g "0 -> 1")
+func newEncoder(w io.Writer) *json.Encoder {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return encoder
+}
+
+// Returns true if an event with the given level will be actually traced, it allows to skip
+// the computation of the event fields (e.g the position of a node) when they would be discarded
+func Enabled(level Level) bool {
+	return level != Off && level <= currentLevel
+}
+
+// Traces the visit of an AST node (Extended level)
+func Nodef(stage, scope, position, format string, args ...interface{}) {
+	emit(Extended, Event{Kind: Node, Stage: stage, Scope: scope, Position: position, Message: fmt.Sprintf(format, args...)})
+}
+
+// Traces a transition added to the automaton of the given scope (Basic level)
+func Transitionf(stage, scope, position, format string, args ...interface{}) {
+	emit(Basic, Event{Kind: Transition, Stage: stage, Scope: scope, Position: position, Message: fmt.Sprintf(format, args...)})
+}
+
+// Traces a decision taken by a transformation (Basic level)
+func Decisionf(stage, scope, format string, args ...interface{}) {
+	emit(Basic, Event{Kind: Decision, Stage: stage, Scope: scope, Message: fmt.Sprintf(format, args...)})
+}
+
+// Writes the given event if its level is enabled, on a write error the tracer is turned off (the
+// analysis goes on anyway since the trace doesn't affect the results)
+func emit(level Level, event Event) {
+	if !Enabled(level) {
+		return
+	}
+
+	written++
+	event.Seq = written
+	if err := encoder.Encode(event); err != nil {
+		logging.Errorf("Unable to write the trace, tracing disabled: %s", err)
+		currentLevel = Off
+	}
+}
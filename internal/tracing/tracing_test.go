@@ -0,0 +1,95 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the structured trace of the analysis
+package tracing_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/tracing"
+)
+
+// Redirects the trace to a buffer with the given level, the tracer is turned off at the end of the test
+func traceTo(t *testing.T, level tracing.Level) *bytes.Buffer {
+	buffer := &bytes.Buffer{}
+	tracing.SetOutput(buffer)
+	tracing.SetLevel(level)
+	t.Cleanup(func() {
+		tracing.SetLevel(tracing.Off)
+		tracing.SetOutput(os.Stderr)
+	})
+	return buffer
+}
+
+// Decodes the events written in the given buffer, one per line
+func decodeEvents(t *testing.T, buffer *bytes.Buffer) []tracing.Event {
+	events := []tracing.Event{}
+	for _, line := range strings.Split(strings.TrimSpace(buffer.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		event := tracing.Event{}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("invalid event %q: %s", line, err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestLevels(t *testing.T) {
+	buffer := traceTo(t, tracing.Basic)
+
+	tracing.Nodef("static-analysis", "main", "main.go:3:1", "%T", buffer)
+	tracing.Decisionf("inlining", "main", "call to '%s' inlined", "worker")
+
+	events := decodeEvents(t, buffer)
+	if len(events) != 1 || events[0].Kind != tracing.Decision || events[0].Seq != 1 {
+		t.Fatalf("expected only the decision to be traced, got %v", events)
+	}
+	if events[0].Message != "call to 'worker' inlined" || events[0].Position != "" {
+		t.Errorf("unexpected decision %v", events[0])
+	}
+
+	if level, err := tracing.ParseLevel("extended"); err != nil || level != tracing.Extended {
+		t.Errorf("expected the extended level, got %v (%v)", level, err)
+	}
+	if _, err := tracing.ParseLevel("verbose"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+}
+
+func TestAnalysisTrace(t *testing.T) {
+	buffer := traceTo(t, tracing.Off)
+	meta.ExtractMetadata("../../example/ClosureCapture.go", meta.ExtendedTrace)
+
+	kinds := map[tracing.EventKind]int{}
+	for i, event := range decodeEvents(t, buffer) {
+		kinds[event.Kind]++
+		if event.Seq != i+1 {
+			t.Fatalf("expected the events to be numbered in order, got %d at %d", event.Seq, i+1)
+		}
+		if event.Kind != tracing.Decision && !strings.HasPrefix(event.Position, "ClosureCapture.go:") {
+			t.Errorf("expected the position in the source of %v", event)
+		}
+	}
+	if kinds[tracing.Node] == 0 || kinds[tracing.Transition] == 0 {
+		t.Errorf("expected both the nodes visited and the transitions emitted, got %v", kinds)
+	}
+
+	// The send on "done" is the second statement of the closure spawned by the worker
+	found := false
+	for _, event := range decodeEvents(t, buffer) {
+		found = found || (event.Kind == tracing.Transition && event.Scope == "worker-func1" && event.Position == "ClosureCapture.go:14:3")
+	}
+	if !found {
+		t.Error("expected the send on 'done' to be traced with its position")
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package transforms declares the types and functions used to transform and work with some type of FSA.
+// Come of the transformation implemented here are standard such as determinization (Subset Construction),
+// minimization but more are specifically related to Choreia (GoroutineFSA extraction & Composition)
+//
+package transforms
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+)
+
+// The label used for the internal (tau) moves in the signatures of the weak bisimulation
+const tauLabel = "τ"
+
+// Returns the abstract protocol view of the given Choreography Automata, made only of the message
+// exchanges between the participants. The spawns (and every other internal transition) are hidden,
+// that is turned into eps (tau) transitions that keep the original label, then the automaton is reduced
+// modulo weak bisimulation so that the states that differ only for internal moves are merged together.
+//
+// Note that a tau transition survives the reduction when it's not inert, for example when a spawn is the
+// alternative of a message exchange: hiding it would change the choices available in the protocol
+func HideInternal(choreography *fsa.FSA) *fsa.FSA {
+	hidden := choreography.Copy()
+
+	hidden.ForEachTransition(func(from, to int, t fsa.Transition) {
+		if interaction, isInteraction := t.Payload.(Interaction); isInteraction && interaction.Channel.Name != "" {
+			return
+		}
+
+		if t.Move != fsa.Eps {
+			hidden.RemoveTransition(from, to, t)
+			hidden.AddTransition(from, to, fsa.Transition{Move: fsa.Eps, Label: t.Label, Payload: t.Payload})
+		}
+	})
+
+	return weakBisimulationQuotient(hidden, interactionKey)
+}
+
+// Returns the key of a visible transition of the Choreography Automata in the signatures of the weak bisimulation,
+// the label alone since the one of an interaction already tells who exchanges what (see Interaction.Label)
+func interactionKey(t fsa.Transition) string {
+	return t.Label
+}
+
+// Returns the quotient of the given automaton with respect to the (coarsest) weak bisimulation, in which
+// the eps transitions are the internal moves. The equivalence classes are computed by partition refinement:
+// starting from the partition given by (weak) termination, the states are split until every state in a class
+// can reach with "tau* a tau*" the same classes (for every visible move a) and with "tau*" the same classes as
+// well. Two visible transitions are the same move when they have the same key (see interactionKey)
+func weakBisimulationQuotient(automaton *fsa.FSA, key func(t fsa.Transition) string) *fsa.FSA {
+	type outgoingT struct {
+		to int
+		t  fsa.Transition
+	}
+
+	states := []int{}
+	automaton.ForEachState(func(id int) { states = append(states, id) })
+
+	internal := make(map[int][]int)
+	visible := make(map[int][]outgoingT)
+	automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+		if t.Move == fsa.Eps {
+			internal[from] = append(internal[from], to)
+		} else {
+			visible[from] = append(visible[from], outgoingT{to, t})
+		}
+	})
+
+	// The states reachable from each state with zero or more internal moves
+	closures := make(map[int][]int, len(states))
+	for _, id := range states {
+		closures[id] = tauClosure(id, internal)
+	}
+
+	// Every state starts in the class given by the (weak) termination
+	classes := make(map[int]int, len(states))
+	for _, id := range states {
+		classes[id] = 0
+		for _, reachable := range closures[id] {
+			if automaton.IsFinalState(reachable) {
+				classes[id] = 1
+			}
+		}
+	}
+
+	for nClasses := -1; ; {
+		// The signature of each state is its current class and the weak moves to the other classes
+		signatures := make(map[string]int)
+		newClasses := make(map[int]int, len(states))
+
+		for _, id := range states {
+			moves := map[string]bool{fmt.Sprintf("class %d", classes[id]): true}
+
+			for _, reachable := range closures[id] {
+				moves[fmt.Sprintf("%s %d", tauLabel, classes[reachable])] = true
+
+				for _, item := range visible[reachable] {
+					for _, target := range closures[item.to] {
+						moves[fmt.Sprintf("%s %d", key(item.t), classes[target])] = true
+					}
+				}
+			}
+
+			sortedMoves := make([]string, 0, len(moves))
+			for move := range moves {
+				sortedMoves = append(sortedMoves, move)
+			}
+			sort.Strings(sortedMoves)
+
+			signature := strings.Join(sortedMoves, "\n")
+			if _, exist := signatures[signature]; !exist {
+				signatures[signature] = len(signatures)
+			}
+			newClasses[id] = signatures[signature]
+		}
+
+		classes = newClasses
+		// The refinement only splits the classes, when their number is stable the partition is as well
+		if len(signatures) == nClasses {
+			break
+		}
+		nClasses = len(signatures)
+	}
+
+	// Each class becomes a state, the internal moves inside the same class are dropped
+	quotient := fsa.New()
+	for _, id := range states {
+		if automaton.IsFinalState(id) {
+			quotient.SetFinalState(classes[id])
+		}
+		for _, item := range visible[id] {
+			quotient.AddTransition(classes[id], classes[item.to], item.t)
+		}
+	}
+
+	automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+		if t.Move == fsa.Eps && classes[from] != classes[to] {
+			quotient.AddTransition(classes[from], classes[to], t)
+		}
+	})
+
+	quotient.SetInitialState(classes[automaton.InitialState()])
+	return renumberStates(quotient)
+}
+
+// Returns the (sorted) states reachable from the given one with zero or more internal moves
+func tauClosure(id int, internal map[int][]int) []int {
+	visited := map[int]bool{id: true}
+	stack := []int{id}
+
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		for _, next := range internal[current] {
+			if !visited[next] {
+				visited[next] = true
+				stack = append(stack, next)
+			}
+		}
+	}
+
+	closure := make([]int, 0, len(visited))
+	for reachable := range visited {
+		closure = append(closure, reachable)
+	}
+	sort.Ints(closure)
+	return closure
+}
@@ -0,0 +1,59 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the abstract protocol view of the Choreography Automata (internal actions hidden)
+package transforms_test
+
+import (
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestHideInternal(t *testing.T) {
+	spawn := transforms.Interaction{From: "main", To: "main/worker@main.go:9#1"}
+	exchange := transforms.Interaction{From: "main/worker@main.go:9#1", To: "main", Channel: meta.ChanMetadata{Name: "results"}}
+
+	// The spawn (either before or after the exchange) is inert, the result is a single exchange
+	choreography := fsa.NewBuilder().
+		State("start").On(fsa.Empty, spawn.Label(transforms.FullLabels), spawn).To("spawned").
+		On(fsa.Empty, exchange.Label(transforms.FullLabels), exchange).To("end").
+		State("start").On(fsa.Empty, exchange.Label(transforms.FullLabels), exchange).To("other").
+		On(fsa.Empty, spawn.Label(transforms.FullLabels), spawn).To("end").
+		Final("end").Build()
+
+	expected := "final 1\n0 -> 1 Empty \"main/worker@main.go:9#1 → main: results\"\n"
+	if text, _ := transforms.HideInternal(choreography).MarshalText(); string(text) != expected {
+		t.Errorf("expected the abstract view\n%s\ngot\n%s", expected, text)
+	}
+}
+
+func TestHideInternalPingPong(t *testing.T) {
+	fileMetadata := meta.ExtractMetadata("../../example/PingPong.go", meta.NoTrace)
+	localViews := transforms.ExtractGoroutineFSA(fileMetadata)
+	for _, lView := range localViews {
+		lView.Automaton = transforms.SubsetConstruction(lView.Automaton)
+	}
+	choreography := transforms.LocalViewsComposition(localViews)
+	abstract := transforms.HideInternal(choreography)
+
+	nExchanges := 0
+	abstract.ForEachTransition(func(_, _ int, tr fsa.Transition) {
+		if interaction := tr.Payload.(transforms.Interaction); tr.Move != fsa.Eps {
+			if interaction.Channel.Name == "" {
+				t.Errorf("the spawn '%s' should be hidden", tr.Label)
+			}
+			nExchanges++
+		}
+	})
+
+	if nExchanges == 0 {
+		t.Error("no message exchange found in the abstract view")
+	}
+	if abstract.GetLastId() > choreography.GetLastId() {
+		t.Error("the abstract view should not have more states than the global view")
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package transforms declares the types and functions used to transform and work with some type of FSA.
+// Come of the transformation implemented here are standard such as determinization (Subset Construction),
+// minimization but more are specifically related to Choreia (GoroutineFSA extraction & Composition)
+//
+package transforms
+
+import (
+	"fmt"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+)
+
+// The key used to look up the peers of a channel operation: the local view that
+// makes the operation, the Move of the latter (Send or Recv) and the channel
+type peerKey struct {
+	view    string
+	move    fsa.MoveKind
+	channel string
+}
+
+// Returns a copy of the given local views where the Send/Recv transitions are annotated with the
+// CSP-style notation, "!ch" for a send and "?ch" for a receive on the channel ch. When the Choreography
+// Automata is given (it can be nil) the latter is used to infer the peer with which every operation
+// synchronizes: if there's only one the annotation is prefixed with its name (e.g "main!ch") so
+// that the local views can be compared directly with the local projections of the session types.
+// The annotated automata are meant to be exported only, the annotated transitions have Move Empty
+func AnnotateLocalViews(localViews map[string]*GoroutineFSA, choreography *fsa.FSA) map[string]*GoroutineFSA {
+	peers := make(map[peerKey]map[string]bool)
+
+	if choreography != nil {
+		choreography.ForEachTransition(func(_, _ int, t fsa.Transition) {
+			// The peer of a message sent in a buffer is known once it's delivered
+			interaction, isInteraction := t.Payload.(Interaction)
+			if !isInteraction || interaction.Channel.Name == "" || interaction.IsBufferedSend() {
+				return
+			}
+
+			addPeer(peers, peerKey{interaction.From, fsa.Send, interaction.Channel.Name}, interaction.To)
+			addPeer(peers, peerKey{interaction.To, fsa.Recv, interaction.Channel.Name}, interaction.From)
+		})
+	}
+
+	annotatedViews := make(map[string]*GoroutineFSA, len(localViews))
+	for name, lView := range localViews {
+		annotatedView := *lView
+		annotatedView.Automaton = lView.Automaton.Copy()
+
+		annotatedView.Automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+			var operator string
+			switch t.Move {
+			case fsa.Send:
+				operator = "!"
+			case fsa.Recv:
+				operator = "?"
+			default:
+				return
+			}
+
+			label := fmt.Sprintf("%s%s", operator, t.Label)
+			if viewPeers := peers[peerKey{name, t.Move, t.Label}]; len(viewPeers) == 1 {
+				for peer := range viewPeers {
+					label = peer + label
+				}
+			}
+
+			newT := fsa.Transition{Move: fsa.Empty, Label: label, Payload: t.Payload}
+			annotatedView.Automaton.RemoveTransition(from, to, t)
+			annotatedView.Automaton.AddTransition(from, to, newT)
+		})
+
+		annotatedViews[name] = &annotatedView
+	}
+
+	return annotatedViews
+}
+
+// Adds the given peer to the ones of the given channel operation
+func addPeer(peers map[peerKey]map[string]bool, key peerKey, peer string) {
+	if peers[key] == nil {
+		peers[key] = make(map[string]bool)
+	}
+	peers[key][peer] = true
+}
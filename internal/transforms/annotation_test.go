@@ -0,0 +1,46 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the CSP-style annotation of the local views
+package transforms_test
+
+import (
+	"testing"
+
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestAnnotateLocalViews(t *testing.T) {
+	fileMetadata := meta.ExtractMetadata("../../example/SimpleExchange.go", meta.NoTrace)
+	localViews := transforms.ExtractGoroutineFSA(fileMetadata)
+	for _, lView := range localViews {
+		lView.Automaton = transforms.SubsetConstruction(lView.Automaton)
+	}
+
+	// Without the Choreography Automata the peers are unknown
+	annotated := transforms.AnnotateLocalViews(localViews, nil)
+	expected := "final 1\n0 -> 1 Empty \"!chanA\"\n"
+	if text := annotated["main/responder@SimpleExchange.go:20#1"].Automaton.String(); text != expected {
+		t.Errorf("expected the annotated local view\n%s\ngot\n%s", expected, text)
+	}
+
+	annotated = transforms.AnnotateLocalViews(localViews, transforms.LocalViewsComposition(localViews))
+	expected = "final 1\n0 -> 1 Empty \"main!chanA\"\n"
+	if text := annotated["main/responder@SimpleExchange.go:20#1"].Automaton.String(); text != expected {
+		t.Errorf("expected the annotated local view\n%s\ngot\n%s", expected, text)
+	}
+	expected = "final 2 5 6\n" +
+		"0 -> 1 Spawn \"main/responder@SimpleExchange.go:20#1\"\n1 -> 2 Spawn \"main/responder@SimpleExchange.go:21#1\"\n" +
+		"2 -> 3 Empty \"main/responder@SimpleExchange.go:20#1?chanA\"\n2 -> 4 Empty \"main/responder@SimpleExchange.go:21#1?chanB\"\n" +
+		"3 -> 5 Empty \"main/responder@SimpleExchange.go:21#1?chanB\"\n4 -> 6 Empty \"main/responder@SimpleExchange.go:20#1?chanA\"\n"
+	if text := annotated["main"].Automaton.String(); text != expected {
+		t.Errorf("expected the annotated local view\n%s\ngot\n%s", expected, text)
+	}
+
+	// The local views given are left untouched
+	if text := localViews["main/responder@SimpleExchange.go:20#1"].Automaton.String(); text != "final 1\n0 -> 1 Send \"chanA\"\n" {
+		t.Errorf("the original local view has been modified\n%s", text)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Benchmarks for the transforms on synthetic automata of increasing size.
+// Run them with "go test ./internal/transforms -run ^$ -bench ."
+package transforms_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+// Generates a synthetic NFA made of "size" blocks, each block is an if-else with a Send on one
+// branch and a Recv on the other one (both on the given channel), the branches are linked
+// with eps-transitions just like the ScopeAutomata extracted from the source code
+func syntheticAutomaton(size int, channel string) *fsa.FSA {
+	automaton := fsa.New()
+	chanMeta := meta.ChanMetadata{Name: channel, Type: "int"}
+
+	currentId := 0
+	for i := 0; i < size; i++ {
+		branchingId := currentId
+
+		ifId := automaton.AddTransition(branchingId, automaton.AddState(), fsa.Transition{Move: fsa.Eps, Label: "if-block-start"})
+		ifId = automaton.AddTransition(ifId, automaton.AddState(), fsa.Transition{Move: fsa.Send, Label: channel, Payload: chanMeta})
+		mergeId := automaton.AddTransition(ifId, automaton.AddState(), fsa.Transition{Move: fsa.Eps, Label: "if-block-end"})
+
+		elseId := automaton.AddTransition(branchingId, automaton.AddState(), fsa.Transition{Move: fsa.Eps, Label: "else-block-start"})
+		elseId = automaton.AddTransition(elseId, automaton.AddState(), fsa.Transition{Move: fsa.Recv, Label: channel, Payload: chanMeta})
+		currentId = automaton.AddTransition(elseId, mergeId, fsa.Transition{Move: fsa.Eps, Label: "else-block-end"})
+	}
+
+	finalId := automaton.AddTransition(currentId, automaton.AddState(), fsa.Transition{Move: fsa.Eps, Label: "func-return"})
+	automaton.SetFinalState(finalId)
+	return automaton
+}
+
+// Generates the metadata of a synthetic file in which "main" spawns "nWorkers" Goroutines.
+// Every function (main included) communicates over the same channel with a synthetic automaton
+func syntheticFile(nWorkers, size int) meta.FileMetadata {
+	chanMeta := meta.ChanMetadata{Name: "ch", Type: "int"}
+	file := meta.FileMetadata{
+		GlobalChanMeta: map[string]meta.ChanMetadata{},
+		FunctionMeta:   map[string]meta.FuncMetadata{},
+	}
+
+	mainAutomaton, currentId := fsa.New(), 0
+	for i := 0; i < nWorkers; i++ {
+		workerName := fmt.Sprintf("worker%d", i)
+		actualArgs := []meta.FuncArg{{Offset: 0, Name: "ch", Type: meta.Channel}}
+		currentId = mainAutomaton.AddTransition(currentId, mainAutomaton.AddState(), fsa.Transition{Move: fsa.Spawn, Label: workerName, Payload: actualArgs})
+
+		file.FunctionMeta[workerName] = meta.FuncMetadata{
+			Name:       workerName,
+			ChanMeta:   map[string]meta.ChanMetadata{"in": {Name: "in"}},
+			InlineArgs: []meta.FuncArg{{Offset: 0, Name: "in", Type: meta.Channel}},
+			Automaton:  syntheticAutomaton(size, "in"),
+		}
+	}
+
+	// The main function communicates as well after spawning the workers
+	mainBody := syntheticAutomaton(size, "ch")
+	offset := mainAutomaton.GetLastId()
+	mainBody.ForEachTransition(func(from, to int, t fsa.Transition) {
+		mainAutomaton.AddTransition(from+offset, to+offset, t)
+	})
+	mainAutomaton.SetFinalState(mainBody.GetLastId() + offset)
+
+	file.FunctionMeta["main"] = meta.FuncMetadata{
+		Name:      "main",
+		ChanMeta:  map[string]meta.ChanMetadata{"ch": chanMeta},
+		Automaton: mainAutomaton,
+	}
+
+	return file
+}
+
+func BenchmarkSubsetConstruction(b *testing.B) {
+	for _, size := range []int{8, 32, 128} {
+		automaton := syntheticAutomaton(size, "ch")
+
+		b.Run(fmt.Sprintf("blocks=%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				transforms.SubsetConstruction(automaton)
+			}
+		})
+	}
+}
+
+func BenchmarkExtractGoroutineFSA(b *testing.B) {
+	for _, nWorkers := range []int{2, 8, 32} {
+		file := syntheticFile(nWorkers, 8)
+
+		b.Run(fmt.Sprintf("workers=%d", nWorkers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				transforms.ExtractGoroutineFSA(file)
+			}
+		})
+	}
+}
+
+func BenchmarkLocalViewsComposition(b *testing.B) {
+	for _, nWorkers := range []int{1, 2, 4} {
+		localViews := transforms.ExtractGoroutineFSA(syntheticFile(nWorkers, 4))
+		for _, lView := range localViews {
+			lView.Automaton = transforms.SubsetConstruction(lView.Automaton)
+		}
+
+		b.Run(fmt.Sprintf("workers=%d", nWorkers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				transforms.LocalViewsComposition(localViews)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package transforms declares the types and functions used to transform and work with some type of FSA.
+// Come of the transformation implemented here are standard such as determinization (Subset Construction),
+// minimization but more are specifically related to Choreia (GoroutineFSA extraction & Composition)
+//
+package transforms
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+)
+
+// ----------------------------------------------------------------------------
+// CallSite
+
+// A CallSite describes a call inlined in a linearized automaton (see linearizeFSA), it's the payload of both the
+// eps transition that enters the expansion of the callee (fsa.StartCallExpansion) and of the ones that leave it
+// (fsa.EndCallExpansion). The inlining erases the boundaries of the calls otherwise, so this is the only way to
+// know in which call (and from where) an operation of the linearized automaton is performed.
+//
+// When the caller is inlined in turn its call sites are carried along, under the expansion of the outer call:
+// the Id gets its prefix, the Depth grows by one and the states are moved as the ones of the caller
+type CallSite struct {
+	Id         string // The expansion of the call, unique in the linearized automaton (e.g "worker#1/encode#2")
+	Caller     string // The function that makes the call
+	Callee     string // The function called
+	Depth      int    // The number of calls that enclose this one (0 for the calls of the function linearized)
+	FirstState int    // The first state of the expansion of the callee
+	LastState  int    // The last state of the expansion of the callee, the ones in between belong to it as well
+}
+
+// Returns a readable description of the call site (e.g "call to encode() from worker()")
+func (site CallSite) String() string {
+	return fmt.Sprintf("call to %s() from %s()", site.Callee, site.Caller)
+}
+
+// Returns true if the given state belongs to the expansion of the callee (or to the ones of its calls)
+func (site CallSite) Contains(state int) bool {
+	return state >= site.FirstState && state <= site.LastState
+}
+
+// Returns the given call site as it is once its caller is inlined with the given expansion: the id gets the prefix
+// of the latter (as the labels do, see expansionLabel) and the states are moved by the given offset
+func (site CallSite) nested(expansion string, offset int) CallSite {
+	site.Id = expansion + fsa.ExpansionSeparator + site.Id
+	site.Depth++
+	site.FirstState += offset
+	site.LastState += offset
+	return site
+}
+
+// ----------------------------------------------------------------------------
+// Call tree
+
+// A CallNode is a call inlined in a linearized automaton along with the calls made by the callee, in turn
+type CallNode struct {
+	CallSite
+	Calls []*CallNode // The calls made by the callee, sorted by Id
+}
+
+// Returns the call sites recorded in the given linearized automaton (see CallSite), sorted by Id
+func CallSites(automaton *fsa.FSA) []CallSite {
+	sites := []CallSite{}
+	automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+		if site, isCallSite := t.Payload.(CallSite); isCallSite && fsa.BaseLabel(t.Label) == fsa.StartCallExpansion {
+			sites = append(sites, site)
+		}
+	})
+	sort.Slice(sites, func(i, j int) bool { return sites[i].Id < sites[j].Id })
+	return sites
+}
+
+// Reconstructs the tree of the calls inlined in the given linearized automaton and returns its roots, that are the
+// calls made by the function linearized (sorted by Id). Each call is a child of the one whose expansion encloses it
+func CallTree(automaton *fsa.FSA) []*CallNode {
+	roots, nodes := []*CallNode{}, make(map[string]*CallNode)
+	// The Id of the enclosing call is a prefix of the Id of the enclosed one, so the parents are sorted first
+	for _, site := range CallSites(automaton) {
+		node := &CallNode{CallSite: site, Calls: []*CallNode{}}
+		nodes[site.Id] = node
+
+		parent, hasParent := (*CallNode)(nil), false
+		if index := strings.LastIndex(site.Id, fsa.ExpansionSeparator); index >= 0 {
+			parent, hasParent = nodes[site.Id[:index]]
+		}
+		if hasParent {
+			parent.Calls = append(parent.Calls, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	return roots
+}
+
+// Returns the calls that enclose the given state of a linearized automaton, from the outermost to the innermost
+// one (e.g to tell that an operation is performed "inside call to encode() from worker()"). Nothing is returned
+// for the states of the function linearized itself
+func EnclosingCalls(automaton *fsa.FSA, state int) []CallSite {
+	enclosing := []CallSite{}
+	for _, site := range CallSites(automaton) {
+		if site.Contains(state) {
+			enclosing = append(enclosing, site)
+		}
+	}
+	sort.SliceStable(enclosing, func(i, j int) bool { return enclosing[i].Depth < enclosing[j].Depth })
+	return enclosing
+}
@@ -0,0 +1,67 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+package transforms_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestCallTree(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.go")
+	source := `package main
+func encode(ch chan int) { ch <- 1 }
+func worker(ch chan int) {
+	encode(ch)
+	<-ch
+}
+func main() {
+	ch := make(chan int)
+	worker(ch)
+	encode(ch)
+}
+`
+	if err := os.WriteFile(path, []byte(source), 0664); err != nil {
+		t.Fatal(err)
+	}
+	automaton := transforms.LinearizeFunctions(meta.ExtractMetadata(path, meta.NoTrace))["main"]
+
+	// The expansions are numbered in the order of the calls, the call made by worker is moved under the expansion of worker itself once the latter is inlined in main
+	roots := transforms.CallTree(automaton)
+	if len(roots) != 2 || roots[0].Id != "encode#2" || roots[1].Id != "worker#1" {
+		t.Fatalf("expected the calls encode#2 and worker#1 from main, got %d calls", len(roots))
+	}
+	if len(roots[0].Calls) != 0 || len(roots[1].Calls) != 1 {
+		t.Fatalf("expected a single call from worker and none from encode, got %v and %v", roots[1].Calls, roots[0].Calls)
+	}
+	nested := roots[1].Calls[0]
+	if nested.Id != "worker#1/encode#1" || nested.Depth != 1 || nested.String() != "call to encode() from worker()" {
+		t.Errorf("unexpected nested call %q (depth %d): %s", nested.Id, nested.Depth, nested)
+	}
+	if roots[1].Depth != 0 || roots[1].String() != "call to worker() from main()" {
+		t.Errorf("unexpected call %q (depth %d): %s", roots[1].Id, roots[1].Depth, roots[1])
+	}
+
+	// Every send is performed by encode, the one reached through worker is enclosed by both calls
+	enclosing := map[string]int{}
+	automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+		if t.Move != fsa.Send {
+			return
+		}
+		ids := ""
+		for _, site := range transforms.EnclosingCalls(automaton, from) {
+			ids += site.Id + " "
+		}
+		enclosing[ids]++
+	})
+	if len(enclosing) != 2 || enclosing["encode#2 "] != 1 || enclosing["worker#1 worker#1/encode#1 "] != 1 {
+		t.Errorf("unexpected calls enclosing the sends %v", enclosing)
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package transforms declares the types and functions used to transform and work with some type of FSA.
+// Come of the transformation implemented here are standard such as determinization (Subset Construction),
+// minimization but more are specifically related to Choreia (GoroutineFSA extraction & Composition)
+//
+package transforms
+
+import (
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	"github.com/its-hmny/Choreia/internal/logging"
+)
+
+// Given the Choreography Automata extracts the sub-automaton of the interactions over the given channel,
+// in order to focus on the protocol of a single channel among many. Every other interaction (spawns
+// included) is replaced with an eps transition (that keeps the original label) so that the states
+// remain connected as in the global view, the states left without any transition are pruned
+func ExtractChannelView(choreography *fsa.FSA, channel string) *fsa.FSA {
+	channelView := choreography.Copy()
+	nInteractions := 0
+
+	channelView.ForEachTransition(func(from, to int, t fsa.Transition) {
+		if interaction, isInteraction := t.Payload.(Interaction); isInteraction && interaction.Channel.Name == channel {
+			nInteractions++
+			return
+		}
+
+		newT := fsa.Transition{Move: fsa.Eps, Label: t.Label, Payload: t.Payload}
+		channelView.RemoveTransition(from, to, t)
+		channelView.AddTransition(from, to, newT)
+	})
+
+	if nInteractions == 0 {
+		logging.Warnf("No interaction found over channel '%s' in the Choreography Automata", channel)
+	}
+
+	channelView.Prune()
+	return channelView
+}
@@ -0,0 +1,43 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the extraction of the view of a single channel from the Choreography Automata
+package transforms_test
+
+import (
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestExtractChannelView(t *testing.T) {
+	fileMetadata := meta.ExtractMetadata("../../example/PingPong.go", meta.NoTrace)
+	localViews := transforms.ExtractGoroutineFSA(fileMetadata)
+	for _, lView := range localViews {
+		lView.Automaton = transforms.SubsetConstruction(lView.Automaton)
+	}
+	choreography := transforms.LocalViewsComposition(localViews)
+
+	channelView := transforms.ExtractChannelView(choreography, "ping")
+	nInteractions := 0
+
+	channelView.ForEachTransition(func(_, _ int, tr fsa.Transition) {
+		if tr.Move == fsa.Eps {
+			return
+		}
+		if interaction := tr.Payload.(transforms.Interaction); interaction.Channel.Name != "ping" {
+			t.Errorf("unexpected interaction over '%s' in the view of 'ping'", interaction.Channel.Name)
+		}
+		nInteractions++
+	})
+
+	if nInteractions == 0 {
+		t.Error("no interaction over 'ping' found in the channel view")
+	}
+	if choreography.GetLastId() != channelView.GetLastId() {
+		t.Errorf("the channel view should keep the states of the global view")
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package transforms declares the types and functions used to transform and work with some type of FSA.
+// Come of the transformation implemented here are standard such as determinization (Subset Construction),
+// minimization but more are specifically related to Choreia (GoroutineFSA extraction & Composition)
+//
+package transforms
+
+import (
+	"sort"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+)
+
+// ----------------------------------------------------------------------------
+// Choreography
+
+// A Participant of the choreography, that is a local view (a Goroutine or one of the external ones)
+type Participant struct {
+	Name     string // The name of the local view (e.g "main/worker@main.go:42#1")
+	Function string // The function from which the Goroutine has been spawned (the name itself for the others)
+	Spawner  string // The participant that spawns it, empty for the ones running from the start (e.g "main")
+}
+
+// A Channel over which at least a message is exchanged in the choreography
+type Channel struct {
+	meta.ChanMetadata
+	Senders   []string // The (sorted) participants that send on the channel
+	Receivers []string // The (sorted) participants that receive from the channel
+}
+
+// A Tree of the spawns, each Goroutine has as children the ones it spawns (sorted by name)
+type Tree struct {
+	Name     string
+	Children []Tree
+}
+
+// A Choreography is the result of the composition: the Choreography Automata along with the local views
+// composed. It answers the common queries about the participants and their interactions, so that the
+// consumers don't have to parse the labels of the raw automaton. The participants and the spawn tree come
+// from the local views while the channels and the interactions from the automaton
+type Choreography struct {
+	Automaton  *fsa.FSA                 // The Choreography Automata (global view)
+	LocalViews map[string]*GoroutineFSA // The local views of the participants
+}
+
+// Creates a new Choreography from the given Choreography Automata and the local views from which it has been composed
+func NewChoreography(automaton *fsa.FSA, localViews map[string]*GoroutineFSA) *Choreography {
+	return &Choreography{Automaton: automaton, LocalViews: localViews}
+}
+
+// Returns the participants of the choreography sorted by name, each one with the participant that spawns it
+func (c *Choreography) Participants() []Participant {
+	spawners := c.spawners()
+	participants := make([]Participant, 0, len(c.LocalViews))
+
+	for name, lView := range c.LocalViews {
+		function := name
+		if lView.FuncMetadata.Name != "" {
+			function = lView.FuncMetadata.Name
+		}
+		participants = append(participants, Participant{Name: name, Function: function, Spawner: spawners[name]})
+	}
+
+	sort.Slice(participants, func(i, j int) bool { return participants[i].Name < participants[j].Name })
+	return participants
+}
+
+// Returns the channels over which the participants interact, sorted by name
+func (c *Choreography) Channels() []Channel {
+	channels := make(map[string]*Channel)
+	senders, receivers := make(map[string]map[string]bool), make(map[string]map[string]bool)
+
+	c.forEachInteraction(func(interaction Interaction) {
+		if interaction.IsSpawn() {
+			return
+		}
+		name := interaction.Channel.Name
+		if _, exist := channels[name]; !exist {
+			channels[name] = &Channel{ChanMetadata: interaction.Channel}
+			senders[name], receivers[name] = make(map[string]bool), make(map[string]bool)
+		}
+		senders[name][interaction.From] = true
+		if !interaction.IsBufferedSend() {
+			receivers[name][interaction.To] = true
+		}
+	})
+
+	sorted := make([]Channel, 0, len(channels))
+	for name, channel := range channels {
+		channel.Senders, channel.Receivers = sortedKeys(senders[name]), sortedKeys(receivers[name])
+		sorted = append(sorted, *channel)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
+
+// Returns the distinct interactions (spawns included) between the two given participants, in both directions.
+// The interactions are in the order they appear in the Choreography Automata (by state id)
+func (c *Choreography) InteractionsBetween(a, b string) []Interaction {
+	interactions, found := []Interaction{}, make(map[Interaction]bool)
+
+	c.forEachInteraction(func(interaction Interaction) {
+		isBetween := (interaction.From == a && interaction.To == b) || (interaction.From == b && interaction.To == a)
+		if isBetween && !found[interaction] {
+			found[interaction] = true
+			interactions = append(interactions, interaction)
+		}
+	})
+
+	return interactions
+}
+
+// Returns the spawn tree rooted at the "main" Goroutine, the participants that aren't spawned by the
+// latter (e.g the network and the handlers it starts, see ExtractNetwork) aren't part of it
+func (c *Choreography) SpawnTree() Tree {
+	children := make(map[string][]string)
+	for spawned, spawner := range c.spawners() {
+		children[spawner] = append(children[spawner], spawned)
+	}
+
+	var build func(name string) Tree
+	build = func(name string) Tree {
+		tree := Tree{Name: name, Children: []Tree{}}
+		sort.Strings(children[name])
+		for _, child := range children[name] {
+			tree.Children = append(tree.Children, build(child))
+		}
+		return tree
+	}
+
+	return build(MainName)
+}
+
+// Returns the participant that spawns each Goroutine, according to the Spawn transitions of the local views
+// (the composition could miss some of them, e.g the spawns made by a Goroutine as soon as it starts)
+func (c *Choreography) spawners() map[string]string {
+	return spawnersOf(c.LocalViews)
+}
+
+// Returns the local view that spawns each one of the given local views, the ones spawned by a
+// participant not included (or never spawned, e.g main) aren't part of the result
+func spawnersOf(localViews map[string]*GoroutineFSA) map[string]string {
+	spawners := make(map[string]string)
+	for name, lView := range localViews {
+		lView.Automaton.ForEachTransition(func(_, _ int, t fsa.Transition) {
+			if _, isParticipant := localViews[t.Label]; isParticipant && t.Move == fsa.Spawn {
+				spawners[t.Label] = name
+			}
+		})
+	}
+	return spawners
+}
+
+// Calls the given function on the payload of each transition of the Choreography Automata that has one
+func (c *Choreography) forEachInteraction(callback func(interaction Interaction)) {
+	c.Automaton.ForEachTransition(func(_, _ int, t fsa.Transition) {
+		if interaction, isInteraction := t.Payload.(Interaction); isInteraction {
+			callback(interaction)
+		}
+	})
+}
+
+// Returns the keys of the given set, sorted
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
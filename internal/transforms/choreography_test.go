@@ -0,0 +1,62 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the queries on the result of the composition
+package transforms_test
+
+import (
+	"reflect"
+	"testing"
+
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestChoreographyQueries(t *testing.T) {
+	const (
+		worker  = "main/worker@ClosureCapture.go:23#1"
+		closure = "main/worker/worker-func1@ClosureCapture.go:12#1"
+		literal = "main/main-func1@ClosureCapture.go:25#1"
+	)
+
+	localViews := transforms.ExtractGoroutineFSA(meta.ExtractMetadata("../../example/ClosureCapture.go", meta.NoTrace))
+	for _, lView := range localViews {
+		lView.Automaton = transforms.SubsetConstruction(lView.Automaton)
+	}
+	result := transforms.NewChoreography(transforms.LocalViewsComposition(localViews), localViews)
+
+	participants := []transforms.Participant{
+		{Name: "main", Function: "main"},
+		{Name: literal, Function: "main-func1", Spawner: "main"},
+		{Name: closure, Function: "worker-func1", Spawner: worker},
+		{Name: worker, Function: "worker", Spawner: "main"},
+	}
+	if found := result.Participants(); !reflect.DeepEqual(found, participants) {
+		t.Errorf("expected the participants\n%v\ngot\n%v", participants, found)
+	}
+
+	channels := result.Channels()
+	if len(channels) != 2 || channels[0].Name != "done" || channels[1].Name != "values" {
+		t.Fatalf("expected the channels 'done' and 'values', got %v", channels)
+	}
+	if senders := channels[1].Senders; !reflect.DeepEqual(senders, []string{literal, closure}) || channels[1].Type != "int" {
+		t.Errorf("expected 'values' to be an int channel with two senders, got %v", channels[1])
+	}
+
+	interactions := result.InteractionsBetween(worker, closure)
+	if len(interactions) != 2 || !interactions[0].IsSpawn() || interactions[1].From != closure || interactions[1].Channel.Name != "done" {
+		t.Errorf("expected the spawn of the closure and a single message on 'done' to the worker, got %v", interactions)
+	}
+	if spawns := result.InteractionsBetween(worker, "main"); len(spawns) != 1 || !spawns[0].IsSpawn() {
+		t.Errorf("expected the spawn of the worker only, got %v", spawns)
+	}
+
+	tree := transforms.Tree{Name: "main", Children: []transforms.Tree{
+		{Name: literal, Children: []transforms.Tree{}},
+		{Name: worker, Children: []transforms.Tree{{Name: closure, Children: []transforms.Tree{}}}},
+	}}
+	if found := result.SpawnTree(); !reflect.DeepEqual(found, tree) {
+		t.Errorf("expected the spawn tree\n%v\ngot\n%v", tree, found)
+	}
+}
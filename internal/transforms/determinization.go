@@ -18,15 +18,22 @@ import (
 // An adapted version of the classic Subset Construction Algorithm for FSA determinization.
 // Allows to transform a Nondeterministic Finite State Automaton (NFA) to an equivalent
 // Deterministic Finite State Automaton (DFA), the latter doesn't present eps-transition
-// or duplicated parallel labels and its easier to be understood by humans
+// or duplicated parallel transitions and its easier to be understood by humans. The alphabet
+// is made of the whole transitions, payload included (see getReachable)
 func SubsetConstruction(NCA *fsa.FSA) *fsa.FSA {
 	DCA := fsa.New() // The deterministic version of the FSA
 
-	// Initialization of the eps-closure of the initial state,
-	initialClosure := newEpsClosure(NCA, set.New(0))
+	// Initialization of the eps-closure of the initial state, the latter is always 0 in the DCA
+	initialClosure := newEpsClosure(NCA, set.New(NCA.InitialState()))
 	//Init the tSet (a set of eps-closure)
 	tSet := list.New(initialClosure)
 
+	// If a final state can be reached from the initial one with only eps-transition
+	// (e.g. a function without communications) then the initial state is final as well
+	if containsFinalState(NCA, initialClosure) {
+		DCA.SetFinalState(0)
+	}
+
 	// Since the range statement uses a "frozen" version of the variable we use this trick
 	// to enable working with "live" data and catch the mutations that are happining inside the loop
 	for nIteration := 0; nIteration < tSet.Size(); nIteration++ {
@@ -48,13 +55,6 @@ func SubsetConstruction(NCA *fsa.FSA) *fsa.FSA {
 				return
 			}
 
-			// Checks if at least one state in the closure is a final state.
-			// Then the new state in the DCA (the current closure) will be final as well
-			containsFinalState := NCA.FinalStates.Any(func(_ int, value interface{}) bool {
-				finalStateId := value.(int)
-				return moveEpsClosure.Contains(finalStateId)
-			})
-
 			// If the eps-closure extracted already exist in tSet (has been already discovered)
 			// then retrieves its twin's id from the map, and use the latter instead of the current id
 			twinIndex, twinId := tSet.Find(func(_ int, item interface{}) bool {
@@ -67,10 +67,11 @@ func SubsetConstruction(NCA *fsa.FSA) *fsa.FSA {
 
 			if twinId == nil { // A twindId doesn't exist so a new state is created
 				tSet.Add(moveEpsClosure)
-				DCA.AddTransition(nIteration, fsa.NewState, t)
-				// The new state as to be added to the final state list as well
-				if containsFinalState {
-					DCA.FinalStates.Add(DCA.GetLastId())
+				newStateId := DCA.AddTransition(nIteration, DCA.AddState(), t)
+				// If at least one state in the closure is a final state then the
+				// new state in the DCA (the current closure) will be final as well
+				if containsFinalState(NCA, moveEpsClosure) {
+					DCA.SetFinalState(newStateId)
 				}
 			} else { // If a twin closure already exist its index is used to link the states with t
 				DCA.AddTransition(nIteration, twinIndex, t)
@@ -78,9 +79,18 @@ func SubsetConstruction(NCA *fsa.FSA) *fsa.FSA {
 		})
 	}
 
+	DCA.Prune()
 	return DCA
 }
 
+// Checks if at least one state in the given closure (or set of states) is a final state of the automaton
+func containsFinalState(automata *fsa.FSA, closure *set.Set) bool {
+	return automata.FinalStates.Any(func(_ int, value interface{}) bool {
+		finalStateId := value.(int)
+		return closure.Contains(finalStateId)
+	})
+}
+
 // Given a set of states extracts recursively the aggregate epsilon closure of said states
 func newEpsClosure(automata *fsa.FSA, states *set.Set) *set.Set {
 	// A set to keep track of all the states already reached
@@ -106,13 +116,15 @@ func newEpsClosure(automata *fsa.FSA, states *set.Set) *set.Set {
 }
 
 // Returns a set of reachable states from a closure (or set of state) "clos" with the given move
-// For move we mean a specific transition with a Move and Label fields
+// For move we mean a specific transition with its Move, Label and Payload (see Transition.Identical),
+// so the transitions that differ only by payload are different symbols of the alphabet and each
+// one is carried with its own data (e.g the ChanMetadata needed by the composition) in the DCA
 func getReachable(automata *fsa.FSA, clos *set.Set, move fsa.Transition) *set.Set {
 	// Init an empty list of states reachable
 	tReachable := set.New()
 
 	automata.ForEachTransition(func(from, to int, t fsa.Transition) {
-		if move.Move == t.Move && move.Label == t.Label && clos.Contains(from) {
+		if move.Identical(t) && clos.Contains(from) {
 			tReachable.Add(to)
 		}
 	})
@@ -0,0 +1,47 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the determinization of the local views
+package transforms_test
+
+import (
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestSubsetConstructionPayloads(t *testing.T) {
+	always := meta.ChanMetadata{Name: "ch", Type: "int"}
+	mayBeNil := meta.ChanMetadata{Name: "ch", Type: "int", MayBeNil: true}
+
+	// Two branches send on the same channel but only in the second one it may be nil,
+	// while the third one is an exact copy of the first (so it's merged with it)
+	nca := fsa.New()
+	for i, payload := range []meta.ChanMetadata{always, mayBeNil, always} {
+		branch := nca.AddTransition(0, nca.AddState(), fsa.Transition{Move: fsa.Eps, Label: "branch"})
+		end := nca.AddTransition(branch, nca.AddState(), fsa.Transition{Move: fsa.Send, Label: "ch", Payload: payload})
+		nca.AddTransition(end, nca.AddState(), fsa.Transition{Move: fsa.Recv, Label: "ack", Payload: meta.ChanMetadata{Name: "ack"}})
+		if i == 1 {
+			nca.SetFinalState(end)
+		}
+	}
+
+	dca := transforms.SubsetConstruction(nca)
+	sends := []meta.ChanMetadata{}
+	dca.ForEachTransition(func(from, to int, t fsa.Transition) {
+		if t.Move == fsa.Send {
+			sends = append(sends, t.Payload.(meta.ChanMetadata))
+		}
+	})
+
+	// Each payload is a symbol of its own, so the send that may be nil keeps its metadata (and its final state)
+	if len(sends) != 2 || sends[0] != always || sends[1] != mayBeNil {
+		t.Fatalf("expected a send for each distinct payload, got %v\n%s", sends, dca)
+	}
+	if dca.FinalStates.Size() != 1 || !dca.IsFinalState(2) {
+		t.Errorf("expected only the state reached by the send that may be nil to be final\n%s", dca)
+	}
+}
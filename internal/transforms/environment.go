@@ -0,0 +1,68 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package transforms declares the types and functions used to transform and work with some type of FSA.
+// Come of the transformation implemented here are standard such as determinization (Subset Construction),
+// minimization but more are specifically related to Choreia (GoroutineFSA extraction & Composition)
+//
+package transforms
+
+import (
+	"sort"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+)
+
+// The name of the local view that models the runtime (timers, signals, Contexts, ...)
+const EnvironmentName = "environment"
+
+// Given the local views extracted from a file returns an additional local view that represents the
+// environment: the channels filled by the runtime (see ChanMetadata.Environment) have no sender in
+// the program, so the receives on them would never be matched during the composition. The environment
+// is a single (final) state that can send at any time on each one of these channels, in this way a
+// receive on a timer or a signal is an input that may or may not happen. Returns nil if the local views
+// don't receive from any environment channel
+func ExtractEnvironment(localViews map[string]*GoroutineFSA) *GoroutineFSA {
+	channels := make(map[string]meta.ChanMetadata)
+
+	for _, lView := range localViews {
+		lView.Automaton.ForEachTransition(func(_, _ int, t fsa.Transition) {
+			if channelMeta, isChanMeta := t.Payload.(meta.ChanMetadata); isChanMeta && t.Move == fsa.Recv && channelMeta.Environment {
+				channels[t.Label] = channelMeta
+			}
+		})
+	}
+
+	if len(channels) == 0 {
+		return nil
+	}
+
+	environment := GoroutineFSA{
+		Name: EnvironmentName,
+		FuncMetadata: meta.FuncMetadata{
+			Name:       EnvironmentName,
+			ChanMeta:   channels,
+			InlineArgs: []meta.FuncArg{},
+			Automaton:  fsa.New(),
+		},
+	}
+
+	// The transitions are added sorted by channel, since the order of the parallel ones
+	// determines the order in which the couples are visited during the composition
+	chanNames := make([]string, 0, len(channels))
+	for chanName := range channels {
+		chanNames = append(chanNames, chanName)
+	}
+	sort.Strings(chanNames)
+
+	for _, chanName := range chanNames {
+		channelMeta := channels[chanName]
+		channelMeta.MayBeNil = false
+		environment.Automaton.AddTransition(0, 0, fsa.Transition{Move: fsa.Send, Label: chanName, Payload: channelMeta})
+	}
+	environment.Automaton.SetFinalState(0)
+
+	return &environment
+}
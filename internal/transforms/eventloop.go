@@ -0,0 +1,111 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package transforms declares the types and functions used to transform and work with some type of FSA.
+// Come of the transformation implemented here are standard such as determinization (Subset Construction),
+// minimization but more are specifically related to Choreia (GoroutineFSA extraction & Composition)
+//
+package transforms
+
+import (
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+)
+
+// An edge of the automaton, used to keep track of the transitions that make up a branch
+type loopEdge struct {
+	from, to int
+	t        fsa.Transition
+}
+
+// Normalizes the branches of the event loops (see fsa.EventLoopCaseStart) in the given linearized automaton.
+// Once the calls are inlined most of the branches are a single operation followed by a chain of eps-transitions
+// back to the loop head, such a branch is replaced by a self-loop on the loop head itself. In this way after
+// the determinization every iteration of the select returns to the same state, instead of a different one for
+// each branch taken. The branches without any operation (e.g a default clause that just logs) are removed,
+// they're an iteration in which nothing observable happens. The other branches are left untouched
+func foldEventLoops(automaton *fsa.FSA) {
+	loopHeads := []int{}
+
+	incoming := make(map[int]int)
+	outgoing := make(map[int][]loopEdge)
+	automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+		incoming[to]++
+		outgoing[from] = append(outgoing[from], loopEdge{from, to, t})
+	})
+
+	automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+		if t.Move != fsa.Eps || !fsa.BaseLabel(t.Label).Matches(fsa.EventLoopCaseStart) {
+			return
+		}
+
+		branch := []loopEdge{{from, to, t}}
+		var operation *loopEdge
+
+		// Follows the branch until it returns to the loop head, every state in it must be a simple link
+		for current := to; current != from; {
+			if incoming[current] != 1 || len(outgoing[current]) != 1 {
+				return
+			}
+
+			next := outgoing[current][0]
+			if next.t.Move != fsa.Eps {
+				if operation != nil {
+					return // More than one operation in the branch, it can't be folded
+				}
+				operation = &next
+			}
+			branch = append(branch, next)
+			current = next.to
+		}
+
+		for _, edge := range branch {
+			automaton.RemoveTransition(edge.from, edge.to, edge.t)
+		}
+		if operation != nil {
+			automaton.AddTransition(from, from, operation.t)
+		}
+		loopHeads = append(loopHeads, from)
+	})
+
+	for _, head := range loopHeads {
+		contractEntry(automaton, head)
+	}
+}
+
+// Contracts the chain of eps-transitions that leads to the given loop head (e.g the calls to "make" replaced
+// before the loop), otherwise the first iteration of the loop would start from a different deterministic state
+// than the following ones. A state is merged with the loop head if its only outgoing transition is an eps one
+// toward the latter, its incoming transitions are redirected to the loop head (that may become the initial state)
+func contractEntry(automaton *fsa.FSA, head int) {
+	for {
+		outgoing := make(map[int][]loopEdge)
+		automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+			outgoing[from] = append(outgoing[from], loopEdge{from, to, t})
+		})
+
+		entry := fsa.Unknown
+		for from, edges := range outgoing {
+			link := edges[0]
+			isEventLoopCase := fsa.BaseLabel(link.t.Label).Matches(fsa.EventLoopCaseStart, fsa.EventLoopCaseEnd, fsa.EventLoopCaseExit)
+			if len(edges) == 1 && from != head && link.to == head && link.t.Move == fsa.Eps && !isEventLoopCase && !automaton.IsFinalState(from) {
+				entry = from
+				break
+			}
+		}
+		if entry == fsa.Unknown {
+			return
+		}
+
+		automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+			if to == entry {
+				automaton.RemoveTransition(from, to, t)
+				automaton.AddTransition(from, head, t)
+			}
+		})
+		automaton.RemoveTransition(entry, head, outgoing[entry][0].t)
+		if automaton.InitialState() == entry {
+			automaton.SetInitialState(head)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the normalization of the event loops ("for { select { ... } }")
+package transforms_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+const eventLoopSource = `package main
+
+func main() {
+	requests, done := make(chan int), make(chan bool)
+	for {
+		select {
+		case <-requests:
+			println("request received")
+		case <-done:
+			return
+		default:
+			println("nothing to do")
+		}
+	}
+}
+`
+
+func TestEventLoopFolding(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.go")
+	if err := os.WriteFile(path, []byte(eventLoopSource), 0664); err != nil {
+		t.Fatal(err)
+	}
+
+	fileMetadata := meta.ExtractMetadata(path, meta.NoTrace)
+	mainView := transforms.ExtractGoroutineFSA(fileMetadata)[transforms.MainName]
+	deterministic := transforms.SubsetConstruction(mainView.Automaton)
+
+	// Every request returns to the loop head while the done channel leaves the loop
+	expected := "final 0 1\n0 -> 0 Recv \"requests\"\n0 -> 1 Recv \"done\"\n"
+	if text, _ := deterministic.MarshalText(); string(text) != expected {
+		t.Errorf("expected the event loop automaton\n%s\ngot\n%s", expected, text)
+	}
+}
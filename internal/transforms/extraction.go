@@ -10,18 +10,32 @@ package transforms
 
 import (
 	"fmt"
+	"go/token"
+	"go/types"
 	"log"
+	"sort"
+	"strings"
 
 	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	"github.com/its-hmny/Choreia/internal/logging"
 	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/tracing"
 )
 
 var (
-	nGoroutineStarted = 0
-	inlinedCache      = make(map[string]*fsa.FSA)
+	spawnInstances = make(map[string]int) // The Goroutines named so far, by spawn path (and site)
+	inlinedCache   = make(map[string]*fsa.FSA)
+	instanceCache  = make(map[string]*fsa.FSA) // The spawned automata after the substitution, by substitutionKey
 )
 
-const nameTemplate = "%s (%d)"
+const (
+	// The name of the local view of the "main" Goroutine, the root of the spawn tree
+	MainName = "main"
+
+	siteTemplate     = "%s@%s" // The spawn path followed by the spawn site (e.g "main/worker@main.go:42")
+	instanceTemplate = "%s#%d" // The n-th Goroutine spawned with the same path and site (e.g "main/worker@main.go:42#1")
+	scopedTemplate   = "%s@%s" // A channel local to an inlined function followed by the call site (e.g "ch@helper#1")
+)
 
 // -------------------------------------------------------------------------------------------
 // GoroutineFSA
@@ -33,16 +47,48 @@ const nameTemplate = "%s (%d)"
 type GoroutineFSA struct {
 	Name string // An identifier for the Automata
 	meta.FuncMetadata
+	Spawn SpawnInfo // How the Goroutine has been spawned, empty for the ones running from the start (e.g main)
+}
+
+// How a Goroutine has been spawned: by which participant, from where in the source and with which channels.
+// It's kept along with the local view so that the spawn tree can be inspected without composing the latters
+type SpawnInfo struct {
+	Spawner  string           // The participant that spawns the Goroutine
+	Site     string           // The spawn site (e.g "main.go:42"), empty when unknown (e.g the spawns of the stubs)
+	Bindings []ChannelBinding // The channels passed to the Goroutine, in the order of the arguments
+}
+
+// A channel passed to a spawned Goroutine: the argument of the spawned function and the channel of the spawner
+type ChannelBinding struct {
+	Parameter string // The formal argument (e.g "jobs" in "func worker(jobs chan int)"), "$<offset>" if unknown
+	Channel   string // The actual argument (e.g "tasks" in "go worker(tasks)")
+}
+
+// Given the metadata associated to a file returns the linearized automaton of every function
+// declared in it (the function calls are inlined as subgraphs). This is the same intermediate
+// result used by ExtractGoroutineFSA and it's mainly exposed for debugging and inspection.
+func LinearizeFunctions(file meta.FileMetadata) map[string]*fsa.FSA {
+	cache := make(map[string]*fsa.FSA)
+
+	for _, function := range file.FunctionMeta {
+		// Cache miss: We must linearize the current automaton (recursive calls fill the cache as well)
+		if cache[function.Name] == nil {
+			linearizeFSA(function, file, cache)
+		}
+	}
+
+	return cache
 }
 
 // Given the metadata associated to a file it linearizes the automata found in it
 // (function calls inlining). Once done that extracts recursively the FSA associated to
 // each Goroutine spawned during the program execution, the latter are returned as output
 func ExtractGoroutineFSA(file meta.FileMetadata) map[string]*GoroutineFSA {
-	// Cleanup function that resets the global variable nGoroutineStarted & inlinedCache
+	// Cleanup function that resets the global variables spawnInstances, inlinedCache & instanceCache
 	defer func() {
-		nGoroutineStarted = 0
+		spawnInstances = make(map[string]int)
 		inlinedCache = make(map[string]*fsa.FSA)
+		instanceCache = make(map[string]*fsa.FSA)
 	}()
 
 	for _, function := range file.FunctionMeta {
@@ -56,20 +102,76 @@ func ExtractGoroutineFSA(file meta.FileMetadata) map[string]*GoroutineFSA {
 		linearizeFSA(function, file, inlinedCache) // Cache miss: We must linearize the current automaton
 	}
 
-	name := fmt.Sprintf(nameTemplate, "main", nGoroutineStarted)
+	// The constructs that can't be modeled are known once every function has been linearized (and the stubs, if
+	// any, have replaced the external functions they model) so the observer is informed only of the ones left
+	file.Unsupported.Warn()
+
 	meta, existMeta := file.FunctionMeta["main"]
-	mainGrFSA := GoroutineFSA{name, meta}
+	mainGrFSA := GoroutineFSA{Name: MainName, FuncMetadata: meta}
 
 	automaton, existLin := inlinedCache["main"]
-	mainGrFSA.Automaton = automaton.Copy()
-
 	if !existMeta || !existLin {
 		log.Fatal("Automaton or meta associated to 'main' function not found")
 	}
+	mainGrFSA.Automaton, mainGrFSA.ChanMeta = entrypointAutomaton(file, meta, automaton)
 
 	// Extracts all the GoroutineFSA starting from the "main" function
 	// which is the entrypoint for the Go program
-	return extractSpawnTree(mainGrFSA, file)
+	localViews := extractSpawnTree(mainGrFSA, file)
+
+	// The network handlers (if recognized) are started by the network participant instead
+	if network := ExtractNetwork(file); network != nil {
+		for grName, grFSA := range extractSpawnTree(*network, file) {
+			localViews[grName] = grFSA
+		}
+	}
+
+	// The runtime takes part in the choreography as well when some channel is filled by it
+	if environment := ExtractEnvironment(localViews); environment != nil {
+		localViews[environment.Name] = environment
+	}
+
+	return localViews
+}
+
+// Returns the automaton of the main Goroutine, in which the init functions of the package run one after the other
+// (see meta.PackageMetadata) before the linearized main, along with the channels in its scope. The Goroutines
+// spawned by the init functions are spawned by main then, while the channels local to an init function are
+// renamed after the latter (e.g "ch@init#1") when they collide with the ones of main or of a previous init
+func entrypointAutomaton(file meta.FileMetadata, main meta.FuncMetadata, automaton *fsa.FSA) (*fsa.FSA, map[string]meta.ChanMetadata) {
+	if len(file.Package.InitFlow) == 0 {
+		return automaton.Copy(), main.ChanMeta
+	}
+
+	scope := make(map[string]meta.ChanMetadata, len(main.ChanMeta))
+	for name, channel := range main.ChanMeta {
+		scope[name] = channel
+	}
+
+	var entrypoint *fsa.FSA
+	for _, name := range file.Package.InitFlow {
+		initMeta, initLin := file.FunctionMeta[name], inlinedCache[name]
+		if initLin == nil {
+			continue
+		}
+		scoped := localSubstitution(initMeta, nil, initLin, file, scope, automaton, name)
+		for channelName, channel := range initMeta.ChanMeta {
+			if _, isKnown := scope[channelName]; !isKnown {
+				scope[channelName] = channel
+			}
+		}
+
+		tracing.Decisionf("extraction", MainName, "init function '%s' runs before main", name)
+		if entrypoint == nil {
+			entrypoint = scoped
+		} else {
+			entrypoint = fsa.Concat(entrypoint, scoped)
+		}
+	}
+	if entrypoint == nil {
+		return automaton.Copy(), main.ChanMeta
+	}
+	return fsa.Concat(entrypoint, automaton), scope
 }
 
 // Given an entrypoint (a Goroutine FSA) extracts recursively all the Goroutine spawned during
@@ -85,36 +187,66 @@ func extractSpawnTree(gr GoroutineFSA, file meta.FileMetadata) map[string]*Gorou
 			return
 		}
 
-		nGoroutineStarted++
-		spawnedName := fmt.Sprintf(nameTemplate, t.Label, nGoroutineStarted)
 		// Retrieves a reference to the metadata of the spawned function
 		spawnedMeta, existMeta := file.FunctionMeta[t.Label]
-		spawnedGrFSA := GoroutineFSA{spawnedName, spawnedMeta}
 		// Retrieves a reference to the linearized automaton of the spawned function
 		spawnedLin, existLin := inlinedCache[t.Label]
 
-		// IF the automaton doesn't exist we override the transition with an eps one
-		if !existMeta || !existLin {
-			newT := fsa.Transition{Move: fsa.Eps, Label: "unknown-function-spawn"}
+		// IF the automaton doesn't exist we override the transition with an eps one, unless channels are
+		// passed to the function: in that case it's spawned as an opaque participant (see opaqueParticipant).
+		// The same goes for the functions declared without a body, whose automaton is empty
+		if !existMeta || !existLin || !spawnedMeta.Modeled() {
+			actualArgs, site := spawnSite(t.Payload)
+			if opaque := opaqueParticipant(t.Label, actualArgs, gr.ChanMeta); opaque != nil {
+				opaque.Name = goroutineName(gr.Name, t.Label, site)
+				opaque.Spawn = SpawnInfo{gr.Name, site, channelBindings(nil, actualArgs)}
+				logging.Debugf("Spawn of external function '%s' modeled as the opaque participant '%s'", t.Label, opaque.Name)
+				tracing.Decisionf("extraction", gr.Name, "spawn of external function '%s' modeled as the opaque participant '%s'", t.Label, opaque.Name)
+				gr.Automaton.RemoveTransition(from, to, t)
+				gr.Automaton.AddTransition(from, to, fsa.Transition{Move: fsa.Spawn, Label: opaque.Name})
+				spawnedGoroutines[opaque.Name] = opaque
+				return
+			}
+
+			logging.Debugf("Spawn of unknown function '%s' replaced with an eps transition", t.Label)
+			tracing.Decisionf("extraction", gr.Name, "spawn of unknown function '%s' replaced with an eps transition", t.Label)
+			meta.Warnf(meta.UnknownSpawn, gr.Name, "spawn of unknown function '%s' replaced with an eps transition", t.Label)
+			newT := fsa.UnknownFunctionSpawn.Transition()
 			gr.Automaton.RemoveTransition(from, to, t)
 			gr.Automaton.AddTransition(from, to, newT)
 			return
 		}
 
 		// Updates the Spawn transition with the full name/id of the spawned Goroutine
+		actualArgs, site := spawnSite(t.Payload)
+		spawnedName := goroutineName(gr.Name, t.Label, site)
+		tracing.Decisionf("extraction", gr.Name, "spawn of '%s' (%d -> %d) named '%s'", t.Label, from, to, spawnedName)
+		spawnedGrFSA := GoroutineFSA{Name: spawnedName, FuncMetadata: spawnedMeta}
 		newT := fsa.Transition{Move: fsa.Spawn, Label: spawnedName}
 		gr.Automaton.RemoveTransition(from, to, t)
 		gr.Automaton.AddTransition(from, to, newT)
 
 		// Get a reference to the list of actual arguments and formal ones
 		formalArgs := spawnedMeta.InlineArgs
-		actualArgs, _ := t.Payload.([]meta.FuncArg)
+		if spawnedMeta.IsStub {
+			formalArgs, actualArgs = bindStubArgs(t.Label, formalArgs, actualArgs)
+		}
+		formalArgs, actualArgs = bindArguments(gr.Name, spawnedMeta, formalArgs, actualArgs)
+		spawnedGrFSA.Spawn = SpawnInfo{gr.Name, site, channelBindings(formalArgs, actualArgs)}
 		// Get a reference to the channels metadata in the caller scope
 		channelInfo := gr.ChanMeta
 
-		// Finds and replace transition with subject a formal parameter and replaces
-		// them with the same transition but with a reference to the actual argument
-		spawnedGrFSA.Automaton = argumentSubstitution(formalArgs, actualArgs, spawnedLin, channelInfo)
+		// Finds and replace transition with subject a formal parameter and replaces them with the same transition
+		// but with a reference to the actual argument. The Goroutines spawned from the same function with the same
+		// channels (e.g the workers of a pool) get the same automaton, so the substitution is performed only once
+		key := substitutionKey(t.Label, actualArgs, channelInfo)
+		if substituted, isCached := instanceCache[key]; isCached {
+			tracing.Decisionf("extraction", gr.Name, "substitution of '%s' reused for '%s'", t.Label, spawnedName)
+			spawnedGrFSA.Automaton = substituted.Copy()
+		} else {
+			spawnedGrFSA.Automaton = argumentSubstitution(formalArgs, actualArgs, spawnedLin, channelInfo)
+			instanceCache[key] = spawnedGrFSA.Automaton.Copy()
+		}
 
 		// Extracts recursively the spawn subtree of our spawned and updates the entries in our agglomerate
 		for grName, grFSA := range extractSpawnTree(spawnedGrFSA, file) {
@@ -122,11 +254,73 @@ func extractSpawnTree(gr GoroutineFSA, file meta.FileMetadata) map[string]*Gorou
 		}
 	})
 
-	// Adds the current GoroutineFSA to the list before returning
+	// Adds the current GoroutineFSA (without the leftovers of the replaced transitions) to the list
+	gr.Automaton.Prune()
 	spawnedGoroutines[gr.Name] = &gr
 	return spawnedGoroutines
 }
 
+// Returns the name of a Goroutine spawned by the given one from the given function and site: the spawn path
+// (the one of the spawner followed by the function) and the site, numbered among the Goroutines with the same
+// ones (e.g "main/worker@main.go:42#2" is the second worker spawned by main from the line 42, maybe in a loop).
+// Since two Goroutines differ only when they're spawned in a different way the name doesn't depend on the order
+// in which the others are found, the site is omitted when unknown (e.g the spawns of the stubs)
+func goroutineName(spawner, function, site string) string {
+	path := fmt.Sprintf("%s/%s", spawnPath(spawner), function)
+	if site != "" {
+		path = fmt.Sprintf(siteTemplate, path, site)
+	}
+
+	spawnInstances[path]++
+	return fmt.Sprintf(instanceTemplate, path, spawnInstances[path])
+}
+
+// Returns the spawn path of the Goroutine with the given name (e.g "main/worker" for "main/worker@main.go:42#2"),
+// that is the name of the functions from which it has been spawned. The name of main is its own spawn path
+func spawnPath(name string) string {
+	if hash := strings.LastIndex(name, "#"); hash != -1 {
+		name = name[:hash]
+	}
+	if at := strings.LastIndex(name, "@"); at != -1 {
+		name = name[:at]
+	}
+	return name
+}
+
+// Splits the actual arguments saved in the payload of a Spawn transition from its site (see static_analysis.Site)
+func spawnSite(payload interface{}) ([]meta.FuncArg, string) {
+	payloadArgs, _ := payload.([]meta.FuncArg)
+	actualArgs, site := []meta.FuncArg{}, ""
+
+	for _, arg := range payloadArgs {
+		if arg.Type == meta.Site {
+			site = arg.Name
+		} else {
+			actualArgs = append(actualArgs, arg)
+		}
+	}
+	return actualArgs, site
+}
+
+// Returns the channels passed to a spawned Goroutine, each actual argument paired with the formal one in the same
+// position. When the latter isn't known (e.g an external function) the parameter is named after the position
+func channelBindings(formal, actual []meta.FuncArg) []ChannelBinding {
+	bindings := []ChannelBinding{}
+	for _, actualArg := range actual {
+		if actualArg.Type != meta.Channel {
+			continue
+		}
+		binding := ChannelBinding{Parameter: fmt.Sprintf("$%d", actualArg.Offset), Channel: actualArg.Name}
+		for _, formalArg := range formal {
+			if formalArg.Offset == actualArg.Offset && formalArg.Type == meta.Channel {
+				binding.Parameter = formalArg.Name
+			}
+		}
+		bindings = append(bindings, binding)
+	}
+	return bindings
+}
+
 // Given the metadata associated to a function linearize the automaton associated to the latter
 // by expanding recursively each function call present: The inlining is performed by copying the
 // automaton of the "called" function as subgraph to the automaton of the "caller".
@@ -134,6 +328,8 @@ func extractSpawnTree(gr GoroutineFSA, file meta.FileMetadata) map[string]*Gorou
 func linearizeFSA(function meta.FuncMetadata, file meta.FileMetadata, cache map[string]*fsa.FSA) {
 	// Makes an independent copy that can be freely modified
 	copyAutomaton := function.Automaton.Copy()
+	// The number of calls inlined so far, every expansion is labeled with it (see inlineAutomata)
+	expansions := 0
 
 	copyAutomaton.ForEachTransition(func(from, to int, t fsa.Transition) {
 		if t.Move != fsa.Call { // Ignores all non "Call" type transition
@@ -144,9 +340,27 @@ func linearizeFSA(function meta.FuncMetadata, file meta.FileMetadata, cache map[
 		// append() or make() are not "injected" and no metadata is available
 		calledMeta, exist := file.FunctionMeta[t.Label]
 
-		// If the function doesn't exist the transition is overwritten with an eps transition
+		// If the function doesn't exist the transition is overwritten with an eps transition,
+		// only the calls to user defined functions are reported (builtins and conversions aren't) since
+		// the selector ones (e.g "fmt.Println") have been already reported during the static analysis
 		if !exist {
-			newT := fsa.Transition{Move: fsa.Eps, Label: "unknown-function-call"}
+			logging.Debugf("Call to unknown function '%s' replaced with an eps transition", t.Label)
+			tracing.Decisionf("inlining", function.Name, "call to unknown function '%s' replaced with an eps transition", t.Label)
+			if types.Universe.Lookup(t.Label) == nil && !strings.Contains(t.Label, ".") {
+				file.Unsupported.Add(meta.UnknownFunction, t.Label, function.Name, token.NoPos)
+			}
+			newT := fsa.UnknownFunctionCall.Transition()
+			copyAutomaton.RemoveTransition(from, to, t)
+			copyAutomaton.AddTransition(from, to, newT)
+			return
+		}
+
+		// The functions declared without a body (e.g in assembly) have already been reported during the static
+		// analysis, their empty automaton isn't inlined so that the call isn't mistaken for the one of a no-op
+		if !calledMeta.Modeled() {
+			logging.Debugf("Call to external function '%s' replaced with an eps transition", t.Label)
+			tracing.Decisionf("inlining", function.Name, "call to external function '%s' replaced with an eps transition", t.Label)
+			newT := fsa.ExternalFunctionCall.Transition()
 			copyAutomaton.RemoveTransition(from, to, t)
 			copyAutomaton.AddTransition(from, to, newT)
 			return
@@ -155,6 +369,7 @@ func linearizeFSA(function meta.FuncMetadata, file meta.FileMetadata, cache map[
 		// Cache miss: we linearize the called function and we add it to the cache
 		// The update of the cache is done by the recursive call
 		if cache[t.Label] == nil {
+			logging.Debugf("Linearizing function '%s' called from '%s'", t.Label, function.Name)
 			linearizeFSA(calledMeta, file, cache)
 		}
 
@@ -162,93 +377,381 @@ func linearizeFSA(function meta.FuncMetadata, file meta.FileMetadata, cache map[
 		calledFuncAutomaton := cache[t.Label]
 		// Get a reference to the list of actual arguments and formal ones
 		formalArgs := calledMeta.InlineArgs
-		actualArgs, _ := t.Payload.([]meta.FuncArg)
+		actualArgs, boundResults := splitResults(t.Payload)
+		if calledMeta.IsStub {
+			formalArgs, actualArgs = bindStubArgs(t.Label, formalArgs, actualArgs)
+		}
+		formalArgs, actualArgs = bindArguments(function.Name, calledMeta, formalArgs, actualArgs)
 		// Get a reference to the channels metadata in the caller scope
 		channelInfo := function.ChanMeta
+		// The call site of this expansion, e.g "helper#2" for the second call inlined in the caller
+		expansions++
+		callSite := fmt.Sprintf(instanceTemplate, t.Label, expansions)
 
+		// The channels declared by the callee that have the same name of one of the caller are renamed
+		// first, otherwise the substitutions below couldn't tell them apart from the ones passed
+		replaced := localSubstitution(calledMeta, boundResults, calledFuncAutomaton, file, channelInfo, copyAutomaton, callSite)
 		// Finds and replace transition with subject a formal parameter and replaces
 		// them with the same transition but with a reference to the actual argument
-		replaced := argumentSubstitution(formalArgs, actualArgs, calledFuncAutomaton, channelInfo)
+		replaced = argumentSubstitution(formalArgs, actualArgs, replaced, channelInfo)
+		// Then the channels returned by the callee take the name of the caller variables they're assigned to
+		replaced = resultSubstitution(calledMeta, boundResults, replaced, file, channelInfo)
 
 		// Expands as a subgraph the called function FSA in place of the transition t
 		// this process is really similar to function inlining a technique used in compilers
 		// to avoid function call overhead and the allocation of an Activation Record
-		inlineAutomata(copyAutomaton, from, to, t, replaced)
+		tracing.Decisionf("inlining", function.Name, "call to '%s' (%d -> %d) inlined", t.Label, from, to)
+		inlineAutomata(copyAutomaton, function.Name, from, to, t, replaced, expansions)
 	})
 
-	// Adds the fully linearized automaton to the cache
+	// Adds the fully linearized (and normalized) automaton to the cache
+	foldEventLoops(copyAutomaton)
+	copyAutomaton.Prune()
 	cache[function.Name] = copyAutomaton
 }
 
-// Implements the algorithm to replace formal arguments with actual ones.
-// Overrides the transition label but also the payload so that future reference to the channel
-// will always be correct and successfull
-func argumentSubstitution(formal, actual []meta.FuncArg, automaton *fsa.FSA, chanMeta map[string]meta.ChanMetadata) *fsa.FSA {
-	// Makes a copy that can be freely modified
-	automatonCopy := automaton.Copy()
+// Pairs the actual arguments of a call (or spawn) made by the given function with the formal channel arguments of
+// the callee, the pairs are returned in the same position (as expected by argumentSubstitution). Each actual argument
+// is bound to the formal one in the same position, the ones passed to the variadic channel parameter but never used
+// by the callee are dropped. The function arguments are left out, even though the caller passes them, since
+// only the channels are substituted. When some formal channel is left unbound (e.g the caller passes a field or
+// the result of a call, not tracked) and as many actual channels are left as well these are bound by position,
+// in order, otherwise the channels left keep their name. In both cases a Warning is reported (see meta.Warnf)
+func bindArguments(caller string, callee meta.FuncMetadata, formal, actual []meta.FuncArg) ([]meta.FuncArg, []meta.FuncArg) {
+	boundFormal, boundActual := []meta.FuncArg{}, []meta.FuncArg{}
+	unboundFormal, unboundActual := []meta.FuncArg{}, []meta.FuncArg{}
+	isBound := make(map[int]bool)
+
+	for _, formalArg := range formal {
+		if formalArg.Type != meta.Channel {
+			continue
+		}
+		isFound := false
+		for _, actualArg := range actual {
+			if actualArg.Offset == formalArg.Offset && actualArg.Type == formalArg.Type && !isFound {
+				boundFormal, boundActual = append(boundFormal, formalArg), append(boundActual, actualArg)
+				isBound[actualArg.Offset], isFound = true, true
+			}
+		}
+		if !isFound {
+			unboundFormal = append(unboundFormal, formalArg)
+		}
+	}
+
+	isVariadic := callee.Variadic.Name != ""
+	for _, actualArg := range actual {
+		if !isBound[actualArg.Offset] && !(isVariadic && actualArg.Offset >= callee.Variadic.Offset) {
+			unboundActual = append(unboundActual, actualArg)
+		}
+	}
+
+	if len(unboundFormal) > 0 && len(unboundFormal) == len(unboundActual) {
+		for i, formalArg := range unboundFormal {
+			actualArg := unboundActual[i]
+			meta.Warnf(meta.ArgumentMismatch, caller, "no channel is passed to '%s' of '%s' in its position, the channel '%s' is bound by position", formalArg.Name, callee.Name, actualArg.Name)
+			actualArg.Offset = formalArg.Offset
+			boundFormal, boundActual = append(boundFormal, formalArg), append(boundActual, actualArg)
+		}
+		return boundFormal, boundActual
+	}
 
-	// Bails out at the first discrepancy blocking the execution
-	if len(formal) != len(actual) {
-		log.Fatalf("Couldn't expand arguments: formal %d but actual %d\n", len(formal), len(actual))
+	for _, formalArg := range unboundFormal {
+		meta.Warnf(meta.ArgumentMismatch, caller, "no channel is passed to '%s' of '%s', the channel keeps its name", formalArg.Name, callee.Name)
 	}
+	for _, actualArg := range unboundActual {
+		meta.Warnf(meta.ArgumentMismatch, caller, "the channel '%s' is passed to '%s' but not to a channel argument, it's ignored", actualArg.Name, callee.Name)
+	}
+	return boundFormal, boundActual
+}
 
-	// Expands the actual arguments with the positional ones
+// Implements the algorithm to replace formal arguments with actual ones.
+// Overrides the transition label but also the payload so that future reference to the channel
+// will always be correct and successfull.
+//
+// Every pair of formal and actual arguments is applied at once on the original transitions, so a channel
+// just replaced isn't replaced again by another pair of the same call (e.g "f(b, a)" with "func f(a, b chan int)")
+func argumentSubstitution(formal, actual []meta.FuncArg, automaton *fsa.FSA, chanMeta map[string]meta.ChanMetadata) *fsa.FSA {
+	// Matches each formal channel argument with the actual one in the same position
+	replacements := make(map[string]meta.FuncArg)
 	for _, actualArg := range actual {
 		for _, funcArg := range formal {
 			// Tries to find a match beetwen the actual argument and the positional argument
 			if funcArg.Offset != actualArg.Offset || funcArg.Type != actualArg.Type {
 				continue
 			}
+			tracing.Decisionf("substitution", "", "formal %s '%s' replaced with the actual '%s'", funcArg.Type, funcArg.Name, actualArg.Name)
+			// ? Handle funcArg.Type == Function as well
+			if funcArg.Type == meta.Channel {
+				replacements[funcArg.Name] = actualArg
+			}
+		}
+	}
+
+	// All the transitions that reference a "formal" argument are replaced with transitions to the "actual" one,
+	// the replaced ones keep their position so the visits are the same of the original automaton
+	substituted := automaton.Relabel(func(t fsa.Transition) fsa.Transition {
+		if actualArg, isFormal := replacements[t.Label]; isFormal && (t.Move == fsa.Recv || t.Move == fsa.Send) {
+			// A nil assignment to the formal argument still applies to the actual one
+			actualMeta := chanMeta[actualArg.Name]
+			if formalMeta, isChanMeta := t.Payload.(meta.ChanMetadata); isChanMeta && formalMeta.MayBeNil {
+				actualMeta.MayBeNil = true
+			}
+			return fsa.Transition{Move: t.Move, Label: actualArg.Name, Payload: actualMeta}
+		}
+
+		// The formal argument could be passed in turn to another function (or closure), in
+		// this case the actual arguments saved in the payload of the Call/Spawn are replaced
+		if nestedArgs, isArgList := t.Payload.([]meta.FuncArg); isArgList && (t.Move == fsa.Call || t.Move == fsa.Spawn) {
+			return fsa.Transition{Move: t.Move, Label: t.Label, Payload: substituteArgs(nestedArgs, replacements)}
+		}
+		return t
+	})
+
+	substituted.Prune()
+	return substituted
+}
+
+// Returns the key of the automaton obtained substituting the given actual arguments in the one of the given function:
+// the arguments (by position) along with the metadata of the channels passed, since they end up in the payloads
+func substitutionKey(function string, actual []meta.FuncArg, chanMeta map[string]meta.ChanMetadata) string {
+	args := make([]string, 0, len(actual))
+	for _, arg := range actual {
+		args = append(args, fmt.Sprintf("%d:%s:%s:%s", arg.Offset, arg.Type, arg.Name, fsa.PayloadKey(chanMeta[arg.Name])))
+	}
+	return fmt.Sprintf("%s(%s)", function, strings.Join(args, ", "))
+}
+
+// Splits the actual arguments saved in the payload of a Call transition from the variables to which the
+// results of the call are assigned (the Result arguments, see static_analysis.FuncMetadata.bindResults)
+func splitResults(payload interface{}) ([]meta.FuncArg, []meta.FuncArg) {
+	payloadArgs, _ := payload.([]meta.FuncArg)
+	var actualArgs, boundResults []meta.FuncArg
+
+	for _, arg := range payloadArgs {
+		if arg.Type == meta.Result {
+			boundResults = append(boundResults, arg)
+		} else {
+			actualArgs = append(actualArgs, arg)
+		}
+	}
+
+	return actualArgs, boundResults
+}
+
+// Renames the channels returned by the callee (e.g "jobs" in "return jobs, results") in its inlined automaton
+// as the caller variables they're assigned to, so that the operations made on the channel by the callee and
+// by the Goroutines it spawns refer to the same channel used by the caller. Each call binds the channels
+// created by the callee to different variables, so the channels of two calls are kept distinct. The global
+// channels and the arguments returned as they are keep their own name since other participants use the latter.
+// The metadata of the caller variables (known only by type until now) are updated with the ones of the channels
+func resultSubstitution(callee meta.FuncMetadata, bound []meta.FuncArg, automaton *fsa.FSA, file meta.FileMetadata, callerScope map[string]meta.ChanMetadata) *fsa.FSA {
+	formal, actual := []meta.FuncArg{}, []meta.FuncArg{}
+	chanMeta := make(map[string]meta.ChanMetadata)
+
+	for _, boundArg := range bound {
+		for _, returnArg := range callee.ReturnArgs {
+			_, isGlobal := file.GlobalChanMeta[returnArg.Name]
+			if returnArg.Offset != boundArg.Offset || isGlobal || isInlineArg(callee, returnArg.Name) {
+				continue
+			}
+
+			// The metadata are the ones of the channel created by the callee, under the caller name
+			channelMeta := callee.ChanMeta[returnArg.Name]
+			channelMeta.Name = boundArg.Name
+			chanMeta[boundArg.Name], callerScope[boundArg.Name] = channelMeta, channelMeta
+
+			formal = append(formal, meta.FuncArg{Offset: returnArg.Offset, Name: returnArg.Name, Type: meta.Channel})
+			actual = append(actual, meta.FuncArg{Offset: boundArg.Offset, Name: boundArg.Name, Type: meta.Channel})
+		}
+	}
+
+	if len(formal) == 0 {
+		return automaton
+	}
+	return argumentSubstitution(formal, actual, automaton, chanMeta)
+}
+
+// The channels declared by an inlined function (not passed to it nor returned to the caller) are still its own, but
+// once inlined they'd be mistaken for the channels of the caller with the same name (e.g both declare a "ch") and
+// the substitutions by label would bind the transitions of one to the other. So the local channels of the callee
+// that collide with a channel of the caller (declared by the latter or by the calls inlined before) are renamed
+// with the given call site (see scopedTemplate) and added to the caller scope, with the metadata they had.
+// The automaton of the callee isn't modified, the one without collisions is returned as it is
+func localSubstitution(callee meta.FuncMetadata, bound []meta.FuncArg, automaton *fsa.FSA, file meta.FileMetadata, callerScope map[string]meta.ChanMetadata, caller *fsa.FSA, callSite string) *fsa.FSA {
+	// The channels returned to the caller are renamed after the variables they're assigned to (see resultSubstitution)
+	isBound := make(map[string]bool)
+	for _, boundArg := range bound {
+		for _, returnArg := range callee.ReturnArgs {
+			if returnArg.Offset == boundArg.Offset {
+				isBound[returnArg.Name] = true
+			}
+		}
+	}
+
+	localMeta, callerChannels := channelsOf(automaton), channelsOf(caller)
+	names := make([]string, 0, len(localMeta))
+	for name := range localMeta {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	scoped := make(map[string]meta.ChanMetadata)
+	for _, name := range names {
+		_, isGlobal := file.GlobalChanMeta[name]
+		_, isDeclaredByCaller := callerScope[name]
+		_, isUsedByCaller := callerChannels[name]
+		if isGlobal || isBound[name] || isInlineArg(callee, name) || localMeta[name].Environment {
+			continue
+		} else if !isDeclaredByCaller && !isUsedByCaller {
+			continue
+		}
+
+		channelMeta, isDeclared := callee.ChanMeta[name]
+		if !isDeclared {
+			channelMeta = localMeta[name]
+		}
+		channelMeta.Name = fmt.Sprintf(scopedTemplate, name, callSite)
+		scoped[name], callerScope[channelMeta.Name] = channelMeta, channelMeta
+		tracing.Decisionf("substitution", callee.Name, "local channel '%s' renamed '%s' since the caller has one with the same name", name, channelMeta.Name)
+	}
+
+	if len(scoped) == 0 {
+		return automaton
+	}
+	renamed := make(map[string]meta.FuncArg, len(scoped))
+	for name, channelMeta := range scoped {
+		renamed[name] = meta.FuncArg{Name: channelMeta.Name, Type: meta.Channel}
+	}
+	return automaton.Relabel(func(t fsa.Transition) fsa.Transition {
+		if channelMeta, isScoped := scoped[t.Label]; isScoped && (t.Move == fsa.Recv || t.Move == fsa.Send) {
+			// A nil assignment to the local channel still applies
+			if oldMeta, isChanMeta := t.Payload.(meta.ChanMetadata); isChanMeta && oldMeta.MayBeNil {
+				channelMeta.MayBeNil = true
+			}
+			return fsa.Transition{Move: t.Move, Label: channelMeta.Name, Payload: channelMeta}
+		}
+		// The local channel could be passed in turn to another function (or Goroutine)
+		if nestedArgs, isArgList := t.Payload.([]meta.FuncArg); isArgList && (t.Move == fsa.Call || t.Move == fsa.Spawn) {
+			return fsa.Transition{Move: t.Move, Label: t.Label, Payload: substituteArgs(nestedArgs, renamed)}
+		}
+		return t
+	})
+}
 
-			// If such match is found then all the transition in the automataCopy that references
-			// that "formal" argument are replaced with transition to the "actual" argument
-			automatonCopy.ForEachTransition(func(from, to int, t fsa.Transition) {
-				if funcArg.Type == meta.Channel && t.Label == funcArg.Name && (t.Move == fsa.Recv || t.Move == fsa.Send) {
-					// Creates a new transition that will overwrite the old one
-					// (the one that references the formal argument)
-					newT := fsa.Transition{
-						Move:    t.Move,
-						Label:   actualArg.Name,
-						Payload: chanMeta[actualArg.Name],
-					}
-
-					// Replace the transitions
-					automatonCopy.RemoveTransition(from, to, t)
-					automatonCopy.AddTransition(from, to, newT)
+// Returns the channels referenced by the given automaton: the ones used by its Send/Recv transitions (with the
+// metadata of their payload) and the ones passed to the Call/Spawn transitions (with no metadata)
+func channelsOf(automaton *fsa.FSA) map[string]meta.ChanMetadata {
+	channels := make(map[string]meta.ChanMetadata)
+	automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+		if t.Move == fsa.Recv || t.Move == fsa.Send {
+			channelMeta, _ := t.Payload.(meta.ChanMetadata)
+			channels[t.Label] = channelMeta
+		} else if nestedArgs, isArgList := t.Payload.([]meta.FuncArg); isArgList && (t.Move == fsa.Call || t.Move == fsa.Spawn) {
+			for _, arg := range nestedArgs {
+				if _, isKnown := channels[arg.Name]; arg.Type == meta.Channel && !isKnown {
+					channels[arg.Name] = meta.ChanMetadata{Name: arg.Name}
 				}
+			}
+		}
+	})
+	return channels
+}
+
+// Returns true if the given name is one of the arguments of the given function
+func isInlineArg(function meta.FuncMetadata, name string) bool {
+	for _, arg := range function.InlineArgs {
+		if arg.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// A stub references only the channel arguments it's interested in (by position) while the call
+// passes every channel available, so only the arguments that have a match on the other side are kept.
+// The placeholders without an actual channel are left as they are and a warning is emitted
+func bindStubArgs(stubName string, formal, actual []meta.FuncArg) ([]meta.FuncArg, []meta.FuncArg) {
+	boundFormal, boundActual := []meta.FuncArg{}, []meta.FuncArg{}
+
+	for _, formalArg := range formal {
+		isBound := false
+		for _, actualArg := range actual {
+			if formalArg.Offset == actualArg.Offset && formalArg.Type == actualArg.Type {
+				boundFormal, boundActual = append(boundFormal, formalArg), append(boundActual, actualArg)
+				isBound = true
+			}
+		}
 
-				// ? Handle funcArg.Type == Function as well
-			})
+		if !isBound {
+			logging.Warnf("Stub '%s' references argument %s but no channel is passed in its place", stubName, formalArg.Name)
+			meta.Warnf(meta.ArgumentMismatch, stubName, "the stub references argument %s but no channel is passed in its place", formalArg.Name)
 		}
 	}
 
-	return automatonCopy
+	return boundFormal, boundActual
+}
+
+// Returns a copy of the given argument list where the channels identified by the formal arguments
+// are replaced by the actual ones, the list is copied since it's shared with the original automaton
+func substituteArgs(args []meta.FuncArg, replacements map[string]meta.FuncArg) []meta.FuncArg {
+	replaced := make([]meta.FuncArg, len(args))
+	for i, arg := range args {
+		replaced[i] = arg
+		if actual, isFormal := replacements[arg.Name]; isFormal && arg.Type == meta.Channel {
+			replaced[i].Name = actual.Name
+		}
+	}
+	return replaced
 }
 
 // This function expands a graph in place of an transition. Since in our case every
 // Automata/Graph has only one initial and final state then we simply copy the other graph
-// state by state and transition by transition and then we link the copy to the "from" and "to" states
-func inlineAutomata(root *fsa.FSA, from, to int, t fsa.Transition, other *fsa.FSA) {
+// state by state and transition by transition and then we link the copy to the "from" and "to" states.
+//
+// The same function can be inlined more than once (or in more than one caller), so the eps transitions of
+// the copy are labeled with the given expansion number as well (see expansionLabel): their labels stay unique
+// in the whole linearized automaton and they still tell from which call (and statement) they come from.
+// The eps transitions that enter and leave the copy carry the CallSite of the call made by the given caller
+func inlineAutomata(root *fsa.FSA, caller string, from, to int, t fsa.Transition, other *fsa.FSA, expansion int) {
 	// First of all remove the old call transition
 	root.RemoveTransition(from, to, t)
 
-	// Count the number of states, in order to extract an offset
-	offset := 0
-	root.ForEachState(func(_ int) { offset++ })
+	// The eps transitions of the expansion are labeled with the latter, then the states of the "other" FSA are moved
+	// after the biggest id of the "root" one, the ids could be non contiguous (e.g. after Prune) so the number of
+	// states could collide with an existing id. The call sites of the callee are moved under this expansion as well
+	offset, callId := root.GetLastId()+1, fmt.Sprintf(instanceTemplate, t.Label, expansion)
+	expanded := other.Relabel(func(inlined fsa.Transition) fsa.Transition {
+		if inlined.Move == fsa.Eps {
+			payload := inlined.Payload
+			if site, isCallSite := payload.(CallSite); isCallSite {
+				payload = site.nested(callId, offset)
+			}
+			inlined = fsa.Transition{Move: fsa.Eps, Label: expansionLabel(t.Label, expansion, inlined.Label), Payload: payload}
+		}
+		return inlined
+	}).Renumber(offset)
+	site := CallSite{Id: callId, Caller: caller, Callee: t.Label, FirstState: offset, LastState: expanded.GetLastId()}
 
-	// Copies the "other" graph state, applying the offset to each id
-	other.ForEachTransition(func(from, to int, t fsa.Transition) {
-		root.AddTransition(from+offset, to+offset, t)
+	// Copies the "other" graph state
+	expanded.ForEachTransition(func(from, to int, inlined fsa.Transition) {
+		root.AddTransition(from, to, inlined)
 	})
 
 	// Links the initial state of "other" FSA with the "root" FSA via eps transition
-	tExpansionStart := fsa.Transition{Move: fsa.Eps, Label: "start-call-expansion"}
-	root.AddTransition(from, offset, tExpansionStart)
+	tExpansionStart := fsa.Transition{Move: fsa.Eps, Label: expansionLabel(t.Label, expansion, string(fsa.StartCallExpansion)), Payload: site}
+	root.AddTransition(from, expanded.InitialState(), tExpansionStart)
 
 	// Links every final/accepting states of the other FSA with the "root" via eps transition
-	for _, item := range other.FinalStates.Values() {
+	for _, item := range expanded.FinalStates.Values() {
 		finalStateId := item.(int)
-		tExpansionEnd := fsa.Transition{Move: fsa.Eps, Label: "end-call-expansion"}
-		root.AddTransition(finalStateId+offset, to, tExpansionEnd)
+		tExpansionEnd := fsa.Transition{Move: fsa.Eps, Label: expansionLabel(t.Label, expansion, string(fsa.EndCallExpansion)), Payload: site}
+		root.AddTransition(finalStateId, to, tExpansionEnd)
 	}
 }
+
+// Returns the label of an eps transition of the given expansion of a call, prefixed by the callee and the number
+// of the expansion (e.g "worker#2/if-block-start@main.go:12:2"). The prefixes of the nested expansions pile up,
+// as a call stack, since the callee has been linearized (and its calls inlined) before being inlined in turn
+func expansionLabel(callee string, expansion int, label string) string {
+	return fmt.Sprintf(instanceTemplate, callee, expansion) + fsa.ExpansionSeparator + label
+}
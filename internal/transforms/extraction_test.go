@@ -0,0 +1,353 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the names given to the Goroutines extracted from the spawn tree
+package transforms_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+// Returns the sorted names of the local views extracted from the given source
+func participantNames(t *testing.T, source string) []string {
+	path := filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(path, []byte(source), 0664); err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for name := range transforms.ExtractGoroutineFSA(meta.ExtractMetadata(path, meta.NoTrace)) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestGoroutineNames(t *testing.T) {
+	source := `package main
+func helper(ch chan int) { ch <- 1 }
+func worker(ch chan int) {
+	go helper(ch)
+}
+func main() {
+	ch := make(chan int)
+	for i := 0; i < 2; i++ {
+		go worker(ch)
+	}
+}
+`
+	expected := []string{
+		"main",
+		"main/worker/helper@main.go:4#1",
+		"main/worker/helper@main.go:4#2",
+		"main/worker@main.go:9#1",
+		"main/worker@main.go:9#2",
+	}
+	if names := participantNames(t, source); !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected the participants %v, got %v", expected, names)
+	}
+
+	// A spawn added elsewhere (on its own line, so the other sites don't move) doesn't rename the others
+	sourceWithHelper := source[:len(source)-2] + "\tgo helper(ch)\n}\n"
+	expected = append(expected, "main/helper@main.go:11#1")
+	sort.Strings(expected)
+	if names := participantNames(t, sourceWithHelper); !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected the participants %v, got %v", expected, names)
+	}
+}
+
+func TestExpansionLabels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.go")
+	source := `package main
+func notify(ch chan int, ok bool) {
+	if ok {
+		ch <- 1
+	}
+}
+func main() {
+	ch := make(chan int)
+	notify(ch, true)
+	notify(ch, false)
+}
+`
+	if err := os.WriteFile(path, []byte(source), 0664); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each call is expanded on its own, the eps transitions of the two copies of the if block keep the
+	// position of the statement but they're labeled with the expansion they belong to
+	labels := []string{}
+	transforms.LinearizeFunctions(meta.ExtractMetadata(path, meta.NoTrace))["main"].ForEachTransition(func(_, _ int, t fsa.Transition) {
+		if meta.StructuralLabel(t.Label) != t.Label {
+			labels = append(labels, t.Label)
+		}
+	})
+	sort.Strings(labels)
+	expected := []string{
+		"func-main-return@main.go:11:1",
+		"notify#1/func-notify-return@main.go:6:1",
+		"notify#1/if-block-end@main.go:3:2",
+		"notify#1/if-block-skip@main.go:3:2",
+		"notify#1/if-block-start@main.go:3:2",
+		"notify#2/func-notify-return@main.go:6:1",
+		"notify#2/if-block-end@main.go:3:2",
+		"notify#2/if-block-skip@main.go:3:2",
+		"notify#2/if-block-start@main.go:3:2",
+	}
+	if !reflect.DeepEqual(labels, expected) {
+		t.Errorf("expected the labels %v, got %v", expected, labels)
+	}
+}
+
+func TestInliningSparseIds(t *testing.T) {
+	tSend := fsa.Transition{Move: fsa.Send, Label: "ch", Payload: meta.ChanMetadata{Name: "ch", Type: "int"}}
+
+	// The callee has non contiguous ids (the state 3 is gone after the pruning) and its final state is the biggest one
+	callee := fsa.New()
+	callee.AddTransition(0, 3, fsa.Transition{Move: fsa.Eps, Label: "removed"})
+	callee.AddTransition(0, 5, tSend)
+	callee.RemoveTransition(0, 3, fsa.Transition{Move: fsa.Eps, Label: "removed"})
+	callee.SetFinalState(5)
+	callee.Prune()
+
+	// The call ends in the biggest id of the caller, that's no longer used by any transition once the call is replaced
+	caller := fsa.New()
+	caller.AddTransition(0, 1, fsa.Transition{Move: fsa.Call, Label: "notify", Payload: []meta.FuncArg{}})
+	caller.SetFinalState(1)
+
+	file := meta.FileMetadata{FunctionMeta: map[string]meta.FuncMetadata{
+		"main":   {Name: "main", Automaton: caller},
+		"notify": {Name: "notify", Automaton: callee},
+	}}
+	linearized := transforms.LinearizeFunctions(file)["main"]
+
+	// The inlined states don't overlap the ones of the caller, so the callee is executed exactly once
+	dca := transforms.SubsetConstruction(linearized)
+	transitions := []string{}
+	dca.ForEachTransition(func(from, to int, t fsa.Transition) {
+		transitions = append(transitions, fmt.Sprintf("%d %s %d", from, t, to))
+	})
+	if !reflect.DeepEqual(transitions, []string{"0 → ch 1"}) || !dca.IsFinalState(1) || dca.IsFinalState(0) {
+		t.Errorf("expected only a send from 0 to the final state 1, got %v\n%s", transitions, linearized)
+	}
+}
+
+func TestExtractionWarnings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.go")
+	source := `package main
+import "strings"
+func worker(v interface{}, out chan int) { out <- 1 }
+func main() {
+	ch := make(chan int)
+	go worker(ch, make(chan int))
+	go strings.ToUpper("x")
+}
+`
+	if err := os.WriteFile(path, []byte(source), 0664); err != nil {
+		t.Fatal(err)
+	}
+
+	warnings := []string{}
+	meta.SetWarningObserver(func(warning meta.Warning) { warnings = append(warnings, warning.String()) })
+	defer meta.SetWarningObserver(nil)
+
+	// The extraction goes on with the approximations, each one of them reported to the observer
+	localViews := transforms.ExtractGoroutineFSA(meta.ExtractMetadata(path, meta.NoTrace))
+	expected := []string{
+		"[unsupported-node] selector-call 'strings.ToUpper' at line 7 isn't modeled (in main)",
+		"[unknown-spawn] spawn of unknown function 'strings.ToUpper' replaced with an eps transition (in main)",
+		"[argument-mismatch] no channel is passed to 'out' of 'worker' in its position, the channel 'ch' is bound by position (in main)",
+	}
+	sort.Strings(expected)
+	sort.Strings(warnings)
+	if !reflect.DeepEqual(warnings, expected) || len(localViews) != 2 {
+		t.Errorf("expected the warnings %v and 2 local views, got %v and %d", expected, warnings, len(localViews))
+	}
+}
+
+func TestVariadicChannels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.go")
+	source := `package main
+func worker(id int, chs ...chan int) {
+	chs[0] <- id
+	<-chs[1]
+}
+func main() {
+	jobs, acks, unused := make(chan int), make(chan int), make(chan int)
+	go worker(1, jobs, acks, unused)
+}
+`
+	if err := os.WriteFile(path, []byte(source), 0664); err != nil {
+		t.Fatal(err)
+	}
+
+	warnings := []meta.Warning{}
+	meta.SetWarningObserver(func(warning meta.Warning) { warnings = append(warnings, warning) })
+	defer meta.SetWarningObserver(nil)
+
+	// Each element accessed by the worker is bound to the channel passed in its position, the last one isn't used
+	file := meta.ExtractMetadata(path, meta.NoTrace)
+	bindings := []transforms.ChannelBinding{{Parameter: "chs[0]", Channel: "jobs"}, {Parameter: "chs[1]", Channel: "acks"}}
+	localViews := transforms.ExtractGoroutineFSA(file)
+	for name, lView := range localViews {
+		if name != transforms.MainName && !reflect.DeepEqual(lView.Spawn.Bindings, bindings) {
+			t.Errorf("expected the bindings %v, got %v", bindings, lView.Spawn.Bindings)
+		}
+	}
+	if len(localViews) != 2 {
+		t.Errorf("expected the local views of main and the worker, got %d", len(localViews))
+	}
+	if len(warnings) != 0 {
+		t.Errorf("expected no warning, got %v", warnings)
+	}
+}
+
+func TestLocalChannelCollisions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.go")
+	source := `package main
+func worker(in chan int) { <-in }
+func helper(out chan int) {
+	ch := make(chan int)
+	go worker(ch)
+	ch <- 1
+	out <- 2
+}
+func main() {
+	ch := make(chan int)
+	helper(ch)
+	helper(ch)
+	<-ch
+}
+`
+	if err := os.WriteFile(path, []byte(source), 0664); err != nil {
+		t.Fatal(err)
+	}
+
+	// The channel declared by each call of the helper is its own, the one passed is the channel of main
+	localViews := transforms.ExtractGoroutineFSA(meta.ExtractMetadata(path, meta.NoTrace))
+	operations := map[string]int{}
+	localViews[transforms.MainName].Automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+		if t.Move == fsa.Send || t.Move == fsa.Recv {
+			operations[t.String()]++
+		}
+	})
+	expected := map[string]int{"→ ch@helper#1": 1, "→ ch@helper#2": 1, "→ ch": 2, "← ch": 1}
+	if !reflect.DeepEqual(operations, expected) {
+		t.Errorf("expected the operations %v, got %v", expected, operations)
+	}
+
+	// Every worker receives on the channel of the helper call that spawned it
+	channels := []string{}
+	for name, lView := range localViews {
+		if name != transforms.MainName {
+			channels = append(channels, lView.Spawn.Bindings[0].Channel)
+		}
+	}
+	sort.Strings(channels)
+	if !reflect.DeepEqual(channels, []string{"ch@helper#1", "ch@helper#2"}) {
+		t.Errorf("expected the workers to receive on the channels of the helper calls, got %v", channels)
+	}
+}
+
+func TestStructuralRegistry(t *testing.T) {
+	paths, err := filepath.Glob("../../example/*.go")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("expected the example corpus, got %v (%v)", paths, err)
+	}
+
+	// Every eps transition of the local views is either a structural one or the placeholder of an action not modeled
+	for _, path := range paths {
+		for name, lView := range transforms.ExtractGoroutineFSA(meta.ExtractMetadata(path, meta.NoTrace)) {
+			lView.Automaton.ForEachTransition(func(from, to int, tr fsa.Transition) {
+				placeholders := []fsa.EpsLabel{fsa.UnknownFunctionCall, fsa.UnknownFunctionSpawn, fsa.UnsupportedSpawn, fsa.NetworkHandlerCall}
+				if tr.Move == fsa.Eps && !tr.Structural() && !fsa.BaseLabel(tr.Label).Matches(placeholders...) {
+					t.Errorf("unregistered eps label '%s' in the local view of '%s' (%s)", tr.Label, name, path)
+				}
+			})
+		}
+	}
+}
+
+func TestInitFlow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.go")
+	source := `package main
+var jobs chan int
+func worker(ch chan int) { <-ch }
+func init() {
+	jobs = make(chan int)
+	go worker(jobs)
+}
+func init() {
+	ch := make(chan int)
+	go worker(ch)
+	ch <- 1
+}
+func main() {
+	ch := make(chan int)
+	jobs <- 1
+	close(ch)
+}
+`
+	if err := os.WriteFile(path, []byte(source), 0664); err != nil {
+		t.Fatal(err)
+	}
+
+	// The init functions run in order before main, the channel local to an init is its own
+	localViews := transforms.ExtractGoroutineFSA(meta.ExtractMetadata(path, meta.NoTrace))
+	var trace []string
+	automaton := localViews[transforms.MainName].Automaton
+	for state, visited := automaton.InitialState(), map[int]bool{}; !visited[state]; {
+		visited[state] = true
+		automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+			if from == state && !visited[to] {
+				if t.Move != fsa.Eps {
+					trace = append(trace, t.String())
+				}
+				state = to
+			}
+		})
+	}
+	expected := []string{"△ main/worker@main.go:6#1", "△ main/worker@main.go:10#1", "→ ch@init#2", "→ jobs"}
+	if !reflect.DeepEqual(trace, expected) {
+		t.Errorf("expected the operations %v of main, got %v", expected, trace)
+	}
+
+	bindings := map[string]string{}
+	for name, lView := range localViews {
+		if name != transforms.MainName {
+			bindings[name] = lView.Spawn.Bindings[0].Channel
+		}
+	}
+	if expected := map[string]string{"main/worker@main.go:6#1": "jobs", "main/worker@main.go:10#1": "ch@init#2"}; !reflect.DeepEqual(bindings, expected) {
+		t.Errorf("expected the workers bound to %v, got %v", expected, bindings)
+	}
+}
+
+func TestExternalFunctionCall(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.go")
+	source := "package main\nfunc checksum(in chan int) int\nfunc main() {\n\tin := make(chan int)\n\tchecksum(in)\n\tin <- 1\n}\n"
+	if err := os.WriteFile(path, []byte(source), 0664); err != nil {
+		t.Fatal(err)
+	}
+
+	// The call isn't inlined as the one of an empty function, since the latter isn't modeled
+	labels := map[fsa.EpsLabel]bool{}
+	transforms.LinearizeFunctions(meta.ExtractMetadata(path, meta.NoTrace))["main"].ForEachTransition(func(from, to int, t fsa.Transition) {
+		if t.Move == fsa.Eps {
+			labels[fsa.BaseLabel(t.Label)] = true
+		}
+	})
+	if !labels[fsa.ExternalFunctionCall] || labels[fsa.StartCallExpansion] {
+		t.Errorf("expected the call to 'checksum' to be replaced with '%s', got %v", fsa.ExternalFunctionCall, labels)
+	}
+}
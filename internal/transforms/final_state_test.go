@@ -0,0 +1,57 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the propagation of the final states through the determinization and the composition
+package transforms_test
+
+import (
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestFinalStatesDeterminization(t *testing.T) {
+	// A function without communications: the final state is reached with eps transitions only
+	silent := fsa.New()
+	silent.AddTransition(0, fsa.NewState, fsa.Transition{Move: fsa.Eps, Label: "if-block-start"})
+	silent.AddTransition(fsa.Current, fsa.NewState, fsa.Transition{Move: fsa.Eps, Label: "func-silent-return"})
+	silent.SetFinalState(silent.GetLastId())
+	if dca := transforms.SubsetConstruction(silent); !dca.IsFinalState(0) {
+		t.Errorf("expected the initial state to be final, got the final states %v", dca.FinalStates.Values())
+	}
+
+	// The closure reached after the send contains the final state, while the initial one doesn't
+	sender := fsa.New()
+	sender.AddTransition(0, fsa.NewState, fsa.Transition{Move: fsa.Send, Label: "ch"})
+	sender.AddTransition(fsa.Current, fsa.NewState, fsa.Transition{Move: fsa.Eps, Label: "func-sender-return"})
+	sender.SetFinalState(sender.GetLastId())
+	if dca := transforms.SubsetConstruction(sender); dca.IsFinalState(0) || !dca.IsFinalState(1) || dca.FinalStates.Size() != 1 {
+		t.Errorf("expected only the state after the send to be final, got %v", dca.FinalStates.Values())
+	}
+}
+
+func TestFinalStatesComposition(t *testing.T) {
+	chanMeta := meta.ChanMetadata{Name: "ch", Type: "int"}
+
+	main := fsa.New()
+	main.AddTransition(0, 1, fsa.Transition{Move: fsa.Spawn, Label: "main/worker@main.go:6#1"})
+	main.AddTransition(1, 2, fsa.Transition{Move: fsa.Recv, Label: "ch", Payload: chanMeta})
+	main.SetFinalState(2)
+	worker := fsa.New()
+	worker.AddTransition(0, 1, fsa.Transition{Move: fsa.Send, Label: "ch", Payload: chanMeta})
+	worker.SetFinalState(1)
+
+	localViews := map[string]*transforms.GoroutineFSA{
+		transforms.MainName:       {Name: transforms.MainName, FuncMetadata: meta.FuncMetadata{Name: "main", Automaton: main}},
+		"main/worker@main.go:6#1": {Name: "main/worker@main.go:6#1", FuncMetadata: meta.FuncMetadata{Name: "worker", Automaton: worker}},
+	}
+
+	// Only the configuration in which both the Goroutines are done is final
+	choreography := transforms.LocalViewsComposition(localViews)
+	if choreography.IsFinalState(0) || choreography.FinalStates.Size() != 1 {
+		t.Errorf("expected a single final state, other than the initial one, got %v", choreography.FinalStates.Values())
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Golden tests for the whole Choreia pipeline, each program in the example corpus is
+// analyzed and the resulting local views and Choreography Automata are compared with
+// the checked-in snapshots. Run "go test ./internal/transforms -update" to regenerate them.
+package transforms_test
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+var updateGolden = flag.Bool("update", false, "Overwrites the golden files with the current output")
+
+// The directories containing the programs to be analyzed
+var corpusDirs = []string{"../../example"}
+
+// Runs the full pipeline on the given program and returns the canonical text form
+// of every local view (sorted by name) followed by the one of the Choreography Automata
+func runPipeline(t *testing.T, programPath string) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+
+	fileMetadata := meta.ExtractMetadata(programPath, meta.NoTrace)
+	localViews := transforms.ExtractGoroutineFSA(fileMetadata)
+
+	viewNames := make([]string, 0, len(localViews))
+	for name, lView := range localViews {
+		lView.Automaton = transforms.SubsetConstruction(lView.Automaton)
+		viewNames = append(viewNames, name)
+	}
+	sort.Strings(viewNames)
+
+	for _, name := range viewNames {
+		fmt.Fprintf(&buffer, "== local view: %s\n%s\n", name, localViews[name].Automaton)
+	}
+
+	choreography := transforms.LocalViewsComposition(localViews)
+	fmt.Fprintf(&buffer, "== global view\n%s", choreography)
+
+	return buffer.Bytes()
+}
+
+func TestGoldenCorpus(t *testing.T) {
+	programs := []string{}
+	for _, dir := range corpusDirs {
+		matches, err := filepath.Glob(filepath.Join(dir, "*.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		programs = append(programs, matches...)
+	}
+
+	if len(programs) == 0 {
+		t.Fatal("no program found in the corpus directories")
+	}
+
+	for _, programPath := range programs {
+		programPath := programPath
+		name := strings.TrimSuffix(filepath.Base(programPath), ".go")
+
+		t.Run(name, func(t *testing.T) {
+			goldenPath := filepath.Join("testdata", "golden", name+".golden")
+			actual := runPipeline(t, programPath)
+
+			if *updateGolden {
+				if err := os.WriteFile(goldenPath, actual, 0664); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			expected, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("missing golden file (run with -update to create it): %s", err)
+			}
+
+			if !bytes.Equal(expected, actual) {
+				t.Errorf("output differs from %s\n--- expected\n%s\n--- actual\n%s", goldenPath, expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package transforms declares the types and functions used to transform and work with some type of FSA.
+// Come of the transformation implemented here are standard such as determinization (Subset Construction),
+// minimization but more are specifically related to Choreia (GoroutineFSA extraction & Composition)
+//
+package transforms
+
+import (
+	"fmt"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+)
+
+const (
+	// LabelVerbosity enum
+	MinimalLabels LabelVerbosity = iota // Only the participants (e.g "A → B")
+	TypedLabels                         // The participants and the message type (e.g "A → B: int")
+	FullLabels                          // The participants, the channel and the message type (e.g "A → B: ch<int>")
+)
+
+// The names of the LabelVerbosity values, as accepted by ParseLabelVerbosity
+var verbosityNames = [...]string{"minimal", "typed", "full"}
+
+// Type alias to abstract the LabelVerbosity enum, it defines how much
+// information is shown in the labels of the Choreography Automata
+type LabelVerbosity int
+
+// Converts the LabelVerbosity to its name (e.g "full")
+func (lv LabelVerbosity) String() string {
+	if lv < 0 || int(lv) >= len(verbosityNames) {
+		return fmt.Sprintf("LabelVerbosity(%d)", int(lv))
+	}
+	return verbosityNames[lv]
+}
+
+// Parses the name of a LabelVerbosity (e.g "typed"), an error is returned for unknown names
+func ParseLabelVerbosity(name string) (LabelVerbosity, error) {
+	for i, verbosityName := range verbosityNames {
+		if name == verbosityName {
+			return LabelVerbosity(i), nil
+		}
+	}
+	return FullLabels, fmt.Errorf("unknown label verbosity '%s'", name)
+}
+
+// ----------------------------------------------------------------------------
+// Interaction
+
+// The payload of the transitions of the Choreography Automata, it describes the interaction
+// between two local views: either a spawn (From spawns To) or a message exchange (From sends to To).
+// On a buffered channel the message is first put in the buffer by the sender (To is empty) and later
+// delivered to the receiver (From is still the sender), the two are different steps of the composition
+type Interaction struct {
+	From    string            // The name of the local view that sends the message (or spawns the other)
+	To      string            // The name of the local view that receives the message (or that is spawned)
+	Channel meta.ChanMetadata // The channel over which the message is exchanged (zero value for spawns)
+}
+
+// A LocalMove is the transition that a participant involved in an interaction makes in its own local view
+type LocalMove struct {
+	Participant string       // The participant that makes the move
+	Move        fsa.MoveKind // The Move of the transition (Spawn, Send or Recv)
+	Label       string       // The label of the transition (the spawned local view or the channel)
+}
+
+// Returns true if the interaction is a spawn (From spawns To) instead of a message exchange
+func (i Interaction) IsSpawn() bool {
+	return i.Channel.Name == ""
+}
+
+// Returns true if the message is exchanged over a buffered channel, that is in two steps: the sender puts it
+// in the buffer (see IsBufferedSend) and then the receiver takes it. The environment channels aren't buffered,
+// the environment is ready at any time (see ExtractEnvironment) so its messages are always exchanged at once
+func (i Interaction) IsBuffered() bool {
+	return !i.IsSpawn() && i.Channel.Async && !i.Channel.Environment
+}
+
+// Returns true if the interaction is the send of a message in the buffer of a channel, whose receiver isn't known yet
+func (i Interaction) IsBufferedSend() bool {
+	return i.IsBuffered() && i.To == ""
+}
+
+// Returns the moves made by the participants, each one in its own local view: the spawner makes the Spawn (the
+// spawned one starts from its initial state), while for a message exchanged at once both the Send and Recv are made.
+// The send in the buffer moves only the sender and, likewise, the delivery of the buffered message only the receiver
+func (i Interaction) LocalMoves() []LocalMove {
+	switch {
+	case i.IsSpawn():
+		return []LocalMove{{i.From, fsa.Spawn, i.To}}
+	case i.IsBufferedSend():
+		return []LocalMove{{i.From, fsa.Send, i.Channel.Name}}
+	case i.IsBuffered():
+		return []LocalMove{{i.To, fsa.Recv, i.Channel.Name}}
+	}
+	return []LocalMove{{i.From, fsa.Send, i.Channel.Name}, {i.To, fsa.Recv, i.Channel.Name}}
+}
+
+// Returns the label of the interaction with the given verbosity, the spawns are always shown as
+// "A △ B" while the message exchanges as "A → B" followed (if known) by the channel and message type.
+// The full labels show the buffer size of the buffered channels as well (e.g "A → B: ch<int>[3]"), the
+// send in the buffer is shown with the channel instead of the receiver (e.g "A ▷ ch" or "A ▷ ch<int>[3]")
+func (i Interaction) Label(verbosity LabelVerbosity) string {
+	if i.IsSpawn() {
+		return fmt.Sprintf("%s △ %s", i.From, i.To)
+	}
+
+	if i.IsBufferedSend() {
+		label := fmt.Sprintf("%s ▷ %s", i.From, i.Channel.Name)
+		switch {
+		case verbosity >= FullLabels && i.Channel.Type != "":
+			label += fmt.Sprintf("<%s>%s", i.Channel.Type, bufferSize(i.Channel))
+		case verbosity >= FullLabels:
+			label += bufferSize(i.Channel)
+		case verbosity == TypedLabels && i.Channel.Type != "":
+			label += fmt.Sprintf(": %s", i.Channel.Type)
+		}
+		return label
+	}
+
+	label := fmt.Sprintf("%s → %s", i.From, i.To)
+	switch {
+	case verbosity >= FullLabels && i.Channel.Type != "":
+		label += fmt.Sprintf(": %s<%s>%s", i.Channel.Name, i.Channel.Type, bufferSize(i.Channel))
+	case verbosity >= FullLabels:
+		label += fmt.Sprintf(": %s%s", i.Channel.Name, bufferSize(i.Channel))
+	case verbosity == TypedLabels && i.Channel.Type != "":
+		label += fmt.Sprintf(": %s", i.Channel.Type)
+	}
+	return label
+}
+
+// Returns the buffer size of the given channel as shown in the labels, "[N]" for a buffered channel
+// ("[?]" if the size isn't constant) and an empty string for an unbuffered (synchronous) one. The buffer
+// of the environment channels isn't shown, the latter are inputs that may or may not happen at any time
+func bufferSize(channel meta.ChanMetadata) string {
+	if !channel.Async || channel.Environment {
+		return ""
+	}
+	if channel.Capacity == meta.UnknownCapacity {
+		return "[?]"
+	}
+	return fmt.Sprintf("[%d]", channel.Capacity)
+}
+
+// Returns a copy of the given Choreography Automata where the label of each interaction is
+// generated again with the given verbosity. Note that with a lower verbosity two different
+// interactions between the same states could have the same label, in that case only one is kept
+func RelabelInteractions(choreography *fsa.FSA, verbosity LabelVerbosity) *fsa.FSA {
+	relabeled := choreography.Copy()
+
+	relabeled.ForEachTransition(func(from, to int, t fsa.Transition) {
+		interaction, isInteraction := t.Payload.(Interaction)
+		if !isInteraction || interaction.Label(verbosity) == t.Label {
+			return
+		}
+
+		newT := fsa.Transition{Move: t.Move, Label: interaction.Label(verbosity), Payload: interaction}
+		relabeled.RemoveTransition(from, to, t)
+		relabeled.AddTransition(from, to, newT)
+	})
+
+	return relabeled
+}
@@ -0,0 +1,35 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the labels generated for the interactions of the Choreography Automata
+package transforms_test
+
+import (
+	"testing"
+
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestInteractionLabel(t *testing.T) {
+	exchange := transforms.Interaction{From: "A", To: "B", Channel: meta.ChanMetadata{Name: "ch", Type: "int"}}
+	spawn := transforms.Interaction{From: "A", To: "B"}
+
+	cases := []struct {
+		interaction transforms.Interaction
+		verbosity   transforms.LabelVerbosity
+		expected    string
+	}{
+		{exchange, transforms.MinimalLabels, "A → B"},
+		{exchange, transforms.TypedLabels, "A → B: int"},
+		{exchange, transforms.FullLabels, "A → B: ch<int>"},
+		{spawn, transforms.FullLabels, "A △ B"},
+	}
+
+	for _, c := range cases {
+		if label := c.interaction.Label(c.verbosity); label != c.expected {
+			t.Errorf("%s label: expected '%s', got '%s'", c.verbosity, c.expected, label)
+		}
+	}
+}
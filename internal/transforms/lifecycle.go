@@ -0,0 +1,193 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package transforms declares the types and functions used to transform and work with some type of FSA.
+// Come of the transformation implemented here are standard such as determinization (Subset Construction),
+// minimization but more are specifically related to Choreia (GoroutineFSA extraction & Composition)
+//
+package transforms
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-graphviz/cgraph"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+)
+
+const (
+	SpawnEdgeStyle       = cgraph.BoldEdgeStyle   // The style of the edges where a participant is spawned
+	TerminationEdgeStyle = cgraph.DottedEdgeStyle // The style of the edges where a participant terminates
+)
+
+// The local state of a participant that hasn't been spawned yet
+const notSpawned = -1
+
+// A lifecycleEdge identifies an edge of the exported Choreography Automata (the group of parallel transitions
+// between the same two states)
+type lifecycleEdge struct{ from, to int }
+
+// Returns an EdgeDecorator that annotates the lifetime of each participant on the Choreography Automata: the
+// edges with a Spawn interaction are drawn with SpawnEdgeStyle, the ones after which a participant has terminated
+// (see terminatingEdges) with TerminationEdgeStyle and its name in the tooltip. Between the two edges lies
+// the window in which the participant is alive. The decorator is meant for the given automaton only, it has to
+// be computed again if the automaton is changed (e.g by Simplify, which renumbers the states)
+func LifecycleDecorator(choreography *fsa.FSA, localViews map[string]*GoroutineFSA) fsa.EdgeDecorator {
+	terminations := make(map[lifecycleEdge]map[string]bool)
+	for name := range localViews {
+		for edge := range terminatingEdges(choreography, localViews, name) {
+			if terminations[edge] == nil {
+				terminations[edge] = make(map[string]bool)
+			}
+			terminations[edge][name] = true
+		}
+	}
+
+	return func(edge *fsa.GraphEdge, from, to int, parallelT []fsa.Transition) {
+		styles := []string{}
+		for _, t := range parallelT {
+			if interaction, isInteraction := t.Payload.(Interaction); isInteraction && interaction.IsSpawn() {
+				styles = append(styles, string(SpawnEdgeStyle))
+				break
+			}
+		}
+
+		if terminated := terminations[lifecycleEdge{from, to}]; len(terminated) > 0 {
+			styles = append(styles, string(TerminationEdgeStyle))
+			tooltip := edge.Tooltip
+			if tooltip == "" {
+				tooltip = edge.Label
+			}
+			edge.Tooltip = fmt.Sprintf("%s\nterminates: %s", tooltip, strings.Join(sortedKeys(terminated), ", "))
+		}
+
+		// Graphviz accepts a comma separated list of styles (e.g "bold,dotted") when both apply
+		if len(styles) > 0 {
+			edge.Style = cgraph.EdgeStyle(strings.Join(styles, ","))
+		}
+	}
+}
+
+// Returns the edges of the Choreography Automata after which the given participant has terminated, that is it's in a
+// final state of its local view and it isn't involved in any of the interactions that can still follow. A participant
+// that never gets there (e.g a worker blocked forever in a loop) has no such edge. The interactions are replayed only
+// on the participant, starting from the initial state of its local view (or from notSpawned if another participant
+// spawns it): the other ones are left to the Choreography Automata, that allows only the moves the system can make
+func terminatingEdges(choreography *fsa.FSA, localViews map[string]*GoroutineFSA, name string) map[lifecycleEdge]bool {
+	automaton := localViews[name].Automaton
+	type pair struct{ global, local int }
+	type step struct {
+		edge       lifecycleEdge
+		next       pair
+		isInvolved bool
+	}
+
+	initial := pair{choreography.InitialState(), automaton.InitialState()}
+	if _, isSpawned := spawnersOf(localViews)[name]; isSpawned {
+		initial.local = notSpawned
+	}
+
+	outgoing := make(map[int][]lifecycleEdge)
+	payloads := make(map[int][]fsa.Transition)
+	choreography.ForEachTransition(func(from, to int, t fsa.Transition) {
+		outgoing[from] = append(outgoing[from], lifecycleEdge{from, to})
+		payloads[from] = append(payloads[from], t)
+	})
+
+	// Explores the pairs (global state, local state of the participant) reachable from the initial one
+	steps, predecessors := make(map[pair][]step), make(map[pair][]pair)
+	seen, queue := map[pair]bool{initial: true}, []pair{initial}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for i, edge := range outgoing[current.global] {
+			local, isInvolved, isMoved := lifecycleMove(automaton, name, current.local, payloads[current.global][i])
+			if !isMoved {
+				continue
+			}
+			next := pair{edge.to, local}
+			steps[current] = append(steps[current], step{edge, next, isInvolved})
+			predecessors[next] = append(predecessors[next], current)
+			if !seen[next] {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	// The pairs from which the participant can still be involved in an interaction, computed backward
+	isActive := make(map[pair]bool)
+	queue = []pair{}
+	for current, currentSteps := range steps {
+		for _, s := range currentSteps {
+			if s.isInvolved && !isActive[current] {
+				isActive[current] = true
+				queue = append(queue, current)
+			}
+		}
+	}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, previous := range predecessors[current] {
+			if !isActive[previous] {
+				isActive[previous] = true
+				queue = append(queue, previous)
+			}
+		}
+	}
+
+	isTerminated := func(p pair) bool {
+		return p.local != notSpawned && automaton.IsFinalState(p.local) && !isActive[p]
+	}
+	edges := make(map[lifecycleEdge]bool)
+	for current, currentSteps := range steps {
+		for _, s := range currentSteps {
+			if s.isInvolved && !isTerminated(current) && isTerminated(s.next) {
+				edges[s.edge] = true
+			}
+		}
+	}
+	return edges
+}
+
+// Returns the local state reached by the given participant with the given transition of the Choreography Automata,
+// the state doesn't change if the interaction doesn't involve the participant (the second return value). The last
+// return value is false if the participant is involved but can't make the move from its current local state
+func lifecycleMove(automaton *fsa.FSA, name string, local int, t fsa.Transition) (int, bool, bool) {
+	interaction, isInteraction := t.Payload.(Interaction)
+	if !isInteraction {
+		return local, false, true
+	}
+	if interaction.IsSpawn() && interaction.To == name {
+		return automaton.InitialState(), true, local == notSpawned
+	}
+
+	for _, move := range interaction.LocalMoves() {
+		if move.Participant != name {
+			continue
+		}
+		if local == notSpawned {
+			return local, true, false
+		}
+
+		// The local views are deterministic, so there's at most one transition with the given move and label
+		moves := []int{}
+		automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+			if from == local && t.Move == move.Move && t.Label == move.Label {
+				moves = append(moves, to)
+			}
+		})
+		sort.Ints(moves)
+		if len(moves) == 0 {
+			return local, true, false
+		}
+		return moves[0], true, true
+	}
+
+	return local, false, true
+}
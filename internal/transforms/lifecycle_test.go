@@ -0,0 +1,48 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the lifecycle annotations of the Choreography Automata
+package transforms_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestLifecycleDecorator(t *testing.T) {
+	fileMetadata := meta.ExtractMetadata("../../example/PingPong.go", meta.NoTrace)
+	localViews := transforms.ExtractGoroutineFSA(fileMetadata)
+	for _, lView := range localViews {
+		lView.Automaton = transforms.SubsetConstruction(lView.Automaton)
+	}
+	choreography := transforms.LocalViewsComposition(localViews)
+
+	graph := fsa.NewMemoryGraph()
+	choreography.DrawDecorated(graph, transforms.LifecycleDecorator(choreography, localViews))
+
+	spawns, terminations := 0, 0
+	for _, edge := range graph.Edges {
+		if strings.Contains(string(edge.Style), string(transforms.SpawnEdgeStyle)) {
+			spawns++
+			if !strings.Contains(edge.Label, "△") {
+				t.Errorf("expected only the spawns to be highlighted as such, got %v", edge)
+			}
+		}
+		if strings.Contains(string(edge.Style), string(transforms.TerminationEdgeStyle)) {
+			terminations++
+			// Main terminates once the ball comes back, along with the player that sent it, while the other
+			// player keeps waiting for the ball forever
+			if !strings.HasSuffix(edge.Tooltip, "terminates: main, main/player@PingPong.go:20#1") || !strings.Contains(edge.Label, "→ main: pong") {
+				t.Errorf("expected main to terminate only when receiving on pong, got %v", edge)
+			}
+		}
+	}
+	if spawns != 2 || terminations == 0 {
+		t.Errorf("expected 2 spawn edges and at least a termination one, got %d and %d", spawns, terminations)
+	}
+}
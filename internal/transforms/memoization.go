@@ -0,0 +1,135 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package transforms declares the types and functions used to transform and work with some type of FSA.
+// Come of the transformation implemented here are standard such as determinization (Subset Construction),
+// minimization but more are specifically related to Choreia (GoroutineFSA extraction & Composition)
+//
+package transforms
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+)
+
+// The template of the placeholder that replaces the name of a spawned Goroutine in the canonical form of a local view
+const spawnPlaceholder = "$spawn(%s)"
+
+// ----------------------------------------------------------------------------
+// DeterminizationCache
+
+// A DeterminizationCache memoizes the determinization of the local views of the Goroutines spawned from the same
+// function with the same channels (e.g the workers of a pool), so that only the first one of them pays its cost.
+//
+// The local views of these Goroutines differ only by the names of the ones they spawn in turn (every instance has
+// its own children), so the determinization is performed on a canonical form in which each spawned Goroutine is
+// named after how it's spawned instead (function, site and channels). The canonical form is isomorphic to the local
+// view and the order in which the Subset Construction visits the transitions doesn't depend on the labels, so the
+// result is renamed back (with the names of the current instance) without any difference from the direct one.
+// Since the local views can be modified by the hooks the canonical form is compared as well before any reuse
+type DeterminizationCache struct {
+	entries map[string]determinizedView
+	Hits    int // The local views whose deterministic version has been reused
+	Misses  int // The local views determinized from scratch
+}
+
+// The deterministic version of a local view, in canonical form, along with the canonical form of the original one
+type determinizedView struct {
+	fingerprint string
+	automaton   *fsa.FSA
+}
+
+// Creates an empty DeterminizationCache, meant to be used for the local views of a single extraction
+func NewDeterminizationCache() *DeterminizationCache {
+	return &DeterminizationCache{entries: make(map[string]determinizedView)}
+}
+
+// Returns the deterministic version of the given local view (see SubsetConstruction), the others are needed to know
+// how its children have been spawned. The cached automata are never modified, a new one is returned every time
+func (cache *DeterminizationCache) Determinize(lView *GoroutineFSA, localViews map[string]*GoroutineFSA) *fsa.FSA {
+	canonical, names, isCanonical := canonicalSpawns(lView.Automaton, localViews)
+	// Only the spawned Goroutines with a known function can share their local view with the others
+	if !isCanonical || lView.FuncMetadata.Name == "" || lView.Spawn.Spawner == "" {
+		cache.Misses++
+		return SubsetConstruction(lView.Automaton)
+	}
+
+	key, fingerprint := instanceKey(lView.FuncMetadata.Name, lView.Spawn.Bindings), automatonFingerprint(canonical)
+	entry, isCached := cache.entries[key]
+	if isCached && entry.fingerprint == fingerprint {
+		cache.Hits++
+	} else {
+		cache.Misses++
+		entry = determinizedView{fingerprint, SubsetConstruction(canonical)}
+		cache.entries[key] = entry
+	}
+	return renameSpawns(entry.automaton, names)
+}
+
+// Returns the key of the Goroutines spawned from the given function with the given channels (e.g "worker(jobs=tasks)")
+func instanceKey(function string, bindings []ChannelBinding) string {
+	channels := make([]string, 0, len(bindings))
+	for _, binding := range bindings {
+		channels = append(channels, fmt.Sprintf("%s=%s", binding.Parameter, binding.Channel))
+	}
+	return fmt.Sprintf("%s(%s)", function, strings.Join(channels, ", "))
+}
+
+// Returns a copy of the given automaton in which every spawned Goroutine is named after how it has been spawned (see
+// spawnPlaceholder) along with the name that each placeholder replaces. False is returned when a spawned Goroutine
+// doesn't have a local view (or a function) or two of them are spawned in the same way, since they couldn't be
+// told apart anymore
+func canonicalSpawns(automaton *fsa.FSA, localViews map[string]*GoroutineFSA) (*fsa.FSA, map[string]string, bool) {
+	placeholders, names, isCanonical := make(map[string]string), make(map[string]string), true
+
+	automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+		spawned, exist := localViews[t.Label]
+		if t.Move != fsa.Spawn || !isCanonical {
+			return
+		} else if !exist || spawned.FuncMetadata.Name == "" {
+			isCanonical = false
+			return
+		}
+
+		site := fmt.Sprintf(siteTemplate, spawned.FuncMetadata.Name, spawned.Spawn.Site)
+		placeholder := fmt.Sprintf(spawnPlaceholder, instanceKey(site, spawned.Spawn.Bindings))
+		if name, isUsed := names[placeholder]; isUsed && name != t.Label {
+			isCanonical = false
+			return
+		}
+		placeholders[t.Label], names[placeholder] = placeholder, t.Label
+	})
+
+	if !isCanonical {
+		return nil, nil, false
+	}
+	return renameSpawns(automaton, placeholders), names, true
+}
+
+// Returns a copy of the given automaton in which the spawned Goroutines are renamed as specified, the renamed
+// transitions keep their position (see fsa.Relabel) so that the visits are the same of the original automaton
+func renameSpawns(automaton *fsa.FSA, names map[string]string) *fsa.FSA {
+	return automaton.Relabel(func(t fsa.Transition) fsa.Transition {
+		if name, isRenamed := names[t.Label]; isRenamed && t.Move == fsa.Spawn {
+			return fsa.Transition{Move: t.Move, Label: name, Payload: t.Payload}
+		}
+		return t
+	})
+}
+
+// Returns a textual form of the given automaton that identifies it: two automata with the same one have the
+// same states (initial and final ones included) and the same transitions, payloads included (see fsa.PayloadKey)
+func automatonFingerprint(automaton *fsa.FSA) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "initial %d\n", automaton.InitialState())
+	automaton.ForEachState(func(id int) {
+		fmt.Fprintf(&builder, "state %d %t\n", id, automaton.IsFinalState(id))
+	})
+	automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+		fmt.Fprintf(&builder, "%d %d %s %s %s\n", from, to, t.Move, t.Label, fsa.PayloadKey(t.Payload))
+	})
+	return builder.String()
+}
@@ -0,0 +1,54 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the memoized determinization of the local views
+package transforms_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestDeterminizationCache(t *testing.T) {
+	source := `package main
+func helper(ch chan int) { ch <- 1 }
+func worker(jobs chan int, results chan int) {
+	go helper(results)
+	for job := range jobs {
+		results <- job
+	}
+}
+func main() {
+	jobs, results, other := make(chan int), make(chan int), make(chan int)
+	go worker(jobs, results)
+	go worker(jobs, results)
+	go worker(jobs, other)
+	jobs <- 1
+	<-results
+}
+`
+	path := filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(path, []byte(source), 0664); err != nil {
+		t.Fatal(err)
+	}
+	localViews := transforms.ExtractGoroutineFSA(meta.ExtractMetadata(path, meta.NoTrace))
+
+	// Every local view has to be the same one that the Subset Construction returns, children names included
+	cache := transforms.NewDeterminizationCache()
+	for name, lView := range localViews {
+		expected := transforms.SubsetConstruction(lView.Automaton)
+		if got := cache.Determinize(lView, localViews); got.String() != expected.String() {
+			t.Errorf("expected the local view of '%s' to be\n%s\ngot\n%s", name, expected, got)
+		}
+	}
+
+	// The second worker (and the helper it spawns) reuse the ones of the first, the third has other channels
+	if cache.Hits != 2 || cache.Misses != len(localViews)-2 {
+		t.Errorf("expected 2 local views reused out of %d, got %d (%d misses)", len(localViews), cache.Hits, cache.Misses)
+	}
+}
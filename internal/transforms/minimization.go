@@ -0,0 +1,32 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package transforms declares the types and functions used to transform and work with some type of FSA.
+// Come of the transformation implemented here are standard such as determinization (Subset Construction),
+// minimization but more are specifically related to Choreia (GoroutineFSA extraction & Composition)
+//
+package transforms
+
+import (
+	"fmt"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+)
+
+// Minimizes the given local view, the states that can't be told apart by the other participants are merged
+// together. The Send, Recv and Spawn transitions are the alphabet, each move compared along with its payload
+// (the channel metadata or the arguments of the spawn, see communicationKey) since the composition reads it,
+// while the eps transitions (if any are left) are the internal moves of a weak bisimulation. The minimized local
+// view offers the same choices at the same time of the original one, so the Choreography Automata composed from
+// it is bisimilar to the one of the original local views (with less configurations to be explored). The states
+// are renumbered breadth-first from the initial one, that keeps the id 0
+func MinimizeLocalView(localView *fsa.FSA) *fsa.FSA {
+	return weakBisimulationQuotient(localView, communicationKey)
+}
+
+// Returns the key of a visible transition of a local view in the signatures of the weak bisimulation, the move
+// is part of it since a Send and a Recv on the same channel have the same label (see MinimizeLocalView)
+func communicationKey(t fsa.Transition) string {
+	return fmt.Sprintf("%s %s %s", t.Move, t.Label, fsa.PayloadKey(t.Payload))
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the minimization of the local views before the composition
+package transforms_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	"github.com/its-hmny/Choreia/internal/diagnostics"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestMinimizeLocalView(t *testing.T) {
+	jobs, maybeNil := meta.ChanMetadata{Name: "jobs", Type: "int"}, meta.ChanMetadata{Name: "jobs", Type: "int", MayBeNil: true}
+
+	// After the first move both the branches send on the same channel and then terminate, so they're merged. The
+	// receive on the same channel is a different move, and so is the send on the channel that may be nil
+	localView := fsa.NewBuilder().
+		State("start").On(fsa.Send, "jobs", jobs).To("left").
+		State("start").On(fsa.Recv, "jobs", jobs).To("right").
+		On(fsa.Send, "jobs", jobs).To("rightEnd").
+		State("left").On(fsa.Send, "jobs", jobs).To("leftEnd").
+		State("start").On(fsa.Send, "jobs", maybeNil).To("nil").
+		Final("leftEnd").Final("rightEnd").Final("nil").Build()
+
+	expected := `final 2
+0 -> 1 Recv "jobs"
+0 -> 1 Send "jobs"
+0 -> 2 Send "jobs"
+1 -> 2 Send "jobs"
+`
+	if text, _ := transforms.MinimizeLocalView(localView).MarshalText(); string(text) != expected {
+		t.Errorf("expected the minimal local view\n%s\ngot\n%s", expected, text)
+	}
+}
+
+func TestMinimizedComposition(t *testing.T) {
+	programs, err := filepath.Glob(filepath.Join("..", "..", "example", "*.go"))
+	if err != nil || len(programs) == 0 {
+		t.Fatalf("no program found in the example corpus (%v)", err)
+	}
+
+	// The Choreography Automata composed from the minimal local views is bisimilar to the original one, and never bigger
+	for _, programPath := range programs {
+		name := strings.TrimSuffix(filepath.Base(programPath), ".go")
+		fileMetadata := meta.ExtractMetadata(programPath, meta.NoTrace)
+
+		localViews, minimalViews := transforms.ExtractGoroutineFSA(fileMetadata), transforms.ExtractGoroutineFSA(fileMetadata)
+		for participant, lView := range localViews {
+			lView.Automaton = transforms.SubsetConstruction(lView.Automaton)
+			minimalViews[participant].Automaton = transforms.MinimizeLocalView(transforms.SubsetConstruction(minimalViews[participant].Automaton))
+		}
+		choreography, minimized := transforms.LocalViewsComposition(localViews), transforms.LocalViewsComposition(minimalViews)
+
+		if issues := diagnostics.CheckConformance(minimized, choreography, diagnostics.Bisimulation); len(issues) > 0 {
+			t.Errorf("%s: expected the compositions to be bisimilar, got %v", name, issues[0])
+		}
+		if original, reduced := countStates(choreography), countStates(minimized); reduced > original {
+			t.Errorf("%s: expected at most %d states, got %d", name, original, reduced)
+		}
+	}
+}
+
+// Returns the number of states of the given automaton
+func countStates(automaton *fsa.FSA) int {
+	n := 0
+	automaton.ForEachState(func(int) { n++ })
+	return n
+}
@@ -0,0 +1,67 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package transforms declares the types and functions used to transform and work with some type of FSA.
+// Come of the transformation implemented here are standard such as determinization (Subset Construction),
+// minimization but more are specifically related to Choreia (GoroutineFSA extraction & Composition)
+//
+package transforms
+
+import (
+	"sort"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+)
+
+// The name of the local view that models the clients of the network handlers
+const NetworkName = "network"
+
+// Returns the local view of the external participant that drives the network handlers found in the file
+// (see static_analysis.EnableNetworkHandlers), nil if there's none. The network starts every handler and
+// then, forever, chooses one of the endpoints: it sends the request to the latter and waits for its
+// response. Since the network starts along with "main" the handlers serve the requests independently from
+// the rest of the program, while the registration itself is not modeled
+func ExtractNetwork(file meta.FileMetadata) *GoroutineFSA {
+	handlers := []meta.FuncMetadata{}
+	for _, function := range file.FunctionMeta {
+		if function.Endpoint != "" {
+			handlers = append(handlers, function)
+		}
+	}
+
+	if len(handlers) == 0 {
+		return nil
+	}
+	sort.Slice(handlers, func(i, j int) bool { return handlers[i].Name < handlers[j].Name })
+
+	network := GoroutineFSA{
+		Name: NetworkName,
+		FuncMetadata: meta.FuncMetadata{
+			Name:       NetworkName,
+			ChanMeta:   make(map[string]meta.ChanMetadata),
+			InlineArgs: []meta.FuncArg{},
+			Automaton:  fsa.New(),
+		},
+	}
+
+	// At first every handler is started, one after the other
+	for i, handler := range handlers {
+		network.Automaton.AddTransition(i, i+1, fsa.Transition{Move: fsa.Spawn, Label: handler.Name})
+	}
+
+	// Then the requests are sent from the (final) state reached
+	serving := len(handlers)
+	for i, handler := range handlers {
+		request, response := meta.NetworkChannels(handler.Endpoint)
+		network.ChanMeta[request.Name], network.ChanMeta[response.Name] = request, response
+
+		waiting := serving + i + 1
+		network.Automaton.AddTransition(serving, waiting, fsa.Transition{Move: fsa.Send, Label: request.Name, Payload: request})
+		network.Automaton.AddTransition(waiting, serving, fsa.Transition{Move: fsa.Recv, Label: response.Name, Payload: response})
+	}
+	network.Automaton.SetFinalState(serving)
+
+	return &network
+}
@@ -0,0 +1,56 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the network participant that drives the HTTP/gRPC handlers
+package transforms_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+const handlersSource = `package main
+import "net/http"
+var audit = make(chan string)
+func handleOrders(w http.ResponseWriter, r *http.Request) {
+	audit <- "order"
+}
+func main() {
+	http.HandleFunc("/orders", handleOrders)
+	for {
+		<-audit
+	}
+}
+`
+
+func TestNetworkHandlers(t *testing.T) {
+	meta.EnableNetworkHandlers()
+	path := filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(path, []byte(handlersSource), 0664); err != nil {
+		t.Fatal(err)
+	}
+
+	localViews := transforms.ExtractGoroutineFSA(meta.ExtractMetadata(path, meta.NoTrace))
+	if _, exist := localViews[transforms.NetworkName]; !exist || len(localViews) != 3 {
+		t.Fatalf("expected the local views of main, the network and the handler, got %d", len(localViews))
+	}
+	for _, lView := range localViews {
+		lView.Automaton = transforms.SubsetConstruction(lView.Automaton)
+	}
+
+	expected := []string{
+		"network → network/http /orders#1: /orders request<request>",
+		"network △ network/http /orders#1",
+		"network/http /orders#1 → main: audit<string>",
+		"network/http /orders#1 → network: /orders response<response>",
+	}
+	if labels := interactionLabels(transforms.LocalViewsComposition(localViews)); !reflect.DeepEqual(labels, expected) {
+		t.Errorf("expected the interactions %v, got %v", expected, labels)
+	}
+}
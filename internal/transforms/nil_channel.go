@@ -0,0 +1,35 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package transforms declares the types and functions used to transform and work with some type of FSA.
+// Come of the transformation implemented here are standard such as determinization (Subset Construction),
+// minimization but more are specifically related to Choreia (GoroutineFSA extraction & Composition)
+//
+package transforms
+
+import (
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+)
+
+// Returns a copy of the given local view without the Send/Recv transitions on channels that may be nil
+// (see ChanMetadata.MayBeNil). An operation on a nil channel blocks forever, so a select branch on such
+// channel is disabled: excluding the latter the composition only considers the branches that are always
+// enabled. The states left unreachable by the removed transitions are pruned as well
+func ExcludeNilChannelOps(automaton *fsa.FSA) *fsa.FSA {
+	automatonCopy := automaton.Copy()
+
+	automatonCopy.ForEachTransition(func(from, to int, t fsa.Transition) {
+		if t.Move != fsa.Send && t.Move != fsa.Recv {
+			return
+		}
+
+		if channelMeta, isChanMeta := t.Payload.(meta.ChanMetadata); isChanMeta && channelMeta.MayBeNil {
+			automatonCopy.RemoveTransition(from, to, t)
+		}
+	})
+
+	automatonCopy.Prune()
+	return automatonCopy
+}
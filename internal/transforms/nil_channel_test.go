@@ -0,0 +1,46 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the exclusion of the operations on the channels that may be nil,
+// the program analyzed is the "disabled select branch" one of the example corpus
+package transforms_test
+
+import (
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestExcludeNilChannelOps(t *testing.T) {
+	fileMetadata := meta.ExtractMetadata("../../example/NilChannelSelect.go", meta.NoTrace)
+	mainView := transforms.ExtractGoroutineFSA(fileMetadata)[transforms.MainName]
+
+	countRecv := func(automaton *fsa.FSA) int {
+		nRecv := 0
+		automaton.ForEachTransition(func(_, _ int, tr fsa.Transition) {
+			if tr.Move != fsa.Recv {
+				return
+			}
+			if channelMeta, _ := tr.Payload.(meta.ChanMetadata); !channelMeta.MayBeNil {
+				t.Errorf("recv on '%s' is not annotated as MayBeNil", tr.Label)
+			}
+			nRecv++
+		})
+		return nRecv
+	}
+
+	if nRecv := countRecv(mainView.Automaton); nRecv != 2 {
+		t.Fatalf("expected 2 recv transitions in the local view, got %d", nRecv)
+	}
+
+	excluded := transforms.ExcludeNilChannelOps(mainView.Automaton)
+	if nRecv := countRecv(excluded); nRecv != 0 {
+		t.Errorf("expected no recv transition after the exclusion, got %d", nRecv)
+	}
+	if nRecv := countRecv(mainView.Automaton); nRecv != 2 {
+		t.Errorf("the original local view has been modified, %d recv transitions left", nRecv)
+	}
+}
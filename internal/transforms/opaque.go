@@ -0,0 +1,80 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package transforms declares the types and functions used to transform and work with some type of FSA.
+// Come of the transformation implemented here are standard such as determinization (Subset Construction),
+// minimization but more are specifically related to Choreia (GoroutineFSA extraction & Composition)
+//
+package transforms
+
+import (
+	"fmt"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+)
+
+// The moves that an opaque participant can make on each channel passed to it, set with SetOpaqueMoves
+var opaqueMoves = []fsa.MoveKind{fsa.Send, fsa.Recv}
+
+// Sets the moves (Send and/or Recv) that the opaque participants can make on their channels, with none of
+// them the spawns of the external functions are replaced with an eps transition (as the ones without channels)
+func SetOpaqueMoves(moves []fsa.MoveKind) {
+	opaqueMoves = moves
+}
+
+// Parses the moves of the opaque participants given on the CLI (both|send|recv|none)
+func ParseOpaqueMoves(name string) ([]fsa.MoveKind, error) {
+	switch name {
+	case "both":
+		return []fsa.MoveKind{fsa.Send, fsa.Recv}, nil
+	case "send":
+		return []fsa.MoveKind{fsa.Send}, nil
+	case "recv":
+		return []fsa.MoveKind{fsa.Recv}, nil
+	case "none":
+		return []fsa.MoveKind{}, nil
+	}
+	return nil, fmt.Errorf("unknown opaque moves '%s' (expected both, send, recv or none)", name)
+}
+
+// Returns the local view of a Goroutine spawned from an external function whose source (or stub) isn't available
+// (e.g "go io.Copy(w, r)" or "go http.Serve(l, h)"), when some channel is passed to it. Since its behaviour is
+// unknown the participant is opaque: a single (final) state that can make at any time the opaqueMoves on each
+// one of the channels, so that the composition accounts for the messages it may exchange instead of losing them.
+// The channels are looked up in the given ones of the spawner, while the participant is named by the caller (see
+// goroutineName) as the other Goroutines. Returns nil if no channel is passed to the function (or no move is allowed)
+func opaqueParticipant(function string, actualArgs []meta.FuncArg, spawnerChannels map[string]meta.ChanMetadata) *GoroutineFSA {
+	opaque := GoroutineFSA{
+		FuncMetadata: meta.FuncMetadata{
+			Name:       function,
+			ChanMeta:   make(map[string]meta.ChanMetadata),
+			InlineArgs: []meta.FuncArg{},
+			Automaton:  fsa.New(),
+		},
+	}
+
+	// The transitions are added in the order of the arguments, since the order of the parallel ones
+	// determines the order in which the couples are visited during the composition
+	for _, arg := range actualArgs {
+		if arg.Type != meta.Channel {
+			continue
+		}
+		channelMeta, exist := spawnerChannels[arg.Name]
+		if !exist {
+			channelMeta = meta.ChanMetadata{Name: arg.Name}
+		}
+		opaque.ChanMeta[arg.Name] = channelMeta
+
+		for _, move := range opaqueMoves {
+			opaque.Automaton.AddTransition(0, 0, fsa.Transition{Move: move, Label: arg.Name, Payload: channelMeta})
+		}
+	}
+
+	if len(opaque.ChanMeta) == 0 || len(opaqueMoves) == 0 {
+		return nil
+	}
+	opaque.Automaton.SetFinalState(0)
+	return &opaque
+}
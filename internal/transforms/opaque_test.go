@@ -0,0 +1,58 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the opaque participants spawned from the external functions
+package transforms_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestOpaqueParticipant(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.go")
+	source := `package main
+import "pipe"
+func main() {
+	in, out := make(chan int), make(chan int)
+	go pipe.Forward(in, out)
+	go pipe.Log("started")
+	in <- 1
+	<-out
+}
+`
+	if err := os.WriteFile(path, []byte(source), 0664); err != nil {
+		t.Fatal(err)
+	}
+	localViews := transforms.ExtractGoroutineFSA(meta.ExtractMetadata(path, meta.NoTrace))
+
+	// The spawn without channels is dropped, the other one can send and receive on both its channels
+	opaque, exist := localViews["main/pipe.Forward@main.go:5#1"]
+	if !exist || len(localViews) != 2 {
+		t.Fatalf("expected main and an opaque participant, got %v", localViews)
+	}
+	expected := "final 0\n0 -> 0 Recv \"in\"\n0 -> 0 Recv \"out\"\n0 -> 0 Send \"in\"\n0 -> 0 Send \"out\"\n"
+	if text, _ := opaque.Automaton.MarshalText(); string(text) != expected || opaque.FuncMetadata.Name != "pipe.Forward" {
+		t.Errorf("expected the opaque local view\n%s\ngot\n%s", expected, text)
+	}
+
+	for _, lView := range localViews {
+		lView.Automaton = transforms.SubsetConstruction(lView.Automaton)
+	}
+	if choreography := transforms.LocalViewsComposition(localViews); choreography.FinalStates.Size() == 0 {
+		t.Errorf("expected main to exchange its messages with the opaque participant, got\n%s", choreography)
+	}
+
+	// Without any move allowed the spawn is dropped as the others
+	transforms.SetOpaqueMoves(nil)
+	defer transforms.SetOpaqueMoves([]fsa.MoveKind{fsa.Send, fsa.Recv})
+	if localViews := transforms.ExtractGoroutineFSA(meta.ExtractMetadata(path, meta.NoTrace)); len(localViews) != 1 {
+		t.Errorf("expected only main without opaque moves, got %v", localViews)
+	}
+}
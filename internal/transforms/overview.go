@@ -0,0 +1,103 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package transforms declares the types and functions used to transform and work with some type of FSA.
+// Come of the transformation implemented here are standard such as determinization (Subset Construction),
+// minimization but more are specifically related to Choreia (GoroutineFSA extraction & Composition)
+//
+package transforms
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/goccy/go-graphviz"
+	"github.com/goccy/go-graphviz/cgraph"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+)
+
+// Exports the given local views in a single "system overview" diagram, each GoroutineFSA is rendered as
+// a cluster (a boxed subgraph) and every Spawn transition is linked with a dashed edge to the initial
+// state of the spawned Goroutine. In this way the spawn tree computed by ExtractGoroutineFSA is shown
+// together with the local views instead of having N disconnected files. An error is returned if the rendering fails
+func ExportSystemOverview(localViews map[string]*GoroutineFSA, outputFile string, format graphviz.Format) error {
+	graph := fsa.NewGraphvizExporter()
+	DrawSystemOverview(localViews, graph)
+
+	// Creates an export in the format requested at the given path
+	return graph.Render(outputFile, format)
+}
+
+// Adds the system overview of the given local views (see ExportSystemOverview) to the given graph
+func DrawSystemOverview(localViews map[string]*GoroutineFSA, graph fsa.Exporter) {
+	// Sorts the local views by name, so that the clusters are always rendered in the same order
+	viewNames := make([]string, 0, len(localViews))
+	for name := range localViews {
+		viewNames = append(viewNames, name)
+	}
+	sort.Strings(viewNames)
+
+	for i, name := range viewNames {
+		// Graphviz draws as a box only the subgraphs whose name starts with "cluster"
+		cluster := fmt.Sprintf("cluster_%d", i)
+		graph.AddCluster(cluster, name)
+		drawCluster(graph, cluster, name, localViews[name].Automaton)
+	}
+
+	// Links each Spawn transition to the initial state of the spawned Goroutine
+	for _, name := range viewNames {
+		localViews[name].Automaton.ForEachTransition(func(from, _ int, t fsa.Transition) {
+			spawned, isSpawned := localViews[t.Label]
+			if t.Move != fsa.Spawn || !isSpawned {
+				return
+			}
+
+			graph.AddEdge(fsa.GraphEdge{
+				Id:    fmt.Sprintf("%s/%d-%s", name, from, t.Label),
+				From:  overviewNode(name, from),
+				To:    overviewNode(t.Label, spawned.Automaton.InitialState()),
+				Style: cgraph.DashedEdgeStyle,
+			})
+		})
+	}
+}
+
+// Returns the id of the node of the given state in the overview, the node names are prefixed
+// with the name of the local view since they must be unique in the whole graph
+func overviewNode(name string, stateId int) string {
+	return fmt.Sprintf("%s/%d", name, stateId)
+}
+
+// Copies the states and the transitions of the given automaton in the cluster, each node
+// is labeled with the id of the state it represents
+func drawCluster(graph fsa.Exporter, cluster, name string, automaton *fsa.FSA) {
+	automaton.ForEachState(func(stateId int) {
+		node := fsa.GraphNode{Id: overviewNode(name, stateId), Cluster: cluster, Label: fmt.Sprint(stateId), Shape: cgraph.CircleShape}
+		if automaton.IsFinalState(stateId) {
+			node.Shape = cgraph.DoubleCircleShape
+		}
+		graph.AddNode(node)
+	})
+
+	// Graphviz doesn't support parallel edges, so the latter are "squashed" in a single edge
+	// whose label has a line for each transition (as done by FSA.Export)
+	type edgeKey struct{ from, to int }
+	edgeKeys, parallelT := []edgeKey{}, make(map[edgeKey][]fsa.Transition)
+
+	automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+		key := edgeKey{from, to}
+		if _, exist := parallelT[key]; !exist {
+			edgeKeys = append(edgeKeys, key)
+		}
+		parallelT[key] = append(parallelT[key], t)
+	})
+
+	for _, key := range edgeKeys {
+		edgeId := fmt.Sprintf("%s/%d-%d", name, key.from, key.to)
+		edge := fsa.LabeledEdge(edgeId, overviewNode(name, key.from), overviewNode(name, key.to), parallelT[key])
+		edge.Cluster = cluster
+		graph.AddEdge(edge)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the system overview of the local views
+package transforms_test
+
+import (
+	"testing"
+
+	"github.com/goccy/go-graphviz/cgraph"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestDrawSystemOverview(t *testing.T) {
+	localViews := transforms.ExtractGoroutineFSA(meta.ExtractMetadata("../../example/PingPong.go", meta.NoTrace))
+
+	graph := fsa.NewMemoryGraph()
+	transforms.DrawSystemOverview(localViews, graph)
+
+	// A cluster for each local view, sorted by name
+	if len(graph.Clusters) != 3 || graph.Clusters["cluster_0"] != "main" {
+		t.Fatalf("expected a cluster for each local view, got %v", graph.Clusters)
+	}
+	for _, node := range graph.Nodes {
+		if node.Cluster == "" {
+			t.Errorf("expected every state to be in the cluster of its local view, got %v", node)
+		}
+	}
+
+	// Each player is spawned by main, the spawn is linked to the initial state of the player
+	spawns := 0
+	for _, edge := range graph.Edges {
+		if edge.Style == cgraph.DashedEdgeStyle {
+			spawns++
+			if edge.Cluster != "" || edge.To != "main/player@PingPong.go:20#1/0" && edge.To != "main/player@PingPong.go:21#1/0" {
+				t.Errorf("expected the spawn edges to link main to the players, got %v", edge)
+			}
+		}
+	}
+	if spawns != 2 {
+		t.Errorf("expected 2 spawn edges, got %d", spawns)
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package transforms declares the types and functions used to transform and work with some type of FSA.
+// Come of the transformation implemented here are standard such as determinization (Subset Construction),
+// minimization but more are specifically related to Choreia (GoroutineFSA extraction & Composition)
+//
+package transforms
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"sort"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+)
+
+// The name of the local view that stands for the participants left out of a partial composition
+const OthersName = "others"
+
+// ----------------------------------------------------------------------------
+// Partial composition
+
+// Returns true if the given pattern (see path.Match) matches the name of the participant or the function from
+// which it has been spawned (e.g both "worker*" and "main/worker@*" match "main/worker@main.go:9#1")
+func MatchParticipant(pattern, participant string) (bool, error) {
+	if isMatch, err := path.Match(pattern, participant); isMatch || err != nil {
+		return isMatch, err
+	}
+	if function, isGoroutine := goroutineFunction(participant); isGoroutine {
+		return path.Match(pattern, function)
+	}
+	return false, nil
+}
+
+// Returns the local views of the participants matching at least one of the given patterns (see MatchParticipant),
+// the environment is kept as well since it isn't a Goroutine. The participants left out are replaced by a single one
+// (named OthersName) ready at any time to make the operations complementary to theirs on the channels shared with the
+// selected ones, so that the latter aren't blocked by the missing peers. The "others" local view is omitted when
+// there's no such channel. An error is returned for an invalid pattern or when no participant is selected
+func SelectParticipants(localViews map[string]*GoroutineFSA, patterns []string) (map[string]*GoroutineFSA, error) {
+	selected, leftOut := make(map[string]*GoroutineFSA), make(map[string]*GoroutineFSA)
+
+	for name, lView := range localViews {
+		isSelected := name == EnvironmentName
+		for _, pattern := range patterns {
+			isMatch, err := MatchParticipant(pattern, name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid participant pattern '%s': %s", pattern, err)
+			}
+			isSelected = isSelected || isMatch
+		}
+
+		if isSelected {
+			selected[name] = lView
+		} else {
+			leftOut[name] = lView
+		}
+	}
+
+	if len(selected) == 0 || (len(selected) == 1 && selected[EnvironmentName] != nil) {
+		return nil, fmt.Errorf("no participant matches the patterns %v", patterns)
+	}
+	if others := extractOthers(selected, leftOut); others != nil {
+		selected[others.Name] = others
+	}
+	return selected, nil
+}
+
+// Composes only the local views selected by the given patterns (see SelectParticipants) and returns the partial
+// Choreography Automata along with the local views composed. The composition starts from the selected participants
+// that aren't spawned by another selected one, each one in its initial state (e.g a worker selected without main
+// is running from the start), its configurations are visited in the order given by the strategy (see ExplorationStrategy).
+// The execution is stopped if the patterns are invalid or don't match any participant
+func PartialComposition(localViews map[string]*GoroutineFSA, patterns []string, strategy ExplorationStrategy) (*fsa.FSA, map[string]*GoroutineFSA) {
+	selected, err := SelectParticipants(localViews, patterns)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	spawners, initial := spawnersOf(selected), Configuration{}
+	for name, lView := range selected {
+		// The environment and the others are always in their only state (see systemStateOf)
+		if name == EnvironmentName || name == OthersName {
+			continue
+		}
+		if _, isSpawned := spawners[name]; !isSpawned {
+			initial[name] = lView.Automaton.InitialState()
+		}
+	}
+
+	return LocalViewsCompositionWith(selected, initial, strategy), selected
+}
+
+// Returns the local view that stands for the left out participants: a single (final) state that can make at any
+// time the operations of the latter on the channels shared with the selected ones. A send of a left out participant
+// is kept only if a selected participant receives on the same channel (and the opposite), nil if there's none
+func extractOthers(selected, leftOut map[string]*GoroutineFSA) *GoroutineFSA {
+	selectedOps, leftOutOps := channelOperations(selected), channelOperations(leftOut)
+
+	// The operation of the others is the one complementary to a selected participant
+	complementary := map[fsa.MoveKind]fsa.MoveKind{fsa.Send: fsa.Recv, fsa.Recv: fsa.Send}
+	operations := []fsa.Transition{}
+	for _, op := range sortedOperations(leftOutOps) {
+		if _, isShared := selectedOps[operationKey{complementary[op.move], op.channel}]; isShared {
+			channelMeta := leftOutOps[op]
+			channelMeta.MayBeNil = false
+			operations = append(operations, fsa.Transition{Move: op.move, Label: op.channel, Payload: channelMeta})
+		}
+	}
+
+	if len(operations) == 0 {
+		return nil
+	}
+
+	others := GoroutineFSA{
+		Name: OthersName,
+		FuncMetadata: meta.FuncMetadata{
+			Name:       OthersName,
+			ChanMeta:   map[string]meta.ChanMetadata{},
+			InlineArgs: []meta.FuncArg{},
+			Automaton:  fsa.New(),
+		},
+	}
+	for _, t := range operations {
+		others.ChanMeta[t.Label] = t.Payload.(meta.ChanMetadata)
+		others.Automaton.AddTransition(0, 0, t)
+	}
+	others.Automaton.SetFinalState(0)
+
+	return &others
+}
+
+// The key of a channel operation made by some local view: its Move (Send or Recv) and the channel
+type operationKey struct {
+	move    fsa.MoveKind
+	channel string
+}
+
+// Returns the channel operations made by the given local views, each one with the metadata of the channel
+func channelOperations(localViews map[string]*GoroutineFSA) map[operationKey]meta.ChanMetadata {
+	operations := make(map[operationKey]meta.ChanMetadata)
+	for _, lView := range localViews {
+		lView.Automaton.ForEachTransition(func(_, _ int, t fsa.Transition) {
+			if channelMeta, isChanMeta := t.Payload.(meta.ChanMetadata); isChanMeta && (t.Move == fsa.Send || t.Move == fsa.Recv) {
+				operations[operationKey{t.Move, t.Label}] = channelMeta
+			}
+		})
+	}
+	return operations
+}
+
+// Returns the keys of the given operations sorted by channel (and then by Move), since the order of the
+// parallel transitions determines the order in which the couples are visited during the composition
+func sortedOperations(operations map[operationKey]meta.ChanMetadata) []operationKey {
+	keys := make([]operationKey, 0, len(operations))
+	for key := range operations {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].channel != keys[j].channel {
+			return keys[i].channel < keys[j].channel
+		}
+		return keys[i].move < keys[j].move
+	})
+	return keys
+}
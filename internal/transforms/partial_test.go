@@ -0,0 +1,74 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the composition of a subset of the participants
+package transforms_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestSelectParticipants(t *testing.T) {
+	localViews := transforms.ExtractGoroutineFSA(meta.ExtractMetadata("../../example/PingPong.go", meta.NoTrace))
+
+	selected, err := transforms.SelectParticipants(localViews, []string{"player"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	others, hasOthers := selected[transforms.OthersName]
+	if _, hasMain := selected["main"]; len(selected) != 3 || hasMain || !hasOthers {
+		t.Fatalf("expected both the players along with the others, got %d local view(s)", len(selected))
+	}
+
+	// The others stand for main, that serves the ball to a player and receives it back from the other one
+	operations := []string{}
+	others.Automaton.ForEachTransition(func(_, _ int, tr fsa.Transition) {
+		operations = append(operations, tr.Move.Symbol()+tr.Label)
+	})
+	if expected := []string{"→ping", "←pong"}; !reflect.DeepEqual(operations, expected) {
+		t.Errorf("expected the operations %v for the others, got %v", expected, operations)
+	}
+
+	if _, err := transforms.SelectParticipants(localViews, []string{"worker*"}); err == nil {
+		t.Errorf("expected an error when no participant matches the patterns")
+	}
+	if _, err := transforms.SelectParticipants(localViews, []string{"[player"}); err == nil {
+		t.Errorf("expected an error for an invalid pattern")
+	}
+}
+
+func TestPartialComposition(t *testing.T) {
+	const player = "main/player@PingPong.go:20#1"
+
+	localViews := transforms.ExtractGoroutineFSA(meta.ExtractMetadata("../../example/PingPong.go", meta.NoTrace))
+	for _, lView := range localViews {
+		lView.Automaton = transforms.SubsetConstruction(lView.Automaton)
+	}
+
+	automaton, composed := transforms.PartialComposition(localViews, []string{player}, transforms.BreadthFirst)
+	if _, hasOthers := composed[transforms.OthersName]; len(composed) != 2 || !hasOthers {
+		t.Fatalf("expected the player along with the others, got %d local view(s)", len(composed))
+	}
+
+	// The player starts on its own, the others (main and the other player) serve and return the ball
+	interactions := []string{}
+	automaton.ForEachTransition(func(from, to int, tr fsa.Transition) {
+		if interaction, isInteraction := tr.Payload.(transforms.Interaction); isInteraction {
+			interactions = append(interactions, interaction.From+" -> "+interaction.To+": "+interaction.Channel.Name)
+		}
+	})
+	expected := []string{
+		"others -> " + player + ": ping",
+		player + " -> others: pong",
+		"others -> " + player + ": ping",
+	}
+	if !reflect.DeepEqual(interactions, expected) {
+		t.Errorf("expected the interactions\n%v\ngot\n%v", expected, interactions)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package transforms declares the types and functions used to transform and work with some type of FSA.
+// Come of the transformation implemented here are standard such as determinization (Subset Construction),
+// minimization but more are specifically related to Choreia (GoroutineFSA extraction & Composition)
+//
+package transforms
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	"github.com/its-hmny/Choreia/internal/logging"
+)
+
+// The name given to the single participant that replaces a family of merged Goroutines (e.g "worker (*)")
+const replicatedTemplate = "%s (*)"
+
+// ----------------------------------------------------------------------------
+// ParticipantDirectives
+
+// The user provided directives about how the participants are named in the output
+//
+// A participant can be renamed explicitly (e.g "main/worker@main.go:9#3" becomes "logger") while the Goroutines
+// spawned from the same function can be merged in a single replicated participant (e.g the
+// "main/worker@main.go:9#1" and "main/worker@main.go:9#2" become "worker (*)"). The name of a replicated participant can be renamed as well
+type ParticipantDirectives struct {
+	Renames map[string]string // The new name of each renamed participant
+	Merges  map[string]bool   // The functions whose Goroutines are merged in a single participant
+}
+
+// Parses the directives given in textual form: the renames are "old=new" pairs while the merges
+// are the names of the functions whose Goroutines are merged, an error is returned on malformed pairs
+func ParseParticipantDirectives(renames, merges []string) (ParticipantDirectives, error) {
+	directives := ParticipantDirectives{Renames: make(map[string]string), Merges: make(map[string]bool)}
+
+	for _, rename := range renames {
+		pair := strings.SplitN(rename, "=", 2)
+		if len(pair) != 2 || strings.TrimSpace(pair[0]) == "" || strings.TrimSpace(pair[1]) == "" {
+			return directives, fmt.Errorf("malformed rename directive '%s', expected 'old=new'", rename)
+		}
+		directives.Renames[strings.TrimSpace(pair[0])] = strings.TrimSpace(pair[1])
+	}
+
+	for _, merge := range merges {
+		directives.Merges[strings.TrimSpace(merge)] = true
+	}
+
+	return directives, nil
+}
+
+// Returns true if there's no directive at all, in that case the output is left untouched
+func (pd ParticipantDirectives) Empty() bool {
+	return len(pd.Renames) == 0 && len(pd.Merges) == 0
+}
+
+// Returns the name that the given participant has in the output, an explicit rename has the
+// precedence over the merge of the family (then the name of the family can be renamed as well)
+func (pd ParticipantDirectives) Name(participant string) string {
+	if newName, isRenamed := pd.Renames[participant]; isRenamed {
+		return newName
+	}
+
+	if function, isGoroutine := goroutineFunction(participant); isGoroutine && pd.Merges[function] {
+		replicated := fmt.Sprintf(replicatedTemplate, function)
+		if newName, isRenamed := pd.Renames[replicated]; isRenamed {
+			return newName
+		}
+		return replicated
+	}
+
+	return participant
+}
+
+// Returns the name of the function from which the Goroutine with the given name has been spawned, that is
+// the last one of its spawn path (e.g "worker" for "main/worker@main.go:42#3"), the second value is false for
+// any other name. The functions whose name contains a slash (e.g the network handlers) aren't told apart
+func goroutineFunction(participant string) (string, bool) {
+	if _, _, isGoroutine := spawnInstance(participant); !isGoroutine {
+		return "", false
+	}
+
+	path := spawnPath(participant)
+	return path[strings.LastIndex(path, "/")+1:], true
+}
+
+// Splits the name of a Goroutine in its spawn path and site (e.g "main/worker@main.go:42") and its
+// instance number (e.g 3 for "main/worker@main.go:42#3"), the last value is false for any other name
+func spawnInstance(participant string) (string, int, bool) {
+	hash := strings.LastIndex(participant, "#")
+	if hash == -1 || !strings.Contains(participant, "/") {
+		return "", 0, false
+	}
+
+	instance, err := strconv.Atoi(participant[hash+1:])
+	if err != nil {
+		return "", 0, false
+	}
+	return participant[:hash], instance, true
+}
+
+// ----------------------------------------------------------------------------
+// Renaming & merging
+
+// Returns a copy of the given Choreography Automata where the participants of each interaction are
+// named as stated by the directives, the labels are generated again with the given verbosity. The
+// interactions of the merged Goroutines become the same one and so they're kept only once
+func RenameParticipants(choreography *fsa.FSA, directives ParticipantDirectives, verbosity LabelVerbosity) *fsa.FSA {
+	renamed := choreography.Copy()
+
+	renamed.ForEachTransition(func(from, to int, t fsa.Transition) {
+		interaction, isInteraction := t.Payload.(Interaction)
+		if !isInteraction {
+			return
+		}
+
+		interaction.From, interaction.To = directives.Name(interaction.From), directives.Name(interaction.To)
+		newT := fsa.Transition{Move: t.Move, Label: interaction.Label(verbosity), Payload: interaction}
+		renamed.RemoveTransition(from, to, t)
+		renamed.AddTransition(from, to, newT)
+	})
+
+	return renamed
+}
+
+// Returns a copy of the given local views named as stated by the directives, the Spawn transitions
+// are relabeled with the new name of the spawned Goroutine as well. Of a family of merged Goroutines only
+// the local view of the first one (by name) is kept, if the others have a different automaton a warning
+// is logged since the replicated participant doesn't show them
+func RenameLocalViews(localViews map[string]*GoroutineFSA, directives ParticipantDirectives) map[string]*GoroutineFSA {
+	names := make([]string, 0, len(localViews))
+	for name := range localViews {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	renamedViews := make(map[string]*GoroutineFSA, len(localViews))
+	firstInstance := make(map[string]string)
+
+	for _, name := range names {
+		renamedView := *localViews[name]
+		renamedView.Name = directives.Name(name)
+		renamedView.Automaton = localViews[name].Automaton.Copy()
+
+		renamedView.Automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+			if newLabel := directives.Name(t.Label); t.Move == fsa.Spawn && newLabel != t.Label {
+				renamedView.Automaton.RemoveTransition(from, to, t)
+				renamedView.Automaton.AddTransition(from, to, fsa.Transition{Move: t.Move, Label: newLabel, Payload: t.Payload})
+			}
+		})
+
+		if first, isMerged := firstInstance[renamedView.Name]; isMerged {
+			if !sameAutomaton(renamedViews[renamedView.Name].Automaton, renamedView.Automaton) {
+				logging.Warnf("The local view of %s differs from the one of %s, only the latter is shown as %s", name, first, renamedView.Name)
+			}
+			continue
+		}
+
+		firstInstance[renamedView.Name] = name
+		renamedViews[renamedView.Name] = &renamedView
+	}
+
+	return renamedViews
+}
+
+// Returns true if the two automata have the same states and transitions (payloads excluded)
+func sameAutomaton(automatonA, automatonB *fsa.FSA) bool {
+	textA, errA := automatonA.MarshalText()
+	textB, errB := automatonB.MarshalText()
+	return errA == nil && errB == nil && string(textA) == string(textB)
+}
@@ -0,0 +1,67 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the renaming and merging of the participants in the output
+package transforms_test
+
+import (
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestParticipantDirectives(t *testing.T) {
+	directives, err := transforms.ParseParticipantDirectives([]string{"main/worker@main.go:9#3=logger", "player (*)=players"}, []string{"worker", "player"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"main/worker@main.go:9#3":            "logger",
+		"main/worker@main.go:9#1":            "worker (*)",
+		"main/dispatcher/worker@main.go:4#1": "worker (*)",
+		"main/player@main.go:12#2":           "players",
+		"main":                               "main",
+		"environment":                        "environment",
+	}
+	for participant, expected := range cases {
+		if name := directives.Name(participant); name != expected {
+			t.Errorf("expected '%s' to be named '%s', got '%s'", participant, expected, name)
+		}
+	}
+
+	if _, err := transforms.ParseParticipantDirectives([]string{"logger"}, nil); err == nil {
+		t.Error("expected an error for a rename directive without '='")
+	}
+}
+
+func TestRenameParticipants(t *testing.T) {
+	fileMetadata := meta.ExtractMetadata("../../example/PingPong.go", meta.NoTrace)
+	localViews := transforms.ExtractGoroutineFSA(fileMetadata)
+	for _, lView := range localViews {
+		lView.Automaton = transforms.SubsetConstruction(lView.Automaton)
+	}
+	choreography := transforms.LocalViewsComposition(localViews)
+
+	directives, _ := transforms.ParseParticipantDirectives([]string{"main=referee"}, []string{"player"})
+	renamed := transforms.RenameParticipants(choreography, directives, transforms.FullLabels)
+
+	renamed.ForEachTransition(func(_, _ int, tr fsa.Transition) {
+		interaction := tr.Payload.(transforms.Interaction)
+		for _, participant := range []string{interaction.From, interaction.To} {
+			if participant != "referee" && participant != "player (*)" {
+				t.Errorf("unexpected participant '%s' in '%s'", participant, tr.Label)
+			}
+		}
+		if tr.Label != interaction.Label(transforms.FullLabels) {
+			t.Errorf("the label '%s' doesn't match the renamed interaction", tr.Label)
+		}
+	})
+
+	if renamedViews := transforms.RenameLocalViews(localViews, directives); len(renamedViews) != 2 {
+		t.Errorf("expected the players to be merged in a single local view, got %d views", len(renamedViews))
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Property-based tests of the automata transformations, run on randomly generated FSAs
+package transforms_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+// The number of random FSAs (one per seed) and of words sampled from each one
+const (
+	randomAutomata = 200
+	sampledWords   = 60
+)
+
+// Returns the options of the random FSA of the given seed, the size and the densities vary from one to another
+func randomOptions(rng *rand.Rand) fsa.RandomOptions {
+	states := 1 + rng.Intn(12)
+	return fsa.RandomOptions{
+		States:          states,
+		Transitions:     rng.Intn(2 * states),
+		EpsDensity:      rng.Float64() * 0.5,
+		ParallelDensity: rng.Float64() * 0.3,
+		FinalDensity:    rng.Float64() * 0.4,
+	}
+}
+
+// Checks that the two automata agree on the words sampled from both of them and on some random ones
+func assertSameLanguage(t *testing.T, rng *rand.Rand, seed int64, original, transformed *fsa.FSA) {
+	t.Helper()
+	words := [][]fsa.Transition{}
+	for i := 0; i < sampledWords; i++ {
+		words = append(words, original.RandomWord(rng, 10), transformed.RandomWord(rng, 10))
+	}
+	// The words obtained mutating a sampled one are mostly rejected by both
+	for i := 0; i < sampledWords; i++ {
+		word := append([]fsa.Transition{}, original.RandomWord(rng, 10)...)
+		if len(word) > 0 {
+			word = append(word[:rng.Intn(len(word))], word[rng.Intn(len(word)):]...)
+		}
+		words = append(words, word)
+	}
+
+	for _, word := range words {
+		if original.Accepts(word) != transformed.Accepts(word) {
+			t.Fatalf("seed %d: the automata disagree on %v (%v before, %v after)\n%s\n%s",
+				seed, word, original.Accepts(word), transformed.Accepts(word), original, transformed)
+		}
+	}
+}
+
+func TestSubsetConstructionProperties(t *testing.T) {
+	for seed := int64(0); seed < randomAutomata; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		nca := fsa.Random(rng, randomOptions(rng))
+		dca := transforms.SubsetConstruction(nca)
+
+		// Deterministic: no eps transition and no state with two outgoing transitions with the same symbol
+		outgoing := make(map[int][]fsa.Transition)
+		dca.ForEachTransition(func(from, _ int, tr fsa.Transition) {
+			if tr.Move == fsa.Eps {
+				t.Fatalf("seed %d: eps transition %d -> %v left in the DCA\n%s", seed, from, tr, dca)
+			}
+			for _, other := range outgoing[from] {
+				if other.Identical(tr) {
+					t.Fatalf("seed %d: state %d has two transitions %v\n%s", seed, from, tr, dca)
+				}
+			}
+			outgoing[from] = append(outgoing[from], tr)
+		})
+
+		assertSameLanguage(t, rng, seed, nca, dca)
+	}
+}
+
+func TestSimplifyProperties(t *testing.T) {
+	for seed := int64(0); seed < randomAutomata; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		automaton := fsa.Random(rng, randomOptions(rng))
+		simplified := transforms.Simplify(automaton)
+
+		// The states are renumbered from 0 and the eps chains contracted, the language is the same
+		if simplified.InitialState() != 0 {
+			t.Fatalf("seed %d: the initial state of the simplified FSA is %d", seed, simplified.InitialState())
+		}
+		assertSameLanguage(t, rng, seed, automaton, simplified)
+	}
+}
@@ -9,231 +9,427 @@
 package transforms
 
 import (
+	"container/heap"
 	"fmt"
 	"log"
-
-	list "github.com/emirpasic/gods/lists/singlylinkedlist"
-	set "github.com/emirpasic/gods/sets/hashset"
+	"sort"
 
 	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
 	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/tracing"
+)
+
+// ----------------------------------------------------------------------------
+// Exploration strategy
+
+const (
+	// ExplorationStrategy enum
+	BreadthFirst ExplorationStrategy = iota // The configurations closest to the initial one first (shortest traces)
+	DepthFirst                              // The configurations found last first (smallest frontier)
+	ByActivity                              // The configurations with less participants still active first
 )
 
-type ProductFSA *list.List // A list of (FrozenAutomata, FrozenAutomata) tuples
+// The names of the ExplorationStrategy values, as accepted by ParseExplorationStrategy
+var strategyNames = [...]string{"bfs", "dfs", "priority"}
+
+// Type alias to abstract the ExplorationStrategy enum, it defines the order in which the composition visits the
+// configurations of the system. The Choreography Automata obtained is the same with every strategy (only the ids
+// of its states change, they're assigned in the order the configurations are found) while the configurations
+// waiting to be visited are not: breadth-first keeps a whole level of them, depth-first only the ones along the
+// current path, each one with its siblings
+type ExplorationStrategy int
 
-// A struct representing a "frozen" state of an FSA
-type FrozenFSA struct {
-	localView *GoroutineFSA // The "frozen" Automata
-	state     int           // The state on which the automata is frozen
+// Converts the ExplorationStrategy to its name (e.g "dfs")
+func (es ExplorationStrategy) String() string {
+	if es < 0 || int(es) >= len(strategyNames) {
+		return fmt.Sprintf("ExplorationStrategy(%d)", int(es))
+	}
+	return strategyNames[es]
 }
 
-// A wildcard variable used as second item in a couple when needed
-var wildcard = FrozenFSA{&GoroutineFSA{Name: "Wildcard"}, -1}
+// Parses the name of an ExplorationStrategy (e.g "priority"), an error is returned for unknown names
+func ParseExplorationStrategy(name string) (ExplorationStrategy, error) {
+	for i, strategyName := range strategyNames {
+		if name == strategyName {
+			return ExplorationStrategy(i), nil
+		}
+	}
+	return BreadthFirst, fmt.Errorf("unknown exploration strategy '%s'", name)
+}
 
-// Utility function to iterate over every possible combination of transition (tA and tB) for
-// a given state of the Composition FSA which is a couple of states from different FSAs
-func forEachCoupleTransition(cFSA ProductFSA, f func(A, B FrozenFSA, tA, tB fsa.Transition, toA, toB int)) {
-	for _, item := range (*list.List)(cFSA).Values() {
-		// Preliminaries conversion and extraction
-		couple := item.(*set.Set)
-		values := couple.Values()
-		frozenA := values[0].(FrozenFSA)
-		frozenB := values[1].(FrozenFSA)
+// A configuration found by the composition and not visited yet, along with its state in the Choreography Automata
+// and the number of participants still active in it (spawned and not in a final state, see composer.activity)
+type pendingState struct {
+	state    systemState
+	id       int
+	activity int
+}
 
-		frozenA.localView.Automaton.ForEachTransition(func(fromA, toA int, tA fsa.Transition) {
-			frozenB.localView.Automaton.ForEachTransition(func(fromB, toB int, tB fsa.Transition) {
-				if fromA != frozenA.state || fromB != frozenB.state {
-					return
-				}
+// The configurations waiting to be visited by the composition, it implements heap.Interface so that the next one
+// is given by the strategy: the state ids follow the order the configurations are found, then the oldest is taken
+// first by breadth-first (as in a queue), the newest by depth-first (as in a stack) and the least active by priority
+type frontier struct {
+	strategy ExplorationStrategy
+	pending  []pendingState
+}
 
-				f(frozenA, frozenB, tA, tB, toA, toB)
-			})
-		})
+func (f *frontier) Len() int      { return len(f.pending) }
+func (f *frontier) Swap(i, j int) { f.pending[i], f.pending[j] = f.pending[j], f.pending[i] }
+
+func (f *frontier) Less(i, j int) bool {
+	switch {
+	case f.strategy == DepthFirst:
+		return f.pending[i].id > f.pending[j].id
+	case f.strategy == ByActivity && f.pending[i].activity != f.pending[j].activity:
+		return f.pending[i].activity < f.pending[j].activity
 	}
+	return f.pending[i].id < f.pending[j].id
 }
 
-// Utility function that searches for a couple (the set) into a list of said couples.
-// Since the list is assumed to have all the couples the case in which the couple is not
-// found is not contemplated and will stop the execution with an error
-func findCoupleId(list *list.List, toFind *set.Set) int {
-	id, _ := list.Find(func(_ int, item interface{}) bool {
-		couple := item.(*set.Set)
-		return couple.Contains(toFind.Values()...)
-	})
+func (f *frontier) Push(x interface{}) { f.pending = append(f.pending, x.(pendingState)) }
 
-	if id == -1 {
-		log.Fatal("Could not find couple")
+func (f *frontier) Pop() interface{} {
+	last := f.pending[len(f.pending)-1]
+	f.pending = f.pending[:len(f.pending)-1]
+	return last
+}
+
+// ----------------------------------------------------------------------------
+// Composition
+
+// A systemState is a configuration of the whole system reached during the composition: the local state of every
+// participant (notSpawned before its spawn) along with the senders of the messages waiting in the buffer of each
+// buffered channel, in the order they've been sent. Two configurations are the same state of the Choreography
+// Automata only when they agree on all of them, e.g three workers in the same local state aren't the same
+// configuration of a single worker in that state
+type systemState struct {
+	locals  []int   // The local state of each participant (see composer.views)
+	buffers [][]int // The senders (as participant index) of the messages in each buffer (see composer.channels)
+}
+
+// Returns the key that identifies the system state in the index of the configurations already found
+func (s systemState) key() string {
+	return fmt.Sprint(s.locals, s.buffers)
+}
+
+// Returns a copy of the system state that can be modified without changing the original one
+func (s systemState) clone() systemState {
+	cloned := systemState{locals: append([]int{}, s.locals...), buffers: make([][]int, len(s.buffers))}
+	for i, buffer := range s.buffers {
+		cloned.buffers[i] = append([]int{}, buffer...)
 	}
+	return cloned
+}
+
+// A single outgoing transition of a local state, as precomputed by the composer
+type localEdge struct {
+	to int            // The ending state of the transition
+	t  fsa.Transition // The transition itself
+}
+
+// A step of the composition: the interaction made from a configuration and the configuration it leads to
+type compositionStep struct {
+	interaction Interaction
+	next        systemState
+}
 
-	return id
+// A composer explores the configurations of the system reachable from an initial one. Each step is either a
+// spawn, a message exchanged at once on an unbuffered channel or the send (receive) of a message in (from) the
+// buffer of a buffered channel, the latter is filled up to its capacity (a single message when it's not constant)
+type composer struct {
+	views      []*GoroutineFSA              // The local views of the participants, sorted by name
+	indexOf    map[string]int               // The index of each participant in views
+	outgoing   []map[int][]localEdge        // The outgoing transitions of each local state, for each participant
+	channels   []string                     // The buffered channels, sorted by name
+	bufferOf   map[string]int               // The index of each buffered channel in channels
+	capacities []int                        // The number of messages that fit in the buffer of each channel
+	bufferMeta map[string]meta.ChanMetadata // The metadata of the buffered channels, as shown in the interactions
+	families   [][]int                      // The interchangeable participants, explored up to permutation (see canonical)
+	familyOf   map[int]int                  // The index of the family of each interchangeable participant
+	strategy   ExplorationStrategy          // The order in which the configurations are visited
 }
 
-// Utility functions that creates a transition from every state that contains at least one
-// element in the fromCouple to the state identified by destId and with newT transitions
-func createTransitions(syncFSA *fsa.FSA, couples *list.List, fromCouple *set.Set, destId int, newT fsa.Transition) {
-	couples.Each(func(currentId int, item interface{}) {
-		couple := item.(*set.Set)
-		for _, frozenFSA := range fromCouple.Values() {
-			if couple.Contains(frozenFSA) {
-				syncFSA.AddTransition(currentId, destId, newT)
+// Creates the composer of the given local views that visits the configurations in the given order, the channels
+// are buffered when they're declared so on at least one side of their operations (e.g a channel received as
+// argument). The environment channels are never buffered since the environment is an input ready at any time
+// (see ExtractEnvironment), its messages are exchanged at once
+func newComposer(localViews map[string]*GoroutineFSA, strategy ExplorationStrategy) *composer {
+	names := make([]string, 0, len(localViews))
+	for name := range localViews {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	c := composer{
+		indexOf:    make(map[string]int, len(names)),
+		bufferOf:   make(map[string]int),
+		bufferMeta: make(map[string]meta.ChanMetadata),
+		familyOf:   make(map[int]int),
+		strategy:   strategy,
+	}
+	for i, name := range names {
+		outgoing := make(map[int][]localEdge)
+		localViews[name].Automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+			outgoing[from] = append(outgoing[from], localEdge{to, t})
+
+			channel, isChanMeta := t.Payload.(meta.ChanMetadata)
+			if !isChanMeta || !channel.Async || channel.Environment || (t.Move != fsa.Send && t.Move != fsa.Recv) {
+				return
+			}
+			// The capacity and the message type could be known only on one side as well
+			known, exist := c.bufferMeta[t.Label]
+			if !exist || known.Capacity == meta.UnknownCapacity {
+				known.Capacity = channel.Capacity
 			}
+			if known.Type == "" {
+				known.Type = channel.Type
+			}
+			known.Name, known.Async = t.Label, true
+			c.bufferMeta[t.Label] = known
+		})
+
+		c.views = append(c.views, localViews[name])
+		c.indexOf[name] = i
+		c.outgoing = append(c.outgoing, outgoing)
+	}
+
+	for channel := range c.bufferMeta {
+		c.channels = append(c.channels, channel)
+	}
+	sort.Strings(c.channels)
+	for i, channel := range c.channels {
+		capacity := c.bufferMeta[channel].Capacity
+		if capacity == meta.UnknownCapacity {
+			capacity = 1
 		}
-	})
+		c.bufferOf[channel] = i
+		c.capacities = append(c.capacities, capacity)
+	}
+
+	return &c
 }
 
-// Takes the deterministic version of the Local Views (or Projection Automata) and merges them
-// in one DCA that will represent the choreography as a whole (the global view). This is possible
-// by composing all the Local View's FSAs into one and then appply a Synchronization transform on it
-func LocalViewsComposition(localViews map[string]*GoroutineFSA) *fsa.FSA {
-	cFSA := fsaProduct(localViews)
-
-	// Creates the entrypoint couples (main - 0, wildcard), the starting couple of the program
-	mainKey := fmt.Sprintf(nameTemplate, "main", 0)
-	entrypointCouple := set.New(FrozenFSA{localViews[mainKey], 0}, wildcard)
-
-	// Precalc the "synched" couples, the one in which the two process could interact between them
-	precalcCouples := precalcSynchedCouples(cFSA, entrypointCouple)
-
-	// With the precalc couple in which the local views synchs and the full composition automata
-	// the full Choreography Automata (global view) is generated and returned
-	return fsaSynchronization(cFSA, precalcCouples)
-}
-
-// Takes two or more FSA given as input and returns the composition FSA of given automata
-// the returned automata is a FSA with m x n x ... z states and all the transitions of the
-// starting FSAs combined, every possible combination is only added once.
-func fsaProduct(localViews map[string]*GoroutineFSA) ProductFSA {
-	// Creates a new list (type alias of CompositionFSA)
-	cAutomata := list.New()
-
-	// Creates all the couples iterating on each automata and each state of the latter
-	// and composes it with each other automata and their respective states
-	for _, lView := range localViews {
-		for _, otherView := range localViews {
-			// Avoids to compose the automata x with itself
-			if lView == otherView {
-				continue
-			}
+// Returns the system state described by the given configuration: the participants listed are in the given local
+// state while the others are yet to be spawned. The environment and the others of a partial composition are always
+// running, in their only state (see ExtractEnvironment and SelectParticipants), and every buffer starts empty.
+// The execution is stopped when the configuration is empty or refers to a participant (or state) that doesn't exist
+func (c *composer) systemStateOf(config Configuration) systemState {
+	if len(config) == 0 {
+		log.Fatal("The initial configuration of the composition is empty")
+	}
 
-			lView.Automaton.ForEachState(func(lViewId int) {
-				otherView.Automaton.ForEachState(func(otherViewId int) {
-					// Creates the "frozen" instances (automata + state in which is frozen)
-					frozenA := FrozenFSA{lView, lViewId}
-					frozenB := FrozenFSA{otherView, otherViewId}
+	state := systemState{locals: make([]int, len(c.views)), buffers: make([][]int, len(c.channels))}
+	for i, lView := range c.views {
+		state.locals[i] = notSpawned
+		if lView.Name == EnvironmentName || lView.Name == OthersName {
+			state.locals[i] = lView.Automaton.InitialState()
+		}
+	}
 
-					// Checks that the couple hasn't been already indexed
-					exist := cAutomata.Any(func(_ int, item interface{}) bool {
-						couple := item.(*set.Set)
-						return couple.Contains(frozenA, frozenB)
-					})
+	for name, local := range config {
+		p, exist := c.indexOf[name]
+		if !exist {
+			log.Fatalf("Participant '%s' of the initial configuration not found\n", name)
+		}
 
-					if !exist { // If the couple hasn't been indexed then is added
-						cAutomata.Add(set.New(frozenA, frozenB))
-					}
-				})
-			})
+		isKnownState := false
+		c.views[p].Automaton.ForEachState(func(id int) { isKnownState = isKnownState || id == local })
+		if !isKnownState {
+			log.Fatalf("State %d of the initial configuration not found in the local view of '%s'\n", local, name)
 		}
+		state.locals[p] = local
 	}
 
-	return cAutomata // Returns the composition finite state automata
+	return state
 }
 
-// Given a composition FSA and the entrypoint (the first state) for the first it precalculate
-// the state of the cFSA in which a synchronization occurs. this means it returns a subset of tuples
-// <state, state> in which 2 actor or local views interact between them
-func precalcSynchedCouples(cFSA ProductFSA, entrypoint *set.Set) *list.List {
-	// Creates the list with the synched couples
-	synchedCouples := list.New(entrypoint)
+// Returns the steps that the system can make from the given configuration, in a deterministic order: by participant
+// (the sender for the message exchanged at once) and then by the order of the transitions of its local view
+func (c *composer) steps(current systemState) []compositionStep {
+	steps := []compositionStep{}
 
-	forEachCoupleTransition(cFSA, func(fA, fB FrozenFSA, tA, tB fsa.Transition, toA, toB int) {
-		var couple *set.Set // Initializes and empty couple
+	for p, lView := range c.views {
+		if current.locals[p] == notSpawned {
+			continue
+		}
 
-		// Retrieve the "destination" couple of the current one
-		newFrozenA := FrozenFSA{fA.localView, toA}
-		newFrozenB := FrozenFSA{fB.localView, toB}
+		for _, edge := range c.outgoing[p][current.locals[p]] {
+			b, isBuffered := c.bufferOf[edge.t.Label]
+
+			switch {
+			// The spawned Goroutine starts from its initial state (once again if it was already running)
+			case edge.t.Move == fsa.Spawn:
+				next, spawnedName := current.clone(), edge.t.Label
+				if spawned, isParticipant := c.indexOf[edge.t.Label]; isParticipant {
+					spawned = c.instanceToSpawn(current, spawned)
+					next.locals[spawned] = c.views[spawned].Automaton.InitialState()
+					spawnedName = c.views[spawned].Name
+				}
+				next.locals[p] = edge.to
+				steps = append(steps, compositionStep{Interaction{From: lView.Name, To: spawnedName}, next})
+
+			// The message is put in the buffer, if there's still room for it
+			case edge.t.Move == fsa.Send && isBuffered:
+				if len(current.buffers[b]) < c.capacities[b] {
+					next := current.clone()
+					next.locals[p], next.buffers[b] = edge.to, append(next.buffers[b], p)
+					steps = append(steps, compositionStep{Interaction{From: lView.Name, Channel: c.bufferMeta[edge.t.Label]}, next})
+				}
 
-		// Check for interaction between A and B (A sends, B receives or the opposite)
-		hasA2B := tA.Move == fsa.Send && tB.Move == fsa.Recv && tA.Label == tB.Label
-		hasB2A := tB.Move == fsa.Send && tA.Move == fsa.Recv && tA.Label == tB.Label
+			// The oldest message of the buffer is delivered, the interaction is with the participant that sent it
+			case edge.t.Move == fsa.Recv && isBuffered:
+				if len(current.buffers[b]) > 0 {
+					next := current.clone()
+					sender := current.buffers[b][0]
+					next.locals[p], next.buffers[b] = edge.to, next.buffers[b][1:]
+					interaction := Interaction{From: c.views[sender].Name, To: lView.Name, Channel: c.bufferMeta[edge.t.Label]}
+					traceMatch(interaction, current.locals[sender], current.locals[p])
+					steps = append(steps, compositionStep{interaction, next})
+				}
 
-		// If A or B have a Spawn transition then the couple <spawner, *> is considered "synched"
-		if tA.Move == fsa.Spawn {
-			couple = set.New(newFrozenA, wildcard)
-		} else if tB.Move == fsa.Spawn {
-			couple = set.New(newFrozenB, wildcard)
-		} else if hasA2B || hasB2A { // If A and B interact between them the couple is "synched"
-			couple = set.New(newFrozenA, newFrozenB)
-		} else { // Else the couple is not "synched" and we skip the iteration
-			return
+			// The message is exchanged at once with every other participant ready to receive it
+			case edge.t.Move == fsa.Send:
+				for q, peer := range c.views {
+					if q == p || current.locals[q] == notSpawned {
+						continue
+					}
+					for _, peerEdge := range c.outgoing[q][current.locals[q]] {
+						if peerEdge.t.Move != fsa.Recv || peerEdge.t.Symbol != edge.t.Symbol {
+							continue
+						}
+						next := current.clone()
+						next.locals[p], next.locals[q] = edge.to, peerEdge.to
+						interaction := newInteraction(lView.Name, peer.Name, edge.t, peerEdge.t)
+						traceMatch(interaction, current.locals[p], current.locals[q])
+						steps = append(steps, compositionStep{interaction, next})
+					}
+				}
+			}
 		}
+	}
 
-		// Checks that the couple has not been already indexed (every couple is indexed only once)
-		alreadyExist := synchedCouples.Any(func(_ int, item interface{}) bool {
-			current := item.(*set.Set)
-			return current.Contains(couple.Values()...)
-		})
+	return steps
+}
 
-		if !alreadyExist {
-			synchedCouples.Add(couple)
+// Returns true if the given configuration is final: every participant started is in a final state of its local view
+// (the ones never spawned don't block the termination), the messages left in the buffers are never received
+func (c *composer) isFinal(state systemState) bool {
+	for p, local := range state.locals {
+		if local != notSpawned && !c.views[p].Automaton.IsFinalState(local) {
+			return false
 		}
-	})
-
-	return synchedCouples // Returns the "synched" couple list
-}
-
-// Iterates over the composition FSA and whenever it found a couple of state (and their respective FSA
-// & transitions) that can be synchronized: 1) they make their own operations (e.g. Spawn) they make
-// opposite transition on the same channel (Send & Receive on x) then it links this couple with every other
-// couple in the synchronization FSA that can reach the current one.
-func fsaSynchronization(cFSA ProductFSA, synchedCouples *list.List) *fsa.FSA {
-	// Initializes the synchronized FSA
-	synchAutomata := fsa.New()
-
-	// ! Refactor this mess
-	forEachCoupleTransition(cFSA, func(frozenA, frozenB FrozenFSA, tA, tB fsa.Transition, toA, toB int) {
-		newFrozenA := FrozenFSA{frozenA.localView, toA}
-		newFrozenB := FrozenFSA{frozenB.localView, toB}
-
-		if tA.Move == fsa.Spawn {
-			// Find the id of the current couple in the precalc list
-			id := findCoupleId(synchedCouples, set.New(newFrozenA, wildcard))
-			// Generate the new transition with label
-			interactionLabel := fmt.Sprintf("%s △ %s", frozenA.localView.Name, tA.Label)
-			newT := fsa.Transition{Move: fsa.Empty, Label: interactionLabel}
-			// Add said transition to the final synchronization FSA
-			createTransitions(synchAutomata, synchedCouples, set.New(frozenA), id, newT)
+	}
+	return true
+}
+
+// Returns the number of participants still active in the given configuration, that is spawned and not in a final state
+func (c *composer) activity(state systemState) int {
+	active := 0
+	for p, local := range state.locals {
+		if local != notSpawned && !c.views[p].Automaton.IsFinalState(local) {
+			active++
 		}
+	}
+	return active
+}
 
-		if tB.Move == fsa.Spawn {
-			// Find the id of the current couple in the precalc list
-			id := findCoupleId(synchedCouples, set.New(newFrozenB, wildcard))
-			// Generate the new transition with label
-			interactionLabel := fmt.Sprintf("%s △ %s", frozenB.localView.Name, tB.Label)
-			newT := fsa.Transition{Move: fsa.Empty, Label: interactionLabel}
-			// Add said transition to the final synchronization FSA
-			createTransitions(synchAutomata, synchedCouples, set.New(frozenB), id, newT)
+// Explores the configurations reachable from the given one and returns the Choreography Automata made of them:
+// each configuration is a state (the initial one is the given configuration) and each step an interaction
+func (c *composer) compose(initial systemState) *fsa.FSA {
+	choreography := fsa.New()
+	initial = c.canonical(initial)
+	ids := map[string]int{initial.key(): choreography.InitialState()}
+
+	// The configurations are visited in the order given by the strategy, the ids are assigned as they're found
+	pending := &frontier{strategy: c.strategy}
+	heap.Push(pending, pendingState{initial, choreography.InitialState(), c.activity(initial)})
+	for pending.Len() > 0 {
+		current := heap.Pop(pending).(pendingState)
+		if c.isFinal(current.state) {
+			choreography.SetFinalState(current.id)
 		}
 
-		if tA.Move == fsa.Send && tB.Move == fsa.Recv && tA.Label == tB.Label {
-			// Find the id of the current couple in the precalc list
-			id := findCoupleId(synchedCouples, set.New(newFrozenA, newFrozenB))
-			// Generate the new transition with label
-			msgType := tA.Payload.(meta.ChanMetadata).Type
-			interactionLabel := fmt.Sprintf("%s → %s: %s", frozenB.localView.Name, frozenA.localView.Name, msgType)
-			newT := fsa.Transition{Move: fsa.Empty, Label: interactionLabel}
-			// Add said transition to the final synchronization FSA
-			createTransitions(synchAutomata, synchedCouples, set.New(frozenA, frozenB), id, newT)
-		} else if tB.Move == fsa.Send && tA.Move == fsa.Recv && tA.Label == tB.Label {
-			// Find the id of the current couple in the precalc list
-			id := findCoupleId(synchedCouples, set.New(newFrozenA, newFrozenB))
-			// Generate the new transition with label
-			msgType := tA.Payload.(meta.ChanMetadata).Type
-			interactionLabel := fmt.Sprintf("%s → %s: %s", frozenA.localView.Name, frozenB.localView.Name, msgType)
-			newT := fsa.Transition{Move: fsa.Empty, Label: interactionLabel}
-			// Add said transition to the final synchronization FSA
-			createTransitions(synchAutomata, synchedCouples, set.New(frozenA, frozenB), id, newT)
+		for _, step := range c.steps(current.state) {
+			step.next = c.canonical(step.next)
+			to, exist := ids[step.next.key()]
+			if !exist {
+				to = choreography.AddState()
+				ids[step.next.key()] = to
+				heap.Push(pending, pendingState{step.next, to, c.activity(step.next)})
+			}
+
+			newT := fsa.Transition{Move: fsa.Empty, Label: step.interaction.Label(FullLabels), Payload: step.interaction}
+			choreography.AddTransition(current.id, to, newT)
 		}
-	})
+	}
+
+	return choreography
+}
 
-	return synchAutomata
+// Returns the type of the message exchanged by the given (matching) Send and Recv transitions, the
+// channel type could be known only on one side (e.g. a channel received as argument) so the first
+// non empty one is returned
+func messageType(tSend, tRecv fsa.Transition) string {
+	if typeSend := tSend.Payload.(meta.ChanMetadata).Type; typeSend != "" {
+		return typeSend
+	}
+	return tRecv.Payload.(meta.ChanMetadata).Type
+}
+
+// Creates the Interaction for the message exchanged at once by a sender (the one making the Send transition)
+// and a receiver, the channel is identified by the label of the Send that is shared by both the transitions
+func newInteraction(sender, receiver string, tSend, tRecv fsa.Transition) Interaction {
+	channel := tSend.Payload.(meta.ChanMetadata)
+	channel.Name, channel.Type = tSend.Label, messageType(tSend, tRecv)
+	return Interaction{From: sender, To: receiver, Channel: channel}
+}
+
+// Traces the synchronization of a sender and a receiver (in the local states from which they interact)
+func traceMatch(interaction Interaction, senderState, receiverState int) {
+	tracing.Decisionf("composition", "", "send of %s (state %d) matched with the receive of %s (state %d) on '%s'",
+		interaction.From, senderState, interaction.To, receiverState, interaction.Channel.Name)
+}
+
+// Takes the deterministic version of the Local Views (or Projection Automata) and merges them
+// in one DCA that will represent the choreography as a whole (the global view). This is possible
+// by exploring the configurations of the whole system reachable from the initial one (see systemState):
+// each state of the Choreography Automata is a configuration while each transition an interaction
+func LocalViewsComposition(localViews map[string]*GoroutineFSA) *fsa.FSA {
+	return LocalViewsCompositionFrom(localViews, InitialConfiguration(localViews))
+}
+
+// Composes the local views as LocalViewsComposition does but starting from the given configuration instead of
+// the initial one: e.g a snapshot of the program after its setup or two Goroutines exchanging messages on their own.
+// The execution is stopped when the configuration is empty or refers to a participant (or state) that doesn't exist
+func LocalViewsCompositionFrom(localViews map[string]*GoroutineFSA, initial Configuration) *fsa.FSA {
+	return LocalViewsCompositionWith(localViews, initial, BreadthFirst)
+}
+
+// Composes the local views from the given configuration as LocalViewsCompositionFrom does, visiting the
+// configurations of the system in the order given by the strategy (see ExplorationStrategy)
+func LocalViewsCompositionWith(localViews map[string]*GoroutineFSA, initial Configuration, strategy ExplorationStrategy) *fsa.FSA {
+	c := newComposer(localViews, strategy)
+	return c.compose(c.systemStateOf(initial))
+}
+
+// ----------------------------------------------------------------------------
+// Configuration
+
+// A Configuration is the state from which the composition starts: the participants running at once, each
+// one in the given state of its local view, while the others are yet to be spawned (e.g {"main": 0})
+type Configuration map[string]int
+
+// Returns the configuration from which a program starts: main in its initial state, along with the network
+// participant (if any) since it starts with main instead of being spawned (see ExtractNetwork)
+func InitialConfiguration(localViews map[string]*GoroutineFSA) Configuration {
+	mainView, exist := localViews[MainName]
+	if !exist {
+		log.Fatalf("Local view of the '%s' Goroutine not found\n", MainName)
+	}
+
+	initial := Configuration{MainName: mainView.Automaton.InitialState()}
+	if network, exist := localViews[NetworkName]; exist {
+		initial[NetworkName] = network.Automaton.InitialState()
+	}
+	return initial
 }
@@ -0,0 +1,216 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the composition of the local views and its initial configuration
+package transforms_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+// Returns the local view with the given name that loops over the given transitions, from and back to 0
+func loopingView(name string, transitions ...fsa.Transition) *transforms.GoroutineFSA {
+	automaton := fsa.New()
+	from := automaton.InitialState()
+	for i, t := range transitions {
+		to := automaton.AddState()
+		if i == len(transitions)-1 {
+			to = automaton.InitialState()
+		}
+		from = automaton.AddTransition(from, to, t)
+	}
+	automaton.SetFinalState(automaton.InitialState())
+	return &transforms.GoroutineFSA{Name: name, FuncMetadata: meta.FuncMetadata{Name: name, Automaton: automaton}}
+}
+
+// The two players of a ping-pong game exchanging the ball, neither of them is spawned by main
+func pingPongViews() map[string]*transforms.GoroutineFSA {
+	ball := meta.ChanMetadata{Name: "ball", Type: "int"}
+	send := fsa.Transition{Move: fsa.Send, Label: "ball", Payload: ball}
+	recv := fsa.Transition{Move: fsa.Recv, Label: "ball", Payload: ball}
+
+	return map[string]*transforms.GoroutineFSA{
+		"ping": loopingView("ping", send, recv),
+		"pong": loopingView("pong", recv, send),
+	}
+}
+
+func TestCompositionFromConfiguration(t *testing.T) {
+	choreography := transforms.LocalViewsCompositionFrom(pingPongViews(), transforms.Configuration{"ping": 0, "pong": 0})
+
+	// The ball goes back and forth: the configuration where both the players are back to 0 is the initial one
+	expected := map[[2]int]transforms.Interaction{}
+	choreography.ForEachTransition(func(from, to int, t fsa.Transition) {
+		expected[[2]int{from, to}] = t.Payload.(transforms.Interaction)
+	})
+	if len(expected) != 2 || expected[[2]int{0, 1}].From != "ping" || expected[[2]int{1, 0}].From != "pong" {
+		t.Fatalf("expected the ball to go from ping to pong and back\n%s", choreography)
+	}
+	if !choreography.IsFinalState(0) || choreography.IsFinalState(1) {
+		t.Errorf("expected only the initial configuration to be final\n%s", choreography)
+	}
+
+	// Starting from the middle of the game the first one to hit is pong
+	midGame := transforms.LocalViewsCompositionFrom(pingPongViews(), transforms.Configuration{"ping": 1, "pong": 1})
+	midGame.ForEachTransition(func(from, to int, tMid fsa.Transition) {
+		if interaction := tMid.Payload.(transforms.Interaction); from == 0 && interaction.From != "pong" {
+			t.Errorf("expected pong to hit first, got %s", interaction.Label(transforms.MinimalLabels))
+		}
+	})
+}
+
+func TestInitialConfiguration(t *testing.T) {
+	localViews := pingPongViews()
+	localViews[transforms.MainName] = loopingView(transforms.MainName,
+		fsa.Transition{Move: fsa.Spawn, Label: "ping"}, fsa.Transition{Move: fsa.Spawn, Label: "pong"})
+
+	initial := transforms.InitialConfiguration(localViews)
+	if len(initial) != 1 || initial[transforms.MainName] != 0 {
+		t.Fatalf("expected only main in its initial state, got %v", initial)
+	}
+
+	// The default composition starts from the initial configuration
+	fromInitial := transforms.LocalViewsCompositionFrom(localViews, initial).String()
+	if composed := transforms.LocalViewsComposition(localViews).String(); composed != fromInitial {
+		t.Errorf("expected the same composition from the initial configuration\n%s\ngot\n%s", composed, fromInitial)
+	}
+}
+
+// Returns the local view with the given name that makes the given transitions one after another and then terminates
+func linearView(name string, transitions ...fsa.Transition) *transforms.GoroutineFSA {
+	automaton := fsa.New()
+	from := automaton.InitialState()
+	for _, t := range transitions {
+		from = automaton.AddTransition(from, automaton.AddState(), t)
+	}
+	automaton.SetFinalState(from)
+	return &transforms.GoroutineFSA{Name: name, FuncMetadata: meta.FuncMetadata{Name: name, Automaton: automaton}}
+}
+
+func TestCompositionOfManyParticipants(t *testing.T) {
+	jobs, done := meta.ChanMetadata{Name: "jobs", Type: "int"}, meta.ChanMetadata{Name: "done", Type: "bool"}
+	workers, mainMoves := []string{"w1", "w2", "w3"}, []fsa.Transition{}
+	localViews := map[string]*transforms.GoroutineFSA{}
+	for _, worker := range workers {
+		localViews[worker] = linearView(worker,
+			fsa.Transition{Move: fsa.Recv, Label: "jobs", Payload: jobs}, fsa.Transition{Move: fsa.Send, Label: "done", Payload: done})
+		mainMoves = append(mainMoves, fsa.Transition{Move: fsa.Spawn, Label: worker})
+	}
+	for range workers {
+		mainMoves = append(mainMoves, fsa.Transition{Move: fsa.Send, Label: "jobs", Payload: jobs})
+	}
+	for range workers {
+		mainMoves = append(mainMoves, fsa.Transition{Move: fsa.Recv, Label: "done", Payload: done})
+	}
+	localViews[transforms.MainName] = linearView(transforms.MainName, mainMoves...)
+
+	// Every worker takes a job and reports back, in any order, so that the only final configuration is the one
+	// reached once all of them (and main) have terminated
+	choreography, interactions := transforms.LocalViewsComposition(localViews), map[string]bool{}
+	choreography.ForEachTransition(func(_, _ int, t fsa.Transition) {
+		interactions[t.Payload.(transforms.Interaction).Label(transforms.MinimalLabels)] = true
+	})
+	for _, worker := range workers {
+		for _, label := range []string{"main → " + worker, worker + " → main"} {
+			if !interactions[label] {
+				t.Errorf("expected the interaction %s in the composition\n%s", label, choreography)
+			}
+		}
+	}
+	finals := 0
+	choreography.ForEachState(func(id int) {
+		if choreography.IsFinalState(id) {
+			finals++
+		}
+	})
+	if finals != 1 {
+		t.Errorf("expected a single final configuration, got %d\n%s", finals, choreography)
+	}
+}
+
+func TestCompositionOfBufferedChannel(t *testing.T) {
+	queue := meta.ChanMetadata{Name: "queue", Type: "int", Async: true, Capacity: 1}
+	send := fsa.Transition{Move: fsa.Send, Label: "queue", Payload: queue}
+	recv := fsa.Transition{Move: fsa.Recv, Label: "queue", Payload: queue}
+	localViews := map[string]*transforms.GoroutineFSA{
+		"producer": linearView("producer", send, send),
+		"consumer": linearView("consumer", recv, recv),
+	}
+
+	// The buffer holds a single message: the second send has to wait for the first one to be delivered
+	choreography := transforms.LocalViewsCompositionFrom(localViews, transforms.Configuration{"producer": 0, "consumer": 0})
+	sent, delivered, transitions := "producer ▷ queue<int>[1]", "producer → consumer: queue<int>[1]", 0
+	expected := []string{sent, delivered, sent, delivered}
+	choreography.ForEachTransition(func(from, to int, tr fsa.Transition) {
+		label := tr.Payload.(transforms.Interaction).Label(transforms.FullLabels)
+		if transitions++; from >= len(expected) || to != from+1 || label != expected[from] {
+			t.Errorf("unexpected interaction %s from %d to %d", label, from, to)
+		}
+	})
+	if transitions != len(expected) || !choreography.IsFinalState(len(expected)) {
+		t.Errorf("expected the two messages to be sent and delivered one after the other\n%s", choreography)
+	}
+}
+
+func TestExplorationStrategies(t *testing.T) {
+	for _, name := range []string{"bfs", "dfs", "priority"} {
+		if strategy, err := transforms.ParseExplorationStrategy(name); err != nil || strategy.String() != name {
+			t.Errorf("expected the strategy %s to be parsed, got %v (%v)", name, strategy, err)
+		}
+	}
+	if _, err := transforms.ParseExplorationStrategy("random"); err == nil {
+		t.Errorf("expected an unknown strategy to be rejected")
+	}
+
+	// Every strategy finds the same configurations and interactions, only the order of the ids changes
+	localViews := pingPongViews()
+	localViews[transforms.MainName] = linearView(transforms.MainName,
+		fsa.Transition{Move: fsa.Spawn, Label: "ping"}, fsa.Transition{Move: fsa.Spawn, Label: "pong"})
+	summary := func(strategy transforms.ExplorationStrategy) (map[string]int, int, int) {
+		choreography := transforms.LocalViewsCompositionWith(localViews, transforms.InitialConfiguration(localViews), strategy)
+		labels, states, finals := map[string]int{}, 0, 0
+		choreography.ForEachTransition(func(_, _ int, tr fsa.Transition) { labels[tr.Label]++ })
+		choreography.ForEachState(func(id int) {
+			if states++; choreography.IsFinalState(id) {
+				finals++
+			}
+		})
+		return labels, states, finals
+	}
+
+	expectedLabels, expectedStates, expectedFinals := summary(transforms.BreadthFirst)
+	for _, strategy := range []transforms.ExplorationStrategy{transforms.DepthFirst, transforms.ByActivity} {
+		labels, states, finals := summary(strategy)
+		if !reflect.DeepEqual(labels, expectedLabels) || states != expectedStates || finals != expectedFinals {
+			t.Errorf("expected the %s composition to match the breadth-first one, got %v (%d states, %d final)", strategy, labels, states, finals)
+		}
+	}
+}
+
+func TestDepthFirstExploration(t *testing.T) {
+	// Two independent participants: breadth-first numbers the configurations one step away first, depth-first goes on along
+	// the first path found (the initial configuration is 0, the ones it leads to come right after)
+	localViews := map[string]*transforms.GoroutineFSA{
+		"a": linearView("a", fsa.Transition{Move: fsa.Spawn, Label: "x"}, fsa.Transition{Move: fsa.Spawn, Label: "y"}),
+		"b": linearView("b", fsa.Transition{Move: fsa.Spawn, Label: "z"}, fsa.Transition{Move: fsa.Spawn, Label: "w"}),
+	}
+	initial := transforms.Configuration{"a": 0, "b": 0}
+	successors := func(strategy transforms.ExplorationStrategy) map[int][]int {
+		choreography, next := transforms.LocalViewsCompositionWith(localViews, initial, strategy), map[int][]int{}
+		choreography.ForEachTransition(func(from, to int, _ fsa.Transition) { next[from] = append(next[from], to) })
+		return next
+	}
+
+	if bfs := successors(transforms.BreadthFirst); !reflect.DeepEqual(bfs[1], []int{3, 4}) {
+		t.Errorf("expected breadth-first to find the second level after the first, got %v", bfs)
+	}
+	if dfs := successors(transforms.DepthFirst); !reflect.DeepEqual(dfs[2], []int{3, 4}) {
+		t.Errorf("expected depth-first to go on from the last configuration found, got %v", dfs)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package transforms declares the types and functions used to transform and work with some type of FSA.
+// Come of the transformation implemented here are standard such as determinization (Subset Construction),
+// minimization but more are specifically related to Choreia (GoroutineFSA extraction & Composition)
+//
+package transforms
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+)
+
+// The name of a participant or of a channel qualified by the service it belongs to (e.g "orders/main")
+const serviceTemplate = "%s/%s"
+
+// ----------------------------------------------------------------------------
+// Service
+
+// A Service is a set of local views produced by an independent analysis (e.g. one of the binaries of
+// a monorepo) along with the global channels of the latter, the ones that can be shared with other services
+type Service struct {
+	Name           string                       // The name used to qualify the participants and channels of the service
+	GlobalChanMeta map[string]meta.ChanMetadata // The channels declared in the global scope of the service
+	LocalViews     map[string]*GoroutineFSA     // The local views of the Goroutines of the service
+}
+
+// Returns the name that the given channel of the service has in the cross-service composition. The links
+// associate the name of a channel to the one it has in the composition, either for a single service (e.g
+// "orders/outbox" to "queue") or for every service using it (e.g "events" to "bus"). Without a link the
+// global channels are shared by name with the other services while any other channel is private to the
+// service, so its name is qualified by the latter (e.g "orders/jobs")
+func (s Service) channelName(channel string, links map[string]string) string {
+	if shared, isLinked := links[fmt.Sprintf(serviceTemplate, s.Name, channel)]; isLinked {
+		return shared
+	}
+	if shared, isLinked := links[channel]; isLinked {
+		return shared
+	}
+	if _, isGlobal := s.GlobalChanMeta[channel]; isGlobal {
+		return channel
+	}
+	return fmt.Sprintf(serviceTemplate, s.Name, channel)
+}
+
+// Returns a copy of the local views of the service where the participants are qualified by the service
+// name (the Spawn transitions as well) and the channels are named as in the cross-service composition.
+// The environment of the service is dropped since a single one is shared by all the services
+func (s Service) qualifiedViews(links map[string]string) map[string]*GoroutineFSA {
+	qualified := make(map[string]*GoroutineFSA, len(s.LocalViews))
+
+	for name, lView := range s.LocalViews {
+		if name == EnvironmentName {
+			continue
+		}
+
+		qualifiedView := *lView
+		qualifiedView.Name = fmt.Sprintf(serviceTemplate, s.Name, name)
+		qualifiedView.Automaton = lView.Automaton.Copy()
+
+		qualifiedView.Automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+			newT := t
+			switch t.Move {
+			case fsa.Spawn:
+				newT = fsa.Transition{Move: t.Move, Label: fmt.Sprintf(serviceTemplate, s.Name, t.Label), Payload: t.Payload}
+			case fsa.Send, fsa.Recv:
+				channelMeta, _ := t.Payload.(meta.ChanMetadata)
+				channelMeta.Name = s.channelName(t.Label, links)
+				newT = fsa.Transition{Move: t.Move, Label: channelMeta.Name, Payload: channelMeta}
+			default:
+				return
+			}
+			qualifiedView.Automaton.RemoveTransition(from, to, t)
+			qualifiedView.Automaton.AddTransition(from, to, newT)
+		})
+
+		qualified[qualifiedView.Name] = &qualifiedView
+	}
+
+	return qualified
+}
+
+// ----------------------------------------------------------------------------
+// Cross-service composition
+
+// Composes the local views of more services, analyzed independently, in a single Choreography Automata. The
+// services interact over the channels they share (see Service.channelName), the links are optional and map
+// the channels of the services to the shared ones (e.g "orders/outbox" and "billing/inbox" to "queue").
+//
+// The participants are qualified by the name of their service (e.g "billing/main/worker@billing.go:7#1") and the main
+// Goroutines of the services are all running from the start, so the entrypoint of the composition holds
+// the initial state of each one of them. Returns the cross-service Choreography Automata along with the
+// local views composed
+func ComposeServices(services []Service, links map[string]string) (*fsa.FSA, map[string]*GoroutineFSA) {
+	localViews := make(map[string]*GoroutineFSA)
+	initial := Configuration{}
+
+	for _, service := range services {
+		mainName := fmt.Sprintf(serviceTemplate, service.Name, MainName)
+		qualified := service.qualifiedViews(links)
+		mainView, exist := qualified[mainName]
+		if !exist {
+			log.Fatalf("Local view of the 'main' Goroutine not found in service '%s'\n", service.Name)
+		}
+
+		for name, lView := range qualified {
+			if _, isDuplicate := localViews[name]; isDuplicate {
+				log.Fatalf("Participant '%s' found in more services, the service names must be unique\n", name)
+			}
+			localViews[name] = lView
+		}
+		initial[mainName] = mainView.Automaton.InitialState()
+	}
+
+	// A single environment fills the channels of the runtime for every service
+	if environment := ExtractEnvironment(localViews); environment != nil {
+		localViews[environment.Name] = environment
+	}
+
+	// With a single service the entrypoint is the same of LocalViewsComposition
+	return LocalViewsCompositionFrom(localViews, initial), localViews
+}
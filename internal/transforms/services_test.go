@@ -0,0 +1,104 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the composition of independently analyzed services over their shared channels
+package transforms_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+// Both the services use a private "jobs" channel, while "queue" is the global one they share
+var servicesSources = map[string]string{
+	"orders": `package main
+var queue = make(chan int)
+func main() {
+	jobs := make(chan int)
+	go func() { <-jobs }()
+	jobs <- 1
+	queue <- 1
+}
+`,
+	"billing": `package main
+var queue = make(chan int)
+func main() {
+	jobs := make(chan int)
+	go func() {
+		order := <-queue
+		jobs <- order
+	}()
+	<-jobs
+}
+`,
+}
+
+// Extracts the deterministic local views of the given service
+func extractService(t *testing.T, name string) transforms.Service {
+	path := filepath.Join(t.TempDir(), name+".go")
+	if err := os.WriteFile(path, []byte(servicesSources[name]), 0664); err != nil {
+		t.Fatal(err)
+	}
+
+	metadata := meta.ExtractMetadata(path, meta.NoTrace)
+	localViews := transforms.ExtractGoroutineFSA(metadata)
+	for _, lView := range localViews {
+		lView.Automaton = transforms.SubsetConstruction(lView.Automaton)
+	}
+	return transforms.Service{Name: name, GlobalChanMeta: metadata.GlobalChanMeta, LocalViews: localViews}
+}
+
+// Returns the sorted (and deduplicated) labels of the interactions in the given Choreography Automata
+func interactionLabels(choreography *fsa.FSA) []string {
+	found := map[string]bool{}
+	choreography.ForEachTransition(func(_, _ int, tr fsa.Transition) { found[tr.Label] = true })
+
+	labels := make([]string, 0, len(found))
+	for label := range found {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+func TestComposeServices(t *testing.T) {
+	services := []transforms.Service{extractService(t, "orders"), extractService(t, "billing")}
+	choreography, localViews := transforms.ComposeServices(services, nil)
+
+	if _, exist := localViews["billing/main/main-func1@billing.go:5#1"]; !exist || len(localViews) != 4 {
+		t.Errorf("expected the qualified Goroutines of both the services, got %d local views", len(localViews))
+	}
+
+	expected := []string{
+		"billing/main △ billing/main/main-func1@billing.go:5#1",
+		"billing/main/main-func1@billing.go:5#1 → billing/main: billing/jobs<int>",
+		"orders/main → billing/main/main-func1@billing.go:5#1: queue<int>",
+		"orders/main → orders/main/main-func1@orders.go:5#1: orders/jobs<int>",
+		"orders/main △ orders/main/main-func1@orders.go:5#1",
+	}
+	if labels := interactionLabels(choreography); !reflect.DeepEqual(labels, expected) {
+		t.Errorf("expected the interactions\n%v\ngot\n%v", expected, labels)
+	}
+}
+
+func TestComposeServicesWithLinks(t *testing.T) {
+	services := []transforms.Service{extractService(t, "orders"), extractService(t, "billing")}
+	// The private channel of orders is linked to the global one of billing, the other way around for "queue"
+	links := map[string]string{"orders/jobs": "queue", "orders/queue": "orders/outbox"}
+	choreography, _ := transforms.ComposeServices(services, links)
+
+	for _, label := range interactionLabels(choreography) {
+		if label == "orders/main → billing/main/main-func1@billing.go:5#1: queue<int>" {
+			return
+		}
+	}
+	t.Errorf("expected the linked channel to carry an interaction between the services")
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package transforms declares the types and functions used to transform and work with some type of FSA.
+// Come of the transformation implemented here are standard such as determinization (Subset Construction),
+// minimization but more are specifically related to Choreia (GoroutineFSA extraction & Composition)
+//
+package transforms
+
+import (
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+)
+
+// Returns a simplified copy of the given automaton, meant to be used only before exporting it. The chains
+// of states connected only by eps transitions (e.g "if-block-start", "start-call-expansion", ...) are
+// contracted and then the states are renumbered in breadth-first order from the initial one, so that the
+// same program always produces diagrams with the same compact shape regardless of the internal ids
+func Simplify(automaton *fsa.FSA) *fsa.FSA {
+	return renumberStates(collapseEpsChains(automaton))
+}
+
+// Contracts every non final state whose only outgoing transition is an eps one, the transitions entering
+// the state are redirected to the ending state of the eps. The same is done with the initial state when
+// nothing enters it, in this case the ending state of the eps becomes the new initial state
+func collapseEpsChains(automaton *fsa.FSA) *fsa.FSA {
+	contracted := automaton.Copy()
+
+	for {
+		outgoing := make(map[int][]int)
+		incoming := make(map[int]int)
+		isEps := make(map[int]bool)
+
+		contracted.ForEachTransition(func(from, to int, t fsa.Transition) {
+			outgoing[from] = append(outgoing[from], to)
+			incoming[to]++
+			isEps[from] = t.Move == fsa.Eps
+		})
+
+		// Finds the first state (in id order) that can be contracted
+		contractedId, targetId := fsa.Unknown, fsa.Unknown
+		contracted.ForEachState(func(id int) {
+			if contractedId != fsa.Unknown || !isEps[id] || len(outgoing[id]) != 1 || outgoing[id][0] == id || contracted.IsFinalState(id) {
+				return
+			}
+			if id != contracted.InitialState() || incoming[id] == 0 {
+				contractedId, targetId = id, outgoing[id][0]
+			}
+		})
+
+		if contractedId == fsa.Unknown {
+			return contracted
+		}
+
+		contracted.ForEachTransition(func(from, to int, t fsa.Transition) {
+			if from == contractedId {
+				contracted.RemoveTransition(from, to, t)
+			} else if to == contractedId {
+				contracted.RemoveTransition(from, to, t)
+				contracted.AddTransition(from, targetId, t)
+			}
+		})
+
+		if contractedId == contracted.InitialState() {
+			contracted.SetInitialState(targetId)
+		}
+	}
+}
+
+// Returns a copy of the given automaton where the states reachable from the initial state are
+// renumbered in breadth-first order (the initial state becomes 0), the unreachable ones are dropped
+func renumberStates(automaton *fsa.FSA) *fsa.FSA {
+	type outgoingT struct {
+		to int
+		t  fsa.Transition
+	}
+
+	outgoing := make(map[int][]outgoingT)
+	automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+		outgoing[from] = append(outgoing[from], outgoingT{to, t})
+	})
+
+	initialId := automaton.InitialState()
+	newIds := map[int]int{initialId: 0}
+	queue := []int{initialId}
+	renumbered := fsa.New()
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		if automaton.IsFinalState(current) {
+			renumbered.SetFinalState(newIds[current])
+		}
+
+		for _, item := range outgoing[current] {
+			if _, visited := newIds[item.to]; !visited {
+				newIds[item.to] = len(newIds)
+				queue = append(queue, item.to)
+			}
+			renumbered.AddTransition(newIds[current], newIds[item.to], item.t)
+		}
+	}
+
+	return renumbered
+}
@@ -0,0 +1,38 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the simplification of the exported automata (eps chains contraction and renumbering)
+package transforms_test
+
+import (
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+func TestSimplify(t *testing.T) {
+	automaton := fsa.New()
+	if err := automaton.UnmarshalText([]byte(`final 9
+0 -> 4 Epsilon "if-block-start"
+4 -> 5 Recv "x"
+4 -> 7 Epsilon "else-block-start"
+5 -> 6 Epsilon "if-block-end"
+6 -> 9 Send "y"
+7 -> 6 Epsilon "else-block-end"
+`)); err != nil {
+		t.Fatal(err)
+	}
+
+	// State 4 has more than one outgoing transition and state 9 is final, so only 0, 5, 7 are contracted
+	expected := "final 2\n0 -> 1 Epsilon \"else-block-start\"\n0 -> 1 Recv \"x\"\n1 -> 2 Send \"y\"\n"
+	if text := transforms.Simplify(automaton).String(); text != expected {
+		t.Errorf("expected the simplified automaton\n%s\ngot\n%s", expected, text)
+	}
+
+	// The original automaton isn't modified and the simplification is stable
+	if simplified := transforms.Simplify(automaton); simplified.String() != transforms.Simplify(simplified).String() {
+		t.Errorf("expected the simplification to be idempotent, got\n%s", transforms.Simplify(simplified))
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package transforms declares the types and functions used to transform and work with some type of FSA.
+// Come of the transformation implemented here are standard such as determinization (Subset Construction),
+// minimization but more are specifically related to Choreia (GoroutineFSA extraction & Composition)
+//
+package transforms
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	"github.com/its-hmny/Choreia/internal/logging"
+)
+
+// Returns the families of symmetric Goroutines among the given local views, that is the Goroutines spawned
+// from the same function whose local views are identical (same states and same operations on the same bound
+// channels). Each family has at least two members sorted by spawn order (e.g "main/worker@main.go:9#1", "main/worker@main.go:9#2", ...)
+// while the families are sorted by the name of their first member
+func SymmetricFamilies(localViews map[string]*GoroutineFSA) [][]string {
+	names := make([]string, 0, len(localViews))
+	for name := range localViews {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return lessBySpawnOrder(names[i], names[j]) })
+
+	// The local views are grouped by function and textual form of the automaton
+	groups := make(map[string][]string)
+	keys := []string{}
+	for _, name := range names {
+		text, err := localViews[name].Automaton.MarshalText()
+		if _, isGoroutine := goroutineFunction(name); !isGoroutine || err != nil {
+			continue
+		}
+
+		key := localViews[name].FuncMetadata.Name + "\n" + string(text)
+		if _, exist := groups[key]; !exist {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], name)
+	}
+
+	families := [][]string{}
+	for _, key := range keys {
+		if len(groups[key]) > 1 {
+			families = append(families, groups[key])
+		}
+	}
+
+	sort.Slice(families, func(i, j int) bool { return families[i][0] < families[j][0] })
+	return families
+}
+
+// Composes the local views as LocalViewsComposition does but exploring the configurations up to a permutation of the
+// symmetric Goroutines (see SymmetricFamilies). Since the members of a family are interchangeable only the number of
+// them in each local state matters, so the configurations that differ just by which member is where are the same
+// state of the reduced Choreography Automata. Every member is still part of the composition, the interactions of the
+// family are shown with the name of the member that makes them in the representative configuration of the state.
+// The configurations are visited in the order given by the strategy (see ExplorationStrategy)
+func SymmetricComposition(localViews map[string]*GoroutineFSA, strategy ExplorationStrategy) *fsa.FSA {
+	c := newComposer(localViews, strategy)
+	for _, family := range SymmetricFamilies(localViews) {
+		logging.Infof("Symmetry reduction: %s explored up to permutation", strings.Join(family, ", "))
+
+		members := make([]int, 0, len(family))
+		for _, name := range family {
+			c.familyOf[c.indexOf[name]] = len(c.families)
+			members = append(members, c.indexOf[name])
+		}
+		c.families = append(c.families, members)
+	}
+
+	return c.compose(c.systemStateOf(InitialConfiguration(localViews)))
+}
+
+// Returns the representative of the given configuration among the ones obtained by permuting the members of each
+// family: the members hold the local states in decreasing order (so the ones yet to be spawned come last) while the
+// ones in the same local state are sorted by the position of the messages they have in the buffers, if any. The
+// senders of the buffered messages are renamed accordingly, so that each member keeps the messages it has sent
+func (c *composer) canonical(state systemState) systemState {
+	if len(c.families) == 0 {
+		return state
+	}
+
+	// The position of each buffered message, counted over all the buffers one after another
+	positions, offset := make([][]int, len(state.locals)), 0
+	for b, buffer := range state.buffers {
+		for i, sender := range buffer {
+			positions[sender] = append(positions[sender], offset+i)
+		}
+		offset += c.capacities[b]
+	}
+	lessInstance := func(memberA, memberB int) bool {
+		if state.locals[memberA] != state.locals[memberB] {
+			return state.locals[memberA] > state.locals[memberB]
+		}
+		for i := 0; i < len(positions[memberA]) && i < len(positions[memberB]); i++ {
+			if positions[memberA][i] != positions[memberB][i] {
+				return positions[memberA][i] < positions[memberB][i]
+			}
+		}
+		return len(positions[memberA]) > len(positions[memberB])
+	}
+
+	canonical, renamed := state.clone(), make([]int, len(state.locals))
+	for p := range renamed {
+		renamed[p] = p
+	}
+	for _, family := range c.families {
+		members := append([]int{}, family...)
+		sort.SliceStable(members, func(i, j int) bool { return lessInstance(members[i], members[j]) })
+		for slot, member := range members {
+			canonical.locals[family[slot]] = state.locals[member]
+			renamed[member] = family[slot]
+		}
+	}
+	for b, buffer := range state.buffers {
+		for i, sender := range buffer {
+			canonical.buffers[b][i] = renamed[sender]
+		}
+	}
+	return canonical
+}
+
+// Returns the participant started by the spawn of the given one: the members of a family are interchangeable, so
+// when the given one is already running (as in a representative configuration) a member yet to be spawned starts
+func (c *composer) instanceToSpawn(state systemState, spawned int) int {
+	family, isMember := c.familyOf[spawned]
+	if !isMember || state.locals[spawned] == notSpawned {
+		return spawned
+	}
+	for _, member := range c.families[family] {
+		if state.locals[member] == notSpawned {
+			return member
+		}
+	}
+	return spawned
+}
+
+// Compares two participant names by spawn path and site and then by instance number (e.g "main/worker@main.go:9#2"
+// comes before "main/worker@main.go:9#10"), the names that don't belong to a Goroutine are compared as they are
+func lessBySpawnOrder(nameA, nameB string) bool {
+	spawnA, idA, isGoroutineA := spawnInstance(nameA)
+	spawnB, idB, isGoroutineB := spawnInstance(nameB)
+	if !isGoroutineA || !isGoroutineB || spawnA != spawnB {
+		return nameA < nameB
+	}
+	return idA < idB
+}
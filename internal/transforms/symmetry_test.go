@@ -0,0 +1,113 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the symmetry reduction of the replicated Goroutines during the composition
+package transforms_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+const workerPoolSource = `package main
+
+func worker(jobs chan int, results chan int) {
+	for job := range jobs {
+		results <- job
+	}
+}
+
+func main() {
+	jobs, results := make(chan int), make(chan int)
+	go worker(jobs, results)
+	go worker(jobs, results)
+	go worker(jobs, results)
+	go worker(jobs, results)
+	jobs <- 1
+	<-results
+}
+`
+
+// Three workers taking a single job each, main waits for all of them
+const oneShotSource = `package main
+
+func worker(jobs chan int, done chan bool) {
+	<-jobs
+	done <- true
+}
+
+func main() {
+	jobs, done := make(chan int), make(chan bool)
+	go worker(jobs, done)
+	go worker(jobs, done)
+	go worker(jobs, done)
+	jobs <- 1
+	jobs <- 2
+	jobs <- 3
+	<-done
+	<-done
+	<-done
+}
+`
+
+// Returns the determinized local views of the given source
+func symmetricViews(t *testing.T, source string) map[string]*transforms.GoroutineFSA {
+	path := filepath.Join(t.TempDir(), "pool.go")
+	if err := os.WriteFile(path, []byte(source), 0664); err != nil {
+		t.Fatal(err)
+	}
+
+	localViews := transforms.ExtractGoroutineFSA(meta.ExtractMetadata(path, meta.NoTrace))
+	for _, lView := range localViews {
+		lView.Automaton = transforms.SubsetConstruction(lView.Automaton)
+	}
+	return localViews
+}
+
+func TestSymmetricComposition(t *testing.T) {
+	localViews := symmetricViews(t, workerPoolSource)
+
+	expected := [][]string{{"main/worker@pool.go:11#1", "main/worker@pool.go:12#1", "main/worker@pool.go:13#1", "main/worker@pool.go:14#1"}}
+	if families := transforms.SymmetricFamilies(localViews); !reflect.DeepEqual(families, expected) {
+		t.Fatalf("expected the families %v, got %v", expected, families)
+	}
+
+	full, reduced := transforms.LocalViewsComposition(localViews), transforms.SymmetricComposition(localViews, transforms.BreadthFirst)
+	if reduced.GetLastId() >= full.GetLastId() {
+		t.Errorf("expected less states with the symmetry reduction, got %d (full composition %d)", reduced.GetLastId(), full.GetLastId())
+	}
+}
+
+func TestSymmetricCompositionKeepsEveryMember(t *testing.T) {
+	localViews := symmetricViews(t, oneShotSource)
+	full, reduced := transforms.LocalViewsComposition(localViews), transforms.SymmetricComposition(localViews, transforms.BreadthFirst)
+	if reduced.GetLastId() >= full.GetLastId() {
+		t.Errorf("expected less states with the symmetry reduction, got %d (full composition %d)", reduced.GetLastId(), full.GetLastId())
+	}
+
+	// Each job is taken by a different worker: main terminates only if none of them is left out
+	jobs, finals := 0, 0
+	reduced.ForEachTransition(func(_, _ int, tr fsa.Transition) {
+		if interaction := tr.Payload.(transforms.Interaction); interaction.Channel.Name == "jobs" {
+			jobs++
+		}
+	})
+	reduced.ForEachState(func(id int) {
+		if reduced.IsFinalState(id) {
+			finals++
+		}
+	})
+	if finals != 1 {
+		t.Errorf("expected main and the three workers to terminate, got %d final states\n%s", finals, reduced)
+	}
+	if jobs < 3 {
+		t.Errorf("expected the three jobs to be sent, got %d interactions\n%s", jobs, reduced)
+	}
+}
@@ -0,0 +1,323 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Package transforms declares the types and functions used to transform and work with some type of FSA.
+// Come of the transformation implemented here are standard such as determinization (Subset Construction),
+// minimization but more are specifically related to Choreia (GoroutineFSA extraction & Composition)
+//
+package transforms
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/its-hmny/Choreia/internal/data_structures/fsa"
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+)
+
+// The configuration of TLC saved alongside the specification, the deadlocks are checked by TLC itself
+const tlaConfig = "SPECIFICATION Spec\nINVARIANT TypeOK\n"
+
+// A transition of a local view, along with the participant that makes it
+type tlaMove struct {
+	participant string
+	from, to    int
+	t           fsa.Transition
+}
+
+// An action of the TLA+ specification: the state of the participants (and channels) in which it's enabled
+// and how they change, the participants and buffers not updated are left unchanged
+type tlaAction struct {
+	name      string   // The name of the action (e.g "Sync3")
+	comment   string   // What the action models (e.g "main -> worker: jobs"), the comments are kept in ASCII
+	guards    []string // The conditions on the current state (e.g `pc["main"] = 0`)
+	pcUpdates []string // The new state of the participants that move (e.g `!["main"] = 1`)
+	bufUpdate string   // The update of the buffer of the channel, if any (e.g `!["jobs"] = @ + 1`)
+}
+
+// Exports the TLA+ specification of the given local views (see WriteTLA) to "<basePath>.tla", along with the
+// configuration "<basePath>.cfg" with which TLC checks it. The module is named after the file (e.g "global")
+func ExportTLA(localViews map[string]*GoroutineFSA, basePath string) {
+	module := strings.Map(func(r rune) rune {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, filepath.Base(basePath))
+
+	specFile, err := os.Create(fmt.Sprintf("%s.tla", basePath))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer specFile.Close()
+
+	if err := WriteTLA(specFile, module, localViews); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(fmt.Sprintf("%s.cfg", basePath), []byte(tlaConfig), 0664); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Writes the TLA+ specification of the system described by the given local views, so that it can be model checked
+// with TLC against the invariants (or temporal properties) added by the user. The state is made of two variables:
+// pc, the state of each participant in its local view (NotSpawned until a Spawn transition starts it) and buf, the
+// number of messages in the buffer of each buffered channel. Each action is a possible step of the system:
+//
+//   - a Send and a Recv on the same unbuffered channel made at once by two participants (a synchronization)
+//   - a Send (or Recv) on a buffered channel, enabled while its buffer isn't full (or empty)
+//   - a Spawn, that starts the spawned participant from the initial state of its local view
+//   - any other transition (e.g an eps one), made by the participant alone
+//
+// Differently from the Choreography Automata the buffers are modeled, so the specification allows the interleavings
+// in which the messages are sent before being received. The buffers whose size isn't constant get UnknownCapacity
+// messages. The specification terminates (stuttering) once every participant started is in a final state of its local
+// view, so the deadlocks found by TLC are the states in which some participant is stuck
+func WriteTLA(w io.Writer, module string, localViews map[string]*GoroutineFSA) error {
+	names := make([]string, 0, len(localViews))
+	for name := range localViews {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	channels := tlaChannels(localViews)
+	channelNames := make([]string, 0, len(channels))
+	for name := range channels {
+		channelNames = append(channelNames, name)
+	}
+	sort.Strings(channelNames)
+
+	// The participants that no one spawns start with the program (e.g main and the environment)
+	spawners, initial := spawnersOf(localViews), InitialConfiguration(localViews)
+	start, states, finals, capacities := []string{}, []string{}, []string{}, []string{}
+	for _, name := range names {
+		automaton := localViews[name].Automaton
+		_, isSpawned := spawners[name]
+		if state, isInitial := initial[name]; isInitial || !isSpawned {
+			if !isInitial {
+				state = automaton.InitialState()
+			}
+			start = append(start, fmt.Sprint(state))
+		} else {
+			start = append(start, "NotSpawned")
+		}
+
+		stateIds, finalIds := []string{"NotSpawned"}, []string{}
+		automaton.ForEachState(func(id int) {
+			stateIds = append(stateIds, fmt.Sprint(id))
+			if automaton.IsFinalState(id) {
+				finalIds = append(finalIds, fmt.Sprint(id))
+			}
+		})
+		states, finals = append(states, tlaSet(stateIds)), append(finals, tlaSet(finalIds))
+	}
+	for _, name := range channelNames {
+		switch channel := channels[name]; {
+		case !channel.Async:
+			capacities = append(capacities, "0")
+		case channel.Capacity == meta.UnknownCapacity:
+			capacities = append(capacities, "UnknownCapacity")
+		default:
+			capacities = append(capacities, fmt.Sprint(channel.Capacity))
+		}
+	}
+
+	var spec strings.Builder
+	fmt.Fprintf(&spec, "---------------------------- MODULE %s ----------------------------\n", module)
+	fmt.Fprintln(&spec, `\* Generated by Choreia from the local views of the participants, see transforms.WriteTLA`)
+	fmt.Fprintln(&spec, "EXTENDS Integers")
+	fmt.Fprintln(&spec)
+	fmt.Fprintln(&spec, `NotSpawned == -1 \* The state of the participants that haven't been spawned yet`)
+	fmt.Fprintln(&spec, `UnknownCapacity == 1 \* The buffer size of the channels whose capacity isn't constant`)
+	fmt.Fprintln(&spec)
+	fmt.Fprintf(&spec, "Participants == %s\n", tlaSet(tlaStrings(names)))
+	fmt.Fprintf(&spec, "Channels == %s\n", tlaSet(tlaStrings(channelNames)))
+	fmt.Fprintf(&spec, "Start == %s\n", tlaFunction("p", "Participants", names, start))
+	fmt.Fprintf(&spec, "States == %s\n", tlaFunction("p", "Participants", names, states))
+	fmt.Fprintf(&spec, "Final == %s\n", tlaFunction("p", "Participants", names, finals))
+	fmt.Fprintf(&spec, "Capacity == %s\n", tlaFunction("c", "Channels", channelNames, capacities))
+	fmt.Fprintln(&spec)
+	fmt.Fprintln(&spec, "VARIABLES pc, buf")
+	fmt.Fprintln(&spec, "vars == <<pc, buf>>")
+	fmt.Fprintln(&spec)
+	fmt.Fprintln(&spec, "TypeOK ==")
+	fmt.Fprintln(&spec, `    /\ \A p \in Participants : pc[p] \in States[p]`)
+	fmt.Fprintln(&spec, `    /\ \A c \in Channels : buf[c] \in 0..Capacity[c]`)
+	fmt.Fprintln(&spec)
+	fmt.Fprintln(&spec, `Init == pc = Start /\ buf = [c \in Channels |-> 0]`)
+	fmt.Fprintln(&spec)
+
+	actions := tlaActions(localViews, names, channels)
+	for _, action := range actions {
+		fmt.Fprintf(&spec, "\\* %s\n%s ==\n", action.comment, action.name)
+		for _, guard := range action.guards {
+			fmt.Fprintf(&spec, "    /\\ %s\n", guard)
+		}
+		fmt.Fprintf(&spec, "    /\\ pc' = [pc EXCEPT %s]\n", strings.Join(action.pcUpdates, ", "))
+		if action.bufUpdate != "" {
+			fmt.Fprintf(&spec, "    /\\ buf' = [buf EXCEPT %s]\n", action.bufUpdate)
+		} else {
+			fmt.Fprintln(&spec, "    /\\ UNCHANGED buf")
+		}
+		fmt.Fprintln(&spec)
+	}
+
+	fmt.Fprintln(&spec, `Terminated == \A p \in Participants : pc[p] = NotSpawned \/ pc[p] \in Final[p]`)
+	fmt.Fprintln(&spec, `Done == Terminated /\ UNCHANGED vars`)
+	fmt.Fprintln(&spec)
+	fmt.Fprintln(&spec, "Next ==")
+	for _, action := range actions {
+		fmt.Fprintf(&spec, "    \\/ %s\n", action.name)
+	}
+	fmt.Fprintln(&spec, `    \/ Done`)
+	fmt.Fprintln(&spec)
+	fmt.Fprintln(&spec, `Spec == Init /\ [][Next]_vars`)
+	fmt.Fprintln(&spec)
+	fmt.Fprintln(&spec, `\* The invariants to be checked can be added here (and to the INVARIANT list of the .cfg), e.g`)
+	fmt.Fprintln(&spec, `\* NoLostMessages == Terminated => \A c \in Channels : buf[c] = 0`)
+	fmt.Fprintln(&spec, "=============================================================================")
+
+	_, err := io.WriteString(w, spec.String())
+	return err
+}
+
+// Returns the channels on which the given local views send or receive, a channel is buffered if any of its
+// transitions says so (the buffer could be known only on one side, as in newComposer)
+func tlaChannels(localViews map[string]*GoroutineFSA) map[string]meta.ChanMetadata {
+	channels := make(map[string]meta.ChanMetadata)
+	for _, lView := range localViews {
+		lView.Automaton.ForEachTransition(func(_, _ int, t fsa.Transition) {
+			if t.Move != fsa.Send && t.Move != fsa.Recv {
+				return
+			}
+			channel, _ := t.Payload.(meta.ChanMetadata)
+			if known, exist := channels[t.Label]; !exist || (!known.Async && channel.Async) {
+				channels[t.Label] = meta.ChanMetadata{Name: t.Label, Async: channel.Async, Capacity: channel.Capacity}
+			}
+		})
+	}
+	return channels
+}
+
+// Returns the actions of the specification, in the order of the participants (sorted by name) and of their moves
+func tlaActions(localViews map[string]*GoroutineFSA, names []string, channels map[string]meta.ChanMetadata) []tlaAction {
+	moves := []tlaMove{}
+	for _, name := range names {
+		viewMoves := []tlaMove{}
+		localViews[name].Automaton.ForEachTransition(func(from, to int, t fsa.Transition) {
+			viewMoves = append(viewMoves, tlaMove{name, from, to, t})
+		})
+		sort.SliceStable(viewMoves, func(i, j int) bool {
+			a, b := viewMoves[i], viewMoves[j]
+			if a.from != b.from {
+				return a.from < b.from
+			}
+			if a.to != b.to {
+				return a.to < b.to
+			}
+			if a.t.Move != b.t.Move {
+				return a.t.Move < b.t.Move
+			}
+			return a.t.Label < b.t.Label
+		})
+		moves = append(moves, viewMoves...)
+	}
+
+	actions := []tlaAction{}
+	addAction := func(kind string, action tlaAction) {
+		action.name = fmt.Sprintf("%s%d", kind, len(actions)+1)
+		actions = append(actions, action)
+	}
+	// The guard and the update of the given move, to be combined in an action
+	guard := func(m tlaMove) string { return fmt.Sprintf("pc[%s] = %d", tlaString(m.participant), m.from) }
+	update := func(m tlaMove) string { return fmt.Sprintf("![%s] = %d", tlaString(m.participant), m.to) }
+
+	for _, m := range moves {
+		channel, isBuffered := tlaString(m.t.Label), channels[m.t.Label].Async
+		steps := fmt.Sprintf("%d -> %d", m.from, m.to)
+
+		switch {
+		case m.t.Move == fsa.Send && !isBuffered:
+			// The receivers are matched here, so that each synchronization is added once
+			for _, other := range moves {
+				if other.t.Move != fsa.Recv || other.t.Label != m.t.Label || other.participant == m.participant {
+					continue
+				}
+				addAction("Sync", tlaAction{
+					comment:   fmt.Sprintf("%s -> %s: %s (%s, %d -> %d)", m.participant, other.participant, m.t.Label, steps, other.from, other.to),
+					guards:    []string{guard(m), guard(other)},
+					pcUpdates: []string{update(m), update(other)},
+				})
+			}
+		case m.t.Move == fsa.Send:
+			addAction("Send", tlaAction{
+				comment:   fmt.Sprintf("%s sends on %s (%s)", m.participant, m.t.Label, steps),
+				guards:    []string{guard(m), fmt.Sprintf("buf[%s] < Capacity[%s]", channel, channel)},
+				pcUpdates: []string{update(m)},
+				bufUpdate: fmt.Sprintf("![%s] = @ + 1", channel),
+			})
+		case m.t.Move == fsa.Recv && isBuffered:
+			addAction("Recv", tlaAction{
+				comment:   fmt.Sprintf("%s receives from %s (%s)", m.participant, m.t.Label, steps),
+				guards:    []string{guard(m), fmt.Sprintf("buf[%s] > 0", channel)},
+				pcUpdates: []string{update(m)},
+				bufUpdate: fmt.Sprintf("![%s] = @ - 1", channel),
+			})
+		case m.t.Move == fsa.Recv:
+			// Already added as a synchronization with each sender
+		case m.t.Move == fsa.Spawn && localViews[m.t.Label] != nil:
+			spawned := tlaString(m.t.Label)
+			addAction("Spawn", tlaAction{
+				comment:   fmt.Sprintf("%s spawns %s (%s)", m.participant, m.t.Label, steps),
+				guards:    []string{guard(m), fmt.Sprintf("pc[%s] = NotSpawned", spawned)},
+				pcUpdates: []string{update(m), fmt.Sprintf("![%s] = %d", spawned, localViews[m.t.Label].Automaton.InitialState())},
+			})
+		default:
+			addAction("Step", tlaAction{
+				comment:   fmt.Sprintf("%s: %s %s (%s)", m.participant, m.t.Move, m.t.Label, steps),
+				guards:    []string{guard(m)},
+				pcUpdates: []string{update(m)},
+			})
+		}
+	}
+	return actions
+}
+
+// Returns the TLA+ function over the given domain that maps each key to the respective value, as a CASE expression
+// (e.g `[p \in Participants |-> CASE p = "main" -> 0 [] p = "worker" -> NotSpawned]`)
+func tlaFunction(variable, domain string, keys, values []string) string {
+	if len(keys) == 0 {
+		return fmt.Sprintf(`[%s \in %s |-> 0]`, variable, domain)
+	}
+
+	arms := make([]string, len(keys))
+	for i, key := range keys {
+		arms[i] = fmt.Sprintf("%s = %s -> %s", variable, tlaString(key), values[i])
+	}
+	return fmt.Sprintf("[%s \\in %s |->\n    CASE %s]", variable, domain, strings.Join(arms, "\n      [] "))
+}
+
+// Returns the TLA+ set literal with the given elements (e.g `{0, 1, 2}`)
+func tlaSet(elements []string) string {
+	return fmt.Sprintf("{%s}", strings.Join(elements, ", "))
+}
+
+// Returns the TLA+ string literals of each one of the given strings
+func tlaStrings(values []string) []string {
+	literals := make([]string, len(values))
+	for i, value := range values {
+		literals[i] = tlaString(value)
+	}
+	return literals
+}
+
+// Returns the TLA+ string literal of the given string, where the backslashes and the quotes are escaped
+func tlaString(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
@@ -0,0 +1,70 @@
+// Copyright 2020 Enea Guidi (hmny). All rights reserved.
+// This file are distributed under the General Public License v 3.0.
+// A copy of abovesaid license can be found in the LICENSE file.
+
+// Tests for the TLA+ specification generated from the local views
+package transforms_test
+
+import (
+	"strings"
+	"testing"
+
+	meta "github.com/its-hmny/Choreia/internal/static_analysis"
+	"github.com/its-hmny/Choreia/internal/transforms"
+)
+
+// Returns the TLA+ specification of the (deterministic) local views of the given example
+func exampleSpec(t *testing.T, example string) string {
+	fileMetadata := meta.ExtractMetadata("../../example/"+example, meta.NoTrace)
+	localViews := transforms.ExtractGoroutineFSA(fileMetadata)
+	for _, lView := range localViews {
+		lView.Automaton = transforms.SubsetConstruction(lView.Automaton)
+	}
+
+	var spec strings.Builder
+	if err := transforms.WriteTLA(&spec, "global", localViews); err != nil {
+		t.Fatal(err)
+	}
+	return spec.String()
+}
+
+func TestWriteTLA(t *testing.T) {
+	spec := exampleSpec(t, "PingPong.go")
+
+	expected := []string{
+		"---- MODULE global ----",
+		`Capacity == [c \in Channels |->` + "\n    CASE c = \"ping\" -> 0\n      [] c = \"pong\" -> 0]",
+		// The players are spawned by main, only the latter starts with the program
+		`CASE p = "main" -> 0` + "\n      [] p = \"main/player@PingPong.go:20#1\" -> NotSpawned",
+		"Spawn1 ==\n    /\\ pc[\"main\"] = 0\n    /\\ pc[\"main/player@PingPong.go:20#1\"] = NotSpawned\n",
+		// The messages on the unbuffered channels are exchanged at once by the two participants
+		"\\* main -> main/player@PingPong.go:20#1: ping (2 -> 3, 0 -> 1)\nSync3 ==\n",
+		"    /\\ pc' = [pc EXCEPT ![\"main\"] = 3, ![\"main/player@PingPong.go:20#1\"] = 1]\n    /\\ UNCHANGED buf\n",
+		"    \\/ Sync9\n    \\/ Done\n",
+	}
+	for _, fragment := range expected {
+		if !strings.Contains(spec, fragment) {
+			t.Errorf("expected the specification to contain\n%s\ngot\n%s", fragment, spec)
+		}
+	}
+	if strings.Contains(spec, "Recv") || strings.Contains(spec, "buf' =") {
+		t.Errorf("expected no buffered operation on the unbuffered channels, got\n%s", spec)
+	}
+}
+
+func TestWriteTLABuffers(t *testing.T) {
+	spec := exampleSpec(t, "ProducerConsumer.go")
+
+	// The queue is buffered (2 messages), done is not
+	expected := []string{
+		"CASE c = \"done\" -> 0\n      [] c = \"queue\" -> 2]",
+		"    /\\ buf[\"queue\"] < Capacity[\"queue\"]\n    /\\ pc' = [pc EXCEPT ![\"main/producer@ProducerConsumer.go:25#1\"] = 1]\n    /\\ buf' = [buf EXCEPT ![\"queue\"] = @ + 1]\n",
+		"    /\\ buf[\"queue\"] > 0\n    /\\ pc' = [pc EXCEPT ![\"main/consumer@ProducerConsumer.go:26#1\"] = 1]\n    /\\ buf' = [buf EXCEPT ![\"queue\"] = @ - 1]\n",
+		"\\* main/producer@ProducerConsumer.go:25#1 -> main: done",
+	}
+	for _, fragment := range expected {
+		if !strings.Contains(spec, fragment) {
+			t.Errorf("expected the specification to contain\n%s\ngot\n%s", fragment, spec)
+		}
+	}
+}